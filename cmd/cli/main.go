@@ -1,7 +1,217 @@
+// Command cli drives a relay from scripts
+// "cli job" runs a one-shot agent task through the admin API (POST
+// /admin/jobs) and prints its result for CI: the relay's JSON result, or a
+// report with one step per stage (-format json or junit). The exit code is
+// 0 if every step passed, 1 if one failed, 2 for bad arguments and 3 if the
+// relay could not run the job.
+//
+// For relays with adminAuth keys, -key-id (or OUROCODUS_ADMIN_KEY_ID) names
+// the key to sign the request with; its secret is read from the environment
+// variable named by -key-secret, as the relay reads it.
 package main
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay"
+	"github.com/2389-research/ourocodus/pkg/report"
+)
+
+// formatResult prints the relay's JobResult as is
+const formatResult = "result"
+
+// errRefused marks a job the relay rejected as invalid
+var errRefused = errors.New("relay refused the job")
+
+// prompts collects repeated -prompt flags
+type prompts []string
+
+func (p *prompts) String() string     { return strings.Join(*p, ", ") }
+func (p *prompts) Set(v string) error { *p = append(*p, v); return nil }
 
 func main() {
-	fmt.Println("CLI - placeholder")
+	if len(os.Args) < 2 || os.Args[1] != "job" {
+		fmt.Fprintln(os.Stderr, "usage: cli job -role ROLE -workspace DIR -prompt TEXT [-prompt TEXT...] [flags]")
+		os.Exit(report.ExitUsage)
+	}
+	os.Exit(runJob(os.Args[2:], os.Stdout, os.Stderr))
+}
+
+// runJob parses the job flags, runs the job and writes its result to stdout
+// or -o; returns the exit code
+func runJob(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("job", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	admin := flags.String("admin", "http://127.0.0.1:8081", "relay admin API URL")
+	format := flags.String("format", formatResult, "output: result (the relay's JSON), json or junit (a report with one step per stage)")
+	outPath := flags.String("o", "", "write the output to this file instead of stdout")
+	keyID := flags.String("key-id", os.Getenv("OUROCODUS_ADMIN_KEY_ID"), "admin key to sign the request with (adminAuth.keys[].id); empty sends it unsigned")
+	keySecret := flags.String("key-secret", "OUROCODUS_ADMIN_KEY", "environment variable holding the admin key's secret")
+	var req relay.JobRequest
+	var ps prompts
+	var timeout time.Duration
+	flags.StringVar(&req.Role, "role", "", "agent role")
+	flags.StringVar(&req.Workspace, "workspace", "", "absolute path of the agent's workspace on the relay host")
+	flags.StringVar(&req.SeedFrom, "seed-from", "", "directory to seed a missing workspace from")
+	flags.Var(&ps, "prompt", "prompt to send; repeat to send several in order")
+	flags.DurationVar(&timeout, "timeout", 30*time.Minute, "time limit for the whole job (0 = none)")
+	flags.BoolVar(&req.Diff, "diff", false, "include git diff HEAD of the workspace in the result")
+	flags.StringVar(&req.ArchivePath, "archive", "", "path on the relay host to export the session archive to")
+	if err := flags.Parse(args); err != nil {
+		return report.ExitUsage
+	}
+	req.Prompts = ps
+	req.Timeout = relay.Duration(timeout)
+	if err := req.Validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return report.ExitUsage
+	}
+	if *format != formatResult && *format != report.FormatJSON && *format != report.FormatJUnit {
+		fmt.Fprintf(stderr, "unknown format %q\n", *format)
+		return report.ExitUsage
+	}
+
+	var key *relay.AdminKey
+	if *keyID != "" {
+		k, err := relay.NewAdminKey(relay.EnvSecrets(os.LookupEnv), *keyID, *keySecret)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return report.ExitUsage
+		}
+		key = &k
+	}
+
+	result, err := postJob(*admin, req, key)
+	if errors.Is(err, errRefused) {
+		fmt.Fprintln(stderr, err)
+		return report.ExitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "relay error: %v\n", err)
+		return report.ExitError
+	}
+
+	out := stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return report.ExitError
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+	rep := jobReport(req, result)
+	if *format == formatResult {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(result)
+	} else {
+		err = report.Write(out, *format, rep)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return report.ExitError
+	}
+	return rep.ExitCode()
+}
+
+// postJob runs req on the relay's admin API and returns its result
+// The request is signed with key unless it is nil.
+func postJob(admin string, req relay.JobRequest, key *relay.AdminKey) (relay.JobResult, error) {
+	var result relay.JobResult
+	body, err := json.Marshal(req)
+	if err != nil {
+		return result, err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(admin, "/")+"/admin/jobs", bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if key != nil {
+		if err := relay.SignAdminRequest(httpReq, *key, time.Now()); err != nil {
+			return result, err
+		}
+	}
+	resp, err := http.DefaultClient.Do(httpReq) // #nosec G107 -- the admin URL is the operator's
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusBadRequest:
+		return result, fmt.Errorf("%w: %s", errRefused, bytes.TrimSpace(data))
+	case resp.StatusCode != http.StatusOK:
+		return result, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("invalid response: %w", err)
+	}
+	return result, nil
+}
+
+// jobReport describes a job as steps: spawning the agent, each prompt (those
+// after a failure skipped), then the diff and archive if requested (pure function)
+func jobReport(req relay.JobRequest, result relay.JobResult) *report.Report {
+	rep := &report.Report{Name: "job " + req.Role, Duration: ms(result.DurationMs)}
+	var spawnErr error
+	if result.SessionID == "" {
+		spawnErr = errors.New(result.Error)
+	}
+	rep.Add("spawn", ms(result.SpawnMs), spawnErr)
+
+	for i, prompt := range req.Prompts {
+		name := fmt.Sprintf("prompt %d", i+1)
+		if i >= len(result.Turns) {
+			rep.Skip(name, "an earlier step failed")
+			continue
+		}
+		turn := result.Turns[i]
+		var err error
+		if turn.Error != "" {
+			err = errors.New(turn.Error)
+		}
+		step := rep.Add(name, ms(turn.DurationMs), err)
+		step.Output = "> " + prompt + "\n" + turn.Reply
+	}
+
+	if req.Diff {
+		addOptional(rep, "diff", result.SessionID != "", result.DiffError).Output = result.Diff
+	}
+	if req.ArchivePath != "" {
+		addOptional(rep, "archive", result.SessionID != "", result.ArchiveErr)
+	}
+	return rep
+}
+
+// addOptional adds a collection step that only runs if the agent spawned
+func addOptional(rep *report.Report, name string, ran bool, errText string) *report.Step {
+	if !ran {
+		rep.Skip(name, "the agent did not start")
+		return &report.Step{}
+	}
+	var err error
+	if errText != "" {
+		err = errors.New(errText)
+	}
+	return rep.Add(name, 0, err)
+}
+
+// ms converts milliseconds to a duration (pure function)
+func ms(n int64) time.Duration {
+	return time.Duration(n) * time.Millisecond
 }
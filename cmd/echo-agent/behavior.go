@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// envPrefix is prepended to a flag's upper-cased name (dashes become
+// underscores) to get the environment variable setting its default, e.g.
+// ECHO_AGENT_FAIL_RATE for -fail-rate
+// Agents spawned by the relay get no extra arguments, so the environment is
+// the only way to configure them there.
+const envPrefix = "ECHO_AGENT_"
+
+// behavior is how the agent simulates a real one; the zero value echoes
+// instantly, word by word
+type behavior struct {
+	latency       time.Duration // Delay before answering each request
+	jitter        time.Duration // Random extra delay, up to this much
+	failRate      float64       // Share of requests answered with an internal error
+	malformedRate float64       // Share of requests answered with invalid JSON
+	notifications int           // agent/progress notifications sent before each answer
+	chunkSize     int           // Runes per streamed delta; 0 streams word by word, below 0 not at all
+	chunkDelay    time.Duration // Delay between streamed deltas
+	rng           *rand.Rand
+}
+
+// parseBehavior reads the agent's flags from args, falling back to the
+// environment looked up with getenv
+// Unknown flags are an error, except --workspace which the ACP client always
+// passes.
+func parseBehavior(args []string, getenv func(string) string) (behavior, error) {
+	var b behavior
+	var seed uint64
+	fs := flag.NewFlagSet("echo-agent", flag.ContinueOnError)
+	fs.String("workspace", "", "Workspace directory (ignored)")
+	fs.DurationVar(&b.latency, "latency", 0, "Delay before answering each request")
+	fs.DurationVar(&b.jitter, "jitter", 0, "Random extra delay per request, up to this much")
+	fs.Float64Var(&b.failRate, "fail-rate", 0, "Share of requests (0-1) answered with an internal error")
+	fs.Float64Var(&b.malformedRate, "malformed-rate", 0, "Share of requests (0-1) answered with invalid JSON")
+	fs.IntVar(&b.notifications, "notifications", 0, "agent/progress notifications sent before each answer")
+	fs.IntVar(&b.chunkSize, "chunk-size", 0, "Runes per streamed delta (0 = word by word, -1 = no streaming)")
+	fs.DurationVar(&b.chunkDelay, "chunk-delay", 0, "Delay between streamed deltas")
+	fs.Uint64Var(&seed, "seed", 0, "Random seed for reproducible runs (0 = random)")
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value := getenv(name); value != "" && envErr == nil {
+			if err := fs.Set(f.Name, value); err != nil {
+				envErr = fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	})
+	if envErr != nil {
+		return behavior{}, envErr
+	}
+	if err := fs.Parse(args); err != nil {
+		return behavior{}, err
+	}
+
+	switch {
+	case b.latency < 0 || b.jitter < 0 || b.chunkDelay < 0:
+		return behavior{}, fmt.Errorf("delays must not be negative")
+	case b.failRate < 0 || b.failRate > 1:
+		return behavior{}, fmt.Errorf("fail-rate must be between 0 and 1")
+	case b.malformedRate < 0 || b.malformedRate > 1:
+		return behavior{}, fmt.Errorf("malformed-rate must be between 0 and 1")
+	case b.notifications < 0:
+		return behavior{}, fmt.Errorf("notifications must not be negative")
+	}
+
+	if seed == 0 {
+		seed = rand.Uint64() // #nosec G404 -- simulated faults need no crypto randomness
+	}
+	b.rng = rand.New(rand.NewPCG(seed, seed)) // #nosec G404 -- as above
+	return b, nil
+}
+
+// delay returns how long to wait before answering a request
+func (b *behavior) delay() time.Duration {
+	if b.jitter <= 0 {
+		return b.latency
+	}
+	return b.latency + time.Duration(b.rng.Int64N(int64(b.jitter)+1))
+}
+
+// fails reports whether a request should fail at the given rate
+func (b *behavior) fails(rate float64) bool {
+	return rate > 0 && b.rng.Float64() < rate
+}
+
+// chunk splits content into the deltas to stream (pure function)
+func chunk(content string, size int) []string {
+	switch {
+	case size < 0 || content == "":
+		return nil
+	case size == 0:
+		var words []string
+		for _, word := range strings.SplitAfter(content, " ") {
+			if word != "" {
+				words = append(words, word)
+			}
+		}
+		return words
+	}
+
+	var chunks []string
+	for len(content) > 0 {
+		end, runes := 0, 0
+		for end < len(content) && runes < size {
+			_, width := utf8.DecodeRuneInString(content[end:])
+			end += width
+			runes++
+		}
+		chunks = append(chunks, content[:end])
+		content = content[end:]
+	}
+	return chunks
+}
@@ -1,54 +1,152 @@
 package main
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"os"
 
 	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/e2e"
 )
 
+// methodProgress is the notification sent with -notifications; the ACP
+// client ignores it, as it would any notification it does not know
+const methodProgress = "agent/progress"
+
+// codeRequestCancelled answers requests interrupted by agent/cancel
+const codeRequestCancelled = -32800
+
+// agent answers requests read from its inbox, one at a time
+type agent struct {
+	behavior
+	box   *e2e.Box // Opens prompts and seals replies when spawned with a payload key
+	inbox *acp.Inbox
+}
+
 func main() {
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := scanner.Bytes()
-
-		// Parse incoming JSON-RPC request
-		var req acp.Request
-		if err := json.Unmarshal(line, &req); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to parse request: %v\n", err)
-			continue
-		}
+	b, err := parseBehavior(os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "echo-agent: %v\n", err)
+		os.Exit(2)
+	}
+	box, err := e2e.FromEnv(os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "echo-agent: %v\n", err)
+		os.Exit(2)
+	}
 
-		// Handle sendMessage method
-		if req.Method == acp.MethodSendMessage {
-			// Extract params
-			paramsData, _ := json.Marshal(req.Params)
-			var params acp.SendMessageParams
-			if err := json.Unmarshal(paramsData, &params); err != nil {
-				sendError(req.ID, -32602, "Invalid params")
-				continue
-			}
-
-			// Echo the message back
-			msg := acp.AgentMessage{
-				Type:    "text",
-				Content: fmt.Sprintf("Echo: %s", params.Content),
-			}
-
-			sendResponse(req.ID, msg)
-		} else {
-			sendError(req.ID, -32601, "Method not found")
+	a := &agent{behavior: b, box: box, inbox: acp.NewInbox(os.Stdin)}
+	for {
+		line, ok := a.inbox.Next()
+		if !ok {
+			break
 		}
+		a.handle(line)
 	}
 
-	if err := scanner.Err(); err != nil {
+	if err := a.inbox.Err(); err != nil {
 		fmt.Fprintf(os.Stderr, "Scanner error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// handle answers one line from stdin
+func (a *agent) handle(line []byte) {
+	// Parse incoming JSON-RPC request
+	var req acp.Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse request: %v\n", err)
+		return
+	}
+
+	// Notifications (e.g. agent/cancel) have no id and get no reply; a
+	// cancel arriving here is for a request already answered
+	if req.ID == nil {
+		return
+	}
+
+	if req.Method != acp.MethodSendMessage {
+		sendError(req.ID, acp.CodeMethodNotFound, "Method not found")
+		return
+	}
+
+	// Extract params
+	paramsData, _ := json.Marshal(req.Params)
+	var params acp.SendMessageParams
+	if err := json.Unmarshal(paramsData, &params); err != nil {
+		sendError(req.ID, acp.CodeInvalidParams, "Invalid params")
+		return
+	}
+
+	for i := 0; i < a.notifications; i++ {
+		sendNotification(methodProgress, map[string]interface{}{"requestId": req.ID, "message": fmt.Sprintf("working (%d/%d)", i+1, a.notifications)})
+	}
+	if !a.inbox.Wait(req.ID, a.delay()) {
+		sendError(req.ID, codeRequestCancelled, "Request cancelled")
+		return
+	}
+	switch {
+	case a.fails(a.failRate):
+		sendError(req.ID, acp.CodeInternalError, "Simulated failure")
+		return
+	case a.fails(a.malformedRate):
+		fmt.Println(`{"jsonrpc":"2.0","id":`)
+		return
+	}
+
+	prompt := params.Content
+	if a.box != nil {
+		var err error
+		if prompt, err = a.box.Open(prompt); err != nil {
+			sendError(req.ID, acp.CodeInvalidParams, "Cannot open sealed prompt")
+			return
+		}
+	}
+
+	// Echo the message back, streaming it in chunks first
+	reply := fmt.Sprintf("Echo: %s", prompt)
+	requestID, ok := req.ID.(float64)
+	for i, content := range chunk(reply, a.chunkSize) {
+		if i > 0 && !a.inbox.Wait(req.ID, a.chunkDelay) {
+			sendError(req.ID, codeRequestCancelled, "Request cancelled")
+			return
+		}
+		if ok {
+			sendNotification(acp.MethodDelta, acp.Delta{RequestID: int(requestID), Seq: i + 1, Content: a.seal(content)})
+		}
+	}
+	sendResponse(req.ID, acp.AgentMessage{Type: "text", Content: a.seal(reply)})
+}
+
+// seal encrypts reply content when the agent has a payload key; each delta is
+// sealed on its own so clients can open them as they arrive
+func (a *agent) seal(content string) string {
+	if a.box == nil {
+		return content
+	}
+	sealed, err := a.box.Seal(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to seal reply: %v\n", err)
+		os.Exit(1)
+	}
+	return sealed
+}
+
+// sendNotification writes a notification with the given params
+func sendNotification(method string, params interface{}) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal %s params: %v\n", method, err)
+		return
+	}
+	data, err = json.Marshal(acp.Notification{JSONRPC: "2.0", Method: method, Params: data})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal %s: %v\n", method, err)
+		return
+	}
+	fmt.Println(string(data))
+}
+
 func sendResponse(id interface{}, result interface{}) {
 	resp := acp.Response{
 		JSONRPC: "2.0",
@@ -0,0 +1,222 @@
+// Mock-claude stands in for claude-code-acp in end-to-end tests: it speaks
+// the whole protocol the relay uses but scripts its replies, so it needs no
+// API key.
+//
+// Prompts starting with "/tool NAME [JSON args]" make it ask to run a tool;
+// the turn ends with the pending call, which the next agent/toolCall request
+// (or a "yes"/"no" prompt) approves or denies. Any other prompt gets a
+// streamed canned reply that agent/cancel interrupts.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+const (
+	agentName    = "mock-claude"
+	agentVersion = "0.1.0"
+)
+
+// codeRequestCancelled answers requests interrupted by agent/cancel
+const codeRequestCancelled = -32800
+
+// agent is the mock's protocol state
+type agent struct {
+	inbox       *acp.Inbox
+	workspace   string
+	chunkDelay  time.Duration
+	requireInit bool
+	initialized bool
+	turns       int
+	toolCalls   int
+	pending     *acp.ToolCall // Tool call awaiting approval
+}
+
+func main() {
+	fs := flag.NewFlagSet(agentName, flag.ExitOnError)
+	workspace := fs.String("workspace", "", "Workspace directory")
+	chunkDelay := fs.Duration("chunk-delay", 10*time.Millisecond, "Delay between streamed deltas")
+	requireInit := fs.Bool("require-initialize", false, "Reject requests before initialize, like the real agent")
+	_ = fs.Parse(os.Args[1:])
+
+	a := &agent{inbox: acp.NewInbox(os.Stdin), workspace: *workspace, chunkDelay: *chunkDelay, requireInit: *requireInit}
+	for {
+		line, ok := a.inbox.Next()
+		if !ok {
+			break
+		}
+		a.handle(line)
+	}
+
+	if err := a.inbox.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Scanner error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handle answers one line from stdin
+func (a *agent) handle(line []byte) {
+	var req struct {
+		ID      interface{}     `json:"id"`
+		Params  json.RawMessage `json:"params"`
+		JSONRPC string          `json:"jsonrpc"`
+		Method  string          `json:"method"`
+	}
+	if err := json.Unmarshal(line, &req); err != nil {
+		send(acp.Response{JSONRPC: "2.0", Error: &acp.Error{Code: acp.CodeParseError, Message: "Parse error"}})
+		return
+	}
+	if req.ID == nil {
+		return // Notifications; a cancel here is for a request already answered
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		sendError(req.ID, acp.CodeInvalidRequest, "Invalid request")
+		return
+	}
+	if a.requireInit && !a.initialized && req.Method != acp.MethodInitialize {
+		sendError(req.ID, acp.CodeInvalidRequest, "Not initialized")
+		return
+	}
+
+	switch req.Method {
+	case acp.MethodInitialize:
+		var params acp.InitializeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			sendError(req.ID, acp.CodeInvalidParams, "Invalid params")
+			return
+		}
+		a.initialized = true
+		sendResponse(req.ID, acp.InitializeResult{
+			AgentName:       agentName,
+			AgentVersion:    agentVersion,
+			ProtocolVersion: acp.ProtocolVersion,
+			Capabilities:    acp.AgentCapabilities{Streaming: true, Cancel: true, ToolApproval: true},
+		})
+
+	case acp.MethodSendMessage:
+		var params acp.SendMessageParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			sendError(req.ID, acp.CodeInvalidParams, "Invalid params")
+			return
+		}
+		a.turns++
+		if a.pending != nil {
+			answer := strings.ToLower(strings.TrimSpace(params.Content))
+			sendResponse(req.ID, a.resolve(answer == "y" || answer == "yes" || answer == "approve", nil))
+			return
+		}
+		if name, args, ok := parseToolPrompt(params.Content); ok {
+			a.toolCalls++
+			a.pending = &acp.ToolCall{ID: fmt.Sprintf("tool-%d", a.toolCalls), Name: name, Args: args}
+			sendResponse(req.ID, acp.AgentMessage{Type: acp.MessageTypeParts, Parts: []acp.Part{
+				{Type: acp.PartTypeText, Text: fmt.Sprintf("I need to run %s. Approve?", name)},
+				{Type: acp.PartTypeToolCall, ToolCall: a.pending},
+			}})
+			return
+		}
+		a.stream(req.ID, fmt.Sprintf("Mock Claude here. You said: %s", params.Content))
+
+	case acp.MethodToolCall:
+		var params acp.ToolCallParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			sendError(req.ID, acp.CodeInvalidParams, "Invalid params")
+			return
+		}
+		if a.pending == nil || a.pending.ID != params.ToolCallID {
+			sendError(req.ID, acp.CodeInvalidParams, fmt.Sprintf("No tool call %q awaiting approval", params.ToolCallID))
+			return
+		}
+		sendResponse(req.ID, a.resolve(params.Approved, params.Result))
+
+	case acp.MethodGetContext:
+		sendResponse(req.ID, map[string]interface{}{"workspace": a.workspace, "turns": a.turns})
+
+	default:
+		sendError(req.ID, acp.CodeMethodNotFound, "Method not found")
+	}
+}
+
+// resolve approves or denies the pending tool call and describes the outcome
+// A ran call reports the client's result as its output.
+func (a *agent) resolve(approved bool, ran *acp.ToolResult) acp.AgentMessage {
+	call := a.pending
+	a.pending = nil
+	if !approved {
+		return acp.AgentMessage{Type: "text", Content: fmt.Sprintf("Okay, I won't run %s.", call.Name)}
+	}
+	var output interface{} = "ok"
+	if ran != nil {
+		output = ran
+	}
+	result, _ := json.Marshal(map[string]interface{}{"toolCallId": call.ID, "name": call.Name, "args": call.Args, "output": output})
+	return acp.AgentMessage{Type: acp.MessageTypeParts, Parts: []acp.Part{
+		{Type: acp.PartTypeText, Text: fmt.Sprintf("Ran %s.", call.Name)},
+		{Type: acp.PartTypeData, Data: result},
+	}}
+}
+
+// stream sends content word by word, then as the response to id, unless an
+// agent/cancel for id arrives first
+func (a *agent) stream(id interface{}, content string) {
+	requestID, ok := id.(float64)
+	seq := 0
+	for _, word := range strings.SplitAfter(content, " ") {
+		if word == "" {
+			continue
+		}
+		if seq > 0 && !a.inbox.Wait(id, a.chunkDelay) {
+			sendError(id, codeRequestCancelled, "Request cancelled")
+			return
+		}
+		seq++
+		if ok {
+			params, _ := json.Marshal(acp.Delta{RequestID: int(requestID), Seq: seq, Content: word})
+			send(acp.Notification{JSONRPC: "2.0", Method: acp.MethodDelta, Params: params})
+		}
+	}
+	sendResponse(id, acp.AgentMessage{Type: "text", Content: content})
+}
+
+// parseToolPrompt reads a "/tool NAME [JSON args]" prompt (pure function)
+func parseToolPrompt(content string) (string, map[string]interface{}, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(content), "/tool ")
+	if !ok {
+		return "", nil, false
+	}
+	name, rawArgs, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	if name == "" {
+		return "", nil, false
+	}
+	args := map[string]interface{}{}
+	if rawArgs = strings.TrimSpace(rawArgs); rawArgs != "" {
+		if err := json.Unmarshal([]byte(rawArgs), &args); err != nil {
+			args = map[string]interface{}{"input": rawArgs}
+		}
+	}
+	return name, args, true
+}
+
+func sendResponse(id interface{}, result interface{}) {
+	send(acp.Response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func sendError(id interface{}, code int, message string) {
+	send(acp.Response{JSONRPC: "2.0", ID: id, Error: &acp.Error{Code: code, Message: message}})
+}
+
+// send writes one message to stdout
+func send(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal message: %v\n", err)
+		return
+	}
+	fmt.Println(string(data))
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -10,40 +14,388 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/integrations/github"
+	"github.com/2389-research/ourocodus/pkg/policy"
+	"github.com/2389-research/ourocodus/pkg/redact"
 	"github.com/2389-research/ourocodus/pkg/relay"
-)
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+	"github.com/2389-research/ourocodus/pkg/sandbox"
 
-const (
-	port            = 8080
-	shutdownTimeout = 10 * time.Second
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the "pgx" driver for store.driver
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate-config" {
+		os.Exit(runValidateConfig(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	configPath := flag.String("config", "", "path to JSON config file (defaults used if empty)")
+	debugAddr := flag.String("debug-addr", "", "serve pprof and runtime state on this address (overrides config debugAddr)")
+	flag.Parse()
+
+	cfg, err := relay.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Config error: %v", err)
+	}
+	if *debugAddr != "" {
+		cfg.DebugAddr = *debugAddr
+	}
+
+	// Every log line passes through the redactor so credentials never reach logs
+	redactor, err := cfg.Redaction.NewRedactor()
+	if err != nil {
+		log.Fatalf("Config error: %v", err)
+	}
+	logger := redact.NewLogger(&relay.StdLogger{}, redactor)
+	clock := &relay.SystemClock{}
+
+	serverIDGen, err := relay.NewIDGenerator(cfg.IDs.Strategy, cfg.IDs.ServerPrefix)
+	if err != nil {
+		log.Fatalf("Config error: %v", err)
+	}
+	sessionIDGen, err := relay.NewIDGenerator(cfg.IDs.Strategy, cfg.IDs.SessionPrefix)
+	if err != nil {
+		log.Fatalf("Config error: %v", err)
+	}
+
+	agentStats := relay.NewAgentStats(clock)
+	middleware := []session.Middleware{session.LoggingMiddleware(logger), agentStats.Middleware()}
+	if cfg.Redaction.LogTranscripts {
+		middleware = append(middleware, session.TranscriptMiddleware(logger, redactor))
+	}
+
+	// The breaker's notifier needs the manager, which needs the breaker's
+	// middleware: bind the notifier after the manager is built
+	var sessionManager *session.Manager
+	var breaker *session.CircuitBreaker
+	if cfg.CircuitBreaker.Threshold > 0 {
+		breaker = session.NewCircuitBreaker(cfg.CircuitBreaker.Threshold,
+			time.Duration(cfg.CircuitBreaker.Cooldown), clock,
+			func(e session.BreakerEvent) { relay.NewBreakerNotifier(sessionManager, logger)(e) })
+		middleware = append(middleware, breaker.Middleware())
+	}
+
+	// Event sink and webhooks deliver in the background until shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	var sink *relay.EventSink
+	if cfg.EventSink.NATSURL != "" {
+		publisher, err := relay.NewNATSPublisher(cfg.EventSink.NATSURL, logger)
+		if err != nil {
+			log.Fatalf("Config error: %v", err)
+		}
+		defer func() { _ = publisher.Close() }()
+		sink = relay.NewEventSink(publisher, cfg.EventSink.TopicPrefix, cfg.EventSink.Buffer, clock, logger, nil)
+		if cfg.EventSink.PublishOutputs {
+			middleware = append(middleware, sink.Middleware(redactor))
+		}
+		go sink.Run(bgCtx)
+	}
+
+	var journal *relay.Journal
+	if cfg.JournalDir != "" {
+		journal, err = relay.NewJournal(cfg.JournalDir, clock, logger)
+		if err != nil {
+			log.Fatalf("Journal error: %v", err)
+		}
+		middleware = append(middleware, journal.Middleware())
+	}
+	if cfg.AgentTracing != "" {
+		// Innermost, so everything above records the prompt as sent
+		middleware = append(middleware, relay.TraceMiddleware(cfg.AgentTracing))
+	}
+
+	agentOpts := []relay.AgentFactoryOption{relay.WithAgentEnv(cfg.AgentProxy.Env()...)}
+	switch cfg.AgentWireLog {
+	case "":
+	case "-":
+		// The relay log is redacted already
+		agentOpts = append(agentOpts, relay.WithAgentWireLog(acp.NewLoggerWireLog(logger), nil))
+	default:
+		out, closeWireLog, err := openLogFile(cfg.AgentWireLog)
+		if err != nil {
+			log.Fatalf("Agent wire log error: %v", err)
+		}
+		defer closeWireLog()
+		agentOpts = append(agentOpts, relay.WithAgentWireLog(acp.NewJSONWireLog(out), redactor.String))
+	}
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	agentFactory := relay.NewFactoryRegistry(relay.NewACPAgentFactory(apiKey, "", logger, agentOpts...))
+	for kind, kindCfg := range cfg.AgentKinds {
+		agentFactory.Register(kind, kindCfg.NewFactory(apiKey, logger, agentOpts...))
+	}
+	var plugins *relay.AgentPlugins
+	if cfg.AgentPlugins.Dir != "" {
+		plugins = relay.NewAgentPlugins(cfg.AgentPlugins.Dir, agentFactory, func(kind relay.AgentKindConfig) relay.AgentFactory {
+			return kind.NewFactory(apiKey, logger, agentOpts...)
+		}, logger)
+		if _, _, err := plugins.Scan(); err != nil {
+			log.Fatalf("Agent plugins error: %v", err)
+		}
+	}
+	managerOpts := []session.ManagerOption{
+		session.WithMiddleware(middleware...),
+		session.WithAgentStarter(agentFactory.NewAgent),
+	}
+	if journal != nil {
+		managerOpts = append(managerOpts, session.WithEventJournal(journal))
+	}
+	if cfg.History.MaxEntries > 0 {
+		managerOpts = append(managerOpts, session.WithHistory(session.NewMemoryHistory(cfg.History.MaxEntries)))
+	}
+	if cfg.Concurrency.MaxInFlight > 0 {
+		managerOpts = append(managerOpts, session.WithConcurrencyLimit(cfg.Concurrency.MaxInFlight, cfg.Concurrency.Queue))
+	}
+	managerOpts = append(managerOpts, session.WithMaxConnections(cfg.Quotas.MaxConnectionsPerSession))
+	if cfg.SessionTTL.Default > 0 {
+		managerOpts = append(managerOpts, session.WithDefaultTTL(time.Duration(cfg.SessionTTL.Default)))
+	}
+	if cfg.SessionTTL.Retention > 0 {
+		managerOpts = append(managerOpts, session.WithRetention(time.Duration(cfg.SessionTTL.Retention)))
+	}
+	if cfg.Encryption.ArchiveKeySecret != "" {
+		cipher, err := relay.NewArchiveCipher(relay.EnvSecrets(os.LookupEnv), cfg.Encryption.ArchiveKeySecret)
+		if err != nil {
+			log.Fatalf("Encryption error: %v", err)
+		}
+		managerOpts = append(managerOpts, session.WithArchiveCipher(cipher))
+	}
+	if cfg.ToolPolicy != nil {
+		managerOpts = append(managerOpts, session.WithDefaultToolPolicy(*cfg.ToolPolicy))
+	}
+	if sink != nil {
+		managerOpts = append(managerOpts, session.WithToolAuditor(sink.HandleToolAudit))
+	}
+	if len(cfg.Sandbox.Tools) > 0 {
+		runner := sandbox.New(
+			sandbox.WithTimeout(time.Duration(cfg.Sandbox.Timeout)),
+			sandbox.WithMaxOutput(cfg.Sandbox.MaxOutputBytes),
+			sandbox.WithEnv(cfg.Sandbox.Env...))
+		managerOpts = append(managerOpts, session.WithToolRunner(relay.ShellToolRunner(runner, cfg.Sandbox.Tools, logger)))
+	}
+	if len(cfg.Sandbox.ReadTools) > 0 || len(cfg.Sandbox.WriteTools) > 0 {
+		guard := sandbox.NewFileGuard(
+			sandbox.WithMaxRead(cfg.Sandbox.MaxFileBytes),
+			sandbox.WithMaxWrite(cfg.Sandbox.MaxFileBytes))
+		managerOpts = append(managerOpts, session.WithToolRunner(
+			relay.FileToolRunner(guard, cfg.Sandbox.ReadTools, cfg.Sandbox.WriteTools, logger)))
+	}
+
+	store, closeStore, err := openSessionStore(cfg.Store, logger)
+	if err != nil {
+		log.Fatalf("Session store error: %v", err)
+	}
+	sessionManager = relay.NewSessionManagerWithStore(store, logger, clock, sessionIDGen, managerOpts...)
+	sessionManager.Events().Subscribe(agentStats.HandleLifecycle)
+	if breaker != nil {
+		sessionManager.Events().Subscribe(breaker.HandleLifecycle)
+	}
+	if sink != nil {
+		sessionManager.Events().Subscribe(sink.HandleLifecycle)
+	}
+	if journal != nil {
+		sessionManager.Events().Subscribe(journal.HandleLifecycle)
+	}
+	if len(cfg.Webhooks) > 0 {
+		webhooks := relay.NewWebhookNotifier(cfg.Webhooks, nil, logger)
+		sessionManager.Events().Subscribe(webhooks.HandleLifecycle)
+		go webhooks.Run(bgCtx)
+	}
+
+	// Sessions can get a TTL from agent:spawn even without a default, so the reaper always runs
+	reaper := session.NewReaper(sessionManager, time.Duration(cfg.SessionTTL.Warning), relay.NewExpiryNotifier(clock, logger))
+	go reaper.Run(bgCtx, time.Duration(cfg.SessionTTL.Interval))
+	if plugins != nil {
+		go plugins.Run(bgCtx, time.Duration(cfg.AgentPlugins.Interval))
+	}
+	if cfg.Resources.Interval > 0 {
+		monitor := relay.NewResourceMonitor(sessionManager, relay.ReadProcessUsage, clock, logger,
+			relay.WithResourceThresholds(relay.ResourceThresholds{
+				CPUPercent: cfg.Resources.CPUPercent,
+				RSSBytes:   cfg.Resources.RSSBytes,
+			}))
+		go monitor.Run(bgCtx, time.Duration(cfg.Resources.Interval))
+	}
+
+	pressure := relay.NewPressureMonitor(relay.PressureThresholds{
+		WorkspaceRoot: cfg.Pressure.WorkspaceRoot,
+		MinFreeBytes:  cfg.Pressure.MinFreeBytes,
+		MaxFDPercent:  cfg.Pressure.MaxFDPercent,
+	}, logger)
+	_ = pressure.Check()
+	go pressure.Run(bgCtx, time.Duration(cfg.Pressure.Interval))
+
 	// Create relay server with dependency injection
+	metrics := relay.NewCounterMetrics() // Served at /debug/state
+	spawnerOpts := []relay.SpawnerOption{relay.WithSpawnQuota(cfg.Quotas), relay.WithSpawnPressure(pressure)}
+	if cfg.WorkspaceCache.Dir != "" {
+		spawnerOpts = append(spawnerOpts, relay.WithWorkspaceSeeder(
+			relay.NewWorkspaceCache(cfg.WorkspaceCache.Dir, cfg.WorkspaceCache.Mode, logger)))
+	}
+	spawner := relay.NewSpawner(sessionManager, agentFactory, cfg.Templates, logger, spawnerOpts...)
+	var federation *relay.Federation
+	streamerOpts := []relay.StreamerOption{relay.WithFlowControl(cfg.Streaming.HighWater, cfg.Streaming.LowWater)}
+	if cfg.Federation.Enabled() {
+		key, err := relay.NewFederationKey(relay.EnvSecrets(os.LookupEnv), cfg.Federation.SecretName)
+		if err != nil {
+			log.Fatalf("Federation error: %v", err)
+		}
+		federation = relay.NewFederation(cfg.Federation.RelayID, key, cfg.Federation.Peers, sessionManager, clock, logger,
+			relay.WithFederationClient(&http.Client{Timeout: time.Duration(cfg.Federation.Timeout)}), relay.WithFederationMetrics(metrics))
+		streamerOpts = append(streamerOpts, relay.WithForwarder(federation))
+	}
+	serverOpts := []relay.ServerOption{
+		relay.WithMetrics(metrics),
+		relay.WithAgentStreamer(relay.NewAgentStreamer(sessionManager, clock, logger, streamerOpts...)),
+		relay.WithSpawner(spawner),
+		relay.WithErrorBudget(cfg.ErrorBudget.MaxViolations, time.Duration(cfg.ErrorBudget.Window)),
+		relay.WithProtocolConfig(cfg.Protocol),
+	}
+	if cfg.BinaryFrames.Policy == relay.BinaryAttachments {
+		attachmentIDGen, err := relay.NewIDGenerator(cfg.IDs.Strategy, "att_")
+		if err != nil {
+			log.Fatalf("Config error: %v", err)
+		}
+		serverOpts = append(serverOpts, relay.WithAttachments(relay.NewMemoryAttachmentStore(
+			attachmentIDGen, cfg.BinaryFrames.MaxBytes, cfg.BinaryFrames.MaxAttachments)))
+		if limit := 2 * int64(cfg.BinaryFrames.MaxBytes); limit > relay.DefaultReadLimit {
+			// Oversized attachments get a rejection rather than a dropped connection
+			serverOpts = append(serverOpts, relay.WithReadLimits(limit, relay.DefaultReadTimeout))
+		}
+	}
+	if cfg.IPFilter.Enabled() {
+		filter, err := relay.NewIPFilter(cfg.IPFilter)
+		if err != nil {
+			log.Fatalf("Config error: %v", err)
+		}
+		serverOpts = append(serverOpts, relay.WithIPFilter(filter))
+	}
+	if cfg.Auth.UserHeader != "" {
+		serverOpts = append(serverOpts, relay.WithAuthenticator(&relay.HeaderAuthenticator{
+			Header: cfg.Auth.UserHeader, Required: cfg.Auth.Required}))
+	}
+	if journal != nil {
+		serverOpts = append(serverOpts, relay.WithJournal(journal))
+	}
+	if cfg.MessagePolicy.Module != "" {
+		hook, err := policy.Load(context.Background(), cfg.MessagePolicy.Module,
+			policy.WithTimeout(time.Duration(cfg.MessagePolicy.Timeout)),
+			policy.WithMaxMemory(cfg.MessagePolicy.MaxMemoryBytes))
+		if err != nil {
+			log.Fatalf("Message policy error: %v", err)
+		}
+		defer func() { _ = hook.Close(context.Background()) }()
+		serverOpts = append(serverOpts, relay.WithMessagePolicy(hook, cfg.MessagePolicy.FailOpen))
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		client := github.NewClient(token, github.WithBaseURL(cfg.GitHub.APIURL))
+		opener := github.NewOpener(client, github.ExecGit{}, cfg.GitHub.Remote)
+		serverOpts = append(serverOpts, relay.WithPullRequests(
+			relay.NewPullRequestService(sessionManager, opener, cfg.GitHub, logger)))
+	}
 	server := relay.NewServer(
-		&relay.UUIDGenerator{},
-		&relay.StdLogger{},
-		&relay.SystemClock{},
+		serverIDGen,
+		logger,
+		clock,
 		relay.NewGorillaUpgrader(func(r *http.Request) bool {
 			// Allow all origins for development (Phase 1)
 			return true
 		}),
+		serverOpts...,
 	)
 
 	// Create HTTP server
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", server.HandleWebSocket)
+	if federation != nil {
+		mux.Handle(relay.FederationPath, federation)
+	}
+
+	var handler http.Handler = mux
+	if cfg.AccessLog.Path != "" {
+		out, closeLog, err := openLogFile(cfg.AccessLog.Path)
+		if err != nil {
+			log.Fatalf("Access log error: %v", err)
+		}
+		defer closeLog()
+		handler = relay.NewAccessLogger(relay.NewJSONAccessLog(out), cfg.AccessLog.SampleRate, clock).Middleware(mux)
+	}
+	if len(cfg.TrustedProxies) > 0 {
+		proxies, err := relay.NewTrustedProxies(cfg.TrustedProxies)
+		if err != nil {
+			log.Fatalf("Config error: %v", err)
+		}
+		handler = proxies.Middleware(handler) // Outermost, so every layer sees the client address
+	}
 
 	httpServer := &http.Server{
-		Addr:              fmt.Sprintf(":%d", port),
-		Handler:           mux,
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           handler,
 		ReadHeaderTimeout: 10 * time.Second, // Prevent Slowloris attacks
 	}
 
+	// Admin API on a separate loopback listener
+	jobs := relay.NewJobRunner(spawner, sessionManager, clock, logger)
+	scheduler := relay.NewScheduler(jobs.Run, clock, logger)
+	for _, sched := range cfg.Schedules {
+		if _, err := scheduler.Put(sched); err != nil {
+			log.Fatalf("Config error: %v", err)
+		}
+	}
+	go scheduler.Run(bgCtx, relay.DefaultScheduleTick)
+	adminOpts := []relay.AdminOption{relay.WithAdminImport(spawner), relay.WithAdminConnections(server),
+		relay.WithAdminAgentStats(agentStats), relay.WithAdminJobs(jobs), relay.WithAdminScheduler(scheduler)}
+	if len(cfg.AdminAuth.Keys) > 0 {
+		keys := make([]relay.AdminKey, 0, len(cfg.AdminAuth.Keys))
+		for _, k := range cfg.AdminAuth.Keys {
+			key, err := relay.NewAdminKey(relay.EnvSecrets(os.LookupEnv), k.ID, k.SecretName)
+			if err != nil {
+				log.Fatalf("Admin auth error: %v", err)
+			}
+			keys = append(keys, key)
+		}
+		verifier, err := relay.NewAdminVerifier(keys, clock, relay.WithAdminVerifierMetrics(metrics))
+		if err != nil {
+			log.Fatalf("Admin auth error: %v", err)
+		}
+		adminOpts = append(adminOpts, relay.WithAdminSigning(verifier))
+	}
+	adminServer := &http.Server{
+		Addr:              cfg.AdminAddr,
+		Handler:           relay.NewAdminHandler(sessionManager, logger, adminOpts...),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("Admin API listening on http://%s/admin/sessions", cfg.AdminAddr)
+		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Admin server error: %v", err)
+		}
+	}()
+
+	// Debug listener is opt-in: profiles can expose memory contents
+	var debugServer *http.Server
+	if cfg.DebugAddr != "" {
+		debugServer = &http.Server{
+			Addr:              cfg.DebugAddr,
+			Handler:           relay.NewDebugHandler(server, sessionManager, logger),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.Printf("Debug endpoints listening on http://%s/debug/state", cfg.DebugAddr)
+			if err := debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Debug server error: %v", err)
+			}
+		}()
+	}
+
 	// Start server in goroutine
 	go func() {
-		log.Printf("Relay server starting on port %d", port)
-		log.Printf("WebSocket endpoint: ws://localhost:%d/ws", port)
+		log.Printf("Relay server starting on port %d", cfg.Port)
+		log.Printf("WebSocket endpoint: ws://localhost:%d/ws", cfg.Port)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
 		}
@@ -56,15 +408,109 @@ func main() {
 
 	log.Println("Shutdown signal received, gracefully stopping server...")
 
-	// Create shutdown context with timeout
+	// Stop accepting, then close connections, then end sessions, then flush
+	// the store, so nothing is written to a store that is already closed
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
-
-	// Attempt graceful shutdown
-	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+	err = runShutdown(ctx, logger, []shutdownPhase{
+		{name: "stop HTTP listeners", timeout: httpStopTimeout, run: func(ctx context.Context) error {
+			servers := []*http.Server{httpServer, adminServer}
+			if debugServer != nil {
+				servers = append(servers, debugServer)
+			}
+			var errs []error
+			for _, srv := range servers {
+				errs = append(errs, srv.Shutdown(ctx))
+			}
+			return errors.Join(errs...)
+		}},
+		{name: "drain WebSocket connections", timeout: drainTimeout, run: server.Drain},
+		{name: "terminate sessions", timeout: sessionsTimeout, run: func(ctx context.Context) error {
+			n, err := sessionManager.Shutdown(ctx, session.WithTerminateProgress(func(p session.TerminateProgress) {
+				if p.Err != nil {
+					logger.Printf("Shutdown: failed to terminate session (%d/%d): %v", p.Done, p.Total, p.Err)
+				} else if p.Done%10 == 0 || p.Done == p.Total {
+					logger.Printf("Shutdown: %d/%d sessions terminated", p.Done, p.Total)
+				}
+			}))
+			logger.Printf("Shutdown: %d sessions terminated", n)
+			return err
+		}},
+		{name: "stop background workers", timeout: workersTimeout, run: func(ctx context.Context) error {
+			stopBackground()
+			return nil
+		}},
+		{name: "flush session store", timeout: storeFlushTimeout, run: func(ctx context.Context) error {
+			closeStore()
+			if journal != nil {
+				return journal.Close()
+			}
+			return nil
+		}},
+	})
+	if err != nil {
+		log.Printf("Server stopped with errors: %v", err)
 		os.Exit(1)
 	}
 
 	log.Println("Server stopped")
 }
+
+// openLogFile opens a log file for appending; "-" is stdout
+func openLogFile(path string) (io.Writer, func(), error) {
+	if path == "-" {
+		return os.Stdout, func() {}, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) // #nosec G304 -- path comes from the operator's config
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { _ = f.Close() }, nil
+}
+
+// openSessionStore returns the configured session store and a function closing it
+// PostgreSQL stores are migrated before use; the DSN comes from SESSION_STORE_DSN.
+func openSessionStore(cfg relay.StoreConfig, logger relay.Logger) (session.Store, func(), error) {
+	if cfg.Path != "" {
+		store, err := session.OpenBoltStore(cfg.Path, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Session store: %s (%d sessions restored)", cfg.Path, store.Count())
+		return store, func() { _ = store.Close() }, nil
+	}
+	if cfg.Driver == "" {
+		return session.NewMemoryStore(), func() {}, nil
+	}
+	dsn := os.Getenv("SESSION_STORE_DSN")
+	if dsn == "" {
+		return nil, nil, fmt.Errorf("store.driver is %q but SESSION_STORE_DSN is not set", cfg.Driver)
+	}
+
+	db, err := sql.Open(cfg.Driver, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := session.MigratePostgres(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+	store, err := session.NewPostgresStore(ctx, db, logger)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+	log.Printf("Session store: %s (shared)", cfg.Driver)
+	return store, func() {
+		_ = store.Close()
+		_ = db.Close()
+	}, nil
+}
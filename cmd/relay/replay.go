@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/2389-research/ourocodus/pkg/relay"
+	"github.com/2389-research/ourocodus/pkg/relay/relaytest"
+)
+
+// runReplay implements `relay replay [-v] <journal>`
+// Returns the process exit code: 0 if the replay matched the journal, 1 if it
+// diverged, 2 on usage errors or an unreadable journal
+func runReplay(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	verbose := fs.Bool("v", false, "print every entry, not only divergences")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: relay replay [-v] <journal>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	entries, err := relay.ReadJournal(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", fs.Arg(0), err)
+		return 2
+	}
+	report := relaytest.Replay(entries)
+	for _, step := range report.Steps {
+		if step.Diverged != "" {
+			fmt.Fprintf(stdout, "DIVERGED %s\n         %s\n", describeEntry(step.Entry), step.Diverged)
+		} else if *verbose {
+			fmt.Fprintf(stdout, "ok       %s\n", describeEntry(step.Entry))
+		}
+	}
+	fmt.Fprintf(stdout, "%s: %d entries replayed, %d divergences\n", fs.Arg(0), len(entries), report.Divergences)
+
+	if report.Divergences > 0 {
+		return 1
+	}
+	return 0
+}
+
+// describeEntry summarizes a journal entry on one line (pure function)
+func describeEntry(e relay.JournalEntry) string {
+	prefix := fmt.Sprintf("#%d %s %s", e.Seq, e.Time, e.SessionID)
+	switch e.Kind {
+	case relay.JournalFrame:
+		return fmt.Sprintf("%s frame %s %s", prefix, e.Direction, e.Frame)
+	case relay.JournalLifecycle:
+		s := fmt.Sprintf("%s %s -> %s (%s)", prefix, e.From, e.To, e.Event)
+		if e.Error != "" {
+			s += " error: " + e.Error
+		} else if e.Reason != "" {
+			s += " " + e.Reason
+		}
+		return s
+	case relay.JournalAgent:
+		if e.Error != "" {
+			return fmt.Sprintf("%s prompt %q -> error: %s", prefix, e.Prompt, e.Error)
+		}
+		return fmt.Sprintf("%s prompt %q -> reply", prefix, e.Prompt)
+	}
+	return fmt.Sprintf("%s %s", prefix, e.Kind)
+}
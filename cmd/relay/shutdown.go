@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay"
+)
+
+// Shutdown budget: the whole sequence and each phase within it
+const (
+	shutdownTimeout   = 30 * time.Second
+	httpStopTimeout   = 5 * time.Second
+	drainTimeout      = 10 * time.Second
+	sessionsTimeout   = 10 * time.Second
+	workersTimeout    = time.Second
+	storeFlushTimeout = 5 * time.Second
+)
+
+// shutdownPhase is one step of the relay's shutdown
+type shutdownPhase struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// runShutdown runs phases in order under ctx, each bounded by its own timeout
+// A phase that fails or overruns is logged and the next one still runs, so
+// sessions are terminated and stores flushed even if connections would not
+// drain. A phase that ignores its context is abandoned when it times out.
+// Returns every phase's error, joined.
+func runShutdown(ctx context.Context, logger relay.Logger, phases []shutdownPhase) error {
+	var errs []error
+	for _, phase := range phases {
+		start := time.Now()
+		logger.Printf("Shutdown: %s", phase.name)
+		phaseCtx, cancel := context.WithTimeout(ctx, phase.timeout)
+		done := make(chan error, 1)
+		go func() { done <- phase.run(phaseCtx) }()
+		var err error
+		select {
+		case err = <-done:
+		case <-phaseCtx.Done():
+			err = phaseCtx.Err()
+		}
+		cancel()
+		if err != nil {
+			logger.Printf("Shutdown: %s failed after %s: %v", phase.name, time.Since(start).Round(time.Millisecond), err)
+			errs = append(errs, fmt.Errorf("%s: %w", phase.name, err))
+			continue
+		}
+		logger.Printf("Shutdown: %s done in %s", phase.name, time.Since(start).Round(time.Millisecond))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/2389-research/ourocodus/pkg/relay"
+)
+
+// runValidateConfig implements `relay validate-config [-json] <path>`
+// Returns the process exit code: 0 if valid, 1 if the config has errors,
+// 2 on usage errors
+func runValidateConfig(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: relay validate-config [-json] <path>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	report := relay.CheckConfigFile(fs.Arg(0))
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(stderr, "failed to write report: %v\n", err)
+			return 2
+		}
+	} else {
+		printReport(stdout, report)
+	}
+
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
+
+// printReport writes a human-readable report
+func printReport(w io.Writer, report relay.ConfigReport) {
+	for _, e := range report.Errors {
+		fmt.Fprintf(w, "error: %s\n", e)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+	if report.Valid {
+		fmt.Fprintf(w, "%s: OK (%d warnings)\n", report.Path, len(report.Warnings))
+	} else {
+		fmt.Fprintf(w, "%s: INVALID (%d errors, %d warnings)\n", report.Path, len(report.Errors), len(report.Warnings))
+	}
+}
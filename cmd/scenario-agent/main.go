@@ -0,0 +1,131 @@
+// Scenario-agent plays back a scripted conversation for deterministic
+// end-to-end tests.
+//
+// The scenario file (-scenario, or SCENARIO_AGENT_FILE for agents spawned by
+// the relay) lists the requests the agent expects in order and its exact
+// answer to each: text, tool calls, parts, raw results or errors, optionally
+// streamed and delayed. A request that does not match the next step gets a
+// JSON-RPC invalid request error naming the step, and the step stays next.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// codeRequestCancelled answers requests interrupted by agent/cancel
+const codeRequestCancelled = -32800
+
+// player answers requests read from its inbox with the scenario's steps
+type player struct {
+	scenario *scenario
+	next     int // Index of the step expected next
+	inbox    *acp.Inbox
+}
+
+func main() {
+	fs := flag.NewFlagSet("scenario-agent", flag.ExitOnError)
+	fs.String("workspace", "", "Workspace directory (ignored)")
+	path := fs.String("scenario", os.Getenv("SCENARIO_AGENT_FILE"), "Scenario file (YAML)")
+	_ = fs.Parse(os.Args[1:])
+
+	if *path == "" {
+		fmt.Fprintln(os.Stderr, "scenario-agent: -scenario or SCENARIO_AGENT_FILE is required")
+		os.Exit(2)
+	}
+	s, err := loadScenario(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "scenario-agent: %s: %v\n", *path, err)
+		os.Exit(2)
+	}
+
+	p := &player{scenario: s, inbox: acp.NewInbox(os.Stdin)}
+	for {
+		line, ok := p.inbox.Next()
+		if !ok {
+			break
+		}
+		p.handle(line)
+	}
+
+	if err := p.inbox.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Scanner error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handle answers one line from stdin with the next step
+func (p *player) handle(line []byte) {
+	var req struct {
+		ID     interface{}     `json:"id"`
+		Params json.RawMessage `json:"params"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(line, &req); err != nil {
+		send(acp.Response{JSONRPC: "2.0", Error: &acp.Error{Code: acp.CodeParseError, Message: "Parse error"}})
+		return
+	}
+	if req.ID == nil {
+		return // Notifications; a cancel here is for a request already answered
+	}
+
+	if p.next == len(p.scenario.Steps) {
+		if !p.scenario.Loop {
+			sendError(req.ID, acp.CodeInvalidRequest, fmt.Sprintf("scenario finished after %d steps", p.next))
+			return
+		}
+		p.next = 0
+	}
+	n := p.next + 1
+	st := &p.scenario.Steps[p.next]
+	if reason := st.Match.matches(req.Method, req.Params); reason != "" {
+		fmt.Fprintf(os.Stderr, "scenario-agent: step %d: %s\n", n, reason)
+		sendError(req.ID, acp.CodeInvalidRequest, fmt.Sprintf("scenario step %d: %s", n, reason))
+		return
+	}
+	p.next++
+
+	if !p.inbox.Wait(req.ID, time.Duration(st.Delay)) {
+		sendError(req.ID, codeRequestCancelled, "Request cancelled")
+		return
+	}
+	requestID, isNumber := req.ID.(float64)
+	for i, content := range st.Deltas {
+		if i > 0 && !p.inbox.Wait(req.ID, time.Duration(st.DeltaDelay)) {
+			sendError(req.ID, codeRequestCancelled, "Request cancelled")
+			return
+		}
+		if isNumber {
+			params, _ := json.Marshal(acp.Delta{RequestID: int(requestID), Seq: i + 1, Content: content})
+			send(acp.Notification{JSONRPC: "2.0", Method: acp.MethodDelta, Params: params})
+		}
+	}
+
+	switch {
+	case st.Error != nil:
+		send(acp.Response{JSONRPC: "2.0", ID: req.ID, Error: st.Error})
+	case len(st.Result) > 0:
+		send(acp.Response{JSONRPC: "2.0", ID: req.ID, Result: st.Result})
+	default:
+		send(acp.Response{JSONRPC: "2.0", ID: req.ID, Result: st.message()})
+	}
+}
+
+func sendError(id interface{}, code int, message string) {
+	send(acp.Response{JSONRPC: "2.0", ID: id, Error: &acp.Error{Code: code, Message: message}})
+}
+
+// send writes one message to stdout
+func send(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal message: %v\n", err)
+		return
+	}
+	fmt.Println(string(data))
+}
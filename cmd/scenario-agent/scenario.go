@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// scenario is the script the agent plays: the requests it expects, in order,
+// and its exact answer to each
+type scenario struct {
+	Steps []step `json:"steps"`
+	// Loop restarts the steps once they are used up; otherwise later
+	// requests are answered with an error
+	Loop bool `json:"loop,omitempty"`
+}
+
+// step is one expected request and its answer
+type step struct {
+	Match match `json:"match"`
+
+	Delay      duration `json:"delay,omitempty"`      // Wait before answering; agent/cancel interrupts it
+	Deltas     []string `json:"deltas,omitempty"`     // agent/delta chunks streamed before the answer
+	DeltaDelay duration `json:"deltaDelay,omitempty"` // Wait between deltas
+
+	// Exactly one of the following is the answer
+	Reply    string          `json:"reply,omitempty"`    // A text message
+	ToolCall *acp.ToolCall   `json:"toolCall,omitempty"` // A toolCall message
+	Parts    []acp.Part      `json:"parts,omitempty"`    // A parts message
+	Result   json.RawMessage `json:"result,omitempty"`   // Any result, e.g. for initialize
+	Error    *acp.Error      `json:"error,omitempty"`    // A JSON-RPC error
+}
+
+// match describes the request a step expects; empty fields match anything
+type match struct {
+	Method  string                 `json:"method,omitempty"`  // Defaults to agent/sendMessage
+	Content string                 `json:"content,omitempty"` // Exact prompt text
+	Pattern string                 `json:"pattern,omitempty"` // Regular expression the prompt must match
+	Params  map[string]interface{} `json:"params,omitempty"`  // Values the request params must contain
+
+	pattern *regexp.Regexp
+}
+
+// duration reads Go duration strings such as "250ms"
+type duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"250ms\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// loadScenario reads a YAML (or JSON) scenario file
+func loadScenario(path string) (*scenario, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is the operator's scenario file
+	if err != nil {
+		return nil, err
+	}
+	return parseScenario(data)
+}
+
+// parseScenario parses and validates a scenario
+// The YAML is converted to JSON first so steps use the same field names as
+// the protocol messages they describe; unknown fields are an error.
+func parseScenario(data []byte) (*scenario, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	asJSON, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("scenario must use string keys: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(asJSON))
+	dec.DisallowUnknownFields()
+	var s scenario
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("scenario has no steps")
+	}
+	for i := range s.Steps {
+		if err := s.Steps[i].validate(); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return &s, nil
+}
+
+// validate checks the step has one answer and compiles its pattern
+func (s *step) validate() error {
+	if s.Match.Method == "" {
+		s.Match.Method = acp.MethodSendMessage
+	}
+	if s.Match.Pattern != "" {
+		re, err := regexp.Compile(s.Match.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		s.Match.pattern = re
+	}
+	answers := 0
+	for _, set := range []bool{s.Reply != "", s.ToolCall != nil, len(s.Parts) > 0, len(s.Result) > 0, s.Error != nil} {
+		if set {
+			answers++
+		}
+	}
+	if answers != 1 {
+		return fmt.Errorf("needs exactly one of reply, toolCall, parts, result and error")
+	}
+	if len(s.Deltas) > 0 && s.Match.Method != acp.MethodSendMessage {
+		return fmt.Errorf("deltas are only streamed for %s", acp.MethodSendMessage)
+	}
+	if msg := s.message(); msg != nil {
+		return msg.Validate()
+	}
+	return nil
+}
+
+// message is the agent message the step answers with, if any
+func (s *step) message() *acp.AgentMessage {
+	switch {
+	case s.Reply != "":
+		return &acp.AgentMessage{Type: "text", Content: s.Reply}
+	case s.ToolCall != nil:
+		return &acp.AgentMessage{Type: acp.PartTypeToolCall, ToolCall: s.ToolCall}
+	case len(s.Parts) > 0:
+		return &acp.AgentMessage{Type: acp.MessageTypeParts, Parts: s.Parts}
+	}
+	return nil
+}
+
+// matches explains why a request does not fit the step, or returns "" if it does
+func (m *match) matches(method string, params json.RawMessage) string {
+	if method != m.Method {
+		return fmt.Sprintf("expected %s, got %s", m.Method, method)
+	}
+	var got map[string]interface{}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &got); err != nil {
+			return fmt.Sprintf("params are not an object: %v", err)
+		}
+	}
+	content, _ := got["content"].(string)
+	if m.Content != "" && content != m.Content {
+		return fmt.Sprintf("expected prompt %q, got %q", m.Content, content)
+	}
+	if m.pattern != nil && !m.pattern.MatchString(content) {
+		return fmt.Sprintf("expected prompt matching %q, got %q", m.Pattern, content)
+	}
+	for key, want := range m.Params {
+		if !reflect.DeepEqual(got[key], want) {
+			return fmt.Sprintf("expected params.%s = %v, got %v", key, want, got[key])
+		}
+	}
+	return ""
+}
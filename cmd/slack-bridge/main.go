@@ -0,0 +1,108 @@
+// Command slack-bridge connects Slack channels to relay agents: messages in a
+// mapped channel are sent to that channel's agent and replies are posted back
+// in the message's thread
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/client"
+	"github.com/2389-research/ourocodus/pkg/integrations/slack"
+	"github.com/2389-research/ourocodus/pkg/relay"
+)
+
+const (
+	// agentTimeout bounds one prompt and reply
+	agentTimeout = 10 * time.Minute
+
+	// Redial attempts (with doubling backoff) after losing the relay
+	reconnectAttempts = 8
+	reconnectBackoff  = 500 * time.Millisecond
+)
+
+func main() {
+	relayURL := flag.String("relay", "ws://localhost:8080/ws", "relay WebSocket URL")
+	addr := flag.String("addr", ":3000", "address serving the Slack Events API request URL (/slack/events)")
+	routesPath := flag.String("routes", "", `JSON file mapping channel IDs to agents: {"C0123": {"role": "auth", "workspace": "/repo"}}`)
+	flag.Parse()
+
+	token, secret := os.Getenv("SLACK_BOT_TOKEN"), os.Getenv("SLACK_SIGNING_SECRET")
+	if token == "" || secret == "" {
+		log.Fatal("SLACK_BOT_TOKEN and SLACK_SIGNING_SECRET must be set")
+	}
+	routes, err := loadRoutes(*routesPath)
+	if err != nil {
+		log.Fatalf("Routes error: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger := &relay.StdLogger{}
+	conn, err := client.Dial(ctx, *relayURL,
+		client.WithReconnect(reconnectAttempts, reconnectBackoff), client.WithLogger(logger))
+	if err != nil {
+		log.Fatalf("Relay error: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	bridge := slack.NewBridge(clientRelay{client: conn}, slack.NewClient(token, ""), routes, logger)
+
+	mux := http.NewServeMux()
+	mux.Handle("/slack/events", slack.NewEventHandler(secret, func(event slack.MessageEvent) {
+		msgCtx, cancel := context.WithTimeout(ctx, agentTimeout)
+		defer cancel()
+		bridge.HandleMessage(msgCtx, event)
+	}))
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		log.Printf("Slack bridge listening on %s/slack/events for %d channels", *addr, len(routes))
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	// The client reconnects and reattaches sessions on its own; once it gives
+	// up the bridge exits so a supervisor can restart it
+	select {
+	case <-ctx.Done():
+		log.Println("Shutdown signal received")
+	case <-conn.Done():
+		log.Printf("Relay connection lost: %v", conn.Err())
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+}
+
+// loadRoutes reads the channel → agent map
+func loadRoutes(path string) (map[string]slack.Route, error) {
+	if path == "" {
+		return nil, fmt.Errorf("-routes is required")
+	}
+	// #nosec G304 -- routes path is supplied by the operator
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var routes map[string]slack.Route
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
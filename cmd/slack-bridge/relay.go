@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/client"
+	"github.com/2389-research/ourocodus/pkg/integrations/slack"
+)
+
+// clientRelay adapts the relay SDK client to slack.Relay
+type clientRelay struct {
+	client *client.Client
+}
+
+// Spawn implements slack.Relay
+func (r clientRelay) Spawn(ctx context.Context, role, workspace string) (string, error) {
+	spawned, err := r.client.Spawn(ctx, role, workspace, "")
+	if err != nil {
+		return "", err
+	}
+	return spawned.SessionID, nil
+}
+
+// Send implements slack.Relay
+func (r clientRelay) Send(ctx context.Context, sessionID, content string) (string, error) {
+	reply, err := r.client.Send(ctx, sessionID, content)
+	var relayErr *client.Error
+	if errors.As(err, &relayErr) && relayErr.Code == "SESSION_NOT_FOUND" {
+		return "", fmt.Errorf("%w: %v", slack.ErrSessionGone, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return partsText(reply.Parts), nil
+}
+
+// partsText flattens the text and code parts of an agent reply for Slack
+func partsText(parts []acp.Part) string {
+	var texts []string
+	for _, p := range parts {
+		switch p.Type {
+		case acp.PartTypeText:
+			texts = append(texts, p.Text)
+		case acp.PartTypeCode:
+			texts = append(texts, "```\n"+p.Text+"\n```")
+		case acp.PartTypePatch:
+			texts = append(texts, "```diff\n"+p.Diff+"\n```")
+		case acp.PartTypeError:
+			if p.Error != nil {
+				texts = append(texts, ":x: "+p.Error.Message)
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,72 @@
+package acp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+// newPipeClient returns a client wired to an in-memory agent that answers
+// each agent/sendMessage with deltas deltas followed by the full reply,
+// so benchmarks measure framing and JSON work without process overhead
+func newPipeClient(tb testing.TB, deltas int) *Client {
+	tb.Helper()
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	tb.Cleanup(func() {
+		_ = reqW.Close()
+		_ = respR.Close()
+	})
+
+	go func() {
+		defer func() { _ = respW.Close() }()
+		in := bufio.NewScanner(reqR)
+		enc := json.NewEncoder(respW)
+		for in.Scan() {
+			var req struct {
+				Params SendMessageParams `json:"params"`
+				ID     int               `json:"id"`
+			}
+			if err := json.Unmarshal(in.Bytes(), &req); err != nil || req.ID == 0 {
+				continue // Notifications need no reply
+			}
+			for i := 0; i < deltas; i++ {
+				params, _ := json.Marshal(Delta{RequestID: req.ID, Seq: i, Content: req.Params.Content})
+				if enc.Encode(Notification{JSONRPC: "2.0", Method: MethodDelta, Params: params}) != nil {
+					return
+				}
+			}
+			reply := Response{JSONRPC: "2.0", ID: req.ID, Result: AgentMessage{Type: "text", Content: "Echo: " + req.Params.Content}}
+			if enc.Encode(reply) != nil {
+				return
+			}
+		}
+	}()
+
+	return &Client{stdin: reqW, stdout: respR, messages: newMessageDecoder(respR, DefaultMaxMessageSize), logger: noOpLogger{}, nextID: 1}
+}
+
+func BenchmarkSendMessage(b *testing.B) {
+	client := newPipeClient(b, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.SendMessage("summarize the diff"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSendMessageStream(b *testing.B) {
+	client := newPipeClient(b, 8)
+	var received int
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.SendMessageStream("summarize the diff", func(Delta) { received++ }); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if received != 8*b.N {
+		b.Fatalf("expected %d deltas, got %d", 8*b.N, received)
+	}
+}
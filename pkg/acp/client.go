@@ -2,12 +2,15 @@ package acp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,20 +20,32 @@ type Client struct {
 	stdin    io.WriteCloser
 	stdout   io.ReadCloser
 	stderr   io.ReadCloser
-	scanner  *bufio.Scanner
+	messages *messageDecoder
+	wire     *wireTap    // Nil unless WithWireLogger was used
+	sentAt   time.Time   // When the pending request was sent (protected by reqMu)
+	tree     processTree // The agent and the processes it starts, killed on Close
 	logger   Logger
 	closedMu sync.RWMutex
 	reqMu    sync.Mutex // Protects entire request/response cycle
+	writeMu  sync.Mutex // Serializes stdin writes (requests and cancel notifications)
 	nextID   int
 	closed   bool
+	stopped  atomic.Bool                      // Set by Suspend until Resume; Close continues the agent first
+	info     atomic.Pointer[InitializeResult] // Set by a successful Initialize
 }
 
+// DefaultCommand is the ACP executable spawned when WithCommand is not used
+const DefaultCommand = "claude-code-acp"
+
 // ClientOption configures a Client
 type ClientOption func(*clientConfig)
 
 type clientConfig struct {
 	commandPath string
 	commandArgs []string
+	env         []string
+	maxMessage  int64
+	wire        *wireTap
 	logger      Logger
 }
 
@@ -43,6 +58,34 @@ func WithCommand(path string, args ...string) ClientOption {
 	}
 }
 
+// WithEnv adds KEY=value variables to the ACP process environment, on top
+// of the relay's own; later entries win over earlier ones
+// ANTHROPIC_API_KEY is always the key passed to NewClient.
+func WithEnv(vars ...string) ClientOption {
+	return func(c *clientConfig) {
+		c.env = append(c.env, vars...)
+	}
+}
+
+// WithMaxMessageSize limits how large a single message from the agent may be;
+// larger ones fail the request with *ResponseTooLargeError
+// Zero or less removes the limit. Defaults to DefaultMaxMessageSize.
+func WithMaxMessageSize(bytes int64) ClientOption {
+	return func(c *clientConfig) {
+		c.maxMessage = max(bytes, 0)
+	}
+}
+
+// WithWireLogger records every JSON-RPC frame exchanged with the agent, so
+// integration issues can be debugged without instrumenting the agent
+// Frames carry prompts and replies verbatim; pass redact (e.g.
+// (*redact.Redactor).String) to scrub them first, or nil to log them as is.
+func WithWireLogger(logger WireLogger, redact func(string) string) ClientOption {
+	return func(c *clientConfig) {
+		c.wire = &wireTap{logger: logger, redact: redact}
+	}
+}
+
 // WithLogger sets a custom logger for ACP stderr output
 func WithLogger(logger Logger) ClientOption {
 	return func(c *clientConfig) {
@@ -65,8 +108,9 @@ func NewClient(workspace string, apiKey string, opts ...ClientOption) (*Client,
 
 	// Apply options
 	cfg := &clientConfig{
-		commandPath: "claude-code-acp",
+		commandPath: DefaultCommand,
 		commandArgs: []string{"--workspace", workspace},
+		maxMessage:  DefaultMaxMessageSize,
 		logger:      noOpLogger{},
 	}
 	for _, opt := range opts {
@@ -82,9 +126,12 @@ func NewClient(workspace string, apiKey string, opts ...ClientOption) (*Client,
 
 	// Run the process within the workspace for relative path operations
 	cmd.Dir = workspace
+	if err := prepareCommand(cmd); err != nil {
+		return nil, err
+	}
 
-	// Set API key via environment variable
-	cmd.Env = append(os.Environ(), fmt.Sprintf("ANTHROPIC_API_KEY=%s", apiKey))
+	// Set API key via environment variable, after cfg.env so it cannot be overridden
+	cmd.Env = append(append(os.Environ(), cfg.env...), fmt.Sprintf("ANTHROPIC_API_KEY=%s", apiKey))
 
 	// Setup stdin pipe
 	stdin, err := cmd.StdinPipe()
@@ -116,19 +163,18 @@ func NewClient(workspace string, apiKey string, opts ...ClientOption) (*Client,
 	}
 
 	client := &Client{
-		cmd:     cmd,
-		stdin:   stdin,
-		stdout:  stdout,
-		stderr:  stderr,
-		scanner: bufio.NewScanner(stdout),
-		logger:  cfg.logger,
-		nextID:  1,
-		closed:  false,
+		cmd:      cmd,
+		tree:     newProcessTree(cmd, cfg.logger),
+		stdin:    stdin,
+		stdout:   stdout,
+		stderr:   stderr,
+		messages: newMessageDecoder(stdout, cfg.maxMessage),
+		wire:     cfg.wire,
+		logger:   cfg.logger,
+		nextID:   1,
+		closed:   false,
 	}
 
-	// Allow large JSON messages (init 64KB, max 5MB)
-	client.scanner.Buffer(make([]byte, 64*1024), 5*1024*1024)
-
 	// Start goroutine to log stderr (for debugging)
 	go client.logStderr()
 
@@ -156,6 +202,39 @@ func (c *Client) logStderr() {
 //   - reqMu serializes request/response pairs to prevent interleaving
 //   - Example: Thread A sends request ID=1, Thread B sends ID=2; without reqMu, responses could mismatch
 func (c *Client) SendMessage(content string) (*AgentMessage, error) {
+	return c.SendMessageStream(content, nil)
+}
+
+// SendMessageStream sends a message like SendMessage and calls onDelta for
+// each agent/delta notification received before the final response
+// onDelta runs on the calling goroutine with reqMu held; it must not call
+// back into the client. A nil onDelta discards the deltas.
+func (c *Client) SendMessageStream(content string, onDelta func(Delta)) (*AgentMessage, error) {
+	return c.SendMessageContext(context.Background(), content, onDelta)
+}
+
+// SendMessageContext sends a message like SendMessageStream and interrupts the
+// agent if ctx is cancelled while the response is pending
+// Cancellation sends an agent/cancel notification and keeps reading until the
+// agent answers the request, so the next request is not handed a stale reply;
+// the cancelled call then returns ctx.Err() wrapped.
+func (c *Client) SendMessageContext(ctx context.Context, content string, onDelta func(Delta)) (*AgentMessage, error) {
+	return c.call(ctx, MethodSendMessage, SendMessageParams{
+		Content:  content,
+		Metadata: MetadataFromContext(ctx),
+	}, onDelta)
+}
+
+// ResolveToolCall approves or denies a tool call awaiting approval and
+// returns the agent's reply, which continues the turn
+// Streaming and cancellation work as in SendMessageContext.
+func (c *Client) ResolveToolCall(ctx context.Context, params ToolCallParams, onDelta func(Delta)) (*AgentMessage, error) {
+	return c.call(ctx, MethodToolCall, params, onDelta)
+}
+
+// call sends a request answered with an agent message, interrupting the
+// agent if ctx is cancelled while the response is pending
+func (c *Client) call(ctx context.Context, method string, params interface{}, onDelta func(Delta)) (*AgentMessage, error) {
 	c.closedMu.RLock()
 	if c.closed {
 		c.closedMu.RUnlock()
@@ -163,6 +242,10 @@ func (c *Client) SendMessage(content string) (*AgentMessage, error) {
 	}
 	c.closedMu.RUnlock()
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Lock for entire request/response cycle to prevent interleaving
 	c.reqMu.Lock()
 	defer c.reqMu.Unlock()
@@ -175,75 +258,232 @@ func (c *Client) SendMessage(content string) (*AgentMessage, error) {
 	req := Request{
 		JSONRPC: "2.0",
 		ID:      id,
-		Method:  MethodSendMessage,
-		Params: SendMessageParams{
-			Content: content,
-		},
+		Method:  method,
+		Params:  params,
+	}
+	c.sentAt = time.Now()
+	if err := c.writeLine(req); err != nil {
+		return nil, err
+	}
+
+	// Interrupt the agent if the caller gives up while we wait
+	stop := context.AfterFunc(ctx, func() {
+		if err := c.cancelRequest(id); err != nil {
+			c.logger.Printf("[ACP] failed to cancel request %d: %v", id, err)
+		}
+	})
+	defer stop()
+
+	// Read response from stdout and verify it matches the request ID
+	msg, err := c.readResponse(id, onDelta)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("request %d cancelled: %w", id, ctx.Err())
+	}
+	return msg, err
+}
+
+// metadataKey is the context key under which WithMetadata stores metadata
+type metadataKey struct{}
+
+// WithMetadata returns a context whose agent/sendMessage requests carry md as
+// params metadata
+func WithMetadata(ctx context.Context, md map[string]string) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFromContext returns the metadata set by WithMetadata (nil = none)
+func MetadataFromContext(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return md
+}
+
+// Initialize opens the connection with an initialize request and returns
+// what the agent reports about itself
+// Agents that require it reject other requests until it succeeds; params
+// configure the agent (system prompt, model) for the rest of the connection.
+// ProtocolVersion defaults to the version this package speaks.
+func (c *Client) Initialize(ctx context.Context, params InitializeParams) (*InitializeResult, error) {
+	c.closedMu.RLock()
+	if c.closed {
+		c.closedMu.RUnlock()
+		return nil, fmt.Errorf("client is closed")
+	}
+	c.closedMu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if params.ProtocolVersion == 0 {
+		params.ProtocolVersion = ProtocolVersion
+	}
+
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+
+	id := c.nextID
+	c.nextID++
+	c.sentAt = time.Now()
+	if err := c.writeLine(Request{JSONRPC: "2.0", ID: id, Method: MethodInitialize, Params: params}); err != nil {
+		return nil, err
 	}
 
-	// Marshal request to JSON
-	data, err := json.Marshal(req)
+	result, err := c.readResult(id, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("initialize failed: %w", err)
+	}
+	var info InitializeResult
+	if err := json.Unmarshal(result, &info); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal initialize result: %w", err)
 	}
+	c.info.Store(&info)
+	return &info, nil
+}
 
-	// Write request to stdin (with newline as delimiter)
+// Info returns what the agent reported at Initialize (nil if it was not
+// initialized)
+// The result is shared; callers must not modify it.
+func (c *Client) Info() *InitializeResult {
+	return c.info.Load()
+}
+
+// cancelRequest sends an agent/cancel notification for a pending request
+func (c *Client) cancelRequest(id int) error {
+	params, err := json.Marshal(CancelParams{RequestID: id})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cancel params: %w", err)
+	}
+	return c.writeLine(Notification{
+		JSONRPC: "2.0",
+		Method:  MethodCancel,
+		Params:  params,
+	})
+}
+
+// writeLine marshals v and writes it to stdin as one newline-delimited message
+func (c *Client) writeLine(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	c.tapFrame(WireSent, data, 0)
 	data = append(data, '\n')
-	if _, err = c.stdin.Write(data); err != nil {
-		return nil, fmt.Errorf("failed to write request: %w", err)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.stdin.Write(data); err != nil {
+		return fmt.Errorf("failed to write request: %w", err)
 	}
+	return nil
+}
 
-	// Read response from stdout and verify it matches the request ID
-	return c.readResponse(id)
+// readResponse reads the response to a request and decodes the agent message
+// it carries
+// Must be called with reqMu held (called from call)
+func (c *Client) readResponse(expectedID int, onDelta func(Delta)) (*AgentMessage, error) {
+	result, err := c.readResult(expectedID, onDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse result as AgentMessage
+	var msg AgentMessage
+	if err := json.Unmarshal(result, &msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal agent message: %w", err)
+	}
+	if err := msg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid agent message: %w", err)
+	}
+
+	return &msg, nil
 }
 
-// readResponse reads a single JSON-RPC response from stdout and validates the ID
-// Must be called with reqMu held (called from SendMessage)
-func (c *Client) readResponse(expectedID int) (*AgentMessage, error) {
-	// Read next line from stdout (protected by reqMu from caller)
-	if !c.scanner.Scan() {
-		if err := c.scanner.Err(); err != nil {
+// readResult reads JSON-RPC messages from stdout until the response
+// arrives, validates its ID and returns its result. Delta notifications for
+// the request are passed to onDelta; other notifications are logged and skipped.
+// Must be called with reqMu held
+func (c *Client) readResult(expectedID int, onDelta func(Delta)) (json.RawMessage, error) {
+	var resp envelope
+	for {
+		// Read next message from stdout (protected by reqMu from caller)
+		line, err := c.messages.next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no response from agent (EOF)")
+		}
+		if err != nil {
 			return nil, fmt.Errorf("failed to read response: %w", err)
 		}
-		return nil, fmt.Errorf("no response from agent (EOF)")
-	}
-	line := c.scanner.Bytes()
+		c.tapFrame(WireReceived, line, time.Since(c.sentAt))
 
-	// Parse JSON-RPC response
-	var resp Response
-	if err := json.Unmarshal(line, &resp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		var env envelope
+		if err := json.Unmarshal(line, &env); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		if err := env.validate(); err != nil {
+			return nil, err
+		}
+		if env.Method == "" {
+			resp = env
+			break
+		}
+		notification := Notification{JSONRPC: env.JSONRPC, Method: env.Method, Params: env.Params}
+		if err := c.handleNotification(notification, expectedID, onDelta); err != nil {
+			return nil, err
+		}
 	}
 
-	// Verify response ID matches request ID
-	if respID, ok := resp.ID.(float64); ok {
-		if int(respID) != expectedID {
-			return nil, fmt.Errorf("mismatched response id: got %v, want %d", resp.ID, expectedID)
+	// Verify response ID matches request ID; agents that could not parse the
+	// request answer with a null ID
+	var respID float64
+	if err := json.Unmarshal(resp.ID, &respID); err != nil || respID != float64(expectedID) {
+		if string(resp.ID) != "null" || resp.Error == nil {
+			return nil, fmt.Errorf("mismatched response id: got %s, want %d", resp.ID, expectedID)
 		}
-	} else if respID, ok := resp.ID.(int); ok {
-		if respID != expectedID {
-			return nil, fmt.Errorf("mismatched response id: got %v, want %d", resp.ID, expectedID)
-		}
-	} else if resp.ID != expectedID {
-		return nil, fmt.Errorf("mismatched response id: got %v, want %d", resp.ID, expectedID)
 	}
 
 	// Check for JSON-RPC error
 	if resp.Error != nil {
-		return nil, fmt.Errorf("ACP error (code %d): %s", resp.Error.Code, resp.Error.Message)
+		return nil, resp.Error
 	}
+	return resp.Result, nil
+}
 
-	// Parse result as AgentMessage
-	var msg AgentMessage
-	resultData, err := json.Marshal(resp.Result)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal result: %w", err)
+// handleNotification dispatches a notification received while awaiting a response
+// Deltas tagged with another request ID are stale and dropped
+func (c *Client) handleNotification(n Notification, expectedID int, onDelta func(Delta)) error {
+	if n.Method != MethodDelta {
+		c.logger.Printf("[ACP] ignoring notification: %s", n.Method)
+		return nil
 	}
-	if err := json.Unmarshal(resultData, &msg); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal agent message: %w", err)
+
+	var delta Delta
+	if err := json.Unmarshal(n.Params, &delta); err != nil {
+		return fmt.Errorf("failed to unmarshal delta: %w", err)
 	}
+	if delta.RequestID != expectedID {
+		c.logger.Printf("[ACP] dropping delta for request %d (awaiting %d)", delta.RequestID, expectedID)
+		return nil
+	}
+	if onDelta != nil {
+		onDelta(delta)
+	}
+	return nil
+}
 
-	return &msg, nil
+// tapFrame hands a frame to the wire logger, if any
+func (c *Client) tapFrame(direction string, data []byte, latency time.Duration) {
+	if c.wire == nil {
+		return
+	}
+	pid := 0
+	if c.cmd != nil && c.cmd.Process != nil {
+		pid = c.cmd.Process.Pid
+	}
+	c.wire.record(pid, direction, data, latency)
+}
+
+// PID returns the process ID of the agent process
+func (c *Client) PID() int {
+	return c.cmd.Process.Pid
 }
 
 // Close terminates the claude-code-acp process and cleans up resources
@@ -256,6 +496,11 @@ func (c *Client) Close() error {
 	c.closed = true
 	c.closedMu.Unlock()
 
+	// A stopped agent cannot see stdin close; continue it or Close waits out the timeout
+	if c.stopped.Load() {
+		c.tree.resume()
+	}
+
 	// Close stdin to signal the process to exit
 	if err := c.stdin.Close(); err != nil {
 		return fmt.Errorf("failed to close stdin: %w", err)
@@ -265,19 +510,19 @@ func (c *Client) Close() error {
 	done := make(chan error, 1)
 	go func() { done <- c.cmd.Wait() }()
 
+	var waitErr error
+	exited := false
 	select {
-	case err := <-done:
+	case waitErr = <-done:
 		// Process exited normally
-		if err != nil {
-			// Process may exit with non-zero status, which is acceptable
-			// Only return error if it's a system error, not exit status
-			if _, ok := err.(*exec.ExitError); !ok {
-				return fmt.Errorf("failed to wait for process: %w", err)
-			}
-		}
+		exited = true
 	case <-time.After(5 * time.Second):
-		// Process didn't exit in time, force kill it
-		_ = c.cmd.Process.Kill()
+		// Process didn't exit in time, force kill it below
+	}
+
+	// Kill the agent if it hung, along with any processes it left running
+	c.tree.kill()
+	if !exited {
 		<-done // Wait for goroutine to finish
 	}
 
@@ -285,5 +530,10 @@ func (c *Client) Close() error {
 	_ = c.stdout.Close()
 	_ = c.stderr.Close()
 
+	// Process may exit with non-zero status, which is acceptable
+	// Only return error if it's a system error, not exit status
+	if _, ok := waitErr.(*exec.ExitError); waitErr != nil && !ok {
+		return fmt.Errorf("failed to wait for process: %w", waitErr)
+	}
 	return nil
 }
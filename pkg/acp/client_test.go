@@ -1,6 +1,8 @@
 package acp_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -46,19 +48,24 @@ func (l *capturingLogger) snapshot() []string {
 // getEchoAgentPath returns the path to the echo-agent binary for testing
 func getEchoAgentPath(t *testing.T) string {
 	t.Helper()
+	return getAgentPath(t, "echo-agent")
+}
+
+// getAgentPath returns the path to a test agent built into bin/
+func getAgentPath(t *testing.T, name string) string {
+	t.Helper()
 
-	// Skip on Windows - echo-agent and bash scripts require Unix-like environment
 	if runtime.GOOS == "windows" {
-		t.Skip("skipping on Windows: echo-agent and bash scripts require a Unix-like shell")
+		name += ".exe"
 	}
 
-	binPath, err := filepath.Abs("../../bin/echo-agent")
+	binPath, err := filepath.Abs(filepath.Join("../../bin", name))
 	if err != nil {
-		t.Fatalf("Failed to get echo-agent path: %v", err)
+		t.Fatalf("Failed to get %s path: %v", name, err)
 	}
 
 	if _, err := os.Stat(binPath); os.IsNotExist(err) {
-		t.Skip("echo-agent binary not found, run 'make build' first")
+		t.Skipf("%s binary not found, run 'make build' first", name)
 	}
 
 	return binPath
@@ -260,6 +267,75 @@ func TestClient_WithLogger(t *testing.T) {
 	}
 }
 
+func TestClient_WithEnv(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping on Windows: mock shell script requires Unix-like environment")
+	}
+
+	workspace := t.TempDir()
+	scriptPath := filepath.Join(t.TempDir(), "env-agent.sh")
+
+	script := "#!/bin/sh\n" +
+		"echo \"proxy=$HTTPS_PROXY key=$ANTHROPIC_API_KEY\" >&2\n" +
+		"sleep 0.2\n"
+
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write mock script: %v", err)
+	}
+
+	logger := &capturingLogger{}
+
+	client, err := acp.NewClient(workspace, "test-api-key",
+		acp.WithCommand(scriptPath),
+		acp.WithLogger(logger),
+		acp.WithEnv("HTTPS_PROXY=http://proxy:3128", "ANTHROPIC_API_KEY=override"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	want := "proxy=http://proxy:3128 key=test-api-key"
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(deadline) && !logger.contains(want) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if !logger.contains(want) {
+		t.Fatalf("Expected agent to see %q, lines=%v", want, logger.snapshot())
+	}
+}
+
+func TestSendMessage_ResponseTooLarge(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping on Windows: mock shell script requires Unix-like environment")
+	}
+
+	tmpDir := t.TempDir()
+	mockScript := filepath.Join(tmpDir, "large-agent.sh")
+	reply := `{"jsonrpc":"2.0","id":1,"result":{"type":"text","content":"` + strings.Repeat("x", 4096) + `"}}`
+	scriptContent := "#!/bin/sh\nread line\necho '" + reply + "'\ncat > /dev/null\n"
+	if err := os.WriteFile(mockScript, []byte(scriptContent), 0o755); err != nil {
+		t.Fatalf("Failed to write mock script: %v", err)
+	}
+
+	client, err := acp.NewClient(tmpDir, "test-api-key", acp.WithCommand(mockScript), acp.WithMaxMessageSize(1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	_, err = client.SendMessage("Hello")
+	var tooLarge *acp.ResponseTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 1024 {
+		t.Fatalf("Expected ResponseTooLargeError with limit 1024, got %v", err)
+	}
+}
+
 func TestNewClient_InvalidCommand(t *testing.T) {
 	t.Parallel()
 	tmpDir := t.TempDir()
@@ -338,3 +414,222 @@ func TestSendMessage_InvalidJSON(t *testing.T) {
 		t.Error("Expected non-empty error message for invalid JSON")
 	}
 }
+
+func TestSendMessageStream_ForwardsDeltas(t *testing.T) {
+	t.Parallel()
+	echoAgent := getEchoAgentPath(t)
+	tmpDir := t.TempDir()
+
+	client, err := acp.NewClient(tmpDir, "test-api-key", acp.WithCommand(echoAgent))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	var deltas []acp.Delta
+	msg, err := client.SendMessageStream("hello streaming world", func(d acp.Delta) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("SendMessageStream failed: %v", err)
+	}
+
+	if len(deltas) == 0 {
+		t.Fatal("Expected at least one delta")
+	}
+	var streamed strings.Builder
+	for i, d := range deltas {
+		if d.Seq != i+1 {
+			t.Errorf("Delta %d: expected seq %d, got %d", i, i+1, d.Seq)
+		}
+		streamed.WriteString(d.Content)
+	}
+	if streamed.String() != msg.Content {
+		t.Errorf("Expected deltas to reassemble %q, got %q", msg.Content, streamed.String())
+	}
+}
+
+func TestSendMessageStream_DropsStaleDeltas(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping on Windows: bash scripts require a Unix-like shell")
+	}
+	tmpDir := t.TempDir()
+
+	// Agent emits a delta for another request, an unknown notification, then
+	// a delta and the response for the current request
+	mockScript := filepath.Join(tmpDir, "stream-agent.sh")
+	scriptContent := `#!/bin/bash
+while read line; do
+  echo '{"jsonrpc":"2.0","method":"agent/delta","params":{"requestId":99,"seq":1,"content":"stale"}}'
+  echo '{"jsonrpc":"2.0","method":"agent/progress","params":{}}'
+  echo '{"jsonrpc":"2.0","method":"agent/delta","params":{"requestId":1,"seq":1,"content":"fresh"}}'
+  echo '{"jsonrpc":"2.0","id":1,"result":{"type":"text","content":"fresh"}}'
+done
+`
+	if err := os.WriteFile(mockScript, []byte(scriptContent), 0755); err != nil {
+		t.Fatalf("Failed to create stream script: %v", err)
+	}
+
+	client, err := acp.NewClient(tmpDir, "test-api-key", acp.WithCommand(mockScript))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	var deltas []acp.Delta
+	msg, err := client.SendMessageStream("Hello", func(d acp.Delta) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("SendMessageStream failed: %v", err)
+	}
+	if msg.Content != "fresh" {
+		t.Errorf("Expected content 'fresh', got %q", msg.Content)
+	}
+	if len(deltas) != 1 || deltas[0].Content != "fresh" {
+		t.Errorf("Expected only the current request's delta, got %+v", deltas)
+	}
+}
+
+func TestSendMessageContext_CancelInterruptsAgent(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping on Windows: bash scripts require a Unix-like shell")
+	}
+	tmpDir := t.TempDir()
+
+	// Agent holds request 1 until it is cancelled, then answers it with an
+	// error; later requests are answered normally
+	mockScript := filepath.Join(tmpDir, "cancel-agent.sh")
+	scriptContent := `#!/bin/bash
+read line
+read cancel
+case "$cancel" in
+  *agent/cancel*) echo '{"jsonrpc":"2.0","id":1,"error":{"code":-32800,"message":"cancelled"}}' ;;
+esac
+while read line; do
+  echo '{"jsonrpc":"2.0","id":2,"result":{"type":"text","content":"after"}}'
+done
+`
+	if err := os.WriteFile(mockScript, []byte(scriptContent), 0755); err != nil {
+		t.Fatalf("Failed to create cancel script: %v", err)
+	}
+
+	client, err := acp.NewClient(tmpDir, "test-api-key", acp.WithCommand(mockScript))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err = client.SendMessageContext(ctx, "long task", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	// The cancelled reply was consumed, so the next request gets its own answer
+	msg, err := client.SendMessage("next")
+	if err != nil {
+		t.Fatalf("SendMessage after cancel failed: %v", err)
+	}
+	if msg.Content != "after" {
+		t.Errorf("Expected content 'after', got %q", msg.Content)
+	}
+}
+
+func TestSendMessageContext_SendsMetadata(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping on Windows: bash scripts require a Unix-like shell")
+	}
+	tmpDir := t.TempDir()
+
+	// Agent answers whether each request carried the expected metadata
+	mockScript := filepath.Join(tmpDir, "metadata-agent.sh")
+	scriptContent := `#!/bin/bash
+id=1
+while read line; do
+  case "$line" in
+    *'"metadata":{"correlationId":"c-1","sessionId":"s-1"}'*) reply=traced ;;
+    *metadata*) reply=wrong ;;
+    *) reply=plain ;;
+  esac
+  echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"result\":{\"type\":\"text\",\"content\":\"$reply\"}}"
+  id=$((id+1))
+done
+`
+	if err := os.WriteFile(mockScript, []byte(scriptContent), 0755); err != nil {
+		t.Fatalf("Failed to create metadata script: %v", err)
+	}
+
+	client, err := acp.NewClient(tmpDir, "test-api-key", acp.WithCommand(mockScript))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx := acp.WithMetadata(context.Background(), map[string]string{"sessionId": "s-1", "correlationId": "c-1"})
+	msg, err := client.SendMessageContext(ctx, "hello", nil)
+	if err != nil {
+		t.Fatalf("SendMessageContext failed: %v", err)
+	}
+	if msg.Content != "traced" {
+		t.Errorf("Expected the metadata to reach the agent, got %q", msg.Content)
+	}
+
+	msg, err = client.SendMessage("hello")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if msg.Content != "plain" {
+		t.Errorf("Expected no metadata without WithMetadata, got %q", msg.Content)
+	}
+}
+
+func TestSendMessageContext_AlreadyCancelled(t *testing.T) {
+	t.Parallel()
+	echoAgent := getEchoAgentPath(t)
+	tmpDir := t.TempDir()
+
+	client, err := acp.NewClient(tmpDir, "test-api-key", acp.WithCommand(echoAgent))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.SendMessageContext(ctx, "hello", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	// Nothing was sent, so the agent is still in sync
+	if _, err := client.SendMessage("hello"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+}
+
+func TestSendMessageContext_CancelsSlowEchoAgent(t *testing.T) {
+	t.Parallel()
+	echoAgent := getEchoAgentPath(t)
+	tmpDir := t.TempDir()
+
+	client, err := acp.NewClient(tmpDir, "test-api-key", acp.WithCommand(echoAgent, "-latency", "10s", "-notifications", "2"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.SendMessageContext(ctx, "hello", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Cancel took %v; the agent ignored agent/cancel", elapsed)
+	}
+}
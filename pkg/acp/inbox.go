@@ -0,0 +1,113 @@
+package acp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// maxInboxLine is the longest line an Inbox reads
+const maxInboxLine = 16 << 20
+
+// Inbox is the agent side of the protocol: it reads one JSON-RPC message per
+// line, for the test agents that stand in for a real one
+// Next returns messages in order; Wait lets an agent pause mid-answer while
+// still noticing an agent/cancel for the request it is answering.
+type Inbox struct {
+	lines   <-chan []byte
+	queued  [][]byte // Requests that arrived during a Wait
+	scanErr chan error
+}
+
+// NewInbox starts reading lines from r
+func NewInbox(r io.Reader) *Inbox {
+	lines := make(chan []byte)
+	scanErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxInboxLine)
+		for scanner.Scan() {
+			lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+		scanErr <- scanner.Err()
+		close(lines)
+	}()
+	return &Inbox{lines: lines, scanErr: scanErr}
+}
+
+// Next returns the next line to handle, queued ones first
+// It returns false once the input is exhausted.
+func (in *Inbox) Next() ([]byte, bool) {
+	if len(in.queued) > 0 {
+		line := in.queued[0]
+		in.queued = in.queued[1:]
+		return line, true
+	}
+	if in.lines == nil {
+		return nil, false
+	}
+	line, ok := <-in.lines
+	if !ok {
+		in.lines = nil
+	}
+	return line, ok
+}
+
+// Err returns the error that ended the input, if it was not EOF
+// Call it only after Next has returned false.
+func (in *Inbox) Err() error {
+	return <-in.scanErr
+}
+
+// Wait sleeps for d, watching the input for an agent/cancel of request id
+// It returns false if the request was cancelled. Other requests arriving
+// meanwhile are queued for Next; other notifications are dropped.
+func (in *Inbox) Wait(id interface{}, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case line, ok := <-in.lines:
+			if !ok {
+				in.lines = nil // Input closed: finish the answer anyway
+				continue
+			}
+			if !isNotificationLine(line) {
+				in.queued = append(in.queued, line)
+				continue
+			}
+			if isCancelOf(line, id) {
+				return false
+			}
+		}
+	}
+}
+
+// isNotificationLine reports whether line is a message without an id (pure function)
+func isNotificationLine(line []byte) bool {
+	var msg struct {
+		ID json.RawMessage `json:"id"`
+	}
+	return json.Unmarshal(line, &msg) == nil && (len(msg.ID) == 0 || string(msg.ID) == "null")
+}
+
+// isCancelOf reports whether line is an agent/cancel notification for
+// request id (pure function)
+func isCancelOf(line []byte, id interface{}) bool {
+	var n Notification
+	if err := json.Unmarshal(line, &n); err != nil || n.Method != MethodCancel {
+		return false
+	}
+	var params CancelParams
+	if err := json.Unmarshal(n.Params, &params); err != nil {
+		return false
+	}
+	requestID, ok := id.(float64)
+	return ok && float64(params.RequestID) == requestID
+}
@@ -0,0 +1,53 @@
+package acp
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestInbox_WaitQueuesRequestsAndStopsOnCancel(t *testing.T) {
+	r, w := io.Pipe()
+	in := NewInbox(r)
+	go func() {
+		for _, line := range []string{
+			`{"jsonrpc":"2.0","id":2,"method":"agent/sendMessage"}`,
+			`{"jsonrpc":"2.0","method":"agent/progress"}`,
+			`{"jsonrpc":"2.0","method":"agent/cancel","params":{"requestId":9}}`,
+			`{"jsonrpc":"2.0","method":"agent/cancel","params":{"requestId":1}}`,
+		} {
+			io.WriteString(w, line+"\n")
+		}
+		w.Close()
+	}()
+
+	if in.Wait(float64(1), 10*time.Second) {
+		t.Fatal("expected the wait cut short by the cancel for request 1")
+	}
+	line, ok := in.Next()
+	if !ok || string(line) != `{"jsonrpc":"2.0","id":2,"method":"agent/sendMessage"}` {
+		t.Fatalf("expected the request that arrived during the wait, got %q", line)
+	}
+	if line, ok := in.Next(); ok {
+		t.Errorf("expected notifications dropped and the input exhausted, got %q", line)
+	}
+	if err := in.Err(); err != nil {
+		t.Errorf("expected no scan error, got %v", err)
+	}
+}
+
+func TestInbox_WaitOutlivesClosedInput(t *testing.T) {
+	r, w := io.Pipe()
+	in := NewInbox(r)
+	w.Close()
+
+	if !in.Wait(float64(1), 20*time.Millisecond) {
+		t.Error("expected the wait to run its course once the input closed")
+	}
+	if !in.Wait(float64(1), 0) {
+		t.Error("expected a zero wait to return at once")
+	}
+	if _, ok := in.Next(); ok {
+		t.Error("expected the input exhausted")
+	}
+}
@@ -0,0 +1,12 @@
+package acp
+
+import (
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/testutil"
+)
+
+// TestMain fails the run if tests leave goroutines or agent processes behind
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
@@ -0,0 +1,139 @@
+package acp_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+func TestMockClaude_StreamsReply(t *testing.T) {
+	t.Parallel()
+	client, err := acp.NewClient(t.TempDir(), "unused", acp.WithCommand(getAgentPath(t, "mock-claude"), "-chunk-delay", "1ms"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	var streamed strings.Builder
+	msg, err := client.SendMessageStream("hello there", func(d acp.Delta) { streamed.WriteString(d.Content) })
+	if err != nil {
+		t.Fatalf("SendMessageStream failed: %v", err)
+	}
+	if !strings.Contains(msg.Content, "hello there") || streamed.String() != msg.Content {
+		t.Errorf("expected streamed reply to match %q, got %q", msg.Content, streamed.String())
+	}
+}
+
+func TestMockClaude_ToolCallAwaitsApproval(t *testing.T) {
+	t.Parallel()
+	client, err := acp.NewClient(t.TempDir(), "unused", acp.WithCommand(getAgentPath(t, "mock-claude")))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	msg, err := client.SendMessage(`/tool bash {"command":"ls"}`)
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	parts := msg.AllParts()
+	if len(parts) != 2 || parts[1].ToolCall == nil || parts[1].ToolCall.Name != "bash" || parts[1].ToolCall.ID == "" {
+		t.Fatalf("expected a pending bash tool call, got %+v", parts)
+	}
+	if parts[1].ToolCall.Args["command"] != "ls" {
+		t.Errorf("expected args to be passed through, got %v", parts[1].ToolCall.Args)
+	}
+
+	msg, err = client.SendMessage("yes")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if parts := msg.AllParts(); len(parts) != 2 || parts[0].Text != "Ran bash." {
+		t.Errorf("expected the approved call to run, got %+v", parts)
+	}
+}
+
+func TestMockClaude_ResolveToolCall(t *testing.T) {
+	t.Parallel()
+	client, err := acp.NewClient(t.TempDir(), "unused", acp.WithCommand(getAgentPath(t, "mock-claude")))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	msg, err := client.SendMessage("/tool rm")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	parts := msg.AllParts()
+	if len(parts) != 2 || parts[1].ToolCall == nil {
+		t.Fatalf("expected a pending tool call, got %+v", parts)
+	}
+	ctx := context.Background()
+
+	msg, err = client.ResolveToolCall(ctx, acp.ToolCallParams{ToolCallID: parts[1].ToolCall.ID, Approved: false}, nil)
+	if err != nil {
+		t.Fatalf("ResolveToolCall failed: %v", err)
+	}
+	if msg.Content != "Okay, I won't run rm." {
+		t.Errorf("expected the denied call not to run, got %+v", msg)
+	}
+
+	// Nothing is awaiting approval any more
+	_, err = client.ResolveToolCall(ctx, acp.ToolCallParams{ToolCallID: parts[1].ToolCall.ID, Approved: true}, nil)
+	if !errors.Is(err, acp.ErrInvalidParams) {
+		t.Errorf("expected ErrInvalidParams for a resolved call, got %v", err)
+	}
+}
+
+func TestMockClaude_CancelInterruptsStream(t *testing.T) {
+	t.Parallel()
+	client, err := acp.NewClient(t.TempDir(), "unused", acp.WithCommand(getAgentPath(t, "mock-claude"), "-chunk-delay", "1s"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.SendMessageContext(ctx, "a long reply", nil); err == nil {
+		t.Fatal("expected the cancelled request to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("cancel took %v; the stream was not interrupted", elapsed)
+	}
+}
+
+func TestMockClaude_Initialize(t *testing.T) {
+	t.Parallel()
+	client, err := acp.NewClient(t.TempDir(), "unused", acp.WithCommand(getAgentPath(t, "mock-claude"), "-require-initialize"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	temperature := 0.2
+	info, err := client.Initialize(context.Background(), acp.InitializeParams{
+		ClientName:   "test",
+		SystemPrompt: "You review code.",
+		Model:        "claude-sonnet",
+		Temperature:  &temperature,
+		MaxTokens:    1024,
+	})
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if info.ProtocolVersion != acp.ProtocolVersion || !info.Capabilities.Streaming {
+		t.Errorf("unexpected initialize result: %+v", info)
+	}
+
+	// The agent now accepts prompts
+	if _, err := client.SendMessage("hello"); err != nil {
+		t.Fatalf("SendMessage after Initialize failed: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build !windows
+
+package acp
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// prepareCommand starts the agent in its own process group, so Close can kill
+// the processes it spawned (tools, language servers) along with it
+func prepareCommand(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return nil
+}
+
+// processTree is the agent's process group
+type processTree struct {
+	pgid int
+}
+
+// newProcessTree tracks the group of a started agent process
+func newProcessTree(cmd *exec.Cmd, _ Logger) processTree {
+	return processTree{pgid: cmd.Process.Pid}
+}
+
+// kill sends SIGKILL to every process left in the group
+func (t processTree) kill() {
+	_ = syscall.Kill(-t.pgid, syscall.SIGKILL) // ESRCH once the group is empty
+}
+
+// resume sends SIGCONT to every process in the group
+func (t processTree) resume() {
+	_ = t.signal(syscall.SIGCONT)
+}
+
+// signal sends sig to every process in the group
+func (t processTree) signal(sig syscall.Signal) error {
+	if t.pgid <= 0 {
+		return fmt.Errorf("no agent process group") // Kill(0) would signal the relay's own group
+	}
+	return syscall.Kill(-t.pgid, sig)
+}
+
+// Suspend stops the agent and the processes it started with SIGSTOP
+// Used when a session is paused so an idle agent and its tools don't consume
+// CPU or tokens
+func (c *Client) Suspend() error {
+	if err := c.signal(syscall.SIGSTOP); err != nil {
+		return err
+	}
+	c.stopped.Store(true)
+	return nil
+}
+
+// Resume continues a suspended agent and its processes with SIGCONT
+func (c *Client) Resume() error {
+	if err := c.signal(syscall.SIGCONT); err != nil {
+		return err
+	}
+	c.stopped.Store(false)
+	return nil
+}
+
+// signal delivers sig to the agent's process group if the client is still open
+func (c *Client) signal(sig syscall.Signal) error {
+	c.closedMu.RLock()
+	defer c.closedMu.RUnlock()
+	if c.closed {
+		return fmt.Errorf("client is closed")
+	}
+	if err := c.tree.signal(sig); err != nil {
+		return fmt.Errorf("failed to send %v to agent processes: %w", sig, err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+//go:build !windows
+
+package acp_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+func TestSuspendResume_RoundTrip(t *testing.T) {
+	t.Parallel()
+	echoAgent := getEchoAgentPath(t)
+	tmpDir := t.TempDir()
+
+	client, err := acp.NewClient(tmpDir, "test-api-key", acp.WithCommand(echoAgent))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Suspend(); err != nil {
+		t.Fatalf("Suspend() failed: %v", err)
+	}
+	if err := client.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+
+	// Process must be responsive again after SIGCONT
+	msg, err := client.SendMessage("after resume")
+	if err != nil {
+		t.Fatalf("SendMessage after resume failed: %v", err)
+	}
+	if msg.Content != "Echo: after resume" {
+		t.Errorf("expected echo response, got %q", msg.Content)
+	}
+}
+
+func TestClose_SuspendedExitsPromptly(t *testing.T) {
+	t.Parallel()
+	echoAgent := getEchoAgentPath(t)
+
+	client, err := acp.NewClient(t.TempDir(), "test-api-key", acp.WithCommand(echoAgent))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.Suspend(); err != nil {
+		t.Fatalf("Suspend() failed: %v", err)
+	}
+
+	start := time.Now()
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected a suspended agent to exit on stdin close, Close took %v", elapsed)
+	}
+}
+
+func TestSuspend_AfterClose(t *testing.T) {
+	t.Parallel()
+	echoAgent := getEchoAgentPath(t)
+	tmpDir := t.TempDir()
+
+	client, err := acp.NewClient(tmpDir, "test-api-key", acp.WithCommand(echoAgent))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	if err := client.Suspend(); err == nil {
+		t.Error("expected error suspending a closed client")
+	}
+}
+
+// startSpawningAgent starts an agent that runs a long-lived child, returning
+// the client and the child's PID
+// The agent exits on EOF, leaving the child behind.
+func startSpawningAgent(t *testing.T) (*acp.Client, string) {
+	t.Helper()
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("needs /proc to inspect process state")
+	}
+	tmpDir := t.TempDir()
+
+	pidFile := filepath.Join(tmpDir, "child.pid")
+	mockScript := filepath.Join(tmpDir, "spawning-agent.sh")
+	script := "#!/bin/sh\nsleep 60 &\necho $! > " + pidFile + "\ncat > /dev/null\n"
+	if err := os.WriteFile(mockScript, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write mock script: %v", err)
+	}
+
+	client, err := acp.NewClient(tmpDir, "test-api-key", acp.WithCommand(mockScript))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if data, err := os.ReadFile(pidFile); err == nil && strings.HasSuffix(string(data), "\n") {
+			return client, strings.TrimSpace(string(data))
+		}
+	}
+	_ = client.Close()
+	t.Fatal("agent never reported its child's PID")
+	return nil, ""
+}
+
+// processState returns the state letter of a process from /proc ("" if gone)
+func processState(pid string) string {
+	stat, err := os.ReadFile("/proc/" + pid + "/stat")
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// waitForState waits up to two seconds for a process to reach one of states
+func waitForState(pid string, states string) bool {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if state := processState(pid); state != "" && strings.Contains(states, state) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSuspend_StopsChildProcesses(t *testing.T) {
+	t.Parallel()
+	client, pid := startSpawningAgent(t)
+	defer client.Close()
+
+	if err := client.Suspend(); err != nil {
+		t.Fatalf("Suspend() failed: %v", err)
+	}
+	if !waitForState(pid, "T") {
+		t.Errorf("expected the agent's child stopped while suspended, state %q", processState(pid))
+	}
+	if err := client.Resume(); err != nil {
+		t.Fatalf("Resume() failed: %v", err)
+	}
+	if !waitForState(pid, "SR") {
+		t.Errorf("expected the agent's child running again after Resume, state %q", processState(pid))
+	}
+}
+
+func TestClose_KillsProcessTree(t *testing.T) {
+	t.Parallel()
+	client, pid := startSpawningAgent(t)
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	// Killed children may linger as zombies until reaped by init
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		stat, err := os.ReadFile("/proc/" + pid + "/stat")
+		if err != nil || strings.Contains(string(stat), ") Z ") {
+			return
+		}
+	}
+	t.Errorf("child process %s outlived Close", pid)
+}
@@ -0,0 +1,143 @@
+//go:build windows
+
+package acp
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// cmdMetachars are characters cmd.exe interprets even inside quoted arguments
+const cmdMetachars = "&|<>^%!\"\r\n"
+
+// prepareCommand runs batch and PowerShell scripts through their interpreter,
+// since CreateProcess only starts executables (npm installs claude-code-acp
+// as a .cmd shim)
+func prepareCommand(cmd *exec.Cmd) error {
+	path, args, err := scriptCommand(cmd.Path, cmd.Args, exec.LookPath)
+	if err != nil {
+		return err
+	}
+	cmd.Path, cmd.Args = path, args
+	return nil
+}
+
+// scriptCommand returns the interpreter command line running script path with
+// args (args[0] is the program name), or path and args unchanged for executables
+// cmd.exe re-parses its command line, so arguments with its metacharacters
+// are refused rather than risk them being run as commands.
+func scriptCommand(path string, args []string, lookPath func(string) (string, error)) (string, []string, error) {
+	var interpreter []string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".cmd", ".bat":
+		for _, arg := range append([]string{path}, args[1:]...) {
+			if strings.ContainsAny(arg, cmdMetachars) {
+				return "", nil, fmt.Errorf("argument %q of batch script %s contains characters cmd.exe would interpret", arg, path)
+			}
+		}
+		comspec := os.Getenv("ComSpec")
+		if comspec == "" {
+			comspec = "cmd.exe"
+		}
+		interpreter = []string{comspec, "/d", "/c"}
+	case ".ps1":
+		interpreter = []string{"powershell.exe", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File"}
+	default:
+		return path, args, nil
+	}
+
+	resolved, err := lookPath(interpreter[0])
+	if err != nil {
+		return "", nil, fmt.Errorf("interpreter for %s: %w", path, err)
+	}
+	argv := append(append(interpreter, path), args[1:]...)
+	return resolved, argv, nil
+}
+
+// processTree is a job object holding the agent and every process it starts;
+// closing the job kills them all
+type processTree struct {
+	job     windows.Handle // Zero if the agent could not be put in a job
+	process *os.Process
+}
+
+// newProcessTree puts a started agent process in a kill-on-close job object
+// Processes the agent started before it was assigned escape the job; if the
+// assignment fails (e.g. no nested jobs on old Windows) only the agent itself
+// is killed.
+func newProcessTree(cmd *exec.Cmd, logger Logger) processTree {
+	tree := processTree{process: cmd.Process}
+	job, err := newKillOnCloseJob()
+	if err == nil {
+		err = assignToJob(job, cmd.Process.Pid)
+		if err != nil {
+			_ = windows.CloseHandle(job)
+		}
+	}
+	if err != nil {
+		logger.Printf("agent process %d is not in a job object; child processes will outlive it: %v", cmd.Process.Pid, err)
+		return tree
+	}
+	tree.job = job
+	return tree
+}
+
+// newKillOnCloseJob creates a job whose processes are killed when its last handle closes
+func newKillOnCloseJob() (windows.Handle, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("create job object: %w", err)
+	}
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	// #nosec G103 -- SetInformationJobObject takes a pointer to the info struct
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		_ = windows.CloseHandle(job)
+		return 0, fmt.Errorf("configure job object: %w", err)
+	}
+	return job, nil
+}
+
+// assignToJob adds process pid to job
+func assignToJob(job windows.Handle, pid int) error {
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid)) // #nosec G115 -- PIDs fit in uint32
+	if err != nil {
+		return fmt.Errorf("open process: %w", err)
+	}
+	defer windows.CloseHandle(process)
+	if err := windows.AssignProcessToJobObject(job, process); err != nil {
+		return fmt.Errorf("assign to job object: %w", err)
+	}
+	return nil
+}
+
+// kill terminates every process left in the job, or just the agent without one
+func (t processTree) kill() {
+	if t.job == 0 {
+		_ = t.process.Kill()
+		return
+	}
+	_ = windows.TerminateJobObject(t.job, 1)
+	_ = windows.CloseHandle(t.job)
+}
+
+// resume does nothing: agents cannot be suspended on Windows
+func (t processTree) resume() {}
+
+// Suspend is not supported on Windows: there is no SIGSTOP equivalent for
+// arbitrary child processes without suspending each thread individually
+func (c *Client) Suspend() error {
+	return fmt.Errorf("suspending agent processes is not supported on windows")
+}
+
+// Resume is not supported on Windows (see Suspend)
+func (c *Client) Resume() error {
+	return fmt.Errorf("resuming agent processes is not supported on windows")
+}
@@ -0,0 +1,47 @@
+//go:build windows
+
+package acp
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestScriptCommand(t *testing.T) {
+	t.Setenv("ComSpec", `C:\Windows\System32\cmd.exe`)
+	lookPath := func(name string) (string, error) { return `C:\bin\` + name, nil }
+
+	tests := []struct {
+		name     string
+		path     string
+		wantPath string
+		wantArgs []string
+	}{
+		{"executable", `C:\npm\agent.exe`, `C:\npm\agent.exe`, []string{"agent", "--workspace", `C:\work`}},
+		{"batch shim", `C:\npm\claude-code-acp.CMD`, `C:\bin\C:\Windows\System32\cmd.exe`,
+			[]string{`C:\Windows\System32\cmd.exe`, "/d", "/c", `C:\npm\claude-code-acp.CMD`, "--workspace", `C:\work`}},
+		{"powershell", `C:\npm\agent.ps1`, `C:\bin\powershell.exe`,
+			[]string{"powershell.exe", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", `C:\npm\agent.ps1`, "--workspace", `C:\work`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []string{"agent", "--workspace", `C:\work`}
+			path, argv, err := scriptCommand(tt.path, args, lookPath)
+			if err != nil {
+				t.Fatalf("scriptCommand failed: %v", err)
+			}
+			if path != tt.wantPath || !slices.Equal(argv, tt.wantArgs) {
+				t.Errorf("expected %s %v, got %s %v", tt.wantPath, tt.wantArgs, path, argv)
+			}
+		})
+	}
+}
+
+func TestScriptCommand_RefusesCmdMetacharacters(t *testing.T) {
+	lookPath := func(name string) (string, error) { return name, nil }
+	_, _, err := scriptCommand(`C:\npm\agent.cmd`, []string{"agent", "--workspace", `C:\work & calc`}, lookPath)
+	if err == nil || !strings.Contains(err.Error(), "cmd.exe would interpret") {
+		t.Errorf("expected metacharacter error, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package acp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxMessageSize is the largest message accepted from an agent when
+// WithMaxMessageSize is not used
+const DefaultMaxMessageSize = 5 << 20
+
+// ResponseTooLargeError is returned when an agent sends a message longer than
+// the client's limit
+// The rest of the message is never read, so the client cannot be used for
+// further requests; close it and start a new agent.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("agent message exceeds %d bytes", e.Limit)
+}
+
+// messageDecoder reads JSON-RPC messages from an agent's stdout
+// Messages are decoded as a JSON stream, so they may span lines; each one may
+// be at most limit bytes (0 means unlimited).
+type messageDecoder struct {
+	dec *json.Decoder
+	in  *limitedReader
+}
+
+func newMessageDecoder(r io.Reader, limit int64) *messageDecoder {
+	in := &limitedReader{r: r, limit: limit}
+	return &messageDecoder{dec: json.NewDecoder(in), in: in}
+}
+
+// next returns the next message
+// Errors are sticky: after a malformed or oversized message the stream
+// position is lost and every later call fails the same way.
+func (d *messageDecoder) next() (json.RawMessage, error) {
+	d.in.start = d.dec.InputOffset()
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// limitedReader hands the decoder at most limit bytes past the start of the
+// message being decoded, so an oversized message fails before it is buffered
+type limitedReader struct {
+	r     io.Reader
+	limit int64 // Zero means unlimited
+	read  int64 // Bytes handed to the decoder so far
+	start int64 // Stream offset of the message being decoded
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.limit > 0 {
+		remaining := l.start + l.limit - l.read
+		if remaining <= 0 {
+			return 0, &ResponseTooLargeError{Limit: l.limit}
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	return n, err
+}
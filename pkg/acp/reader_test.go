@@ -0,0 +1,41 @@
+package acp
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMessageDecoder_Limit(t *testing.T) {
+	small := `{"id":1}`
+	large := `{"id":2,"result":"` + strings.Repeat("x", 100) + `"}`
+	d := newMessageDecoder(strings.NewReader(small+"\n"+large+"\n"+small), int64(len(small)+1))
+
+	raw, err := d.next()
+	if err != nil || string(raw) != small {
+		t.Fatalf("expected %s, got %s (%v)", small, raw, err)
+	}
+
+	var tooLarge *ResponseTooLargeError
+	if _, err := d.next(); !errors.As(err, &tooLarge) || tooLarge.Limit != int64(len(small)+1) {
+		t.Fatalf("expected ResponseTooLargeError, got %v", err)
+	}
+	if _, err := d.next(); !errors.As(err, &tooLarge) {
+		t.Errorf("expected the error to stick, got %v", err)
+	}
+}
+
+func TestMessageDecoder_MultiLineAndUnlimited(t *testing.T) {
+	pretty := "{\n  \"id\": 1,\n  \"result\": \"" + strings.Repeat("x", 10000) + "\"\n}\n"
+	d := newMessageDecoder(strings.NewReader(pretty+`{"id":2}`), 0)
+
+	for i := 0; i < 2; i++ {
+		if _, err := d.next(); err != nil {
+			t.Fatalf("message %d: %v", i+1, err)
+		}
+	}
+	if _, err := d.next(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
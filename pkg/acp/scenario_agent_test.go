@@ -0,0 +1,77 @@
+package acp_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// newScenarioClient starts the scenario agent playing the given YAML
+func newScenarioClient(t *testing.T, scenario string) *acp.Client {
+	t.Helper()
+	agent := getAgentPath(t, "scenario-agent")
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(scenario), 0o600); err != nil {
+		t.Fatalf("Failed to write scenario: %v", err)
+	}
+	client, err := acp.NewClient(t.TempDir(), "unused", acp.WithCommand(agent, "-scenario", path))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestScenarioAgent_PlaysStepsInOrder(t *testing.T) {
+	t.Parallel()
+	client := newScenarioClient(t, `
+steps:
+  - match: {content: hello}
+    deltas: ["Hi ", "there"]
+    reply: Hi there
+  - match: {pattern: "^run"}
+    toolCall: {id: tool-1, name: bash, args: {command: ls}}
+`)
+
+	var streamed strings.Builder
+	msg, err := client.SendMessageStream("hello", func(d acp.Delta) { streamed.WriteString(d.Content) })
+	if err != nil {
+		t.Fatalf("SendMessageStream failed: %v", err)
+	}
+	if msg.Content != "Hi there" || streamed.String() != "Hi there" {
+		t.Errorf("expected scripted reply, got %q (streamed %q)", msg.Content, streamed.String())
+	}
+
+	msg, err = client.SendMessage("run the tests")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if msg.ToolCall == nil || msg.ToolCall.ID != "tool-1" || msg.ToolCall.Args["command"] != "ls" {
+		t.Errorf("expected scripted tool call, got %+v", msg)
+	}
+
+	if _, err := client.SendMessage("one more"); !errors.Is(err, acp.ErrInvalidRequest) {
+		t.Errorf("expected the finished scenario to reject requests, got %v", err)
+	}
+}
+
+func TestScenarioAgent_RejectsUnexpectedRequest(t *testing.T) {
+	t.Parallel()
+	client := newScenarioClient(t, `
+steps:
+  - match: {content: hello}
+    reply: Hi
+`)
+
+	_, err := client.SendMessage("goodbye")
+	if !errors.Is(err, acp.ErrInvalidRequest) || !strings.Contains(err.Error(), "step 1") {
+		t.Fatalf("expected a step 1 mismatch, got %v", err)
+	}
+	if msg, err := client.SendMessage("hello"); err != nil || msg.Content != "Hi" {
+		t.Errorf("expected the step to still be next, got %+v, %v", msg, err)
+	}
+}
@@ -1,5 +1,11 @@
 package acp
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
 // JSON-RPC 2.0 message structures for ACP
 
 // Request represents a JSON-RPC 2.0 request
@@ -19,38 +25,306 @@ type Response struct {
 }
 
 // Error represents a JSON-RPC 2.0 error object
+// The client returns the agent's error objects as *Error, so callers can
+// inspect Data; errors.Is matches the standard codes against ErrParse,
+// ErrInvalidRequest, ErrMethodNotFound, ErrInvalidParams and ErrInternal.
 type Error struct {
-	Data    interface{} `json:"data,omitempty"`
-	Message string      `json:"message"`
-	Code    int         `json:"code"`
+	Data    json.RawMessage `json:"data,omitempty"` // Agent-defined detail, as sent
+	Message string          `json:"message"`
+	Code    int             `json:"code"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ACP error (code %d): %s", e.Code, e.Message)
+}
+
+// Is reports whether target is the sentinel for e's standard code
+func (e *Error) Is(target error) bool {
+	sentinel, ok := standardErrors[e.Code]
+	return ok && sentinel == target
+}
+
+// DecodeData unmarshals Data into v
+func (e *Error) DecodeData(v interface{}) error {
+	if len(e.Data) == 0 {
+		return fmt.Errorf("error has no data")
+	}
+	return json.Unmarshal(e.Data, v)
+}
+
+// Standard JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Sentinels matching agent errors with the standard codes (see Error)
+var (
+	ErrParse          = errors.New("agent could not parse the request")
+	ErrInvalidRequest = errors.New("agent rejected the request as invalid")
+	ErrMethodNotFound = errors.New("agent does not support the method")
+	ErrInvalidParams  = errors.New("agent rejected the request parameters")
+	ErrInternal       = errors.New("agent internal error")
+)
+
+// standardErrors maps standard codes to their sentinels
+var standardErrors = map[int]error{
+	CodeParseError:     ErrParse,
+	CodeInvalidRequest: ErrInvalidRequest,
+	CodeMethodNotFound: ErrMethodNotFound,
+	CodeInvalidParams:  ErrInvalidParams,
+	CodeInternalError:  ErrInternal,
+}
+
+// ErrInvalidMessage is returned when the agent sends something that is not a
+// valid JSON-RPC 2.0 response or notification
+var ErrInvalidMessage = errors.New("invalid JSON-RPC message from agent")
+
+// envelope is a message from the agent before it is known to be a response
+// or a notification; raw fields tell absent members from null ones
+type envelope struct {
+	ID      json.RawMessage `json:"id"`
+	Params  json.RawMessage `json:"params"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+}
+
+// validate checks the members JSON-RPC 2.0 requires of a response or notification
+func (e envelope) validate() error {
+	if e.JSONRPC != "2.0" {
+		return fmt.Errorf("%w: jsonrpc is %q, want \"2.0\"", ErrInvalidMessage, e.JSONRPC)
+	}
+	if e.Method != "" {
+		return nil
+	}
+	if len(e.ID) == 0 {
+		return fmt.Errorf("%w: response has no id", ErrInvalidMessage)
+	}
+	if (len(e.Result) > 0) == (e.Error != nil) {
+		return fmt.Errorf("%w: response must have exactly one of result and error", ErrInvalidMessage)
+	}
+	return nil
 }
 
 // ACP-specific methods
 const (
+	// MethodInitialize opens a connection; agents that require it reject
+	// other requests until it succeeds
+	MethodInitialize = "initialize"
+
 	MethodSendMessage = "agent/sendMessage"
 	MethodGetContext  = "agent/getContext"
 	MethodToolCall    = "agent/toolCall"
+
+	// MethodDelta is a notification (no id) carrying a partial response chunk
+	// Agents may send any number of these before the final response
+	MethodDelta = "agent/delta"
+
+	// MethodCancel is a notification asking the agent to stop working on a
+	// request; the agent still answers that request (typically with an error)
+	MethodCancel = "agent/cancel"
 )
 
+// Notification represents a JSON-RPC 2.0 notification (a request without an id)
+type Notification struct {
+	Params  json.RawMessage `json:"params,omitempty"`
+	JSONRPC string          `json:"jsonrpc"` // Always "2.0"
+	Method  string          `json:"method"`
+}
+
+// CancelParams identifies the request an agent/cancel notification interrupts
+type CancelParams struct {
+	RequestID int `json:"requestId"`
+}
+
+// Delta is a partial chunk of the agent's response to one request
+// Seq starts at 1 and increases by one per chunk; concatenating Content in
+// Seq order yields the streamed text
+type Delta struct {
+	RequestID int    `json:"requestId"`
+	Seq       int    `json:"seq"`
+	Content   string `json:"content"`
+}
+
 // SendMessageParams represents parameters for sending a message to the agent
+// Metadata carries opaque key/value pairs, such as relay trace IDs, that the
+// agent may log but must not treat as part of the prompt.
 type SendMessageParams struct {
-	Images  []string `json:"images,omitempty"`
-	Content string   `json:"content"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Images   []string          `json:"images,omitempty"`
+	Content  string            `json:"content"`
 }
 
 // AgentMessage represents a message from the agent
+// Agents may return rich output as Parts; Type/Content/ToolCall remain for
+// agents that only speak the original single-part format. Use AllParts to
+// read either shape uniformly.
 type AgentMessage struct {
 	ToolCall *ToolCall `json:"toolCall,omitempty"`
-	Type     string    `json:"type"` // "text" or "toolCall"
+	Usage    *Usage    `json:"usage,omitempty"` // Set by agents that report token usage
+	Type     string    `json:"type"`            // "text", "toolCall", or "parts"
 	Content  string    `json:"content,omitempty"`
+	Parts    []Part    `json:"parts,omitempty"`
+}
+
+// Usage is the model tokens an agent spent producing a reply
+type Usage struct {
+	InputTokens  int64 `json:"inputTokens"`
+	OutputTokens int64 `json:"outputTokens"`
+}
+
+// Agent message part types
+const (
+	PartTypeText     = "text"
+	PartTypeToolCall = "toolCall"
+	PartTypeCode     = "code"
+	PartTypePatch    = "patch"
+	PartTypeData     = "data"
+	PartTypeError    = "error"
+)
+
+// MessageTypeParts marks an AgentMessage whose content is carried in Parts
+const MessageTypeParts = "parts"
+
+// Part is one typed piece of an agent response
+// Only the fields relevant to Type are set
+type Part struct {
+	ToolCall *ToolCall       `json:"toolCall,omitempty"` // toolCall
+	Error    *PartError      `json:"error,omitempty"`    // error
+	Data     json.RawMessage `json:"data,omitempty"`     // data: arbitrary JSON
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`     // text, code
+	Language string          `json:"language,omitempty"` // code, e.g. "go"
+	Path     string          `json:"path,omitempty"`     // patch: file the diff applies to
+	Diff     string          `json:"diff,omitempty"`     // patch: unified diff
+}
+
+// PartError describes an error reported by the agent inside a response
+type PartError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Validate checks that the part carries the fields its type requires
+// Unknown types are accepted so newer agents keep working with older relays
+func (p Part) Validate() error {
+	switch p.Type {
+	case "":
+		return fmt.Errorf("part type is required")
+	case PartTypeToolCall:
+		if p.ToolCall == nil || p.ToolCall.Name == "" {
+			return fmt.Errorf("toolCall part requires toolCall.name")
+		}
+	case PartTypePatch:
+		if p.Path == "" || p.Diff == "" {
+			return fmt.Errorf("patch part requires path and diff")
+		}
+	case PartTypeData:
+		if len(p.Data) == 0 || !json.Valid(p.Data) {
+			return fmt.Errorf("data part requires valid JSON data")
+		}
+	case PartTypeError:
+		if p.Error == nil || p.Error.Message == "" {
+			return fmt.Errorf("error part requires error.message")
+		}
+	}
+	return nil
+}
+
+// AllParts returns the message content as parts
+// Legacy single-part messages are converted to one text or toolCall part
+func (m *AgentMessage) AllParts() []Part {
+	if len(m.Parts) > 0 {
+		return m.Parts
+	}
+	switch {
+	case m.ToolCall != nil:
+		return []Part{{Type: PartTypeToolCall, ToolCall: m.ToolCall}}
+	case m.Content != "":
+		return []Part{{Type: PartTypeText, Text: m.Content}}
+	default:
+		return nil
+	}
+}
+
+// Validate checks every part of the message
+func (m *AgentMessage) Validate() error {
+	for i, p := range m.Parts {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("part %d: %w", i, err)
+		}
+	}
+	return nil
 }
 
 // ToolCall represents a tool invocation from the agent
+// Calls with an ID wait for approval: the agent runs them once it gets an
+// agent/toolCall request (ToolCallParams) approving that ID.
 type ToolCall struct {
 	Args map[string]interface{} `json:"args"`
 	Name string                 `json:"name"`
+	ID   string                 `json:"id,omitempty"`
+}
+
+// ToolCallParams approves or denies a tool call awaiting approval
+// Result is set when the client ran an approved call itself; the agent must
+// use it instead of running the call.
+type ToolCallParams struct {
+	Result     *ToolResult `json:"result,omitempty"`
+	ToolCallID string      `json:"toolCallId"`
+	Approved   bool        `json:"approved"`
+}
+
+// ToolResult is the outcome of a tool call run by the client
+// ExitCode is -1 for commands that were killed; Error is set, and the other
+// fields empty, for calls that could not be run at all.
+type ToolResult struct {
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Error     string `json:"error,omitempty"`
+	ExitCode  int    `json:"exitCode"`
+	TimedOut  bool   `json:"timedOut,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
 }
 
+// InitializeParams represents parameters for initialize
+// The remaining fields configure the agent for the connection; zero values
+// leave the agent's defaults.
+type InitializeParams struct {
+	ClientName      string   `json:"clientName,omitempty"`
+	ProtocolVersion int      `json:"protocolVersion"`
+	SystemPrompt    string   `json:"systemPrompt,omitempty"`
+	Model           string   `json:"model,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+	MaxTokens       int      `json:"maxTokens,omitempty"`
+}
+
+// InitializeResult describes the agent and what it supports
+type InitializeResult struct {
+	AgentName       string            `json:"agentName"`
+	AgentVersion    string            `json:"agentVersion"`
+	Capabilities    AgentCapabilities `json:"capabilities"`
+	ProtocolVersion int               `json:"protocolVersion"`
+}
+
+// AgentCapabilities are the optional protocol features an agent supports,
+// and the tools and models it offers
+type AgentCapabilities struct {
+	Tools        []string `json:"tools,omitempty"`  // Tool names the agent may call
+	Models       []string `json:"models,omitempty"` // Models InitializeParams.Model may name
+	Streaming    bool     `json:"streaming"`        // Sends agent/delta notifications
+	Cancel       bool     `json:"cancel"`           // Honors agent/cancel
+	ToolApproval bool     `json:"toolApproval"`     // Waits for agent/toolCall before running tools
+}
+
+// ProtocolVersion is the ACP protocol version this package speaks
+const ProtocolVersion = 1
+
 // Logger abstracts logging operations for the ACP client
 type Logger interface {
 	Printf(format string, v ...interface{})
@@ -0,0 +1,156 @@
+package acp
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAgentMessage_LegacyFormatUnchanged(t *testing.T) {
+	// Messages without parts must serialize exactly as before
+	msg := AgentMessage{Type: "text", Content: "hello"}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"type":"text","content":"hello"}` {
+		t.Errorf("unexpected legacy encoding: %s", data)
+	}
+}
+
+func TestAgentMessage_PartsRoundTrip(t *testing.T) {
+	input := `{
+		"type": "parts",
+		"parts": [
+			{"type": "text", "text": "Here is the fix:"},
+			{"type": "code", "language": "go", "text": "func main() {}"},
+			{"type": "patch", "path": "main.go", "diff": "@@ -1 +1 @@\n-a\n+b"},
+			{"type": "data", "data": {"tests": 12, "passed": true}},
+			{"type": "error", "error": {"code": "TOOL_FAILED", "message": "go vet failed"}},
+			{"type": "toolCall", "toolCall": {"name": "read_file", "args": {"path": "main.go"}}}
+		]
+	}`
+
+	var msg AgentMessage
+	if err := json.Unmarshal([]byte(input), &msg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if err := msg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if len(msg.Parts) != 6 {
+		t.Fatalf("expected 6 parts, got %d", len(msg.Parts))
+	}
+	if msg.Parts[1].Language != "go" || msg.Parts[2].Path != "main.go" || msg.Parts[4].Error.Code != "TOOL_FAILED" {
+		t.Errorf("unexpected parts: %+v", msg.Parts)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var again AgentMessage
+	if err := json.Unmarshal(data, &again); err != nil {
+		t.Fatalf("re-Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(msg.Parts[2], again.Parts[2]) || string(again.Parts[3].Data) != `{"tests":12,"passed":true}` {
+		t.Errorf("parts did not survive a round trip: %+v", again.Parts)
+	}
+}
+
+func TestAgentMessage_AllParts(t *testing.T) {
+	toolCall := &ToolCall{Name: "read_file"}
+
+	tests := []struct {
+		name string
+		msg  AgentMessage
+		want []Part
+	}{
+		{"legacy text", AgentMessage{Type: "text", Content: "hi"}, []Part{{Type: PartTypeText, Text: "hi"}}},
+		{"legacy toolCall", AgentMessage{Type: "toolCall", ToolCall: toolCall}, []Part{{Type: PartTypeToolCall, ToolCall: toolCall}}},
+		{"parts win", AgentMessage{Type: "parts", Content: "ignored", Parts: []Part{{Type: PartTypeCode, Text: "x"}}}, []Part{{Type: PartTypeCode, Text: "x"}}},
+		{"empty", AgentMessage{Type: "text"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.msg.AllParts(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPart_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		part    Part
+		wantErr bool
+	}{
+		{"text", Part{Type: PartTypeText, Text: "hi"}, false},
+		{"unknown type is forward compatible", Part{Type: "image"}, false},
+		{"missing type", Part{Text: "hi"}, true},
+		{"patch without diff", Part{Type: PartTypePatch, Path: "main.go"}, true},
+		{"data not JSON", Part{Type: PartTypeData, Data: json.RawMessage(`{bad`)}, true},
+		{"error without message", Part{Type: PartTypeError, Error: &PartError{Code: "X"}}, true},
+		{"toolCall without name", Part{Type: PartTypeToolCall, ToolCall: &ToolCall{}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.part.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestReadResponse_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr error
+	}{
+		{"missing version", `{"id":1,"result":{"type":"text","content":"hi"}}`, ErrInvalidMessage},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"result":{"type":"text","content":"hi"}}`, ErrInvalidMessage},
+		{"notification missing version", `{"method":"agent/delta","params":{}}`, ErrInvalidMessage},
+		{"no id", `{"jsonrpc":"2.0","result":{"type":"text","content":"hi"}}`, ErrInvalidMessage},
+		{"result and error", `{"jsonrpc":"2.0","id":1,"result":null,"error":{"code":-32603,"message":"boom"}}`, ErrInvalidMessage},
+		{"neither result nor error", `{"jsonrpc":"2.0","id":1}`, ErrInvalidMessage},
+		{"method not found", `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"nope"}}`, ErrMethodNotFound},
+		{"parse error with null id", `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"bad json"}}`, ErrParse},
+		{"valid", `{"jsonrpc":"2.0","id":1,"result":{"type":"text","content":"hi"}}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{messages: newMessageDecoder(strings.NewReader(tt.raw), 0), logger: noOpLogger{}}
+			_, err := c.readResponse(1, nil)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestReadResponse_ErrorKeepsData(t *testing.T) {
+	raw := `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"bad params","data":{"field":"content","reason":"empty"}}}`
+	c := &Client{messages: newMessageDecoder(strings.NewReader(raw), 0), logger: noOpLogger{}}
+
+	_, err := c.readResponse(1, nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("expected *Error matching ErrInvalidParams, got %v", err)
+	}
+	var detail struct {
+		Field  string `json:"field"`
+		Reason string `json:"reason"`
+	}
+	if err := rpcErr.DecodeData(&detail); err != nil || detail.Field != "content" || detail.Reason != "empty" {
+		t.Errorf("expected structured data, got %+v (%v)", detail, err)
+	}
+	if errors.Is(err, ErrInternal) {
+		t.Error("expected error not to match other codes")
+	}
+}
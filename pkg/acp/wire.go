@@ -0,0 +1,104 @@
+package acp
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Wire frame directions
+const (
+	WireSent     = "send"
+	WireReceived = "recv"
+)
+
+// WireFrame is one JSON-RPC message exchanged with an agent
+type WireFrame struct {
+	Time      time.Time
+	PID       int    // The agent process, as reported by Client.PID
+	Direction string // WireSent or WireReceived
+	Message   string // The frame as sent or received, after redaction
+	// Latency is, for received frames, the time since the pending request
+	// was sent; zero for sent frames
+	Latency time.Duration
+}
+
+// WireLogger receives every frame of the clients it is passed to with
+// WithWireLogger
+// Implementations must be safe for concurrent use.
+type WireLogger interface {
+	LogFrame(frame WireFrame)
+}
+
+// LoggerWireLog prints frames to a Logger, one line each
+type LoggerWireLog struct {
+	logger Logger
+}
+
+// NewLoggerWireLog creates a wire log printing to logger
+func NewLoggerWireLog(logger Logger) *LoggerWireLog {
+	return &LoggerWireLog{logger: logger}
+}
+
+// LogFrame prints frame
+func (l *LoggerWireLog) LogFrame(frame WireFrame) {
+	if frame.Direction == WireReceived {
+		l.logger.Printf("[ACP wire] pid=%d %s +%v %s", frame.PID, frame.Direction, frame.Latency, frame.Message)
+		return
+	}
+	l.logger.Printf("[ACP wire] pid=%d %s %s", frame.PID, frame.Direction, frame.Message)
+}
+
+// JSONWireLog writes each frame as one JSON line, e.g. to a file kept apart
+// from the application log
+type JSONWireLog struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewJSONWireLog creates a wire log writing JSON lines to w
+func NewJSONWireLog(w io.Writer) *JSONWireLog {
+	return &JSONWireLog{w: w}
+}
+
+// jsonWireEntry is the JSON form of a WireFrame
+type jsonWireEntry struct {
+	Time      time.Time `json:"time"`
+	PID       int       `json:"pid"`
+	Direction string    `json:"direction"`
+	Message   string    `json:"message"`
+	LatencyMs float64   `json:"latencyMs,omitempty"`
+}
+
+// LogFrame writes frame; write errors are dropped so logging never fails a request
+func (l *JSONWireLog) LogFrame(frame WireFrame) {
+	data, err := json.Marshal(jsonWireEntry{
+		Time:      frame.Time,
+		PID:       frame.PID,
+		Direction: frame.Direction,
+		Message:   frame.Message,
+		LatencyMs: float64(frame.Latency.Microseconds()) / 1000,
+	})
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(append(data, '\n'))
+}
+
+// wireTap hands a client's frames to its WireLogger
+type wireTap struct {
+	logger WireLogger
+	redact func(string) string
+}
+
+// record logs one frame
+func (t *wireTap) record(pid int, direction string, data []byte, latency time.Duration) {
+	message := string(data)
+	if t.redact != nil {
+		message = t.redact(message)
+	}
+	t.logger.LogFrame(WireFrame{Time: time.Now(), PID: pid, Direction: direction, Message: message, Latency: latency})
+}
@@ -0,0 +1,65 @@
+package acp
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingWireLog keeps every frame it is given
+type recordingWireLog struct {
+	mu     sync.Mutex
+	frames []WireFrame
+}
+
+func (r *recordingWireLog) LogFrame(frame WireFrame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames = append(r.frames, frame)
+}
+
+func TestWireLogger_RecordsFrames(t *testing.T) {
+	client := newPipeClient(t, 2)
+	rec := &recordingWireLog{}
+	client.wire = &wireTap{logger: rec, redact: func(s string) string { return strings.ReplaceAll(s, "hunter2", "[REDACTED]") }}
+
+	if _, err := client.SendMessage("password hunter2"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	if len(rec.frames) != 4 {
+		t.Fatalf("expected request, 2 deltas and response, got %d frames", len(rec.frames))
+	}
+	want := []string{WireSent, WireReceived, WireReceived, WireReceived}
+	for i, frame := range rec.frames {
+		if frame.Direction != want[i] {
+			t.Errorf("frame %d: expected %s, got %s", i, want[i], frame.Direction)
+		}
+		if strings.Contains(frame.Message, "hunter2") || !strings.Contains(frame.Message, "[REDACTED]") {
+			t.Errorf("frame %d: expected redacted message, got %s", i, frame.Message)
+		}
+		if (frame.Direction == WireReceived) != (frame.Latency > 0) {
+			t.Errorf("frame %d: unexpected latency %v", i, frame.Latency)
+		}
+	}
+	if !strings.Contains(rec.frames[0].Message, MethodSendMessage) || !strings.Contains(rec.frames[3].Message, `"result"`) {
+		t.Errorf("unexpected frames: %+v", rec.frames)
+	}
+}
+
+func TestJSONWireLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewJSONWireLog(&buf)
+	log.LogFrame(WireFrame{Time: time.Unix(0, 0).UTC(), PID: 42, Direction: WireReceived, Message: `{"id":1}`, Latency: 1500 * time.Microsecond})
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON line, got %q: %v", buf.String(), err)
+	}
+	if entry["pid"] != 42.0 || entry["direction"] != "recv" || entry["message"] != `{"id":1}` || entry["latencyMs"] != 1.5 {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
@@ -0,0 +1,618 @@
+// Package client is a Go SDK for the relay WebSocket protocol
+// It wraps connecting, spawning agents, prompting them with streamed replies
+// and reattaching sessions after a dropped connection in typed methods, using
+// the message structs from pkg/relay.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/e2e"
+	"github.com/2389-research/ourocodus/pkg/relay"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// ErrClosed is returned once the client is closed or gave up reconnecting
+	ErrClosed = errors.New("client closed")
+
+	// ErrDisconnected is returned for requests whose connection dropped before
+	// the reply arrived; the request may or may not have been processed
+	ErrDisconnected = errors.New("relay connection lost")
+
+	// ErrCancelled is returned when the relay confirms a reply was cancelled
+	ErrCancelled = errors.New("agent reply cancelled")
+)
+
+// Error is an error reported by the relay
+type Error struct {
+	Code        string
+	Message     string
+	Recoverable bool
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// errorFromDetail converts a protocol error detail (nil stays nil)
+func errorFromDetail(d *relay.ErrorDetail) error {
+	if d == nil {
+		return nil
+	}
+	return &Error{Code: d.Code, Message: d.Message, Recoverable: d.Recoverable}
+}
+
+// Logger receives reconnection diagnostics
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Printf(format string, v ...interface{}) {}
+
+// Option configures optional Client behavior
+type Option func(*Client)
+
+// WithDialer sets the WebSocket dialer (defaults to websocket.DefaultDialer)
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(c *Client) {
+		c.dialer = dialer
+	}
+}
+
+// WithReconnect redials up to attempts times, doubling backoff after each
+// failure, when the connection drops; sessions this client spawned or resumed
+// are then reattached. Disabled by default.
+func WithReconnect(attempts int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.reconnectAttempts = attempts
+		c.reconnectBackoff = backoff
+	}
+}
+
+// WithStateHandler receives agent:state messages for this client's sessions
+// It runs on the read loop and must not block
+func WithStateHandler(fn func(relay.AgentStateMessage)) Option {
+	return func(c *Client) {
+		c.onState = fn
+	}
+}
+
+// WithLogger sets the logger for reconnection diagnostics
+func WithLogger(logger Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithPayloadKey encrypts prompts and replies end to end with a key from
+// e2e.NewKey (see pkg/e2e): agents this client spawns are given the key,
+// prompts are sealed before they are sent, and sealed deltas and reply text
+// are opened before they are returned
+// Sessions spawned by other clients must have been spawned with the same key.
+func WithPayloadKey(key []byte) Option {
+	return func(c *Client) {
+		c.payloadKey = key
+	}
+}
+
+// link is one WebSocket connection; lost is closed when it drops
+type link struct {
+	conn     *websocket.Conn
+	serverID string
+	lost     chan struct{}
+}
+
+// stream is an agent reply awaiting agent:complete
+type stream struct {
+	onDelta func(relay.AgentDeltaMessage)
+	done    chan streamResult
+}
+
+type streamResult struct {
+	complete relay.AgentCompleteMessage
+	err      error
+}
+
+// Client is a connection to a relay
+// Requests without correlation IDs (spawn, templates, reattach) are sent one
+// at a time since their replies can only be matched by order; prompts run
+// concurrently and are matched to replies by correlation ID.
+type Client struct {
+	url               string
+	dialer            *websocket.Dialer
+	logger            Logger
+	onState           func(relay.AgentStateMessage)
+	reconnectAttempts int
+	reconnectBackoff  time.Duration
+	payloadKey        []byte
+	box               *e2e.Box // Set with payloadKey
+
+	link     *link
+	control  chan []byte // Reply to the in-flight control request
+	pending  map[string]*stream
+	sessions map[string]struct{} // Reattached after reconnecting
+	stop     chan struct{}       // Closed by Close to abort reconnecting
+	done     chan struct{}
+	err      error
+	closed   bool
+	nextID   atomic.Int64
+
+	controlMu sync.Mutex
+	writeMu   sync.Mutex
+	mu        sync.Mutex
+}
+
+// Dial connects to the relay WebSocket endpoint at url and waits for its handshake
+func Dial(ctx context.Context, url string, opts ...Option) (*Client, error) {
+	c := &Client{
+		url:      url,
+		dialer:   websocket.DefaultDialer,
+		logger:   noopLogger{},
+		control:  make(chan []byte, 1),
+		pending:  make(map[string]*stream),
+		sessions: make(map[string]struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.payloadKey != nil {
+		box, err := e2e.New(c.payloadKey)
+		if err != nil {
+			return nil, err
+		}
+		c.box = box
+	}
+
+	l, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.link = l
+	go c.readLoop(l)
+	return c, nil
+}
+
+// connect dials the relay and completes the handshake
+func (c *Client) connect(ctx context.Context) (*link, error) {
+	conn, _, err := c.dialer.DialContext(ctx, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connect to relay: %w", err)
+	}
+	var hello relay.ConnectionEstablishedMessage
+	if err := conn.ReadJSON(&hello); err != nil || hello.Type != "connection:established" {
+		_ = conn.Close()
+		if err == nil {
+			err = fmt.Errorf("unexpected %q message", hello.Type)
+		}
+		return nil, fmt.Errorf("relay handshake failed: %w", err)
+	}
+	return &link{conn: conn, serverID: hello.ServerID, lost: make(chan struct{})}, nil
+}
+
+// ServerID returns the ID the relay announced in its handshake
+func (c *Client) ServerID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.link.serverID
+}
+
+// Done is closed when the client is closed or gives up reconnecting
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client stopped, or nil while it is running
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close disconnects from the relay; sessions stay detached on the relay and
+// can be resumed by another client
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	l := c.link
+	c.mu.Unlock()
+	close(c.stop)
+
+	c.writeMu.Lock()
+	_ = l.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	err := l.conn.Close()
+	<-c.done
+	return err
+}
+
+// Spawn starts an agent for role in workspace and returns its session
+// name is optional
+func (c *Client) Spawn(ctx context.Context, role, workspace, name string) (relay.AgentSpawnedMessage, error) {
+	var reply relay.AgentSpawnedMessage
+	msg := relay.AgentSpawnMessage{
+		BaseMessage: relay.BaseMessage{Version: relay.ProtocolVersion, Type: "agent:spawn"},
+		Role:        role,
+		Workspace:   workspace,
+		Name:        name,
+	}
+	if c.payloadKey != nil {
+		msg.PayloadKey = e2e.EncodeKey(c.payloadKey)
+	}
+	err := c.request(ctx, msg, "agent:spawned", &reply)
+	if err != nil {
+		return reply, err
+	}
+	c.track(reply.SessionID)
+	return reply, nil
+}
+
+// PlanSpawn runs the relay's pre-spawn checks without starting an agent
+func (c *Client) PlanSpawn(ctx context.Context, role, workspace string) (relay.AgentSpawnPlanMessage, error) {
+	var reply relay.AgentSpawnPlanMessage
+	err := c.request(ctx, relay.AgentSpawnMessage{
+		BaseMessage: relay.BaseMessage{Version: relay.ProtocolVersion, Type: "agent:spawn"},
+		Role:        role,
+		Workspace:   workspace,
+		DryRun:      true,
+	}, "agent:spawn_plan", &reply)
+	return reply, err
+}
+
+// CreateFromTemplate launches a template configured on the relay
+// Partial failures are reported per agent in the result, not as an error
+func (c *Client) CreateFromTemplate(ctx context.Context, template string) (relay.SessionTemplateResultMessage, error) {
+	var reply relay.SessionTemplateResultMessage
+	err := c.request(ctx, relay.SessionCreateFromTemplateMessage{
+		BaseMessage: relay.BaseMessage{Version: relay.ProtocolVersion, Type: "session:create_from_template"},
+		Template:    template,
+	}, "session:template_result", &reply)
+	if err != nil {
+		return reply, err
+	}
+	for _, agent := range reply.Agents {
+		if agent.SessionID != "" {
+			c.track(agent.SessionID)
+		}
+	}
+	return reply, nil
+}
+
+// Resume reattaches a detached session to this client, e.g. one spawned by a
+// client that has since disconnected
+func (c *Client) Resume(ctx context.Context, sessionID string) (relay.SessionReattachedMessage, error) {
+	var reply relay.SessionReattachedMessage
+	err := c.request(ctx, relay.SessionReattachMessage{
+		BaseMessage: relay.BaseMessage{Version: relay.ProtocolVersion, Type: "session:reattach"},
+		SessionID:   sessionID,
+	}, "session:reattached", &reply)
+	if err != nil {
+		return reply, err
+	}
+	c.track(sessionID)
+	return reply, nil
+}
+
+// Send prompts a session's agent and waits for the complete reply
+func (c *Client) Send(ctx context.Context, sessionID, content string) (relay.AgentCompleteMessage, error) {
+	return c.Stream(ctx, sessionID, content, nil)
+}
+
+// Stream prompts a session's agent, calling onDelta (if non-nil) for each
+// partial chunk on the read loop, and returns the final agent:complete
+// Cancelling ctx cancels the reply on the relay, and a ctx deadline is sent
+// as the request's timeout. A reply that failed on the relay is returned
+// along with its *Error.
+func (c *Client) Stream(ctx context.Context, sessionID, content string, onDelta func(relay.AgentDeltaMessage)) (relay.AgentCompleteMessage, error) {
+	if c.box != nil {
+		sealed, err := c.box.Seal(content)
+		if err != nil {
+			return relay.AgentCompleteMessage{}, err
+		}
+		content = sealed
+	}
+	correlationID := fmt.Sprintf("req-%d", c.nextID.Add(1))
+	s := &stream{onDelta: onDelta, done: make(chan streamResult, 1)}
+
+	c.mu.Lock()
+	l, err := c.current()
+	if err == nil {
+		c.pending[correlationID] = s
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return relay.AgentCompleteMessage{}, err
+	}
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, correlationID)
+		c.mu.Unlock()
+	}()
+
+	msg := relay.AgentSendMessage{
+		BaseMessage:   relay.BaseMessage{Version: relay.ProtocolVersion, Type: "agent:message"},
+		SessionID:     sessionID,
+		CorrelationID: correlationID,
+		Content:       content,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		// Relative, so clock skew between client and relay doesn't matter
+		msg.TimeoutMs = max(1, int(time.Until(deadline).Milliseconds()))
+	}
+	if err := c.write(l, msg); err != nil {
+		return relay.AgentCompleteMessage{}, err
+	}
+
+	select {
+	case res := <-s.done:
+		return res.complete, res.err
+	case <-l.lost:
+		return relay.AgentCompleteMessage{}, ErrDisconnected
+	case <-ctx.Done():
+		// Best effort: the reply is abandoned whether or not the relay sees this
+		_ = c.write(l, relay.AgentCancelMessage{
+			BaseMessage:   relay.BaseMessage{Version: relay.ProtocolVersion, Type: "agent:cancel"},
+			SessionID:     sessionID,
+			CorrelationID: correlationID,
+		})
+		return relay.AgentCompleteMessage{}, ctx.Err()
+	}
+}
+
+// request sends a control message and decodes the reply of type want into
+// reply; an error message from the relay is returned as *Error
+func (c *Client) request(ctx context.Context, msg interface{}, want string, reply interface{}) error {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+
+	c.mu.Lock()
+	l, err := c.current()
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	// Drop a reply left over from a request abandoned by its context
+	select {
+	case <-c.control:
+	default:
+	}
+	if err := c.write(l, msg); err != nil {
+		return err
+	}
+
+	select {
+	case data := <-c.control:
+		var base relay.BaseMessage
+		if err := json.Unmarshal(data, &base); err != nil {
+			return fmt.Errorf("decode %s reply: %w", want, err)
+		}
+		switch base.Type {
+		case want:
+			return json.Unmarshal(data, reply)
+		case "error":
+			var errMsg relay.ErrorMessage
+			if err := json.Unmarshal(data, &errMsg); err != nil {
+				return fmt.Errorf("decode error reply: %w", err)
+			}
+			return errorFromDetail(&errMsg.Error)
+		default:
+			return fmt.Errorf("expected %s reply, got %s", want, base.Type)
+		}
+	case <-l.lost:
+		return ErrDisconnected
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// current returns the live connection (must hold mu)
+func (c *Client) current() (*link, error) {
+	if c.closed || c.err != nil {
+		return nil, ErrClosed
+	}
+	return c.link, nil
+}
+
+// track remembers a session so it is reattached after reconnecting
+func (c *Client) track(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions[sessionID] = struct{}{}
+}
+
+// write sends one message; gorilla allows a single concurrent writer
+func (c *Client) write(l *link, v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := l.conn.WriteJSON(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrDisconnected, err)
+	}
+	return nil
+}
+
+// readLoop dispatches messages from one connection until it drops, then
+// reconnects or shuts the client down
+func (c *Client) readLoop(l *link) {
+	var readErr error
+	for {
+		_, data, err := l.conn.ReadMessage()
+		if err != nil {
+			readErr = err
+			break
+		}
+		c.dispatch(data)
+	}
+	close(l.lost)
+
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if !closed && c.reconnectAttempts > 0 {
+		if c.reconnect() {
+			return
+		}
+		readErr = fmt.Errorf("reconnect failed after %d attempts: %w", c.reconnectAttempts, readErr)
+	}
+
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = ErrClosed
+		if !c.closed {
+			c.err = fmt.Errorf("%w: %v", ErrDisconnected, readErr)
+		}
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// dispatch routes one message to its waiting caller
+func (c *Client) dispatch(data []byte) {
+	var base relay.BaseMessage
+	if json.Unmarshal(data, &base) != nil {
+		return
+	}
+
+	switch base.Type {
+	case "agent:delta":
+		var msg relay.AgentDeltaMessage
+		if json.Unmarshal(data, &msg) != nil {
+			return
+		}
+		if s := c.stream(msg.CorrelationID); s != nil && s.onDelta != nil {
+			msg.Content = c.open(msg.Content)
+			s.onDelta(msg)
+		}
+	case "agent:complete", "agent:cancelled":
+		var msg relay.AgentCompleteMessage
+		if json.Unmarshal(data, &msg) != nil {
+			return
+		}
+		for i, part := range msg.Parts {
+			msg.Parts[i].Text = c.open(part.Text)
+		}
+		res := streamResult{complete: msg, err: errorFromDetail(msg.Error)}
+		if base.Type == "agent:cancelled" {
+			res.err = ErrCancelled
+		}
+		if s := c.stream(msg.CorrelationID); s != nil {
+			select {
+			case s.done <- res:
+			default: // Already answered
+			}
+		}
+	case "agent:state":
+		var msg relay.AgentStateMessage
+		if json.Unmarshal(data, &msg) == nil && c.onState != nil {
+			c.onState(msg)
+		}
+	case "agent:spawned", "agent:spawn_plan", "session:template_result", "session:reattached", "error":
+		select {
+		case c.control <- data:
+		default: // Nobody is waiting; stray errors are dropped
+		}
+	}
+}
+
+// open decrypts sealed reply content with the payload key; anything else,
+// including content that fails to open, is returned as is and logged
+func (c *Client) open(content string) string {
+	if c.box == nil || !e2e.IsSealed(content) {
+		return content
+	}
+	plaintext, err := c.box.Open(content)
+	if err != nil {
+		c.logger.Printf("Failed to open agent reply: %v", err)
+		return content
+	}
+	return plaintext
+}
+
+// stream returns the pending reply for a correlation ID
+func (c *Client) stream(correlationID string) *stream {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pending[correlationID]
+}
+
+// reconnect redials with exponential backoff and reattaches tracked sessions
+// Returns false if every attempt failed or the client was closed meanwhile
+func (c *Client) reconnect() bool {
+	backoff := c.reconnectBackoff
+	for attempt := 1; attempt <= c.reconnectAttempts; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-c.stop:
+			return false
+		}
+		backoff *= 2
+
+		l, err := c.connect(context.Background())
+		if err != nil {
+			c.logger.Printf("Reconnect attempt %d failed: %v", attempt, err)
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			_ = l.conn.Close()
+			return false
+		}
+		c.link = l
+		sessions := make([]string, 0, len(c.sessions))
+		for id := range c.sessions {
+			sessions = append(sessions, id)
+		}
+		c.mu.Unlock()
+
+		go c.readLoop(l)
+		c.logger.Printf("Reconnected to relay after %d attempt(s)", attempt)
+		c.reattach(sessions)
+		return true
+	}
+	return false
+}
+
+// reattach resumes sessions after reconnecting; ones that cannot be resumed
+// are forgotten. The relay may not have noticed the old connection drop yet,
+// so SESSION_ATTACHED is retried with the reconnect backoff.
+func (c *Client) reattach(sessions []string) {
+	for _, id := range sessions {
+		var err error
+		for attempt := 1; attempt <= c.reconnectAttempts; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			_, err = c.Resume(ctx, id)
+			cancel()
+			var relayErr *Error
+			if !errors.As(err, &relayErr) || relayErr.Code != "SESSION_ATTACHED" {
+				break
+			}
+			select {
+			case <-time.After(c.reconnectBackoff):
+			case <-c.stop:
+				return
+			}
+		}
+		if err != nil {
+			c.logger.Printf("Failed to reattach session %s: %v", id, err)
+			c.mu.Lock()
+			delete(c.sessions, id)
+			c.mu.Unlock()
+		}
+	}
+}
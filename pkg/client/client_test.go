@@ -0,0 +1,300 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/e2e"
+	"github.com/2389-research/ourocodus/pkg/relay"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+type quietLogger struct{}
+
+func (quietLogger) Printf(format string, v ...interface{}) {}
+
+// streamingAgent echoes prompts in two chunks
+type streamingAgent struct{}
+
+func (a *streamingAgent) SendMessage(content string) (*acp.AgentMessage, error) {
+	return a.SendMessageStream(content, func(acp.Delta) {})
+}
+
+func (a *streamingAgent) SendMessageStream(content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	onDelta(acp.Delta{RequestID: 1, Seq: 1, Content: "Echo: "})
+	onDelta(acp.Delta{RequestID: 1, Seq: 2, Content: content})
+	return &acp.AgentMessage{Type: "text", Content: "Echo: " + content}, nil
+}
+
+func (a *streamingAgent) Close() error { return nil }
+
+type agentFactory struct{}
+
+func (agentFactory) NewAgent(ctx context.Context, role, workspace string) (session.ACPClient, error) {
+	return &streamingAgent{}, nil
+}
+
+// sealingAgent echoes sealed prompts sealed, recording what it was sent
+type sealingAgent struct {
+	box     *e2e.Box
+	prompts []string
+}
+
+func (a *sealingAgent) SendMessage(content string) (*acp.AgentMessage, error) {
+	return a.SendMessageStream(content, func(acp.Delta) {})
+}
+
+func (a *sealingAgent) SendMessageStream(content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	a.prompts = append(a.prompts, content)
+	prompt, err := a.box.Open(content)
+	if err != nil {
+		return nil, err
+	}
+	for i, chunk := range []string{"Echo: ", prompt} {
+		sealed, _ := a.box.Seal(chunk)
+		onDelta(acp.Delta{RequestID: 1, Seq: i + 1, Content: sealed})
+	}
+	sealed, _ := a.box.Seal("Echo: " + prompt)
+	return &acp.AgentMessage{Type: "text", Content: sealed}, nil
+}
+
+func (a *sealingAgent) Close() error { return nil }
+
+// sealingFactory starts sealingAgents with the payload key of their spawn
+type sealingFactory struct {
+	agents []*sealingAgent
+}
+
+func (f *sealingFactory) NewAgent(ctx context.Context, role, workspace string) (session.ACPClient, error) {
+	key, err := e2e.ParseKey(relay.PayloadKeyFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	box, err := e2e.New(key)
+	if err != nil {
+		return nil, err
+	}
+	agent := &sealingAgent{box: box}
+	f.agents = append(f.agents, agent)
+	return agent, nil
+}
+
+// startRelay serves a relay with a fake agent factory and returns its ws:// URL
+func startRelay(t *testing.T) string {
+	return startRelayWith(t, agentFactory{})
+}
+
+// startRelayWith serves a relay starting agents with factory
+func startRelayWith(t *testing.T, factory relay.AgentFactory) string {
+	t.Helper()
+	logger := quietLogger{}
+	clock := &relay.SystemClock{}
+	idGen, err := relay.NewIDGenerator("uuid", "")
+	if err != nil {
+		t.Fatalf("NewIDGenerator failed: %v", err)
+	}
+
+	manager := relay.NewSessionManager(logger, clock, idGen)
+	server := relay.NewServer(idGen, logger, clock,
+		relay.NewGorillaUpgrader(func(r *http.Request) bool { return true }),
+		relay.WithAgentStreamer(relay.NewAgentStreamer(manager, clock, logger)),
+		relay.WithSpawner(relay.NewSpawner(manager, factory, nil, logger)),
+	)
+	srv := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dial(t *testing.T, url string, opts ...Option) *Client {
+	t.Helper()
+	c, err := Dial(context.Background(), url, opts...)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestClient_SpawnAndStream(t *testing.T) {
+	var states []string
+	var mu sync.Mutex
+	c := dial(t, startRelay(t), WithStateHandler(func(msg relay.AgentStateMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, msg.To)
+	}))
+	ctx := context.Background()
+
+	if c.ServerID() == "" {
+		t.Error("expected the handshake server ID")
+	}
+
+	spawned, err := c.Spawn(ctx, "auth", t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	if spawned.Role != "auth" || spawned.SessionID == "" {
+		t.Errorf("unexpected spawn reply: %+v", spawned)
+	}
+
+	var deltas []string
+	complete, err := c.Stream(ctx, spawned.SessionID, "hi", func(d relay.AgentDeltaMessage) {
+		deltas = append(deltas, d.Content)
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if strings.Join(deltas, "") != "Echo: hi" || complete.Seq != 2 {
+		t.Errorf("unexpected stream: deltas=%q seq=%d", deltas, complete.Seq)
+	}
+	if len(complete.Parts) != 1 || complete.Parts[0].Text != "Echo: hi" {
+		t.Errorf("unexpected parts: %+v", complete.Parts)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(states) == 0 || states[len(states)-1] != "ACTIVE" {
+		t.Errorf("expected agent:state updates ending ACTIVE, got %v", states)
+	}
+}
+
+func TestClient_EncryptedPayloads(t *testing.T) {
+	factory := &sealingFactory{}
+	key, err := e2e.NewKey()
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	c := dial(t, startRelayWith(t, factory), WithPayloadKey(key))
+	ctx := context.Background()
+
+	spawned, err := c.Spawn(ctx, "auth", t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+	var deltas []string
+	complete, err := c.Stream(ctx, spawned.SessionID, "hi", func(d relay.AgentDeltaMessage) {
+		deltas = append(deltas, d.Content)
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if strings.Join(deltas, "") != "Echo: hi" || len(complete.Parts) != 1 || complete.Parts[0].Text != "Echo: hi" {
+		t.Errorf("expected opened replies, got deltas=%q parts=%+v", deltas, complete.Parts)
+	}
+	if len(factory.agents) != 1 || len(factory.agents[0].prompts) != 1 || !e2e.IsSealed(factory.agents[0].prompts[0]) {
+		t.Errorf("expected the relay to pass on a sealed prompt, got %+v", factory.agents)
+	}
+}
+
+func TestClient_RelayErrors(t *testing.T) {
+	c := dial(t, startRelay(t))
+	ctx := context.Background()
+
+	var relayErr *Error
+	if _, err := c.Send(ctx, "missing", "hi"); !errors.As(err, &relayErr) || relayErr.Code != "SESSION_NOT_FOUND" {
+		t.Errorf("expected SESSION_NOT_FOUND, got %v", err)
+	}
+	if _, err := c.CreateFromTemplate(ctx, "nope"); !errors.As(err, &relayErr) || relayErr.Code != "UNKNOWN_TEMPLATE" {
+		t.Errorf("expected UNKNOWN_TEMPLATE, got %v", err)
+	}
+	if _, err := c.Resume(ctx, "missing"); !errors.As(err, &relayErr) || relayErr.Code != "SESSION_NOT_FOUND" {
+		t.Errorf("expected SESSION_NOT_FOUND, got %v", err)
+	}
+}
+
+func TestClient_ResumeFromAnotherClient(t *testing.T) {
+	url := startRelay(t)
+	ctx := context.Background()
+
+	first := dial(t, url)
+	spawned, err := first.Spawn(ctx, "auth", t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+
+	second := dial(t, url)
+	var relayErr *Error
+	if _, err := second.Resume(ctx, spawned.SessionID); !errors.As(err, &relayErr) || relayErr.Code != "SESSION_ATTACHED" {
+		t.Fatalf("expected SESSION_ATTACHED while the owner is connected, got %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := first.Send(ctx, spawned.SessionID, "hi"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+
+	// The relay detaches the session once it sees the close
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err = second.Resume(ctx, spawned.SessionID)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if reply, err := second.Send(ctx, spawned.SessionID, "again"); err != nil || reply.Parts[0].Text != "Echo: again" {
+		t.Errorf("expected the resumed session to answer, got %+v %v", reply, err)
+	}
+}
+
+func TestClient_ReconnectReattachesSessions(t *testing.T) {
+	c := dial(t, startRelay(t), WithReconnect(5, 10*time.Millisecond))
+	ctx := context.Background()
+
+	spawned, err := c.Spawn(ctx, "auth", t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+
+	c.mu.Lock()
+	dropped := c.link
+	c.mu.Unlock()
+	_ = dropped.conn.Close()
+	<-dropped.lost
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err = c.Send(ctx, spawned.SessionID, "back")
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("expected the session to answer after reconnecting, got %v", err)
+	}
+	if c.Err() != nil {
+		t.Errorf("expected the client to keep running, got %v", c.Err())
+	}
+}
+
+func TestClient_GivesUpWithoutReconnect(t *testing.T) {
+	c := dial(t, startRelay(t))
+
+	c.mu.Lock()
+	_ = c.link.conn.Close()
+	c.mu.Unlock()
+
+	select {
+	case <-c.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the client to stop")
+	}
+	if !errors.Is(c.Err(), ErrDisconnected) {
+		t.Errorf("expected ErrDisconnected, got %v", c.Err())
+	}
+	if _, err := c.Send(context.Background(), "s", "hi"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
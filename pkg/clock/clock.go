@@ -0,0 +1,36 @@
+// Package clock provides the time source shared by the relay and session packages
+// Time is carried as time.Time internally and formatted only at serialization boundaries
+package clock
+
+import "time"
+
+// Clock abstracts time operations for deterministic testing
+type Clock interface {
+	// Now returns the wall-clock time, used for timestamps shown to people
+	Now() time.Time
+
+	// Monotonic returns elapsed time since an arbitrary process-local origin
+	// Use differences between readings for durations (idle time, latencies);
+	// unlike Now they are immune to wall-clock steps and NTP skew
+	Monotonic() time.Duration
+}
+
+// origin anchors System.Monotonic; time.Since uses the monotonic reading
+var origin = time.Now()
+
+// System reads the system clock in UTC
+type System struct{}
+
+// Now returns the current time in UTC
+// UTC strips the monotonic reading, so never subtract two Now values for durations
+func (System) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// Monotonic returns the monotonic time elapsed since process start
+func (System) Monotonic() time.Duration {
+	return time.Since(origin)
+}
+
+// Ensure System implements Clock
+var _ Clock = System{}
@@ -0,0 +1,201 @@
+// Package cron parses standard five-field cron expressions and computes when
+// they next fire
+// Fields are minute, hour, day of month, month and day of week, each "*", a
+// number, a range "a-b", a step "*/n" or "a-b/n", or a comma-separated list
+// of those. Day of week runs 0-6 from Sunday (7 is Sunday too). As in Vixie
+// cron, when both day fields are restricted a day matching either fires.
+// The descriptors @yearly, @monthly, @weekly, @daily and @hourly are
+// accepted; names (JAN, MON) and seconds are not.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchYears bounds how far Next looks for a matching time; expressions
+// such as "0 0 30 2 *" never match
+const searchYears = 5
+
+// descriptors are the @ shorthands and the expressions they stand for
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Schedule is a parsed cron expression
+// Safe for concurrent use.
+type Schedule struct {
+	expr   string
+	minute bits
+	hour   bits
+	dom    bits
+	month  bits
+	dow    bits
+	// Whether the day fields were "*"; a restricted field alone decides
+	domAny bool
+	dowAny bool
+}
+
+// bits is a set of field values
+type bits uint64
+
+func (b bits) has(v int) bool { return b&(1<<uint(v)) != 0 }
+
+// field describes the range of one cron field
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = [5]field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses a five-field cron expression or an @ descriptor
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron %q: want 5 fields (minute hour day month weekday), got %d", expr, len(parts))
+	}
+	var sets [5]bits
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+		sets[i] = set
+	}
+	// Sunday may be written 7
+	if sets[4].has(7) {
+		sets[4] |= 1
+	}
+	return &Schedule{
+		expr:   expr,
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+// parseField parses one comma-separated field (pure function)
+func parseField(s string, f field) (bits, error) {
+	var set bits
+	for _, term := range strings.Split(s, ",") {
+		lo, hi, step, err := parseTerm(term, f)
+		if err != nil {
+			return 0, err
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// parseTerm parses "*", "n", "a-b", each optionally followed by "/step"
+// (pure function)
+func parseTerm(term string, f field) (lo, hi, step int, err error) {
+	rangePart, stepPart, hasStep := strings.Cut(term, "/")
+	step = 1
+	if hasStep {
+		if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+			return 0, 0, 0, fmt.Errorf("%s: invalid step %q", f.name, stepPart)
+		}
+	}
+
+	switch from, to, isRange := strings.Cut(rangePart, "-"); {
+	case rangePart == "*":
+		lo, hi = f.min, f.max
+	case isRange:
+		if lo, err = parseValue(from, f); err != nil {
+			return 0, 0, 0, err
+		}
+		if hi, err = parseValue(to, f); err != nil {
+			return 0, 0, 0, err
+		}
+		if lo > hi {
+			return 0, 0, 0, fmt.Errorf("%s: range %q runs backwards", f.name, rangePart)
+		}
+	default:
+		if lo, err = parseValue(rangePart, f); err != nil {
+			return 0, 0, 0, err
+		}
+		hi = lo
+		if hasStep {
+			hi = f.max // "5/15" is "5-max/15"
+		}
+	}
+	return lo, hi, step, nil
+}
+
+// parseValue parses a number within the field's range (pure function)
+func parseValue(s string, f field) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: %q is not a number from %d to %d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first time after t the schedule fires, in t's location,
+// or the zero time if it never does
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(searchYears, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !s.month.has(int(m)):
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !s.hour.has(t.Hour()):
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case !s.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the day-of-month and day-of-week fields to t's date
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom.has(t.Day()), s.dow.has(int(t.Weekday()))
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
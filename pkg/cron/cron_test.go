@@ -0,0 +1,72 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * *", "want 5 fields"},
+		{"60 * * * *", "minute"},
+		{"* 24 * * *", "hour"},
+		{"* * 0 * *", "day of month"},
+		{"* * * 13 *", "month"},
+		{"* * * * 8", "day of week"},
+		{"*/0 * * * *", "invalid step"},
+		{"5-1 * * * *", "runs backwards"},
+		{"MON * * * *", "minute"},
+		{"@every 5m", "want 5 fields"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2026, time.January, 14, 10, 30, 45, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 14, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 14, 10, 45, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2026, 1, 14, 13, 0, 0, 0, time.UTC)},
+		{"0 3 * * 1-5", time.Date(2026, 1, 15, 3, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 14, 11, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: the 20th or any Friday
+		{"0 0 20 * 5", time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q.Next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestSchedule_Next_Never(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(time.Now()); !got.IsZero() {
+		t.Errorf("expected February 30th never to fire, got %v", got)
+	}
+}
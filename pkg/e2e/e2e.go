@@ -0,0 +1,132 @@
+// Package e2e seals agent prompts and replies end to end, between a client
+// and the agent process, so the relay in between forwards only ciphertext
+// Clients generate a key, hand it to the agent in the agent:spawn message's
+// payloadKey, and seal what they send with it; the relay puts the key in the
+// agent process's environment (EnvKey) without storing, logging or
+// journaling it, and the agent opens prompts and seals its replies and
+// streamed deltas. Everything the relay keeps (transcripts, history,
+// journals, archives) then holds sealed payloads only. The relay still
+// handles the key while it starts the agent: this keeps payloads from
+// operators reading the relay's data, not from one who changes its code.
+//
+// A sealed payload is the text "e2e1:" followed by the unpadded standard
+// base64 of a random 12-byte nonce and the AES-256-GCM ciphertext.
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EnvKey names the agent environment variable carrying the base64 key
+const EnvKey = "OUROCODUS_PAYLOAD_KEY"
+
+// KeySize is the length of payload keys (AES-256)
+const KeySize = 32
+
+// sealedPrefix starts every sealed payload
+const sealedPrefix = "e2e1:"
+
+var (
+	// ErrInvalidKey is returned for keys that are not KeySize bytes of base64
+	ErrInvalidKey = errors.New("invalid payload key")
+
+	// ErrOpen is returned for payloads that are not sealed, are corrupt or
+	// were sealed with another key
+	ErrOpen = errors.New("cannot open sealed payload")
+)
+
+// NewKey returns a random payload key
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncodeKey encodes a key as sent in agent:spawn and EnvKey (pure function)
+func EncodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// ParseKey decodes a key made by EncodeKey (pure function)
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("%w: must be %d bytes of base64", ErrInvalidKey, KeySize)
+	}
+	return key, nil
+}
+
+// IsSealed reports whether s looks like a sealed payload (pure function)
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, sealedPrefix)
+}
+
+// Box seals and opens payloads with one key; safe for concurrent use
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a box for a KeySize-byte key
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("%w: must be %d bytes, got %d", ErrInvalidKey, KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// FromEnv returns a box for the key in EnvKey, or nil if it is unset, e.g. in
+// an agent spawned without end-to-end encryption
+func FromEnv(getenv func(string) string) (*Box, error) {
+	encoded := getenv(EnvKey)
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := ParseKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", EnvKey, err)
+	}
+	return New(key)
+}
+
+// Seal encrypts plaintext under a fresh random nonce
+func (b *Box) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a payload made by Seal
+func (b *Box) Open(s string) (string, error) {
+	if !IsSealed(s) {
+		return "", fmt.Errorf("%w: not sealed", ErrOpen)
+	}
+	data, err := base64.RawStdEncoding.DecodeString(s[len(sealedPrefix):])
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", fmt.Errorf("%w: malformed", ErrOpen)
+	}
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: wrong key or corrupt", ErrOpen)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,77 @@
+package e2e
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestBox(t *testing.T) *Box {
+	t.Helper()
+	key, err := NewKey()
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	box, err := New(key)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return box
+}
+
+func TestBox_SealOpen(t *testing.T) {
+	box := newTestBox(t)
+	sealed, err := box.Seal("fix the login bug")
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !IsSealed(sealed) {
+		t.Errorf("expected %q to look sealed", sealed)
+	}
+	again, _ := box.Seal("fix the login bug")
+	if again == sealed {
+		t.Error("expected every seal to use a fresh nonce")
+	}
+	if plaintext, err := box.Open(sealed); err != nil || plaintext != "fix the login bug" {
+		t.Errorf("Open = %q, %v", plaintext, err)
+	}
+
+	other := newTestBox(t)
+	tampered := sealed[:len(sealed)-2] + "AA"
+	for name, s := range map[string]string{
+		"plaintext": "fix the login bug",
+		"malformed": sealedPrefix + "!!",
+		"tampered":  tampered,
+	} {
+		if _, err := box.Open(s); !errors.Is(err, ErrOpen) {
+			t.Errorf("%s: expected ErrOpen, got %v", name, err)
+		}
+	}
+	if _, err := other.Open(sealed); !errors.Is(err, ErrOpen) {
+		t.Errorf("expected another key to fail, got %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	key, _ := NewKey()
+	env := map[string]string{}
+	getenv := func(name string) string { return env[name] }
+
+	if box, err := FromEnv(getenv); box != nil || err != nil {
+		t.Errorf("expected no box without %s, got %v, %v", EnvKey, box, err)
+	}
+	env[EnvKey] = EncodeKey(key)
+	box, err := FromEnv(getenv)
+	if err != nil || box == nil {
+		t.Fatalf("FromEnv = %v, %v", box, err)
+	}
+	sealed, _ := box.Seal("hello")
+	direct, _ := New(key)
+	if plaintext, err := direct.Open(sealed); err != nil || plaintext != "hello" {
+		t.Errorf("expected the env key to match, got %q, %v", plaintext, err)
+	}
+
+	env[EnvKey] = EncodeKey(key[:16])
+	if _, err := FromEnv(getenv); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey for a short key, got %v", err)
+	}
+}
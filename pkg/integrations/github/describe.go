@@ -0,0 +1,83 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transcript limits applied by Describe
+const (
+	maxTranscriptTurns = 20
+	maxTurnLength      = 500
+	maxSummaryLength   = 2000
+	maxTitleLength     = 72
+)
+
+// Turn is one prompt or reply from an agent session's transcript
+type Turn struct {
+	Speaker string // "user" or "agent"
+	Content string
+}
+
+// Describe builds a pull request body from a transcript, oldest turn first
+// The last agent reply is the summary; the most recent turns follow in a
+// collapsed section so reviewers can see how the change came about (pure function)
+func Describe(turns []Turn) string {
+	var b strings.Builder
+
+	b.WriteString("## Summary\n\n")
+	summary := "_No agent reply recorded._"
+	for i := len(turns) - 1; i >= 0; i-- {
+		if turns[i].Speaker == "agent" && strings.TrimSpace(turns[i].Content) != "" {
+			summary = truncate(strings.TrimSpace(turns[i].Content), maxSummaryLength)
+			break
+		}
+	}
+	b.WriteString(summary)
+	b.WriteString("\n")
+
+	if len(turns) > 0 {
+		shown := turns
+		if len(shown) > maxTranscriptTurns {
+			shown = shown[len(shown)-maxTranscriptTurns:]
+		}
+		fmt.Fprintf(&b, "\n<details>\n<summary>Agent transcript (last %d of %d turns)</summary>\n\n", len(shown), len(turns))
+		for _, t := range shown {
+			content := truncate(strings.TrimSpace(t.Content), maxTurnLength)
+			fmt.Fprintf(&b, "**%s:**\n\n%s\n\n", t.Speaker, quote(content))
+		}
+		b.WriteString("</details>\n")
+	}
+
+	b.WriteString("\n_Opened by ourocodus from an agent worktree._\n")
+	return b.String()
+}
+
+// Title derives a pull request title from the first prompt of a transcript (pure function)
+// Returns fallback when there is no prompt
+func Title(turns []Turn, fallback string) string {
+	for _, t := range turns {
+		if t.Speaker != "user" {
+			continue
+		}
+		line, _, _ := strings.Cut(strings.TrimSpace(t.Content), "\n")
+		if line != "" {
+			return truncate(line, maxTitleLength)
+		}
+	}
+	return fallback
+}
+
+// truncate shortens s to at most n runes, marking the cut
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// quote renders text as a Markdown blockquote
+func quote(s string) string {
+	return "> " + strings.ReplaceAll(s, "\n", "\n> ")
+}
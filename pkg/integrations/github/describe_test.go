@@ -0,0 +1,52 @@
+package github
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	turns := []Turn{
+		{Speaker: "user", Content: "Add login endpoint\nwith JWT"},
+		{Speaker: "agent", Content: "Added POST /login"},
+		{Speaker: "user", Content: "add tests"},
+		{Speaker: "agent", Content: "Added tests for /login"},
+	}
+
+	body := Describe(turns)
+	if !strings.HasPrefix(body, "## Summary\n\nAdded tests for /login\n") {
+		t.Errorf("expected last agent reply as summary, got:\n%s", body)
+	}
+	if !strings.Contains(body, "last 4 of 4 turns") || !strings.Contains(body, "> Add login endpoint\n> with JWT") {
+		t.Errorf("expected quoted transcript, got:\n%s", body)
+	}
+}
+
+func TestDescribe_EmptyAndLongTranscripts(t *testing.T) {
+	if body := Describe(nil); !strings.Contains(body, "No agent reply recorded") || strings.Contains(body, "<details>") {
+		t.Errorf("unexpected body for empty transcript:\n%s", body)
+	}
+
+	var turns []Turn
+	for i := 0; i < 30; i++ {
+		turns = append(turns, Turn{Speaker: "user", Content: strings.Repeat("x", 1000)})
+	}
+	body := Describe(turns)
+	if !strings.Contains(body, "last 20 of 30 turns") || strings.Contains(body, strings.Repeat("x", 501)) {
+		t.Error("expected transcript to be capped in turns and length")
+	}
+}
+
+func TestTitle(t *testing.T) {
+	turns := []Turn{{Speaker: "agent", Content: "ready"}, {Speaker: "user", Content: "Fix the flaky test\nin CI"}}
+	if got := Title(turns, "fallback"); got != "Fix the flaky test" {
+		t.Errorf("expected first prompt line, got %q", got)
+	}
+	if got := Title(nil, "fallback"); got != "fallback" {
+		t.Errorf("expected fallback, got %q", got)
+	}
+	long := Title([]Turn{{Speaker: "user", Content: strings.Repeat("a", 100)}}, "")
+	if len([]rune(long)) != maxTitleLength {
+		t.Errorf("expected title truncated to %d runes, got %d", maxTitleLength, len([]rune(long)))
+	}
+}
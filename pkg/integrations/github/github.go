@@ -0,0 +1,232 @@
+// Package github pushes agent worktree branches and opens pull requests for
+// them through the GitHub REST API
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the public GitHub REST API
+const DefaultAPIURL = "https://api.github.com"
+
+// DefaultRemote is the git remote branches are pushed to
+const DefaultRemote = "origin"
+
+// ErrDetachedHead is returned when a worktree is not on a branch
+var ErrDetachedHead = errors.New("worktree is not on a branch")
+
+// APIError is a non-2xx response from the GitHub API
+type APIError struct {
+	Message    string `json:"message"`
+	StatusCode int    `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("github API: %d %s", e.StatusCode, e.Message)
+}
+
+// NewPullRequest is the body of a create pull request call
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Head  string `json:"head"` // Branch with the changes
+	Base  string `json:"base"` // Branch to merge into
+	Body  string `json:"body,omitempty"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// PullRequest is the subset of GitHub's pull request we report back
+type PullRequest struct {
+	URL    string `json:"html_url"`
+	Number int    `json:"number"`
+}
+
+// Client calls the GitHub REST API with a token
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBaseURL points the client at GitHub Enterprise or a test server
+func WithBaseURL(url string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client (30s timeout)
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// NewClient creates a client authenticating with token
+func NewClient(token string, opts ...Option) *Client {
+	c := &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    DefaultAPIURL,
+		token:      token,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CreatePullRequest opens a pull request in owner/repo
+func (c *Client) CreatePullRequest(ctx context.Context, owner, repo string, pr NewPullRequest) (*PullRequest, error) {
+	body, err := json.Marshal(pr)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls", c.baseURL, owner, repo)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("github API: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("github API: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return nil, apiErr
+	}
+
+	var created PullRequest
+	if err := json.Unmarshal(data, &created); err != nil {
+		return nil, fmt.Errorf("github API: invalid response: %w", err)
+	}
+	return &created, nil
+}
+
+// GitRunner runs git in a directory and returns its trimmed stdout
+type GitRunner interface {
+	Git(ctx context.Context, dir string, args ...string) (string, error)
+}
+
+// ExecGit runs the git binary on PATH
+type ExecGit struct{}
+
+// Git implements GitRunner
+func (ExecGit) Git(ctx context.Context, dir string, args ...string) (string, error) {
+	// #nosec G204 -- arguments are git subcommands built by this package
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// OpenRequest describes a pull request to open from a worktree
+// Owner and Repo default to the remote's GitHub URL
+type OpenRequest struct {
+	Dir   string // Worktree whose current branch is pushed
+	Owner string
+	Repo  string
+	Title string
+	Base  string
+	Body  string
+	Draft bool
+}
+
+// Opener pushes a worktree's branch and opens a pull request for it
+type Opener struct {
+	client *Client
+	git    GitRunner
+	remote string
+}
+
+// NewOpener creates an opener pushing to remote (DefaultRemote if empty)
+func NewOpener(client *Client, git GitRunner, remote string) *Opener {
+	if remote == "" {
+		remote = DefaultRemote
+	}
+	return &Opener{client: client, git: git, remote: remote}
+}
+
+// Open pushes the worktree's current branch and opens a pull request into req.Base
+// Returns the branch that was pushed along with the pull request
+func (o *Opener) Open(ctx context.Context, req OpenRequest) (*PullRequest, string, error) {
+	if req.Title == "" || req.Base == "" {
+		return nil, "", fmt.Errorf("title and base are required")
+	}
+
+	branch, err := o.git.Git(ctx, req.Dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil, "", err
+	}
+	if branch == "HEAD" {
+		return nil, "", ErrDetachedHead
+	}
+	if branch == req.Base {
+		return nil, "", fmt.Errorf("branch %s is the base branch", branch)
+	}
+
+	owner, repo := req.Owner, req.Repo
+	if owner == "" || repo == "" {
+		remoteURL, err := o.git.Git(ctx, req.Dir, "remote", "get-url", o.remote)
+		if err != nil {
+			return nil, "", err
+		}
+		if owner, repo, err = ParseRemote(remoteURL); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if _, err := o.git.Git(ctx, req.Dir, "push", "--set-upstream", o.remote, branch); err != nil {
+		return nil, "", err
+	}
+
+	pr, err := o.client.CreatePullRequest(ctx, owner, repo, NewPullRequest{
+		Title: req.Title,
+		Head:  branch,
+		Base:  req.Base,
+		Body:  req.Body,
+		Draft: req.Draft,
+	})
+	return pr, branch, err
+}
+
+// remotePattern matches https and ssh GitHub remotes
+var remotePattern = regexp.MustCompile(`^(?:https://[^/]+/|git@[^:]+:|ssh://git@[^/]+/)([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+// ParseRemote extracts owner and repo from a git remote URL (pure function)
+func ParseRemote(remoteURL string) (owner, repo string, err error) {
+	m := remotePattern.FindStringSubmatch(remoteURL)
+	if m == nil {
+		return "", "", fmt.Errorf("cannot parse owner/repo from remote %q", remoteURL)
+	}
+	return m[1], m[2], nil
+}
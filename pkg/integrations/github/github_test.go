@@ -0,0 +1,143 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// mockGit answers git commands from a table keyed by the joined arguments
+type mockGit struct {
+	outputs map[string]string
+	errs    map[string]error
+	calls   []string
+}
+
+func (m *mockGit) Git(ctx context.Context, dir string, args ...string) (string, error) {
+	key := strings.Join(args, " ")
+	m.calls = append(m.calls, key)
+	if err := m.errs[key]; err != nil {
+		return "", err
+	}
+	return m.outputs[key], nil
+}
+
+// newGitHubServer fakes the create pull request endpoint
+func newGitHubServer(t *testing.T, status int, reply string, got *NewPullRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/acme/widgets/pulls" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("missing token, got %q", r.Header.Get("Authorization"))
+		}
+		if got != nil {
+			_ = json.NewDecoder(r.Body).Decode(got)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(reply))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_CreatePullRequest(t *testing.T) {
+	var got NewPullRequest
+	srv := newGitHubServer(t, http.StatusCreated, `{"number": 42, "html_url": "https://github.com/acme/widgets/pull/42"}`, &got)
+	client := NewClient("tok", WithBaseURL(srv.URL+"/"))
+
+	pr, err := client.CreatePullRequest(context.Background(), "acme", "widgets",
+		NewPullRequest{Title: "Add auth", Head: "agent/auth", Base: "main"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest failed: %v", err)
+	}
+	if pr.Number != 42 || pr.URL != "https://github.com/acme/widgets/pull/42" {
+		t.Errorf("unexpected pull request: %+v", pr)
+	}
+	if got.Head != "agent/auth" || got.Base != "main" {
+		t.Errorf("unexpected request body: %+v", got)
+	}
+}
+
+func TestClient_CreatePullRequest_APIError(t *testing.T) {
+	srv := newGitHubServer(t, http.StatusUnprocessableEntity, `{"message": "Validation Failed"}`, nil)
+	client := NewClient("tok", WithBaseURL(srv.URL))
+
+	_, err := client.CreatePullRequest(context.Background(), "acme", "widgets", NewPullRequest{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 422 || apiErr.Message != "Validation Failed" {
+		t.Errorf("expected 422 APIError, got %v", err)
+	}
+}
+
+func TestOpener_Open(t *testing.T) {
+	var got NewPullRequest
+	srv := newGitHubServer(t, http.StatusCreated, `{"number": 7, "html_url": "u"}`, &got)
+	git := &mockGit{outputs: map[string]string{
+		"rev-parse --abbrev-ref HEAD": "agent/auth",
+		"remote get-url origin":       "git@github.com:acme/widgets.git",
+	}}
+	opener := NewOpener(NewClient("tok", WithBaseURL(srv.URL)), git, "")
+
+	pr, branch, err := opener.Open(context.Background(), OpenRequest{Dir: "/wt", Title: "Add auth", Base: "main", Body: "body"})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if pr.Number != 7 || branch != "agent/auth" || got.Head != "agent/auth" || got.Body != "body" {
+		t.Errorf("unexpected result: pr=%+v branch=%s request=%+v", pr, branch, got)
+	}
+	if git.calls[len(git.calls)-1] != "push --set-upstream origin agent/auth" {
+		t.Errorf("expected branch to be pushed, calls: %v", git.calls)
+	}
+}
+
+func TestOpener_OpenErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		git  *mockGit
+		err  string
+	}{
+		{"detached head", &mockGit{outputs: map[string]string{"rev-parse --abbrev-ref HEAD": "HEAD"}}, "not on a branch"},
+		{"on base branch", &mockGit{outputs: map[string]string{"rev-parse --abbrev-ref HEAD": "main"}}, "is the base branch"},
+		{"push rejected", &mockGit{
+			outputs: map[string]string{"rev-parse --abbrev-ref HEAD": "agent/auth"},
+			errs:    map[string]error{"push --set-upstream origin agent/auth": errors.New("git push: rejected")},
+		}, "rejected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opener := NewOpener(NewClient("tok", WithBaseURL("http://127.0.0.1:0")), tt.git, "origin")
+			_, _, err := opener.Open(context.Background(), OpenRequest{Dir: "/wt", Owner: "acme", Repo: "widgets", Title: "t", Base: "main"})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		url         string
+		owner, repo string
+	}{
+		{"https://github.com/acme/widgets.git", "acme", "widgets"},
+		{"https://github.com/acme/widgets", "acme", "widgets"},
+		{"git@github.com:acme/widgets.git", "acme", "widgets"},
+		{"ssh://git@github.example.com/acme/widgets.git", "acme", "widgets"},
+	}
+	for _, tt := range tests {
+		owner, repo, err := ParseRemote(tt.url)
+		if err != nil || owner != tt.owner || repo != tt.repo {
+			t.Errorf("%s: expected %s/%s, got %s/%s (%v)", tt.url, tt.owner, tt.repo, owner, repo, err)
+		}
+	}
+	if _, _, err := ParseRemote("/srv/git/widgets"); err == nil {
+		t.Error("expected error for a local path remote")
+	}
+}
@@ -0,0 +1,117 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSessionGone is returned by Relay.Send when the session no longer exists
+// The bridge then spawns a fresh agent on the channel's next message
+var ErrSessionGone = errors.New("agent session no longer exists")
+
+// Relay is the client side of the relay WebSocket protocol used by the bridge
+type Relay interface {
+	// Spawn starts an agent and returns its session ID
+	Spawn(ctx context.Context, role, workspace string) (string, error)
+	// Send prompts a session's agent and returns its reply text
+	Send(ctx context.Context, sessionID, content string) (string, error)
+}
+
+// Poster posts replies to Slack
+type Poster interface {
+	PostMessage(ctx context.Context, channel, threadTS, text string) error
+}
+
+// Route is the agent a Slack channel talks to
+type Route struct {
+	Role      string `json:"role"`
+	Workspace string `json:"workspace"`
+}
+
+// Logger is the logging interface used by the bridge
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Bridge relays messages from Slack channels to agents and posts the replies
+// back in the message's thread. Each channel maps to one agent session, spawned
+// on the first message; the relay allows a single live session per role.
+type Bridge struct {
+	relay    Relay
+	poster   Poster
+	logger   Logger
+	routes   map[string]Route  // Channel ID → agent
+	sessions map[string]string // Channel ID → session ID
+	mu       sync.Mutex        // Guards sessions and serializes spawns
+}
+
+// NewBridge creates a bridge for the channels in routes
+func NewBridge(relay Relay, poster Poster, routes map[string]Route, logger Logger) *Bridge {
+	return &Bridge{
+		relay:    relay,
+		poster:   poster,
+		logger:   logger,
+		routes:   routes,
+		sessions: make(map[string]string),
+	}
+}
+
+// HandleMessage relays one Slack message and posts the agent's reply
+// Messages in unmapped channels are ignored; failures are posted to the thread
+func (b *Bridge) HandleMessage(ctx context.Context, event MessageEvent) {
+	route, ok := b.routes[event.Channel]
+	if !ok {
+		return
+	}
+
+	reply, err := b.send(ctx, event.Channel, route, event.Text)
+	if err != nil {
+		b.logger.Printf("Slack bridge: channel=%s err=%v", event.Channel, err)
+		reply = fmt.Sprintf(":warning: %s agent failed: %v", route.Role, err)
+	}
+	if reply == "" {
+		reply = "_(no reply)_"
+	}
+	if err := b.poster.PostMessage(ctx, event.Channel, event.Thread(), reply); err != nil {
+		b.logger.Printf("Slack bridge: post failed: channel=%s err=%v", event.Channel, err)
+	}
+}
+
+// send prompts the channel's agent, spawning it on first use
+func (b *Bridge) send(ctx context.Context, channel string, route Route, text string) (string, error) {
+	sessionID, err := b.session(ctx, channel, route)
+	if err != nil {
+		return "", err
+	}
+	reply, err := b.relay.Send(ctx, sessionID, text)
+	if errors.Is(err, ErrSessionGone) {
+		b.forget(channel, sessionID)
+	}
+	return reply, err
+}
+
+// session returns the channel's session ID, spawning an agent if needed
+func (b *Bridge) session(ctx context.Context, channel string, route Route) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if id, ok := b.sessions[channel]; ok {
+		return id, nil
+	}
+	id, err := b.relay.Spawn(ctx, route.Role, route.Workspace)
+	if err != nil {
+		return "", fmt.Errorf("spawn %s: %w", route.Role, err)
+	}
+	b.sessions[channel] = id
+	return id, nil
+}
+
+// forget drops a channel's session if it is still the one that failed
+func (b *Bridge) forget(channel, sessionID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sessions[channel] == sessionID {
+		delete(b.sessions, channel)
+	}
+}
@@ -0,0 +1,93 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type mockRelay struct {
+	sendErrs []error // Consumed per Send
+	spawns   int
+	prompts  []string
+}
+
+func (m *mockRelay) Spawn(ctx context.Context, role, workspace string) (string, error) {
+	m.spawns++
+	return fmt.Sprintf("session-%d", m.spawns), nil
+}
+
+func (m *mockRelay) Send(ctx context.Context, sessionID, content string) (string, error) {
+	m.prompts = append(m.prompts, sessionID+":"+content)
+	if len(m.sendErrs) > 0 {
+		err := m.sendErrs[0]
+		m.sendErrs = m.sendErrs[1:]
+		if err != nil {
+			return "", err
+		}
+	}
+	return "echo: " + content, nil
+}
+
+type post struct{ channel, thread, text string }
+
+type mockPoster struct {
+	posts []post
+}
+
+func (m *mockPoster) PostMessage(ctx context.Context, channel, threadTS, text string) error {
+	m.posts = append(m.posts, post{channel, threadTS, text})
+	return nil
+}
+
+type mockLogger struct{}
+
+func (mockLogger) Printf(format string, v ...interface{}) {}
+
+func newTestBridge(relay *mockRelay) (*Bridge, *mockPoster) {
+	poster := &mockPoster{}
+	routes := map[string]Route{"C1": {Role: "auth", Workspace: "/repo"}}
+	return NewBridge(relay, poster, routes, mockLogger{}), poster
+}
+
+func TestBridge_RelaysToThread(t *testing.T) {
+	relay := &mockRelay{}
+	bridge, poster := newTestBridge(relay)
+	ctx := context.Background()
+
+	bridge.HandleMessage(ctx, MessageEvent{Channel: "C1", Text: "hello", TS: "1.1"})
+	bridge.HandleMessage(ctx, MessageEvent{Channel: "C1", Text: "again", TS: "1.2", ThreadTS: "1.1"})
+	bridge.HandleMessage(ctx, MessageEvent{Channel: "C2", Text: "unmapped", TS: "2.1"})
+
+	if relay.spawns != 1 {
+		t.Errorf("expected one agent per channel, got %d spawns", relay.spawns)
+	}
+	if len(poster.posts) != 2 {
+		t.Fatalf("expected 2 replies, got %+v", poster.posts)
+	}
+	if poster.posts[0] != (post{"C1", "1.1", "echo: hello"}) || poster.posts[1].thread != "1.1" {
+		t.Errorf("expected replies in the message thread, got %+v", poster.posts)
+	}
+}
+
+func TestBridge_Failures(t *testing.T) {
+	relay := &mockRelay{sendErrs: []error{
+		errors.New("AGENT_REQUEST_FAILED: timeout"),
+		fmt.Errorf("%w: SESSION_NOT_FOUND", ErrSessionGone),
+	}}
+	bridge, poster := newTestBridge(relay)
+	ctx := context.Background()
+
+	bridge.HandleMessage(ctx, MessageEvent{Channel: "C1", Text: "one", TS: "1"})
+	bridge.HandleMessage(ctx, MessageEvent{Channel: "C1", Text: "two", TS: "2"})
+	bridge.HandleMessage(ctx, MessageEvent{Channel: "C1", Text: "three", TS: "3"})
+
+	if !strings.Contains(poster.posts[0].text, "auth agent failed") {
+		t.Errorf("expected failure posted to the thread, got %q", poster.posts[0].text)
+	}
+	if relay.spawns != 2 || relay.prompts[2] != "session-2:three" {
+		t.Errorf("expected a respawn only after the session was gone, got %d spawns, prompts %v", relay.spawns, relay.prompts)
+	}
+}
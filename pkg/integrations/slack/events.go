@@ -0,0 +1,91 @@
+package slack
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxEventBytes bounds Events API request bodies
+const maxEventBytes = 1 << 20
+
+// MessageEvent is a user message posted in a channel the bot is in
+type MessageEvent struct {
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"` // Set when the message is a thread reply
+	BotID    string `json:"bot_id"`
+	Subtype  string `json:"subtype"`
+	Type     string `json:"type"`
+}
+
+// Thread returns the timestamp of the thread the message belongs to
+// A top-level message starts its own thread
+func (e MessageEvent) Thread() string {
+	if e.ThreadTS != "" {
+		return e.ThreadTS
+	}
+	return e.TS
+}
+
+// envelope is the outer Events API payload
+type envelope struct {
+	Type      string          `json:"type"` // "url_verification" or "event_callback"
+	Challenge string          `json:"challenge"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// EventHandler serves the Events API request URL
+// Verified user messages are passed to onMessage on their own goroutine, since
+// Slack retries requests not acknowledged within three seconds. Bot messages
+// and edits (any subtype) are ignored so the bridge never answers itself.
+type EventHandler struct {
+	onMessage     func(MessageEvent)
+	now           func() time.Time
+	signingSecret string
+}
+
+// NewEventHandler creates a handler verifying requests with signingSecret
+func NewEventHandler(signingSecret string, onMessage func(MessageEvent)) *EventHandler {
+	return &EventHandler{onMessage: onMessage, now: time.Now, signingSecret: signingSecret}
+}
+
+// ServeHTTP implements http.Handler
+func (h *EventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEventBytes))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := Verify(h.signingSecret, r.Header, body, h.now()); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch env.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(env.Challenge))
+		return
+	case "event_callback":
+		var event MessageEvent
+		if json.Unmarshal(env.Event, &event) == nil && event.Type == "message" &&
+			event.Subtype == "" && event.BotID == "" && event.Text != "" {
+			go h.onMessage(event)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,109 @@
+// Package slack bridges Slack channels to relay agent sessions: it receives
+// messages through the Slack Events API and posts agent replies back to threads
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultAPIURL is the Slack Web API
+const DefaultAPIURL = "https://slack.com/api"
+
+// maxRequestAge rejects replayed Events API requests
+const maxRequestAge = 5 * time.Minute
+
+// ErrBadSignature is returned when a request was not signed by Slack
+var ErrBadSignature = errors.New("invalid Slack request signature")
+
+// Verify checks the v0 signature Slack sends with every Events API request (pure function)
+// now is compared against the request timestamp to reject replays
+func Verify(signingSecret string, header http.Header, body []byte, now time.Time) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing timestamp", ErrBadSignature)
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > maxRequestAge || age < -maxRequestAge {
+		return fmt.Errorf("%w: stale timestamp", ErrBadSignature)
+	}
+
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(header.Get("X-Slack-Signature"))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Client posts messages with a bot token
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// NewClient creates a client for the Web API at baseURL (DefaultAPIURL if empty)
+func NewClient(token, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    baseURL,
+		token:      token,
+	}
+}
+
+// PostMessage posts text to a channel, as a thread reply when threadTS is set
+func (c *Client) PostMessage(ctx context.Context, channel, threadTS, text string) error {
+	body, err := json.Marshal(map[string]string{
+		"channel":   channel,
+		"thread_ts": threadTS,
+		"text":      text,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat.postMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack API: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// The Web API reports failures in the body with a 200 status
+	var result struct {
+		Error string `json:"error"`
+		OK    bool   `json:"ok"`
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("slack API: %w", err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("slack API: status %d: invalid response", resp.StatusCode)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack API: chat.postMessage: %s", result.Error)
+	}
+	return nil
+}
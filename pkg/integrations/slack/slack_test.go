@@ -0,0 +1,130 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)
+
+// signedRequest builds an Events API request signed like Slack does
+func signedRequest(secret, body string, at time.Time) *http.Request {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+	req := httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	good := signedRequest("secret", `{}`, testNow)
+	if err := Verify("secret", good.Header, []byte(`{}`), testNow); err != nil {
+		t.Errorf("expected valid signature, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		now    time.Time
+	}{
+		{"wrong secret", "other", `{}`, testNow},
+		{"tampered body", "secret", `{"x":1}`, testNow},
+		{"replayed", "secret", `{}`, testNow.Add(10 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, good.Header, []byte(tt.body), tt.now); !errors.Is(err, ErrBadSignature) {
+				t.Errorf("expected ErrBadSignature, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEventHandler(t *testing.T) {
+	got := make(chan MessageEvent, 1)
+	h := NewEventHandler("secret", func(e MessageEvent) { got <- e })
+	h.now = func() time.Time { return testNow }
+
+	t.Run("url verification", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, signedRequest("secret", `{"type":"url_verification","challenge":"abc"}`, testNow))
+		if rec.Code != http.StatusOK || rec.Body.String() != "abc" {
+			t.Errorf("expected challenge echo, got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("unsigned request", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(`{}`)))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", rec.Code)
+		}
+	})
+
+	t.Run("user message is dispatched", func(t *testing.T) {
+		body := `{"type":"event_callback","event":{"type":"message","channel":"C1","user":"U1","text":"hi","ts":"1.1"}}`
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, signedRequest("secret", body, testNow))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		select {
+		case e := <-got:
+			if e.Channel != "C1" || e.Text != "hi" || e.Thread() != "1.1" {
+				t.Errorf("unexpected event: %+v", e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("message was not dispatched")
+		}
+	})
+
+	t.Run("bot message is ignored", func(t *testing.T) {
+		body := `{"type":"event_callback","event":{"type":"message","channel":"C1","bot_id":"B1","text":"reply","ts":"1.2"}}`
+		h.ServeHTTP(httptest.NewRecorder(), signedRequest("secret", body, testNow))
+		select {
+		case e := <-got:
+			t.Errorf("expected bot message to be ignored, got %+v", e)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func TestClient_PostMessage(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" || r.Header.Get("Authorization") != "Bearer xoxb" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if got["channel"] == "C404" {
+			_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+	client := NewClient("xoxb", srv.URL)
+
+	if err := client.PostMessage(context.Background(), "C1", "1.1", "done"); err != nil {
+		t.Fatalf("PostMessage failed: %v", err)
+	}
+	if got["thread_ts"] != "1.1" || got["text"] != "done" {
+		t.Errorf("unexpected body: %v", got)
+	}
+	if err := client.PostMessage(context.Background(), "C404", "", "x"); err == nil || !strings.Contains(err.Error(), "channel_not_found") {
+		t.Errorf("expected channel_not_found, got %v", err)
+	}
+}
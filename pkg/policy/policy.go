@@ -0,0 +1,302 @@
+// Package policy runs operator-supplied WebAssembly modules that decide what
+// happens to the protocol messages the relay receives and the agent output it
+// sends: let them through, deny them, or replace them
+// Modules run in wazero with WASI but no filesystem, environment or network,
+// under a per-call timeout and a memory cap.
+//
+// A module exports its linear memory as "memory" and two functions:
+//
+//	policy_alloc(size i32) -> i32            // Reserves size bytes for the input
+//	policy_evaluate(ptr i32, len i32) -> i64 // Decides on the Input JSON at ptr
+//
+// policy_evaluate returns 0 to allow the message unchanged, or the location
+// of a Decision's JSON packed as ptr<<32 | len. Modules may also export
+// policy_free(ptr i32, len i32), called for the input and the decision once
+// read. A reactor's _initialize runs when the module is instantiated.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Defaults used by New
+const (
+	DefaultTimeout   = 100 * time.Millisecond
+	DefaultMaxMemory = 16 << 20
+)
+
+// Directions of the messages a Hook evaluates
+const (
+	Inbound  = "inbound"  // Sent by a client to the relay
+	Outbound = "outbound" // Agent output sent by the relay to a client
+)
+
+// Actions a Decision takes
+const (
+	Allow   = "allow"
+	Deny    = "deny"
+	Replace = "replace"
+)
+
+// Exports a module must provide
+const (
+	exportMemory   = "memory"
+	exportAlloc    = "policy_alloc"
+	exportEvaluate = "policy_evaluate"
+	exportFree     = "policy_free"
+)
+
+var (
+	// ErrInvalidModule is returned for modules missing the policy exports
+	ErrInvalidModule = errors.New("invalid policy module")
+
+	// ErrInvalidDecision is returned when a module's decision cannot be used
+	ErrInvalidDecision = errors.New("invalid policy decision")
+)
+
+// Input is what a module decides on
+type Input struct {
+	Direction string          `json:"direction"` // Inbound or Outbound
+	Message   json.RawMessage `json:"message"`   // The protocol message as sent
+}
+
+// Decision is a module's verdict on an Input
+type Decision struct {
+	Action  string          `json:"action"`            // Allow, Deny or Replace; empty allows
+	Message json.RawMessage `json:"message,omitempty"` // Replace: the message sent on instead
+	Reason  string          `json:"reason,omitempty"`  // Deny: why, for the client and the log
+}
+
+// validate checks a decided action can be carried out (pure function)
+func (d *Decision) validate() error {
+	switch d.Action {
+	case "", Allow, Deny:
+		return nil
+	case Replace:
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal(d.Message, &msg); err != nil {
+			return fmt.Errorf("%w: replacement is not a JSON object", ErrInvalidDecision)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidDecision, d.Action)
+	}
+}
+
+// Hook evaluates messages with a policy module
+// Calls are serialized on one module instance, so a module may keep state
+// between them; an instance that traps or times out is discarded and the next
+// call starts a fresh one. Safe for concurrent use.
+type Hook struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	instance api.Module // nil until the next call instantiates one
+}
+
+// Option configures a Hook
+type Option func(*options)
+
+type options struct {
+	timeout   time.Duration
+	maxMemory int
+}
+
+// WithTimeout bounds each evaluation; modules still running are stopped
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.timeout = d
+		}
+	}
+}
+
+// WithMaxMemory caps a module's linear memory at n bytes, rounded down to
+// whole 64KiB pages
+func WithMaxMemory(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxMemory = n
+		}
+	}
+}
+
+// Load compiles the policy module at path
+func Load(ctx context.Context, path string, opts ...Option) (*Hook, error) {
+	wasm, err := os.ReadFile(path) // #nosec G304 -- path comes from the operator's config
+	if err != nil {
+		return nil, err
+	}
+	return New(ctx, wasm, opts...)
+}
+
+// New compiles a policy module, checking it has the policy exports
+func New(ctx context.Context, wasm []byte, opts ...Option) (*Hook, error) {
+	o := options{timeout: DefaultTimeout, maxMemory: DefaultMaxMemory}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	pages := uint32(o.maxMemory / 65536)
+	if pages == 0 {
+		pages = 1
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(pages))
+	fail := func(err error) (*Hook, error) {
+		_ = runtime.Close(ctx)
+		return nil, err
+	}
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		return fail(err)
+	}
+	compiled, err := runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		return fail(fmt.Errorf("%w: %v", ErrInvalidModule, err))
+	}
+	if err := checkExports(compiled); err != nil {
+		return fail(err)
+	}
+	return &Hook{runtime: runtime, compiled: compiled, timeout: o.timeout}, nil
+}
+
+// checkExports verifies a module exports the policy ABI
+func checkExports(compiled wazero.CompiledModule) error {
+	if _, ok := compiled.ExportedMemories()[exportMemory]; !ok {
+		return fmt.Errorf("%w: no %q export", ErrInvalidModule, exportMemory)
+	}
+	i32, i64 := api.ValueTypeI32, api.ValueTypeI64
+	want := map[string][2][]api.ValueType{
+		exportAlloc:    {{i32}, {i32}},
+		exportEvaluate: {{i32, i32}, {i64}},
+	}
+	functions := compiled.ExportedFunctions()
+	if _, ok := functions[exportFree]; ok {
+		want[exportFree] = [2][]api.ValueType{{i32, i32}, nil}
+	}
+	for name, sig := range want {
+		fn, ok := functions[name]
+		if !ok {
+			return fmt.Errorf("%w: no %q export", ErrInvalidModule, name)
+		}
+		if !sameTypes(fn.ParamTypes(), sig[0]) || !sameTypes(fn.ResultTypes(), sig[1]) {
+			return fmt.Errorf("%w: %q has the wrong signature", ErrInvalidModule, name)
+		}
+	}
+	return nil
+}
+
+// sameTypes compares value type lists (pure function)
+func sameTypes(a, b []api.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate asks the module what to do with a message
+// Errors mean the module could not decide, e.g. it trapped, ran out of time
+// or returned an unusable decision; callers choose whether to fail open.
+func (h *Hook) Evaluate(ctx context.Context, in Input) (Decision, error) {
+	input, err := json.Marshal(in)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	if h.instance == nil {
+		instance, err := h.runtime.InstantiateModule(ctx, h.compiled, wazero.NewModuleConfig().
+			WithName("").
+			WithStartFunctions("_initialize"))
+		if err != nil {
+			return Decision{}, fmt.Errorf("instantiate policy module: %w", err)
+		}
+		h.instance = instance
+	}
+
+	output, err := call(ctx, h.instance, input)
+	if err != nil {
+		_ = h.instance.Close(context.Background()) // Its state is unknown after a trap
+		h.instance = nil
+		return Decision{}, err
+	}
+	var decision Decision
+	if output != nil {
+		if err := json.Unmarshal(output, &decision); err != nil {
+			return Decision{}, fmt.Errorf("%w: %v", ErrInvalidDecision, err)
+		}
+		if err := decision.validate(); err != nil {
+			return Decision{}, err
+		}
+	}
+	if decision.Action == "" {
+		decision.Action = Allow
+	}
+	return decision, nil
+}
+
+// call runs policy_evaluate on input, returning a copy of the decision JSON,
+// or nil if the module allowed the message without one
+func call(ctx context.Context, mod api.Module, input []byte) ([]byte, error) {
+	free := mod.ExportedFunction(exportFree)
+	results, err := mod.ExportedFunction(exportAlloc).Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", exportAlloc, err)
+	}
+	ptr := uint32(results[0])
+	if !mod.Memory().Write(ptr, input) {
+		return nil, fmt.Errorf("%s: %w: buffer out of range", exportAlloc, ErrInvalidModule)
+	}
+
+	results, err = mod.ExportedFunction(exportEvaluate).Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", exportEvaluate, err)
+	}
+	if free != nil {
+		if _, err := free.Call(ctx, uint64(ptr), uint64(len(input))); err != nil {
+			return nil, fmt.Errorf("%s: %w", exportFree, err)
+		}
+	}
+	if results[0] == 0 {
+		return nil, nil
+	}
+
+	outPtr, outLen := uint32(results[0]>>32), uint32(results[0])
+	view, ok := mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("%w: decision out of range", ErrInvalidDecision)
+	}
+	output := append([]byte(nil), view...) // The view aliases module memory
+	if free != nil {
+		if _, err := free.Call(ctx, uint64(outPtr), uint64(outLen)); err != nil {
+			return nil, fmt.Errorf("%s: %w", exportFree, err)
+		}
+	}
+	return output, nil
+}
+
+// Close releases the module and its runtime
+func (h *Hook) Close(ctx context.Context) error {
+	return h.runtime.Close(ctx)
+}
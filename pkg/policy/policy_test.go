@@ -0,0 +1,186 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// decisionOffset is where testModule places its decision in memory
+const decisionOffset = 16
+
+// Function bodies for testModule's policy_evaluate
+var (
+	allowBody = []byte{0x42, 0x00, 0x0b}                               // i64.const 0
+	loopBody  = []byte{0x03, 0x40, 0x0c, 0x00, 0x0b, 0x42, 0x00, 0x0b} // loop br 0 end; i64.const 0
+	trapBody  = []byte{0x00, 0x0b}                                     // unreachable
+)
+
+// returnDecision is a policy_evaluate body returning the decision testModule
+// placed in memory
+func returnDecision(decision string) []byte {
+	body := append([]byte{0x42}, sleb128(int64(decisionOffset)<<32|int64(len(decision)))...)
+	return append(body, 0x0b)
+}
+
+// testModule assembles a policy module whose policy_alloc always returns
+// offset 1024 and whose policy_evaluate runs evaluate, with decision stored
+// at decisionOffset
+func testModule(evaluate []byte, decision string) []byte {
+	i32, i64 := byte(0x7f), byte(0x7e)
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, section(1, vec( // Types
+		[]byte{0x60, 0x01, i32, 0x01, i32},
+		[]byte{0x60, 0x02, i32, i32, 0x01, i64},
+	))...)
+	module = append(module, section(3, vec([]byte{0x00}, []byte{0x01}))...) // Functions
+	module = append(module, section(5, vec([]byte{0x00, 0x01}))...)         // Memory of one page
+	module = append(module, section(7, vec(                                 // Exports
+		append(name("memory"), 0x02, 0x00),
+		append(name("policy_alloc"), 0x00, 0x00),
+		append(name("policy_evaluate"), 0x00, 0x01),
+	))...)
+	module = append(module, section(10, vec( // Code
+		body([]byte{0x41, 0x80, 0x08, 0x0b}), // i32.const 1024
+		body(evaluate),
+	))...)
+	data := append([]byte{0x00, 0x41, decisionOffset, 0x0b}, name(decision)...)
+	return append(module, section(11, vec(data))...)
+}
+
+func section(id byte, contents []byte) []byte {
+	return append(append([]byte{id}, uleb128(uint64(len(contents)))...), contents...)
+}
+
+func vec(items ...[]byte) []byte {
+	out := uleb128(uint64(len(items)))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func name(s string) []byte {
+	return append(uleb128(uint64(len(s))), s...)
+}
+
+func body(code []byte) []byte {
+	code = append([]byte{0x00}, code...) // No locals
+	return append(uleb128(uint64(len(code))), code...)
+}
+
+func uleb128(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		if v >>= 7; v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+func sleb128(v int64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func newTestHook(t *testing.T, wasm []byte, opts ...Option) *Hook {
+	t.Helper()
+	hook, err := New(context.Background(), wasm, opts...)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { _ = hook.Close(context.Background()) })
+	return hook
+}
+
+func TestHook_Evaluate(t *testing.T) {
+	in := Input{Direction: Inbound, Message: json.RawMessage(`{"type":"agent:send"}`)}
+	tests := []struct {
+		name     string
+		decision string // Empty allows by returning 0
+		want     Decision
+		wantErr  error
+	}{
+		{name: "allow", want: Decision{Action: Allow}},
+		{name: "explicit allow", decision: `{"action":"allow"}`, want: Decision{Action: Allow}},
+		{name: "deny", decision: `{"action":"deny","reason":"no secrets"}`, want: Decision{Action: Deny, Reason: "no secrets"}},
+		{
+			name:     "replace",
+			decision: `{"action":"replace","message":{"type":"agent:send","content":"redacted"}}`,
+			want:     Decision{Action: Replace, Message: json.RawMessage(`{"type":"agent:send","content":"redacted"}`)},
+		},
+		{name: "not json", decision: `deny`, wantErr: ErrInvalidDecision},
+		{name: "unknown action", decision: `{"action":"drop"}`, wantErr: ErrInvalidDecision},
+		{name: "replace without object", decision: `{"action":"replace","message":"hi"}`, wantErr: ErrInvalidDecision},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluate := allowBody
+			if tt.decision != "" {
+				evaluate = returnDecision(tt.decision)
+			}
+			hook := newTestHook(t, testModule(evaluate, tt.decision))
+			got, err := hook.Evaluate(context.Background(), in)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+			if got.Action != tt.want.Action || got.Reason != tt.want.Reason || string(got.Message) != string(tt.want.Message) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHook_StopsRunawayModules(t *testing.T) {
+	hook := newTestHook(t, testModule(loopBody, ""), WithTimeout(20*time.Millisecond))
+	start := time.Now()
+	if _, err := hook.Evaluate(context.Background(), Input{Direction: Outbound, Message: json.RawMessage(`{}`)}); err == nil {
+		t.Fatal("expected a module that never returns to fail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("evaluation ran %v past its timeout", elapsed)
+	}
+}
+
+func TestHook_ReplacesTrappedInstances(t *testing.T) {
+	hook := newTestHook(t, testModule(trapBody, ""))
+	for i := 0; i < 2; i++ {
+		if _, err := hook.Evaluate(context.Background(), Input{Direction: Inbound, Message: json.RawMessage(`{}`)}); err == nil {
+			t.Fatalf("call %d: expected the trap to be reported", i)
+		}
+		if hook.instance != nil {
+			t.Fatalf("call %d: expected the trapped instance to be discarded", i)
+		}
+	}
+}
+
+func TestNew_RejectsInvalidModules(t *testing.T) {
+	noEvaluate := bytes.Replace(testModule(allowBody, ""), []byte("policy_evaluate"), []byte("policy_evaluatx"), 1)
+	for name, wasm := range map[string][]byte{
+		"not wasm":    []byte("hello"),
+		"no evaluate": noEvaluate,
+	} {
+		if _, err := New(context.Background(), wasm); !errors.Is(err, ErrInvalidModule) {
+			t.Errorf("%s: expected ErrInvalidModule, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package redact removes credentials from text before it is logged or stored
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+)
+
+// Mode selects how matched secrets are replaced
+type Mode string
+
+const (
+	// ModeStrip replaces each secret with a fixed placeholder
+	ModeStrip Mode = "strip"
+
+	// ModeHash replaces each secret with a short SHA-256 fingerprint so the
+	// same secret can be correlated across log lines without being revealed
+	ModeHash Mode = "hash"
+)
+
+// placeholder is the replacement used by ModeStrip
+const placeholder = "[REDACTED]"
+
+// DefaultPatterns match common credential formats
+var DefaultPatterns = []string{
+	`sk-ant-[A-Za-z0-9_\-]{16,}`,          // Anthropic API keys
+	`sk-[A-Za-z0-9_\-]{20,}`,              // OpenAI-style secret keys
+	`gh[pousr]_[A-Za-z0-9]{36,}`,          // GitHub tokens
+	`github_pat_[A-Za-z0-9_]{22,}`,        // GitHub fine-grained tokens
+	`xox[abposr]-[A-Za-z0-9\-]{10,}`,      // Slack tokens
+	`AKIA[0-9A-Z]{16}`,                    // AWS access key IDs
+	`(?i)bearer\s+[A-Za-z0-9_\-\.=]{16,}`, // Authorization bearer tokens
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`, // PEM private keys
+}
+
+// Redactor replaces substrings matching any of its patterns
+// Safe for concurrent use
+type Redactor struct {
+	patterns []*regexp.Regexp
+	mode     Mode
+}
+
+// New compiles patterns into a Redactor
+// Returns an error naming the first pattern that fails to compile
+func New(mode Mode, patterns ...string) (*Redactor, error) {
+	switch mode {
+	case ModeStrip, ModeHash:
+	case "":
+		mode = ModeStrip
+	default:
+		return nil, fmt.Errorf("unknown redaction mode: %s", mode)
+	}
+
+	r := &Redactor{mode: mode}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Default returns a stripping Redactor for DefaultPatterns
+func Default() *Redactor {
+	r, err := New(ModeStrip, DefaultPatterns...)
+	if err != nil {
+		panic(err) // DefaultPatterns are constant and covered by tests
+	}
+	return r
+}
+
+// String returns s with every secret replaced
+// A nil Redactor returns s unchanged
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, r.replace)
+	}
+	return s
+}
+
+// replace renders the replacement for one matched secret
+func (r *Redactor) replace(secret string) string {
+	if r.mode == ModeHash {
+		sum := sha256.Sum256([]byte(secret))
+		return "[REDACTED:" + hex.EncodeToString(sum[:4]) + "]"
+	}
+	return placeholder
+}
+
+// Printer is the logging interface shared by the relay, session, and acp packages
+type Printer interface {
+	Printf(format string, v ...interface{})
+}
+
+// Logger redacts fully formatted log lines before passing them on
+// Wrap audit and transcript sinks with it so secrets never reach storage
+type Logger struct {
+	next     Printer
+	redactor *Redactor
+}
+
+// NewLogger wraps next so every line is redacted by r
+func NewLogger(next Printer, r *Redactor) *Logger {
+	return &Logger{next: next, redactor: r}
+}
+
+// Printf formats the line, redacts it, and forwards it
+func (l *Logger) Printf(format string, v ...interface{}) {
+	l.next.Printf("%s", l.redactor.String(fmt.Sprintf(format, v...)))
+}
@@ -0,0 +1,208 @@
+package relay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// AccessLogEntry describes one HTTP request; for WebSocket connections it is
+// written when the connection ends, so Duration is the connection's lifetime
+type AccessLogEntry struct {
+	Time         time.Time `json:"time"`           // When the request arrived
+	RemoteAddr   string    `json:"remoteAddr"`     // The client, behind any trusted proxies
+	Peer         string    `json:"peer,omitempty"` // The proxy that relayed the request
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	Origin       string    `json:"origin,omitempty"`
+	UserAgent    string    `json:"userAgent,omitempty"`
+	Status       int       `json:"status"` // 101 for upgraded WebSocket connections
+	Upgraded     bool      `json:"upgraded"`
+	UpgradeError string    `json:"upgradeError,omitempty"`
+	DurationMs   int64     `json:"durationMs"`
+	CloseReason  string    `json:"closeReason,omitempty"` // Why an upgraded connection ended
+}
+
+// AccessLogSink receives access log entries
+// Implementations must be safe for concurrent use.
+type AccessLogSink interface {
+	LogAccess(entry AccessLogEntry)
+}
+
+// JSONAccessLog writes each entry as one JSON line, keeping traffic records
+// apart from the application log
+type JSONAccessLog struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewJSONAccessLog creates a sink writing JSON lines to w
+func NewJSONAccessLog(w io.Writer) *JSONAccessLog {
+	return &JSONAccessLog{w: w}
+}
+
+// LogAccess writes entry; write errors are dropped so logging never fails a request
+func (l *JSONAccessLog) LogAccess(entry AccessLogEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(append(data, '\n'))
+}
+
+// AccessLogger is HTTP middleware recording every request to a sink
+// Successful requests are sampled at SampleRate; failed requests (status 400
+// and above, or a failed WebSocket upgrade) are always logged.
+type AccessLogger struct {
+	sink   AccessLogSink
+	clock  Clock
+	rate   float64
+	sample func() float64 // Returns [0, 1); injectable for tests
+}
+
+// NewAccessLogger creates middleware logging to sink, keeping sampleRate
+// (0 to 1) of successful requests
+func NewAccessLogger(sink AccessLogSink, sampleRate float64, clock Clock) *AccessLogger {
+	return &AccessLogger{sink: sink, clock: clock, rate: sampleRate, sample: rand.Float64} // #nosec G404 -- sampling needs no crypto randomness
+}
+
+// Middleware wraps next so each request is logged when it completes
+func (a *AccessLogger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := a.clock.Monotonic()
+		entry := AccessLogEntry{
+			Time:       a.clock.Now(),
+			RemoteAddr: r.RemoteAddr,
+			Peer:       PeerAddr(r),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Origin:     r.Header.Get("Origin"),
+			UserAgent:  r.UserAgent(),
+		}
+		rec := &accessRecord{}
+		rw := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			entry.Status = rw.status()
+			entry.Upgraded = rw.hijacked
+			entry.DurationMs = (a.clock.Monotonic() - start).Milliseconds()
+			entry.UpgradeError, entry.CloseReason = rec.get()
+			if a.keep(entry) {
+				a.sink.LogAccess(entry)
+			}
+		}()
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), accessRecordKey{}, rec)))
+	})
+}
+
+// keep applies sampling to successful requests
+func (a *AccessLogger) keep(entry AccessLogEntry) bool {
+	if entry.Status >= http.StatusBadRequest || entry.UpgradeError != "" {
+		return true
+	}
+	return a.rate >= 1 || a.sample() < a.rate
+}
+
+// accessRecordKey is the context key under which handlers find the accessRecord
+type accessRecordKey struct{}
+
+// accessRecord carries what only the handler knows back to the middleware
+type accessRecord struct {
+	mu           sync.Mutex
+	upgradeError string
+	closeReason  string
+}
+
+func (r *accessRecord) get() (upgradeError, closeReason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.upgradeError, r.closeReason
+}
+
+// noteUpgradeError records a failed WebSocket upgrade in the access log, if any
+func noteUpgradeError(ctx context.Context, err error) {
+	if rec, ok := ctx.Value(accessRecordKey{}).(*accessRecord); ok {
+		rec.mu.Lock()
+		rec.upgradeError = err.Error()
+		rec.mu.Unlock()
+	}
+}
+
+// noteCloseReason records why a WebSocket connection ended in the access log, if any
+func noteCloseReason(ctx context.Context, reason string) {
+	if rec, ok := ctx.Value(accessRecordKey{}).(*accessRecord); ok {
+		rec.mu.Lock()
+		rec.closeReason = reason
+		rec.mu.Unlock()
+	}
+}
+
+// describeReadError turns the error that ended a read loop into a close reason (pure function)
+func describeReadError(err error) string {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		if closeErr.Text != "" {
+			return fmt.Sprintf("client closed: %d %s", closeErr.Code, closeErr.Text)
+		}
+		return fmt.Sprintf("client closed: %d", closeErr.Code)
+	}
+	return "read error: " + err.Error()
+}
+
+// statusRecorder captures the response status and whether the connection
+// was hijacked for a WebSocket upgrade
+type statusRecorder struct {
+	http.ResponseWriter
+	code     int
+	hijacked bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Hijack hands the connection to the WebSocket upgrader, which writes the
+// 101 response itself
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		r.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// status returns the recorded status; handlers that wrote nothing sent 200
+func (r *statusRecorder) status() int {
+	switch {
+	case r.hijacked:
+		return http.StatusSwitchingProtocols
+	case r.code == 0:
+		return http.StatusOK
+	}
+	return r.code
+}
@@ -0,0 +1,99 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// chanAccessSink hands entries to the test as they are logged
+type chanAccessSink chan AccessLogEntry
+
+func (c chanAccessSink) LogAccess(entry AccessLogEntry) { c <- entry }
+
+func (c chanAccessSink) next(t *testing.T) AccessLogEntry {
+	t.Helper()
+	select {
+	case entry := <-c:
+		return entry
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for access log entry")
+		return AccessLogEntry{}
+	}
+}
+
+func TestAccessLogger_WebSocket(t *testing.T) {
+	sink := make(chanAccessSink, 4)
+	logger := NewAccessLogger(sink, 1, &SystemClock{})
+	httpServer := httptest.NewServer(logger.Middleware(http.HandlerFunc(newTestServer().HandleWebSocket)))
+	defer httpServer.Close()
+	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/ws"
+
+	// Plain GET fails the upgrade
+	resp, err := http.Get(httpServer.URL + "/ws")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	entry := sink.next(t)
+	if entry.Status != http.StatusBadRequest || entry.Upgraded || entry.UpgradeError == "" {
+		t.Errorf("unexpected entry for failed upgrade: %+v", entry)
+	}
+
+	header := http.Header{"Origin": {"https://app.example"}}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	_, _, _ = conn.ReadMessage()
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"))
+	_ = conn.Close()
+
+	entry = sink.next(t)
+	if entry.Status != http.StatusSwitchingProtocols || !entry.Upgraded || entry.Path != "/ws" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.Origin != "https://app.example" || entry.CloseReason != "client closed: 1000 bye" {
+		t.Errorf("expected origin and close reason, got %+v", entry)
+	}
+}
+
+func TestAccessLogger_Sampling(t *testing.T) {
+	logger := NewAccessLogger(nil, 0.25, &SystemClock{})
+	logger.sample = func() float64 { return 0.5 }
+	if logger.keep(AccessLogEntry{Status: http.StatusOK}) {
+		t.Error("expected request above the sample rate to be dropped")
+	}
+	if !logger.keep(AccessLogEntry{Status: http.StatusForbidden}) {
+		t.Error("expected failed request to be kept")
+	}
+	logger.sample = func() float64 { return 0.1 }
+	if !logger.keep(AccessLogEntry{Status: http.StatusOK}) {
+		t.Error("expected request below the sample rate to be kept")
+	}
+}
+
+func TestJSONAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONAccessLog(&buf)
+	sink.LogAccess(AccessLogEntry{Time: testTime, Path: "/ws", Status: 101, Upgraded: true, DurationMs: 1500})
+	sink.LogAccess(AccessLogEntry{Time: testTime, Path: "/ws", Status: 400})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if entry["path"] != "/ws" || entry["upgraded"] != true || entry["durationMs"] != 1500.0 {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
@@ -3,8 +3,9 @@ package relay
 import (
 	"log"
 	"net/http"
-	"time"
+	"sync"
 
+	"github.com/2389-research/ourocodus/pkg/clock"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
@@ -16,12 +17,8 @@ func (l *StdLogger) Printf(format string, v ...interface{}) {
 	log.Printf(format, v...)
 }
 
-// SystemClock returns current system time
-type SystemClock struct{}
-
-func (c *SystemClock) Now() string {
-	return time.Now().UTC().Format(time.RFC3339)
-}
+// SystemClock returns current system time in UTC
+type SystemClock = clock.System
 
 // UUIDGenerator generates UUIDs
 type UUIDGenerator struct{}
@@ -30,6 +27,47 @@ func (g *UUIDGenerator) Generate() string {
 	return uuid.New().String()
 }
 
+// NoOpMetrics discards all metric updates
+type NoOpMetrics struct{}
+
+func (m *NoOpMetrics) IncCounter(name string) {}
+
+// CounterMetrics keeps thread-safe in-memory counters
+// Useful for tests and debug endpoints until a real metrics backend is wired in
+type CounterMetrics struct {
+	counters map[string]int64
+	mu       sync.Mutex
+}
+
+// NewCounterMetrics creates an empty in-memory counter set
+func NewCounterMetrics() *CounterMetrics {
+	return &CounterMetrics{counters: make(map[string]int64)}
+}
+
+func (m *CounterMetrics) IncCounter(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name]++
+}
+
+// Snapshot returns a copy of every counter
+func (m *CounterMetrics) Snapshot() map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counters := make(map[string]int64, len(m.counters))
+	for name, n := range m.counters {
+		counters[name] = n
+	}
+	return counters
+}
+
+// Value returns the current value of the named counter
+func (m *CounterMetrics) Value(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
 // GorillaUpgrader wraps gorilla websocket upgrader
 type GorillaUpgrader struct {
 	upgrader websocket.Upgrader
@@ -43,23 +81,10 @@ func NewGorillaUpgrader(checkOrigin func(*http.Request) bool) *GorillaUpgrader {
 	}
 }
 
-func (u *GorillaUpgrader) Upgrade(w interface{}, r interface{}, responseHeader interface{}) (WebSocketConn, error) {
-	httpW, ok := w.(http.ResponseWriter)
-	if !ok {
-		panic("Upgrade: type assertion failed - w must be http.ResponseWriter")
+func (u *GorillaUpgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (WebSocketConn, error) {
+	conn, err := u.upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		return nil, err
 	}
-	httpR, ok := r.(*http.Request)
-	if !ok {
-		panic("Upgrade: type assertion failed - r must be *http.Request")
-	}
-
-	var headers http.Header
-	if responseHeader != nil {
-		headers, ok = responseHeader.(http.Header)
-		if !ok {
-			panic("Upgrade: type assertion failed - responseHeader must be http.Header")
-		}
-	}
-
-	return u.upgrader.Upgrade(httpW, httpR, headers)
+	return newPriorityConn(conn), nil
 }
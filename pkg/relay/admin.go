@@ -0,0 +1,852 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+const (
+	// defaultAdminPageSize is used when a list request does not specify a limit
+	defaultAdminPageSize = 50
+
+	// maxAdminPageSize caps the page size a client may request
+	maxAdminPageSize = 500
+)
+
+// SessionView is the admin API representation of a session
+// Timestamps are formatted as RFC3339 at this serialization boundary
+type SessionView struct {
+	ID            string                `json:"id"`
+	Name          string                `json:"name,omitempty"`
+	AgentID       string                `json:"agentId"`
+	OwnerID       string                `json:"ownerId,omitempty"`
+	Collaborators []string              `json:"collaborators,omitempty"`
+	Labels        map[string]string     `json:"labels,omitempty"`
+	Agent         *session.AgentConfig  `json:"agent,omitempty"`     // Set if the spawn configured the agent
+	AgentInfo     *acp.InitializeResult `json:"agentInfo,omitempty"` // What the agent reported at initialize
+	State         string                `json:"state"`
+	WorktreeDir   string                `json:"worktreeDir,omitempty"`
+	CreatedAt     string                `json:"createdAt"`
+	LastActive    string                `json:"lastActive"`
+	CleanedAt     string                `json:"cleanedAt,omitempty"` // Set on sessions retained after cleanup
+	MessageCount  int                   `json:"messageCount"`
+	Version       uint64                `json:"version"`
+	Resources     *ResourceView         `json:"resources,omitempty"` // Latest agent sample, if any
+}
+
+// ResourceView is the latest CPU and memory sample of a session's agent process
+type ResourceView struct {
+	SampledAt  string  `json:"sampledAt"`
+	CPUPercent float64 `json:"cpuPercent"`
+	RSSBytes   int64   `json:"rssBytes"`
+}
+
+// SessionListResponse is returned by GET /admin/sessions
+// NextOffset is set when more results may be available
+type SessionListResponse struct {
+	NextOffset *int          `json:"nextOffset,omitempty"`
+	Sessions   []SessionView `json:"sessions"`
+	Offset     int           `json:"offset"`
+	Limit      int           `json:"limit"`
+}
+
+// HistoryMatchView is the admin API representation of a history search hit
+type HistoryMatchView struct {
+	SessionID string `json:"sessionId"`
+	AgentID   string `json:"agentId"`
+	Speaker   string `json:"speaker"`
+	Snippet   string `json:"snippet"`
+	Content   string `json:"content"`
+	Time      string `json:"time"`
+}
+
+// HistorySearchResponse is returned by GET /admin/history
+type HistorySearchResponse struct {
+	Matches []HistoryMatchView `json:"matches"`
+}
+
+// TimelineEventView is one point on a session's timeline
+// Messages and failures are set on "messages" points, counting the agent
+// exchanges in the minute starting at time.
+type TimelineEventView struct {
+	Time     string `json:"time"`
+	Kind     string `json:"kind"`
+	AgentID  string `json:"agentId,omitempty"`
+	State    string `json:"state,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Messages int    `json:"messages,omitempty"`
+	Failures int    `json:"failures,omitempty"`
+}
+
+// TimelineResponse is returned by GET /admin/sessions/{id}/timeline
+type TimelineResponse struct {
+	SessionID string              `json:"sessionId"`
+	Events    []TimelineEventView `json:"events"`
+}
+
+// TerminateResponse is returned by POST /admin/sessions/terminate
+type TerminateResponse struct {
+	Matched    int      `json:"matched"`
+	Terminated []string `json:"terminated"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// ConnectionListResponse is returned by GET /admin/connections
+type ConnectionListResponse struct {
+	Connections []ConnectionInfo `json:"connections"`
+}
+
+// StatsView is returned by GET /admin/stats (see session.Manager.Stats)
+type StatsView struct {
+	Sessions          int            `json:"sessions"`
+	ByState           map[string]int `json:"byState"`
+	AgentsByState     map[string]int `json:"agentsByState"`
+	SpawnFailures     int64          `json:"spawnFailures"`
+	AverageAgeSeconds float64        `json:"averageAgeSeconds"`
+}
+
+// AdminHandler serves session management endpoints over HTTP
+// Mount it on an internal listener; it performs no authentication of its own
+// unless WithAdminSigning requires signed requests.
+// Calls that change one session return a ConsistencyHeader token that
+// session reads accept, for read-your-writes across relays.
+type AdminHandler struct {
+	manager  *session.Manager
+	spawner  *Spawner
+	server   *Server
+	stats    *AgentStats
+	jobs     *JobRunner
+	sched    *Scheduler
+	verifier *AdminVerifier
+	logger   Logger
+	mux      *http.ServeMux
+
+	consistencyWait time.Duration // How long reads wait for their ConsistencyHeader tokens
+}
+
+// AdminOption enables optional admin endpoints
+type AdminOption func(*AdminHandler)
+
+// WithAdminImport enables POST /admin/sessions/import, starting the agents of
+// imported sessions through spawner
+func WithAdminImport(spawner *Spawner) AdminOption {
+	return func(h *AdminHandler) {
+		h.spawner = spawner
+	}
+}
+
+// WithAdminConnections enables GET /admin/connections and
+// GET /admin/connections/{id}, the server's view of open WebSocket connections
+func WithAdminConnections(server *Server) AdminOption {
+	return func(h *AdminHandler) {
+		h.server = server
+	}
+}
+
+// WithAdminAgentStats enables GET /api/v1/stats, the per-session and
+// per-role agent request stats collected by stats
+func WithAdminAgentStats(stats *AgentStats) AdminOption {
+	return func(h *AdminHandler) {
+		h.stats = stats
+	}
+}
+
+// WithAdminJobs enables POST /admin/jobs, running one-shot agent tasks
+// with runner
+func WithAdminJobs(runner *JobRunner) AdminOption {
+	return func(h *AdminHandler) {
+		h.jobs = runner
+	}
+}
+
+// WithAdminScheduler enables /admin/schedules: listing, defining and
+// deleting the scheduler's recurring jobs, and running one on demand
+func WithAdminScheduler(scheduler *Scheduler) AdminOption {
+	return func(h *AdminHandler) {
+		h.sched = scheduler
+	}
+}
+
+// NewAdminHandler creates an admin API handler backed by the session manager
+func NewAdminHandler(manager *session.Manager, logger Logger, opts ...AdminOption) *AdminHandler {
+	h := &AdminHandler{
+		manager: manager,
+		logger:  logger,
+		mux:     http.NewServeMux(),
+
+		consistencyWait: defaultConsistencyWait,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("GET /admin/sessions", h.handleListSessions)
+	h.mux.HandleFunc("GET /admin/sessions/{id}/export", h.handleExportSession)
+	h.mux.HandleFunc("GET /admin/sessions/{id}/timeline", h.handleSessionTimeline)
+	h.mux.HandleFunc("POST /admin/sessions/terminate", h.handleTerminateSessions)
+	h.mux.HandleFunc("POST /admin/sessions/{id}/replace-agent", h.handleReplaceAgent)
+	h.mux.HandleFunc("POST /admin/sessions/{id}/transfer", h.handleTransferSession)
+	h.mux.HandleFunc("GET /admin/history", h.handleSearchHistory)
+	h.mux.HandleFunc("GET /admin/stats", h.handleStats)
+	h.mux.HandleFunc("POST /admin/owners/{owner}/purge", h.handlePurgeOwner)
+	if h.spawner != nil {
+		h.mux.HandleFunc("POST /admin/sessions/import", h.handleImportSession)
+	}
+	if h.server != nil {
+		h.mux.HandleFunc("GET /admin/connections", h.handleListConnections)
+		h.mux.HandleFunc("GET /admin/connections/{id}", h.handleGetConnection)
+	}
+	if h.stats != nil {
+		h.mux.HandleFunc("GET /api/v1/stats", h.handleAgentStats)
+	}
+	if h.jobs != nil {
+		h.mux.HandleFunc("POST /admin/jobs", h.handleRunJob)
+	}
+	if h.sched != nil {
+		h.mux.HandleFunc("GET /admin/schedules", h.handleListSchedules)
+		h.mux.HandleFunc("GET /admin/schedules/{name}", h.handleGetSchedule)
+		h.mux.HandleFunc("PUT /admin/schedules/{name}", h.handlePutSchedule)
+		h.mux.HandleFunc("DELETE /admin/schedules/{name}", h.handleDeleteSchedule)
+		h.mux.HandleFunc("POST /admin/schedules/{name}/run", h.handleTriggerSchedule)
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.verifier != nil {
+		if err := h.verifier.Verify(r); err != nil {
+			h.logger.Printf("Admin request rejected: key=%q remote=%s err=%v", r.Header.Get(adminKeyIDHeader), r.RemoteAddr, err)
+			h.verifier.metrics.IncCounter(MetricAdminRejected)
+			h.writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// handleListSessions lists sessions with filtering, sorting, and pagination
+func (h *AdminHandler) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	if !h.awaitConsistency(w, r) {
+		return
+	}
+	filter, err := parseSessionFilter(r.URL.Query())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sessions := h.manager.List(filter)
+	resp := SessionListResponse{
+		Sessions: make([]SessionView, 0, len(sessions)),
+		Offset:   filter.Offset,
+		Limit:    filter.Limit,
+	}
+	for _, s := range sessions {
+		resp.Sessions = append(resp.Sessions, newSessionView(s))
+	}
+	if len(sessions) == filter.Limit {
+		next := filter.Offset + filter.Limit
+		resp.NextOffset = &next
+	}
+
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// handleExportSession streams a session archive (see session.Manager.Export)
+// Errors after the archive has started are only logged; the truncated
+// archive fails to import.
+func (h *AdminHandler) handleExportSession(w http.ResponseWriter, r *http.Request) {
+	if !h.awaitConsistency(w, r) {
+		return
+	}
+	id := r.PathValue("id")
+	if h.manager.Get(id) == nil {
+		h.writeError(w, http.StatusNotFound, fmt.Sprintf("session %s not found", id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".tar.gz"))
+	if err := h.manager.Export(r.Context(), id, w); err != nil {
+		h.logger.Printf("Failed to export session: session=%s err=%v", id, err)
+	}
+}
+
+// handleSessionTimeline returns a session's lifecycle changes and per-minute
+// agent exchange counts, oldest first, for the UI's timeline view
+// Sessions already cleaned up have timelines for as long as their journal is kept.
+func (h *AdminHandler) handleSessionTimeline(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	timeline, err := h.manager.GetTimeline(id)
+	switch {
+	case errors.Is(err, session.ErrTimelineDisabled):
+		h.writeJSON(w, http.StatusNotFound, NewErrorMessage("TIMELINE_DISABLED", err.Error(), false))
+		return
+	case errors.Is(err, session.ErrSessionNotFound):
+		h.writeError(w, http.StatusNotFound, fmt.Sprintf("session %s not found", id))
+		return
+	case err != nil:
+		h.logger.Printf("Failed to read session timeline: session=%s err=%v", id, err)
+		h.writeJSON(w, http.StatusInternalServerError, NewErrorMessage("INTERNAL_ERROR", "timeline unavailable", true))
+		return
+	}
+
+	resp := TimelineResponse{SessionID: id, Events: make([]TimelineEventView, 0, len(timeline))}
+	for _, e := range timeline {
+		resp.Events = append(resp.Events, TimelineEventView{
+			Time:     FormatTimestamp(e.Time),
+			Kind:     string(e.Kind),
+			AgentID:  e.AgentID,
+			State:    string(e.State),
+			Reason:   e.Reason,
+			Error:    e.Error,
+			Messages: e.Messages,
+			Failures: e.Failures,
+		})
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// handleImportSession recreates a session from the archive in the request
+// body and starts its agent; workspace (required) is where files are restored
+// The session starts detached; clients claim it with session:reattach.
+func (h *AdminHandler) handleImportSession(w http.ResponseWriter, r *http.Request) {
+	workspace := r.URL.Query().Get("workspace")
+	if workspace == "" {
+		h.writeError(w, http.StatusBadRequest, "workspace is required")
+		return
+	}
+
+	sess, err := h.spawner.Import(r.Context(), nil, r.Body, workspace)
+	switch {
+	case errors.Is(err, session.ErrInvalidArchive):
+		h.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrResourcePressure):
+		h.writeError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, ErrSpawnRejected):
+		h.writeError(w, http.StatusTooManyRequests, err.Error())
+	case err != nil:
+		h.writeError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		h.setConsistency(w, sess)
+		h.writeJSON(w, http.StatusCreated, newSessionView(sess))
+	}
+}
+
+// handleReplaceAgent restarts a session's agent (see session.Manager.ReplaceAgent)
+// Supported: role (defaults to the session's), replay (history entries to catch the new agent up on)
+func (h *AdminHandler) handleReplaceAgent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	sess := h.manager.Get(id)
+	if sess == nil {
+		h.writeError(w, http.StatusNotFound, fmt.Sprintf("session %s not found", id))
+		return
+	}
+	var opts []session.ReplaceOption
+	if v := r.URL.Query().Get("replay"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxAdminPageSize {
+			h.writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid replay: %s (expected 0-%d)", v, maxAdminPageSize))
+			return
+		}
+		opts = append(opts, session.WithReplay(n))
+	}
+
+	err := h.manager.ReplaceAgent(r.Context(), id, r.URL.Query().Get("role"), opts...)
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound):
+		h.writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, session.ErrReplaceDisabled), errors.Is(err, session.ErrHistoryDisabled):
+		h.writeError(w, http.StatusNotImplemented, err.Error())
+	case errors.Is(err, session.ErrSessionNotActive), errors.Is(err, session.ErrPayloadKeyUnavailable):
+		h.writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		h.logger.Printf("Agent replacement failed: session=%s err=%v", id, err)
+		h.writeError(w, http.StatusBadGateway, err.Error())
+	default:
+		// ReplaceAgent touched the session as it swapped the agent in
+		announceAgent(sess, sess.GetLastActive(), h.logger)
+		h.setConsistency(w, sess)
+		h.writeJSON(w, http.StatusOK, newSessionView(sess))
+	}
+}
+
+// handleTransferSession hands a session to another user (see
+// session.Manager.Transfer) and returns the updated session
+// Supported: to (required), from (the expected current owner; defaults to
+// whoever owns it now), keepAccess (true keeps the previous owner as a collaborator)
+func (h *AdminHandler) handleTransferSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	sess := h.manager.Get(id)
+	if sess == nil {
+		h.writeError(w, http.StatusNotFound, fmt.Sprintf("session %s not found", id))
+		return
+	}
+	query := r.URL.Query()
+	to := query.Get("to")
+	if to == "" {
+		h.writeError(w, http.StatusBadRequest, "to is required")
+		return
+	}
+	from := sess.GetOwnerID()
+	if query.Has("from") {
+		from = query.Get("from")
+	}
+	keepAccess, err := strconv.ParseBool(query.Get("keepAccess"))
+	if err != nil && query.Has("keepAccess") {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid keepAccess: %s", query.Get("keepAccess")))
+		return
+	}
+
+	_, err = h.manager.Transfer(r.Context(), id, from, to, keepAccess)
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound):
+		h.writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, session.ErrNotOwner), errors.Is(err, session.ErrNameTaken):
+		h.writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		h.setConsistency(w, sess)
+		h.writeJSON(w, http.StatusOK, newSessionView(sess))
+	}
+}
+
+// handleTerminateSessions terminates every session matching the same filters
+// as GET /admin/sessions, several at a time
+// Without a filter nothing is terminated unless all=true. Sorting and
+// pagination apply too, but limit defaults to no limit here; parallelism
+// bounds how many sessions end at once. Partial failures are listed in the
+// response's errors with status 200.
+func (h *AdminHandler) handleTerminateSessions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	all := q.Get("all") == "true"
+	q.Del("all")
+	parallelism := session.DefaultTerminateParallelism
+	if v := q.Get("parallelism"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			h.writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid parallelism: %s", v))
+			return
+		}
+		parallelism = n
+		q.Del("parallelism")
+	}
+	if len(q) == 0 && !all {
+		h.writeError(w, http.StatusBadRequest, "a filter is required; pass all=true to terminate every session")
+		return
+	}
+	filter, err := parseSessionFilter(q)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if q.Get("limit") == "" {
+		filter.Limit = 0
+	}
+
+	resp := TerminateResponse{Terminated: []string{}}
+	progress := func(p session.TerminateProgress) {
+		resp.Matched = p.Total
+		if p.Err != nil {
+			resp.Errors = append(resp.Errors, p.Err.Error())
+			return
+		}
+		resp.Terminated = append(resp.Terminated, p.SessionID)
+	}
+	_, err = h.manager.TerminateAll(r.Context(), filter, session.WithTerminateParallelism(parallelism),
+		session.WithTerminateReason("terminated by admin"), session.WithTerminateProgress(progress))
+	h.logger.Printf("Sessions terminated by admin: matched=%d terminated=%d errors=%d", resp.Matched, len(resp.Terminated), len(resp.Errors))
+	if err != nil && r.Context().Err() != nil {
+		return // The caller went away
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// handlePurgeOwner erases a user's sessions, history and workspaces (see
+// session.Manager.PurgeOwner) and returns the purge report
+// Partial failures are listed in the report's errors with status 200, so
+// the report is never lost.
+func (h *AdminHandler) handlePurgeOwner(w http.ResponseWriter, r *http.Request) {
+	owner := r.PathValue("owner")
+	report, err := h.manager.PurgeOwner(r.Context(), owner)
+	if err != nil {
+		h.logger.Printf("Owner purge interrupted: owner=%s sessions=%d err=%v", owner, len(report.Sessions), err)
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, report)
+}
+
+// handleListConnections lists open WebSocket connections, oldest first
+func (h *AdminHandler) handleListConnections(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, ConnectionListResponse{Connections: h.server.Connections()})
+}
+
+// handleGetConnection shows one open WebSocket connection
+func (h *AdminHandler) handleGetConnection(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	info, ok := h.server.Connection(id)
+	if !ok {
+		h.writeError(w, http.StatusNotFound, fmt.Sprintf("connection %s not found", id))
+		return
+	}
+	h.writeJSON(w, http.StatusOK, info)
+}
+
+// handleStats reports aggregate session counts
+func (h *AdminHandler) handleStats(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, newStatsView(h.manager.Stats()))
+}
+
+// handleRunJob runs the job in the request body (a JobRequest) and returns
+// its JobResult once the session is torn down
+// The request blocks for the whole job; set the job's timeout to bound it.
+// Jobs that started but failed are still 200 with ok false.
+func (h *AdminHandler) handleRunJob(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid job: %v", err))
+		return
+	}
+	result, err := h.jobs.Run(r.Context(), req)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// handleListSchedules lists the scheduler's schedules by name
+func (h *AdminHandler) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, ScheduleListResponse{Schedules: h.sched.List()})
+}
+
+// handleGetSchedule shows one schedule with its current and last run
+func (h *AdminHandler) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	view, ok := h.sched.Get(name)
+	if !ok {
+		h.writeError(w, http.StatusNotFound, fmt.Sprintf("schedule %s not found", name))
+		return
+	}
+	h.writeJSON(w, http.StatusOK, view)
+}
+
+// handlePutSchedule defines or replaces the schedule named in the path from
+// a ScheduleConfig body; its name field may be omitted
+func (h *AdminHandler) handlePutSchedule(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var config ScheduleConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid schedule: %v", err))
+		return
+	}
+	if config.Name != "" && config.Name != name {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("schedule name %q does not match the path", config.Name))
+		return
+	}
+	config.Name = name
+	view, err := h.sched.Put(config)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.logger.Printf("Schedule defined: schedule=%s cron=%q paused=%t", name, config.Cron, config.Paused)
+	h.writeJSON(w, http.StatusOK, view)
+}
+
+// handleDeleteSchedule removes a schedule; a run in progress finishes
+func (h *AdminHandler) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := h.sched.Delete(name); err != nil {
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	h.logger.Printf("Schedule deleted: schedule=%s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTriggerSchedule starts a schedule's job now and returns 202 without
+// waiting for it; poll the schedule for the result
+func (h *AdminHandler) handleTriggerSchedule(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	// The job outlives this request
+	err := h.sched.Trigger(context.WithoutCancel(r.Context()), name)
+	switch {
+	case errors.Is(err, ErrScheduleNotFound):
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, ErrScheduleRunning):
+		h.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	view, _ := h.sched.Get(name)
+	h.writeJSON(w, http.StatusAccepted, view)
+}
+
+// handleAgentStats reports agent request stats per session and role
+func (h *AdminHandler) handleAgentStats(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.stats.View())
+}
+
+// newStatsView converts stats for serialization (pure function)
+func newStatsView(stats session.Stats) StatsView {
+	view := StatsView{
+		Sessions:          stats.Sessions,
+		ByState:           make(map[string]int, len(stats.ByState)),
+		AgentsByState:     make(map[string]int, len(stats.AgentsByState)),
+		SpawnFailures:     stats.SpawnFailures,
+		AverageAgeSeconds: stats.AverageAge.Seconds(),
+	}
+	for state, n := range stats.ByState {
+		view.ByState[state.String()] = n
+	}
+	for state, n := range stats.AgentsByState {
+		view.AgentsByState[state.String()] = n
+	}
+	return view
+}
+
+// handleSearchHistory runs a full-text search over conversation history
+// Supported: q (required), session, role, speaker (user|agent), limit
+func (h *AdminHandler) handleSearchHistory(w http.ResponseWriter, r *http.Request) {
+	query, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	matches, err := h.manager.SearchHistory(query)
+	if errors.Is(err, session.ErrHistoryDisabled) {
+		h.writeJSON(w, http.StatusNotFound, NewErrorMessage("HISTORY_DISABLED", err.Error(), false))
+		return
+	}
+	if err != nil {
+		h.logger.Printf("History search failed: %v", err)
+		h.writeJSON(w, http.StatusInternalServerError, NewErrorMessage("INTERNAL_ERROR", "history search failed", true))
+		return
+	}
+
+	resp := HistorySearchResponse{Matches: make([]HistoryMatchView, 0, len(matches))}
+	for _, m := range matches {
+		resp.Matches = append(resp.Matches, HistoryMatchView{
+			SessionID: m.Entry.SessionID,
+			AgentID:   m.Entry.AgentID,
+			Speaker:   string(m.Entry.Speaker),
+			Snippet:   m.Snippet,
+			Content:   m.Entry.Content,
+			Time:      FormatTimestamp(m.Entry.Time),
+		})
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// parseHistoryQuery builds a HistoryQuery from query parameters (pure function)
+func parseHistoryQuery(q url.Values) (session.HistoryQuery, error) {
+	query := session.HistoryQuery{
+		Text:      strings.TrimSpace(q.Get("q")),
+		SessionID: q.Get("session"),
+		AgentID:   q.Get("role"),
+		Limit:     defaultAdminPageSize,
+	}
+	if query.Text == "" {
+		return query, fmt.Errorf("missing search text: q")
+	}
+
+	switch speaker := session.Speaker(q.Get("speaker")); speaker {
+	case "", session.SpeakerUser, session.SpeakerAgent:
+		query.Speaker = speaker
+	default:
+		return query, fmt.Errorf("invalid speaker: %s (expected user or agent)", speaker)
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAdminPageSize {
+			return query, fmt.Errorf("invalid limit: %s (expected 1-%d)", v, maxAdminPageSize)
+		}
+		query.Limit = n
+	}
+	return query, nil
+}
+
+// newSessionView snapshots a session for serialization
+func newSessionView(s *session.Session) SessionView {
+	view := SessionView{
+		ID:            s.GetID(),
+		Name:          s.GetName(),
+		AgentID:       s.GetAgentID(),
+		OwnerID:       s.GetOwnerID(),
+		Collaborators: s.GetCollaborators(),
+		Labels:        s.GetLabels(),
+		State:         s.GetState().String(),
+		WorktreeDir:   s.GetWorktreeDir(),
+		CreatedAt:     FormatTimestamp(s.GetCreatedAt()),
+		LastActive:    FormatTimestamp(s.GetLastActive()),
+		MessageCount:  s.GetMessageCount(),
+		Version:       s.GetVersion(),
+		AgentInfo:     s.GetAgentInfo(),
+	}
+	if cfg := s.GetAgentConfig(); !cfg.IsZero() {
+		view.Agent = &cfg
+	}
+	if cleanedAt := s.GetCleanedAt(); !cleanedAt.IsZero() {
+		view.CleanedAt = FormatTimestamp(cleanedAt)
+	}
+	if usage := s.GetResourceUsage(); !usage.SampledAt.IsZero() {
+		view.Resources = &ResourceView{
+			SampledAt:  FormatTimestamp(usage.SampledAt),
+			CPUPercent: usage.CPUPercent,
+			RSSBytes:   usage.RSSBytes,
+		}
+	}
+	return view
+}
+
+// parseSessionFilter builds a SessionFilter from query parameters (pure function)
+// Supported: state, role (comma-separated), owner, name, label (repeatable key=value),
+// createdAfter, createdBefore (RFC3339), idleLongerThan (Go duration), sort,
+// order (asc|desc), limit, offset
+func parseSessionFilter(q url.Values) (*session.SessionFilter, error) {
+	filter := &session.SessionFilter{Limit: defaultAdminPageSize}
+
+	if v := q.Get("state"); v != "" {
+		state := session.SessionState(strings.ToUpper(v))
+		if !state.IsValid() {
+			return nil, fmt.Errorf("invalid state: %s", v)
+		}
+		filter.State = &state
+	}
+
+	if v := q.Get("role"); v != "" {
+		filter.HasAgentRole = strings.Split(v, ",")
+	}
+
+	if v := q.Get("owner"); v != "" {
+		filter.OwnerID = &v
+	}
+
+	if v := q.Get("name"); v != "" {
+		filter.Name = &v
+	}
+
+	for _, pair := range q["label"] {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label: %s (expected key=value)", pair)
+		}
+		if filter.Labels == nil {
+			filter.Labels = make(map[string]string)
+		}
+		filter.Labels[key] = value
+	}
+
+	for _, bound := range []struct {
+		param string
+		dst   **time.Time
+	}{
+		{"createdAfter", &filter.CreatedAfter},
+		{"createdBefore", &filter.CreatedBefore},
+	} {
+		v := q.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", bound.param, err)
+		}
+		*bound.dst = &t
+	}
+
+	if v := q.Get("idleLongerThan"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid idleLongerThan: %s", v)
+		}
+		filter.IdleLongerThan = &d
+	}
+
+	if v := q.Get("sort"); v != "" {
+		field := session.SortField(v)
+		if !field.IsValid() {
+			return nil, fmt.Errorf("invalid sort field: %s", v)
+		}
+		filter.SortBy = field
+	}
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return nil, fmt.Errorf("invalid order: %s (expected asc or desc)", q.Get("order"))
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAdminPageSize {
+			return nil, fmt.Errorf("invalid limit: %s (expected 1-%d)", v, maxAdminPageSize)
+		}
+		filter.Limit = n
+	}
+
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid offset: %s", v)
+		}
+		filter.Offset = n
+	}
+
+	return filter, nil
+}
+
+// setConsistency sets the ConsistencyHeader token for the session's current
+// version; call it before writing the response
+func (h *AdminHandler) setConsistency(w http.ResponseWriter, sess *session.Session) {
+	w.Header().Set(ConsistencyHeader, newConsistencyToken(sess))
+}
+
+// awaitConsistency holds a read until the store reflects the request's
+// ConsistencyHeader tokens; on failure it writes the error response and
+// returns false
+func (h *AdminHandler) awaitConsistency(w http.ResponseWriter, r *http.Request) bool {
+	err := awaitConsistency(r.Context(), h.manager, r.Header, h.consistencyWait)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrInvalidConsistencyToken):
+		h.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotConsistent):
+		w.Header().Set("Retry-After", "1")
+		h.writeError(w, http.StatusServiceUnavailable, err.Error())
+	}
+	return false // Or the caller went away
+}
+
+// writeJSON encodes v as the response body with the given status
+func (h *AdminHandler) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Printf("Failed to write admin response: %v", err)
+	}
+}
+
+// writeError sends a protocol error message with the given HTTP status
+func (h *AdminHandler) writeError(w http.ResponseWriter, status int, message string) {
+	h.writeJSON(w, status, NewErrorMessage("INVALID_REQUEST", message, true))
+}
@@ -0,0 +1,433 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+func newTestAdmin(t *testing.T) (*AdminHandler, *session.Manager) {
+	t.Helper()
+	idGen := &mockIDGenerator{}
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, idGen)
+
+	ctx := context.Background()
+	for _, role := range []string{"auth", "db", "tests"} {
+		idGen.id = "session-" + role
+		labels := map[string]string{"project": "foo", "role": role}
+		if _, err := manager.Create(ctx, role, &mockWebSocketConn{}, session.WithOwner("alice"), session.WithLabels(labels)); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	return NewAdminHandler(manager, &mockLogger{}), manager
+}
+
+func TestAdminHandler_ListSessions(t *testing.T) {
+	handler, _ := newTestAdmin(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/sessions?role=auth,db&sort=id&order=desc&limit=1", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp SessionListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Sessions) != 1 || resp.Sessions[0].ID != "session-db" {
+		t.Fatalf("expected session-db only, got %+v", resp.Sessions)
+	}
+	if resp.Sessions[0].OwnerID != "alice" || resp.Sessions[0].State != "CREATED" {
+		t.Errorf("unexpected view: %+v", resp.Sessions[0])
+	}
+	if resp.Sessions[0].CreatedAt != "2025-10-23T12:00:00Z" {
+		t.Errorf("expected RFC3339 createdAt, got %s", resp.Sessions[0].CreatedAt)
+	}
+	if resp.NextOffset == nil || *resp.NextOffset != 1 {
+		t.Errorf("expected nextOffset 1, got %v", resp.NextOffset)
+	}
+}
+
+func TestAdminHandler_ListSessions_ByLabel(t *testing.T) {
+	handler, _ := newTestAdmin(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/sessions?label=project=foo&label=role=tests", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp SessionListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Sessions) != 1 || resp.Sessions[0].ID != "session-tests" {
+		t.Fatalf("expected session-tests only, got %+v", resp.Sessions)
+	}
+	if resp.Sessions[0].Labels["project"] != "foo" {
+		t.Errorf("expected labels in view, got %v", resp.Sessions[0].Labels)
+	}
+}
+
+func TestAdminHandler_ListSessions_InvalidQuery(t *testing.T) {
+	handler, _ := newTestAdmin(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/sessions?limit=0", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	var msg ErrorMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &msg); err != nil {
+		t.Fatalf("failed to decode error: %v", err)
+	}
+	if msg.Type != "error" || msg.Error.Code != "INVALID_REQUEST" {
+		t.Errorf("unexpected error message: %+v", msg)
+	}
+}
+
+func TestParseSessionFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"all params", "state=active&role=auth&owner=bob&createdAfter=2025-10-23T00:00:00Z&createdBefore=2025-10-24T00:00:00Z&idleLongerThan=5m&sort=lastActive&order=asc&limit=10&offset=20", false},
+		{"labels", "label=project=foo&label=ticket=BUG-123", false},
+		{"bad label", "label=novalue", true},
+		{"bad state", "state=bogus", true},
+		{"bad time", "createdAfter=yesterday", true},
+		{"bad duration", "idleLongerThan=forever", true},
+		{"bad sort", "sort=name", true},
+		{"bad order", "order=sideways", true},
+		{"limit too large", "limit=100000", true},
+		{"negative offset", "offset=-1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			filter, err := parseSessionFilter(q)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if err == nil && filter.Limit == 0 {
+				t.Error("expected a default page size")
+			}
+		})
+	}
+}
+
+func TestAdminHandler_SearchHistory(t *testing.T) {
+	ctx := context.Background()
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"},
+		session.WithHistory(session.NewMemoryHistory(100)))
+	if _, err := manager.Create(ctx, "auth", &mockWebSocketConn{}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	_ = manager.BeginSpawn(ctx, "session-1")
+	_ = manager.AttachAgent(ctx, "session-1", "/tmp/worktree", &mockStreamingACPClient{})
+	if _, err := manager.SendMessage(ctx, "session-1", "use JWT for sessions"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	handler := NewAdminHandler(manager, &mockLogger{})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/history?q=jwt&speaker=user", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp HistorySearchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Matches) != 1 {
+		t.Fatalf("expected 1 match, got %+v", resp.Matches)
+	}
+	m := resp.Matches[0]
+	if m.SessionID != "session-1" || m.Speaker != "user" || m.Snippet != "use JWT for sessions" || m.Time != "2025-10-23T12:00:00Z" {
+		t.Errorf("unexpected match: %+v", m)
+	}
+}
+
+func TestAdminHandler_SearchHistory_Errors(t *testing.T) {
+	handler, _ := newTestAdmin(t)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusBadRequest},
+		{"q=x&speaker=robot", http.StatusBadRequest},
+		{"q=x", http.StatusNotFound}, // History not enabled
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/admin/history?"+tt.query, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%q: expected %d, got %d", tt.query, tt.want, rec.Code)
+		}
+	}
+}
+
+func TestAdminHandler_ExportImport(t *testing.T) {
+	ctx := context.Background()
+	source := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-src"})
+	worktree := t.TempDir()
+	if err := os.WriteFile(filepath.Join(worktree, "notes.md"), []byte("todo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Create(ctx, "auth", &mockWebSocketConn{}, session.WithName("login")); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.BeginSpawn(ctx, "session-src"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.AttachAgent(ctx, "session-src", worktree, &mockStreamingACPClient{}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	NewAdminHandler(source, &mockLogger{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/sessions/session-src/export", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("expected gzip archive, got %d %s: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	target := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-dst"})
+	factory := &mockAgentFactory{}
+	admin := NewAdminHandler(target, &mockLogger{},
+		WithAdminImport(NewSpawner(target, factory, nil, &mockLogger{})))
+	workspace := filepath.Join(t.TempDir(), "imported")
+	importReq := httptest.NewRequest(http.MethodPost, "/admin/sessions/import?workspace="+url.QueryEscape(workspace), rec.Body)
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, importReq)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var view SessionView
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatal(err)
+	}
+	if view.ID != "session-dst" || view.Name != "login" || view.State != "ACTIVE" || view.WorktreeDir != workspace {
+		t.Errorf("unexpected imported session: %+v", view)
+	}
+	if factory.clients["auth"] == nil {
+		t.Error("expected a fresh agent to be started")
+	}
+	if data, err := os.ReadFile(filepath.Join(workspace, "notes.md")); err != nil || string(data) != "todo" { // #nosec G304 -- test path
+		t.Errorf("expected workspace restored, got %q (%v)", data, err)
+	}
+
+	// The imported session waits detached for a client to claim it
+	conn := &mockWebSocketConn{}
+	if _, err := NewAgentStreamer(target, &mockClock{now: testTime}, &mockLogger{}).Reattach(ctx, "session-dst", conn); err != nil {
+		t.Errorf("expected reattach to succeed, got %v", err)
+	}
+}
+
+func TestAdminHandler_Import(t *testing.T) {
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "s"})
+	spawner := NewSpawner(manager, &mockAgentFactory{}, nil, &mockLogger{})
+
+	tests := []struct {
+		name   string
+		admin  *AdminHandler
+		target string
+		body   string
+		status int
+	}{
+		{"disabled", NewAdminHandler(manager, &mockLogger{}), "/admin/sessions/import?workspace=/tmp/x", "", http.StatusNotFound},
+		{"missing workspace", NewAdminHandler(manager, &mockLogger{}, WithAdminImport(spawner)), "/admin/sessions/import", "", http.StatusBadRequest},
+		{"not an archive", NewAdminHandler(manager, &mockLogger{}, WithAdminImport(spawner)),
+			"/admin/sessions/import?workspace=" + url.QueryEscape(filepath.Join(t.TempDir(), "ws")), "junk", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ExportUnknownSession(t *testing.T) {
+	handler, _ := newTestAdmin(t)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/sessions/missing/export", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestAdminHandler_ReplaceAgent(t *testing.T) {
+	ctx := context.Background()
+	idGen := &mockIDGenerator{id: "active"}
+	started := 0
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, idGen,
+		session.WithAgentStarter(func(context.Context, string, string) (session.ACPClient, error) {
+			started++
+			return &mockClosableACPClient{}, nil
+		}))
+	active, err := manager.Create(ctx, "auth", &mockWebSocketConn{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, active.GetID()); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, active.GetID(), "/tmp/ws", &mockClosableACPClient{}); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+	idGen.id = "created"
+	if _, err := manager.Create(ctx, "db", &mockWebSocketConn{}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	disabled, _ := newTestAdmin(t)
+
+	tests := []struct {
+		name   string
+		admin  *AdminHandler
+		target string
+		status int
+	}{
+		{"replaced", NewAdminHandler(manager, &mockLogger{}), "/admin/sessions/active/replace-agent", http.StatusOK},
+		{"unknown session", NewAdminHandler(manager, &mockLogger{}), "/admin/sessions/missing/replace-agent", http.StatusNotFound},
+		{"not active", NewAdminHandler(manager, &mockLogger{}), "/admin/sessions/created/replace-agent", http.StatusConflict},
+		{"bad replay", NewAdminHandler(manager, &mockLogger{}), "/admin/sessions/active/replace-agent?replay=x", http.StatusBadRequest},
+		{"history disabled", NewAdminHandler(manager, &mockLogger{}), "/admin/sessions/active/replace-agent?replay=5", http.StatusNotImplemented},
+		{"disabled", disabled, "/admin/sessions/session-auth/replace-agent", http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+	if started != 1 {
+		t.Errorf("expected one replacement agent started, got %d", started)
+	}
+}
+
+func TestAdminHandler_Stats(t *testing.T) {
+	handler, _ := newTestAdmin(t)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var stats StatsView
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stats.Sessions != 3 || stats.ByState["CREATED"] != 3 || len(stats.AgentsByState) != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestAdminHandler_TransferSession(t *testing.T) {
+	handler, manager := newTestAdmin(t)
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"missing to", "/admin/sessions/session-auth/transfer", http.StatusBadRequest},
+		{"unknown session", "/admin/sessions/missing/transfer?to=bob", http.StatusNotFound},
+		{"stale owner", "/admin/sessions/session-auth/transfer?to=bob&from=carol", http.StatusConflict},
+		{"bad keepAccess", "/admin/sessions/session-auth/transfer?to=bob&keepAccess=maybe", http.StatusBadRequest},
+		{"transfer", "/admin/sessions/session-auth/transfer?to=bob&keepAccess=true", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	sess := manager.Get("session-auth")
+	if sess.GetOwnerID() != "bob" || !sess.CanAccess("alice") {
+		t.Errorf("expected bob to own it with alice kept on, got owner=%s collaborators=%v", sess.GetOwnerID(), sess.GetCollaborators())
+	}
+}
+
+func TestAdminHandler_TerminateSessions(t *testing.T) {
+	handler, manager := newTestAdmin(t)
+	post := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+		return rec
+	}
+
+	if rec := post("/admin/sessions/terminate"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a filter, got %d", rec.Code)
+	}
+	if rec := post("/admin/sessions/terminate?role=auth&parallelism=0"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid parallelism, got %d", rec.Code)
+	}
+
+	rec := post("/admin/sessions/terminate?role=auth,db&parallelism=2")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp TerminateResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Matched != 2 || len(resp.Terminated) != 2 || len(resp.Errors) != 0 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if manager.Count() != 1 || manager.Get("session-tests") == nil {
+		t.Errorf("expected only the tests session left, %d sessions", manager.Count())
+	}
+
+	if rec := post("/admin/sessions/terminate?all=true"); rec.Code != http.StatusOK || manager.Count() != 0 {
+		t.Errorf("expected all=true to terminate every session, got %d with %d left", rec.Code, manager.Count())
+	}
+}
+
+func TestAdminHandler_PurgeOwner(t *testing.T) {
+	handler, manager := newTestAdmin(t)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/owners/alice/purge", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var report session.PurgeReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.OwnerID != "alice" || len(report.Sessions) != 3 || len(report.Errors) != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if manager.Count() != 0 {
+		t.Errorf("expected every session purged, %d left", manager.Count())
+	}
+}
@@ -0,0 +1,213 @@
+package relay
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Admin request signature headers; the timestamp and signature headers are
+// shared with federation
+const (
+	adminKeyIDHeader     = "X-Ourocodus-Key-Id"
+	adminNonceHeader     = "X-Ourocodus-Nonce"
+	adminTimestampHeader = federationTimestampHeader
+	adminSignatureHeader = federationSignatureHeader
+)
+
+// adminMaxSkew bounds how old (or early) a signed admin request may be
+const adminMaxSkew = 5 * time.Minute
+
+// maxAdminSignedBody caps the admin request bodies read to verify a signature
+const maxAdminSignedBody = 16 << 20
+
+// adminNonceTTL is how long a used nonce is remembered: a request signed
+// adminMaxSkew early stays fresh until adminMaxSkew after its timestamp
+const adminNonceTTL = 2 * adminMaxSkew
+
+// maxAdminNonces caps the nonces remembered for replay protection; requests
+// beyond it are refused until older nonces expire
+const maxAdminNonces = 100000
+
+// MetricAdminRejected counts admin requests refused for a bad signature
+const MetricAdminRejected = "relay_admin_rejected_total"
+
+// AdminKey is a named key admin requests are signed with
+type AdminKey struct {
+	ID  string
+	Key []byte
+}
+
+// AdminVerifier checks signed admin API requests
+// A request carries a key ID, a Unix timestamp, a random nonce and the hex
+// HMAC-SHA256, under the named key, of its method, path and query, timestamp,
+// nonce and body digest (see SignAdminRequest). Requests more than five
+// minutes old or early are refused, as is a nonce seen before under the same
+// key. Every configured key is accepted, so keys are rotated by adding the
+// new one, moving clients over and then removing the old one.
+type AdminVerifier struct {
+	keys    map[string][]byte
+	clock   Clock
+	metrics Metrics
+
+	mu     sync.Mutex
+	nonces map[string]struct{} // Key ID and nonce of recent requests
+	queue  []usedNonce         // The same nonces, oldest first
+}
+
+// usedNonce is a remembered nonce and when it may be forgotten
+type usedNonce struct {
+	nonce   string
+	expires time.Time
+}
+
+// AdminVerifierOption configures an AdminVerifier
+type AdminVerifierOption func(*AdminVerifier)
+
+// WithAdminVerifierMetrics records rejected admin requests
+func WithAdminVerifierMetrics(metrics Metrics) AdminVerifierOption {
+	return func(v *AdminVerifier) { v.metrics = metrics }
+}
+
+// NewAdminVerifier creates a verifier accepting requests signed with any of keys
+func NewAdminVerifier(keys []AdminKey, clock Clock, opts ...AdminVerifierOption) (*AdminVerifier, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("admin verifier needs at least one key")
+	}
+	v := &AdminVerifier{
+		keys:    make(map[string][]byte, len(keys)),
+		clock:   clock,
+		metrics: &NoOpMetrics{},
+		nonces:  make(map[string]struct{}),
+	}
+	for _, key := range keys {
+		if key.ID == "" || len(key.Key) == 0 {
+			return nil, errors.New("admin keys need an ID and key")
+		}
+		if _, dup := v.keys[key.ID]; dup {
+			return nil, fmt.Errorf("duplicate admin key ID %q", key.ID)
+		}
+		v.keys[key.ID] = key.Key
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v, nil
+}
+
+// WithAdminSigning requires every admin request to be signed with a key
+// verifier accepts; unsigned or invalid requests get 401
+func WithAdminSigning(verifier *AdminVerifier) AdminOption {
+	return func(h *AdminHandler) {
+		h.verifier = verifier
+	}
+}
+
+// Verify checks r's signature, freshness and nonce
+// The body is read and replaced, so handlers can still read it.
+func (v *AdminVerifier) Verify(r *http.Request) error {
+	keyID := r.Header.Get(adminKeyIDHeader)
+	key, ok := v.keys[keyID]
+	if !ok {
+		return fmt.Errorf("unknown key ID %q", keyID)
+	}
+	timestamp := r.Header.Get(adminTimestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	now := v.clock.Now()
+	if skew := now.Sub(time.Unix(unix, 0)); skew > adminMaxSkew || skew < -adminMaxSkew {
+		return fmt.Errorf("timestamp off by %v", skew)
+	}
+	nonce := r.Header.Get(adminNonceHeader)
+	if nonce == "" || len(nonce) > 128 {
+		return errors.New("missing or oversized nonce")
+	}
+
+	body, err := readAdminBody(r)
+	if err != nil {
+		return err
+	}
+	want := signAdmin(key, r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	if !hmac.Equal([]byte(r.Header.Get(adminSignatureHeader)), []byte(want)) {
+		return errors.New("signature mismatch")
+	}
+	return v.useNonce(keyID+"\n"+nonce, now)
+}
+
+// useNonce records a nonce for adminNonceTTL, failing if it was already used
+// Nonces are queued in the order they were used, so expiring them only looks
+// at the oldest.
+func (v *AdminVerifier) useNonce(nonce string, now time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for len(v.queue) > 0 && !v.queue[0].expires.After(now) {
+		delete(v.nonces, v.queue[0].nonce)
+		v.queue[0] = usedNonce{}
+		v.queue = v.queue[1:]
+	}
+	if _, seen := v.nonces[nonce]; seen {
+		return errors.New("nonce already used")
+	}
+	if len(v.nonces) >= maxAdminNonces {
+		return errors.New("too many recent nonces")
+	}
+	v.nonces[nonce] = struct{}{}
+	v.queue = append(v.queue, usedNonce{nonce: nonce, expires: now.Add(adminNonceTTL)})
+	return nil
+}
+
+// SignAdminRequest signs r for an AdminVerifier with key at now
+// The body is read and replaced, so r can still be sent.
+func SignAdminRequest(r *http.Request, key AdminKey, now time.Time) error {
+	body, err := readAdminBody(r)
+	if err != nil {
+		return err
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(raw)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	r.Header.Set(adminKeyIDHeader, key.ID)
+	r.Header.Set(adminTimestampHeader, timestamp)
+	r.Header.Set(adminNonceHeader, nonce)
+	r.Header.Set(adminSignatureHeader, signAdmin(key.Key, r.Method, r.URL.RequestURI(), timestamp, nonce, body))
+	return nil
+}
+
+// readAdminBody reads r's body and puts an unread copy back
+func readAdminBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAdminSignedBody+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unreadable body: %w", err)
+	}
+	if len(body) > maxAdminSignedBody {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxAdminSignedBody)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// signAdmin returns the hex HMAC-SHA256 of an admin request (pure function)
+func signAdmin(key []byte, method, requestURI, timestamp, nonce string, body []byte) string {
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(digest[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
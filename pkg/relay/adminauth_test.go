@@ -0,0 +1,119 @@
+package relay
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAdminVerifier(t *testing.T, clock Clock, keys ...AdminKey) *AdminVerifier {
+	t.Helper()
+	verifier, err := NewAdminVerifier(keys, clock)
+	if err != nil {
+		t.Fatalf("NewAdminVerifier failed: %v", err)
+	}
+	return verifier
+}
+
+func TestAdminVerifier_Verify(t *testing.T) {
+	current := AdminKey{ID: "2026-10", Key: bytes.Repeat([]byte("c"), 32)}
+	previous := AdminKey{ID: "2026-07", Key: bytes.Repeat([]byte("p"), 32)}
+	retired := AdminKey{ID: "2026-04", Key: bytes.Repeat([]byte("r"), 32)}
+	clock := &mockClock{now: testTime}
+	verifier := newTestAdminVerifier(t, clock, current, previous)
+
+	signed := func(key AdminKey, signedAt time.Time, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "/admin/schedules/nightly?force=true", strings.NewReader(body))
+		if err := SignAdminRequest(r, key, signedAt); err != nil {
+			t.Fatalf("SignAdminRequest failed: %v", err)
+		}
+		return r
+	}
+
+	r := signed(current, testTime, `{"cron":"0 3 * * *"}`)
+	if err := verifier.Verify(r); err != nil {
+		t.Fatalf("expected a valid request, got %v", err)
+	}
+	if body, _ := io.ReadAll(r.Body); string(body) != `{"cron":"0 3 * * *"}` {
+		t.Errorf("expected the body left for the handler, got %q", body)
+	}
+	if err := verifier.Verify(signed(previous, testTime, "")); err != nil {
+		t.Errorf("expected the previous key accepted during rotation, got %v", err)
+	}
+
+	replayed := signed(current, testTime, "{}")
+	if err := verifier.Verify(replayed); err != nil {
+		t.Fatalf("expected a valid request, got %v", err)
+	}
+	replayed.Body = io.NopCloser(strings.NewReader("{}"))
+
+	tampered := signed(current, testTime, "{}")
+	tampered.Body = io.NopCloser(strings.NewReader(`{"cron":"* * * * *"}`))
+	otherPath := signed(current, testTime, "{}")
+	otherPath.URL.RawQuery = "force=false"
+	unsigned := httptest.NewRequest(http.MethodGet, "/admin/sessions", nil)
+
+	for name, r := range map[string]*http.Request{
+		"replayed":      replayed,
+		"tampered body": tampered,
+		"other query":   otherPath,
+		"stale":         signed(current, testTime.Add(-6*time.Minute), ""),
+		"early":         signed(current, testTime.Add(6*time.Minute), ""),
+		"retired key":   signed(retired, testTime, ""),
+		"unsigned":      unsigned,
+	} {
+		if err := verifier.Verify(r); err == nil {
+			t.Errorf("%s: expected the request refused", name)
+		}
+	}
+
+	// Once the timestamp window has passed the nonce is forgotten, and the
+	// request is refused as stale instead
+	clock.now = testTime.Add(adminNonceTTL)
+	if err := verifier.Verify(signed(current, clock.now, "")); err != nil {
+		t.Fatalf("expected a valid request, got %v", err)
+	}
+	if len(verifier.nonces) != 1 || len(verifier.queue) != 1 {
+		t.Errorf("expected expired nonces pruned, got %d", len(verifier.nonces))
+	}
+}
+
+func TestNewAdminVerifier_RejectsBadKeys(t *testing.T) {
+	key := []byte("k")
+	for name, keys := range map[string][]AdminKey{
+		"none":      nil,
+		"no ID":     {{Key: key}},
+		"no key":    {{ID: "a"}},
+		"duplicate": {{ID: "a", Key: key}, {ID: "a", Key: key}},
+	} {
+		if _, err := NewAdminVerifier(keys, &mockClock{now: testTime}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAdminHandler_Signing(t *testing.T) {
+	_, manager := newTestAdmin(t)
+	key := AdminKey{ID: "ops", Key: bytes.Repeat([]byte("k"), 32)}
+	handler := NewAdminHandler(manager, &mockLogger{}, WithAdminSigning(newTestAdminVerifier(t, &mockClock{now: testTime}, key)))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/sessions", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unsigned request, got %d", rec.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/sessions?role=auth", nil)
+	if err := SignAdminRequest(r, key, testTime); err != nil {
+		t.Fatalf("SignAdminRequest failed: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for a signed request, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,80 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// Agent request counters recorded by MetricsMiddleware
+const (
+	MetricAgentRequests        = "relay_agent_requests_total"
+	MetricAgentRequestFailures = "relay_agent_request_failures_total"
+)
+
+// MetricsMiddleware counts agent requests and failures
+// Install it with session.WithMiddleware when creating the session manager
+func MetricsMiddleware(metrics Metrics) session.Middleware {
+	return func(next session.SendFunc) session.SendFunc {
+		return func(ctx context.Context, req session.AgentRequest) (*acp.AgentMessage, error) {
+			metrics.IncCounter(MetricAgentRequests)
+			msg, err := next(ctx, req)
+			if err != nil {
+				metrics.IncCounter(MetricAgentRequestFailures)
+			}
+			return msg, err
+		}
+	}
+}
+
+// Ways TraceMiddleware can hand trace IDs to agents
+const (
+	TraceHeader = "header" // Prepend a hidden header to the prompt
+	TraceParams = "params" // Send them as agent/sendMessage params metadata
+)
+
+// TraceMiddleware passes the session and correlation IDs of each prompt to the
+// agent so its logs can be matched with the relay's
+// mode is TraceHeader or TraceParams. Install it innermost so transcripts,
+// journals and the event sink record the prompt as the client sent it.
+// Params metadata reaches only clients implementing
+// session.InterruptibleACPClient, as the ACP client does.
+func TraceMiddleware(mode string) session.Middleware {
+	return func(next session.SendFunc) session.SendFunc {
+		return func(ctx context.Context, req session.AgentRequest) (*acp.AgentMessage, error) {
+			switch mode {
+			case TraceHeader:
+				req.Content = traceHeader(req) + req.Content
+			case TraceParams:
+				ctx = acp.WithMetadata(ctx, traceMetadata(ctx, req))
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// traceHeader renders a request's trace IDs as an HTML comment line, which
+// markdown renderers hide (pure function)
+func traceHeader(req session.AgentRequest) string {
+	header := "<!-- relay-trace session=" + req.SessionID
+	if req.CorrelationID != "" {
+		header += " correlation=" + req.CorrelationID
+	}
+	return header + " -->\n"
+}
+
+// traceMetadata adds a request's trace IDs to any metadata already on ctx
+// without modifying it
+func traceMetadata(ctx context.Context, req session.AgentRequest) map[string]string {
+	md := map[string]string{"sessionId": req.SessionID}
+	if req.CorrelationID != "" {
+		md["correlationId"] = req.CorrelationID
+	}
+	for k, v := range acp.MetadataFromContext(ctx) {
+		if _, ok := md[k]; !ok {
+			md[k] = v
+		}
+	}
+	return md
+}
@@ -0,0 +1,77 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	metrics := NewCounterMetrics()
+	fail := true
+	send := MetricsMiddleware(metrics)(func(ctx context.Context, req session.AgentRequest) (*acp.AgentMessage, error) {
+		if fail {
+			return nil, errors.New("agent crashed")
+		}
+		return &acp.AgentMessage{Type: "text"}, nil
+	})
+
+	_, _ = send(context.Background(), session.AgentRequest{SessionID: "s1"})
+	fail = false
+	_, _ = send(context.Background(), session.AgentRequest{SessionID: "s1"})
+
+	if got := metrics.Value(MetricAgentRequests); got != 2 {
+		t.Errorf("expected 2 requests, got %d", got)
+	}
+	if got := metrics.Value(MetricAgentRequestFailures); got != 1 {
+		t.Errorf("expected 1 failure, got %d", got)
+	}
+}
+
+func TestTraceMiddleware_Header(t *testing.T) {
+	var got session.AgentRequest
+	send := TraceMiddleware(TraceHeader)(func(ctx context.Context, req session.AgentRequest) (*acp.AgentMessage, error) {
+		got = req
+		if md := acp.MetadataFromContext(ctx); md != nil {
+			t.Errorf("expected no params metadata in header mode, got %v", md)
+		}
+		return &acp.AgentMessage{Type: "text"}, nil
+	})
+
+	_, _ = send(context.Background(), session.AgentRequest{SessionID: "s1", CorrelationID: "c1", Content: "hello"})
+	if want := "<!-- relay-trace session=s1 correlation=c1 -->\nhello"; got.Content != want {
+		t.Errorf("expected %q, got %q", want, got.Content)
+	}
+
+	_, _ = send(context.Background(), session.AgentRequest{SessionID: "s1", Content: "hello"})
+	if want := "<!-- relay-trace session=s1 -->\nhello"; got.Content != want {
+		t.Errorf("expected %q without a correlation ID, got %q", want, got.Content)
+	}
+}
+
+func TestTraceMiddleware_Params(t *testing.T) {
+	var md map[string]string
+	var content string
+	send := TraceMiddleware(TraceParams)(func(ctx context.Context, req session.AgentRequest) (*acp.AgentMessage, error) {
+		md = acp.MetadataFromContext(ctx)
+		content = req.Content
+		return &acp.AgentMessage{Type: "text"}, nil
+	})
+
+	existing := map[string]string{"tenant": "acme"}
+	ctx := acp.WithMetadata(context.Background(), existing)
+	_, _ = send(ctx, session.AgentRequest{SessionID: "s1", CorrelationID: "c1", Content: "hello"})
+
+	if content != "hello" {
+		t.Errorf("expected the prompt unchanged, got %q", content)
+	}
+	if md["sessionId"] != "s1" || md["correlationId"] != "c1" || md["tenant"] != "acme" {
+		t.Errorf("expected trace IDs alongside existing metadata, got %v", md)
+	}
+	if len(existing) != 1 {
+		t.Errorf("expected the caller's metadata untouched, got %v", existing)
+	}
+}
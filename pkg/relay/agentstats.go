@@ -0,0 +1,191 @@
+package relay
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// latencyWindow is how many of the most recent agent latencies each session
+// and role keeps for its percentile
+const latencyWindow = 512
+
+// AgentStats aggregates agent request counts, latencies, token usage and
+// errors per session and per role, for GET /api/v1/stats
+// Install Middleware on the session manager and subscribe HandleLifecycle to
+// its events; sessions are forgotten once cleaned up, while role totals
+// cover everything since the relay started. Token usage counts only agents
+// that report it (acp.AgentMessage.Usage). Safe for concurrent use.
+type AgentStats struct {
+	clock Clock
+
+	mu       sync.Mutex
+	sessions map[string]*requestStats // By session ID
+	roles    map[string]*requestStats // By agent role
+}
+
+// requestStats accumulates the requests of one session or role
+type requestStats struct {
+	role         string
+	requests     int64
+	errors       int64
+	totalLatency time.Duration
+	latencies    []time.Duration // Ring of the most recent latencyWindow
+	next         int             // Ring position of the next latency
+	inputTokens  int64
+	outputTokens int64
+}
+
+// NewAgentStats creates an empty collector timing requests with clock
+func NewAgentStats(clock Clock) *AgentStats {
+	return &AgentStats{
+		clock:    clock,
+		sessions: make(map[string]*requestStats),
+		roles:    make(map[string]*requestStats),
+	}
+}
+
+// Middleware times each prompt and records its outcome
+func (s *AgentStats) Middleware() session.Middleware {
+	return func(next session.SendFunc) session.SendFunc {
+		return func(ctx context.Context, req session.AgentRequest) (*acp.AgentMessage, error) {
+			start := s.clock.Monotonic()
+			msg, err := next(ctx, req)
+			s.record(req.SessionID, req.AgentID, s.clock.Monotonic()-start, msg, err)
+			return msg, err
+		}
+	}
+}
+
+// HandleLifecycle forgets the stats of sessions that have been cleaned up
+func (s *AgentStats) HandleLifecycle(event session.LifecycleEvent) {
+	if event.To != session.StateCleaned {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, event.SessionID)
+}
+
+// record adds one request to its session's and role's stats
+func (s *AgentStats) record(sessionID, role string, latency time.Duration, msg *acp.AgentMessage, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stats := range []*requestStats{statsFor(s.sessions, sessionID, role), statsFor(s.roles, role, role)} {
+		stats.add(latency, msg, err)
+	}
+}
+
+// statsFor returns the stats stored under key, creating them if missing
+func statsFor(m map[string]*requestStats, key, role string) *requestStats {
+	stats, ok := m[key]
+	if !ok {
+		stats = &requestStats{role: role}
+		m[key] = stats
+	}
+	return stats
+}
+
+// add records one request
+func (r *requestStats) add(latency time.Duration, msg *acp.AgentMessage, err error) {
+	r.requests++
+	if err != nil {
+		r.errors++
+	}
+	r.totalLatency += latency
+	if len(r.latencies) < latencyWindow {
+		r.latencies = append(r.latencies, latency)
+	} else {
+		r.latencies[r.next] = latency
+	}
+	r.next = (r.next + 1) % latencyWindow
+	if msg != nil && msg.Usage != nil {
+		r.inputTokens += msg.Usage.InputTokens
+		r.outputTokens += msg.Usage.OutputTokens
+	}
+}
+
+// AgentStatsView is returned by GET /api/v1/stats
+// Sessions and roles are sorted by ID and name.
+type AgentStatsView struct {
+	Roles    []RoleStatsView    `json:"roles"`
+	Sessions []SessionStatsView `json:"sessions"`
+}
+
+// RoleStatsView is the stats of every session an agent role has had
+type RoleStatsView struct {
+	Role string `json:"role"`
+	RequestStatsView
+}
+
+// SessionStatsView is the stats of one live session
+type SessionStatsView struct {
+	SessionID string `json:"sessionId"`
+	Role      string `json:"role"`
+	RequestStatsView
+}
+
+// RequestStatsView summarizes a set of agent requests
+// The mean covers every request; the 95th percentile only the most recent.
+type RequestStatsView struct {
+	Requests      int64   `json:"requests"`
+	Errors        int64   `json:"errors"`
+	ErrorRate     float64 `json:"errorRate"`
+	MeanLatencyMs float64 `json:"meanLatencyMs"`
+	P95LatencyMs  float64 `json:"p95LatencyMs"`
+	InputTokens   int64   `json:"inputTokens"`
+	OutputTokens  int64   `json:"outputTokens"`
+}
+
+// View returns a snapshot of the stats for serialization
+func (s *AgentStats) View() AgentStatsView {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	view := AgentStatsView{
+		Roles:    make([]RoleStatsView, 0, len(s.roles)),
+		Sessions: make([]SessionStatsView, 0, len(s.sessions)),
+	}
+	for role, stats := range s.roles {
+		view.Roles = append(view.Roles, RoleStatsView{Role: role, RequestStatsView: stats.view()})
+	}
+	for id, stats := range s.sessions {
+		view.Sessions = append(view.Sessions, SessionStatsView{SessionID: id, Role: stats.role, RequestStatsView: stats.view()})
+	}
+	sort.Slice(view.Roles, func(i, j int) bool { return view.Roles[i].Role < view.Roles[j].Role })
+	sort.Slice(view.Sessions, func(i, j int) bool { return view.Sessions[i].SessionID < view.Sessions[j].SessionID })
+	return view
+}
+
+// view summarizes the stats
+func (r *requestStats) view() RequestStatsView {
+	v := RequestStatsView{Requests: r.requests, Errors: r.errors, InputTokens: r.inputTokens, OutputTokens: r.outputTokens}
+	if r.requests > 0 {
+		v.ErrorRate = float64(r.errors) / float64(r.requests)
+		v.MeanLatencyMs = milliseconds(r.totalLatency / time.Duration(r.requests))
+		v.P95LatencyMs = milliseconds(percentile(r.latencies, 0.95))
+	}
+	return v
+}
+
+// percentile returns the nearest-rank p-th percentile of latencies, 0 if
+// there are none (pure function)
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+// milliseconds converts d to fractional milliseconds (pure function)
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
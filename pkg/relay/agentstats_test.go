@@ -0,0 +1,107 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+func TestAgentStats(t *testing.T) {
+	clock := &mockClock{now: testTime}
+	stats := NewAgentStats(clock)
+	// Each request takes as many milliseconds as its prompt says and fails if
+	// it says "fail"
+	send := stats.Middleware()(func(_ context.Context, req session.AgentRequest) (*acp.AgentMessage, error) {
+		if req.Content == "fail" {
+			clock.mono += time.Second
+			return nil, errors.New("agent crashed")
+		}
+		d, _ := time.ParseDuration(req.Content)
+		clock.mono += d
+		return &acp.AgentMessage{Type: "text", Usage: &acp.Usage{InputTokens: 10, OutputTokens: 2}}, nil
+	})
+
+	for _, req := range []session.AgentRequest{
+		{SessionID: "s1", AgentID: "auth", Content: "100ms"},
+		{SessionID: "s1", AgentID: "auth", Content: "300ms"},
+		{SessionID: "s1", AgentID: "auth", Content: "fail"},
+		{SessionID: "s2", AgentID: "db", Content: "20ms"},
+		{SessionID: "s3", AgentID: "auth", Content: "200ms"},
+	} {
+		_, _ = send(context.Background(), req)
+	}
+
+	view := stats.View()
+	if len(view.Roles) != 2 || view.Roles[0].Role != "auth" || view.Roles[1].Role != "db" {
+		t.Fatalf("expected auth and db roles, got %+v", view.Roles)
+	}
+	auth := view.Roles[0].RequestStatsView
+	want := RequestStatsView{Requests: 4, Errors: 1, ErrorRate: 0.25, MeanLatencyMs: 400, P95LatencyMs: 1000, InputTokens: 30, OutputTokens: 6}
+	if auth != want {
+		t.Errorf("expected auth stats %+v, got %+v", want, auth)
+	}
+	if len(view.Sessions) != 3 || view.Sessions[0].SessionID != "s1" || view.Sessions[0].Role != "auth" || view.Sessions[0].Requests != 3 {
+		t.Errorf("unexpected session stats: %+v", view.Sessions)
+	}
+
+	// Cleaned up sessions are dropped; role totals stay
+	stats.HandleLifecycle(session.LifecycleEvent{SessionID: "s1", From: session.StateTerminating, To: session.StateCleaned})
+	view = stats.View()
+	if len(view.Sessions) != 2 || view.Sessions[0].SessionID != "s2" || view.Roles[0].Requests != 4 {
+		t.Errorf("expected s1 to be forgotten but counted in its role, got %+v", view)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 0, 20)
+	for i := 20; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		latencies []time.Duration
+		p         float64
+		want      time.Duration
+	}{
+		{nil, 0.95, 0},
+		{latencies[:1], 0.95, 20 * time.Millisecond},
+		{latencies, 0.95, 19 * time.Millisecond},
+		{latencies, 0.5, 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.latencies, tt.p); got != tt.want {
+			t.Errorf("percentile(%d latencies, %v) = %v, want %v", len(tt.latencies), tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestAdminHandler_AgentStats(t *testing.T) {
+	_, manager := newTestAdmin(t)
+	stats := NewAgentStats(&mockClock{now: testTime})
+	stats.record("session-auth", "auth", 50*time.Millisecond, nil, nil)
+
+	rec := httptest.NewRecorder()
+	NewAdminHandler(manager, &mockLogger{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without WithAdminAgentStats, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	NewAdminHandler(manager, &mockLogger{}, WithAdminAgentStats(stats)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var view AgentStatsView
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(view.Sessions) != 1 || view.Sessions[0].SessionID != "session-auth" || view.Sessions[0].MeanLatencyMs != 50 {
+		t.Errorf("unexpected stats: %+v", view)
+	}
+}
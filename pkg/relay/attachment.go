@@ -0,0 +1,127 @@
+package relay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Binary frame policies
+const (
+	BinaryReject      = "reject"      // Binary frames are answered with BINARY_NOT_SUPPORTED
+	BinaryAttachments = "attachments" // Binary frames are stored as attachments
+)
+
+var (
+	// ErrAttachmentTooLarge is returned when a binary frame exceeds the size limit
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+
+	// ErrAttachmentNotFound is returned when an attachment ID is unknown or evicted
+	ErrAttachmentNotFound = errors.New("attachment not found")
+)
+
+// Attachment describes a stored binary payload
+type Attachment struct {
+	ID     string `json:"id"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// AttachmentStore keeps binary frames so later messages can refer to them by ID
+type AttachmentStore interface {
+	Put(data []byte) (Attachment, error)
+	Get(id string) ([]byte, error)
+}
+
+// MemoryAttachmentStore is a bounded in-memory AttachmentStore
+// When full, the oldest attachment is evicted
+type MemoryAttachmentStore struct {
+	idGen    IDGenerator
+	data     map[string][]byte
+	order    []string // IDs oldest first
+	maxBytes int      // Per attachment
+	maxCount int
+	mu       sync.Mutex
+}
+
+// NewMemoryAttachmentStore keeps up to maxCount attachments of at most maxBytes each
+func NewMemoryAttachmentStore(idGen IDGenerator, maxBytes, maxCount int) *MemoryAttachmentStore {
+	if idGen == nil {
+		panic("idGen cannot be nil")
+	}
+	if maxCount < 1 {
+		maxCount = 1
+	}
+	return &MemoryAttachmentStore{
+		idGen:    idGen,
+		data:     make(map[string][]byte),
+		maxBytes: maxBytes,
+		maxCount: maxCount,
+	}
+}
+
+// Put copies data into the store and returns its descriptor
+func (s *MemoryAttachmentStore) Put(data []byte) (Attachment, error) {
+	if len(data) > s.maxBytes {
+		return Attachment{}, fmt.Errorf("%w: %d bytes (max %d)", ErrAttachmentTooLarge, len(data), s.maxBytes)
+	}
+
+	sum := sha256.Sum256(data)
+	att := Attachment{
+		ID:     s.idGen.Generate(),
+		SHA256: hex.EncodeToString(sum[:]),
+		Size:   len(data),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.order) >= s.maxCount {
+		delete(s.data, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.data[att.ID] = append([]byte(nil), data...) // Caller may reuse the read buffer
+	s.order = append(s.order, att.ID)
+	return att, nil
+}
+
+// Get returns the stored bytes for an attachment ID
+func (s *MemoryAttachmentStore) Get(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.data[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, id)
+	}
+	return data, nil
+}
+
+// handleBinary applies the binary frame policy
+// Without an attachment store, binary frames are protocol violations
+// Returns true if connection should be closed
+func (s *Server) handleBinary(conn WebSocketConn, data []byte) bool {
+	if s.attachments == nil {
+		return s.handleValidationError(conn, ValidationError{
+			Code:        "BINARY_NOT_SUPPORTED",
+			Message:     "binary frames are not accepted; send JSON text frames",
+			Recoverable: true,
+		})
+	}
+
+	att, err := s.attachments.Put(data)
+	if err != nil {
+		return s.handleValidationError(conn, ValidationError{
+			Code:        "ATTACHMENT_REJECTED",
+			Message:     err.Error(),
+			Recoverable: true,
+		})
+	}
+
+	s.logger.Printf("Stored attachment %s (%d bytes)", att.ID, att.Size)
+	if err := conn.WriteJSON(NewAttachmentStoredMessage(att, FormatTimestamp(s.clock.Now()))); err != nil {
+		s.logger.Printf("Failed to send attachment receipt: %v", err)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package relay
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMemoryAttachmentStore_PutGet(t *testing.T) {
+	store := NewMemoryAttachmentStore(&sequentialIDGenerator{}, 16, 2)
+
+	buf := []byte("hello")
+	att, err := store.Put(buf)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if att.Size != 5 || att.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("unexpected attachment: %+v", att)
+	}
+
+	buf[0] = 'j' // Stored copy must not alias the read buffer
+	data, err := store.Get(att.ID)
+	if err != nil || string(data) != "hello" {
+		t.Errorf("expected hello, got %q (%v)", data, err)
+	}
+}
+
+func TestMemoryAttachmentStore_Limits(t *testing.T) {
+	store := NewMemoryAttachmentStore(&sequentialIDGenerator{}, 4, 2)
+
+	if _, err := store.Put([]byte("too big")); !errors.Is(err, ErrAttachmentTooLarge) {
+		t.Errorf("expected ErrAttachmentTooLarge, got %v", err)
+	}
+
+	first, _ := store.Put([]byte("a"))
+	store.Put([]byte("b"))
+	store.Put([]byte("c"))
+	if _, err := store.Get(first.ID); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("expected oldest attachment to be evicted, got %v", err)
+	}
+}
+
+func TestHandleFrame_RejectsBinaryByDefault(t *testing.T) {
+	conn := &mockWebSocketConn{}
+	server := NewServer(&mockIDGenerator{}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{})
+
+	if server.handleFrame(conn, websocket.BinaryMessage, []byte{0xff, 0x00}) {
+		t.Error("expected rejected binary frame to keep the connection open")
+	}
+	if len(conn.written) != 1 {
+		t.Fatalf("expected 1 message written, got %d", len(conn.written))
+	}
+	msg, ok := conn.written[0].(ErrorMessage)
+	if !ok || msg.Error.Code != "BINARY_NOT_SUPPORTED" {
+		t.Errorf("expected BINARY_NOT_SUPPORTED error, got %+v", conn.written[0])
+	}
+}
+
+func TestHandleFrame_StoresAttachment(t *testing.T) {
+	conn := &mockWebSocketConn{}
+	store := NewMemoryAttachmentStore(&mockIDGenerator{id: "att-1"}, 16, 4)
+	server := NewServer(&mockIDGenerator{}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{},
+		WithAttachments(store))
+
+	if server.handleFrame(conn, websocket.BinaryMessage, []byte{1, 2, 3}) {
+		t.Fatal("expected connection to stay open")
+	}
+	msg, ok := conn.written[0].(AttachmentStoredMessage)
+	if !ok || msg.Type != "attachment:stored" || msg.Attachment.ID != "att-1" || msg.Attachment.Size != 3 {
+		t.Fatalf("unexpected receipt: %+v", conn.written[0])
+	}
+	if data, err := store.Get("att-1"); err != nil || len(data) != 3 {
+		t.Errorf("expected stored bytes, got %v (%v)", data, err)
+	}
+
+	// Oversized frames are rejected but not fatal
+	if server.handleFrame(conn, websocket.BinaryMessage, make([]byte, 17)) {
+		t.Error("expected oversized attachment to keep the connection open")
+	}
+	if msg, ok := conn.written[1].(ErrorMessage); !ok || msg.Error.Code != "ATTACHMENT_REJECTED" {
+		t.Errorf("expected ATTACHMENT_REJECTED error, got %+v", conn.written[1])
+	}
+}
+
+func TestHandleFrame_TextStillValidated(t *testing.T) {
+	conn := &mockWebSocketConn{}
+	server := NewServer(&mockIDGenerator{}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{},
+		WithAttachments(NewMemoryAttachmentStore(&mockIDGenerator{}, 16, 4)))
+
+	server.handleFrame(conn, websocket.TextMessage, []byte("not json"))
+	if msg, ok := conn.written[0].(ErrorMessage); !ok || msg.Error.Code != "INVALID_MESSAGE" {
+		t.Errorf("expected INVALID_MESSAGE error, got %+v", conn.written[0])
+	}
+}
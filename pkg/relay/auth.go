@@ -0,0 +1,39 @@
+package relay
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// MetricAuthRejected counts connections refused by the authenticator
+const MetricAuthRejected = "relay_auth_rejected_total"
+
+// ErrUnauthenticated is returned by authenticators that require a user and
+// found none
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Authenticator identifies the user behind a WebSocket upgrade request
+// An empty user ID with a nil error admits the connection anonymously; an
+// error refuses the upgrade with 401.
+type Authenticator interface {
+	Authenticate(r *http.Request) (string, error)
+}
+
+// HeaderAuthenticator trusts the user ID in a header set by an authenticating
+// reverse proxy (e.g. oauth2-proxy's X-Forwarded-User)
+// Only use it when clients can reach the relay solely through that proxy and
+// the proxy overwrites the header, or clients can claim any identity.
+type HeaderAuthenticator struct {
+	Header   string
+	Required bool // Refuse requests without the header instead of admitting them anonymously
+}
+
+// Authenticate returns the header's value
+func (a *HeaderAuthenticator) Authenticate(r *http.Request) (string, error) {
+	userID := strings.TrimSpace(r.Header.Get(a.Header))
+	if userID == "" && a.Required {
+		return "", ErrUnauthenticated
+	}
+	return userID, nil
+}
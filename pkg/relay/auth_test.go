@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+	"github.com/gorilla/websocket"
+)
+
+func TestHeaderAuthenticator(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	optional := &HeaderAuthenticator{Header: "X-Forwarded-User"}
+	required := &HeaderAuthenticator{Header: "X-Forwarded-User", Required: true}
+
+	if user, err := optional.Authenticate(r); user != "" || err != nil {
+		t.Errorf("expected an anonymous user, got %q (%v)", user, err)
+	}
+	if _, err := required.Authenticate(r); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated, got %v", err)
+	}
+	r.Header.Set("X-Forwarded-User", " alice ")
+	if user, err := required.Authenticate(r); user != "alice" || err != nil {
+		t.Errorf("expected alice, got %q (%v)", user, err)
+	}
+}
+
+func TestServer_AuthenticatorRejectsBeforeUpgrade(t *testing.T) {
+	server := NewServer(&UUIDGenerator{}, &StdLogger{}, &SystemClock{},
+		NewGorillaUpgrader(func(r *http.Request) bool { return true }),
+		WithAuthenticator(&HeaderAuthenticator{Header: "X-Forwarded-User", Required: true}))
+	httpServer := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	defer httpServer.Close()
+	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/ws"
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %v (%v)", resp, err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"X-Forwarded-User": {"alice"}})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	var handshake ConnectionEstablishedMessage
+	if err := conn.ReadJSON(&handshake); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	if err := conn.WriteJSON(map[string]string{"version": "1.0", "type": "connection:whoami"}); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var info ConnectionInfoMessage
+	if err := conn.ReadJSON(&info); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	if info.UserID != "alice" {
+		t.Errorf("expected the connection attributed to alice, got %+v", info.ConnectionInfo)
+	}
+}
+
+func TestServer_SharedSession(t *testing.T) {
+	ctx := context.Background()
+	history := session.NewMemoryHistory(10)
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"},
+		session.WithMaxConnections(2), session.WithHistory(history))
+	streamer := NewAgentStreamer(manager, &mockClock{now: testTime}, &mockLogger{})
+	server := NewServer(&mockIDGenerator{id: "conn"}, &mockLogger{}, &mockClock{now: testTime}, nil, WithAgentStreamer(streamer))
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	aliceConn, bobConn := &mockWebSocketConn{}, &mockWebSocketConn{}
+	alice, _, _ := server.registerConnection(aliceConn, r, "alice")
+	bob, _, _ := server.registerConnection(bobConn, r, "bob")
+
+	if _, err := manager.Create(ctx, "auth", alice, session.WithOwner("alice")); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, "session-1"); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, "session-1", "/tmp/worktree", &mockStreamingACPClient{}); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+	last := func(conn *mockWebSocketConn) interface{} { return conn.written[len(conn.written)-1] }
+
+	// Bob may not join until alice shares the session
+	reattach := []byte(`{"version":"1.0","type":"session:reattach","sessionId":"session-1"}`)
+	server.handleMessage(bob, reattach)
+	if errMsg, ok := last(bobConn).(ErrorMessage); !ok || errMsg.Error.Code != "FORBIDDEN" {
+		t.Fatalf("expected FORBIDDEN, got %+v", last(bobConn))
+	}
+	server.handleMessage(bob, []byte(`{"version":"1.0","type":"session:share","sessionId":"session-1","userId":"bob"}`))
+	if errMsg, ok := last(bobConn).(ErrorMessage); !ok || errMsg.Error.Code != "FORBIDDEN" {
+		t.Fatalf("expected only the owner to share, got %+v", last(bobConn))
+	}
+	server.handleMessage(alice, []byte(`{"version":"1.0","type":"session:share","sessionId":"session-1","userId":"bob"}`))
+	access, ok := last(aliceConn).(SessionAccessMessage)
+	if !ok || access.OwnerID != "alice" || len(access.Collaborators) != 1 || access.Collaborators[0] != "bob" {
+		t.Fatalf("expected bob to be a collaborator, got %+v", last(aliceConn))
+	}
+	server.handleMessage(bob, reattach)
+	if _, ok := last(bobConn).(SessionReattachedMessage); !ok {
+		t.Fatalf("expected bob to join, got %+v", last(bobConn))
+	}
+
+	// Bob's prompt is attributed to him
+	if _, err := streamer.Stream(server.userContext(bob), "session-1", "req-1", "from bob"); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	prompts, _ := manager.SearchHistory(session.HistoryQuery{SessionID: "session-1", Speaker: session.SpeakerUser})
+	if len(prompts) != 1 || prompts[0].Entry.UserID != "bob" {
+		t.Errorf("expected the prompt attributed to bob, got %+v", prompts)
+	}
+
+	// Once unshared, bob's connection stays but may not prompt
+	server.handleMessage(alice, []byte(`{"version":"1.0","type":"session:unshare","sessionId":"session-1","userId":"bob"}`))
+	if access, ok := last(aliceConn).(SessionAccessMessage); !ok || len(access.Collaborators) != 0 {
+		t.Fatalf("expected no collaborators, got %+v", last(aliceConn))
+	}
+	if _, err := streamer.Stream(server.userContext(bob), "session-1", "req-2", "again"); !errors.Is(err, session.ErrAccessDenied) {
+		t.Errorf("expected ErrAccessDenied, got %v", err)
+	}
+
+	// Alice hands the session to bob and stays on
+	transfer := []byte(`{"version":"1.0","type":"session:transfer","sessionId":"session-1","userId":"bob","keepAccess":true}`)
+	server.handleMessage(bob, transfer)
+	if errMsg, ok := last(bobConn).(ErrorMessage); !ok || errMsg.Error.Code != "FORBIDDEN" {
+		t.Fatalf("expected only the owner to transfer, got %+v", last(bobConn))
+	}
+	server.handleMessage(alice, transfer)
+	access, ok = last(aliceConn).(SessionAccessMessage)
+	if !ok || access.OwnerID != "bob" || len(access.Collaborators) != 1 || access.Collaborators[0] != "alice" {
+		t.Fatalf("expected bob to own the session with alice kept on, got %+v", last(aliceConn))
+	}
+	if _, err := streamer.Stream(server.userContext(bob), "session-1", "req-3", "mine now"); err != nil {
+		t.Errorf("expected the new owner to prompt, got %v", err)
+	}
+}
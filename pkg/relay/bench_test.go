@@ -0,0 +1,97 @@
+package relay
+
+import (
+	"context"
+	"testing"
+)
+
+// Baselines for performance work; run with make bench
+
+// discardLogger drops log lines so benchmarks don't accumulate them
+type discardLogger struct{}
+
+func (discardLogger) Printf(string, ...interface{}) {}
+
+// benchMessages is ordered so benchmark output lines up across runs
+var benchMessages = []struct {
+	name string
+	msg  []byte
+}{
+	{"small", []byte(`{"version":"1.0","type":"echo","message":"hi"}`)},
+	{"agent_message", []byte(`{"version":"1.0","type":"agent:message","sessionId":"session-1",` +
+		`"content":"Refactor the session manager to use the store interface","timeoutMs":30000}`)},
+	{"nested", []byte(`{"version":"1.0","type":"echo","payload":{"items":[{"id":1,"tags":["a","b"]},` +
+		`{"id":2,"tags":["c"]},{"id":3,"meta":{"k":"v","n":[1,2,3]}}]}}`)},
+}
+
+func BenchmarkValidateMessage(b *testing.B) {
+	strict := ProtocolConfig{MaxDepth: 16, MaxFields: 256, RejectDuplicateKeys: true}
+	for _, bm := range benchMessages {
+		name, msg := bm.name, bm.msg
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := ValidateMessage(msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/strict", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := ValidateMessageWith(msg, strict); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkHandleMessage(b *testing.B) {
+	b.Run("echo", func(b *testing.B) {
+		server := NewServer(&mockIDGenerator{}, discardLogger{}, &mockClock{now: testTime}, &mockUpgrader{})
+		benchHandle(b, server, benchMessages[2].msg)
+	})
+
+	b.Run("validation_error", func(b *testing.B) {
+		server := NewServer(&mockIDGenerator{}, discardLogger{}, &mockClock{now: testTime}, &mockUpgrader{})
+		benchHandle(b, server, []byte(`{"type":"echo"}`))
+	})
+
+}
+
+// BenchmarkStream covers the agent:message path past routing, which
+// handleMessage runs on its own goroutine
+func BenchmarkStream(b *testing.B) {
+	ctx := context.Background()
+	manager := NewSessionManager(discardLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"})
+	if _, err := manager.Create(ctx, "auth", &discardConn{}); err != nil {
+		b.Fatal(err)
+	}
+	if err := manager.BeginSpawn(ctx, "session-1"); err != nil {
+		b.Fatal(err)
+	}
+	if err := manager.AttachAgent(ctx, "session-1", "/tmp/worktree",
+		&mockStreamingACPClient{chunks: []string{"Echo: ", "refactor ", "done"}}); err != nil {
+		b.Fatal(err)
+	}
+	streamer := NewAgentStreamer(manager, &mockClock{now: testTime}, discardLogger{})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := streamer.Stream(ctx, "session-1", "req-1", "refactor done"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchHandle(b *testing.B, server *Server, msg []byte) {
+	b.Helper()
+	conn := &discardConn{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if server.handleMessage(conn, msg) {
+			b.Fatal("connection closed")
+		}
+	}
+}
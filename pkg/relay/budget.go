@@ -0,0 +1,56 @@
+package relay
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// MetricPolicyViolationCloses counts connections closed for exhausting their error budget
+const MetricPolicyViolationCloses = "relay_policy_violation_closes_total"
+
+// closeWriteTimeout bounds how long sending a close frame may block
+const closeWriteTimeout = time.Second
+
+// errorBudget counts protocol violations on one connection in a sliding window
+// Owned by the connection's read loop; not safe for concurrent use
+type errorBudget struct {
+	clock  Clock
+	hits   []time.Duration // Clock.Monotonic of each violation inside the window
+	window time.Duration
+	max    int
+}
+
+// newErrorBudget allows max violations per window
+func newErrorBudget(max int, window time.Duration, clock Clock) *errorBudget {
+	return &errorBudget{clock: clock, window: window, max: max}
+}
+
+// spend records a violation and reports whether the budget is now exceeded
+func (b *errorBudget) spend() bool {
+	now := b.clock.Monotonic()
+	kept := b.hits[:0]
+	for _, t := range b.hits {
+		if now-t < b.window {
+			kept = append(kept, t)
+		}
+	}
+	b.hits = append(kept, now)
+	return len(b.hits) > b.max
+}
+
+// closeWithPolicyViolation tells the client why and sends close code 1008
+// The caller closes the connection.
+func (s *Server) closeWithPolicyViolation(conn WebSocketConn, reason string) {
+	s.logger.Printf("Closing connection: %s", reason)
+	if s.metrics != nil {
+		s.metrics.IncCounter(MetricPolicyViolationCloses)
+	}
+
+	if err := conn.WriteJSON(NewErrorMessage("POLICY_VIOLATION", reason, false)); err != nil {
+		s.logger.Printf("Failed to send error response: %v", err)
+	}
+	if err := sendClose(conn, websocket.ClosePolicyViolation, reason, s.clock.Now().Add(closeWriteTimeout)); err != nil {
+		s.logger.Printf("Failed to send close frame: %v", err)
+	}
+}
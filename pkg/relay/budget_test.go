@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestErrorBudget_SlidingWindow(t *testing.T) {
+	clock := &mockClock{}
+	budget := newErrorBudget(2, time.Minute, clock)
+
+	if budget.spend() || budget.spend() {
+		t.Fatal("expected the first two violations to be within budget")
+	}
+	if !budget.spend() {
+		t.Fatal("expected the third violation to exceed the budget")
+	}
+
+	// Earlier violations age out of the window
+	clock.mono += time.Minute
+	if budget.spend() {
+		t.Error("expected violations older than the window to be forgiven")
+	}
+}
+
+func TestHandleValidationError_ExceedsBudget(t *testing.T) {
+	conn := &mockWebSocketConn{}
+	server := NewServer(&mockIDGenerator{}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{},
+		WithErrorBudget(1, time.Minute))
+	defer server.trackBudget(conn)()
+
+	validationErr := ValidationError{Code: "INVALID_JSON", Message: "bad", Recoverable: true}
+
+	if server.handleValidationError(conn, validationErr) {
+		t.Fatal("expected first violation to be tolerated")
+	}
+	if !server.handleValidationError(conn, validationErr) {
+		t.Fatal("expected connection to close once the budget is exceeded")
+	}
+
+	last, ok := conn.written[len(conn.written)-1].(ErrorMessage)
+	if !ok || last.Error.Code != "POLICY_VIOLATION" || last.Error.Recoverable {
+		t.Errorf("expected non-recoverable POLICY_VIOLATION error, got %+v", conn.written[len(conn.written)-1])
+	}
+	if len(conn.controls) != 1 {
+		t.Fatalf("expected one close frame, got %d", len(conn.controls))
+	}
+	code := int(conn.controls[0][0])<<8 | int(conn.controls[0][1])
+	if code != websocket.ClosePolicyViolation {
+		t.Errorf("expected close code %d, got %d", websocket.ClosePolicyViolation, code)
+	}
+}
+
+func TestHandleValidationError_BudgetDisabled(t *testing.T) {
+	conn := &mockWebSocketConn{}
+	server := NewServer(&mockIDGenerator{}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{})
+	defer server.trackBudget(conn)()
+
+	validationErr := ValidationError{Code: "INVALID_JSON", Message: "bad", Recoverable: true}
+	for i := 0; i < 100; i++ {
+		if server.handleValidationError(conn, validationErr) {
+			t.Fatalf("expected no budget by default, closed after %d errors", i+1)
+		}
+	}
+}
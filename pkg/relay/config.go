@@ -0,0 +1,1012 @@
+package relay
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/integrations/github"
+	"github.com/2389-research/ourocodus/pkg/policy"
+	"github.com/2389-research/ourocodus/pkg/redact"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+	"github.com/2389-research/ourocodus/pkg/sandbox"
+)
+
+// Config holds relay settings loaded from a JSON file
+// Zero values are replaced by DefaultConfig when loading
+type Config struct {
+	AdminAddr      string                     `json:"adminAddr"`
+	DebugAddr      string                     `json:"debugAddr"` // pprof and runtime state; empty disables
+	IDs            IDConfig                   `json:"ids"`
+	Redaction      RedactionConfig            `json:"redaction"`
+	CircuitBreaker BreakerConfig              `json:"circuitBreaker"`
+	History        HistoryConfig              `json:"history"`
+	Concurrency    ConcurrencyConfig          `json:"concurrency"`
+	Templates      map[string]TemplateConfig  `json:"templates"`
+	AgentKinds     map[string]AgentKindConfig `json:"agentKinds"` // Agent backends selectable by kind
+	AgentPlugins   AgentPluginConfig          `json:"agentPlugins"`
+	WorkspaceCache WorkspaceCacheConfig       `json:"workspaceCache"`
+	Quotas         QuotaConfig                `json:"quotas"`
+	Store          StoreConfig                `json:"store"`
+	SessionTTL     SessionTTLConfig           `json:"sessionTtl"`
+	Resources      ResourceConfig             `json:"resources"`
+	Pressure       PressureConfig             `json:"pressure"`
+	Streaming      StreamingConfig            `json:"streaming"`
+	Encryption     EncryptionConfig           `json:"encryption"`
+	AccessLog      AccessLogConfig            `json:"accessLog"`
+	IPFilter       IPFilterConfig             `json:"ipFilter"`
+	Auth           AuthConfig                 `json:"auth"`
+	Federation     FederationConfig           `json:"federation"`
+	AdminAuth      AdminAuthConfig            `json:"adminAuth"`
+	AgentProxy     AgentProxyConfig           `json:"agentProxy"`
+	AgentWireLog   string                     `json:"agentWireLog"`   // Debug log of every agent JSON-RPC frame: absolute path, or "-" for the relay log
+	JournalDir     string                     `json:"journalDir"`     // Per-session event journals for `relay replay`: absolute path; empty disables
+	AgentTracing   string                     `json:"agentTracing"`   // Pass trace IDs to agents: "header", "params", or empty to disable
+	ToolPolicy     *session.ToolPolicy        `json:"toolPolicy"`     // For sessions spawned without one; nil leaves tool calls to clients
+	TrustedProxies []string                   `json:"trustedProxies"` // CIDRs of load balancers whose X-Forwarded-For is believed
+	ErrorBudget    ErrorBudgetConfig          `json:"errorBudget"`
+	Sandbox        SandboxConfig              `json:"sandbox"`
+	BinaryFrames   BinaryConfig               `json:"binaryFrames"`
+	Protocol       ProtocolConfig             `json:"protocol"`
+	MessagePolicy  MessagePolicyConfig        `json:"messagePolicy"`
+	EventSink      EventSinkConfig            `json:"eventSink"`
+	Webhooks       []WebhookConfig            `json:"webhooks"`
+	Schedules      []ScheduleConfig           `json:"schedules"` // Recurring jobs; more can be defined through the admin API
+	GitHub         GitHubConfig               `json:"github"`
+	Port           int                        `json:"port"`
+}
+
+// IDConfig selects how IDs are generated for each entity type
+type IDConfig struct {
+	Strategy      string `json:"strategy"`      // "uuid" (default) or "ulid"
+	SessionPrefix string `json:"sessionPrefix"` // e.g. "sess_"
+	ServerPrefix  string `json:"serverPrefix"`  // e.g. "srv_"
+}
+
+// RedactionConfig controls how secrets are scrubbed from logs and transcripts
+// Patterns are added to redact.DefaultPatterns, never replace them
+type RedactionConfig struct {
+	Mode           string   `json:"mode"`           // "strip" (default) or "hash"
+	Patterns       []string `json:"patterns"`       // Extra regexes to redact
+	LogTranscripts bool     `json:"logTranscripts"` // Log redacted prompts and replies
+}
+
+// NewRedactor builds the redactor described by the config
+func (c RedactionConfig) NewRedactor() (*redact.Redactor, error) {
+	patterns := append(append([]string{}, redact.DefaultPatterns...), c.Patterns...)
+	return redact.New(redact.Mode(c.Mode), patterns...)
+}
+
+// BreakerConfig configures the per-agent circuit breaker
+// A zero threshold disables the breaker
+type BreakerConfig struct {
+	Cooldown  Duration `json:"cooldown"`  // e.g. "30s"
+	Threshold int      `json:"threshold"` // Consecutive failures before opening
+}
+
+// HistoryConfig controls the searchable conversation history
+// A zero MaxEntries disables history
+type HistoryConfig struct {
+	MaxEntries int `json:"maxEntries"` // Most recent prompts and replies kept in memory
+}
+
+// ConcurrencyConfig caps in-flight agent requests per session
+// A zero MaxInFlight means unlimited; extra requests fail with BUSY unless Queue is set
+type ConcurrencyConfig struct {
+	MaxInFlight int  `json:"maxInFlight"`
+	Queue       bool `json:"queue"` // Wait for a free slot instead of rejecting
+}
+
+// WorkspaceCacheConfig seeds workspaces for spawns that set seedFrom from
+// cached snapshots of the seed directory
+// An empty Dir disables seeding
+type WorkspaceCacheConfig struct {
+	Dir  string `json:"dir"`  // Absolute path where snapshots are kept
+	Mode string `json:"mode"` // "reflink" (default), "hardlink" or "copy"
+}
+
+// QuotaConfig limits how many live sessions may be spawned and how many
+// connections (e.g. browser tabs) may share one
+// Zero means unlimited
+type QuotaConfig struct {
+	MaxSessions              int `json:"maxSessions"`
+	MaxSessionsPerOwner      int `json:"maxSessionsPerOwner"`
+	MaxConnectionsPerSession int `json:"maxConnectionsPerSession"` // Defaults to 1
+}
+
+// StoreConfig selects where session metadata is kept
+// By default sessions are kept in memory. Path keeps them in an embedded
+// bbolt file so they survive restarts of a single relay. Driver instead names
+// a database/sql driver for PostgreSQL compiled into the relay; cmd/relay
+// registers "pgx" (github.com/jackc/pgx/v5/stdlib). Sessions are then shared
+// with every relay using the same database, and the connection string is
+// read from SESSION_STORE_DSN so it stays out of config files.
+type StoreConfig struct {
+	Path            string   `json:"path"` // Absolute path of the bbolt file
+	Driver          string   `json:"driver"`
+	MaxOpenConns    int      `json:"maxOpenConns"`    // Connection pool size; zero means unlimited
+	MaxIdleConns    int      `json:"maxIdleConns"`    // Zero means database/sql's default
+	ConnMaxLifetime Duration `json:"connMaxLifetime"` // Zero means connections are reused forever
+}
+
+// validate checks the store settings and that the driver is compiled in
+func (c StoreConfig) validate() error {
+	if c.Path != "" && c.Driver != "" {
+		return fmt.Errorf("path and driver are mutually exclusive")
+	}
+	if c.Path != "" && !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("path must be an absolute path")
+	}
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 || c.ConnMaxLifetime < 0 {
+		return fmt.Errorf("pool settings cannot be negative")
+	}
+	if c.Driver != "" && !slices.Contains(sql.Drivers(), c.Driver) {
+		return fmt.Errorf("driver %q is not compiled into this relay (available: %v)", c.Driver, sql.Drivers())
+	}
+	return nil
+}
+
+// SessionTTLConfig terminates sessions a fixed time after creation, however
+// active they are; clients are sent session:expiring Warning beforehand
+// A zero Default leaves sessions without a TTL unless agent:spawn sets ttlSeconds
+// Terminated sessions stay queryable in state CLEANED for Retention, then are
+// purged on the same Interval; zero deletes them as soon as they are cleaned.
+type SessionTTLConfig struct {
+	Default   Duration `json:"default"`   // e.g. "8h"
+	Warning   Duration `json:"warning"`   // Lead time of the warning; zero disables it
+	Interval  Duration `json:"interval"`  // How often sessions are checked for expiry
+	Retention Duration `json:"retention"` // e.g. "24h"
+}
+
+// validate checks the TTL settings
+func (c SessionTTLConfig) validate() error {
+	if c.Default < 0 || c.Warning < 0 {
+		return fmt.Errorf("default and warning cannot be negative")
+	}
+	if c.Retention < 0 {
+		return fmt.Errorf("retention cannot be negative")
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	return nil
+}
+
+// ResourceConfig samples the CPU and memory of every agent process, shown in
+// the admin API and /debug/state; agent:resource_warning is sent to a session's
+// clients when its agent goes over a threshold
+// A zero Interval disables sampling; zero thresholds disable the warnings.
+type ResourceConfig struct {
+	Interval   Duration `json:"interval"`   // e.g. "10s"
+	CPUPercent float64  `json:"cpuPercent"` // Share of one core; 200 is two cores
+	RSSBytes   int64    `json:"rssBytes"`
+}
+
+// validate checks the sampling settings
+func (c ResourceConfig) validate() error {
+	if c.Interval < 0 || c.CPUPercent < 0 || c.RSSBytes < 0 {
+		return fmt.Errorf("interval and thresholds cannot be negative")
+	}
+	return nil
+}
+
+// PressureConfig refuses new spawns with RESOURCE_PRESSURE while the disk
+// holding WorkspaceRoot is nearly full or the relay is close to its file
+// descriptor limit, so a struggling relay does not take on more agents
+// Zero thresholds disable a check.
+type PressureConfig struct {
+	Interval      Duration `json:"interval"`      // How often disk and FDs are checked
+	WorkspaceRoot string   `json:"workspaceRoot"` // Directory whose filesystem holds the workspaces
+	MinFreeBytes  int64    `json:"minFreeBytes"`  // e.g. 1073741824 for 1 GiB
+	MaxFDPercent  float64  `json:"maxFdPercent"`  // Share of the soft RLIMIT_NOFILE
+}
+
+// validate checks the pressure thresholds
+func (c PressureConfig) validate() error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if c.MinFreeBytes < 0 {
+		return fmt.Errorf("minFreeBytes cannot be negative")
+	}
+	if c.MaxFDPercent < 0 || c.MaxFDPercent > 100 {
+		return fmt.Errorf("maxFdPercent must be between 0 and 100")
+	}
+	if c.MinFreeBytes > 0 && !filepath.IsAbs(c.WorkspaceRoot) {
+		return fmt.Errorf("workspaceRoot must be an absolute path when minFreeBytes is set")
+	}
+	return nil
+}
+
+// StreamingConfig bounds how far an agent may stream ahead of a slow client:
+// reading a reply from the agent pauses once HighWater agent:delta messages
+// are waiting to be written and resumes when LowWater are left
+type StreamingConfig struct {
+	HighWater int `json:"highWater"`
+	LowWater  int `json:"lowWater"`
+}
+
+// validate checks the water marks
+func (c StreamingConfig) validate() error {
+	if c.HighWater <= 0 || c.LowWater < 0 {
+		return fmt.Errorf("highWater must be positive and lowWater not negative")
+	}
+	if c.LowWater >= c.HighWater {
+		return fmt.Errorf("lowWater (%d) must be below highWater (%d)", c.LowWater, c.HighWater)
+	}
+	return nil
+}
+
+// SandboxConfig runs the commands of approved shell tool calls, and the
+// reads and writes of file tool calls, in the relay (see pkg/sandbox) instead
+// of letting the agent run them
+// Empty tool lists disable each.
+type SandboxConfig struct {
+	Tools          []string `json:"tools"`          // Tool names whose "command" argument is run, e.g. ["bash"]
+	ReadTools      []string `json:"readTools"`      // Tool names reading their "path" argument, e.g. ["read_file"]
+	WriteTools     []string `json:"writeTools"`     // Tool names writing "content" to their "path" argument
+	Env            []string `json:"env"`            // KEY=value variables commands see; nothing is inherited
+	Timeout        Duration `json:"timeout"`        // Commands still running are killed
+	MaxOutputBytes int      `json:"maxOutputBytes"` // Cap on each of stdout and stderr
+	MaxFileBytes   int64    `json:"maxFileBytes"`   // Cap on each file read or written
+}
+
+// validate checks the sandbox limits
+func (c SandboxConfig) validate() error {
+	if (len(c.ReadTools) > 0 || len(c.WriteTools) > 0) && c.MaxFileBytes <= 0 {
+		return fmt.Errorf("maxFileBytes must be positive")
+	}
+	if len(c.Tools) == 0 {
+		return nil
+	}
+	if c.Timeout <= 0 || c.MaxOutputBytes <= 0 {
+		return fmt.Errorf("timeout and maxOutputBytes must be positive")
+	}
+	for _, kv := range c.Env {
+		if key, _, ok := strings.Cut(kv, "="); !ok || key == "" {
+			return fmt.Errorf("env entry %q must be KEY=value", kv)
+		}
+	}
+	return nil
+}
+
+// EncryptionConfig encrypts session archives (exports with their transcripts
+// and workspace files) at rest with AES-256-GCM
+// ArchiveKeySecret names the secret the key is derived from; the relay reads
+// it from the environment variable of that name. Empty leaves archives in plaintext.
+type EncryptionConfig struct {
+	ArchiveKeySecret string `json:"archiveKeySecret"` // e.g. "RELAY_ARCHIVE_KEY"
+}
+
+// validate checks the secret name
+func (c EncryptionConfig) validate() error {
+	if strings.ContainsAny(c.ArchiveKeySecret, " \t\r\n=") {
+		return fmt.Errorf("archiveKeySecret must be an environment variable name, got %q", c.ArchiveKeySecret)
+	}
+	return nil
+}
+
+// AccessLogConfig writes one JSON line per HTTP request and WebSocket
+// connection, separate from the application log
+// An empty Path disables the access log.
+type AccessLogConfig struct {
+	Path       string  `json:"path"`       // Absolute file path, or "-" for stdout
+	SampleRate float64 `json:"sampleRate"` // Fraction of successful requests logged; failures always are
+}
+
+// validate checks the access log destination and sample rate
+func (c AccessLogConfig) validate() error {
+	if c.Path != "" && c.Path != "-" && !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("path must be an absolute path or \"-\"")
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sampleRate must be in (0, 1], got %g", c.SampleRate)
+	}
+	return nil
+}
+
+// IPFilterConfig restricts which client addresses may open WebSocket
+// connections; entries are CIDRs or bare IPs
+// Deny wins over allow, and an empty Allow admits every address not denied.
+// Behind a load balancer set Config.TrustedProxies so the lists see clients.
+type IPFilterConfig struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// Enabled reports whether any list is set
+func (c IPFilterConfig) Enabled() bool {
+	return len(c.Allow) > 0 || len(c.Deny) > 0
+}
+
+// AuthConfig identifies the user of each WebSocket connection from a header
+// set by an authenticating reverse proxy; sessions they spawn are owned by
+// them and shared with session:share
+// Clients must only be able to reach the relay through that proxy, or they
+// can claim any identity. An empty UserHeader leaves connections anonymous.
+type AuthConfig struct {
+	UserHeader string `json:"userHeader"` // e.g. "X-Forwarded-User"
+	Required   bool   `json:"required"`   // Refuse connections without the header (401)
+}
+
+// validate checks the auth settings
+func (c AuthConfig) validate() error {
+	if c.Required && c.UserHeader == "" {
+		return fmt.Errorf("required needs userHeader")
+	}
+	return nil
+}
+
+// AdminAuthConfig requires admin API requests to be signed (see AdminVerifier)
+// Each key is derived from the secret named by its SecretName; clients sign
+// with the same derivation. Keys are rotated by adding one, moving clients to
+// it and removing the old one. No Keys leaves the admin API unauthenticated.
+type AdminAuthConfig struct {
+	Keys []AdminKeyConfig `json:"keys"`
+}
+
+// AdminKeyConfig names an admin signing key and its secret
+type AdminKeyConfig struct {
+	ID         string `json:"id"`         // Sent by clients in X-Ourocodus-Key-Id
+	SecretName string `json:"secretName"` // e.g. "RELAY_ADMIN_KEY_2026"
+}
+
+// validate checks the admin signing keys
+func (c AdminAuthConfig) validate() error {
+	seen := make(map[string]bool, len(c.Keys))
+	for i, key := range c.Keys {
+		if key.ID == "" || seen[key.ID] {
+			return fmt.Errorf("keys[%d]: id must be set and unique", i)
+		}
+		seen[key.ID] = true
+		if key.SecretName == "" || strings.ContainsAny(key.SecretName, " \t\r\n=") {
+			return fmt.Errorf("keys[%d]: secretName must be an environment variable name, got %q", i, key.SecretName)
+		}
+	}
+	return nil
+}
+
+// FederationConfig (experimental) lets relays sharing a PostgreSQL session
+// store forward agent:message for sessions whose agent runs on a peer
+// Peers authenticate each other with a key derived from the secret named by
+// SecretName, which every relay must share. No Peers disables federation.
+type FederationConfig struct {
+	RelayID    string           `json:"relayId"`    // This relay's name, unique among its peers
+	SecretName string           `json:"secretName"` // e.g. "RELAY_FEDERATION_KEY"
+	Peers      []FederationPeer `json:"peers"`
+	Timeout    Duration         `json:"timeout"` // Bounds each forwarded prompt, agent reply included
+}
+
+// Enabled reports whether any peer is configured
+func (c FederationConfig) Enabled() bool {
+	return len(c.Peers) > 0
+}
+
+// validate checks the federation settings
+func (c FederationConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.RelayID == "" {
+		return fmt.Errorf("relayId is required")
+	}
+	if c.SecretName == "" || strings.ContainsAny(c.SecretName, " \t\r\n=") {
+		return fmt.Errorf("secretName must be an environment variable name, got %q", c.SecretName)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	seen := make(map[string]bool, len(c.Peers))
+	for i, peer := range c.Peers {
+		if peer.ID == "" || peer.ID == c.RelayID || seen[peer.ID] {
+			return fmt.Errorf("peers[%d]: id must be set, unique and differ from relayId", i)
+		}
+		seen[peer.ID] = true
+		u, err := url.Parse(peer.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("peers[%d]: url must be an http(s) URL, got %q", i, peer.URL)
+		}
+	}
+	return nil
+}
+
+// AgentProxyConfig routes the outbound traffic of spawned agents through an
+// HTTP(S) proxy, for relays in networks without direct internet access
+// The settings reach each agent process as the conventional proxy environment
+// variables; the relay's own traffic is unaffected.
+type AgentProxyConfig struct {
+	HTTPProxy  string   `json:"httpProxy"` // e.g. "http://proxy.internal:3128"
+	HTTPSProxy string   `json:"httpsProxy"`
+	NoProxy    []string `json:"noProxy"`  // Hosts, domains or CIDRs reached directly
+	CABundle   string   `json:"caBundle"` // Absolute path of extra PEM CA certificates, e.g. of a TLS-inspecting proxy
+}
+
+// Env returns the variables passing the settings to an agent process (pure function)
+// Proxy variables are set in both cases since tools disagree on which they
+// read; CABundle is passed as NODE_EXTRA_CA_CERTS, which adds to Node's
+// built-in roots rather than replacing them.
+func (c AgentProxyConfig) Env() []string {
+	var env []string
+	add := func(name, value string) {
+		if value != "" {
+			env = append(env, name+"="+value, strings.ToLower(name)+"="+value)
+		}
+	}
+	add("HTTP_PROXY", c.HTTPProxy)
+	add("HTTPS_PROXY", c.HTTPSProxy)
+	add("NO_PROXY", strings.Join(c.NoProxy, ","))
+	if c.CABundle != "" {
+		env = append(env, "NODE_EXTRA_CA_CERTS="+c.CABundle)
+	}
+	return env
+}
+
+// validate checks the proxy URLs and CA bundle path
+func (c AgentProxyConfig) validate() error {
+	for _, p := range []struct{ name, proxy string }{{"httpProxy", c.HTTPProxy}, {"httpsProxy", c.HTTPSProxy}} {
+		name, proxy := p.name, p.proxy
+		if proxy == "" {
+			continue
+		}
+		u, err := url.Parse(proxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || u.Host == "" {
+			return fmt.Errorf("%s must be an http, https or socks5 URL, got %q", name, proxy)
+		}
+	}
+	for _, host := range c.NoProxy {
+		if host == "" || strings.ContainsAny(host, ", \t\r\n") {
+			return fmt.Errorf("noProxy entries must be single hosts, got %q", host)
+		}
+	}
+	if c.CABundle != "" && !filepath.IsAbs(c.CABundle) {
+		return fmt.Errorf("caBundle must be an absolute path")
+	}
+	return nil
+}
+
+// ErrorBudgetConfig limits protocol violations (invalid JSON, failed
+// validation) per connection before it is closed with POLICY_VIOLATION
+// A zero MaxViolations disables the budget
+type ErrorBudgetConfig struct {
+	Window        Duration `json:"window"` // e.g. "1m"
+	MaxViolations int      `json:"maxViolations"`
+}
+
+// BinaryConfig decides what happens to binary WebSocket frames
+type BinaryConfig struct {
+	Policy         string `json:"policy"`         // "reject" (default) or "attachments"
+	MaxBytes       int    `json:"maxBytes"`       // Largest attachment accepted
+	MaxAttachments int    `json:"maxAttachments"` // Attachments kept in memory; oldest evicted
+}
+
+// ProtocolConfig tightens how inbound protocol messages are parsed
+// Zero limits are unlimited
+type ProtocolConfig struct {
+	MaxDepth            int  `json:"maxDepth"`            // Nested objects and arrays
+	MaxFields           int  `json:"maxFields"`           // Object keys plus array elements, whole message
+	RejectDuplicateKeys bool `json:"rejectDuplicateKeys"` // Otherwise the last value silently wins
+}
+
+// EventSinkConfig publishes lifecycle events and agent outputs to a queue
+// An empty NATSURL disables the sink
+type EventSinkConfig struct {
+	NATSURL        string `json:"natsUrl"`        // e.g. "nats://127.0.0.1:4222"
+	TopicPrefix    string `json:"topicPrefix"`    // Subjects are <prefix>.lifecycle and <prefix>.agent_output
+	Buffer         int    `json:"buffer"`         // Events queued before new ones are dropped
+	PublishOutputs bool   `json:"publishOutputs"` // Include redacted agent replies
+}
+
+// WebhookConfig is one endpoint notified of session lifecycle events
+type WebhookConfig struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"`      // HMAC-SHA256 key for the signature header
+	Events      []string `json:"events"`      // Empty subscribes to every event
+	MaxAttempts int      `json:"maxAttempts"` // Default 5
+	Backoff     Duration `json:"backoff"`     // First retry delay, doubled each retry; default "1s"
+}
+
+// GitHubConfig controls git:open_pr; the token comes from GITHUB_TOKEN
+// Owner and Repo default to the worktree remote's GitHub URL
+type GitHubConfig struct {
+	APIURL string `json:"apiUrl"` // Default https://api.github.com
+	Owner  string `json:"owner"`
+	Repo   string `json:"repo"`
+	Remote string `json:"remote"` // Default "origin"
+	Base   string `json:"base"`   // Branch pull requests merge into
+}
+
+// TemplateConfig describes a multi-agent setup launched by one
+// session:create_from_template message
+type TemplateConfig struct {
+	Agents []TemplateAgent `json:"agents"`
+}
+
+// TemplateAgent is one agent of a template
+type TemplateAgent struct {
+	Role          string `json:"role"`
+	Workspace     string `json:"workspace"`               // Directory the agent works in
+	SeedFrom      string `json:"seedFrom,omitempty"`      // Seed a missing workspace from this directory
+	InitialPrompt string `json:"initialPrompt,omitempty"` // Sent once every agent is up
+	Kind          string `json:"kind,omitempty"`          // One of agentKinds; empty starts the default agent
+}
+
+// AgentKindConfig declares an agent backend that sessions select by naming it
+// as the kind of their agent config, so adding a backend needs no code
+// An Image runs Command inside a container started with Runtime (see
+// AgentContainer); Limits apply only there. Env is added to the environment
+// every agent gets, e.g. from agentProxy.
+type AgentKindConfig struct {
+	Command string      `json:"command"` // Agent executable; default claude-code-acp
+	Args    []string    `json:"args"`    // Replace "--workspace <dir>"; "{workspace}" is the session's workspace
+	Env     []string    `json:"env"`     // KEY=value
+	Image   string      `json:"image"`   // Container image; empty runs Command on the relay's host
+	Runtime string      `json:"runtime"` // Container CLI; default "docker"
+	Limits  AgentLimits `json:"limits"`
+}
+
+// AgentPluginConfig discovers agent kinds from plugin executables in Dir
+// (see AgentPlugins), rescanned every Interval so plugins can be installed
+// without restarting the relay
+// An empty Dir disables plugins.
+type AgentPluginConfig struct {
+	Dir      string   `json:"dir"`      // Absolute path
+	Interval Duration `json:"interval"` // e.g. "30s"
+}
+
+// validate checks the plugin directory is absolute and rescanned
+func (c AgentPluginConfig) validate() error {
+	if c.Dir != "" && !filepath.IsAbs(c.Dir) {
+		return fmt.Errorf("dir must be an absolute path")
+	}
+	if c.Dir != "" && c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	return nil
+}
+
+// MessagePolicyConfig filters inbound messages and outbound agent output
+// through a WASM policy module (see pkg/policy and WithMessagePolicy)
+// An empty Module disables the policy.
+type MessagePolicyConfig struct {
+	Module         string   `json:"module"`         // Absolute path of the .wasm file
+	Timeout        Duration `json:"timeout"`        // Per message; e.g. "100ms"
+	MaxMemoryBytes int      `json:"maxMemoryBytes"` // Cap on the module's linear memory
+	FailOpen       bool     `json:"failOpen"`       // Pass messages on when the module fails to decide
+}
+
+// validate checks the module path and limits
+func (c MessagePolicyConfig) validate() error {
+	if c.Module == "" {
+		return nil
+	}
+	if !filepath.IsAbs(c.Module) {
+		return fmt.Errorf("module must be an absolute path")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if c.MaxMemoryBytes < 64<<10 {
+		return fmt.Errorf("maxMemoryBytes must be at least one 64KiB page")
+	}
+	return nil
+}
+
+// validate checks the environment entries and that limits come with an image
+func (c AgentKindConfig) validate() error {
+	for _, kv := range c.Env {
+		if name, _, ok := strings.Cut(kv, "="); !ok || name == "" {
+			return fmt.Errorf("env entries must be KEY=value, got %q", kv)
+		}
+	}
+	if c.Limits.MemoryBytes < 0 || c.Limits.CPUs < 0 || c.Limits.Pids < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+	if c.Image == "" && (c.Runtime != "" || c.Limits != AgentLimits{}) {
+		return fmt.Errorf("runtime and limits need an image")
+	}
+	return nil
+}
+
+// NewFactory creates the factory starting agents of this kind with apiKey
+// opts, such as the relay-wide WithAgentEnv, apply before the kind's settings
+func (c AgentKindConfig) NewFactory(apiKey string, logger Logger, opts ...AgentFactoryOption) *ACPAgentFactory {
+	opts = slices.Clone(opts)
+	if c.Args != nil {
+		opts = append(opts, WithAgentArgs(c.Args...))
+	}
+	if len(c.Env) > 0 {
+		opts = append(opts, WithAgentEnv(c.Env...))
+	}
+	if c.Image != "" {
+		opts = append(opts, WithAgentContainer(AgentContainer{Runtime: c.Runtime, Image: c.Image, Limits: c.Limits}))
+	}
+	return NewACPAgentFactory(apiKey, c.Command, logger, opts...)
+}
+
+// Duration is a time.Duration that reads and writes Go duration strings in JSON
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string such as "30s" or "5m"
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON renders the duration as a Go duration string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// DefaultConfig returns the settings used when no config file is given
+func DefaultConfig() Config {
+	return Config{
+		Port:      8080,
+		AdminAddr: "127.0.0.1:8081", // Admin API is unauthenticated: loopback only
+		IDs: IDConfig{
+			Strategy: IDStrategyUUID,
+		},
+		CircuitBreaker: BreakerConfig{
+			Threshold: 5,
+			Cooldown:  Duration(30 * time.Second),
+		},
+		History: HistoryConfig{
+			MaxEntries: 10000,
+		},
+		Quotas: QuotaConfig{
+			MaxConnectionsPerSession: 1,
+		},
+		ErrorBudget: ErrorBudgetConfig{
+			MaxViolations: 10,
+			Window:        Duration(time.Minute),
+		},
+		Protocol: ProtocolConfig{
+			MaxDepth:  32,
+			MaxFields: 10000,
+		},
+		GitHub: GitHubConfig{
+			APIURL: github.DefaultAPIURL,
+			Remote: github.DefaultRemote,
+			Base:   "main",
+		},
+		EventSink: EventSinkConfig{
+			TopicPrefix: "ourocodus",
+			Buffer:      1024,
+		},
+		BinaryFrames: BinaryConfig{
+			Policy:         BinaryReject,
+			MaxBytes:       10 << 20,
+			MaxAttachments: 100,
+		},
+		AgentPlugins: AgentPluginConfig{
+			Interval: Duration(DefaultPluginScanInterval),
+		},
+		MessagePolicy: MessagePolicyConfig{
+			Timeout:        Duration(policy.DefaultTimeout),
+			MaxMemoryBytes: policy.DefaultMaxMemory,
+		},
+		SessionTTL: SessionTTLConfig{
+			Warning:  Duration(5 * time.Minute),
+			Interval: Duration(15 * time.Second),
+		},
+		Resources: ResourceConfig{
+			Interval: Duration(10 * time.Second),
+		},
+		Streaming: StreamingConfig{
+			HighWater: DefaultOutboxHighWater,
+			LowWater:  DefaultOutboxLowWater,
+		},
+		Pressure: PressureConfig{
+			Interval:     Duration(10 * time.Second),
+			MaxFDPercent: 90,
+		},
+		AccessLog: AccessLogConfig{
+			SampleRate: 1,
+		},
+		Federation: FederationConfig{
+			Timeout: Duration(10 * time.Minute),
+		},
+		Sandbox: SandboxConfig{
+			Timeout:        Duration(sandbox.DefaultTimeout),
+			MaxOutputBytes: sandbox.DefaultMaxOutput,
+			MaxFileBytes:   sandbox.DefaultMaxFileBytes,
+		},
+	}
+}
+
+// LoadConfig reads a JSON config file over the defaults and validates it
+// An empty path returns DefaultConfig. Unknown fields are rejected so typos
+// surface at startup instead of being silently ignored.
+func LoadConfig(path string) (Config, error) {
+	if path == "" {
+		return DefaultConfig(), nil
+	}
+
+	cfg, err := decodeConfig(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// decodeConfig reads a JSON config file over the defaults without validating it
+func decodeConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	// #nosec G304 -- config path is supplied by the operator
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the config for values the relay cannot start with
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be between 1 and 65535, got %d", c.Port))
+	}
+
+	switch c.IDs.Strategy {
+	case "", IDStrategyUUID, IDStrategyULID:
+	default:
+		errs = append(errs, fmt.Errorf("ids.strategy must be %q or %q, got %q",
+			IDStrategyUUID, IDStrategyULID, c.IDs.Strategy))
+	}
+
+	if c.CircuitBreaker.Threshold < 0 {
+		errs = append(errs, fmt.Errorf("circuitBreaker.threshold cannot be negative"))
+	}
+	if c.CircuitBreaker.Cooldown < 0 {
+		errs = append(errs, fmt.Errorf("circuitBreaker.cooldown cannot be negative"))
+	}
+
+	if c.History.MaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("history.maxEntries cannot be negative"))
+	}
+	if c.Concurrency.MaxInFlight < 0 {
+		errs = append(errs, fmt.Errorf("concurrency.maxInFlight cannot be negative"))
+	}
+
+	if c.ErrorBudget.MaxViolations < 0 {
+		errs = append(errs, fmt.Errorf("errorBudget.maxViolations cannot be negative"))
+	}
+	if c.ErrorBudget.MaxViolations > 0 && c.ErrorBudget.Window <= 0 {
+		errs = append(errs, fmt.Errorf("errorBudget.window must be positive"))
+	}
+
+	if c.Protocol.MaxDepth < 0 || c.Protocol.MaxFields < 0 {
+		errs = append(errs, fmt.Errorf("protocol limits cannot be negative"))
+	}
+
+	if c.EventSink.NATSURL != "" {
+		if _, err := NewNATSPublisher(c.EventSink.NATSURL, nil); err != nil {
+			errs = append(errs, fmt.Errorf("eventSink.natsUrl: %w", err))
+		}
+		if c.EventSink.TopicPrefix == "" || strings.ContainsAny(c.EventSink.TopicPrefix, " \t\r\n") {
+			errs = append(errs, fmt.Errorf("eventSink.topicPrefix must be non-empty without whitespace"))
+		}
+		if c.EventSink.Buffer < 1 {
+			errs = append(errs, fmt.Errorf("eventSink.buffer must be positive"))
+		}
+	}
+
+	for i, hook := range c.Webhooks {
+		if err := hook.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: %w", i, err))
+		}
+	}
+
+	scheduleNames := make(map[string]bool, len(c.Schedules))
+	for i, sched := range c.Schedules {
+		if err := sched.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("schedules[%d]: %w", i, err))
+		}
+		if scheduleNames[sched.Name] {
+			errs = append(errs, fmt.Errorf("schedules[%d]: duplicate name %s", i, sched.Name))
+		}
+		scheduleNames[sched.Name] = true
+	}
+
+	switch c.BinaryFrames.Policy {
+	case "", BinaryReject:
+	case BinaryAttachments:
+		if c.BinaryFrames.MaxBytes < 1 || c.BinaryFrames.MaxAttachments < 1 {
+			errs = append(errs, fmt.Errorf("binaryFrames.maxBytes and binaryFrames.maxAttachments must be positive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("binaryFrames.policy must be %q or %q, got %q",
+			BinaryReject, BinaryAttachments, c.BinaryFrames.Policy))
+	}
+
+	if c.Quotas.MaxSessions < 0 || c.Quotas.MaxSessionsPerOwner < 0 || c.Quotas.MaxConnectionsPerSession < 0 {
+		errs = append(errs, fmt.Errorf("quotas cannot be negative"))
+	}
+	if err := c.Store.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("store: %w", err))
+	}
+	if err := c.SessionTTL.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("sessionTtl: %w", err))
+	}
+	if err := c.Resources.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("resources: %w", err))
+	}
+	if err := c.Pressure.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("pressure: %w", err))
+	}
+	if err := c.Streaming.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("streaming: %w", err))
+	}
+	if err := c.Sandbox.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("sandbox: %w", err))
+	}
+	if err := c.Encryption.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("encryption: %w", err))
+	}
+	if err := c.AccessLog.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("accessLog: %w", err))
+	}
+	if _, err := NewIPFilter(c.IPFilter); err != nil {
+		errs = append(errs, fmt.Errorf("ipFilter.%w", err))
+	}
+	if err := c.Auth.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("auth: %w", err))
+	}
+	if err := c.Federation.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("federation: %w", err))
+	}
+	if err := c.AdminAuth.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("adminAuth: %w", err))
+	}
+	if c.AgentWireLog != "" && c.AgentWireLog != "-" && !filepath.IsAbs(c.AgentWireLog) {
+		errs = append(errs, fmt.Errorf("agentWireLog must be an absolute path or \"-\""))
+	}
+	if c.JournalDir != "" && !filepath.IsAbs(c.JournalDir) {
+		errs = append(errs, fmt.Errorf("journalDir must be an absolute path"))
+	}
+	switch c.AgentTracing {
+	case "", TraceHeader, TraceParams:
+	default:
+		errs = append(errs, fmt.Errorf("agentTracing must be %q, %q or empty, got %q", TraceHeader, TraceParams, c.AgentTracing))
+	}
+	if c.ToolPolicy != nil {
+		if err := c.ToolPolicy.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := c.AgentProxy.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("agentProxy: %w", err))
+	}
+	if err := c.AgentPlugins.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("agentPlugins: %w", err))
+	}
+	if err := c.MessagePolicy.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("messagePolicy: %w", err))
+	}
+	if c.Federation.Enabled() && c.Store.Driver == "" {
+		errs = append(errs, fmt.Errorf("federation needs a shared session store (store.driver)"))
+	}
+	if _, err := NewTrustedProxies(c.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("trustedProxies: %w", err))
+	}
+
+	switch c.WorkspaceCache.Mode {
+	case "", SeedReflink, SeedHardlink, SeedCopy:
+	default:
+		errs = append(errs, fmt.Errorf("workspaceCache.mode must be %q, %q or %q, got %q",
+			SeedReflink, SeedHardlink, SeedCopy, c.WorkspaceCache.Mode))
+	}
+	if c.WorkspaceCache.Dir != "" && !filepath.IsAbs(c.WorkspaceCache.Dir) {
+		errs = append(errs, fmt.Errorf("workspaceCache.dir must be an absolute path"))
+	}
+
+	kinds := make([]string, 0, len(c.AgentKinds))
+	for kind := range c.AgentKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds) // Stable error order
+	for _, kind := range kinds {
+		if kind == "" || kind != strings.TrimSpace(kind) {
+			errs = append(errs, fmt.Errorf("agentKinds: invalid kind name %q", kind))
+		}
+		if err := c.AgentKinds[kind].validate(); err != nil {
+			errs = append(errs, fmt.Errorf("agentKinds.%s: %w", kind, err))
+		}
+	}
+
+	names := make([]string, 0, len(c.Templates))
+	for name := range c.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names) // Stable error order
+	for _, name := range names {
+		if err := c.Templates[name].validate(c.WorkspaceCache.Dir != ""); err != nil {
+			errs = append(errs, fmt.Errorf("templates.%s: %w", name, err))
+		}
+		for i, agent := range c.Templates[name].Agents {
+			// Plugin kinds are only known once the relay scans for them
+			if _, ok := c.AgentKinds[agent.Kind]; agent.Kind != "" && !ok && c.AgentPlugins.Dir == "" {
+				errs = append(errs, fmt.Errorf("templates.%s.agents[%d]: unknown kind %q", name, i, agent.Kind))
+			}
+		}
+	}
+
+	if _, err := c.Redaction.NewRedactor(); err != nil {
+		errs = append(errs, fmt.Errorf("redaction: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate checks that a webhook has a usable URL, a secret and known events
+func (w WebhookConfig) validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL, got %q", w.URL)
+	}
+	if w.Secret == "" {
+		return fmt.Errorf("secret is required")
+	}
+	for _, e := range w.Events {
+		switch e {
+		case WebhookSessionCreated, WebhookAgentFailed, WebhookSessionTerminated:
+		default:
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+	if w.MaxAttempts < 0 || w.Backoff < 0 {
+		return fmt.Errorf("maxAttempts and backoff cannot be negative")
+	}
+	return nil
+}
+
+// validate checks that a template names each role once and gives it a workspace
+// seedFrom is only allowed when workspace seeding is enabled
+func (t TemplateConfig) validate(seeding bool) error {
+	if len(t.Agents) == 0 {
+		return fmt.Errorf("no agents defined")
+	}
+	seen := make(map[string]bool, len(t.Agents))
+	for i, agent := range t.Agents {
+		switch {
+		case agent.Role == "":
+			return fmt.Errorf("agents[%d]: role is required", i)
+		case seen[agent.Role]:
+			return fmt.Errorf("agents[%d]: duplicate role %s", i, agent.Role)
+		case agent.Workspace == "":
+			return fmt.Errorf("agents[%d]: workspace is required", i)
+		case agent.SeedFrom != "" && !seeding:
+			return fmt.Errorf("agents[%d]: seedFrom requires workspaceCache.dir", i)
+		}
+		seen[agent.Role] = true
+	}
+	return nil
+}
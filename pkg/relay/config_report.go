@@ -0,0 +1,131 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+)
+
+// ConfigReport is the result of checking a config file for `relay validate-config`
+// Errors would stop the relay from starting or break features at runtime;
+// warnings flag settings that work but are probably unintended
+type ConfigReport struct {
+	Path     string   `json:"path"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+	Valid    bool     `json:"valid"`
+}
+
+// CheckConfigFile loads and validates a config file, then checks it against
+// the local environment (template workspaces exist, listeners don't collide)
+// Unlike LoadConfig it collects every problem instead of stopping at the first
+func CheckConfigFile(path string) ConfigReport {
+	report := ConfigReport{Path: path, Errors: []string{}, Warnings: []string{}}
+
+	cfg, err := decodeConfig(path)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+
+	if err := cfg.Validate(); err != nil {
+		for _, e := range unwrapJoined(err) {
+			report.Errors = append(report.Errors, e.Error())
+		}
+	}
+	for _, e := range cfg.environmentErrors() {
+		report.Errors = append(report.Errors, e.Error())
+	}
+	report.Warnings = append(report.Warnings, cfg.warnings()...)
+
+	report.Valid = len(report.Errors) == 0
+	return report
+}
+
+// environmentErrors checks settings that depend on the machine the relay runs on
+func (c Config) environmentErrors() []error {
+	var errs []error
+
+	listeners := map[string]string{"adminAddr": c.AdminAddr}
+	if c.DebugAddr != "" {
+		listeners["debugAddr"] = c.DebugAddr
+	}
+	for _, field := range []string{"adminAddr", "debugAddr"} {
+		addr, ok := listeners[field]
+		if !ok {
+			continue
+		}
+		if _, port, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		} else if port == strconv.Itoa(c.Port) {
+			errs = append(errs, fmt.Errorf("%s %s collides with port %d (the relay listens on all interfaces)", field, addr, c.Port))
+		}
+	}
+	if c.DebugAddr != "" && c.DebugAddr == c.AdminAddr {
+		errs = append(errs, fmt.Errorf("debugAddr %s collides with adminAddr", c.DebugAddr))
+	}
+
+	names := make([]string, 0, len(c.Templates))
+	for name := range c.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for i, agent := range c.Templates[name].Agents {
+			if agent.Workspace == "" {
+				continue // Reported by Validate
+			}
+			if agent.SeedFrom != "" && !pathExists(agent.Workspace) {
+				// Created from the seed directory at launch
+				if err := checkWorkspace(agent.SeedFrom); err != nil {
+					errs = append(errs, fmt.Errorf("templates.%s.agents[%d].seedFrom: %w", name, i, err))
+				}
+				continue
+			}
+			if err := checkWorkspace(agent.Workspace); err != nil {
+				errs = append(errs, fmt.Errorf("templates.%s.agents[%d]: %w", name, i, err))
+			}
+		}
+	}
+	return errs
+}
+
+// warnings flags valid settings that are risky or likely mistakes
+func (c Config) warnings() []string {
+	var warnings []string
+	if !isLoopbackAddr(c.AdminAddr) {
+		warnings = append(warnings, fmt.Sprintf("adminAddr %s is not loopback; the admin API is unauthenticated", c.AdminAddr))
+	}
+	if c.DebugAddr != "" && !isLoopbackAddr(c.DebugAddr) {
+		warnings = append(warnings, fmt.Sprintf("debugAddr %s is not loopback; profiles can expose memory contents", c.DebugAddr))
+	}
+	if c.Redaction.LogTranscripts {
+		warnings = append(warnings, "redaction.logTranscripts writes prompts and replies to the log")
+	}
+	if c.CircuitBreaker.Threshold == 0 {
+		warnings = append(warnings, "circuitBreaker.threshold is 0; failing agents are never isolated")
+	}
+	return warnings
+}
+
+// isLoopbackAddr reports whether a listen address binds only to loopback
+// Unparseable addresses count as loopback; they are reported as errors instead
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback())
+}
+
+// unwrapJoined splits an errors.Join error into its parts
+func unwrapJoined(err error) []error {
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,61 @@
+package relay
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckConfigFile_Valid(t *testing.T) {
+	workspace := t.TempDir()
+	path := writeConfig(t, `{"templates": {"web": {"agents": [{"role": "api", "workspace": "`+workspace+`"}]}}}`)
+
+	report := CheckConfigFile(path)
+
+	if !report.Valid || len(report.Errors) != 0 {
+		t.Errorf("expected valid report, got %+v", report)
+	}
+	if report.Path != path {
+		t.Errorf("expected path %s, got %s", path, report.Path)
+	}
+}
+
+func TestCheckConfigFile_CollectsAllErrors(t *testing.T) {
+	path := writeConfig(t, `{
+		"port": 9000,
+		"adminAddr": "0.0.0.0:9000",
+		"debugAddr": "localhost",
+		"quotas": {"maxSessions": -1},
+		"templates": {"web": {"agents": [{"role": "api", "workspace": "/does/not/exist"}]}}
+	}`)
+
+	report := CheckConfigFile(path)
+
+	if report.Valid {
+		t.Fatal("expected invalid report")
+	}
+	for _, want := range []string{"quotas cannot be negative", "collides with port 9000", "debugAddr: address localhost: missing port", "templates.web.agents[0]: workspace unavailable"} {
+		if !containsSubstring(report.Errors, want) {
+			t.Errorf("expected error containing %q, got %v", want, report.Errors)
+		}
+	}
+	if !containsSubstring(report.Warnings, "not loopback") {
+		t.Errorf("expected non-loopback admin warning, got %v", report.Warnings)
+	}
+}
+
+func TestCheckConfigFile_ParseError(t *testing.T) {
+	report := CheckConfigFile(writeConfig(t, `{"prot": 1}`))
+
+	if report.Valid || len(report.Errors) != 1 || !strings.Contains(report.Errors[0], "unknown field") {
+		t.Errorf("expected single parse error, got %+v", report)
+	}
+}
+
+func containsSubstring(list []string, substr string) bool {
+	for _, s := range list {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
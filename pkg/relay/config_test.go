@@ -0,0 +1,148 @@
+package relay
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "relay.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Port != 8080 || cfg.IDs.Strategy != IDStrategyUUID {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadConfig_OverridesDefaults(t *testing.T) {
+	path := writeConfig(t, `{"port": 9000, "ids": {"strategy": "ulid", "sessionPrefix": "sess_"}, "circuitBreaker": {"threshold": 2, "cooldown": "1m"}}`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Port != 9000 || cfg.IDs.Strategy != IDStrategyULID || cfg.IDs.SessionPrefix != "sess_" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if cfg.CircuitBreaker.Threshold != 2 || time.Duration(cfg.CircuitBreaker.Cooldown) != time.Minute {
+		t.Errorf("unexpected circuit breaker config: %+v", cfg.CircuitBreaker)
+	}
+	if cfg.AdminAddr != DefaultConfig().AdminAddr {
+		t.Errorf("expected unset fields to keep defaults, got %q", cfg.AdminAddr)
+	}
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{"unknown field", `{"prot": 9000}`, "unknown field"},
+		{"bad strategy", `{"ids": {"strategy": "snowflake"}}`, "ids.strategy"},
+		{"bad port", `{"port": 0}`, "port must be"},
+		{"bad redaction pattern", `{"redaction": {"patterns": ["([x"]}}`, "redaction"},
+		{"bad cooldown", `{"circuitBreaker": {"cooldown": 30}}`, "duration must be a string"},
+		{"negative threshold", `{"circuitBreaker": {"threshold": -1}}`, "threshold"},
+		{"bad redaction mode", `{"redaction": {"mode": "encrypt"}}`, "redaction"},
+		{"negative history size", `{"history": {"maxEntries": -1}}`, "history.maxEntries"},
+		{"negative concurrency limit", `{"concurrency": {"maxInFlight": -1}}`, "concurrency.maxInFlight"},
+		{"negative quota", `{"quotas": {"maxSessions": -1}}`, "quotas cannot be negative"},
+		{"negative connection quota", `{"quotas": {"maxConnectionsPerSession": -1}}`, "quotas cannot be negative"},
+		{"auth required without header", `{"auth": {"required": true}}`, "auth: required needs userHeader"},
+		{"federation without relay ID", `{"federation": {"secretName": "K", "peers": [{"id": "b", "url": "http://b:8080"}]}}`, "federation: relayId is required"},
+		{"federation peer without scheme", `{"federation": {"relayId": "a", "secretName": "K", "peers": [{"id": "b", "url": "b:8080"}]}}`, "federation: peers[0]: url must be an http(s) URL"},
+		{"admin key without id", `{"adminAuth": {"keys": [{"secretName": "K"}]}}`, "adminAuth: keys[0]: id must be set and unique"},
+		{"duplicate admin key", `{"adminAuth": {"keys": [{"id": "a", "secretName": "K"}, {"id": "a", "secretName": "L"}]}}`, "adminAuth: keys[1]: id must be set and unique"},
+		{"admin key bad secret name", `{"adminAuth": {"keys": [{"id": "a", "secretName": "A=B"}]}}`, "adminAuth: keys[0]: secretName must be an environment variable name"},
+		{"federation peer named like relay", `{"federation": {"relayId": "a", "secretName": "K", "peers": [{"id": "a", "url": "http://b:8080"}]}}`, "federation: peers[0]: id must be set"},
+		{"relative agent wire log", `{"agentWireLog": "wire.log"}`, "agentWireLog must be an absolute path"},
+		{"unknown agent tracing mode", `{"agentTracing": "stdout"}`, `agentTracing must be "header", "params" or empty`},
+		{"sandbox without timeout", `{"sandbox": {"tools": ["bash"], "timeout": "0s"}}`, "sandbox: timeout and maxOutputBytes must be positive"},
+		{"sandbox env without value", `{"sandbox": {"tools": ["bash"], "env": ["PATH"]}}`, `sandbox: env entry "PATH" must be KEY=value`},
+		{"sandbox file tools without cap", `{"sandbox": {"readTools": ["read_file"], "maxFileBytes": 0}}`, "sandbox: maxFileBytes must be positive"},
+		{"bad tool policy pattern", `{"toolPolicy": {"deny": ["[bash"]}}`, `toolPolicy.deny pattern "[bash"`},
+		{"agent proxy without scheme", `{"agentProxy": {"httpsProxy": "proxy:3128"}}`, "agentProxy: httpsProxy must be an http, https or socks5 URL"},
+		{"agent proxy relative CA bundle", `{"agentProxy": {"caBundle": "ca.pem"}}`, "agentProxy: caBundle must be an absolute path"},
+		{"agent proxy joined no-proxy list", `{"agentProxy": {"noProxy": ["a.internal,b.internal"]}}`, "agentProxy: noProxy entries must be single hosts"},
+		{"federation without shared store", `{"federation": {"relayId": "a", "secretName": "K", "peers": [{"id": "b", "url": "http://b:8080"}]}}`, "federation needs a shared session store"},
+		{"unregistered store driver", `{"store": {"driver": "pgx"}}`, `store: driver "pgx" is not compiled into this relay`},
+		{"relative store path", `{"store": {"path": "sessions.db"}}`, "store: path must be an absolute path"},
+		{"store path and driver", `{"store": {"path": "/var/lib/relay.db", "driver": "pgx"}}`, "store: path and driver are mutually exclusive"},
+		{"negative pool", `{"store": {"maxOpenConns": -1}}`, "store: pool settings cannot be negative"},
+		{"negative ttl", `{"sessionTtl": {"default": "-1h"}}`, "sessionTtl: default and warning cannot be negative"},
+		{"zero ttl interval", `{"sessionTtl": {"interval": "0s"}}`, "sessionTtl: interval must be positive"},
+		{"negative retention", `{"sessionTtl": {"retention": "-1h"}}`, "sessionTtl: retention cannot be negative"},
+		{"agent kind limits without image", `{"agentKinds": {"small": {"limits": {"pids": 10}}}}`, "agentKinds.small: runtime and limits need an image"},
+		{"agent kind env", `{"agentKinds": {"small": {"env": ["NOVALUE"]}}}`, "agentKinds.small: env entries must be KEY=value, got \"NOVALUE\""},
+		{"relative plugin dir", `{"agentPlugins": {"dir": "plugins"}}`, "agentPlugins: dir must be an absolute path"},
+		{"relative policy module", `{"messagePolicy": {"module": "policy.wasm"}}`, "messagePolicy: module must be an absolute path"},
+		{"policy memory under a page", `{"messagePolicy": {"module": "/etc/policy.wasm", "maxMemoryBytes": 1024}}`, "messagePolicy: maxMemoryBytes must be at least one 64KiB page"},
+		{"template unknown kind", `{"templates": {"t": {"agents": [{"role": "a", "workspace": "/tmp", "kind": "gpu"}]}}}`, "templates.t.agents[0]: unknown kind \"gpu\""},
+		{"bad archive key secret", `{"encryption": {"archiveKeySecret": "KEY=abc"}}`, "encryption: archiveKeySecret must be an environment variable name"},
+		{"relative access log", `{"accessLog": {"path": "access.log"}}`, "accessLog: path must be an absolute path"},
+		{"bad access log sample rate", `{"accessLog": {"sampleRate": 1.5}}`, "accessLog: sampleRate must be in (0, 1]"},
+		{"bad ip filter cidr", `{"ipFilter": {"deny": ["10.0.0.0/33"]}}`, "ipFilter.deny:"},
+		{"bad trusted proxy", `{"trustedProxies": ["lb.internal"]}`, "trustedProxies:"},
+		{"bad seeding mode", `{"workspaceCache": {"dir": "/var/cache/ouro", "mode": "overlay"}}`, "workspaceCache.mode"},
+		{"relative cache dir", `{"workspaceCache": {"dir": "cache"}}`, "workspaceCache.dir"},
+		{"seedFrom without cache", `{"templates": {"t": {"agents": [{"role": "a", "workspace": "/w", "seedFrom": "/repo"}]}}}`, "seedFrom requires workspaceCache.dir"},
+		{"negative error budget", `{"errorBudget": {"maxViolations": -1}}`, "errorBudget.maxViolations"},
+		{"zero error budget window", `{"errorBudget": {"window": "0s"}}`, "errorBudget.window"},
+		{"bad binary policy", `{"binaryFrames": {"policy": "drop"}}`, "binaryFrames.policy"},
+		{"zero attachment size", `{"binaryFrames": {"policy": "attachments", "maxBytes": 0}}`, "binaryFrames.maxBytes"},
+		{"negative protocol limit", `{"protocol": {"maxDepth": -1}}`, "protocol limits"},
+		{"bad nats url", `{"eventSink": {"natsUrl": "kafka://broker:9092"}}`, "eventSink.natsUrl"},
+		{"bad topic prefix", `{"eventSink": {"natsUrl": "nats://localhost", "topicPrefix": "a b"}}`, "eventSink.topicPrefix"},
+		{"webhook without secret", `{"webhooks": [{"url": "https://example.com/hook"}]}`, "webhooks[0]: secret is required"},
+		{"webhook bad url", `{"webhooks": [{"url": "example.com", "secret": "s"}]}`, "absolute http(s) URL"},
+		{"webhook unknown event", `{"webhooks": [{"url": "https://example.com", "secret": "s", "events": ["session.paused"]}]}`, "unknown event"},
+		{"schedule bad cron", `{"schedules": [{"name": "nightly", "cron": "0 3 * *", "job": {"role": "deps", "workspace": "/w", "prompts": ["go"]}}]}`, "schedules[0]: invalid schedule"},
+		{"schedule duplicate name", `{"schedules": [{"name": "n", "cron": "@daily", "job": {"role": "deps", "workspace": "/w", "prompts": ["go"]}}, {"name": "n", "cron": "@daily", "job": {"role": "deps", "workspace": "/w", "prompts": ["go"]}}]}`, "schedules[1]: duplicate name n"},
+		{"empty template", `{"templates": {"web": {"agents": []}}}`, "templates.web: no agents"},
+		{"duplicate template role", `{"templates": {"web": {"agents": [{"role": "db", "workspace": "/a"}, {"role": "db", "workspace": "/b"}]}}}`, "duplicate role db"},
+		{"template without workspace", `{"templates": {"web": {"agents": [{"role": "db"}]}}}`, "workspace is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestAgentProxyConfig_Env(t *testing.T) {
+	cfg := AgentProxyConfig{
+		HTTPSProxy: "http://proxy:3128",
+		NoProxy:    []string{"localhost", ".internal"},
+		CABundle:   "/etc/ssl/proxy-ca.pem",
+	}
+	want := []string{
+		"HTTPS_PROXY=http://proxy:3128", "https_proxy=http://proxy:3128",
+		"NO_PROXY=localhost,.internal", "no_proxy=localhost,.internal",
+		"NODE_EXTRA_CA_CERTS=/etc/ssl/proxy-ca.pem",
+	}
+	if got := cfg.Env(); !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if env := (AgentProxyConfig{}).Env(); len(env) != 0 {
+		t.Errorf("expected no variables without a proxy, got %v", env)
+	}
+}
@@ -0,0 +1,156 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// connState is what the server tracks about an open connection
+type connState struct {
+	id          string
+	userID      string // Set by the server's Authenticator ("" = anonymous)
+	remoteAddr  string
+	origin      string
+	connectedAt time.Time
+	received    atomic.Int64
+	sent        atomic.Int64
+}
+
+// countingConn counts the messages written to a connection
+type countingConn struct {
+	WebSocketConn
+	state *connState
+}
+
+func (c *countingConn) WriteJSON(v interface{}) error {
+	if err := c.WebSocketConn.WriteJSON(v); err != nil {
+		return err
+	}
+	c.state.sent.Add(1)
+	return nil
+}
+
+// registerConnection starts tracking conn, opened by userID, and returns the
+// connection the server should use from then on (it counts sent messages) and
+// a func that stops tracking it
+func (s *Server) registerConnection(conn WebSocketConn, r *http.Request, userID string) (WebSocketConn, *connState, func()) {
+	state := &connState{
+		id:          s.idGen.Generate(),
+		userID:      userID,
+		remoteAddr:  r.RemoteAddr,
+		origin:      r.Header.Get("Origin"),
+		connectedAt: s.clock.Now(),
+	}
+	wrapped := WebSocketConn(&countingConn{WebSocketConn: conn, state: state})
+
+	s.connsMu.Lock()
+	s.connections[wrapped] = state
+	s.connsMu.Unlock()
+	return wrapped, state, func() {
+		s.connsMu.Lock()
+		delete(s.connections, wrapped)
+		s.connsMu.Unlock()
+	}
+}
+
+// Connections returns the server's view of every open connection, oldest first
+func (s *Server) Connections() []ConnectionInfo {
+	s.connsMu.Lock()
+	conns := make(map[WebSocketConn]*connState, len(s.connections))
+	for conn, state := range s.connections {
+		conns[conn] = state
+	}
+	s.connsMu.Unlock()
+
+	infos := make([]ConnectionInfo, 0, len(conns))
+	for conn, state := range conns {
+		infos = append(infos, s.connectionInfo(conn, state))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].ConnectedAt != infos[j].ConnectedAt {
+			return infos[i].ConnectedAt < infos[j].ConnectedAt
+		}
+		return infos[i].ConnectionID < infos[j].ConnectionID
+	})
+	return infos
+}
+
+// Connection returns the view of one open connection
+func (s *Server) Connection(id string) (ConnectionInfo, bool) {
+	for _, info := range s.Connections() {
+		if info.ConnectionID == id {
+			return info, true
+		}
+	}
+	return ConnectionInfo{}, false
+}
+
+// connectionInfo snapshots one connection
+func (s *Server) connectionInfo(conn WebSocketConn, state *connState) ConnectionInfo {
+	info := ConnectionInfo{
+		ConnectionID:     state.id,
+		UserID:           state.userID,
+		RemoteAddr:       state.remoteAddr,
+		Origin:           state.origin,
+		Features:         s.features(),
+		SessionIDs:       []string{},
+		MessagesReceived: state.received.Load(),
+		MessagesSent:     state.sent.Load(),
+		ConnectedAt:      FormatTimestamp(state.connectedAt),
+	}
+	if s.streamer != nil {
+		if owned := s.streamer.OwnedBy(conn); owned != nil {
+			info.SessionIDs = owned
+		}
+	}
+	return info
+}
+
+// userContext returns a context attributing requests to the user of conn
+// Connections not opened through HandleWebSocket are anonymous.
+func (s *Server) userContext(conn WebSocketConn) context.Context {
+	s.connsMu.Lock()
+	state, ok := s.connections[conn]
+	s.connsMu.Unlock()
+	if !ok {
+		return context.Background()
+	}
+	return session.WithUser(context.Background(), state.userID)
+}
+
+// features lists the optional message handlers this server was built with
+func (s *Server) features() []string {
+	features := []string{}
+	if s.streamer != nil {
+		features = append(features, "agent:message", "agent:cancel", "session:reattach", "session:observe", "session:share", "session:transfer")
+	}
+	if s.spawner != nil {
+		features = append(features, "agent:spawn", "session:create_from_template")
+	}
+	if s.pullRequests != nil {
+		features = append(features, "git:open_pr")
+	}
+	if s.attachments != nil {
+		features = append(features, "binary:attachments")
+	}
+	return features
+}
+
+// handleWhoami replies with the server's view of the connection
+func (s *Server) handleWhoami(conn WebSocketConn) {
+	s.connsMu.Lock()
+	state, ok := s.connections[conn]
+	s.connsMu.Unlock()
+	if !ok {
+		return // Not registered: called outside HandleWebSocket
+	}
+	msg := NewConnectionInfoMessage(s.connectionInfo(conn, state), FormatTimestamp(s.clock.Now()))
+	if err := conn.WriteJSON(msg); err != nil {
+		s.logger.Printf("Failed to send connection info: %v", err)
+	}
+}
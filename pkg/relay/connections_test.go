@@ -0,0 +1,84 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestServer_Whoami(t *testing.T) {
+	server := newTestServer()
+	httpServer := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	defer httpServer.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"https://app.example"}})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("Failed to read handshake: %v", err)
+	}
+
+	if err := conn.WriteJSON(map[string]string{"version": ProtocolVersion, "type": "connection:whoami"}); err != nil {
+		t.Fatalf("Failed to send whoami: %v", err)
+	}
+	var info ConnectionInfoMessage
+	if err := conn.ReadJSON(&info); err != nil {
+		t.Fatalf("Failed to read connection:info: %v", err)
+	}
+	if info.Type != "connection:info" || info.ConnectionID == "" || info.Origin != "https://app.example" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if !strings.HasPrefix(info.RemoteAddr, "127.0.0.1:") {
+		t.Errorf("expected loopback remote address, got %s", info.RemoteAddr)
+	}
+	// The handshake was sent and the whoami received; the reply is counted after it is written
+	if info.MessagesReceived != 1 || info.MessagesSent != 1 {
+		t.Errorf("expected 1 received and 1 sent, got %d/%d", info.MessagesReceived, info.MessagesSent)
+	}
+	if info.SessionIDs == nil || info.Features == nil {
+		t.Error("sessionIds and features should be empty lists, not null")
+	}
+
+	// The admin view sees the same connection
+	admin := NewAdminHandler(NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{}), &mockLogger{},
+		WithAdminConnections(server))
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/connections", nil))
+	var list ConnectionListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Connections) != 1 || list.Connections[0].ConnectionID != info.ConnectionID {
+		t.Errorf("unexpected connections: %+v", list.Connections)
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/connections/"+info.ConnectionID, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/connections/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestServer_Features(t *testing.T) {
+	bare := newTestServer()
+	if got := bare.features(); len(got) != 0 {
+		t.Errorf("expected no optional features, got %v", got)
+	}
+	withAttachments := NewServer(&mockIDGenerator{id: "srv"}, &mockLogger{}, &mockClock{}, nil,
+		WithAttachments(NewMemoryAttachmentStore(&mockIDGenerator{id: "att"}, 1, 1)))
+	if got := withAttachments.features(); len(got) != 1 || got[0] != "binary:attachments" {
+		t.Errorf("unexpected features: %v", got)
+	}
+}
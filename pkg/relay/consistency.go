@@ -0,0 +1,109 @@
+package relay
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// ConsistencyHeader carries read-your-writes tokens on the admin API
+// Responses to admin calls that change a session set it; reads sent with
+// it (repeat the header for several) see at least those changes, whichever
+// relay sharing the session store serves them.
+const ConsistencyHeader = "X-Ourocodus-Consistency"
+
+const (
+	// defaultConsistencyWait bounds how long a read waits for the store to
+	// catch up with its tokens before answering 503
+	defaultConsistencyWait = 2 * time.Second
+
+	// consistencyPollInterval is how often a waiting read re-reads the store
+	consistencyPollInterval = 25 * time.Millisecond
+)
+
+var (
+	// ErrInvalidConsistencyToken is returned for tokens this relay did not issue
+	ErrInvalidConsistencyToken = errors.New("invalid consistency token")
+
+	// ErrNotConsistent is returned when the store has not caught up with a
+	// token in time
+	ErrNotConsistent = errors.New("session store has not caught up with the consistency token")
+)
+
+// consistencyToken names a session version a client has written
+type consistencyToken struct {
+	SessionID string `json:"s"`
+	Version   uint64 `json:"v"`
+}
+
+// newConsistencyToken encodes the session's current version as an opaque token
+func newConsistencyToken(sess *session.Session) string {
+	data, _ := json.Marshal(consistencyToken{SessionID: sess.GetID(), Version: sess.GetVersion()})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseConsistencyToken decodes a token made by newConsistencyToken (pure function)
+func parseConsistencyToken(s string) (consistencyToken, error) {
+	var token consistencyToken
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &token)
+	}
+	if err != nil || token.SessionID == "" {
+		return consistencyToken{}, fmt.Errorf("%w: %q", ErrInvalidConsistencyToken, s)
+	}
+	return token, nil
+}
+
+// satisfied reports whether the store shows the token's session at its
+// version or later
+func (t consistencyToken) satisfied(manager *session.Manager) bool {
+	sess := manager.Get(t.SessionID)
+	return sess != nil && sess.GetVersion() >= t.Version
+}
+
+// awaitConsistency waits until the store shows every session version named
+// in the request's ConsistencyHeader, re-reading it until wait has passed
+func awaitConsistency(ctx context.Context, manager *session.Manager, header http.Header, wait time.Duration) error {
+	values := header.Values(ConsistencyHeader)
+	if len(values) == 0 {
+		return nil
+	}
+	tokens := make([]consistencyToken, 0, len(values))
+	for _, v := range values {
+		token, err := parseConsistencyToken(v)
+		if err != nil {
+			return err
+		}
+		tokens = append(tokens, token)
+	}
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	ticker := time.NewTicker(consistencyPollInterval)
+	defer ticker.Stop()
+	for {
+		pending := tokens[:0]
+		for _, token := range tokens {
+			if !token.satisfied(manager) {
+				pending = append(pending, token)
+			}
+		}
+		if tokens = pending; len(tokens) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout.C:
+			return fmt.Errorf("%w: session %s version %d", ErrNotConsistent, tokens[0].SessionID, tokens[0].Version)
+		case <-ticker.C:
+		}
+	}
+}
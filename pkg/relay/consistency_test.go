@@ -0,0 +1,63 @@
+package relay
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseConsistencyToken(t *testing.T) {
+	_, manager := newTestAdmin(t)
+	sess := manager.Get("session-auth")
+	token, err := parseConsistencyToken(newConsistencyToken(sess))
+	if err != nil {
+		t.Fatalf("parseConsistencyToken: %v", err)
+	}
+	if token.SessionID != "session-auth" || token.Version != sess.GetVersion() {
+		t.Errorf("unexpected token: %+v", token)
+	}
+	for _, bad := range []string{"", "not base64!", "e30"} { // e30 is {}
+		if _, err := parseConsistencyToken(bad); !errors.Is(err, ErrInvalidConsistencyToken) {
+			t.Errorf("parseConsistencyToken(%q): expected ErrInvalidConsistencyToken, got %v", bad, err)
+		}
+	}
+}
+
+func TestAdminHandler_ReadYourWrites(t *testing.T) {
+	handler, manager := newTestAdmin(t)
+	handler.consistencyWait = 50 * time.Millisecond
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/sessions/session-auth/transfer?to=bob", nil))
+	token := rec.Header().Get(ConsistencyHeader)
+	if rec.Code != http.StatusOK || token == "" {
+		t.Fatalf("expected a consistency token with the transfer, got %d %q", rec.Code, token)
+	}
+
+	list := func(tokens ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/sessions?owner=bob", nil)
+		for _, token := range tokens {
+			req.Header.Add(ConsistencyHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := list(token); rec.Code != http.StatusOK {
+		t.Errorf("expected the write to be visible, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := list("garbage"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid token, got %d", rec.Code)
+	}
+
+	// A version the store has not reached, as if a lagging relay served the read
+	data, _ := json.Marshal(consistencyToken{SessionID: "session-db", Version: manager.Get("session-db").GetVersion() + 1})
+	rec = list(token, base64.RawURLEncoding.EncodeToString(data))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After until the store catches up, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// DebugState is the runtime snapshot served by GET /debug/state
+type DebugState struct {
+	Sessions        map[string]int   `json:"sessions"` // Count by state
+	Agents          []AgentProcess   `json:"agents"`
+	Goroutines      int              `json:"goroutines"`
+	OpenConnections int              `json:"openConnections"`
+	HeapAllocBytes  uint64           `json:"heapAllocBytes"`
+	NumGC           uint32           `json:"numGC"`
+	Counters        map[string]int64 `json:"counters,omitempty"` // Set if the server counts into CounterMetrics
+}
+
+// AgentProcess is one row of the agent process table
+// PID is 0 if the agent client does not expose its process; CPU and memory
+// are the ResourceMonitor's latest sample, zero until it has taken one
+type AgentProcess struct {
+	SessionID   string  `json:"sessionId"`
+	Role        string  `json:"role"`
+	State       string  `json:"state"`
+	WorktreeDir string  `json:"worktreeDir,omitempty"`
+	PID         int     `json:"pid,omitempty"`
+	CPUPercent  float64 `json:"cpuPercent,omitempty"`
+	RSSBytes    int64   `json:"rssBytes,omitempty"`
+}
+
+// processIdentifier is implemented by agent clients backed by an OS process
+type processIdentifier interface {
+	PID() int
+}
+
+// NewDebugHandler serves net/http/pprof under /debug/pprof/ and a runtime
+// snapshot under /debug/state (full goroutine stacks: /debug/pprof/goroutine?debug=2)
+// Profiles can expose memory contents: mount it only on a loopback listener
+func NewDebugHandler(server *Server, manager *session.Manager, logger Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("GET /debug/state", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(collectDebugState(server, manager)); err != nil {
+			logger.Printf("Failed to write debug state: %v", err)
+		}
+	})
+	return mux
+}
+
+// collectDebugState snapshots goroutines, connections, sessions, and agents
+func collectDebugState(server *Server, manager *session.Manager) DebugState {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	state := DebugState{
+		Sessions:        make(map[string]int),
+		Agents:          []AgentProcess{},
+		Goroutines:      runtime.NumGoroutine(),
+		OpenConnections: server.ActiveConnections(),
+		HeapAllocBytes:  mem.HeapAlloc,
+		NumGC:           mem.NumGC,
+	}
+
+	if counters, ok := server.metrics.(*CounterMetrics); ok {
+		state.Counters = counters.Snapshot()
+	}
+	for st, n := range manager.Stats().ByState {
+		state.Sessions[st.String()] = n
+	}
+	for _, sess := range manager.List(&session.SessionFilter{SortBy: session.SortByCreatedAt}) {
+		handle := sess.GetHandle()
+		if handle == nil || handle.ACPClient == nil {
+			continue
+		}
+		proc := AgentProcess{
+			SessionID:   sess.GetID(),
+			Role:        sess.GetAgentID(),
+			State:       sess.GetState().String(),
+			WorktreeDir: sess.GetWorktreeDir(),
+		}
+		if p, ok := handle.ACPClient.(processIdentifier); ok {
+			proc.PID = p.PID()
+		}
+		usage := sess.GetResourceUsage()
+		proc.CPUPercent, proc.RSSBytes = usage.CPUPercent, usage.RSSBytes
+		state.Agents = append(state.Agents, proc)
+	}
+	return state
+}
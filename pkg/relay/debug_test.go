@@ -0,0 +1,71 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type mockProcessACPClient struct {
+	mockStreamingACPClient
+	pid int
+}
+
+func (m *mockProcessACPClient) PID() int { return m.pid }
+
+func TestDebugHandler_State(t *testing.T) {
+	ctx := context.Background()
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &sequentialIDGenerator{})
+	if _, err := manager.Create(ctx, "auth", &mockWebSocketConn{}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := manager.Create(ctx, "db", &mockWebSocketConn{}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	_ = manager.BeginSpawn(ctx, "session-2")
+	_ = manager.AttachAgent(ctx, "session-2", "/work/db", &mockProcessACPClient{pid: 4242})
+
+	metrics := NewCounterMetrics()
+	metrics.IncCounter(MetricAdminRejected)
+	handler := NewDebugHandler(&Server{metrics: metrics}, manager, &mockLogger{})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/state", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var state DebugState
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+	if state.Goroutines < 1 {
+		t.Errorf("expected goroutine count, got %d", state.Goroutines)
+	}
+	if state.Counters[MetricAdminRejected] != 1 {
+		t.Errorf("expected the server's counters, got %v", state.Counters)
+	}
+	if state.Sessions["CREATED"] != 1 || state.Sessions["ACTIVE"] != 1 {
+		t.Errorf("unexpected session counts: %v", state.Sessions)
+	}
+	if len(state.Agents) != 1 {
+		t.Fatalf("expected 1 agent process, got %+v", state.Agents)
+	}
+	agent := state.Agents[0]
+	if agent.SessionID != "session-2" || agent.Role != "db" || agent.PID != 4242 || agent.WorktreeDir != "/work/db" {
+		t.Errorf("unexpected agent process: %+v", agent)
+	}
+}
+
+func TestDebugHandler_Pprof(t *testing.T) {
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &sequentialIDGenerator{})
+	handler := NewDebugHandler(&Server{}, manager, &mockLogger{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 from pprof, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// drainPollInterval is how often Drain checks whether connections have closed
+const drainPollInterval = 10 * time.Millisecond
+
+// Drain stops accepting WebSocket connections and closes the open ones with
+// close code 1001 (going away), then waits for their handlers to return
+// Upgrades are refused with 503 from the first call on. Sessions outlive
+// their connections as usual. Returns an error wrapping ctx's if connections
+// are still open when ctx is done.
+func (s *Server) Drain(ctx context.Context) error {
+	s.draining.Store(true)
+
+	s.connsMu.Lock()
+	conns := make([]WebSocketConn, 0, len(s.connections))
+	for conn := range s.connections {
+		conns = append(conns, conn)
+	}
+	s.connsMu.Unlock()
+
+	s.logger.Printf("Draining %d connections", len(conns))
+	for _, conn := range conns {
+		if err := sendClose(conn, websocket.CloseGoingAway, "relay shutting down", s.clock.Now().Add(closeWriteTimeout)); err != nil {
+			s.logger.Printf("Failed to send close frame: %v", err)
+		}
+		// Ends the handler's read loop; the handler closes it again on return
+		_ = conn.Close()
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for s.conns.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d connections still open: %w", s.conns.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// blockingConn blocks reads until it is closed, like an idle client
+type blockingConn struct {
+	mockWebSocketConn
+	done     chan struct{}
+	once     sync.Once
+	controls chan []byte
+}
+
+func newBlockingConn() *blockingConn {
+	return &blockingConn{done: make(chan struct{}), controls: make(chan []byte, 1)}
+}
+
+func (c *blockingConn) ReadMessage() (int, []byte, error) {
+	<-c.done
+	return 0, nil, errors.New("use of closed network connection")
+}
+
+func (c *blockingConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	c.controls <- data
+	return nil
+}
+
+func (c *blockingConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+func TestServer_DrainClosesConnections(t *testing.T) {
+	conn := newBlockingConn()
+	server := NewServer(&mockIDGenerator{id: "relay"}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{conn: conn})
+
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		server.HandleWebSocket(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws", nil))
+	}()
+	for server.ActiveConnections() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Drain(ctx); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	<-handled
+
+	frame := <-conn.controls
+	if code := int(frame[0])<<8 | int(frame[1]); code != websocket.CloseGoingAway {
+		t.Errorf("expected close code %d, got %d", websocket.CloseGoingAway, code)
+	}
+
+	rec := httptest.NewRecorder()
+	server.HandleWebSocket(rec, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for connections after Drain, got %d", rec.Code)
+	}
+}
+
+func TestServer_DrainTimesOut(t *testing.T) {
+	server := NewServer(&mockIDGenerator{id: "relay"}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{})
+	server.conns.Add(1) // A handler that never returns
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+}
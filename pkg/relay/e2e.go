@@ -0,0 +1,39 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/2389-research/ourocodus/pkg/e2e"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// ErrPayloadNotSealed is returned for plaintext prompts to sessions whose
+// payloads are end-to-end encrypted
+var ErrPayloadNotSealed = errors.New("prompt is not sealed for this end-to-end encrypted session")
+
+type payloadKeyContextKey struct{}
+
+// ContextWithPayloadKey carries the payload key of an agent:spawn to the
+// factory starting its agent, which passes it in the agent's environment as
+// e2e.EnvKey
+// The key lives only as long as ctx: it is not stored with the session.
+func ContextWithPayloadKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, payloadKeyContextKey{}, key)
+}
+
+// PayloadKeyFromContext returns the payload key ctx carries, or ""
+func PayloadKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(payloadKeyContextKey{}).(string)
+	return key
+}
+
+// checkSealed refuses plaintext content for sessions with EncryptedPayloads,
+// so a misconfigured client cannot leak prompts to the relay (pure function)
+func checkSealed(sess *session.Session, content string) error {
+	if sess.GetAgentConfig().EncryptedPayloads && !e2e.IsSealed(content) {
+		return fmt.Errorf("%w: %s", ErrPayloadNotSealed, sess.GetID())
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/e2e"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// keyRecordingFactory records the payload key each agent was started with
+type keyRecordingFactory struct {
+	mockAgentFactory
+	keys []string
+}
+
+func (f *keyRecordingFactory) NewAgent(ctx context.Context, role, workspace string) (session.ACPClient, error) {
+	f.keys = append(f.keys, PayloadKeyFromContext(ctx))
+	return f.mockAgentFactory.NewAgent(ctx, role, workspace)
+}
+
+func TestServer_SpawnWithPayloadKey(t *testing.T) {
+	factory := &keyRecordingFactory{}
+	spawner, manager := newTestSpawner(t, factory)
+	server := NewServer(&mockIDGenerator{id: "conn"}, &mockLogger{}, &mockClock{now: testTime}, nil, WithSpawner(spawner))
+	key, err := e2e.NewKey()
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	spawn := func(extra string) interface{} {
+		conn := &mockWebSocketConn{}
+		server.handleMessage(conn, []byte(fmt.Sprintf(`{"version":"1.0","type":"agent:spawn","role":"auth","workspace":%q%s}`, t.TempDir(), extra)))
+		return conn.written[len(conn.written)-1]
+	}
+
+	spawned, ok := spawn(fmt.Sprintf(`,"payloadKey":%q`, e2e.EncodeKey(key))).(AgentSpawnedMessage)
+	if !ok {
+		t.Fatalf("expected agent:spawned, got %+v", spawned)
+	}
+	if len(factory.keys) != 1 || factory.keys[0] != e2e.EncodeKey(key) {
+		t.Errorf("expected the agent started with the key, got %q", factory.keys)
+	}
+	if !manager.Get(spawned.SessionID).GetAgentConfig().EncryptedPayloads {
+		t.Error("expected the session marked as end-to-end encrypted")
+	}
+
+	for name, extra := range map[string]string{
+		"short key":   fmt.Sprintf(`,"payloadKey":%q`, e2e.EncodeKey(key[:16])),
+		"missing key": `,"encryptedPayloads":true`,
+	} {
+		if reply, ok := spawn(extra).(ErrorMessage); !ok || reply.Error.Code != "INVALID_MESSAGE" {
+			t.Errorf("%s: expected INVALID_MESSAGE, got %+v", name, reply)
+		}
+	}
+	if len(factory.keys) != 1 {
+		t.Errorf("expected no agent started for invalid spawns, got %d", len(factory.keys))
+	}
+}
+
+func TestSpawner_EncryptedSessionNeedsPayloadKey(t *testing.T) {
+	spawner, _ := newTestSpawner(t, &mockAgentFactory{})
+	req := SpawnRequest{
+		Role:      "auth",
+		Workspace: t.TempDir(),
+		Options:   []session.CreateOption{session.WithAgentConfig(session.AgentConfig{EncryptedPayloads: true})},
+	}
+	if _, err := spawner.SpawnAgent(context.Background(), &mockWebSocketConn{}, req); !errors.Is(err, session.ErrPayloadKeyUnavailable) {
+		t.Errorf("expected ErrPayloadKeyUnavailable, got %v", err)
+	}
+}
+
+func TestAgentStreamer_RefusesPlaintextForEncryptedSessions(t *testing.T) {
+	ctx := context.Background()
+	conn := &mockWebSocketConn{}
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"})
+	if _, err := manager.Create(ctx, "auth", conn, session.WithAgentConfig(session.AgentConfig{EncryptedPayloads: true})); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, "session-1"); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, "session-1", "/tmp/worktree", &mockStreamingACPClient{}); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+	streamer := NewAgentStreamer(manager, &mockClock{now: testTime}, &mockLogger{})
+
+	if _, err := streamer.Stream(ctx, "session-1", "req-1", "hello"); !errors.Is(err, ErrPayloadNotSealed) {
+		t.Fatalf("expected ErrPayloadNotSealed, got %v", err)
+	}
+	complete, ok := conn.written[len(conn.written)-1].(AgentCompleteMessage)
+	if !ok || complete.Error == nil || complete.Error.Code != "PAYLOAD_NOT_SEALED" {
+		t.Errorf("expected agent:complete with PAYLOAD_NOT_SEALED, got %+v", conn.written[len(conn.written)-1])
+	}
+
+	key, _ := e2e.NewKey()
+	box, _ := e2e.New(key)
+	sealed, _ := box.Seal("hello")
+	if _, err := streamer.Stream(ctx, "session-1", "req-2", sealed); err != nil {
+		t.Errorf("expected sealed prompts through, got %v", err)
+	}
+}
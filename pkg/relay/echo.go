@@ -0,0 +1,50 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+var (
+	timestampKey = []byte(`"timestamp"`)
+	unicodeEsc   = []byte(`\u`)
+)
+
+// maxPooledEcho caps buffers returned to the pool so one huge frame doesn't
+// pin its memory for the life of the process
+const maxPooledEcho = 64 << 10
+
+// echoBuffers recycles the buffers echo frames are assembled in
+var echoBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
+
+// appendTimestamp appends raw to dst with a "timestamp" member added to the
+// top-level object, without decoding it (pure function)
+// ok is false when the byte-level edit cannot match decoding: raw is not a
+// non-empty JSON object, or may already carry a timestamp (possibly spelled
+// with escapes) that the echo must overwrite. The caller then falls back to
+// the decoding path.
+func appendTimestamp(dst, raw []byte, timestamp string) (out []byte, ok bool) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) < 2 || trimmed[0] != '{' || !json.Valid(trimmed) {
+		return dst, false
+	}
+	if bytes.Contains(trimmed, timestampKey) || bytes.Contains(trimmed, unicodeEsc) {
+		return dst, false
+	}
+	end := len(trimmed) - 1
+	if len(bytes.TrimSpace(trimmed[1:end])) == 0 {
+		return dst, false // Empty object; not worth a special case
+	}
+
+	dst = append(dst, trimmed[:end]...)
+	dst = append(dst, `,"timestamp":"`...)
+	dst = append(dst, timestamp...)
+	dst = append(dst, `"}`...)
+	return dst, true
+}
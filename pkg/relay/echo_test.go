@@ -0,0 +1,110 @@
+package relay
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const echoTimestamp = "2025-10-23T12:00:00Z"
+
+func TestAppendTimestamp(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+		ok   bool
+	}{
+		{"object", `{"version":"1.0","type":"echo"}`, `{"version":"1.0","type":"echo","timestamp":"` + echoTimestamp + `"}`, true},
+		{"surrounding whitespace", " {\"a\":[1,{\"b\":2}]}\n", `{"a":[1,{"b":2}],"timestamp":"` + echoTimestamp + `"}`, true},
+		{"existing timestamp", `{"type":"x","timestamp":"old"}`, "", false},
+		{"escaped key", `{"type":"x","\u0074imestamp":"old"}`, "", false},
+		{"empty object", `{}`, "", false},
+		{"array", `[1,2]`, "", false},
+		{"invalid", `{invalid json}`, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := appendTimestamp(nil, []byte(tt.raw), echoTimestamp)
+			if ok != tt.ok {
+				t.Fatalf("expected ok=%v, got %v", tt.ok, ok)
+			}
+			if ok && string(got) != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEchoMessage_FastPathMatchesDecoding(t *testing.T) {
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}}
+	raw := []byte(`{"version":"1.0","type":"echo","nested":{"n":1.5,"list":["a",null,true]}}`)
+
+	conn := &mockWebSocketConn{}
+	if err := server.echoMessage(conn, raw); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, ok := conn.written[0].(map[string]interface{}); !ok {
+		t.Fatalf("expected decoded echo, got %T", conn.written[0])
+	}
+
+	var want map[string]interface{}
+	if err := json.Unmarshal(raw, &want); err != nil {
+		t.Fatal(err)
+	}
+	want["timestamp"] = echoTimestamp
+	gotJSON, _ := json.Marshal(conn.written[0])
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("expected %s, got %s", wantJSON, gotJSON)
+	}
+}
+
+func TestEchoMessage_OverwritesClientTimestamp(t *testing.T) {
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}}
+	conn := &mockWebSocketConn{}
+
+	if err := server.echoMessage(conn, []byte(`{"version":"1.0","type":"echo","timestamp":"old"}`)); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	echo := conn.written[0].(map[string]interface{})
+	if echo["timestamp"] != echoTimestamp {
+		t.Errorf("expected server timestamp, got %v", echo["timestamp"])
+	}
+}
+
+// discardConn encodes writes and drops them, standing in for the socket
+type discardConn struct {
+	mockWebSocketConn
+}
+
+func (d *discardConn) WriteJSON(v interface{}) error {
+	_, err := json.Marshal(v)
+	return err
+}
+
+var benchEcho = []byte(`{"version":"1.0","type":"echo","message":"hello from the benchmark","meta":{"seq":42,"tags":["a","b"]}}`)
+
+func BenchmarkEchoMessage(b *testing.B) {
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}}
+	conn := &discardConn{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := server.echoMessage(conn, benchEcho); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEchoMessage_Decoding measures the map round trip the fast path avoids
+func BenchmarkEchoMessage_Decoding(b *testing.B) {
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}}
+	conn := &discardConn{}
+	raw := []byte(`{"version":"1.0","type":"echo","message":"hello from the benchmark","meta":{"seq":42,"tags":["a","b"]},"timestamp":"old"}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := server.echoMessage(conn, raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
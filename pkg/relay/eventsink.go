@@ -0,0 +1,207 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/redact"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// MetricSinkDropped counts events dropped because the sink's buffer was full
+const MetricSinkDropped = "relay_event_sink_dropped_total"
+
+// Event kinds published by EventSink
+const (
+	SinkKindLifecycle = "lifecycle"
+	SinkKindOutput    = "agent_output"
+	SinkKindToolAudit = "tool_audit"
+)
+
+// Publisher sends a payload to a message queue topic (NATS subject, Kafka topic)
+type Publisher interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+}
+
+// SinkEvent is the JSON payload published for each event
+type SinkEvent struct {
+	Kind      string `json:"kind"`
+	Time      string `json:"time"`
+	SessionID string `json:"sessionId"`
+	AgentID   string `json:"agentId,omitempty"`
+	Name      string `json:"name,omitempty"`
+	From      string `json:"from,omitempty"`  // lifecycle
+	To        string `json:"to,omitempty"`    // lifecycle
+	Event     string `json:"event,omitempty"` // lifecycle
+	Reason    string `json:"reason,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Content   string `json:"content,omitempty"`  // agent_output, redacted
+	UserID    string `json:"userId,omitempty"`   // tool_audit
+	Tool      string `json:"tool,omitempty"`     // tool_audit
+	Decision  string `json:"decision,omitempty"` // tool_audit
+	Approved  *bool  `json:"approved,omitempty"` // tool_audit
+}
+
+// sinkMessage is a queued publish
+type sinkMessage struct {
+	topic   string
+	payload []byte
+}
+
+// EventSink publishes session lifecycle events, agent outputs and tool call
+// decisions to a queue
+// so external systems can consume them without connecting to each relay node.
+// Events are buffered and published by Run; when the buffer is full new
+// events are dropped rather than blocking session transitions.
+type EventSink struct {
+	publisher Publisher
+	clock     Clock
+	logger    Logger
+	metrics   Metrics
+	queue     chan sinkMessage
+	prefix    string
+	dropped   atomic.Int64
+}
+
+// NewEventSink creates a sink publishing to "<prefix>.lifecycle",
+// "<prefix>.agent_output" and "<prefix>.tool_audit"
+func NewEventSink(publisher Publisher, prefix string, buffer int, clock Clock, logger Logger, metrics Metrics) *EventSink {
+	if buffer < 1 {
+		buffer = 1
+	}
+	if metrics == nil {
+		metrics = &NoOpMetrics{}
+	}
+	return &EventSink{
+		publisher: publisher,
+		clock:     clock,
+		logger:    logger,
+		metrics:   metrics,
+		queue:     make(chan sinkMessage, buffer),
+		prefix:    prefix,
+	}
+}
+
+// Topic returns the topic events of a kind are published to
+func (s *EventSink) Topic(kind string) string {
+	return s.prefix + "." + kind
+}
+
+// Dropped returns how many events were dropped because the buffer was full
+func (s *EventSink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// HandleLifecycle queues a lifecycle event
+// Subscribe it to Manager.Events
+func (s *EventSink) HandleLifecycle(event session.LifecycleEvent) {
+	e := SinkEvent{
+		Kind:      SinkKindLifecycle,
+		Time:      FormatTimestamp(event.Time),
+		SessionID: event.SessionID,
+		AgentID:   event.AgentID,
+		Name:      event.Name,
+		From:      string(event.From),
+		To:        string(event.To),
+		Event:     string(event.Event),
+		Reason:    event.Reason,
+	}
+	if event.Err != nil {
+		e.Error = event.Err.Error()
+	}
+	s.enqueue(e)
+}
+
+// HandleToolAudit queues a tool call decision
+// Pass it to session.WithToolAuditor. Tool arguments are not published.
+func (s *EventSink) HandleToolAudit(entry session.ToolAuditEntry) {
+	approved := entry.Approved
+	s.enqueue(SinkEvent{
+		Kind:      SinkKindToolAudit,
+		Time:      FormatTimestamp(entry.Time),
+		SessionID: entry.SessionID,
+		AgentID:   entry.AgentID,
+		UserID:    entry.UserID,
+		Tool:      entry.Tool,
+		Decision:  string(entry.Decision),
+		Approved:  &approved,
+	})
+}
+
+// Middleware returns agent request middleware that queues each agent reply
+// Text content is passed through redactor before it leaves the relay
+func (s *EventSink) Middleware(redactor *redact.Redactor) session.Middleware {
+	return func(next session.SendFunc) session.SendFunc {
+		return func(ctx context.Context, req session.AgentRequest) (*acp.AgentMessage, error) {
+			msg, err := next(ctx, req)
+			e := SinkEvent{
+				Kind:      SinkKindOutput,
+				Time:      FormatTimestamp(s.clock.Now()),
+				SessionID: req.SessionID,
+				AgentID:   req.AgentID,
+			}
+			if err != nil {
+				e.Error = redactor.String(err.Error())
+			} else {
+				e.Content = redactor.String(outputText(msg))
+			}
+			s.enqueue(e)
+			return msg, err
+		}
+	}
+}
+
+// Run publishes queued events until ctx is done
+// Publish failures are logged and the event is dropped; Publishers that need
+// delivery guarantees should retry internally
+func (s *EventSink) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-s.queue:
+			if err := s.publisher.Publish(ctx, m.topic, m.payload); err != nil {
+				s.logger.Printf("Event sink publish failed: topic=%s err=%v", m.topic, err)
+			}
+		}
+	}
+}
+
+// enqueue serializes an event and queues it without blocking
+func (s *EventSink) enqueue(e SinkEvent) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		s.logger.Printf("Event sink marshal failed: %v", err)
+		return
+	}
+	select {
+	case s.queue <- sinkMessage{topic: s.Topic(e.Kind), payload: payload}:
+	default:
+		s.dropped.Add(1)
+		s.metrics.IncCounter(MetricSinkDropped)
+	}
+}
+
+// outputText flattens the human-readable parts of an agent reply
+func outputText(msg *acp.AgentMessage) string {
+	if msg == nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range msg.AllParts() {
+		switch part.Type {
+		case acp.PartTypeText, acp.PartTypeCode:
+			texts = append(texts, part.Text)
+		case acp.PartTypePatch:
+			texts = append(texts, part.Diff)
+		case acp.PartTypeError:
+			if part.Error != nil {
+				texts = append(texts, part.Error.Message)
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,193 @@
+package relay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/redact"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+type publishedMessage struct {
+	topic   string
+	payload []byte
+}
+
+type mockPublisher struct {
+	published chan publishedMessage
+}
+
+func (m *mockPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	m.published <- publishedMessage{topic: topic, payload: payload}
+	return nil
+}
+
+// nextEvent waits for the sink to publish one event
+func nextEvent(t *testing.T, pub *mockPublisher) (string, SinkEvent) {
+	t.Helper()
+	select {
+	case m := <-pub.published:
+		var e SinkEvent
+		if err := json.Unmarshal(m.payload, &e); err != nil {
+			t.Fatalf("invalid payload %s: %v", m.payload, err)
+		}
+		return m.topic, e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for publish")
+		return "", SinkEvent{}
+	}
+}
+
+func TestEventSink_PublishesLifecycle(t *testing.T) {
+	pub := &mockPublisher{published: make(chan publishedMessage, 1)}
+	sink := NewEventSink(pub, "relay", 8, &mockClock{now: testTime}, &mockLogger{}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sink.Run(ctx)
+
+	sink.HandleLifecycle(session.LifecycleEvent{
+		Time:      testTime,
+		Err:       errors.New("agent crashed"),
+		SessionID: "session-1",
+		From:      session.StateActive,
+		To:        session.StateTerminating,
+		Event:     session.EventTerminate,
+	})
+
+	topic, e := nextEvent(t, pub)
+	if topic != "relay.lifecycle" {
+		t.Errorf("expected topic relay.lifecycle, got %s", topic)
+	}
+	if e.Kind != SinkKindLifecycle || e.SessionID != "session-1" || e.To != "TERMINATING" ||
+		e.Error != "agent crashed" || e.Time != "2025-10-23T12:00:00Z" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
+
+func TestEventSink_PublishesToolAudit(t *testing.T) {
+	pub := &mockPublisher{published: make(chan publishedMessage, 1)}
+	sink := NewEventSink(pub, "relay", 8, &mockClock{now: testTime}, &mockLogger{}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sink.Run(ctx)
+
+	sink.HandleToolAudit(session.ToolAuditEntry{
+		Time:       testTime,
+		Args:       map[string]interface{}{"command": "rm -rf /"},
+		SessionID:  "session-1",
+		AgentID:    "auth",
+		ToolCallID: "tool-1",
+		Tool:       "bash",
+		Decision:   session.ToolDeny,
+	})
+
+	topic, e := nextEvent(t, pub)
+	if topic != "relay.tool_audit" {
+		t.Errorf("expected topic relay.tool_audit, got %s", topic)
+	}
+	if e.Kind != SinkKindToolAudit || e.Tool != "bash" || e.Decision != "deny" || e.Approved == nil || *e.Approved {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if strings.Contains(e.Content, "rm") {
+		t.Errorf("expected tool args not to be published, got %+v", e)
+	}
+}
+
+func TestEventSink_MiddlewareRedactsOutputs(t *testing.T) {
+	pub := &mockPublisher{published: make(chan publishedMessage, 1)}
+	sink := NewEventSink(pub, "relay", 8, &mockClock{now: testTime}, &mockLogger{}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sink.Run(ctx)
+
+	redactor, err := redact.New(redact.ModeStrip, redact.DefaultPatterns...)
+	if err != nil {
+		t.Fatalf("redact.New failed: %v", err)
+	}
+	send := sink.Middleware(redactor)(func(ctx context.Context, req session.AgentRequest) (*acp.AgentMessage, error) {
+		return &acp.AgentMessage{Type: "text", Content: "key is sk-ant-REDACTED"}, nil
+	})
+	if _, err := send(ctx, session.AgentRequest{SessionID: "session-1", AgentID: "agent-1"}); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	topic, e := nextEvent(t, pub)
+	if topic != "relay.agent_output" || e.Kind != SinkKindOutput || e.AgentID != "agent-1" {
+		t.Errorf("unexpected event on %s: %+v", topic, e)
+	}
+	if strings.Contains(e.Content, "sk-ant-") || !strings.HasPrefix(e.Content, "key is ") {
+		t.Errorf("expected redacted content, got %q", e.Content)
+	}
+}
+
+func TestEventSink_DropsWhenFull(t *testing.T) {
+	pub := &mockPublisher{published: make(chan publishedMessage, 8)}
+	sink := NewEventSink(pub, "relay", 1, &mockClock{now: testTime}, &mockLogger{}, nil)
+
+	// Not running: the second event finds the buffer full
+	sink.HandleLifecycle(session.LifecycleEvent{SessionID: "a"})
+	sink.HandleLifecycle(session.LifecycleEvent{SessionID: "b"})
+
+	if sink.Dropped() != 1 {
+		t.Errorf("expected 1 dropped event, got %d", sink.Dropped())
+	}
+}
+
+func TestNATSPublisher_Publish(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+
+	// Fake server: handshake, then report the first PUB frame
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		r := bufio.NewReader(conn)
+		connect, _ := r.ReadString('\n')
+		pub, _ := r.ReadString('\n')
+		payload, _ := r.ReadString('\n')
+		received <- connect + pub + payload
+	}()
+
+	p, err := NewNATSPublisher("nats://"+ln.Addr().String(), &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewNATSPublisher failed: %v", err)
+	}
+	defer p.Close()
+
+	if err := p.Publish(context.Background(), "relay.lifecycle", []byte(`{"kind":"lifecycle"}`)); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if !strings.HasPrefix(got, "CONNECT {") ||
+			!strings.Contains(got, "PUB relay.lifecycle 20\r\n{\"kind\":\"lifecycle\"}\r\n") {
+			t.Errorf("unexpected wire data %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server did not receive PUB")
+	}
+}
+
+func TestNewNATSPublisher_InvalidURL(t *testing.T) {
+	for _, u := range []string{"kafka://broker:9092", "nats://", "127.0.0.1:4222"} {
+		if _, err := NewNATSPublisher(u, &mockLogger{}); err == nil {
+			t.Errorf("expected error for %q", u)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// ErrUnknownAgentKind is returned for agent configs naming a kind the relay
+// has no factory for
+var ErrUnknownAgentKind = errors.New("unknown agent kind")
+
+// DefaultContainerRuntime runs AgentContainer agents unless Runtime is set
+const DefaultContainerRuntime = "docker"
+
+// workspacePlaceholder in agent arguments is replaced by the session's workspace
+const workspacePlaceholder = "{workspace}"
+
+// AgentContainer runs the agent command inside a container image, with the
+// workspace mounted at its own path as the working directory
+// The relay's agent environment and ANTHROPIC_API_KEY are passed in by name,
+// so their values stay off the command line. The agent exits when its stdin
+// closes, which removes the container.
+type AgentContainer struct {
+	Runtime string      `json:"runtime,omitempty"` // CLI accepting docker run's flags; default "docker"
+	Image   string      `json:"image"`
+	Limits  AgentLimits `json:"limits,omitempty"`
+}
+
+// AgentLimits caps the resources of a containerized agent; zero values are unlimited
+type AgentLimits struct {
+	MemoryBytes int64   `json:"memoryBytes,omitempty"`
+	CPUs        float64 `json:"cpus,omitempty"`
+	Pids        int     `json:"pids,omitempty"` // Processes and threads
+}
+
+// runtime returns the container CLI to run
+func (c *AgentContainer) runtime() string {
+	if c.Runtime == "" {
+		return DefaultContainerRuntime
+	}
+	return c.Runtime
+}
+
+// runArgs builds the arguments of `<runtime> run` starting command in the
+// image (pure function)
+func (c *AgentContainer) runArgs(workspace string, envNames []string, command []string) []string {
+	args := []string{"run", "--rm", "-i", "-v", workspace + ":" + workspace, "-w", workspace}
+	if c.Limits.MemoryBytes > 0 {
+		args = append(args, "--memory", strconv.FormatInt(c.Limits.MemoryBytes, 10))
+	}
+	if c.Limits.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(c.Limits.CPUs, 'f', -1, 64))
+	}
+	if c.Limits.Pids > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(c.Limits.Pids))
+	}
+	for _, name := range envNames {
+		args = append(args, "-e", name)
+	}
+	args = append(args, c.Image)
+	return append(args, command...)
+}
+
+// commandLine returns the process NewAgent starts for workspace; ok is false
+// when acp's default command line applies
+// The default "--workspace <workspace>" arguments go only to the default command.
+func (f *ACPAgentFactory) commandLine(workspace string, env []string) (path string, args []string, ok bool) {
+	switch {
+	case f.customArgs:
+		args = expandWorkspace(f.args, workspace)
+	case f.command == acp.DefaultCommand:
+		args = []string{"--workspace", workspace}
+	}
+	if f.container != nil {
+		command := append([]string{f.command}, args...)
+		return f.container.runtime(), f.container.runArgs(workspace, envNames(env), command), true
+	}
+	if f.command == acp.DefaultCommand && !f.customArgs {
+		return "", nil, false
+	}
+	return f.command, args, true
+}
+
+// envNames lists the variables of env, and ANTHROPIC_API_KEY, once each for
+// a containerized agent (pure function)
+func envNames(env []string) []string {
+	names := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if !slices.Contains(names, "ANTHROPIC_API_KEY") {
+		names = append(names, "ANTHROPIC_API_KEY")
+	}
+	return names
+}
+
+// expandWorkspace replaces workspacePlaceholder in args (pure function)
+func expandWorkspace(args []string, workspace string) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		out[i] = strings.ReplaceAll(arg, workspacePlaceholder, workspace)
+	}
+	return out
+}
+
+// FactoryRegistry starts each agent with the factory of the kind its session's
+// agent config names (session.AgentConfig.Kind), or with the default factory
+// when it names none
+// Kinds may be registered and unregistered while agents start, e.g. by
+// AgentPlugins; agents already started are unaffected.
+type FactoryRegistry struct {
+	fallback AgentFactory
+
+	mu    sync.RWMutex
+	kinds map[string]AgentFactory
+}
+
+// NewFactoryRegistry creates a registry starting agents of no kind with fallback
+func NewFactoryRegistry(fallback AgentFactory) *FactoryRegistry {
+	return &FactoryRegistry{fallback: fallback, kinds: make(map[string]AgentFactory)}
+}
+
+// Register makes factory start the agents of kind, replacing any earlier one
+func (r *FactoryRegistry) Register(kind string, factory AgentFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kinds[kind] = factory
+}
+
+// Unregister removes a kind; sessions naming it can no longer start agents
+func (r *FactoryRegistry) Unregister(kind string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.kinds, kind)
+}
+
+// Has reports whether kind is registered
+func (r *FactoryRegistry) Has(kind string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.kinds[kind]
+	return ok
+}
+
+// Kinds returns the registered kinds, sorted
+func (r *FactoryRegistry) Kinds() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	kinds := make([]string, 0, len(r.kinds))
+	for kind := range r.kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// NewAgent starts an agent with the factory of the kind ctx's agent config names
+func (r *FactoryRegistry) NewAgent(ctx context.Context, role, workspace string) (session.ACPClient, error) {
+	factory, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return factory.NewAgent(ctx, role, workspace)
+}
+
+// CheckAgent reports whether the kind ctx's agent config names exists, and
+// whether its factory could start an agent (if it implements AgentChecker)
+func (r *FactoryRegistry) CheckAgent(ctx context.Context, role, workspace string) error {
+	factory, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	if checker, ok := factory.(AgentChecker); ok {
+		return checker.CheckAgent(ctx, role, workspace)
+	}
+	return nil
+}
+
+// resolve picks the factory for the agent config ctx carries
+func (r *FactoryRegistry) resolve(ctx context.Context) (AgentFactory, error) {
+	kind := session.AgentConfigFromContext(ctx).Kind
+	if kind == "" {
+		return r.fallback, nil
+	}
+	r.mu.RLock()
+	factory, ok := r.kinds[kind]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAgentKind, kind)
+	}
+	return factory, nil
+}
@@ -0,0 +1,74 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+func TestFactoryRegistry_ResolvesKinds(t *testing.T) {
+	fallback, sandboxed := &mockAgentFactory{}, &mockAgentFactory{}
+	registry := NewFactoryRegistry(fallback)
+	registry.Register("sandboxed", sandboxed)
+	spawner, manager := newTestSpawner(t, registry)
+	ctx := context.Background()
+	workspace := t.TempDir()
+
+	if _, err := spawner.SpawnAgent(ctx, &mockWebSocketConn{}, SpawnRequest{Role: "db", Workspace: workspace}); err != nil {
+		t.Fatalf("SpawnAgent failed: %v", err)
+	}
+	if _, err := spawner.SpawnAgent(ctx, &mockWebSocketConn{}, SpawnRequest{Role: "api", Workspace: workspace, Kind: "sandboxed",
+		Options: []session.CreateOption{session.WithAgentConfig(session.AgentConfig{Kind: "sandboxed"})}}); err != nil {
+		t.Fatalf("SpawnAgent failed: %v", err)
+	}
+	if fallback.clients["db"] == nil || sandboxed.clients["api"] == nil || fallback.clients["api"] != nil {
+		t.Errorf("expected each agent started by its kind's factory")
+	}
+
+	plan := spawner.Plan(ctx, SpawnRequest{Role: "ui", Workspace: workspace, Kind: "gpu"})
+	if plan.OK || !errors.Is(plan.Err(), ErrSpawnRejected) || !strings.Contains(plan.Err().Error(), "unknown agent kind") {
+		t.Errorf("expected an unknown kind rejected before spawning, got %+v", plan)
+	}
+	if manager.GetByRole("ui") != nil {
+		t.Error("expected nothing created for a plan")
+	}
+	if kinds := registry.Kinds(); len(kinds) != 1 || kinds[0] != "sandboxed" {
+		t.Errorf("unexpected kinds %v", kinds)
+	}
+}
+
+func TestACPAgentFactory_CommandLine(t *testing.T) {
+	tests := []struct {
+		name string
+		kind AgentKindConfig
+		want string // Empty when acp's default applies
+	}{
+		{"default", AgentKindConfig{}, ""},
+		{"custom command", AgentKindConfig{Command: "/opt/agent"}, "/opt/agent"},
+		{"args", AgentKindConfig{Command: "/opt/agent", Args: []string{"--root={workspace}", "-v"}}, "/opt/agent --root=/work -v"},
+		{"container", AgentKindConfig{
+			Image:  "agents/claude:1",
+			Env:    []string{"MODE=fast", "MODE=slow"},
+			Limits: AgentLimits{MemoryBytes: 1 << 30, CPUs: 1.5, Pids: 256},
+		}, "docker run --rm -i -v /work:/work -w /work --memory 1073741824 --cpus 1.5 --pids-limit 256 " +
+			"-e HTTPS_PROXY -e MODE -e ANTHROPIC_API_KEY agents/claude:1 claude-code-acp --workspace /work"},
+		{"container runtime", AgentKindConfig{Image: "agent", Runtime: "podman", Command: "run-agent"},
+			"podman run --rm -i -v /work:/work -w /work -e HTTPS_PROXY -e ANTHROPIC_API_KEY agent run-agent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.kind.NewFactory("key", &mockLogger{}, WithAgentEnv("HTTPS_PROXY=http://proxy:3128"))
+			path, args, ok := f.commandLine("/work", f.env)
+			got := strings.Join(append([]string{path}, args...), " ")
+			if !ok {
+				got = ""
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// FederationPath is where relays accept prompts forwarded by their peers
+const FederationPath = "/federation/v1/messages"
+
+// Federation request headers
+const (
+	federationRelayHeader     = "X-Ourocodus-Relay"
+	federationTimestampHeader = "X-Ourocodus-Timestamp"
+	federationSignatureHeader = "X-Ourocodus-Signature"
+)
+
+// federationMaxSkew bounds how old (or early) a signed request may be
+const federationMaxSkew = 5 * time.Minute
+
+// maxFederationBody caps forwarded request and response bodies
+const maxFederationBody = 16 << 20
+
+// MetricFederationRejected counts forwarded requests refused for a bad signature
+const MetricFederationRejected = "relay_federation_rejected_total"
+
+// ErrNoPeer is returned when no peer relay hosts a session's agent
+var ErrNoPeer = errors.New("no peer relay hosts the session's agent")
+
+// errNotHosted is a peer's answer for sessions whose agent runs elsewhere
+var errNotHosted = errors.New("session not hosted by peer")
+
+// FederationPeer is another relay sharing this relay's session store
+type FederationPeer struct {
+	ID  string `json:"id"`
+	URL string `json:"url"` // Base URL of the peer's HTTP listener, e.g. https://relay-2:8080
+}
+
+// PeerError is a prompt failure reported by the peer relay that ran it
+type PeerError struct {
+	Peer    string
+	Code    string // Protocol error code, as sent in agent:complete
+	Message string
+}
+
+func (e *PeerError) Error() string {
+	return fmt.Sprintf("peer %s: %s", e.Peer, e.Message)
+}
+
+// federationRequest is the body of a forwarded prompt
+type federationRequest struct {
+	SessionID string `json:"sessionId"`
+	UserID    string `json:"userId,omitempty"` // Who sent the prompt, for history attribution
+	Content   string `json:"content"`
+}
+
+// federationResponse carries the agent's reply or why it failed
+type federationResponse struct {
+	Message *acp.AgentMessage `json:"message,omitempty"`
+	Code    string            `json:"code,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// Federation (experimental) lets relays sharing a session store prompt agents
+// running on each other
+// A client attached to a session whose agent another relay spawned has its
+// agent:message forwarded to the peers in order until one hosts the agent.
+// Requests are signed with HMAC-SHA256 over the sending relay's ID, a Unix
+// timestamp and the body, keyed by a secret every relay shares; peers accept
+// requests up to five minutes old. Forwarded replies are not streamed: the
+// client only sees agent:complete. Peers never forward a request again.
+type Federation struct {
+	relayID string
+	key     []byte
+	peers   []FederationPeer
+	manager *session.Manager
+	client  *http.Client
+	clock   Clock
+	logger  Logger
+	metrics Metrics
+}
+
+// FederationOption configures a Federation
+type FederationOption func(*Federation)
+
+// WithFederationClient sets the HTTP client used to reach peers
+// Its Timeout bounds a whole forwarded prompt, agent reply included.
+func WithFederationClient(client *http.Client) FederationOption {
+	return func(f *Federation) { f.client = client }
+}
+
+// WithFederationMetrics records rejected peer requests
+func WithFederationMetrics(metrics Metrics) FederationOption {
+	return func(f *Federation) { f.metrics = metrics }
+}
+
+// NewFederation creates the federation endpoint of relay relayID, signing
+// with key (see NewFederationKey) and forwarding to peers
+func NewFederation(relayID string, key []byte, peers []FederationPeer, manager *session.Manager, clock Clock, logger Logger, opts ...FederationOption) *Federation {
+	f := &Federation{
+		relayID: relayID,
+		key:     key,
+		peers:   peers,
+		manager: manager,
+		client:  http.DefaultClient,
+		clock:   clock,
+		logger:  logger,
+		metrics: &NoOpMetrics{},
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Forward sends a prompt for a session whose agent runs on a peer relay and
+// returns the agent's reply
+// The user in ctx (session.WithUser) is passed on for history attribution.
+// Peers that are unreachable or do not host the agent are skipped; if none
+// does, the error wraps ErrNoPeer. Failures reported by the hosting peer are
+// returned as *PeerError.
+func (f *Federation) Forward(ctx context.Context, sessionID, content string) (*acp.AgentMessage, error) {
+	body, err := json.Marshal(federationRequest{SessionID: sessionID, UserID: session.UserFromContext(ctx), Content: content})
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, peer := range f.peers {
+		msg, err := f.forwardTo(ctx, peer, body)
+		if errors.Is(err, errNotHosted) {
+			continue
+		}
+		var peerErr *PeerError
+		if err == nil || errors.As(err, &peerErr) || ctx.Err() != nil {
+			return msg, err
+		}
+		f.logger.Printf("Federation peer unreachable: peer=%s session=%s err=%v", peer.ID, sessionID, err)
+		errs = append(errs, fmt.Errorf("%s: %w", peer.ID, err))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %s (%v)", ErrNoPeer, sessionID, errors.Join(errs...))
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoPeer, sessionID)
+}
+
+// forwardTo posts a signed prompt to one peer
+func (f *Federation) forwardTo(ctx context.Context, peer FederationPeer, body []byte) (*acp.AgentMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(peer.URL, "/")+FederationPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(f.clock.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(federationRelayHeader, f.relayID)
+	req.Header.Set(federationTimestampHeader, timestamp)
+	req.Header.Set(federationSignatureHeader, f.sign(f.relayID, timestamp, body))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotHosted
+	}
+	var out federationResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxFederationBody)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || out.Message == nil {
+		return nil, &PeerError{Peer: peer.ID, Code: out.Code, Message: out.Error}
+	}
+	return out.Message, nil
+}
+
+// ServeHTTP runs prompts forwarded by peers on the agents this relay hosts
+// Sessions without a local agent get 404 so the sender tries its next peer.
+func (f *Federation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxFederationBody))
+	if err != nil {
+		http.Error(w, "unreadable body", http.StatusBadRequest)
+		return
+	}
+	peer := r.Header.Get(federationRelayHeader)
+	if err := f.verify(peer, r.Header.Get(federationTimestampHeader), r.Header.Get(federationSignatureHeader), body); err != nil {
+		f.logger.Printf("Federation request rejected: peer=%q remote=%s err=%v", peer, r.RemoteAddr, err)
+		f.metrics.IncCounter(MetricFederationRejected)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req federationRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.SessionID == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	sess := f.manager.Get(req.SessionID)
+	if sess == nil || !hostsAgent(sess) {
+		http.Error(w, "session not hosted here", http.StatusNotFound)
+		return
+	}
+
+	ctx := session.WithUser(r.Context(), req.UserID)
+	msg, err := f.manager.SendMessage(ctx, req.SessionID, req.Content)
+	resp := federationResponse{Message: msg}
+	status := http.StatusOK
+	if err != nil {
+		resp = federationResponse{Code: agentErrorCode(err), Error: err.Error()}
+		status = http.StatusBadGateway
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		f.logger.Printf("Failed to send federation reply: peer=%s session=%s err=%v", peer, req.SessionID, err)
+	}
+}
+
+// verify checks a peer request's signature and freshness
+func (f *Federation) verify(peer, timestamp, signature string, body []byte) error {
+	if peer == "" || peer == f.relayID {
+		return fmt.Errorf("invalid relay ID %q", peer)
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if skew := f.clock.Now().Sub(time.Unix(unix, 0)); skew > federationMaxSkew || skew < -federationMaxSkew {
+		return fmt.Errorf("timestamp off by %v", skew)
+	}
+	if !hmac.Equal([]byte(signature), []byte(f.sign(peer, timestamp, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// sign returns the hex HMAC-SHA256 of a request from relayID
+func (f *Federation) sign(relayID, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write([]byte(relayID + "\n" + timestamp + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hostsAgent reports whether the session's agent runs on this relay
+func hostsAgent(sess *session.Session) bool {
+	handle := sess.GetHandle()
+	return handle != nil && handle.ACPClient != nil
+}
@@ -0,0 +1,134 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+var testFederationKey = []byte("0123456789abcdef0123456789abcdef")
+
+// setupPeer serves relay-b's federation endpoint for a session running client
+func setupPeer(t *testing.T, client session.ACPClient) *httptest.Server {
+	t.Helper()
+	streamer, _ := setupStreamer(t, client)
+	peer := NewFederation("relay-b", testFederationKey, nil, streamer.manager, &mockClock{now: testTime}, &mockLogger{})
+	srv := httptest.NewServer(peer)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// setupRemoteSession returns a streamer of relay-a for session-1, attached to
+// a client but with its agent on another relay
+func setupRemoteSession(t *testing.T, peers []FederationPeer) (*AgentStreamer, *mockWebSocketConn) {
+	t.Helper()
+	conn := &mockWebSocketConn{}
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"})
+	if _, err := manager.Create(context.Background(), "auth", conn); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	conn.written = nil
+	federation := NewFederation("relay-a", testFederationKey, peers, manager, &mockClock{now: testTime}, &mockLogger{})
+	return NewAgentStreamer(manager, &mockClock{now: testTime}, &mockLogger{}, WithForwarder(federation)), conn
+}
+
+func TestFederation_ForwardsToHostingPeer(t *testing.T) {
+	bystander := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer bystander.Close()
+	host := setupPeer(t, &mockStreamingACPClient{chunks: []string{"pong"}})
+
+	streamer, conn := setupRemoteSession(t, []FederationPeer{{ID: "relay-c", URL: bystander.URL}, {ID: "relay-b", URL: host.URL + "/"}})
+	msg, err := streamer.Stream(context.Background(), "session-1", "req-1", "ping")
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if parts := msg.AllParts(); len(parts) != 1 || parts[0].Text != "Echo: ping" {
+		t.Errorf("expected peer's reply, got %+v", parts)
+	}
+	if len(conn.written) != 1 {
+		t.Fatalf("expected only agent:complete, got %d messages", len(conn.written))
+	}
+	complete, ok := conn.written[0].(AgentCompleteMessage)
+	if !ok || complete.Error != nil || len(complete.Parts) != 1 || complete.Parts[0].Text != "Echo: ping" {
+		t.Errorf("unexpected complete: %+v", conn.written[0])
+	}
+}
+
+func TestFederation_NoPeerHostsAgent(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	streamer, conn := setupRemoteSession(t, []FederationPeer{{ID: "relay-b", URL: unreachable.URL}})
+	_, err := streamer.Stream(context.Background(), "session-1", "req-1", "ping")
+	if !errors.Is(err, ErrNoPeer) {
+		t.Fatalf("expected ErrNoPeer, got %v", err)
+	}
+	complete := conn.written[0].(AgentCompleteMessage)
+	if complete.Error == nil || complete.Error.Code != "AGENT_REQUEST_FAILED" {
+		t.Errorf("expected failed complete, got %+v", complete)
+	}
+}
+
+func TestFederation_PeerErrorKeepsCode(t *testing.T) {
+	host := setupPeer(t, &mockStreamingACPClient{err: session.ErrSessionBusy})
+
+	streamer, conn := setupRemoteSession(t, []FederationPeer{{ID: "relay-b", URL: host.URL}})
+	_, err := streamer.Stream(context.Background(), "session-1", "req-1", "ping")
+	var peerErr *PeerError
+	if !errors.As(err, &peerErr) || peerErr.Peer != "relay-b" {
+		t.Fatalf("expected PeerError from relay-b, got %v", err)
+	}
+	if complete := conn.written[0].(AgentCompleteMessage); complete.Error == nil || complete.Error.Code != "BUSY" {
+		t.Errorf("expected peer's BUSY code, got %+v", complete.Error)
+	}
+}
+
+func TestFederation_RejectsUnsignedRequests(t *testing.T) {
+	metrics := NewCounterMetrics()
+	streamer, _ := setupStreamer(t, &mockStreamingACPClient{chunks: []string{"pong"}})
+	peer := NewFederation("relay-b", testFederationKey, nil, streamer.manager, &mockClock{now: testTime}, &mockLogger{},
+		WithFederationMetrics(metrics))
+	sender := NewFederation("relay-a", testFederationKey, nil, nil, &mockClock{now: testTime}, &mockLogger{})
+	body := []byte(`{"sessionId":"session-1","content":"ping"}`)
+	now := strconv.FormatInt(testTime.Unix(), 10)
+	stale := strconv.FormatInt(testTime.Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		relayID   string
+		timestamp string
+		signature string
+		want      int
+	}{
+		{"valid", "relay-a", now, sender.sign("relay-a", now, body), http.StatusOK},
+		{"wrong key", "relay-a", now, "deadbeef", http.StatusUnauthorized},
+		{"signed for another relay", "relay-c", now, sender.sign("relay-a", now, body), http.StatusUnauthorized},
+		{"stale", "relay-a", stale, sender.sign("relay-a", stale, body), http.StatusUnauthorized},
+		{"own relay ID", "relay-b", now, sender.sign("relay-b", now, body), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, FederationPath, bytes.NewReader(body))
+			req.Header.Set(federationRelayHeader, tt.relayID)
+			req.Header.Set(federationTimestampHeader, tt.timestamp)
+			req.Header.Set(federationSignatureHeader, tt.signature)
+			rec := httptest.NewRecorder()
+			peer.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+	if got := metrics.Value(MetricFederationRejected); got != 4 {
+		t.Errorf("expected 4 rejections counted, got %d", got)
+	}
+}
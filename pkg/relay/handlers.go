@@ -0,0 +1,86 @@
+package relay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// messageHandler handles one client message type
+type messageHandler struct {
+	// enabled reports whether the server has the collaborator the handler
+	// needs; nil means always
+	enabled func(s *Server) bool
+	// handle processes the message and returns true if the connection
+	// should be closed
+	handle func(s *Server, conn WebSocketConn, rawMessage []byte) bool
+}
+
+// clientHandlers routes every client message type the relay handles
+// A type whose collaborator is not configured is unknown to that server.
+// TestClientHandlers_MatchProtocol keeps it in step with Protocol.
+var clientHandlers = map[string]messageHandler{
+	"echo":                         {handle: (*Server).handleEcho},
+	"connection:whoami":            {handle: func(s *Server, conn WebSocketConn, _ []byte) bool { s.handleWhoami(conn); return false }},
+	"agent:message":                {enabled: hasStreamer, handle: handled((*Server).handleAgentSend)},
+	"agent:cancel":                 {enabled: hasStreamer, handle: handled((*Server).handleAgentCancel)},
+	"agent:tool_decision":          {enabled: hasStreamer, handle: handled((*Server).handleAgentToolDecision)},
+	"session:reattach":             {enabled: hasStreamer, handle: handled((*Server).handleSessionReattach)},
+	"session:observe":              {enabled: hasStreamer, handle: handled((*Server).handleSessionObserve)},
+	"session:share":                {enabled: hasStreamer, handle: handled((*Server).handleSessionShare)},
+	"session:unshare":              {enabled: hasStreamer, handle: handled((*Server).handleSessionShare)},
+	"session:transfer":             {enabled: hasStreamer, handle: handled((*Server).handleSessionTransfer)},
+	"agent:spawn":                  {enabled: hasSpawner, handle: handled((*Server).handleAgentSpawn)},
+	"session:create_from_template": {enabled: hasSpawner, handle: handled((*Server).handleCreateFromTemplate)},
+	"git:open_pr":                  {enabled: hasPullRequests, handle: handled((*Server).handleOpenPR)},
+}
+
+func hasStreamer(s *Server) bool     { return s.streamer != nil }
+func hasSpawner(s *Server) bool      { return s.spawner != nil }
+func hasPullRequests(s *Server) bool { return s.pullRequests != nil }
+
+// handled adapts a handler that never closes the connection
+func handled(f func(s *Server, conn WebSocketConn, rawMessage []byte)) func(*Server, WebSocketConn, []byte) bool {
+	return func(s *Server, conn WebSocketConn, rawMessage []byte) bool {
+		f(s, conn, rawMessage)
+		return false
+	}
+}
+
+// handlerFor returns the handler for a message type, if this server has one
+func (s *Server) handlerFor(messageType string) (messageHandler, bool) {
+	h, ok := clientHandlers[messageType]
+	if !ok || (h.enabled != nil && !h.enabled(s)) {
+		return messageHandler{}, false
+	}
+	return h, true
+}
+
+// supportedTypes lists the client message types this server handles, sorted
+func (s *Server) supportedTypes() []string {
+	var types []string
+	for t := range clientHandlers {
+		if _, ok := s.handlerFor(t); ok {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// handleEcho timestamps and echoes an echo message back
+// Returns true if the echo could not be written
+func (s *Server) handleEcho(conn WebSocketConn, rawMessage []byte) bool {
+	return s.echoMessage(conn, rawMessage) != nil
+}
+
+// handleUnknownType answers a message no handler accepts with UNKNOWN_TYPE,
+// listing the types this server supports
+func (s *Server) handleUnknownType(conn WebSocketConn, messageType string) {
+	msg := NewErrorMessage("UNKNOWN_TYPE",
+		fmt.Sprintf("Unknown message type %q; supported types: %s", messageType, strings.Join(s.supportedTypes(), ", ")),
+		true)
+	if err := conn.WriteJSON(msg); err != nil {
+		s.logger.Printf("Failed to send unknown type error: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package relay
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientHandlers_MatchProtocol(t *testing.T) {
+	listed := make(map[string]bool)
+	for _, spec := range Protocol {
+		if spec.Direction != FromClient {
+			continue
+		}
+		listed[spec.Type] = true
+		if _, ok := clientHandlers[spec.Type]; !ok {
+			t.Errorf("client type %s has no handler", spec.Type)
+		}
+	}
+	for messageType := range clientHandlers {
+		if !listed[messageType] {
+			t.Errorf("handler for %s is not in Protocol", messageType)
+		}
+	}
+}
+
+func TestHandleMessage_UnknownType(t *testing.T) {
+	conn := &mockWebSocketConn{}
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}}
+
+	if server.handleMessage(conn, []byte(`{"version":"1.0","type":"bogus"}`)) {
+		t.Error("expected an unknown type to keep the connection open")
+	}
+
+	if len(conn.written) != 1 {
+		t.Fatalf("expected 1 message written, got %d", len(conn.written))
+	}
+	msg, ok := conn.written[0].(ErrorMessage)
+	if !ok {
+		t.Fatalf("expected ErrorMessage, got %T", conn.written[0])
+	}
+	if msg.Error.Code != "UNKNOWN_TYPE" || !msg.Error.Recoverable {
+		t.Errorf("expected recoverable UNKNOWN_TYPE, got %+v", msg.Error)
+	}
+	if !strings.Contains(msg.Error.Message, `"bogus"`) || !strings.Contains(msg.Error.Message, "connection:whoami, echo") {
+		t.Errorf("expected the type and supported types in %q", msg.Error.Message)
+	}
+}
+
+func TestHandleMessage_UnconfiguredHandlerIsUnknown(t *testing.T) {
+	conn := &mockWebSocketConn{}
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}}
+
+	server.handleMessage(conn, []byte(`{"version":"1.0","type":"agent:spawn","role":"auth"}`))
+
+	msg, ok := conn.written[0].(ErrorMessage)
+	if !ok || msg.Error.Code != "UNKNOWN_TYPE" {
+		t.Fatalf("expected UNKNOWN_TYPE without a spawner, got %+v", conn.written[0])
+	}
+	if strings.Contains(msg.Error.Message, "agent:spawn,") {
+		t.Errorf("expected agent:spawn not to be listed as supported: %q", msg.Error.Message)
+	}
+}
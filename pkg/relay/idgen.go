@@ -0,0 +1,129 @@
+package relay
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ID generation strategies selectable via IDConfig.Strategy
+const (
+	IDStrategyUUID = "uuid"
+	IDStrategyULID = "ulid"
+)
+
+// crockfordAlphabet is the base32 alphabet used by ULIDs (no I, L, O, U)
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator generates lexicographically sortable ULIDs
+// 48-bit millisecond timestamp followed by 80 bits of randomness, encoded as
+// 26 Crockford base32 characters. IDs generated within the same millisecond
+// increment the random part so ordering is preserved (monotonic ULIDs); if
+// that overflows, the timestamp is advanced by a millisecond instead.
+type ULIDGenerator struct {
+	now      func() time.Time
+	entropy  io.Reader
+	lastMs   uint64
+	lastRand [10]byte
+	mu       sync.Mutex
+}
+
+// NewULIDGenerator creates a ULID generator using the system clock and crypto/rand
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{now: time.Now, entropy: rand.Reader}
+}
+
+// Generate returns a new ULID string
+// Panics if the entropy source fails, matching uuid.New behavior
+func (g *ULIDGenerator) Generate() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixMilli())
+	if ms <= g.lastMs {
+		// Same millisecond (or clock stepped back): stay monotonic
+		ms = g.lastMs
+		if incrementEntropy(&g.lastRand) {
+			ms++
+			g.lastMs = ms
+		}
+	} else {
+		if _, err := io.ReadFull(g.entropy, g.lastRand[:]); err != nil {
+			panic(fmt.Sprintf("ulid: entropy source failed: %v", err))
+		}
+		g.lastMs = ms
+	}
+
+	return encodeULID(ms, g.lastRand)
+}
+
+// incrementEntropy adds one to the 80-bit random component
+// Returns true if it wrapped around to zero
+func incrementEntropy(b *[10]byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeULID renders timestamp and entropy as 26 Crockford base32 characters (pure function)
+func encodeULID(ms uint64, entropy [10]byte) string {
+	var raw [16]byte
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(raw[:6], ts[2:])
+	copy(raw[6:], entropy[:])
+
+	// 128 bits → 26 characters of 5 bits each (first character carries 3 bits)
+	var out [26]byte
+	hi := binary.BigEndian.Uint64(raw[:8])
+	lo := binary.BigEndian.Uint64(raw[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&0x1f]
+		lo = (lo >> 5) | (hi << 59)
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// PrefixedIDGenerator prepends an entity prefix (e.g. "sess_") to generated IDs
+// so IDs are distinguishable by entity type in logs
+type PrefixedIDGenerator struct {
+	next   IDGenerator
+	prefix string
+}
+
+// NewPrefixedIDGenerator wraps next so every ID starts with prefix
+func NewPrefixedIDGenerator(prefix string, next IDGenerator) *PrefixedIDGenerator {
+	return &PrefixedIDGenerator{prefix: prefix, next: next}
+}
+
+// Generate returns the wrapped generator's ID with the prefix prepended
+func (g *PrefixedIDGenerator) Generate() string {
+	return g.prefix + g.next.Generate()
+}
+
+// NewIDGenerator builds a generator for the given strategy and optional prefix
+// An empty strategy selects UUIDs
+func NewIDGenerator(strategy, prefix string) (IDGenerator, error) {
+	var gen IDGenerator
+	switch strategy {
+	case "", IDStrategyUUID:
+		gen = &UUIDGenerator{}
+	case IDStrategyULID:
+		gen = NewULIDGenerator()
+	default:
+		return nil, fmt.Errorf("unknown ID strategy: %s", strategy)
+	}
+
+	if prefix != "" {
+		gen = NewPrefixedIDGenerator(prefix, gen)
+	}
+	return gen, nil
+}
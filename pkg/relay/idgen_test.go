@@ -0,0 +1,86 @@
+package relay
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestULIDGenerator_FormatAndOrdering(t *testing.T) {
+	now := time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)
+	gen := &ULIDGenerator{
+		now:     func() time.Time { return now },
+		entropy: bytes.NewReader(bytes.Repeat([]byte{0xff}, 64)),
+	}
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, gen.Generate())
+		now = now.Add(time.Millisecond)
+	}
+	// Same millisecond: entropy is incremented rather than re-read
+	now = now.Add(-time.Millisecond)
+	ids = append(ids, gen.Generate())
+
+	for _, id := range ids {
+		if len(id) != 26 {
+			t.Errorf("expected 26-character ULID, got %q", id)
+		}
+		if strings.ContainsAny(id, "ILOU") {
+			t.Errorf("ULID %q contains characters outside the Crockford alphabet", id)
+		}
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("expected ULIDs to sort by generation time, got %v", ids)
+	}
+	if ids[2] == ids[3] {
+		t.Error("expected distinct ULIDs within the same millisecond")
+	}
+}
+
+func TestEncodeULID_KnownValue(t *testing.T) {
+	var zero [10]byte
+	if got := encodeULID(0, zero); got != "00000000000000000000000000" {
+		t.Errorf("unexpected encoding of zero ULID: %s", got)
+	}
+
+	var max [10]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+	if got := encodeULID(1<<48-1, max); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("unexpected encoding of max ULID: %s", got)
+	}
+}
+
+func TestNewIDGenerator(t *testing.T) {
+	tests := []struct {
+		strategy string
+		prefix   string
+		length   int
+		wantErr  bool
+	}{
+		{"", "", 36, false},
+		{IDStrategyUUID, "sess_", 41, false},
+		{IDStrategyULID, "agt_", 30, false},
+		{"snowflake", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy+"/"+tt.prefix, func(t *testing.T) {
+			gen, err := NewIDGenerator(tt.strategy, tt.prefix)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			id := gen.Generate()
+			if !strings.HasPrefix(id, tt.prefix) || len(id) != tt.length {
+				t.Errorf("unexpected ID %q", id)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+// Package integration tests the session manager against real agent processes
+//
+// The tests are behind the integration build tag and drive bin/echo-agent
+// through relay.ACPAgentFactory, so build first:
+//
+//	make build && go test -tags integration ./pkg/relay/integration/
+package integration
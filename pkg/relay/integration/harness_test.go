@@ -0,0 +1,153 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// testLogger sends relay and agent logs to the test log
+type testLogger struct{ t *testing.T }
+
+func (l testLogger) Printf(format string, v ...interface{}) {
+	l.t.Helper()
+	l.t.Logf(format, v...)
+}
+
+// discardConn stands in for a client's WebSocket, dropping what it is sent
+type discardConn struct{}
+
+func (discardConn) WriteJSON(v interface{}) error { return nil }
+func (discardConn) ReadMessage() (int, []byte, error) {
+	return 0, nil, io.EOF
+}
+func (discardConn) Close() error { return nil }
+
+// harness is a session manager and spawner starting real echo-agent processes
+type harness struct {
+	t       *testing.T
+	manager *session.Manager
+	spawner *relay.Spawner
+
+	mu   sync.Mutex
+	pids []int // Every agent process started, for leak checks
+}
+
+// newHarness builds a manager whose agents run bin/echo-agent with the given
+// extra environment (e.g. ECHO_AGENT_LATENCY=1s)
+// The test fails if any agent process outlives it.
+func newHarness(t *testing.T, env ...string) *harness {
+	t.Helper()
+	logger := testLogger{t}
+	manager := relay.NewSessionManager(logger, relay.SystemClock{}, &relay.UUIDGenerator{})
+	factory := relay.NewACPAgentFactory("test-api-key", echoAgentPath(t), logger, relay.WithAgentEnv(env...))
+	h := &harness{t: t, manager: manager, spawner: relay.NewSpawner(manager, factory, nil, logger)}
+	t.Cleanup(func() {
+		for _, sess := range manager.List(nil) {
+			if _, err := manager.PurgeOwner(context.Background(), sess.GetOwnerID()); err != nil {
+				t.Errorf("Failed to clean up session %s: %v", sess.GetID(), err)
+			}
+		}
+		h.assertNoLeaks()
+	})
+	return h
+}
+
+// echoAgentPath returns the echo-agent binary built by make build
+func echoAgentPath(t *testing.T) string {
+	t.Helper()
+	name := "echo-agent"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	path, err := filepath.Abs(filepath.Join("../../../bin", name))
+	if err != nil {
+		t.Fatalf("Failed to get echo-agent path: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("echo-agent binary not found, run 'make build' first: %v", err)
+	}
+	return path
+}
+
+// spawn starts an agent for role, owned by owner, in a fresh workspace
+func (h *harness) spawn(role, owner string, opts ...session.CreateOption) (*session.Session, error) {
+	sess, err := h.spawner.SpawnAgent(context.Background(), discardConn{}, relay.SpawnRequest{
+		Role:      role,
+		Workspace: h.t.TempDir(),
+		OwnerID:   owner,
+		Options:   opts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pid := agentPID(sess); pid != 0 {
+		h.mu.Lock()
+		h.pids = append(h.pids, pid)
+		h.mu.Unlock()
+	}
+	return sess, nil
+}
+
+// agentPID returns the process ID of a session's agent (0 if none)
+func agentPID(sess *session.Session) int {
+	handle := sess.GetHandle()
+	if handle == nil {
+		return 0
+	}
+	if p, ok := handle.ACPClient.(interface{ PID() int }); ok {
+		return p.PID()
+	}
+	return 0
+}
+
+// assertNoLeaks fails the test if an agent process started by the harness is
+// still running
+// Processes are polled briefly since a killed process takes a moment to go.
+func (h *harness) assertNoLeaks() {
+	h.t.Helper()
+	if runtime.GOOS == "windows" {
+		h.t.Log("Leak check skipped: signal 0 is not supported on Windows")
+		return
+	}
+	h.mu.Lock()
+	pids := append([]int(nil), h.pids...)
+	h.mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for _, pid := range pids {
+		for alive(pid) {
+			if time.Now().After(deadline) {
+				h.t.Errorf("agent process %d leaked", pid)
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// alive reports whether a process exists
+func alive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return proc.Signal(syscall.Signal(0)) == nil
+}
+
+// role names the i-th agent of a test
+func role(i int) string {
+	return fmt.Sprintf("agent-%d", i)
+}
@@ -0,0 +1,134 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+func TestSpawnAndTerminate_Concurrent(t *testing.T) {
+	const agents = 16
+	h := newHarness(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, agents)
+	for i := 0; i < agents; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sess, err := h.spawn(role(i), fmt.Sprintf("user-%d", i))
+			if err != nil {
+				errs <- fmt.Errorf("spawn %s: %w", role(i), err)
+				return
+			}
+			content := fmt.Sprintf("hello from %s", role(i))
+			msg, err := h.manager.SendMessage(context.Background(), sess.GetID(), content)
+			if err != nil {
+				errs <- fmt.Errorf("send %s: %w", role(i), err)
+				return
+			}
+			if msg.Content != "Echo: "+content {
+				errs <- fmt.Errorf("%s replied %q", role(i), msg.Content)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := h.manager.Count(); got != agents {
+		t.Fatalf("expected %d sessions, got %d", agents, got)
+	}
+
+	for i := 0; i < agents; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := h.manager.PurgeOwner(context.Background(), fmt.Sprintf("user-%d", i)); err != nil {
+				t.Errorf("terminate %s: %v", role(i), err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := h.manager.Count(); got != 0 {
+		t.Errorf("expected every session cleaned up, %d remain", got)
+	}
+	h.assertNoLeaks()
+}
+
+func TestSpawn_RoleIsFreedAfterTermination(t *testing.T) {
+	h := newHarness(t)
+
+	for round := 0; round < 3; round++ {
+		sess, err := h.spawn("builder", "user-1")
+		if err != nil {
+			t.Fatalf("round %d: spawn failed: %v", round, err)
+		}
+		if _, err := h.spawn("builder", "user-2"); err == nil {
+			t.Fatalf("round %d: expected a second builder to be rejected", round)
+		}
+		if _, err := h.manager.PurgeOwner(context.Background(), "user-1"); err != nil {
+			t.Fatalf("round %d: terminate failed: %v", round, err)
+		}
+		if h.manager.Get(sess.GetID()) != nil {
+			t.Fatalf("round %d: session %s still stored", round, sess.GetID())
+		}
+	}
+}
+
+func TestSendMessage_TimeoutInterruptsAgent(t *testing.T) {
+	h := newHarness(t, "ECHO_AGENT_LATENCY=1s")
+	sess, err := h.spawn("slow", "user-1")
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := h.manager.SendMessage(ctx, sess.GetID(), "first"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("timed out request took %v; agent/cancel was not honored", elapsed)
+	}
+
+	// The cancelled reply was drained, so the next request gets its own
+	msg, err := h.manager.SendMessage(context.Background(), sess.GetID(), "second")
+	if err != nil {
+		t.Fatalf("SendMessage after timeout failed: %v", err)
+	}
+	if msg.Content != "Echo: second" {
+		t.Errorf("expected reply to the second prompt, got %q", msg.Content)
+	}
+}
+
+func TestReaper_ExpiredSessionStopsAgent(t *testing.T) {
+	h := newHarness(t)
+	sess, err := h.spawn("short-lived", "user-1", session.WithTTL(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("spawn failed: %v", err)
+	}
+	pid := agentPID(sess)
+	if pid == 0 || (runtime.GOOS != "windows" && !alive(pid)) {
+		t.Fatalf("expected a running agent, got PID %d", pid)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n := session.NewReaper(h.manager, 0, nil).Sweep(context.Background()); n != 1 {
+		t.Fatalf("expected 1 expired session, got %d", n)
+	}
+	if h.manager.Get(sess.GetID()) != nil {
+		t.Error("expected the expired session to be removed")
+	}
+	h.assertNoLeaks()
+}
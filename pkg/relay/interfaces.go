@@ -1,6 +1,12 @@
 package relay
 
-import "github.com/gorilla/websocket"
+import (
+	"net/http"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/clock"
+	"github.com/gorilla/websocket"
+)
 
 // Logger abstracts logging operations
 type Logger interface {
@@ -8,26 +14,45 @@ type Logger interface {
 }
 
 // Clock abstracts time operations
-type Clock interface {
-	Now() string // Returns RFC3339 formatted timestamp
-}
+// Shared with the session package; timestamps are formatted only when
+// protocol messages are built
+type Clock = clock.Clock
 
 // IDGenerator abstracts unique ID generation
 type IDGenerator interface {
 	Generate() string
 }
 
+// Metrics abstracts counter instrumentation
+type Metrics interface {
+	IncCounter(name string)
+}
+
 // WebSocketConn abstracts websocket connection operations
+// The deadline, read limit, control frame and pong handler methods are
+// gorilla's; see sendClose for closing with a status code.
 type WebSocketConn interface {
 	WriteJSON(v interface{}) error
 	ReadMessage() (messageType int, p []byte, err error)
 	Close() error
+	SetReadLimit(limit int64)
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	SetPongHandler(h func(appData string) error)
 }
 
 // Upgrader abstracts WebSocket upgrade operations
 type Upgrader interface {
-	Upgrade(w interface{}, r interface{}, responseHeader interface{}) (WebSocketConn, error)
+	Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (WebSocketConn, error)
 }
 
 // Ensure gorilla websocket implements our interface
 var _ WebSocketConn = (*websocket.Conn)(nil)
+
+// sendClose sends a close frame with code and reason, waiting at most until
+// deadline
+// The caller still closes the connection.
+func sendClose(conn WebSocketConn, code int, reason string, deadline time.Time) error {
+	return conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+}
@@ -0,0 +1,78 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// MetricIPRejected counts connections refused by the IP filter
+const MetricIPRejected = "relay_ip_rejected_total"
+
+// IPFilter decides from the client address whether a connection may upgrade
+// Deny entries win over allow entries; with an empty allow list every address
+// not denied is allowed.
+type IPFilter struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewIPFilter parses allow and deny lists of CIDRs or bare IPs
+func NewIPFilter(cfg IPFilterConfig) (*IPFilter, error) {
+	var f IPFilter
+	var err error
+	if f.allow, err = parsePrefixes(cfg.Allow); err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	if f.deny, err = parsePrefixes(cfg.Deny); err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	return &f, nil
+}
+
+// parsePrefixes parses CIDRs, treating a bare IP as a single-address prefix (pure function)
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ClientIP returns the address a request came from
+// Behind load balancers wrap the handler in TrustedProxies.Middleware so
+// this is the real client rather than the balancer.
+func (f *IPFilter) ClientIP(r *http.Request) netip.Addr {
+	return remoteIP(r)
+}
+
+// Allowed reports whether addr may connect
+func (f *IPFilter) Allowed(addr netip.Addr) bool {
+	if !addr.IsValid() || matchesAny(f.deny, addr) {
+		return false
+	}
+	return len(f.allow) == 0 || matchesAny(f.allow, addr)
+}
+
+// matchesAny reports whether addr is in any of the prefixes (pure function)
+func matchesAny(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestIPFilter_Allowed(t *testing.T) {
+	filter, err := NewIPFilter(IPFilterConfig{
+		Allow: []string{"10.0.0.0/8", "2001:db8::/32"},
+		Deny:  []string{"10.0.13.0/24"},
+	})
+	if err != nil {
+		t.Fatalf("NewIPFilter failed: %v", err)
+	}
+	for addr, want := range map[string]bool{
+		"10.1.2.3":     true,
+		"10.0.13.5":    false, // Deny wins
+		"2001:db8::1":  true,
+		"203.0.113.7":  false, // Not in the allow list
+		"192.168.0.10": false,
+	} {
+		if got := filter.Allowed(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Allowed(%s) = %t, want %t", addr, got, want)
+		}
+	}
+	if filter.Allowed(netip.Addr{}) {
+		t.Error("invalid address must be rejected")
+	}
+
+	open, _ := NewIPFilter(IPFilterConfig{Deny: []string{"203.0.113.7"}})
+	if !open.Allowed(netip.MustParseAddr("198.51.100.1")) || open.Allowed(netip.MustParseAddr("203.0.113.7")) {
+		t.Error("empty allow list should admit everything not denied")
+	}
+
+	if _, err := NewIPFilter(IPFilterConfig{Allow: []string{"10.0.0.0/40"}}); err == nil || !strings.HasPrefix(err.Error(), "allow:") {
+		t.Errorf("expected allow parse error, got %v", err)
+	}
+}
+
+func TestServer_IPFilterRejectsBeforeUpgrade(t *testing.T) {
+	filter, _ := NewIPFilter(IPFilterConfig{Deny: []string{"127.0.0.1", "::1"}})
+	server := NewServer(&UUIDGenerator{}, &StdLogger{}, &SystemClock{},
+		NewGorillaUpgrader(func(r *http.Request) bool { return true }), WithIPFilter(filter))
+	httpServer := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	defer httpServer.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/ws"
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err == nil {
+		t.Fatal("expected the dial to be refused")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403, got %v", resp)
+	}
+	if server.ActiveConnections() != 0 {
+		t.Error("refused connection must not be counted")
+	}
+}
@@ -0,0 +1,235 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/integrations/github"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// ErrInvalidJob is returned for job requests missing a role, workspace or prompt
+var ErrInvalidJob = errors.New("invalid job")
+
+// JobRequest describes a one-shot agent task: spawn an agent, send it each
+// prompt in order, collect the results, tear everything down
+// Workspace is where the agent works. Like agent:spawn, a missing workspace
+// is seeded from SeedFrom; one seeded for the job is removed afterwards.
+type JobRequest struct {
+	Agent       *session.AgentConfig `json:"agent,omitempty"`
+	Role        string               `json:"role"`
+	Workspace   string               `json:"workspace"`
+	SeedFrom    string               `json:"seedFrom,omitempty"`
+	ArchivePath string               `json:"archivePath,omitempty"` // Export the session here before teardown; must not exist
+	Prompts     []string             `json:"prompts"`               // Sent in order; a failed prompt ends the job
+	Timeout     Duration             `json:"timeout,omitempty"`     // Whole job, including spawn (zero = none)
+	Diff        bool                 `json:"diff,omitempty"`        // Report git diff HEAD of the workspace
+}
+
+// Validate checks the request names a role, a workspace and a prompt
+func (r JobRequest) Validate() error {
+	switch {
+	case r.Role == "":
+		return fmt.Errorf("%w: role is required", ErrInvalidJob)
+	case r.Workspace == "":
+		return fmt.Errorf("%w: workspace is required", ErrInvalidJob)
+	case len(r.Prompts) == 0:
+		return fmt.Errorf("%w: at least one prompt is required", ErrInvalidJob)
+	case r.Timeout < 0:
+		return fmt.Errorf("%w: timeout must not be negative", ErrInvalidJob)
+	}
+	if r.Agent != nil {
+		return r.Agent.Validate()
+	}
+	return nil
+}
+
+// JobTurn is one prompt of a job and the agent's reply
+type JobTurn struct {
+	Prompt     string `json:"prompt"`
+	Reply      string `json:"reply,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// JobResult reports what a job did
+// OK is true if the agent spawned and answered every prompt; the diff and
+// archive are best-effort and report their own errors.
+type JobResult struct {
+	SessionID  string    `json:"sessionId,omitempty"`
+	Role       string    `json:"role"`
+	Turns      []JobTurn `json:"turns"`
+	Diff       string    `json:"diff,omitempty"`
+	DiffError  string    `json:"diffError,omitempty"`
+	Archive    string    `json:"archive,omitempty"` // Path the session was exported to
+	ArchiveErr string    `json:"archiveError,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	SpawnMs    int64     `json:"spawnMs"`    // Time to start the agent
+	DurationMs int64     `json:"durationMs"` // Whole job, including teardown
+	OK         bool      `json:"ok"`
+}
+
+// JobRunner runs one-shot agent tasks without an interactive client, e.g.
+// from CI through POST /admin/jobs
+type JobRunner struct {
+	spawner *Spawner
+	manager *session.Manager
+	git     github.GitRunner
+	clock   Clock
+	logger  Logger
+}
+
+// JobOption configures a JobRunner
+type JobOption func(*JobRunner)
+
+// WithJobGit replaces the git used for job diffs (github.ExecGit by default)
+func WithJobGit(git github.GitRunner) JobOption {
+	return func(r *JobRunner) {
+		r.git = git
+	}
+}
+
+// NewJobRunner creates a runner spawning job agents through spawner
+func NewJobRunner(spawner *Spawner, manager *session.Manager, clock Clock, logger Logger, opts ...JobOption) *JobRunner {
+	r := &JobRunner{
+		spawner: spawner,
+		manager: manager,
+		git:     github.ExecGit{},
+		clock:   clock,
+		logger:  logger,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run spawns the job's agent, sends it the prompts and tears the session
+// down, whatever happens in between
+// Errors are only returned for invalid requests; failures once the job has
+// started are reported in the result. The session is owned by the user ctx
+// is attributed to (session.WithUser), if any.
+func (r *JobRunner) Run(ctx context.Context, req JobRequest) (JobResult, error) {
+	if err := req.Validate(); err != nil {
+		return JobResult{Role: req.Role}, err
+	}
+	start := r.clock.Monotonic()
+	result := r.run(ctx, req)
+	result.DurationMs = (r.clock.Monotonic() - start).Milliseconds()
+	return result, nil
+}
+
+// run does the work of Run on a valid request
+func (r *JobRunner) run(ctx context.Context, req JobRequest) JobResult {
+	result := JobResult{Role: req.Role, Turns: []JobTurn{}}
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.Timeout))
+		defer cancel()
+	}
+
+	var opts []session.CreateOption
+	var kind string
+	if req.Agent != nil {
+		opts = append(opts, session.WithAgentConfig(*req.Agent))
+		kind = req.Agent.Kind
+	}
+	opts = append(opts, session.WithLabels(map[string]string{"job": "true"}))
+	seeded := req.SeedFrom != "" && !pathExists(req.Workspace)
+	start := r.clock.Monotonic()
+	sess, err := r.spawner.SpawnAgent(ctx, jobConn{}, SpawnRequest{
+		Role:      req.Role,
+		Workspace: req.Workspace,
+		SeedFrom:  req.SeedFrom,
+		OwnerID:   session.UserFromContext(ctx),
+		Kind:      kind,
+		Options:   opts,
+	})
+	result.SpawnMs = (r.clock.Monotonic() - start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.SessionID = sess.GetID()
+	r.logger.Printf("Job started: session=%s role=%s prompts=%d", result.SessionID, req.Role, len(req.Prompts))
+
+	result.OK = true
+	for _, prompt := range req.Prompts {
+		turn := JobTurn{Prompt: prompt}
+		start := r.clock.Monotonic()
+		msg, err := r.manager.SendMessage(ctx, result.SessionID, prompt)
+		turn.DurationMs = (r.clock.Monotonic() - start).Milliseconds()
+		if err != nil {
+			turn.Error = err.Error()
+			result.Error = err.Error()
+			result.OK = false
+		} else {
+			turn.Reply = outputText(msg)
+		}
+		result.Turns = append(result.Turns, turn)
+		if err != nil {
+			break
+		}
+	}
+
+	// Collect and tear down even if the job ran out of time
+	cleanupCtx := context.WithoutCancel(ctx)
+	if req.Diff {
+		if result.Diff, err = r.git.Git(cleanupCtx, req.Workspace, "diff", "HEAD"); err != nil {
+			result.DiffError = err.Error()
+		}
+	}
+	if req.ArchivePath != "" {
+		if err := r.export(cleanupCtx, result.SessionID, req.ArchivePath); err != nil {
+			result.ArchiveErr = err.Error()
+		} else {
+			result.Archive = req.ArchivePath
+		}
+	}
+	r.finish(cleanupCtx, sess, seeded, req.Workspace)
+	r.logger.Printf("Job finished: session=%s role=%s ok=%t", result.SessionID, req.Role, result.OK)
+	return result
+}
+
+// export writes the session archive to a new file at path
+func (r *JobRunner) export(ctx context.Context, sessionID, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600) // #nosec G304 -- path is chosen by the admin API caller
+	if err != nil {
+		return err
+	}
+	if err := r.manager.Export(ctx, sessionID, f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// finish terminates the job's session and closes its agent, removing the
+// workspace if it was seeded for the job
+func (r *JobRunner) finish(ctx context.Context, sess *session.Session, seeded bool, workspace string) {
+	if err := r.manager.MarkTerminating(ctx, sess.GetID(), "job finished"); err != nil {
+		r.logger.Printf("Failed to terminate job session: session=%s err=%v", sess.GetID(), err)
+	}
+	r.spawner.closeAgent(sess)
+	if err := r.manager.CompleteCleanup(ctx, sess.GetID()); err != nil {
+		r.logger.Printf("Failed to clean up job session: session=%s err=%v", sess.GetID(), err)
+	}
+	if seeded {
+		if err := os.RemoveAll(workspace); err != nil {
+			r.logger.Printf("Failed to remove workspace: path=%s err=%v", workspace, err)
+		}
+	}
+}
+
+// jobConn stands in for the client of a job's session, which has none
+// Messages for it are dropped; the job collects replies itself.
+type jobConn struct{}
+
+func (jobConn) WriteJSON(interface{}) error       { return nil }
+func (jobConn) ReadMessage() (int, []byte, error) { return 0, nil, io.EOF }
+func (jobConn) Close() error                      { return nil }
@@ -0,0 +1,133 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// diffGit answers git diff with a fixed patch
+type diffGit struct {
+	args []string
+}
+
+func (g *diffGit) Git(_ context.Context, _ string, args ...string) (string, error) {
+	g.args = args
+	return "diff --git a/main.go b/main.go", nil
+}
+
+func TestJobRunner_Run(t *testing.T) {
+	factory := &mockAgentFactory{}
+	spawner, manager := newTestSpawner(t, factory)
+	git := &diffGit{}
+	runner := NewJobRunner(spawner, manager, &mockClock{now: testTime}, &mockLogger{}, WithJobGit(git))
+	archive := filepath.Join(t.TempDir(), "job.tar.gz")
+
+	result, err := runner.Run(context.Background(), JobRequest{
+		Role:        "auth",
+		Workspace:   t.TempDir(),
+		Prompts:     []string{"write the handler", "add tests"},
+		Diff:        true,
+		ArchivePath: archive,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !result.OK || result.SessionID != "session-1" || len(result.Turns) != 2 || result.Turns[1].Reply != "Echo: add tests" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Diff != "diff --git a/main.go b/main.go" || strings.Join(git.args, " ") != "diff HEAD" {
+		t.Errorf("expected the workspace diff, got %q from git %v", result.Diff, git.args)
+	}
+	if info, err := os.Stat(archive); err != nil || info.Size() == 0 || result.Archive != archive {
+		t.Errorf("expected the session archive at %s, got %q, %v", archive, result.Archive, err)
+	}
+
+	// Everything is torn down: the agent closed and the role free again
+	if !factory.clients["auth"].closed || manager.Get(result.SessionID) != nil || manager.GetByRole("auth") != nil {
+		t.Error("expected the job's session and agent to be gone")
+	}
+}
+
+func TestJobRunner_Run_PromptFails(t *testing.T) {
+	factory := &mockAgentFactory{}
+	spawner, manager := newTestSpawner(t, factory)
+	runner := NewJobRunner(spawner, manager, &mockClock{now: testTime}, &mockLogger{})
+	ctx := context.Background()
+
+	// The first prompt's agent fails; the second is never sent
+	failing := &failingFactory{mockAgentFactory: factory}
+	spawner.factory = failing
+	result, err := runner.Run(ctx, JobRequest{Role: "auth", Workspace: t.TempDir(), Prompts: []string{"one", "two"}})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.OK || len(result.Turns) != 1 || result.Turns[0].Error == "" || result.Error == "" {
+		t.Errorf("expected the job to stop at the failed prompt, got %+v", result)
+	}
+	if manager.GetByRole("auth") != nil {
+		t.Error("expected a failed job to be torn down too")
+	}
+
+	// Spawn failures are reported without a session
+	factory.failRoles = map[string]bool{"db": true}
+	spawner.factory = factory
+	result, _ = runner.Run(ctx, JobRequest{Role: "db", Workspace: t.TempDir(), Prompts: []string{"one"}})
+	if result.OK || result.SessionID != "" || !strings.Contains(result.Error, "executable not found") {
+		t.Errorf("expected the spawn failure, got %+v", result)
+	}
+
+	// Invalid requests are errors
+	if _, err := runner.Run(ctx, JobRequest{Role: "auth", Workspace: t.TempDir()}); !errors.Is(err, ErrInvalidJob) {
+		t.Errorf("expected ErrInvalidJob without prompts, got %v", err)
+	}
+}
+
+// failingFactory starts agents whose prompts fail
+type failingFactory struct {
+	*mockAgentFactory
+}
+
+func (f *failingFactory) NewAgent(ctx context.Context, role, workspace string) (session.ACPClient, error) {
+	client, err := f.mockAgentFactory.NewAgent(ctx, role, workspace)
+	if err == nil {
+		client.(*mockClosableACPClient).err = errors.New("agent crashed")
+	}
+	return client, err
+}
+
+func TestAdminHandler_RunJob(t *testing.T) {
+	spawner, manager := newTestSpawner(t, &mockAgentFactory{})
+	handler := NewAdminHandler(manager, &mockLogger{},
+		WithAdminJobs(NewJobRunner(spawner, manager, &mockClock{now: testTime}, &mockLogger{})))
+
+	body, _ := json.Marshal(JobRequest{Role: "auth", Workspace: t.TempDir(), Prompts: []string{"hello"}, Timeout: Duration(time.Minute)})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/jobs", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result JobResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !result.OK || result.Turns[0].Reply != "Echo: hello" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/jobs", strings.NewReader(`{"role": "auth"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid job, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,301 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// Journal entry kinds
+const (
+	JournalFrame     = "frame"     // A protocol message to or from a client
+	JournalLifecycle = "lifecycle" // A session state change
+	JournalAgent     = "agent"     // A prompt and the agent's reply
+)
+
+// Frame directions in a journal
+const (
+	FrameIn  = "in"
+	FrameOut = "out"
+)
+
+// JournalEntry is one line of a session journal
+// Seq orders entries across every session the relay journals.
+type JournalEntry struct {
+	Seq       int64  `json:"seq"`
+	Time      string `json:"time"`
+	Kind      string `json:"kind"`
+	SessionID string `json:"sessionId"`
+
+	Direction string          `json:"direction,omitempty"` // frame
+	Frame     json.RawMessage `json:"frame,omitempty"`     // frame, as sent
+
+	AgentID string `json:"agentId,omitempty"` // lifecycle, agent
+	Name    string `json:"name,omitempty"`    // lifecycle
+	From    string `json:"from,omitempty"`    // lifecycle
+	To      string `json:"to,omitempty"`      // lifecycle
+	Event   string `json:"event,omitempty"`   // lifecycle
+	Reason  string `json:"reason,omitempty"`  // lifecycle
+
+	Prompt string            `json:"prompt,omitempty"` // agent
+	Reply  *acp.AgentMessage `json:"reply,omitempty"`  // agent
+	Error  string            `json:"error,omitempty"`  // lifecycle (failure cause), agent
+}
+
+// Journal records every session's protocol frames, lifecycle events and agent
+// exchanges, in order, to <dir>/<session ID>.jsonl so `relay replay` can
+// reproduce what happened
+// Journals hold full transcripts, unredacted so replays are exact: files are
+// created readable by the relay's user only. A session's file is closed when
+// it is cleaned up. Frames are journaled only under a session their
+// connection is attached to or observing.
+type Journal struct {
+	dir    string
+	clock  Clock
+	logger Logger
+
+	mu    sync.Mutex
+	seq   int64
+	files map[string]*os.File // Open journals by session ID
+}
+
+// NewJournal creates a journal writing to dir, which is created if needed
+func NewJournal(dir string, clock Clock, logger Logger) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("journal directory: %w", err)
+	}
+	return &Journal{dir: dir, clock: clock, logger: logger, files: make(map[string]*os.File)}, nil
+}
+
+// HandleLifecycle journals a session state change (subscribe it to the
+// Manager's EventBus)
+func (j *Journal) HandleLifecycle(event session.LifecycleEvent) {
+	e := JournalEntry{
+		Kind:      JournalLifecycle,
+		SessionID: event.SessionID,
+		AgentID:   event.AgentID,
+		Name:      event.Name,
+		From:      string(event.From),
+		To:        string(event.To),
+		Event:     event.Event.String(),
+		Reason:    event.Reason,
+	}
+	if event.Err != nil {
+		e.Error = event.Err.Error()
+	}
+	j.append(e)
+	if event.To == session.StateCleaned {
+		j.closeSession(event.SessionID)
+	}
+}
+
+// Middleware journals each prompt with the agent's reply or error
+func (j *Journal) Middleware() session.Middleware {
+	return func(next session.SendFunc) session.SendFunc {
+		return func(ctx context.Context, req session.AgentRequest) (*acp.AgentMessage, error) {
+			msg, err := next(ctx, req)
+			e := JournalEntry{Kind: JournalAgent, SessionID: req.SessionID, AgentID: req.AgentID, Prompt: req.Content, Reply: msg}
+			if err != nil {
+				e.Error = err.Error()
+			}
+			j.append(e)
+			return msg, err
+		}
+	}
+}
+
+// RecordFrame journals a protocol message under the session named by its
+// sessionId field
+// The caller checks the session exists and that the frame's connection may
+// use it: the ID is whatever the client sent, and each new one opens a file.
+func (j *Journal) RecordFrame(direction string, frame []byte) {
+	if sessionID := frameSessionID(frame); sessionID != "" {
+		j.append(JournalEntry{Kind: JournalFrame, SessionID: sessionID, Direction: direction, Frame: frame})
+	}
+}
+
+// frameSessionID returns the sessionId field of a frame, if any (pure function)
+func frameSessionID(frame []byte) string {
+	var target struct {
+		SessionID string `json:"sessionId"`
+	}
+	if json.Unmarshal(frame, &target) != nil {
+		return ""
+	}
+	return target.SessionID
+}
+
+// Conn wraps conn so the messages written to it are journaled, for the
+// sessions uses reports the connection is attached to or observing
+func (j *Journal) Conn(conn WebSocketConn, uses func(sessionID string) bool) WebSocketConn {
+	return &journalConn{WebSocketConn: conn, journal: j, uses: uses}
+}
+
+// Close closes every open journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var firstErr error
+	for id, f := range j.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(j.files, id)
+	}
+	return firstErr
+}
+
+// append numbers, timestamps and writes an entry to its session's file
+// Failures are logged: losing the journal must not fail the session.
+func (j *Journal) append(e JournalEntry) {
+	if e.SessionID == "" || filepath.Base(e.SessionID) != e.SessionID {
+		return // Not a file name
+	}
+	e.Time = FormatTimestamp(j.clock.Now())
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq++
+	e.Seq = j.seq
+	line, err := json.Marshal(e)
+	if err != nil {
+		j.logger.Printf("Failed to encode journal entry: session=%s err=%v", e.SessionID, err)
+		return
+	}
+	f, ok := j.files[e.SessionID]
+	if !ok {
+		f, err = os.OpenFile(j.path(e.SessionID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) // #nosec G304 -- session IDs are checked to be plain names
+		if err != nil {
+			j.logger.Printf("Failed to open journal: session=%s err=%v", e.SessionID, err)
+			return
+		}
+		j.files[e.SessionID] = f
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		j.logger.Printf("Failed to write journal: session=%s err=%v", e.SessionID, err)
+	}
+}
+
+// path is where a session's journal is written
+func (j *Journal) path(sessionID string) string {
+	return filepath.Join(j.dir, sessionID+".jsonl")
+}
+
+// SessionEvents reads back a session's lifecycle events and agent exchanges,
+// implementing session.EventJournal for Manager.GetTimeline
+func (j *Journal) SessionEvents(sessionID string) ([]session.JournaledEvent, error) {
+	if sessionID == "" || filepath.Base(sessionID) != sessionID {
+		return nil, nil // Never journaled
+	}
+	// Read without the lock, which would stall every session's journaling
+	// for the length of the read; an entry still being appended is left out
+	data, err := os.ReadFile(j.path(sessionID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries, err := decodeJournal(bytes.NewReader(data[:bytes.LastIndexByte(data, '\n')+1]))
+	if err != nil {
+		return nil, err
+	}
+	return journaledEvents(entries), nil
+}
+
+// journaledEvents converts journal entries to the events a timeline is built
+// from; frames are left out (pure function)
+func journaledEvents(entries []JournalEntry) []session.JournaledEvent {
+	events := make([]session.JournaledEvent, 0, len(entries))
+	for _, e := range entries {
+		at, _ := time.Parse(time.RFC3339, e.Time)
+		switch e.Kind {
+		case JournalLifecycle:
+			event := &session.LifecycleEvent{
+				Time:      at,
+				SessionID: e.SessionID,
+				Name:      e.Name,
+				AgentID:   e.AgentID,
+				From:      session.SessionState(e.From),
+				To:        session.SessionState(e.To),
+				Event:     session.Event(e.Event),
+				Reason:    e.Reason,
+			}
+			if e.Error != "" {
+				event.Err = errors.New(e.Error)
+			}
+			events = append(events, session.JournaledEvent{Time: at, Lifecycle: event})
+		case JournalAgent:
+			events = append(events, session.JournaledEvent{Time: at, Failed: e.Error != ""})
+		}
+	}
+	return events
+}
+
+// closeSession closes a session's journal file
+func (j *Journal) closeSession(sessionID string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if f, ok := j.files[sessionID]; ok {
+		if err := f.Close(); err != nil {
+			j.logger.Printf("Failed to close journal: session=%s err=%v", sessionID, err)
+		}
+		delete(j.files, sessionID)
+	}
+}
+
+// journalConn journals the messages written to a connection
+type journalConn struct {
+	WebSocketConn
+	journal *Journal
+	uses    func(sessionID string) bool
+}
+
+func (c *journalConn) WriteJSON(v interface{}) error {
+	if frame, err := json.Marshal(v); err == nil {
+		if sessionID := frameSessionID(frame); sessionID != "" && c.uses(sessionID) {
+			c.journal.RecordFrame(FrameOut, frame)
+		}
+	}
+	return c.WebSocketConn.WriteJSON(v)
+}
+
+// ReadJournal reads a session journal written by Journal
+func ReadJournal(path string) ([]JournalEntry, error) {
+	f, err := os.Open(path) // #nosec G304 -- path is the operator's journal file
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return decodeJournal(f)
+}
+
+// decodeJournal parses journal entries, one per line
+func decodeJournal(r io.Reader) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var e JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,250 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+	"github.com/2389-research/ourocodus/pkg/relay/session/sessiontest"
+)
+
+func TestJournal_RecordsSessionInOrder(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "journal")
+	journal, err := NewJournal(dir, &mockClock{now: testTime}, &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewJournal failed: %v", err)
+	}
+	defer func() { _ = journal.Close() }()
+
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"},
+		session.WithMiddleware(journal.Middleware()))
+	manager.Events().Subscribe(journal.HandleLifecycle)
+	conn := journal.Conn(&mockWebSocketConn{}, func(string) bool { return true })
+
+	if _, err := manager.Create(ctx, "auth", conn); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	_ = manager.BeginSpawn(ctx, "session-1")
+	_ = manager.AttachAgent(ctx, "session-1", "/work/auth", &mockStreamingACPClient{chunks: []string{"hi"}})
+	journal.RecordFrame(FrameIn, []byte(`{"type":"agent:message","sessionId":"session-1","content":"hi"}`))
+	journal.RecordFrame(FrameIn, []byte(`{"type":"connection:whoami"}`)) // No session: skipped
+	if _, err := manager.SendMessage(ctx, "session-1", "hi"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	_ = manager.MarkTerminating(ctx, "session-1", "done")
+	_ = manager.CompleteCleanup(ctx, "session-1")
+
+	path := filepath.Join(dir, "session-1.jsonl")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("journal not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected journal readable by its owner only, got %v", perm)
+	}
+	entries, err := ReadJournal(path)
+	if err != nil {
+		t.Fatalf("ReadJournal failed: %v", err)
+	}
+
+	var kinds []string
+	for i, e := range entries {
+		if e.Seq != int64(i+1) || e.SessionID != "session-1" || e.Time != FormatTimestamp(testTime) {
+			t.Errorf("entry %d: unexpected header %+v", i, e)
+		}
+		kind := e.Kind
+		switch e.Kind {
+		case JournalLifecycle:
+			kind += ":" + e.To
+		case JournalFrame:
+			kind += ":" + e.Direction
+		}
+		kinds = append(kinds, kind)
+	}
+	// Each transition's agent:state frame (and agent:ready on activation) is
+	// sent by a handler subscribed before the journal
+	want := []string{
+		"lifecycle:CREATED",
+		"frame:out", "lifecycle:SPAWNING",
+		"frame:out", "frame:out", "lifecycle:ACTIVE",
+		"frame:in", "agent",
+		"frame:out", "lifecycle:TERMINATING",
+		"frame:out", "lifecycle:CLEANED",
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("expected entries %v, got %v", want, kinds)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("expected entries %v, got %v", want, kinds)
+		}
+	}
+	if agent := entries[7]; agent.Prompt != "hi" || agent.Reply == nil || agent.AgentID != "auth" {
+		t.Errorf("unexpected agent entry: %+v", agent)
+	}
+
+	journal.mu.Lock()
+	open := len(journal.files)
+	journal.mu.Unlock()
+	if open != 0 {
+		t.Errorf("expected the journal closed once the session was cleaned, %d open", open)
+	}
+}
+
+func TestJournal_IgnoresUnsafeSessionIDs(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := NewJournal(dir, &mockClock{now: testTime}, &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewJournal failed: %v", err)
+	}
+	defer func() { _ = journal.Close() }()
+
+	journal.RecordFrame(FrameIn, []byte(`{"sessionId":"../escape"}`))
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("expected no journal outside the directory, got %v", err)
+	}
+}
+
+func TestJournal_SessionTimeline(t *testing.T) {
+	ctx := context.Background()
+	journal, err := NewJournal(t.TempDir(), &mockClock{now: testTime}, &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewJournal failed: %v", err)
+	}
+	defer func() { _ = journal.Close() }()
+
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"},
+		session.WithMiddleware(journal.Middleware()), session.WithEventJournal(journal))
+	manager.Events().Subscribe(journal.HandleLifecycle)
+	if _, err := manager.Create(ctx, "auth", &mockWebSocketConn{}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	_ = manager.BeginSpawn(ctx, "session-1")
+	_ = manager.AttachAgent(ctx, "session-1", "/work/auth", &mockStreamingACPClient{chunks: []string{"hi"}})
+	for i := 0; i < 2; i++ {
+		if _, err := manager.SendMessage(ctx, "session-1", "hi"); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+	_ = manager.MarkTerminating(ctx, "session-1", "done")
+	_ = manager.CompleteCleanup(ctx, "session-1")
+
+	// Cleaned up and deleted, but still journaled
+	handler := NewAdminHandler(manager, &mockLogger{})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/sessions/session-1/timeline", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp TimelineResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var kinds []string
+	for _, e := range resp.Events {
+		kinds = append(kinds, e.Kind)
+	}
+	want := []string{"created", "spawning", "agent_spawned", "messages", "terminating", "terminated"}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected timeline %v, got %v", want, kinds)
+	}
+	if messages := resp.Events[3]; messages.Messages != 2 || messages.Time != FormatTimestamp(testTime.Truncate(time.Minute)) {
+		t.Errorf("unexpected messages point: %+v", messages)
+	}
+	if resp.Events[4].Reason != "done" {
+		t.Errorf("expected the termination reason, got %+v", resp.Events[4])
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/sessions/nope/timeline", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a session never journaled, got %d", rec.Code)
+	}
+}
+
+func TestJournal_SessionEventsSkipsPartialEntryWithoutLocking(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := NewJournal(dir, &mockClock{now: testTime}, &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewJournal failed: %v", err)
+	}
+	defer func() { _ = journal.Close() }()
+
+	journal.HandleLifecycle(session.LifecycleEvent{SessionID: "session-1", From: session.StateCreated, To: session.StateSpawning})
+	f, err := os.OpenFile(filepath.Join(dir, "session-1.jsonl"), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, _ = f.WriteString(`{"seq":2,"kind":"lifec`) // An entry still being written
+	_ = f.Close()
+
+	journal.mu.Lock() // Another session's write in progress
+	defer journal.mu.Unlock()
+	done := make(chan []session.JournaledEvent)
+	go func() {
+		events, err := journal.SessionEvents("session-1")
+		if err != nil {
+			t.Errorf("SessionEvents failed: %v", err)
+		}
+		done <- events
+	}()
+	select {
+	case events := <-done:
+		if len(events) != 1 || events[0].Lifecycle == nil || events[0].Lifecycle.To != session.StateSpawning {
+			t.Errorf("expected only the complete entry, got %+v", events)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("SessionEvents waited for the journal lock")
+	}
+}
+
+func TestServer_JournalsOnlyFramesForAttachedSessions(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := NewJournal(dir, &mockClock{now: testTime}, &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewJournal failed: %v", err)
+	}
+	defer func() { _ = journal.Close() }()
+	streamer, owner := setupStreamer(t, &mockStreamingACPClient{})
+
+	intruder := sessiontest.NewConn(
+		[]byte(`{"version":"1.0","type":"echo","sessionId":"session-1"}`),
+		[]byte(`{"version":"1.0","type":"echo","sessionId":"made-up"}`),
+	)
+	server := NewServer(&mockIDGenerator{id: "relay"}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{conn: intruder},
+		WithAgentStreamer(streamer), WithJournal(journal))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.HandleWebSocket(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws", nil))
+	}()
+	if _, err := intruder.WaitWritten(3, 2*time.Second); err != nil { // Handshake and both echoes
+		t.Fatalf("echoes not sent: %v", err)
+	}
+	_ = intruder.Close()
+	<-done
+
+	if _, err := os.Stat(filepath.Join(dir, "made-up.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("expected no journal for a made-up session, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "session-1.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing journaled for another connection's session, got %v", err)
+	}
+
+	server.handleMessage(owner, []byte(`{"version":"1.0","type":"echo","sessionId":"session-1"}`))
+	entries, err := ReadJournal(filepath.Join(dir, "session-1.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadJournal failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Kind != JournalFrame || entries[0].Direction != FrameIn {
+		t.Errorf("expected the attached connection's frame journaled, got %+v", entries)
+	}
+}
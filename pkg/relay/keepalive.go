@@ -0,0 +1,76 @@
+package relay
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Default client connection limits
+const (
+	DefaultReadLimit   = 16 << 20         // Largest frame accepted, in bytes
+	DefaultReadTimeout = 60 * time.Second // Longest a client may send nothing, not even a pong
+)
+
+// WithReadLimits closes connections that send a frame larger than limit
+// bytes, or nothing at all for timeout
+// Clients are pinged often enough that an idle but live one always answers
+// in time. A zero limit accepts frames of any size; a zero timeout waits
+// forever and sends no pings.
+func WithReadLimits(limit int64, timeout time.Duration) ServerOption {
+	return func(s *Server) {
+		s.readLimit = limit
+		s.readTimeout = timeout
+	}
+}
+
+// applyReadLimits sets conn's read limit and first read deadline, and has
+// every pong push the deadline back
+// The read loop pushes it back after each frame with extendReadDeadline.
+func (s *Server) applyReadLimits(conn WebSocketConn) {
+	if s.readLimit > 0 {
+		conn.SetReadLimit(s.readLimit)
+	}
+	if s.readTimeout <= 0 {
+		return
+	}
+	s.extendReadDeadline(conn)
+	conn.SetPongHandler(func(string) error {
+		s.extendReadDeadline(conn)
+		return nil
+	})
+}
+
+// extendReadDeadline gives the client another readTimeout to send a frame
+func (s *Server) extendReadDeadline(conn WebSocketConn) {
+	if s.readTimeout <= 0 {
+		return
+	}
+	if err := conn.SetReadDeadline(s.clock.Now().Add(s.readTimeout)); err != nil {
+		s.logger.Printf("Failed to set read deadline: %v", err)
+	}
+}
+
+// keepAlive pings conn every nine tenths of readTimeout until the returned
+// func is called or a ping fails
+func (s *Server) keepAlive(conn WebSocketConn) func() {
+	if s.readTimeout <= 0 {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.readTimeout * 9 / 10)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, s.clock.Now().Add(closeWriteTimeout)); err != nil {
+					return // The read loop sees the broken connection too
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
@@ -0,0 +1,97 @@
+package relay
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestServer_AppliesReadLimits(t *testing.T) {
+	clock := &mockClock{now: testTime}
+	conn := &mockWebSocketConn{readError: io.EOF}
+	server := NewServer(&mockIDGenerator{id: "relay"}, &mockLogger{}, clock, &mockUpgrader{conn: conn},
+		WithReadLimits(1024, time.Minute))
+	server.HandleWebSocket(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+	if conn.readLimit != 1024 {
+		t.Errorf("expected read limit 1024, got %d", conn.readLimit)
+	}
+	if want := testTime.Add(time.Minute); !conn.readDeadline.Equal(want) {
+		t.Errorf("expected read deadline %v, got %v", want, conn.readDeadline)
+	}
+	if conn.pongHandler == nil {
+		t.Fatal("expected a pong handler")
+	}
+	clock.now = testTime.Add(30 * time.Second)
+	if err := conn.pongHandler(""); err != nil {
+		t.Fatalf("pong handler failed: %v", err)
+	}
+	if want := clock.now.Add(time.Minute); !conn.readDeadline.Equal(want) {
+		t.Errorf("expected a pong to push the deadline to %v, got %v", want, conn.readDeadline)
+	}
+}
+
+func TestServer_ReadLimitsOverWebSocket(t *testing.T) {
+	server := NewServer(&UUIDGenerator{}, &mockLogger{}, &SystemClock{},
+		NewGorillaUpgrader(func(r *http.Request) bool { return true }),
+		WithReadLimits(64, 200*time.Millisecond))
+	httpServer := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	defer httpServer.Close()
+	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/ws"
+
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		return conn
+	}
+	// readUntilClosed reads until the relay drops the connection, failing
+	// if that takes longer than within
+	readUntilClosed := func(conn *websocket.Conn, within time.Duration) error {
+		_ = conn.SetReadDeadline(time.Now().Add(within))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				var netErr interface{ Timeout() bool }
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					t.Errorf("connection still open after %v", within)
+				}
+				return err
+			}
+		}
+	}
+
+	t.Run("oversized frame", func(t *testing.T) {
+		conn := dial()
+		defer conn.Close()
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 100))); err != nil {
+			t.Fatalf("WriteMessage failed: %v", err)
+		}
+		if err := readUntilClosed(conn, 2*time.Second); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+			t.Errorf("expected close code %d, got %v", websocket.CloseMessageTooBig, err)
+		}
+	})
+
+	t.Run("silent client", func(t *testing.T) {
+		conn := dial()
+		defer conn.Close()
+		time.Sleep(500 * time.Millisecond) // Not reading, so pings go unanswered
+		readUntilClosed(conn, 2*time.Second)
+	})
+
+	t.Run("live client", func(t *testing.T) {
+		conn := dial()
+		go readUntilClosed(conn, 10*time.Second) // Reading answers the pings
+		time.Sleep(500 * time.Millisecond)
+		if n := server.ActiveConnections(); n != 1 {
+			t.Errorf("expected the pinged client still connected, got %d connections", n)
+		}
+		conn.Close()
+	})
+}
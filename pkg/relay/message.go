@@ -3,6 +3,13 @@ package relay
 import (
 	"encoding/json"
 	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/e2e"
+	"github.com/2389-research/ourocodus/pkg/integrations/github"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
 )
 
 const (
@@ -16,6 +23,11 @@ type BaseMessage struct {
 	Type    string `json:"type"`
 }
 
+// MessageType returns the message type; promoted to every protocol message
+func (b BaseMessage) MessageType() string {
+	return b.Type
+}
+
 // ConnectionEstablishedMessage is sent when a WebSocket connection is established
 type ConnectionEstablishedMessage struct {
 	BaseMessage
@@ -23,6 +35,12 @@ type ConnectionEstablishedMessage struct {
 	Timestamp string `json:"timestamp"`
 }
 
+// FormatTimestamp renders a time as the RFC3339 UTC string used in protocol messages
+// This is the single serialization boundary for timestamps (pure function)
+func FormatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
 // ValidationError represents different types of validation failures
 type ValidationError struct {
 	Code        string
@@ -34,6 +52,24 @@ func (e ValidationError) Error() string {
 	return e.Message
 }
 
+// validateEncoding rejects text that is not well-formed UTF-8 (pure function)
+// encoding/json would silently replace bad bytes with U+FFFD, hiding corrupt
+// input from downstream consumers
+func validateEncoding(data []byte) error {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return ValidationError{
+				Code:        "INVALID_ENCODING",
+				Message:     fmt.Sprintf("Invalid UTF-8 at byte %d", i),
+				Recoverable: true,
+			}
+		}
+		i += size
+	}
+	return nil
+}
+
 // parseMessage parses JSON into BaseMessage (pure function)
 func parseMessage(data []byte) (BaseMessage, error) {
 	var base BaseMessage
@@ -83,6 +119,19 @@ func validateVersion(version string) error {
 // ValidateMessage checks if a message has required fields and valid version
 // Composes pure validation functions
 func ValidateMessage(data []byte) error {
+	return ValidateMessageWith(data, ProtocolConfig{})
+}
+
+// ValidateMessageWith is ValidateMessage with the parsing rules in cfg
+func ValidateMessageWith(data []byte, cfg ProtocolConfig) error {
+	if err := validateEncoding(data); err != nil {
+		return err
+	}
+
+	if err := checkStrictJSON(data, cfg); err != nil {
+		return err
+	}
+
 	base, err := parseMessage(data)
 	if err != nil {
 		return err
@@ -106,12 +155,330 @@ type ErrorDetail struct {
 	Recoverable bool   `json:"recoverable"`
 }
 
+// EchoMessage is sent back to the client with a timestamp added, carrying
+// any other fields it has unchanged
+type EchoMessage struct {
+	BaseMessage
+}
+
 // ErrorMessage is sent when an error occurs
 type ErrorMessage struct {
 	BaseMessage
 	Error ErrorDetail `json:"error"`
 }
 
+// AgentStateMessage is pushed to the owning session's WebSocket whenever its
+// agent changes lifecycle state
+type AgentStateMessage struct {
+	BaseMessage
+	Error     *ErrorDetail `json:"error,omitempty"`
+	SessionID string       `json:"sessionId"`
+	Name      string       `json:"name,omitempty"`
+	Role      string       `json:"role"`
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	Reason    string       `json:"reason,omitempty"`
+	Timestamp string       `json:"timestamp"`
+}
+
+// AgentReadyMessage is pushed to a session's connections when an agent is
+// attached and can take prompts, describing what it supports so UIs can
+// enable features per agent
+// Capabilities is nil if the agent did not report any at initialize.
+type AgentReadyMessage struct {
+	BaseMessage
+	Capabilities    *acp.AgentCapabilities `json:"capabilities,omitempty"`
+	SessionID       string                 `json:"sessionId"`
+	Role            string                 `json:"role"`
+	AgentName       string                 `json:"agentName,omitempty"`
+	AgentVersion    string                 `json:"agentVersion,omitempty"`
+	ProtocolVersion int                    `json:"protocolVersion,omitempty"` // ACP version the agent speaks
+	Timestamp       string                 `json:"timestamp"`
+}
+
+// AgentDeltaMessage carries one partial chunk of an agent reply
+// Seq starts at 1 for each reply and increases by one per chunk, so clients
+// can render typewriter-style output and detect gaps
+type AgentDeltaMessage struct {
+	BaseMessage
+	SessionID     string `json:"sessionId"`
+	CorrelationID string `json:"correlationId"`
+	Content       string `json:"content"`
+	Seq           int    `json:"seq"`
+}
+
+// AgentCompleteMessage ends a streamed reply
+// Seq is the number of agent:delta messages sent for the reply; Parts holds
+// the final structured reply, or Error is set if the request failed
+type AgentCompleteMessage struct {
+	BaseMessage
+	Error         *ErrorDetail `json:"error,omitempty"`
+	SessionID     string       `json:"sessionId"`
+	CorrelationID string       `json:"correlationId"`
+	Parts         []acp.Part   `json:"parts,omitempty"`
+	Seq           int          `json:"seq"`
+	Timestamp     string       `json:"timestamp"`
+}
+
+// AgentSendMessage is sent by clients to prompt a session's agent
+// The reply streams back as agent:delta messages ending in agent:complete,
+// all carrying the client-chosen correlationId. If the agent has not answered
+// by the deadline (the earlier of timeoutMs and deadline, when given) the
+// request is interrupted and completes with DEADLINE_EXCEEDED.
+type AgentSendMessage struct {
+	BaseMessage
+	SessionID     string `json:"sessionId"`
+	CorrelationID string `json:"correlationId"`
+	Content       string `json:"content"`
+	Deadline      string `json:"deadline,omitempty"`  // Absolute, RFC3339
+	TimeoutMs     int    `json:"timeoutMs,omitempty"` // Relative to receipt by the relay
+}
+
+// DeadlineFrom returns when the request must be answered, measured from now
+// ok is false if the client set no deadline (pure function)
+func (m AgentSendMessage) DeadlineFrom(now time.Time) (deadline time.Time, ok bool) {
+	if m.TimeoutMs > 0 {
+		deadline, ok = now.Add(time.Duration(m.TimeoutMs)*time.Millisecond), true
+	}
+	if m.Deadline != "" {
+		// Format checked by ParseAgentSend
+		if abs, err := time.Parse(time.RFC3339, m.Deadline); err == nil && (!ok || abs.Before(deadline)) {
+			deadline, ok = abs, true
+		}
+	}
+	return deadline, ok
+}
+
+// AgentCancelMessage is sent by clients to stop an in-flight agent reply
+type AgentCancelMessage struct {
+	BaseMessage
+	SessionID     string `json:"sessionId"`
+	CorrelationID string `json:"correlationId"`
+}
+
+// AgentToolDecisionMessage is sent by clients to approve or deny the tool
+// call an agent reply left awaiting approval (a toolCall part with an id)
+// The agent's reply streams back like one to agent:message, under the new
+// correlationId. Calls the session's tool policy allows or denies never reach
+// the client.
+type AgentToolDecisionMessage struct {
+	BaseMessage
+	SessionID     string `json:"sessionId"`
+	CorrelationID string `json:"correlationId"`
+	ToolCallID    string `json:"toolCallId"`
+	Approved      bool   `json:"approved"`
+}
+
+// AgentCancelledMessage confirms a cancelled reply; it replaces agent:complete
+// as the last message of that reply. Seq is the number of deltas sent.
+type AgentCancelledMessage struct {
+	BaseMessage
+	SessionID     string `json:"sessionId"`
+	CorrelationID string `json:"correlationId"`
+	Seq           int    `json:"seq"`
+	Timestamp     string `json:"timestamp"`
+}
+
+// AgentSpawnMessage asks the relay to start an agent for a role
+// With DryRun set nothing is spawned; the relay answers with agent:spawn_plan.
+// The embedded AgentConfig (systemPrompt, model, temperature, maxTokens) is
+// sent to the agent when it is initialized; its kind picks which of the
+// relay's agent backends is started. A payloadKey turns on end-to-end
+// encryption (see pkg/e2e): the agent gets the key in its environment, and
+// the session then only accepts sealed prompts.
+type AgentSpawnMessage struct {
+	BaseMessage
+	session.AgentConfig
+	Role       string `json:"role"`
+	Workspace  string `json:"workspace"`
+	SeedFrom   string `json:"seedFrom,omitempty"` // Create a missing workspace from this directory
+	Name       string `json:"name,omitempty"`
+	TTLSeconds int    `json:"ttlSeconds,omitempty"` // Terminate the session this long after spawn (0 = relay default)
+	DryRun     bool   `json:"dryRun,omitempty"`
+	PayloadKey string `json:"payloadKey,omitempty"` // Base64 AES-256 key; passed to the agent, never stored
+}
+
+// AgentSpawnPlanMessage reports the pre-spawn checks for a dry-run agent:spawn
+type AgentSpawnPlanMessage struct {
+	BaseMessage
+	Role      string       `json:"role"`
+	Workspace string       `json:"workspace"`
+	Checks    []SpawnCheck `json:"checks"`
+	OK        bool         `json:"ok"`
+	Timestamp string       `json:"timestamp"`
+}
+
+// AgentSpawnedMessage confirms an agent was started
+type AgentSpawnedMessage struct {
+	BaseMessage
+	SessionID string `json:"sessionId"`
+	Role      string `json:"role"`
+	Name      string `json:"name,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// SessionCreateFromTemplateMessage asks the relay to launch a configured template
+type SessionCreateFromTemplateMessage struct {
+	BaseMessage
+	Template string `json:"template"`
+}
+
+// SessionTemplateResultMessage reports the per-agent outcome of a template launch
+type SessionTemplateResultMessage struct {
+	BaseMessage
+	Template  string              `json:"template"`
+	Agents    []AgentLaunchResult `json:"agents"`
+	OK        bool                `json:"ok"`
+	Timestamp string              `json:"timestamp"`
+}
+
+// SessionReattachMessage asks the relay to bind a detached session to this
+// connection; sessions are detached when the connection that owned them closes
+type SessionReattachMessage struct {
+	BaseMessage
+	SessionID string `json:"sessionId"`
+}
+
+// SessionReattachedMessage confirms a session now belongs to this connection
+type SessionReattachedMessage struct {
+	BaseMessage
+	SessionID string `json:"sessionId"`
+	Role      string `json:"role"`
+	Name      string `json:"name,omitempty"`
+	State     string `json:"state"`
+	Timestamp string `json:"timestamp"`
+}
+
+// SessionObserveMessage asks the relay to let this connection watch a session
+// read-only: it receives agent output and state changes but cannot prompt,
+// cancel or terminate
+type SessionObserveMessage struct {
+	BaseMessage
+	SessionID string `json:"sessionId"`
+}
+
+// SessionObservingMessage confirms this connection now observes a session
+type SessionObservingMessage struct {
+	BaseMessage
+	SessionID string `json:"sessionId"`
+	Role      string `json:"role"`
+	Name      string `json:"name,omitempty"`
+	State     string `json:"state"`
+	Timestamp string `json:"timestamp"`
+}
+
+// SessionShareMessage asks the relay to let another user prompt a session
+// Only the session's owner may share it.
+type SessionShareMessage struct {
+	BaseMessage
+	SessionID string `json:"sessionId"`
+	UserID    string `json:"userId"`
+}
+
+// SessionUnshareMessage revokes a collaborator's access to a session
+type SessionUnshareMessage struct {
+	BaseMessage
+	SessionID string `json:"sessionId"`
+	UserID    string `json:"userId"`
+}
+
+// SessionTransferMessage hands a session to another user, who becomes its
+// owner; only the current owner may transfer it
+type SessionTransferMessage struct {
+	BaseMessage
+	SessionID  string `json:"sessionId"`
+	UserID     string `json:"userId"`               // The new owner
+	KeepAccess bool   `json:"keepAccess,omitempty"` // Stay on as a collaborator
+}
+
+// SessionAccessMessage reports who may use a session after a share, unshare
+// or transfer
+type SessionAccessMessage struct {
+	BaseMessage
+	SessionID     string   `json:"sessionId"`
+	OwnerID       string   `json:"ownerId"`
+	Collaborators []string `json:"collaborators"`
+	Timestamp     string   `json:"timestamp"`
+}
+
+// ConnectionWhoamiMessage asks the relay for its view of this connection
+type ConnectionWhoamiMessage struct {
+	BaseMessage
+}
+
+// ConnectionInfo is the relay's view of one WebSocket connection
+type ConnectionInfo struct {
+	ConnectionID     string   `json:"connectionId"`
+	UserID           string   `json:"userId,omitempty"` // Authenticated user; empty when anonymous
+	RemoteAddr       string   `json:"remoteAddr"`
+	Origin           string   `json:"origin,omitempty"`
+	Features         []string `json:"features"`   // Optional message handlers enabled on this relay
+	SessionIDs       []string `json:"sessionIds"` // Sessions owned by the connection
+	MessagesReceived int64    `json:"messagesReceived"`
+	MessagesSent     int64    `json:"messagesSent"`
+	ConnectedAt      string   `json:"connectedAt"`
+}
+
+// ConnectionInfoMessage answers connection:whoami
+type ConnectionInfoMessage struct {
+	BaseMessage
+	ConnectionInfo
+	Timestamp string `json:"timestamp"`
+}
+
+// SessionExpiringMessage warns the owning connection that a session will be
+// terminated when its TTL runs out, however active it is
+type SessionExpiringMessage struct {
+	BaseMessage
+	SessionID        string `json:"sessionId"`
+	Role             string `json:"role"`
+	Name             string `json:"name,omitempty"`
+	ExpiresAt        string `json:"expiresAt"`
+	RemainingSeconds int    `json:"remainingSeconds"`
+	Timestamp        string `json:"timestamp"`
+}
+
+// AgentResourceWarningMessage tells the owning connection that a session's
+// agent process went over a CPU or memory threshold
+type AgentResourceWarningMessage struct {
+	BaseMessage
+	SessionID  string  `json:"sessionId"`
+	Role       string  `json:"role"`
+	Name       string  `json:"name,omitempty"`
+	Resource   string  `json:"resource"` // "cpu" or "memory"
+	CPUPercent float64 `json:"cpuPercent"`
+	RSSBytes   int64   `json:"rssBytes"`
+	Limit      float64 `json:"limit"` // Percent of one core for cpu, bytes for memory
+	Timestamp  string  `json:"timestamp"`
+}
+
+// AttachmentStoredMessage acknowledges a binary frame kept as an attachment
+type AttachmentStoredMessage struct {
+	BaseMessage
+	Attachment Attachment `json:"attachment"`
+	Timestamp  string     `json:"timestamp"`
+}
+
+// GitOpenPRMessage asks the relay to push a session's worktree branch and
+// open a pull request for it
+type GitOpenPRMessage struct {
+	BaseMessage
+	SessionID string `json:"sessionId"`
+	Title     string `json:"title,omitempty"` // Defaults to the session's first prompt
+	Base      string `json:"base,omitempty"`  // Defaults to the configured base branch
+	Draft     bool   `json:"draft,omitempty"`
+}
+
+// GitPROpenedMessage reports the pull request opened for a session
+type GitPROpenedMessage struct {
+	BaseMessage
+	SessionID string `json:"sessionId"`
+	Branch    string `json:"branch"`
+	URL       string `json:"url"`
+	Number    int    `json:"number"`
+	Timestamp string `json:"timestamp"`
+}
+
 // NewConnectionEstablished creates a connection established message (pure function)
 func NewConnectionEstablished(serverID, timestamp string) ConnectionEstablishedMessage {
 	return ConnectionEstablishedMessage{
@@ -138,3 +505,492 @@ func NewErrorMessage(code, message string, recoverable bool) ErrorMessage {
 		},
 	}
 }
+
+// NewAgentStateMessage creates an agent state change notification (pure function)
+// Pass a nil errDetail for ordinary transitions
+func NewAgentStateMessage(sessionID, role, from, to, reason, timestamp string, errDetail *ErrorDetail) AgentStateMessage {
+	return AgentStateMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "agent:state",
+		},
+		Error:     errDetail,
+		SessionID: sessionID,
+		Role:      role,
+		From:      from,
+		To:        to,
+		Reason:    reason,
+		Timestamp: timestamp,
+	}
+}
+
+// NewAgentReadyMessage creates an agent ready notification from what the
+// agent reported at initialize (nil = nothing) (pure function)
+func NewAgentReadyMessage(sessionID, role string, info *acp.InitializeResult, timestamp string) AgentReadyMessage {
+	msg := AgentReadyMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "agent:ready",
+		},
+		SessionID: sessionID,
+		Role:      role,
+		Timestamp: timestamp,
+	}
+	if info != nil {
+		capabilities := info.Capabilities
+		msg.Capabilities = &capabilities
+		msg.AgentName = info.AgentName
+		msg.AgentVersion = info.AgentVersion
+		msg.ProtocolVersion = info.ProtocolVersion
+	}
+	return msg
+}
+
+// NewAgentDeltaMessage creates a streamed reply chunk (pure function)
+func NewAgentDeltaMessage(sessionID, correlationID string, seq int, content string) AgentDeltaMessage {
+	return AgentDeltaMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "agent:delta",
+		},
+		SessionID:     sessionID,
+		CorrelationID: correlationID,
+		Content:       content,
+		Seq:           seq,
+	}
+}
+
+// NewAgentCompleteMessage creates the end-of-reply marker (pure function)
+// Pass a nil errDetail when the reply succeeded
+func NewAgentCompleteMessage(sessionID, correlationID string, seq int, parts []acp.Part, timestamp string, errDetail *ErrorDetail) AgentCompleteMessage {
+	return AgentCompleteMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "agent:complete",
+		},
+		Error:         errDetail,
+		SessionID:     sessionID,
+		CorrelationID: correlationID,
+		Parts:         parts,
+		Seq:           seq,
+		Timestamp:     timestamp,
+	}
+}
+
+// NewAgentCancelledMessage creates a cancellation confirmation (pure function)
+func NewAgentCancelledMessage(sessionID, correlationID string, seq int, timestamp string) AgentCancelledMessage {
+	return AgentCancelledMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "agent:cancelled",
+		},
+		SessionID:     sessionID,
+		CorrelationID: correlationID,
+		Seq:           seq,
+		Timestamp:     timestamp,
+	}
+}
+
+// ParseAgentSend decodes and checks an agent:message message (pure function)
+func ParseAgentSend(data []byte) (AgentSendMessage, error) {
+	var msg AgentSendMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("Invalid JSON: %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.SessionID == "" || msg.CorrelationID == "" || msg.Content == "" {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "agent:message requires sessionId, correlationId and content",
+			Recoverable: true,
+		}
+	}
+	if msg.TimeoutMs < 0 {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "agent:message timeoutMs cannot be negative",
+			Recoverable: true,
+		}
+	}
+	if msg.Deadline != "" {
+		if _, err := time.Parse(time.RFC3339, msg.Deadline); err != nil {
+			return msg, ValidationError{
+				Code:        "INVALID_MESSAGE",
+				Message:     "agent:message deadline must be an RFC3339 timestamp",
+				Recoverable: true,
+			}
+		}
+	}
+	return msg, nil
+}
+
+// ParseAgentCancel decodes and checks an agent:cancel message (pure function)
+func ParseAgentCancel(data []byte) (AgentCancelMessage, error) {
+	var msg AgentCancelMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("Invalid JSON: %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.SessionID == "" || msg.CorrelationID == "" {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "agent:cancel requires sessionId and correlationId",
+			Recoverable: true,
+		}
+	}
+	return msg, nil
+}
+
+// ParseAgentToolDecision parses and validates an agent:tool_decision message
+func ParseAgentToolDecision(data []byte) (AgentToolDecisionMessage, error) {
+	var msg AgentToolDecisionMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("Invalid JSON: %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.SessionID == "" || msg.CorrelationID == "" || msg.ToolCallID == "" {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "agent:tool_decision requires sessionId, correlationId and toolCallId",
+			Recoverable: true,
+		}
+	}
+	return msg, nil
+}
+
+// NewSessionTemplateResultMessage creates a template launch report (pure function)
+func NewSessionTemplateResultMessage(result TemplateResult, timestamp string) SessionTemplateResultMessage {
+	return SessionTemplateResultMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "session:template_result",
+		},
+		Template:  result.Template,
+		Agents:    result.Agents,
+		OK:        result.OK,
+		Timestamp: timestamp,
+	}
+}
+
+// NewAttachmentStoredMessage creates an attachment receipt (pure function)
+func NewAttachmentStoredMessage(att Attachment, timestamp string) AttachmentStoredMessage {
+	return AttachmentStoredMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "attachment:stored",
+		},
+		Attachment: att,
+		Timestamp:  timestamp,
+	}
+}
+
+// ParseSessionCreateFromTemplate decodes and checks a session:create_from_template message (pure function)
+func ParseSessionCreateFromTemplate(data []byte) (SessionCreateFromTemplateMessage, error) {
+	var msg SessionCreateFromTemplateMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("Invalid JSON: %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.Template == "" {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "session:create_from_template requires template",
+			Recoverable: true,
+		}
+	}
+	return msg, nil
+}
+
+// ParseAgentSpawn decodes and checks an agent:spawn message (pure function)
+// Role and workspace are validated further by Spawner.Plan
+func ParseAgentSpawn(data []byte) (AgentSpawnMessage, error) {
+	var msg AgentSpawnMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("Invalid JSON: %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.TTLSeconds < 0 {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "agent:spawn ttlSeconds cannot be negative",
+			Recoverable: true,
+		}
+	}
+	if err := msg.AgentConfig.Validate(); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("agent:spawn %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.PayloadKey != "" {
+		if _, err := e2e.ParseKey(msg.PayloadKey); err != nil {
+			return msg, ValidationError{
+				Code:        "INVALID_MESSAGE",
+				Message:     fmt.Sprintf("agent:spawn payloadKey: %v", err),
+				Recoverable: true,
+			}
+		}
+		msg.EncryptedPayloads = true
+	} else if msg.EncryptedPayloads {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "agent:spawn encryptedPayloads requires a payloadKey",
+			Recoverable: true,
+		}
+	}
+	return msg, nil
+}
+
+// ParseSessionReattach decodes a session:reattach message (pure function)
+func ParseSessionReattach(data []byte) (SessionReattachMessage, error) {
+	var msg SessionReattachMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("Invalid JSON: %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.SessionID == "" {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "session:reattach requires sessionId",
+			Recoverable: true,
+		}
+	}
+	return msg, nil
+}
+
+// NewSessionReattachedMessage creates a reattach confirmation (pure function)
+func NewSessionReattachedMessage(sessionID, role, name, state, timestamp string) SessionReattachedMessage {
+	return SessionReattachedMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "session:reattached",
+		},
+		SessionID: sessionID,
+		Role:      role,
+		Name:      name,
+		State:     state,
+		Timestamp: timestamp,
+	}
+}
+
+// ParseSessionObserve decodes a session:observe message (pure function)
+func ParseSessionObserve(data []byte) (SessionObserveMessage, error) {
+	var msg SessionObserveMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("Invalid JSON: %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.SessionID == "" {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "session:observe requires sessionId",
+			Recoverable: true,
+		}
+	}
+	return msg, nil
+}
+
+// NewSessionObservingMessage creates an observe confirmation (pure function)
+func NewSessionObservingMessage(sessionID, role, name, state, timestamp string) SessionObservingMessage {
+	return SessionObservingMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "session:observing",
+		},
+		SessionID: sessionID,
+		Role:      role,
+		Name:      name,
+		State:     state,
+		Timestamp: timestamp,
+	}
+}
+
+// ParseSessionShare decodes a session:share or session:unshare message; both
+// carry the same fields (pure function)
+func ParseSessionShare(data []byte) (SessionShareMessage, error) {
+	var msg SessionShareMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("Invalid JSON: %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.SessionID == "" || msg.UserID == "" {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("%s requires sessionId and userId", msg.Type),
+			Recoverable: true,
+		}
+	}
+	return msg, nil
+}
+
+// ParseSessionTransfer decodes a session:transfer message (pure function)
+func ParseSessionTransfer(data []byte) (SessionTransferMessage, error) {
+	var msg SessionTransferMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("Invalid JSON: %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.SessionID == "" || msg.UserID == "" {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "session:transfer requires sessionId and userId",
+			Recoverable: true,
+		}
+	}
+	return msg, nil
+}
+
+// NewSessionAccessMessage creates a session:access reply (pure function)
+func NewSessionAccessMessage(sessionID, ownerID string, collaborators []string, timestamp string) SessionAccessMessage {
+	if collaborators == nil {
+		collaborators = []string{}
+	}
+	return SessionAccessMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "session:access",
+		},
+		SessionID:     sessionID,
+		OwnerID:       ownerID,
+		Collaborators: collaborators,
+		Timestamp:     timestamp,
+	}
+}
+
+// NewConnectionInfoMessage creates a connection:info reply (pure function)
+func NewConnectionInfoMessage(info ConnectionInfo, timestamp string) ConnectionInfoMessage {
+	return ConnectionInfoMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "connection:info",
+		},
+		ConnectionInfo: info,
+		Timestamp:      timestamp,
+	}
+}
+
+// NewSessionExpiringMessage creates a TTL warning (pure function)
+func NewSessionExpiringMessage(sessionID, role, name, expiresAt string, remainingSeconds int, timestamp string) SessionExpiringMessage {
+	return SessionExpiringMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "session:expiring",
+		},
+		SessionID:        sessionID,
+		Role:             role,
+		Name:             name,
+		ExpiresAt:        expiresAt,
+		RemainingSeconds: remainingSeconds,
+		Timestamp:        timestamp,
+	}
+}
+
+// NewAgentResourceWarningMessage creates a resource threshold warning (pure function)
+func NewAgentResourceWarningMessage(sessionID, role, name, resource string, cpuPercent float64, rssBytes int64, limit float64, timestamp string) AgentResourceWarningMessage {
+	return AgentResourceWarningMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "agent:resource_warning",
+		},
+		SessionID:  sessionID,
+		Role:       role,
+		Name:       name,
+		Resource:   resource,
+		CPUPercent: cpuPercent,
+		RSSBytes:   rssBytes,
+		Limit:      limit,
+		Timestamp:  timestamp,
+	}
+}
+
+// ParseGitOpenPR decodes and checks a git:open_pr message (pure function)
+func ParseGitOpenPR(data []byte) (GitOpenPRMessage, error) {
+	var msg GitOpenPRMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     fmt.Sprintf("Invalid JSON: %v", err),
+			Recoverable: true,
+		}
+	}
+	if msg.SessionID == "" {
+		return msg, ValidationError{
+			Code:        "INVALID_MESSAGE",
+			Message:     "git:open_pr requires sessionId",
+			Recoverable: true,
+		}
+	}
+	return msg, nil
+}
+
+// NewGitPROpenedMessage creates a pull request confirmation (pure function)
+func NewGitPROpenedMessage(sessionID, branch string, pr *github.PullRequest, timestamp string) GitPROpenedMessage {
+	return GitPROpenedMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "git:pr_opened",
+		},
+		SessionID: sessionID,
+		Branch:    branch,
+		URL:       pr.URL,
+		Number:    pr.Number,
+		Timestamp: timestamp,
+	}
+}
+
+// NewAgentSpawnPlanMessage creates a dry-run spawn report (pure function)
+func NewAgentSpawnPlanMessage(plan SpawnPlan, timestamp string) AgentSpawnPlanMessage {
+	return AgentSpawnPlanMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "agent:spawn_plan",
+		},
+		Role:      plan.Role,
+		Workspace: plan.Workspace,
+		Checks:    plan.Checks,
+		OK:        plan.OK,
+		Timestamp: timestamp,
+	}
+}
+
+// NewAgentSpawnedMessage creates a spawn confirmation (pure function)
+func NewAgentSpawnedMessage(sessionID, role, name, timestamp string) AgentSpawnedMessage {
+	return AgentSpawnedMessage{
+		BaseMessage: BaseMessage{
+			Version: ProtocolVersion,
+			Type:    "agent:spawned",
+		},
+		SessionID: sessionID,
+		Role:      role,
+		Name:      name,
+		Timestamp: timestamp,
+	}
+}
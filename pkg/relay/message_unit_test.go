@@ -2,6 +2,7 @@ package relay
 
 import (
 	"testing"
+	"time"
 )
 
 // Unit tests for decomposed validation functions
@@ -43,6 +44,44 @@ func TestParseMessage_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestValidateEncoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		valid bool
+	}{
+		{"ascii", []byte(`{"type":"x"}`), true},
+		{"multibyte", []byte(`{"message":"héllo 🙂"}`), true},
+		{"stray continuation byte", []byte("{\"message\":\"\x80\"}"), false},
+		{"truncated sequence", []byte("{\"message\":\"\xe2\x82\"}"), false},
+		{"overlong encoding", []byte("{\"message\":\"\xc0\xaf\"}"), false},
+		{"encoded surrogate", []byte("{\"message\":\"\xed\xa0\x80\"}"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEncoding(tt.data)
+			if tt.valid {
+				if err != nil {
+					t.Errorf("expected valid, got %v", err)
+				}
+				return
+			}
+			verr, ok := err.(ValidationError)
+			if !ok || verr.Code != "INVALID_ENCODING" || !verr.Recoverable {
+				t.Errorf("expected recoverable INVALID_ENCODING, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateMessage_RejectsInvalidUTF8(t *testing.T) {
+	err := ValidateMessage([]byte("{\"version\":\"1.0\",\"type\":\"test\xff\"}"))
+	if verr, ok := err.(ValidationError); !ok || verr.Code != "INVALID_ENCODING" {
+		t.Errorf("expected INVALID_ENCODING before JSON parsing, got %v", err)
+	}
+}
+
 func TestParseMessage_EmptyJSON(t *testing.T) {
 	data := []byte(`{}`)
 
@@ -250,3 +289,49 @@ func TestNewErrorMessage_Structure(t *testing.T) {
 		t.Errorf("expected recoverable %v, got %v", recoverable, errorMsg.Error.Recoverable)
 	}
 }
+
+func TestParseAgentSend_Deadlines(t *testing.T) {
+	base := `{"version":"1.0","type":"agent:message","sessionId":"s","correlationId":"r","content":"hi"`
+	tests := []struct {
+		name    string
+		extra   string
+		wantErr bool
+	}{
+		{"no deadline", ``, false},
+		{"timeout", `,"timeoutMs":1500`, false},
+		{"deadline", `,"deadline":"2025-10-23T12:00:05Z"`, false},
+		{"negative timeout", `,"timeoutMs":-1`, true},
+		{"bad deadline", `,"deadline":"in five seconds"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAgentSend([]byte(base + tt.extra + `}`))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAgentSendMessage_DeadlineFrom(t *testing.T) {
+	now := time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		msg  AgentSendMessage
+		want time.Time
+		ok   bool
+	}{
+		{"none", AgentSendMessage{}, time.Time{}, false},
+		{"timeout", AgentSendMessage{TimeoutMs: 1500}, now.Add(1500 * time.Millisecond), true},
+		{"deadline", AgentSendMessage{Deadline: "2025-10-23T12:00:05Z"}, now.Add(5 * time.Second), true},
+		{"earlier of both", AgentSendMessage{TimeoutMs: 9000, Deadline: "2025-10-23T12:00:05Z"}, now.Add(5 * time.Second), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.msg.DeadlineFrom(now)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("expected %v/%v, got %v/%v", tt.want, tt.ok, got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package relay
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsIOTimeout bounds the handshake and each publish write
+const natsIOTimeout = 5 * time.Second
+
+// NATSPublisher publishes to a NATS server using the core text protocol
+// It connects lazily and redials on the next Publish after a failure.
+// Core NATS is at-most-once; auth, TLS and JetStream are not supported.
+type NATSPublisher struct {
+	logger Logger
+	conn   net.Conn
+	addr   string
+	mu     sync.Mutex
+}
+
+// NewNATSPublisher creates a publisher for a URL such as nats://127.0.0.1:4222
+func NewNATSPublisher(rawURL string, logger Logger) (*NATSPublisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS URL: %w", err)
+	}
+	if u.Scheme != "nats" || u.Host == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q: want nats://host:port", rawURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	return &NATSPublisher{addr: addr, logger: logger}, nil
+}
+
+// Publish sends payload to the subject topic
+func (p *NATSPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	if topic == "" || strings.ContainsAny(topic, " \t\r\n") {
+		return fmt.Errorf("invalid NATS subject %q", topic)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.dial(ctx); err != nil {
+			return err
+		}
+	}
+
+	frame := append([]byte(fmt.Sprintf("PUB %s %d\r\n", topic, len(payload))), payload...)
+	frame = append(frame, '\r', '\n')
+
+	_ = p.conn.SetWriteDeadline(time.Now().Add(natsIOTimeout))
+	if _, err := p.conn.Write(frame); err != nil {
+		p.closeLocked()
+		return fmt.Errorf("NATS publish failed: %w", err)
+	}
+	return nil
+}
+
+// Close disconnects from the server
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closeLocked()
+}
+
+// dial connects and performs the INFO/CONNECT handshake (must hold mu)
+func (p *NATSPublisher) dial(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: natsIOTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return fmt.Errorf("NATS connect failed: %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+	_ = conn.SetDeadline(time.Now().Add(natsIOTimeout))
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		_ = conn.Close()
+		return fmt.Errorf("NATS handshake failed: expected INFO, got %q (%v)", strings.TrimSpace(line), err)
+	}
+	if _, err := conn.Write([]byte(`CONNECT {"verbose":false,"pedantic":false,"name":"ourocodus-relay"}` + "\r\n")); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("NATS handshake failed: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	p.conn = conn
+	go p.readLoop(conn, r)
+	return nil
+}
+
+// readLoop answers server PINGs and logs server errors until conn closes
+func (p *NATSPublisher) readLoop(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			p.mu.Lock()
+			if p.conn == conn {
+				p.closeLocked()
+			}
+			p.mu.Unlock()
+			return
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PING":
+			p.mu.Lock()
+			if p.conn == conn {
+				_ = conn.SetWriteDeadline(time.Now().Add(natsIOTimeout))
+				if _, err := conn.Write([]byte("PONG\r\n")); err != nil {
+					p.logger.Printf("NATS PONG failed: %v", err)
+				}
+			}
+			p.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			p.logger.Printf("NATS server error: %s", line)
+		}
+	}
+}
+
+// closeLocked drops the current connection (must hold mu)
+func (p *NATSPublisher) closeLocked() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
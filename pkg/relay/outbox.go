@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"context"
+	"sync"
+)
+
+// Default outbox water marks, in agent:delta messages
+const (
+	DefaultOutboxHighWater = 256
+	DefaultOutboxLowWater  = 64
+)
+
+// outbox queues a reply's agent:delta messages for the session's connections,
+// letting the agent run ahead of a slow client by up to highWater messages
+// Once the queue reaches highWater, push blocks until the sender has drained
+// it to lowWater. push is called from the delta callback, which runs on the
+// goroutine reading the agent's stdout, so a paused outbox stops reading from
+// the agent: its pipe fills and the agent itself is throttled. Each reply has
+// its own outbox, so a session running several replies at once
+// (concurrency.maxInFlight above 1) buffers up to highWater for each of them.
+// A reply to a shared session goes through one outbox for every connection,
+// and the slowest of them holds it back.
+type outbox struct {
+	send      func(v interface{})
+	highWater int
+	lowWater  int
+
+	mu     sync.Mutex
+	queue  []interface{}
+	paused bool
+	resume chan struct{} // Closed when the queue drains to lowWater
+	closed bool
+	pauses int // Times the queue reached highWater
+
+	wake chan struct{} // Signals the sender that the queue changed
+	done chan struct{} // Closed when the sender has written everything
+}
+
+// newOutbox starts a sender that writes queued messages with send
+func newOutbox(highWater, lowWater int, send func(v interface{})) *outbox {
+	o := &outbox{
+		send:      send,
+		highWater: highWater,
+		lowWater:  lowWater,
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	go o.run()
+	return o
+}
+
+// push queues v, first waiting for a paused outbox to drain
+// Returns ctx's error, without queuing v, if ctx is done while waiting.
+func (o *outbox) push(ctx context.Context, v interface{}) error {
+	o.mu.Lock()
+	for o.paused {
+		resume := o.resume
+		o.mu.Unlock()
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		o.mu.Lock()
+	}
+	o.queue = append(o.queue, v)
+	if len(o.queue) >= o.highWater {
+		o.paused = true
+		o.resume = make(chan struct{})
+		o.pauses++
+	}
+	o.mu.Unlock()
+	o.signal()
+	return nil
+}
+
+// close waits for every queued message to be sent, then stops the sender
+func (o *outbox) close() {
+	o.mu.Lock()
+	o.closed = true
+	o.mu.Unlock()
+	o.signal()
+	<-o.done
+}
+
+// discard drops every queued message, then stops the sender
+// A message the sender has already taken is still sent; a paused push resumes.
+func (o *outbox) discard() {
+	o.mu.Lock()
+	for i := range o.queue {
+		o.queue[i] = nil
+	}
+	o.queue = nil
+	if o.paused {
+		o.paused = false
+		close(o.resume)
+	}
+	o.mu.Unlock()
+	o.close()
+}
+
+// pauseCount returns how many times the outbox paused the agent
+func (o *outbox) pauseCount() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.pauses
+}
+
+func (o *outbox) signal() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run sends queued messages in order until the outbox is closed and empty
+func (o *outbox) run() {
+	defer close(o.done)
+	for {
+		o.mu.Lock()
+		if len(o.queue) == 0 {
+			closed := o.closed
+			o.mu.Unlock()
+			if closed {
+				return
+			}
+			<-o.wake
+			continue
+		}
+		v := o.queue[0]
+		o.queue[0] = nil
+		o.queue = o.queue[1:]
+		if o.paused && len(o.queue) <= o.lowWater {
+			o.paused = false
+			close(o.resume)
+		}
+		o.mu.Unlock()
+		o.send(v)
+	}
+}
@@ -0,0 +1,133 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutbox_SendsInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var sent []interface{}
+	out := newOutbox(4, 1, func(v interface{}) {
+		mu.Lock()
+		sent = append(sent, v)
+		mu.Unlock()
+	})
+	for i := 0; i < 20; i++ {
+		if err := out.push(context.Background(), i); err != nil {
+			t.Fatalf("push %d failed: %v", i, err)
+		}
+	}
+	out.close()
+
+	if len(sent) != 20 {
+		t.Fatalf("expected 20 messages sent, got %d", len(sent))
+	}
+	for i, v := range sent {
+		if v != i {
+			t.Fatalf("message %d out of order: %v", i, v)
+		}
+	}
+}
+
+func TestOutbox_PausesAtHighWaterUntilLowWater(t *testing.T) {
+	release := make(chan struct{})
+	sending := make(chan interface{}, 10)
+	out := newOutbox(3, 1, func(v interface{}) {
+		sending <- v
+		<-release // A client that reads nothing until told to
+	})
+	defer out.close()
+
+	// The sender takes message 0 and blocks; 1-3 fill the queue to highWater
+	_ = out.push(context.Background(), 0)
+	<-sending
+	for i := 1; i < 4; i++ {
+		if err := out.push(context.Background(), i); err != nil {
+			t.Fatalf("push %d failed: %v", i, err)
+		}
+	}
+
+	pushed := make(chan error, 1)
+	go func() { pushed <- out.push(context.Background(), 4) }()
+	select {
+	case err := <-pushed:
+		t.Fatalf("expected push to wait above highWater, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Sending 0 and 1 leaves 2 and 3 queued: still above lowWater
+	release <- struct{}{}
+	<-sending
+	select {
+	case err := <-pushed:
+		t.Fatalf("expected push to wait until lowWater, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Taking 2 leaves one queued: resume
+	release <- struct{}{}
+	<-sending
+	if err := <-pushed; err != nil {
+		t.Fatalf("push failed after resuming: %v", err)
+	}
+	if n := out.pauseCount(); n != 1 {
+		t.Errorf("expected 1 pause, got %d", n)
+	}
+	close(release)
+}
+
+func TestOutbox_PushGivesUpWhenCancelled(t *testing.T) {
+	release := make(chan struct{})
+	out := newOutbox(1, 0, func(v interface{}) { <-release })
+	defer out.close()
+	defer close(release)
+
+	_ = out.push(context.Background(), 0)
+	_ = out.push(context.Background(), 1) // Queued while 0 is stuck: paused
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := out.push(ctx, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestOutbox_DiscardDropsQueued(t *testing.T) {
+	release := make(chan struct{})
+	sending := make(chan interface{}, 10)
+	out := newOutbox(2, 0, func(v interface{}) {
+		sending <- v
+		<-release
+	})
+
+	// The sender takes 0 and blocks; 1 and 2 fill the queue and pause it
+	_ = out.push(context.Background(), 0)
+	<-sending
+	_ = out.push(context.Background(), 1)
+	_ = out.push(context.Background(), 2)
+	pushed := make(chan error, 1)
+	go func() { pushed <- out.push(context.Background(), 3) }()
+
+	discarded := make(chan struct{})
+	go func() {
+		out.discard()
+		close(discarded)
+	}()
+	if err := <-pushed; err != nil {
+		t.Fatalf("expected the paused push to resume on discard, got %v", err)
+	}
+	close(release)
+	<-discarded
+
+	// 3 was pushed after the queue was dropped, so it may still go out
+	close(sending)
+	for v := range sending {
+		if v != 3 {
+			t.Errorf("expected queued messages dropped, sent %v", v)
+		}
+	}
+}
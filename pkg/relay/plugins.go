@@ -0,0 +1,201 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPluginScanInterval is how often AgentPlugins looks for changed plugins
+// unless configured otherwise
+const DefaultPluginScanInterval = 30 * time.Second
+
+// pluginManifestExt names the optional manifest beside a plugin binary
+const pluginManifestExt = ".json"
+
+// AgentPlugins registers the agent plugins found in a directory as agent
+// kinds, and keeps the registry in step as plugins are added, changed or
+// removed while the relay runs
+// A plugin is an executable that speaks ACP (JSON-RPC over stdio) like
+// claude-code-acp, started in the session's workspace with
+// "--workspace <workspace>" and ANTHROPIC_API_KEY set. Its kind is its file
+// name without extension. An optional <kind>.json manifest beside it sets its
+// args and env as in AgentKindConfig; plugins run on the relay's host, not in
+// containers. Kinds declared in config take precedence over plugins of the same
+// name. Agents already started keep running when their plugin goes away.
+type AgentPlugins struct {
+	dir        string
+	registry   *FactoryRegistry
+	newFactory func(AgentKindConfig) AgentFactory
+	logger     Logger
+
+	mu     sync.Mutex
+	loaded map[string]pluginFingerprint // Kinds this scanner registered
+}
+
+// pluginFingerprint tells whether a plugin changed since it was registered
+type pluginFingerprint struct {
+	binary, manifest time.Time
+	size             int64
+}
+
+// NewAgentPlugins creates a scanner registering the plugins in dir with
+// registry; newFactory builds the factory for each plugin's settings, e.g.
+// AgentKindConfig.NewFactory with the relay's API key
+func NewAgentPlugins(dir string, registry *FactoryRegistry, newFactory func(AgentKindConfig) AgentFactory, logger Logger) *AgentPlugins {
+	return &AgentPlugins{
+		dir:        dir,
+		registry:   registry,
+		newFactory: newFactory,
+		logger:     logger,
+		loaded:     make(map[string]pluginFingerprint),
+	}
+}
+
+// Run scans every interval until ctx is done
+func (p *AgentPlugins) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, _, err := p.Scan(); err != nil {
+				p.logger.Printf("Agent plugin scan failed: dir=%s err=%v", p.dir, err)
+			}
+		}
+	}
+}
+
+// Scan registers new and changed plugins and unregisters removed ones,
+// returning the kinds registered and removed
+// A plugin with an invalid manifest is logged and skipped, unregistering an
+// earlier version of it. Fails only if the directory cannot be read.
+func (p *AgentPlugins) Scan() (registered, removed []string, err error) {
+	found, err := p.discover()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	kinds := make([]string, 0, len(found))
+	for kind := range found {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds) // Stable registration order
+	for _, kind := range kinds {
+		plugin := found[kind]
+		old, ours := p.loaded[kind]
+		if !ours && p.registry.Has(kind) {
+			continue // Declared in config
+		}
+		if ours && old == plugin.fingerprint {
+			continue
+		}
+		cfg, err := readPluginManifest(plugin.manifest)
+		if err != nil {
+			p.logger.Printf("Agent plugin skipped: kind=%s err=%v", kind, err)
+			delete(found, kind)
+			continue
+		}
+		cfg.Command = plugin.binary
+		if cfg.Args == nil {
+			cfg.Args = []string{"--workspace", workspacePlaceholder}
+		}
+		p.registry.Register(kind, p.newFactory(cfg))
+		p.loaded[kind] = plugin.fingerprint
+		registered = append(registered, kind)
+		p.logger.Printf("Agent plugin registered: kind=%s path=%s", kind, plugin.binary)
+	}
+
+	for kind := range p.loaded {
+		if _, ok := found[kind]; !ok {
+			p.registry.Unregister(kind)
+			delete(p.loaded, kind)
+			removed = append(removed, kind)
+			p.logger.Printf("Agent plugin removed: kind=%s", kind)
+		}
+	}
+	sort.Strings(removed)
+	return registered, removed, nil
+}
+
+// pluginFile is a plugin found in the directory
+type pluginFile struct {
+	binary, manifest string // manifest is empty if there is none
+	fingerprint      pluginFingerprint
+}
+
+// discover lists the executables in the plugin directory by kind
+// Hidden files, directories and manifests are ignored.
+func (p *AgentPlugins) discover() (map[string]pluginFile, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]pluginFile)
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || filepath.Ext(name) == pluginManifestExt {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(p.dir, name)) // Follows symlinks to installed binaries
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		kind := strings.TrimSuffix(name, filepath.Ext(name))
+		if other, ok := found[kind]; ok {
+			p.logger.Printf("Agent plugin ignored: kind=%s path=%s (%s has the same kind)", kind, name, filepath.Base(other.binary))
+			continue
+		}
+		plugin := pluginFile{
+			binary:      filepath.Join(p.dir, name),
+			fingerprint: pluginFingerprint{binary: info.ModTime(), size: info.Size()},
+		}
+		manifest := filepath.Join(p.dir, kind+pluginManifestExt)
+		if minfo, err := os.Stat(manifest); err == nil {
+			plugin.manifest = manifest
+			plugin.fingerprint.manifest = minfo.ModTime()
+		}
+		found[kind] = plugin
+	}
+	return found, nil
+}
+
+// readPluginManifest reads a plugin's settings; no manifest means defaults
+func readPluginManifest(path string) (AgentKindConfig, error) {
+	var cfg AgentKindConfig
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is in the operator's plugin directory
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil // Removed since discovery
+	}
+	if err != nil {
+		return cfg, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("manifest: %w", err)
+	}
+	if cfg.Command != "" || cfg.Image != "" {
+		return cfg, fmt.Errorf("manifest: command and image cannot be set; the plugin itself runs")
+	}
+	if err := cfg.validate(); err != nil {
+		return cfg, fmt.Errorf("manifest: %w", err)
+	}
+	return cfg, nil
+}
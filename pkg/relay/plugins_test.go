@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAgentPlugins_Scan(t *testing.T) {
+	dir := t.TempDir()
+	writes := 0
+	write := func(name, content string, perm os.FileMode) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), perm); err != nil {
+			t.Fatal(err)
+		}
+		// Later writes must look changed even on coarse-grained filesystems
+		writes++
+		later := time.Now().Add(time.Duration(writes) * time.Second)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("gemini", "#!/bin/sh\n", 0o755)
+	write("codex.sh", "#!/bin/sh\n", 0o755)
+	write("codex.json", `{"args": ["--dir", "{workspace}"], "env": ["CODEX_MODE=fast"]}`, 0o644)
+	write("README", "not a plugin", 0o644)
+	write("claude", "#!/bin/sh\n", 0o755) // Declared in config
+
+	registry := NewFactoryRegistry(&mockAgentFactory{})
+	configured := &mockAgentFactory{}
+	registry.Register("claude", configured)
+	configs := make(map[string]AgentKindConfig)
+	plugins := NewAgentPlugins(dir, registry, func(cfg AgentKindConfig) AgentFactory {
+		configs[filepath.Base(cfg.Command)] = cfg
+		return &mockAgentFactory{}
+	}, &mockLogger{})
+
+	registered, removed, err := plugins.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if strings.Join(registered, ",") != "codex,gemini" || len(removed) != 0 {
+		t.Fatalf("expected codex and gemini registered, got %v removed %v", registered, removed)
+	}
+	if kinds := registry.Kinds(); strings.Join(kinds, ",") != "claude,codex,gemini" {
+		t.Errorf("unexpected kinds %v", kinds)
+	}
+	if cfg := configs["codex.sh"]; strings.Join(cfg.Args, " ") != "--dir {workspace}" || cfg.Env[0] != "CODEX_MODE=fast" {
+		t.Errorf("expected the manifest applied, got %+v", cfg)
+	}
+	if cfg := configs["gemini"]; strings.Join(cfg.Args, " ") != "--workspace {workspace}" {
+		t.Errorf("expected plugins without a manifest started with --workspace, got %+v", cfg)
+	}
+	if _, ok := configs["claude"]; ok {
+		t.Error("expected the configured kind left alone")
+	}
+
+	if registered, _, _ := plugins.Scan(); len(registered) != 0 {
+		t.Errorf("expected unchanged plugins left registered, got %v", registered)
+	}
+
+	// A broken manifest takes the plugin out; a removed binary too
+	write("codex.json", `{"image": "codex:1"}`, 0o644)
+	if err := os.Remove(filepath.Join(dir, "gemini")); err != nil {
+		t.Fatal(err)
+	}
+	registered, removed, err = plugins.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(registered) != 0 || strings.Join(removed, ",") != "codex,gemini" {
+		t.Errorf("expected codex and gemini removed, got registered %v removed %v", registered, removed)
+	}
+	if kinds := registry.Kinds(); strings.Join(kinds, ",") != "claude" {
+		t.Errorf("expected only the configured kind left, got %v", kinds)
+	}
+}
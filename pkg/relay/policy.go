@@ -0,0 +1,159 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/2389-research/ourocodus/pkg/policy"
+)
+
+// Metrics counting MessagePolicy decisions, inbound and outbound
+const (
+	MetricPolicyDenied   = "relay_policy_denied_total"
+	MetricPolicyReplaced = "relay_policy_replaced_total"
+	MetricPolicyErrors   = "relay_policy_errors_total" // The policy failed to decide
+)
+
+// MessagePolicy decides what happens to inbound protocol messages and
+// outbound agent output, e.g. a *policy.Hook running a WASM module
+type MessagePolicy interface {
+	Evaluate(ctx context.Context, in policy.Input) (policy.Decision, error)
+}
+
+// policyOutboundTypes are the outbound messages carrying agent output
+var policyOutboundTypes = map[string]bool{
+	"agent:delta":    true,
+	"agent:complete": true,
+}
+
+// WithMessagePolicy runs every valid inbound message, and every agent:delta
+// and agent:complete sent to clients, through p
+// Denied inbound messages are answered with a POLICY_DENIED error and denied
+// agent output is dropped; replacements are sent on in their place. When p
+// fails to decide the message is refused, or passed on unchanged if failOpen
+// is set.
+func WithMessagePolicy(p MessagePolicy, failOpen bool) ServerOption {
+	return func(s *Server) {
+		s.policy = p
+		s.policyFailOpen = failOpen
+	}
+}
+
+// applyInboundPolicy returns the message to route in place of rawMessage, or
+// false if it was refused and the client told why
+func (s *Server) applyInboundPolicy(conn WebSocketConn, rawMessage []byte) ([]byte, bool) {
+	decision, err := s.evaluatePolicy(s.userContext(conn), policy.Inbound, rawMessage)
+	if err == nil && decision.Action == policy.Replace {
+		if err = ValidateMessageWith(decision.Message, s.protocol); err != nil {
+			s.logger.Printf("Message policy replacement refused: %v", err)
+		}
+	}
+
+	var reply ErrorMessage
+	switch {
+	case err != nil && s.policyFailOpen:
+		return rawMessage, true
+	case err != nil:
+		reply = NewErrorMessage("POLICY_ERROR", "message policy failed", true)
+	case decision.Action == policy.Deny:
+		reason := decision.Reason
+		if reason == "" {
+			reason = "message denied by policy"
+		}
+		reply = NewErrorMessage("POLICY_DENIED", reason, true)
+	case decision.Action == policy.Replace:
+		return decision.Message, true
+	default:
+		return rawMessage, true
+	}
+	if err := conn.WriteJSON(reply); err != nil {
+		s.logger.Printf("Failed to send error response: %v", err)
+	}
+	return nil, false
+}
+
+// evaluatePolicy asks the policy about a message, logging and counting
+// anything but an unchanged allow
+func (s *Server) evaluatePolicy(ctx context.Context, direction string, message []byte) (policy.Decision, error) {
+	decision, err := s.policy.Evaluate(ctx, policy.Input{Direction: direction, Message: message})
+	switch {
+	case err != nil:
+		s.logger.Printf("Message policy failed: direction=%s failOpen=%t err=%v", direction, s.policyFailOpen, err)
+		s.metrics.IncCounter(MetricPolicyErrors)
+	case decision.Action == policy.Deny:
+		s.logger.Printf("Message policy denied: direction=%s reason=%q", direction, decision.Reason)
+		s.metrics.IncCounter(MetricPolicyDenied)
+	case decision.Action == policy.Replace:
+		s.logger.Printf("Message policy replaced: direction=%s", direction)
+		s.metrics.IncCounter(MetricPolicyReplaced)
+	}
+	return decision, err
+}
+
+// policyConn runs the agent output written to a connection through the
+// server's MessagePolicy
+// Deltas the policy denies, or fails to decide on, are dropped. agent:complete
+// always goes out, carrying a POLICY_DENIED or POLICY_ERROR error in place of
+// the reply, so clients still see the reply end.
+type policyConn struct {
+	WebSocketConn
+	server *Server
+}
+
+func (c *policyConn) WriteJSON(v interface{}) error {
+	frame, err := json.Marshal(v)
+	if err != nil {
+		return c.WebSocketConn.WriteJSON(v)
+	}
+	var base struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(frame, &base) != nil || !policyOutboundTypes[base.Type] {
+		return c.WebSocketConn.WriteJSON(v)
+	}
+
+	decision, err := c.server.evaluatePolicy(context.Background(), policy.Outbound, frame)
+	switch {
+	case err != nil && !c.server.policyFailOpen:
+		return c.refuse(base.Type, frame, "POLICY_ERROR", "message policy failed")
+	case err != nil:
+		return c.WebSocketConn.WriteJSON(v)
+	case decision.Action == policy.Deny:
+		reason := decision.Reason
+		if reason == "" {
+			reason = "agent output denied by policy"
+		}
+		return c.refuse(base.Type, frame, "POLICY_DENIED", reason)
+	case decision.Action == policy.Replace:
+		return c.WebSocketConn.WriteJSON(laneFrame{RawMessage: decision.Message, typ: base.Type})
+	default:
+		return c.WebSocketConn.WriteJSON(v)
+	}
+}
+
+// refuse drops refused agent output, ending the reply with an error if it was
+// its agent:complete
+func (c *policyConn) refuse(messageType string, frame []byte, code, reason string) error {
+	if messageType != "agent:complete" {
+		return nil // Dropped: the client cannot be sent output the policy has not passed
+	}
+	var complete AgentCompleteMessage
+	if err := json.Unmarshal(frame, &complete); err != nil {
+		return err
+	}
+	complete.Parts = nil
+	complete.Error = &ErrorDetail{Code: code, Message: reason, Recoverable: true}
+	return c.WebSocketConn.WriteJSON(complete)
+}
+
+// laneFrame is a pre-encoded replacement that keeps the writer lane of the
+// message it replaces, so a replaced delta stays in order with the others
+type laneFrame struct {
+	json.RawMessage
+	typ string
+}
+
+// MessageType returns the type of the replaced message
+func (f laneFrame) MessageType() string {
+	return f.typ
+}
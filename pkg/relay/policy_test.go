@@ -0,0 +1,153 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/policy"
+)
+
+// fakePolicy decides with a function, recording what it was asked
+type fakePolicy struct {
+	decide func(policy.Input) (policy.Decision, error)
+	inputs []policy.Input
+}
+
+func (p *fakePolicy) Evaluate(_ context.Context, in policy.Input) (policy.Decision, error) {
+	p.inputs = append(p.inputs, in)
+	return p.decide(in)
+}
+
+func TestServer_InboundPolicy(t *testing.T) {
+	whoami := []byte(`{"version":"1.0","type":"connection:whoami"}`)
+	tests := []struct {
+		name     string
+		decision policy.Decision
+		err      error
+		failOpen bool
+		wantCode string // Error code sent back; empty expects the whoami reply
+	}{
+		{name: "allow", decision: policy.Decision{Action: policy.Allow}},
+		{name: "deny", decision: policy.Decision{Action: policy.Deny, Reason: "not now"}, wantCode: "POLICY_DENIED"},
+		{name: "replace", decision: policy.Decision{Action: policy.Replace, Message: whoami}},
+		{name: "invalid replacement", decision: policy.Decision{Action: policy.Replace, Message: json.RawMessage(`{"type":"agent:message"}`)}, wantCode: "POLICY_ERROR"},
+		{name: "fail closed", err: errors.New("trap"), wantCode: "POLICY_ERROR"},
+		{name: "fail open", err: errors.New("trap"), failOpen: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakePolicy{decide: func(policy.Input) (policy.Decision, error) { return tt.decision, tt.err }}
+			server := NewServer(&mockIDGenerator{id: "conn"}, &mockLogger{}, &mockClock{now: testTime}, nil, WithMessagePolicy(p, tt.failOpen))
+			mock := &mockWebSocketConn{}
+			conn, _, _ := server.registerConnection(mock, httptest.NewRequest(http.MethodGet, "/ws", nil), "alice")
+
+			// Without a streamer session:observe is unknown, so only a replacement gets a whoami reply
+			msg := whoami
+			if tt.decision.Action == policy.Replace {
+				msg = []byte(`{"version":"1.0","type":"session:observe","sessionId":"s"}`)
+			}
+			if closeConn := server.handleMessage(conn, msg); closeConn {
+				t.Fatal("expected the connection to stay open")
+			}
+			if len(p.inputs) != 1 || p.inputs[0].Direction != policy.Inbound || string(p.inputs[0].Message) != string(msg) {
+				t.Fatalf("unexpected policy inputs: %+v", p.inputs)
+			}
+			if len(mock.written) != 1 {
+				t.Fatalf("expected one reply, got %+v", mock.written)
+			}
+			errMsg, isErr := mock.written[0].(ErrorMessage)
+			switch {
+			case tt.wantCode == "" && isErr:
+				t.Errorf("expected the message to be handled, got %+v", errMsg)
+			case tt.wantCode != "" && (!isErr || errMsg.Error.Code != tt.wantCode):
+				t.Errorf("expected %s, got %+v", tt.wantCode, mock.written[0])
+			}
+		})
+	}
+}
+
+func TestPolicyConn_FiltersAgentOutput(t *testing.T) {
+	p := &fakePolicy{decide: func(in policy.Input) (policy.Decision, error) {
+		var msg AgentDeltaMessage
+		_ = json.Unmarshal(in.Message, &msg)
+		switch msg.Content {
+		case "secret":
+			return policy.Decision{Action: policy.Deny}, nil
+		case "rude":
+			return policy.Decision{Action: policy.Replace, Message: json.RawMessage(`{"type":"agent:delta","content":"***"}`)}, nil
+		case "broken":
+			return policy.Decision{}, errors.New("trap")
+		}
+		return policy.Decision{Action: policy.Allow}, nil
+	}}
+	server := NewServer(&mockIDGenerator{id: "conn"}, &mockLogger{}, &mockClock{now: testTime}, nil, WithMessagePolicy(p, false))
+	mock := &mockWebSocketConn{}
+	conn := &policyConn{WebSocketConn: mock, server: server}
+
+	for _, content := range []string{"hello", "secret", "rude", "broken"} {
+		if err := conn.WriteJSON(NewAgentDeltaMessage("s", "c", 1, content)); err != nil {
+			t.Fatalf("WriteJSON failed: %v", err)
+		}
+	}
+	if err := conn.WriteJSON(NewErrorMessage("X", "not agent output", true)); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	if len(p.inputs) != 4 {
+		t.Errorf("expected only agent output evaluated, got %d inputs", len(p.inputs))
+	}
+	if len(mock.written) != 3 {
+		t.Fatalf("expected the allowed, replaced and error frames, got %+v", mock.written)
+	}
+	if delta, ok := mock.written[0].(AgentDeltaMessage); !ok || delta.Content != "hello" {
+		t.Errorf("expected the allowed delta, got %+v", mock.written[0])
+	}
+	replaced, ok := mock.written[1].(laneFrame)
+	if !ok || !isDataMessage(replaced) {
+		t.Fatalf("expected the replacement in the data lane, got %+v", mock.written[1])
+	}
+	var delta AgentDeltaMessage
+	if err := json.Unmarshal(replaced.RawMessage, &delta); err != nil || delta.Content != "***" {
+		t.Errorf("expected the replaced content, got %s", replaced.RawMessage)
+	}
+}
+
+func TestPolicyConn_RefusedCompleteStillEndsReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		decision policy.Decision
+		err      error
+		wantCode string
+	}{
+		{name: "deny", decision: policy.Decision{Action: policy.Deny, Reason: "leaks secrets"}, wantCode: "POLICY_DENIED"},
+		{name: "error", err: errors.New("timeout"), wantCode: "POLICY_ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakePolicy{decide: func(policy.Input) (policy.Decision, error) { return tt.decision, tt.err }}
+			server := NewServer(&mockIDGenerator{id: "conn"}, &mockLogger{}, &mockClock{now: testTime}, nil, WithMessagePolicy(p, false))
+			mock := &mockWebSocketConn{}
+			conn := &policyConn{WebSocketConn: mock, server: server}
+
+			parts := []acp.Part{{Type: "text", Text: "the secret"}}
+			if err := conn.WriteJSON(NewAgentCompleteMessage("s", "req-1", 2, parts, "2025-10-23T12:00:00Z", nil)); err != nil {
+				t.Fatalf("WriteJSON failed: %v", err)
+			}
+			if len(mock.written) != 1 {
+				t.Fatalf("expected agent:complete sent, got %+v", mock.written)
+			}
+			complete, ok := mock.written[0].(AgentCompleteMessage)
+			if !ok || complete.CorrelationID != "req-1" || complete.Seq != 2 || complete.Parts != nil {
+				t.Fatalf("expected the reply's agent:complete without parts, got %+v", mock.written[0])
+			}
+			if complete.Error == nil || complete.Error.Code != tt.wantCode {
+				t.Errorf("expected %s, got %+v", tt.wantCode, complete.Error)
+			}
+		})
+	}
+}
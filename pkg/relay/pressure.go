@@ -0,0 +1,138 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricResourcePressure counts the times the relay came under disk or FD pressure
+const MetricResourcePressure = "relay_resource_pressure_total"
+
+// ErrResourcePressure is returned for spawns refused while the relay is short
+// of disk space or file descriptors
+var ErrResourcePressure = errors.New("resource pressure")
+
+// PressureThresholds are the limits below which new spawns are refused
+// Zero disables a threshold.
+type PressureThresholds struct {
+	WorkspaceRoot string  // Filesystem whose free space is checked
+	MinFreeBytes  int64   // Free space required under WorkspaceRoot
+	MaxFDPercent  float64 // Share of the FD soft limit the relay may have open
+}
+
+// PressureMonitor checks free disk space and the relay's file descriptor use
+// on an interval; while either is over its threshold, Err refuses new spawns
+// Each pressure episode is logged and counted once, when it starts.
+type PressureMonitor struct {
+	thresholds PressureThresholds
+	diskFree   func(path string) (int64, error)
+	fdUsage    func() (open, limit int, err error)
+	logger     Logger
+	metrics    Metrics
+
+	mu     sync.RWMutex
+	reason string // Why the relay is under pressure; empty if it is not
+}
+
+// PressureOption configures a PressureMonitor
+type PressureOption func(*PressureMonitor)
+
+// WithPressureProbes replaces the disk and FD readers (DiskFree and FDUsage by default)
+func WithPressureProbes(diskFree func(path string) (int64, error), fdUsage func() (open, limit int, err error)) PressureOption {
+	return func(m *PressureMonitor) {
+		m.diskFree = diskFree
+		m.fdUsage = fdUsage
+	}
+}
+
+// WithPressureMetrics counts pressure episodes in metrics
+func WithPressureMetrics(metrics Metrics) PressureOption {
+	return func(m *PressureMonitor) {
+		m.metrics = metrics
+	}
+}
+
+// NewPressureMonitor creates a monitor for the thresholds
+func NewPressureMonitor(thresholds PressureThresholds, logger Logger, opts ...PressureOption) *PressureMonitor {
+	m := &PressureMonitor{
+		thresholds: thresholds,
+		diskFree:   DiskFree,
+		fdUsage:    FDUsage,
+		logger:     logger,
+		metrics:    &NoOpMetrics{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Run checks every interval until ctx is done
+func (m *PressureMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check()
+		}
+	}
+}
+
+// Check reads disk and FD usage and updates the pressure state
+// Readings that fail (or are unsupported on this platform) count as no pressure.
+// Returns the new state, as Err does.
+func (m *PressureMonitor) Check() error {
+	var reasons []string
+	if t := m.thresholds; t.WorkspaceRoot != "" && t.MinFreeBytes > 0 {
+		free, err := m.diskFree(t.WorkspaceRoot)
+		switch {
+		case errors.Is(err, errors.ErrUnsupported):
+		case err != nil:
+			m.logger.Printf("Failed to read free disk space: path=%s err=%v", t.WorkspaceRoot, err)
+		case free < t.MinFreeBytes:
+			reasons = append(reasons, fmt.Sprintf("disk: %d bytes free under %s, need %d", free, t.WorkspaceRoot, t.MinFreeBytes))
+		}
+	}
+	if t := m.thresholds; t.MaxFDPercent > 0 {
+		open, limit, err := m.fdUsage()
+		switch {
+		case errors.Is(err, errors.ErrUnsupported):
+		case err != nil:
+			m.logger.Printf("Failed to read file descriptor usage: %v", err)
+		case limit > 0 && float64(open)*100/float64(limit) > t.MaxFDPercent:
+			reasons = append(reasons, fmt.Sprintf("file descriptors: %d of %d open, limit %g%%", open, limit, t.MaxFDPercent))
+		}
+	}
+	reason := strings.Join(reasons, "; ")
+
+	m.mu.Lock()
+	was := m.reason
+	m.reason = reason
+	m.mu.Unlock()
+	switch {
+	case reason != "" && was == "":
+		m.metrics.IncCounter(MetricResourcePressure)
+		m.logger.Printf("Resource pressure, refusing new spawns: %s", reason)
+	case reason == "" && was != "":
+		m.logger.Printf("Resource pressure cleared, accepting spawns")
+	}
+	return m.Err()
+}
+
+// Err returns an ErrResourcePressure error naming what is short, or nil
+// It reports the last Check and does not read anything itself.
+func (m *PressureMonitor) Err() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.reason == "" {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrResourcePressure, m.reason)
+}
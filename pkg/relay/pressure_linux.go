@@ -0,0 +1,31 @@
+//go:build linux
+
+package relay
+
+import (
+	"os"
+	"syscall"
+)
+
+// DiskFree returns the bytes available to unprivileged users on the
+// filesystem holding path
+func DiskFree(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * st.Bsize, nil
+}
+
+// FDUsage returns how many file descriptors the relay has open and its soft limit
+func FDUsage() (open, limit int, err error) {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, 0, err
+	}
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(entries) - 1, int(rl.Cur), nil // Less the descriptor ReadDir held open
+}
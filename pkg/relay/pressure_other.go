@@ -0,0 +1,15 @@
+//go:build !linux
+
+package relay
+
+import "errors"
+
+// DiskFree is only implemented on Linux; elsewhere disk pressure is not checked
+func DiskFree(path string) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
+
+// FDUsage is only implemented on Linux; elsewhere FD pressure is not checked
+func FDUsage() (open, limit int, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,109 @@
+package relay
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeProbes stands in for DiskFree and FDUsage
+type fakeProbes struct {
+	free        int64
+	open, limit int
+	err         error
+}
+
+func (p *fakeProbes) diskFree(path string) (int64, error) { return p.free, p.err }
+
+func (p *fakeProbes) fdUsage() (int, int, error) { return p.open, p.limit, p.err }
+
+func newTestPressureMonitor(probes *fakeProbes, metrics Metrics) *PressureMonitor {
+	return NewPressureMonitor(PressureThresholds{WorkspaceRoot: "/work", MinFreeBytes: 1000, MaxFDPercent: 90},
+		&mockLogger{}, WithPressureProbes(probes.diskFree, probes.fdUsage), WithPressureMetrics(metrics))
+}
+
+func TestPressureMonitor_Check(t *testing.T) {
+	tests := []struct {
+		name   string
+		probes fakeProbes
+		want   string // Substring of the pressure error ("" = no pressure)
+	}{
+		{"healthy", fakeProbes{free: 5000, open: 10, limit: 100}, ""},
+		{"disk full", fakeProbes{free: 999, open: 10, limit: 100}, "disk: 999 bytes free under /work"},
+		{"fds exhausted", fakeProbes{free: 5000, open: 91, limit: 100}, "file descriptors: 91 of 100 open"},
+		{"unsupported", fakeProbes{err: errors.ErrUnsupported}, ""},
+		{"probe failure", fakeProbes{err: errors.New("permission denied")}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newTestPressureMonitor(&tt.probes, &NoOpMetrics{}).Check()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("expected no pressure, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrResourcePressure) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected ErrResourcePressure mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPressureMonitor_CountsEpisodes(t *testing.T) {
+	probes := &fakeProbes{free: 5000, open: 10, limit: 100}
+	metrics := NewCounterMetrics()
+	monitor := newTestPressureMonitor(probes, metrics)
+
+	for _, free := range []int64{5000, 10, 20, 5000, 10} {
+		probes.free = free
+		monitor.Check()
+	}
+	if n := metrics.Value(MetricResourcePressure); n != 2 {
+		t.Errorf("expected 2 pressure episodes, got %d", n)
+	}
+	if !errors.Is(monitor.Err(), ErrResourcePressure) {
+		t.Errorf("expected pressure after the last check, got %v", monitor.Err())
+	}
+}
+
+func TestServer_HandleMessage_AgentSpawnUnderPressure(t *testing.T) {
+	monitor := newTestPressureMonitor(&fakeProbes{free: 1, open: 10, limit: 100}, &NoOpMetrics{})
+	monitor.Check()
+	factory := &mockAgentFactory{}
+	spawner, manager := newTestSpawner(t, factory, WithSpawnPressure(monitor))
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, spawner: spawner}
+	conn := &mockWebSocketConn{}
+
+	raw := []byte(`{"version":"1.0","type":"agent:spawn","role":"auth","workspace":"` + t.TempDir() + `"}`)
+	server.handleMessage(conn, raw)
+
+	last := conn.written[len(conn.written)-1]
+	msg, ok := last.(ErrorMessage)
+	if !ok {
+		t.Fatalf("expected ErrorMessage, got %T: %+v", last, last)
+	}
+	if msg.Error.Code != "RESOURCE_PRESSURE" {
+		t.Errorf("expected RESOURCE_PRESSURE, got %+v", msg.Error)
+	}
+	if manager.Count() != 0 || len(factory.clients) != 0 {
+		t.Error("expected nothing to be created under pressure")
+	}
+}
+
+func TestFDUsage_Self(t *testing.T) {
+	open, limit, err := FDUsage()
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("FD usage is not supported on this platform")
+	}
+	if err != nil {
+		t.Fatalf("FDUsage failed: %v", err)
+	}
+	if open < 3 || limit < open {
+		t.Errorf("unexpected FD usage: %d of %d", open, limit)
+	}
+	if _, err := DiskFree(os.TempDir()); err != nil {
+		t.Errorf("DiskFree failed: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package relay
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc: 100 on every
+// architecture Go supports
+const clockTicks = 100
+
+// ReadProcessUsage reads a process's CPU time and resident memory from
+// /proc/<pid>/stat
+func ReadProcessUsage(pid int) (ProcessUsage, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return ProcessUsage{}, err
+	}
+	return parseProcStat(string(data), os.Getpagesize())
+}
+
+// parseProcStat reads utime, stime and rss from a /proc/<pid>/stat line (pure function)
+// The command name may contain spaces and parentheses, so fields are counted
+// from the last ')'.
+func parseProcStat(stat string, pageSize int) (ProcessUsage, error) {
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return ProcessUsage{}, fmt.Errorf("malformed stat: no command name")
+	}
+	fields := strings.Fields(stat[end+1:])
+	// fields[0] is field 3 (state): utime is 14, stime 15, rss 24
+	const utime, stime, rss = 14 - 3, 15 - 3, 24 - 3
+	if len(fields) <= rss {
+		return ProcessUsage{}, fmt.Errorf("malformed stat: %d fields", len(fields)+2)
+	}
+	var ticks uint64
+	for _, i := range []int{utime, stime} {
+		n, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return ProcessUsage{}, fmt.Errorf("malformed stat field %d: %w", i+3, err)
+		}
+		ticks += n
+	}
+	pages, err := strconv.ParseInt(fields[rss], 10, 64)
+	if err != nil {
+		return ProcessUsage{}, fmt.Errorf("malformed stat field %d: %w", rss+3, err)
+	}
+	return ProcessUsage{
+		CPUTime:  time.Duration(ticks) * time.Second / clockTicks,
+		RSSBytes: pages * int64(pageSize),
+	}, nil
+}
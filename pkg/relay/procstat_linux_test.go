@@ -0,0 +1,38 @@
+//go:build linux
+
+package relay
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	// Command names may hold spaces and parentheses
+	stat := "4242 (claude (acp) x) S 1 4242 4242 0 -1 4194560 1000 0 0 0 250 150 0 0 20 0 8 0 12345 1000000 300 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 3 0 0 0 0 0"
+	got, err := parseProcStat(stat, 4096)
+	if err != nil {
+		t.Fatalf("parseProcStat failed: %v", err)
+	}
+	if got.CPUTime != 4*time.Second {
+		t.Errorf("expected 4s of CPU, got %v", got.CPUTime)
+	}
+	if got.RSSBytes != 300*4096 {
+		t.Errorf("expected %d bytes resident, got %d", 300*4096, got.RSSBytes)
+	}
+
+	if _, err := parseProcStat("4242 (truncated) S 1 2", 4096); err == nil {
+		t.Error("expected an error for a truncated stat line")
+	}
+}
+
+func TestReadProcessUsage_Self(t *testing.T) {
+	got, err := ReadProcessUsage(os.Getpid())
+	if err != nil {
+		t.Fatalf("ReadProcessUsage failed: %v", err)
+	}
+	if got.RSSBytes <= 0 {
+		t.Errorf("expected resident memory, got %d", got.RSSBytes)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package relay
+
+import "errors"
+
+// ReadProcessUsage is only implemented on Linux; elsewhere agents are not sampled
+func ReadProcessUsage(pid int) (ProcessUsage, error) {
+	return ProcessUsage{}, errors.ErrUnsupported
+}
@@ -0,0 +1,55 @@
+package relay
+
+//go:generate go run ../../scripts/protospec -out ../../docs/protocol.schema.json
+
+// Message directions in the protocol description
+const (
+	FromClient = "client"
+	FromServer = "server"
+)
+
+// MessageSpec describes one message type of the WebSocket protocol
+type MessageSpec struct {
+	Type      string      // Value of the "type" field
+	Direction string      // FromClient or FromServer
+	Message   interface{} // Zero value of the Go struct carrying the message
+}
+
+// Protocol lists every message type the relay sends or handles
+// docs/protocol.schema.json is generated from it; run go generate ./pkg/relay
+// after adding a message or changing a message struct. Client types are
+// routed by clientHandlers; others are answered with UNKNOWN_TYPE.
+var Protocol = []MessageSpec{
+	{"echo", FromClient, EchoMessage{}},
+	{"agent:message", FromClient, AgentSendMessage{}},
+	{"agent:cancel", FromClient, AgentCancelMessage{}},
+	{"agent:tool_decision", FromClient, AgentToolDecisionMessage{}},
+	{"agent:spawn", FromClient, AgentSpawnMessage{}},
+	{"session:create_from_template", FromClient, SessionCreateFromTemplateMessage{}},
+	{"session:reattach", FromClient, SessionReattachMessage{}},
+	{"session:observe", FromClient, SessionObserveMessage{}},
+	{"session:share", FromClient, SessionShareMessage{}},
+	{"session:unshare", FromClient, SessionUnshareMessage{}},
+	{"session:transfer", FromClient, SessionTransferMessage{}},
+	{"git:open_pr", FromClient, GitOpenPRMessage{}},
+	{"connection:whoami", FromClient, ConnectionWhoamiMessage{}},
+
+	{"connection:established", FromServer, ConnectionEstablishedMessage{}},
+	{"connection:info", FromServer, ConnectionInfoMessage{}},
+	{"error", FromServer, ErrorMessage{}},
+	{"agent:state", FromServer, AgentStateMessage{}},
+	{"agent:ready", FromServer, AgentReadyMessage{}},
+	{"agent:delta", FromServer, AgentDeltaMessage{}},
+	{"agent:complete", FromServer, AgentCompleteMessage{}},
+	{"agent:cancelled", FromServer, AgentCancelledMessage{}},
+	{"agent:spawn_plan", FromServer, AgentSpawnPlanMessage{}},
+	{"agent:spawned", FromServer, AgentSpawnedMessage{}},
+	{"agent:resource_warning", FromServer, AgentResourceWarningMessage{}},
+	{"session:template_result", FromServer, SessionTemplateResultMessage{}},
+	{"session:reattached", FromServer, SessionReattachedMessage{}},
+	{"session:observing", FromServer, SessionObservingMessage{}},
+	{"session:access", FromServer, SessionAccessMessage{}},
+	{"session:expiring", FromServer, SessionExpiringMessage{}},
+	{"attachment:stored", FromServer, AttachmentStoredMessage{}},
+	{"git:pr_opened", FromServer, GitPROpenedMessage{}},
+}
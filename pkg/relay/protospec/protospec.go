@@ -0,0 +1,312 @@
+// Package protospec renders the relay WebSocket protocol as JSON Schema
+// Schemas are derived from the message structs listed in relay.Protocol by
+// reflection, with descriptions taken from their doc comments, so frontend
+// clients can generate or check their types against the Go definitions.
+package protospec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay"
+)
+
+// SchemaURL is the JSON Schema dialect of the generated description
+const SchemaURL = "https://json-schema.org/draft/2020-12/schema"
+
+// Docs maps "pkg.Type" and "pkg.Type.Field" to doc comment text
+type Docs map[string]string
+
+// ParseDocs collects type and field doc comments from Go source directories
+func ParseDocs(dirs ...string) (Docs, error) {
+	docs := make(Docs)
+	for _, dir := range dirs {
+		fset := token.NewFileSet()
+		pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+			return !strings.HasSuffix(fi.Name(), "_test.go")
+		}, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", dir, err)
+		}
+		for name, pkg := range pkgs {
+			for _, file := range pkg.Files {
+				collectDocs(docs, name, file)
+			}
+		}
+	}
+	return docs, nil
+}
+
+// collectDocs records the doc comments of one file's type declarations
+func collectDocs(docs Docs, pkg string, file *ast.File) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			key := pkg + "." + ts.Name.Name
+			doc := ts.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			setDoc(docs, key, doc)
+
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			for _, field := range st.Fields.List {
+				doc := field.Doc
+				if doc == nil {
+					doc = field.Comment
+				}
+				for _, name := range field.Names {
+					setDoc(docs, key+"."+name.Name, doc)
+				}
+			}
+		}
+	}
+}
+
+func setDoc(docs Docs, key string, doc *ast.CommentGroup) {
+	if text := strings.TrimSpace(doc.Text()); text != "" {
+		docs[key] = text
+	}
+}
+
+// schema is one JSON Schema object
+type schema map[string]interface{}
+
+// generator builds shared definitions while walking message types
+type generator struct {
+	docs Docs
+	defs map[string]schema
+	seen map[string]reflect.Type // Definition name → type, to catch collisions
+}
+
+// Generate renders specs as a JSON Schema document whose root accepts any
+// protocol message; each message and nested struct gets a $defs entry
+func Generate(specs []relay.MessageSpec, docs Docs) ([]byte, error) {
+	g := &generator{docs: docs, defs: make(map[string]schema), seen: make(map[string]reflect.Type)}
+
+	types := make(map[string]bool, len(specs))
+	oneOf := make([]schema, 0, len(specs))
+	for _, spec := range specs {
+		if types[spec.Type] {
+			return nil, fmt.Errorf("duplicate message type %q", spec.Type)
+		}
+		types[spec.Type] = true
+
+		t := reflect.TypeOf(spec.Message)
+		if t == nil || t.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("message %q must be a struct, got %T", spec.Type, spec.Message)
+		}
+		ref, err := g.define(t)
+		if err != nil {
+			return nil, err
+		}
+		def := g.defs[t.Name()]
+		props := def["properties"].(map[string]schema)
+		if _, ok := props["type"]; !ok {
+			return nil, fmt.Errorf("message %q does not embed relay.BaseMessage", spec.Type)
+		}
+		props["type"] = schema{"const": spec.Type}
+		props["version"] = schema{"const": relay.ProtocolVersion}
+		def["x-direction"] = spec.Direction
+		oneOf = append(oneOf, ref)
+	}
+
+	doc := schema{
+		"$schema":           SchemaURL,
+		"title":             "Ourocodus relay WebSocket protocol",
+		"description":       "Generated from the message structs in pkg/relay by go generate; do not edit.",
+		"x-protocolVersion": relay.ProtocolVersion,
+		"oneOf":             oneOf,
+		"$defs":             g.defs,
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// scalarTypes maps Go kinds to JSON Schema types
+var scalarTypes = map[reflect.Kind]string{
+	reflect.String: "string", reflect.Bool: "boolean",
+	reflect.Int: "integer", reflect.Int8: "integer", reflect.Int16: "integer",
+	reflect.Int32: "integer", reflect.Int64: "integer",
+	reflect.Uint: "integer", reflect.Uint8: "integer", reflect.Uint16: "integer",
+	reflect.Uint32: "integer", reflect.Uint64: "integer",
+	reflect.Float32: "number", reflect.Float64: "number",
+}
+
+// schemaFor returns the schema of a Go type, defining named structs once
+func (g *generator) schemaFor(t reflect.Type) (schema, error) {
+	switch t {
+	case rawMessageType:
+		return schema{}, nil // Arbitrary JSON
+	case timeType:
+		return schema{"type": "string", "format": "date-time"}, nil
+	}
+	if typ, ok := scalarTypes[t.Kind()]; ok {
+		return schema{"type": typ}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.Interface:
+		return schema{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return schema{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key of %s must be a string", t)
+		}
+		values, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return schema{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t, "")
+		}
+		return g.define(t)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// define adds a named struct to $defs (once) and returns a reference to it
+func (g *generator) define(t reflect.Type) (schema, error) {
+	name := t.Name()
+	ref := schema{"$ref": "#/$defs/" + name}
+	if prev, ok := g.seen[name]; ok {
+		if prev != t {
+			return nil, fmt.Errorf("types %s and %s share the definition name %s", prev, t, name)
+		}
+		return ref, nil
+	}
+	g.seen[name] = t
+
+	def, err := g.structSchema(t, docKey(t))
+	if err != nil {
+		return nil, err
+	}
+	g.defs[name] = def
+	return ref, nil
+}
+
+// structSchema describes a struct's JSON object; embedded structs without a
+// JSON name are flattened as encoding/json does
+func (g *generator) structSchema(t reflect.Type, key string) (schema, error) {
+	props := make(map[string]schema)
+	var required []string
+	if err := g.addFields(t, key, props, &required); err != nil {
+		return nil, err
+	}
+
+	s := schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	if doc := g.docs[key]; doc != "" {
+		s["description"] = doc
+	}
+	return s, nil
+}
+
+// addFields adds the JSON properties of t's fields
+// Fields are required unless tagged omitempty, as encoding/json always emits them
+func (g *generator) addFields(t reflect.Type, key string, props map[string]schema, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			if err := g.addFields(f.Type, docKey(f.Type), props, required); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s, err := g.schemaFor(f.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		omitEmpty := strings.Contains(opts, "omitempty")
+		if !omitEmpty {
+			*required = append(*required, name)
+			switch f.Type.Kind() {
+			case reflect.Pointer, reflect.Slice, reflect.Map:
+				s = nullable(s) // nil is encoded as null
+			}
+		}
+		if doc := g.docs[key+"."+f.Name]; doc != "" {
+			s = withDescription(s, doc)
+		}
+		props[name] = s
+	}
+	return nil
+}
+
+// nullable also accepts null; schemas that accept anything are returned as is
+func nullable(s schema) schema {
+	if len(s) == 0 {
+		return s
+	}
+	return schema{"anyOf": []schema{s, {"type": "null"}}}
+}
+
+// withDescription annotates a schema; references are wrapped since siblings
+// of $ref are not allowed by every consumer
+func withDescription(s schema, doc string) schema {
+	if _, ok := s["$ref"]; ok {
+		return schema{"allOf": []schema{s}, "description": doc}
+	}
+	s["description"] = doc
+	return s
+}
+
+// docKey is the Docs key of a named type, e.g. "relay.ErrorDetail"
+func docKey(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
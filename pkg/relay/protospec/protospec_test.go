@@ -0,0 +1,115 @@
+package protospec
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/relay"
+)
+
+func TestGenerate_MatchesCheckedInSchema(t *testing.T) {
+	docs, err := ParseDocs("..", "../../acp")
+	if err != nil {
+		t.Fatalf("ParseDocs failed: %v", err)
+	}
+	got, err := Generate(relay.Protocol, docs)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	want, err := os.ReadFile("../../../docs/protocol.schema.json")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("docs/protocol.schema.json is stale; run go generate ./pkg/relay")
+	}
+}
+
+type testNested struct {
+	Label string `json:"label"`
+}
+
+type testMessage struct {
+	relay.BaseMessage
+	Name     string            `json:"name"`
+	Note     string            `json:"note,omitempty"`
+	Nested   *testNested       `json:"nested,omitempty"`
+	Items    []testNested      `json:"items"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Raw      json.RawMessage   `json:"raw,omitempty"`
+	Internal string            `json:"-"`
+}
+
+func TestGenerate_FieldRules(t *testing.T) {
+	docs := Docs{"protospec.testMessage": "testMessage is a test", "protospec.testMessage.Name": "Who"}
+	data, err := Generate([]relay.MessageSpec{{Type: "test:message", Direction: relay.FromClient, Message: testMessage{}}}, docs)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	var doc struct {
+		Defs map[string]struct {
+			Description string                     `json:"description"`
+			Direction   string                     `json:"x-direction"`
+			Properties  map[string]json.RawMessage `json:"properties"`
+			Required    []string                   `json:"required"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	msg := doc.Defs["testMessage"]
+
+	if want := []string{"version", "type", "name", "items"}; !reflect.DeepEqual(msg.Required, want) {
+		t.Errorf("expected required %v, got %v", want, msg.Required)
+	}
+	if msg.Description != "testMessage is a test" || msg.Direction != relay.FromClient {
+		t.Errorf("unexpected annotations: %q %q", msg.Description, msg.Direction)
+	}
+	for prop, want := range map[string]string{
+		"type":   `{"const":"test:message"}`,
+		"name":   `{"description":"Who","type":"string"}`,
+		"nested": `{"$ref":"#/$defs/testNested"}`,
+		"items":  `{"anyOf":[{"items":{"$ref":"#/$defs/testNested"},"type":"array"},{"type":"null"}]}`,
+		"labels": `{"additionalProperties":{"type":"string"},"type":"object"}`,
+		"raw":    `{}`,
+	} {
+		var compact bytes.Buffer
+		_ = json.Compact(&compact, msg.Properties[prop])
+		if compact.String() != want {
+			t.Errorf("%s: expected %s, got %s", prop, want, compact.String())
+		}
+	}
+	if _, ok := msg.Properties["Internal"]; ok || len(msg.Properties) != 8 {
+		t.Errorf("expected json:\"-\" fields left out, got %d properties", len(msg.Properties))
+	}
+	if _, ok := doc.Defs["testNested"]; !ok {
+		t.Error("expected nested struct in $defs")
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		specs  []relay.MessageSpec
+		errMsg string
+	}{
+		{"duplicate type", []relay.MessageSpec{
+			{Type: "a", Message: relay.AgentSendMessage{}},
+			{Type: "a", Message: relay.AgentCancelMessage{}},
+		}, "duplicate message type"},
+		{"not a struct", []relay.MessageSpec{{Type: "a", Message: "x"}}, "must be a struct"},
+		{"no base message", []relay.MessageSpec{{Type: "a", Message: relay.ErrorDetail{}}}, "does not embed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Generate(tt.specs, nil); err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package relay
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies derives the real client address of requests that arrive
+// through load balancers or reverse proxies
+// X-Forwarded-For (or X-Real-IP when it is absent) is honored only when the
+// direct peer is one of the trusted ranges, so clients cannot spoof their
+// address by sending the headers themselves.
+type TrustedProxies struct {
+	prefixes []netip.Prefix
+}
+
+// NewTrustedProxies parses proxy ranges given as CIDRs or bare IPs
+func NewTrustedProxies(cidrs []string) (*TrustedProxies, error) {
+	prefixes, err := parsePrefixes(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return &TrustedProxies{prefixes: prefixes}, nil
+}
+
+// ClientIP returns the address of the client behind any trusted proxies
+// The X-Forwarded-For chain is walked from the nearest hop while hops are
+// trusted; the first untrusted hop is the client. An invalid address is
+// returned when none can be parsed.
+func (p *TrustedProxies) ClientIP(r *http.Request) netip.Addr {
+	addr := remoteIP(r)
+	if !addr.IsValid() || !matchesAny(p.prefixes, addr) {
+		return addr
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	if len(hops) == 0 {
+		if real := r.Header.Get("X-Real-IP"); real != "" {
+			hops = []string{real}
+		}
+	}
+	for i := len(hops) - 1; i >= 0 && matchesAny(p.prefixes, addr); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{} // A trusted proxy sent garbage; fail closed
+		}
+		addr = hop.Unmap()
+	}
+	return addr
+}
+
+// Middleware rewrites r.RemoteAddr to the client address, so logging, the
+// access log and IPFilter all see the client instead of the proxy
+// The proxy's own address is kept for the access log (see PeerAddr).
+func (p *TrustedProxies) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := p.ClientIP(r)
+		if client == remoteIP(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r2 := r.WithContext(context.WithValue(r.Context(), peerAddrKey{}, r.RemoteAddr))
+		r2.RemoteAddr = netip.AddrPortFrom(client, 0).String()
+		if !client.IsValid() {
+			r2.RemoteAddr = ""
+		}
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// peerAddrKey is the context key of the proxy address replaced by Middleware
+type peerAddrKey struct{}
+
+// PeerAddr returns the address of the proxy that relayed r, or "" if r came
+// directly from the client
+func PeerAddr(r *http.Request) string {
+	peer, _ := r.Context().Value(peerAddrKey{}).(string)
+	return peer
+}
+
+// remoteIP parses the host of r.RemoteAddr (an invalid address if it cannot)
+func remoteIP(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
@@ -0,0 +1,71 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	proxies, err := NewTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("NewTrustedProxies failed: %v", err)
+	}
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "", "203.0.113.7"},
+		{"untrusted peer ignores header", "203.0.113.7:5000", []string{"10.1.1.1"}, "10.1.1.2", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.5:5000", []string{"198.51.100.9"}, "", "198.51.100.9"},
+		{"proxy chain", "10.0.0.5:5000", []string{"198.51.100.9, 192.0.2.1", "10.0.0.6"}, "", "198.51.100.9"},
+		{"spoofed left hop", "10.0.0.5:5000", []string{"10.9.9.9, 198.51.100.9"}, "", "198.51.100.9"},
+		{"all hops trusted", "10.0.0.5:5000", []string{"10.0.0.7"}, "", "10.0.0.7"},
+		{"garbage from proxy", "10.0.0.5:5000", []string{"not-an-ip"}, "", "invalid IP"},
+		{"x-real-ip", "10.0.0.5:5000", nil, "198.51.100.9", "198.51.100.9"},
+		{"forwarded-for wins", "10.0.0.5:5000", []string{"198.51.100.9"}, "198.51.100.10", "198.51.100.9"},
+		{"ipv4-mapped", "[::ffff:203.0.113.7]:5000", nil, "", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := proxies.ClientIP(r).String(); got != tt.want {
+				t.Errorf("ClientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedProxies_Middleware(t *testing.T) {
+	proxies, _ := NewTrustedProxies([]string{"10.0.0.0/8"})
+	var gotRemote, gotPeer string
+	handler := proxies.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRemote, gotPeer = r.RemoteAddr, PeerAddr(r)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.RemoteAddr = "10.0.0.5:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.9")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if gotRemote != "198.51.100.9:0" || gotPeer != "10.0.0.5:5000" {
+		t.Errorf("expected client with proxy as peer, got remote=%s peer=%s", gotRemote, gotPeer)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.RemoteAddr = "203.0.113.7:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.9")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if gotRemote != "203.0.113.7:5000" || gotPeer != "" {
+		t.Errorf("expected direct request untouched, got remote=%s peer=%s", gotRemote, gotPeer)
+	}
+}
@@ -0,0 +1,101 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/2389-research/ourocodus/pkg/integrations/github"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// ErrNoWorktree is returned when a session has no worktree to open a pull request from
+var ErrNoWorktree = errors.New("session has no worktree")
+
+// PullRequestService opens pull requests for session worktrees, describing
+// them from the session's conversation history
+type PullRequestService struct {
+	manager *session.Manager
+	opener  *github.Opener
+	logger  Logger
+	cfg     GitHubConfig
+}
+
+// NewPullRequestService creates a service that opens pull requests with opener
+func NewPullRequestService(manager *session.Manager, opener *github.Opener, cfg GitHubConfig, logger Logger) *PullRequestService {
+	return &PullRequestService{manager: manager, opener: opener, logger: logger, cfg: cfg}
+}
+
+// Open pushes the session's worktree branch and opens a pull request
+// Returns the pushed branch with the pull request
+func (p *PullRequestService) Open(ctx context.Context, msg GitOpenPRMessage) (*github.PullRequest, string, error) {
+	sess := p.manager.Get(msg.SessionID)
+	if sess == nil {
+		return nil, "", fmt.Errorf("%w: %s", session.ErrSessionNotFound, msg.SessionID)
+	}
+	dir := sess.GetWorktreeDir()
+	if dir == "" {
+		return nil, "", fmt.Errorf("%w: %s", ErrNoWorktree, msg.SessionID)
+	}
+
+	turns := p.transcript(msg.SessionID)
+	title := msg.Title
+	if title == "" {
+		title = github.Title(turns, fmt.Sprintf("Changes from %s agent", sess.GetAgentID()))
+	}
+	base := msg.Base
+	if base == "" {
+		base = p.cfg.Base
+	}
+
+	pr, branch, err := p.opener.Open(ctx, github.OpenRequest{
+		Dir:   dir,
+		Owner: p.cfg.Owner,
+		Repo:  p.cfg.Repo,
+		Title: title,
+		Base:  base,
+		Body:  github.Describe(turns),
+		Draft: msg.Draft,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	p.logger.Printf("Opened pull request #%d for session %s: %s", pr.Number, msg.SessionID, pr.URL)
+	return pr, branch, nil
+}
+
+// transcript returns the session's recorded conversation, oldest first
+// Without history the pull request is described without a transcript
+func (p *PullRequestService) transcript(sessionID string) []github.Turn {
+	matches, err := p.manager.SearchHistory(session.HistoryQuery{SessionID: sessionID})
+	if err != nil {
+		return nil
+	}
+	turns := make([]github.Turn, 0, len(matches))
+	for i := len(matches) - 1; i >= 0; i-- { // Search returns newest first
+		entry := matches[i].Entry
+		turns = append(turns, github.Turn{Speaker: string(entry.Speaker), Content: entry.Content})
+	}
+	return turns
+}
+
+// handleOpenPR answers git:open_pr with git:pr_opened or a PR_FAILED error
+func (s *Server) handleOpenPR(conn WebSocketConn, rawMessage []byte) {
+	msg, err := ParseGitOpenPR(rawMessage)
+	if err != nil {
+		s.handleValidationError(conn, err)
+		return
+	}
+
+	var reply interface{}
+	if pr, branch, err := s.pullRequests.Open(context.Background(), msg); err != nil {
+		s.logger.Printf("Open PR failed: session=%s err=%v", msg.SessionID, err)
+		reply = NewErrorMessage("PR_FAILED", err.Error(), true)
+	} else {
+		reply = NewGitPROpenedMessage(msg.SessionID, branch, pr, FormatTimestamp(s.clock.Now()))
+	}
+
+	if err := conn.WriteJSON(reply); err != nil {
+		s.logger.Printf("Failed to send pull request response: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/integrations/github"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// mockGitRunner reports a feature branch and succeeds at every other command
+type mockGitRunner struct {
+	dirs []string
+}
+
+func (m *mockGitRunner) Git(ctx context.Context, dir string, args ...string) (string, error) {
+	m.dirs = append(m.dirs, dir)
+	if args[0] == "rev-parse" {
+		return "agent/auth", nil
+	}
+	return "", nil
+}
+
+// newTestPullRequests wires a service to a fake GitHub that records the request body
+func newTestPullRequests(t *testing.T, manager *session.Manager, got *github.NewPullRequest) (*PullRequestService, *mockGitRunner) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(got)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"number": 12, "html_url": "https://github.com/acme/widgets/pull/12"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	git := &mockGitRunner{}
+	opener := github.NewOpener(github.NewClient("tok", github.WithBaseURL(srv.URL)), git, "")
+	cfg := GitHubConfig{Owner: "acme", Repo: "widgets", Base: "main"}
+	return NewPullRequestService(manager, opener, cfg, &mockLogger{}), git
+}
+
+func TestServer_OpenPR(t *testing.T) {
+	ctx := context.Background()
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"},
+		session.WithHistory(session.NewMemoryHistory(100)))
+	_, _ = manager.Create(ctx, "auth", &mockWebSocketConn{})
+	_ = manager.BeginSpawn(ctx, "session-1")
+	_ = manager.AttachAgent(ctx, "session-1", "/tmp/worktree", &mockStreamingACPClient{})
+	if _, err := manager.SendMessage(ctx, "session-1", "Add JWT login"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	var got github.NewPullRequest
+	service, git := newTestPullRequests(t, manager, &got)
+	conn := &mockWebSocketConn{}
+	server := NewServer(&mockIDGenerator{}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{},
+		WithPullRequests(service))
+
+	server.handleMessage(conn, []byte(`{"version":"1.0","type":"git:open_pr","sessionId":"session-1"}`))
+
+	msg, ok := conn.written[0].(GitPROpenedMessage)
+	if !ok {
+		t.Fatalf("expected GitPROpenedMessage, got %+v", conn.written[0])
+	}
+	if msg.Number != 12 || msg.Branch != "agent/auth" || msg.SessionID != "session-1" {
+		t.Errorf("unexpected confirmation: %+v", msg)
+	}
+	if git.dirs[0] != "/tmp/worktree" {
+		t.Errorf("expected git to run in the session worktree, got %s", git.dirs[0])
+	}
+	if got.Title != "Add JWT login" || got.Base != "main" || !strings.Contains(got.Body, "Echo: Add JWT login") {
+		t.Errorf("expected title and body from the transcript, got %+v", got)
+	}
+}
+
+func TestServer_OpenPR_Errors(t *testing.T) {
+	ctx := context.Background()
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"})
+	_, _ = manager.Create(ctx, "auth", &mockWebSocketConn{}) // No worktree yet
+
+	service, _ := newTestPullRequests(t, manager, &github.NewPullRequest{})
+	server := NewServer(&mockIDGenerator{}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{},
+		WithPullRequests(service))
+
+	tests := []struct {
+		name string
+		msg  string
+		code string
+	}{
+		{"missing session ID", `{"version":"1.0","type":"git:open_pr"}`, "INVALID_MESSAGE"},
+		{"unknown session", `{"version":"1.0","type":"git:open_pr","sessionId":"nope"}`, "PR_FAILED"},
+		{"no worktree", `{"version":"1.0","type":"git:open_pr","sessionId":"session-1"}`, "PR_FAILED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &mockWebSocketConn{}
+			server.handleMessage(conn, []byte(tt.msg))
+			msg, ok := conn.written[0].(ErrorMessage)
+			if !ok || msg.Error.Code != tt.code {
+				t.Errorf("expected %s error, got %+v", tt.code, conn.written[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package relay
+
+import (
+	"errors"
+	"os"
+)
+
+// reflink is only implemented on Linux; callers fall back to copying
+func reflink(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,86 @@
+// Package relaytest provides fakes of the relay package's dependencies, for
+// programs embedding the relay to use in their own tests
+//
+// The connection, clock, logger and ID fakes are those of sessiontest, which
+// satisfy the relay interfaces as well. The relay package's own tests keep
+// private mocks, since they cannot import this package.
+package relaytest
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay"
+	"github.com/2389-research/ourocodus/pkg/relay/session/sessiontest"
+)
+
+// Fakes shared with sessiontest
+type (
+	Conn        = sessiontest.Conn
+	Clock       = sessiontest.Clock
+	Logger      = sessiontest.Logger
+	IDGenerator = sessiontest.IDGenerator
+	Agent       = sessiontest.Agent
+)
+
+// Compile-time checks that the fakes satisfy the relay interfaces
+var (
+	_ relay.WebSocketConn = (*Conn)(nil)
+	_ relay.Clock         = (*Clock)(nil)
+	_ relay.Logger        = (*Logger)(nil)
+	_ relay.IDGenerator   = (*IDGenerator)(nil)
+	_ relay.Upgrader      = (*Upgrader)(nil)
+)
+
+// NewConn creates a connection with client messages already queued
+func NewConn(messages ...[]byte) *Conn {
+	return sessiontest.NewConn(messages...)
+}
+
+// NewClock creates a clock reading now
+func NewClock(now time.Time) *Clock {
+	return sessiontest.NewClock(now)
+}
+
+// errNoConn is returned once an Upgrader has handed out all its connections
+var errNoConn = errors.New("relaytest: no connection left to upgrade to")
+
+// Upgrader hands out prepared connections instead of upgrading requests
+// Each Upgrade returns the next of Conns; once they run out it returns Err,
+// or an error if Err is nil.
+type Upgrader struct {
+	Conns []relay.WebSocketConn
+	Err   error
+
+	mu       sync.Mutex
+	upgrades int
+}
+
+// NewUpgrader creates an upgrader handing out conns in order
+func NewUpgrader(conns ...relay.WebSocketConn) *Upgrader {
+	return &Upgrader{Conns: conns}
+}
+
+// Upgrade returns the next connection
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (relay.WebSocketConn, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.upgrades >= len(u.Conns) {
+		if u.Err != nil {
+			return nil, u.Err
+		}
+		return nil, errNoConn
+	}
+	conn := u.Conns[u.upgrades]
+	u.upgrades++
+	return conn, nil
+}
+
+// Upgrades returns how many connections were handed out
+func (u *Upgrader) Upgrades() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.upgrades
+}
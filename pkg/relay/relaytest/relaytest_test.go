@@ -0,0 +1,67 @@
+package relaytest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay"
+	"github.com/2389-research/ourocodus/pkg/relay/relaytest"
+)
+
+func TestServer_HandshakeWithFakes(t *testing.T) {
+	conn := relaytest.NewConn()
+	upgrader := relaytest.NewUpgrader(conn)
+	logger := &relaytest.Logger{}
+	server := relay.NewServer(&relaytest.IDGenerator{Prefix: "conn"}, logger,
+		relaytest.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)), upgrader)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.HandleWebSocket(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws", nil))
+	}()
+
+	written, err := conn.WaitWritten(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var handshake struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &handshake); err != nil {
+		t.Fatal(err)
+	}
+	if handshake.Type != "connection:established" {
+		t.Errorf("expected connection:established first, got %s", data)
+	}
+
+	_ = conn.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return after the connection closed")
+	}
+	if n := upgrader.Upgrades(); n != 1 {
+		t.Errorf("expected 1 upgrade, got %d", n)
+	}
+	if !logger.Contains("WebSocket connection established") {
+		t.Errorf("expected connection to be logged, got %v", logger.Lines())
+	}
+}
+
+func TestUpgrader_RunsOut(t *testing.T) {
+	upgrader := relaytest.NewUpgrader(relaytest.NewConn())
+	if _, err := upgrader.Upgrade(nil, nil, nil); err != nil {
+		t.Fatalf("first Upgrade failed: %v", err)
+	}
+	if _, err := upgrader.Upgrade(nil, nil, nil); err == nil {
+		t.Error("expected an error once connections ran out")
+	}
+}
@@ -0,0 +1,204 @@
+package relaytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+	"github.com/2389-research/ourocodus/pkg/relay/session/sessiontest"
+)
+
+// ReplayStep is the outcome of replaying one journal entry
+type ReplayStep struct {
+	Entry    relay.JournalEntry
+	Diverged string // How the replay differed from the journal; empty if it matched
+}
+
+// ReplayReport lists every step of a replay
+type ReplayReport struct {
+	Steps       []ReplayStep
+	Divergences int
+}
+
+// Replay re-drives a session.Manager built on fakes with a journal's
+// lifecycle events and agent exchanges, in order, and reports every point
+// where the Manager now behaves differently than when the journal was written
+// Each lifecycle event is matched against the next transition the Manager
+// publishes; if there is none, the Manager call that causes the event is
+// made first. The fake agent answers each prompt with the journaled reply or
+// error, and the clock follows the journal's timestamps. Frames are not
+// re-sent and always match: they are reported for context.
+func Replay(entries []relay.JournalEntry) ReplayReport {
+	r := &replayer{
+		ctx:   context.Background(),
+		clock: NewClock(time.Time{}),
+		ids:   &replayIDs{},
+	}
+	r.manager = session.NewManager(session.NewMemoryStore(), r.ids, r.clock, &sessiontest.Cleaner{}, &Logger{})
+	r.manager.Events().Subscribe(func(e session.LifecycleEvent) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.published = append(r.published, e)
+	})
+
+	var report ReplayReport
+	for i, e := range entries {
+		r.tick(e.Time, i == 0)
+		step := ReplayStep{Entry: e}
+		switch e.Kind {
+		case relay.JournalLifecycle:
+			step.Diverged = r.lifecycle(e)
+		case relay.JournalAgent:
+			step.Diverged = r.prompt(e)
+		case relay.JournalFrame:
+		default:
+			step.Diverged = fmt.Sprintf("unknown entry kind %q", e.Kind)
+		}
+		report.add(step)
+	}
+	for _, e := range r.takeAll() {
+		report.add(ReplayStep{
+			Entry:    relay.JournalEntry{Kind: relay.JournalLifecycle, SessionID: e.SessionID, From: string(e.From), To: string(e.To), Event: e.Event.String()},
+			Diverged: "transition not in the journal",
+		})
+	}
+	return report
+}
+
+func (r *ReplayReport) add(step ReplayStep) {
+	r.Steps = append(r.Steps, step)
+	if step.Diverged != "" {
+		r.Divergences++
+	}
+}
+
+// replayer holds the Manager being re-driven and its fakes
+type replayer struct {
+	ctx     context.Context
+	manager *session.Manager
+	clock   *Clock
+	ids     *replayIDs
+
+	answer relay.JournalEntry // The agent entry being replayed
+
+	mu        sync.Mutex
+	published []session.LifecycleEvent // Transitions not yet matched
+}
+
+// replayIDs hands the Manager the journaled ID of the session being created
+type replayIDs struct {
+	next string
+}
+
+func (g *replayIDs) Generate() string { return g.next }
+
+// tick moves the clock to a journal timestamp; it never goes backwards
+func (r *replayer) tick(timestamp string, first bool) {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return
+	}
+	if first {
+		r.clock.Set(t)
+	} else if d := t.Sub(r.clock.Now()); d > 0 {
+		r.clock.Advance(d)
+	}
+}
+
+// lifecycle matches a journaled transition, causing it if it has not happened
+func (r *replayer) lifecycle(e relay.JournalEntry) string {
+	got, ok := r.take()
+	if !ok {
+		if err := r.cause(e); err != nil {
+			return fmt.Sprintf("%s failed: %v", e.Event, err)
+		}
+		if got, ok = r.take(); !ok {
+			return fmt.Sprintf("%s caused no transition", e.Event)
+		}
+	}
+	if got.SessionID != e.SessionID || string(got.From) != e.From || string(got.To) != e.To || got.Event.String() != e.Event {
+		return fmt.Sprintf("got %s %s -> %s (%s)", got.SessionID, got.From, got.To, got.Event)
+	}
+	return ""
+}
+
+// cause makes the Manager call that produces a lifecycle event
+func (r *replayer) cause(e relay.JournalEntry) error {
+	id := e.SessionID
+	switch session.Event(e.Event) {
+	case session.EventCreate:
+		r.ids.next = id
+		var opts []session.CreateOption
+		if e.Name != "" {
+			opts = append(opts, session.WithName(e.Name))
+		}
+		_, err := r.manager.Create(r.ctx, e.AgentID, NewConn(), opts...)
+		return err
+	case session.EventSpawn:
+		return r.manager.BeginSpawn(r.ctx, id)
+	case session.EventActivate:
+		return r.manager.AttachAgent(r.ctx, id, "/replay/"+id, &Agent{Reply: r.reply})
+	case session.EventPause:
+		return r.manager.Pause(r.ctx, id, e.Reason)
+	case session.EventResume:
+		return r.manager.Resume(r.ctx, id)
+	case session.EventTerminate:
+		if e.Error != "" {
+			return r.manager.MarkFailed(r.ctx, id, errors.New(e.Error))
+		}
+		return r.manager.MarkTerminating(r.ctx, id, e.Reason)
+	case session.EventClean:
+		return r.manager.CompleteCleanup(r.ctx, id)
+	}
+	return fmt.Errorf("unknown event")
+}
+
+// prompt replays a prompt; the fake agent answers as the journal says it did
+func (r *replayer) prompt(e relay.JournalEntry) string {
+	r.answer = e
+	_, err := r.manager.SendMessage(r.ctx, e.SessionID, e.Prompt)
+	switch {
+	case err != nil && e.Error == "":
+		return fmt.Sprintf("prompt failed: %v", err)
+	case err == nil && e.Error != "":
+		return fmt.Sprintf("prompt succeeded, journal has error: %s", e.Error)
+	}
+	return ""
+}
+
+// reply answers the fake agent's prompts with the journaled outcome
+func (r *replayer) reply(content string) (*acp.AgentMessage, error) {
+	if r.answer.Error != "" {
+		return nil, errors.New(r.answer.Error)
+	}
+	if r.answer.Reply == nil {
+		return &acp.AgentMessage{Type: "text"}, nil
+	}
+	return r.answer.Reply, nil
+}
+
+// take pops the oldest unmatched transition
+func (r *replayer) take() (session.LifecycleEvent, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.published) == 0 {
+		return session.LifecycleEvent{}, false
+	}
+	e := r.published[0]
+	r.published = r.published[1:]
+	return e, true
+}
+
+// takeAll pops every unmatched transition
+func (r *replayer) takeAll() []session.LifecycleEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rest := r.published
+	r.published = nil
+	return rest
+}
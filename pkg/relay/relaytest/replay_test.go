@@ -0,0 +1,113 @@
+package relaytest
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+	"github.com/2389-research/ourocodus/pkg/relay/session/sessiontest"
+)
+
+// recordJournal runs a session through prompts and a failure with a journal
+// attached and returns the journal's entries
+func recordJournal(t *testing.T) []relay.JournalEntry {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+	clock := NewClock(time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC))
+	journal, err := relay.NewJournal(dir, clock, &Logger{})
+	if err != nil {
+		t.Fatalf("NewJournal failed: %v", err)
+	}
+	manager := session.NewManager(session.NewMemoryStore(), &IDGenerator{Prefix: "sess"}, clock,
+		&sessiontest.Cleaner{}, &Logger{}, session.WithMiddleware(journal.Middleware()))
+	manager.Events().Subscribe(journal.HandleLifecycle)
+
+	sess, err := manager.Create(ctx, "auth", NewConn(), session.WithName("login"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	id := sess.GetID()
+	agent := &Agent{Reply: func(content string) (*acp.AgentMessage, error) {
+		if content == "crash" {
+			return nil, errors.New("agent exited")
+		}
+		return &acp.AgentMessage{Type: "text", Content: "ok"}, nil
+	}}
+	clock.Advance(time.Second)
+	_ = manager.BeginSpawn(ctx, id)
+	_ = manager.AttachAgent(ctx, id, t.TempDir(), agent)
+	for _, prompt := range []string{"hello", "crash"} {
+		clock.Advance(time.Second)
+		_, _ = manager.SendMessage(ctx, id, prompt)
+	}
+	_ = manager.MarkFailed(ctx, id, errors.New("agent exited"))
+	_ = manager.CompleteCleanup(ctx, id)
+	if err := journal.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	entries, err := relay.ReadJournal(filepath.Join(dir, id+".jsonl"))
+	if err != nil {
+		t.Fatalf("ReadJournal failed: %v", err)
+	}
+	return entries
+}
+
+func TestReplay_MatchesRecording(t *testing.T) {
+	entries := recordJournal(t)
+	if len(entries) != 7 {
+		t.Fatalf("expected 5 transitions and 2 prompts, got %d entries", len(entries))
+	}
+
+	report := Replay(entries)
+	if report.Divergences != 0 {
+		for _, step := range report.Steps {
+			if step.Diverged != "" {
+				t.Errorf("seq %d: %s", step.Entry.Seq, step.Diverged)
+			}
+		}
+	}
+	if len(report.Steps) != len(entries) {
+		t.Errorf("expected a step per entry, got %d", len(report.Steps))
+	}
+}
+
+func TestReplay_ReportsDivergence(t *testing.T) {
+	recorded := recordJournal(t)
+	// Without the agent attached, the Manager refuses the prompt and
+	// terminates the session from SPAWNING rather than ACTIVE
+	var entries []relay.JournalEntry
+	for _, e := range recorded {
+		if e.Event != string(session.EventActivate) {
+			entries = append(entries, e)
+		}
+	}
+	// And a transition the Manager cannot make
+	entries = append(entries, relay.JournalEntry{
+		Seq: 99, Kind: relay.JournalLifecycle, SessionID: entries[0].SessionID,
+		From: "CLEANED", To: "ACTIVE", Event: "RESUME",
+	})
+
+	report := Replay(entries)
+	var diverged []string
+	for _, step := range report.Steps {
+		if step.Diverged != "" {
+			diverged = append(diverged, step.Diverged)
+		}
+	}
+	if report.Divergences != 3 || len(diverged) != 3 {
+		t.Fatalf("expected 3 divergences, got %q", diverged)
+	}
+	for i, want := range []string{"prompt failed", "SPAWNING -> TERMINATING", "RESUME failed"} {
+		if !strings.Contains(diverged[i], want) {
+			t.Errorf("divergence %d: expected %q, got %q", i, want, diverged[i])
+		}
+	}
+}
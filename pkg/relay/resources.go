@@ -0,0 +1,206 @@
+package relay
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// MetricResourceWarnings counts agent:resource_warning events sent
+const MetricResourceWarnings = "relay_agent_resource_warnings_total"
+
+// Resources named by agent:resource_warning
+const (
+	ResourceCPU    = "cpu"
+	ResourceMemory = "memory"
+)
+
+// ProcessUsage is a reading of a process's cumulative CPU time and resident memory
+type ProcessUsage struct {
+	CPUTime  time.Duration
+	RSSBytes int64
+}
+
+// ProcessReader reads a process's usage by PID (ReadProcessUsage in production)
+type ProcessReader func(pid int) (ProcessUsage, error)
+
+// ResourceThresholds are the limits above which an agent is warned about
+// Zero disables a threshold.
+type ResourceThresholds struct {
+	CPUPercent float64 // Share of one core; 200 is two cores
+	RSSBytes   int64
+}
+
+// ResourceMonitor samples the CPU and memory of every agent process on an
+// interval, attaches the readings to the session, and sends
+// agent:resource_warning when a threshold is crossed
+// Warnings are edge-triggered: one per crossing, re-armed once the reading
+// drops back under the threshold.
+type ResourceMonitor struct {
+	manager    *session.Manager
+	read       ProcessReader
+	clock      Clock
+	logger     Logger
+	metrics    Metrics
+	thresholds ResourceThresholds
+
+	mu    sync.Mutex
+	procs map[string]*processSample // By session ID, pruned as sessions go
+}
+
+// processSample is the previous reading of one session's agent
+type processSample struct {
+	pid     int
+	cpuTime time.Duration
+	mono    time.Duration
+	over    map[string]bool // Resources currently over their threshold
+}
+
+// ResourceMonitorOption configures a ResourceMonitor
+type ResourceMonitorOption func(*ResourceMonitor)
+
+// WithResourceThresholds sets the limits that trigger warnings
+func WithResourceThresholds(thresholds ResourceThresholds) ResourceMonitorOption {
+	return func(m *ResourceMonitor) {
+		m.thresholds = thresholds
+	}
+}
+
+// WithResourceMetrics counts warnings in metrics
+func WithResourceMetrics(metrics Metrics) ResourceMonitorOption {
+	return func(m *ResourceMonitor) {
+		m.metrics = metrics
+	}
+}
+
+// NewResourceMonitor creates a monitor for the manager's agent processes
+func NewResourceMonitor(manager *session.Manager, read ProcessReader, clock Clock, logger Logger, opts ...ResourceMonitorOption) *ResourceMonitor {
+	m := &ResourceMonitor{
+		manager: manager,
+		read:    read,
+		clock:   clock,
+		logger:  logger,
+		metrics: &NoOpMetrics{},
+		procs:   make(map[string]*processSample),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Run samples every interval until ctx is done
+func (m *ResourceMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Sample()
+		}
+	}
+}
+
+// Sample reads every active agent process once
+// CPU percentages need two readings of the same process, so an agent's first
+// sample only reports memory. Returns how many agents were sampled.
+func (m *ResourceMonitor) Sample() int {
+	now, nowMono := m.clock.Now(), m.clock.Monotonic()
+	live := make(map[string]bool)
+	sampled := 0
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, sess := range m.manager.List(nil) {
+		if sess.GetState() != session.StateActive {
+			continue
+		}
+		handle := sess.GetHandle()
+		if handle == nil {
+			continue
+		}
+		p, ok := handle.ACPClient.(processIdentifier)
+		if !ok || p.PID() == 0 {
+			continue
+		}
+		pid := p.PID()
+		usage, err := m.read(pid)
+		if err != nil {
+			m.logger.Printf("Failed to sample agent process: session=%s pid=%d err=%v", sess.GetID(), pid, err)
+			continue
+		}
+		id := sess.GetID()
+		live[id] = true
+
+		prev := m.procs[id]
+		if prev == nil || prev.pid != pid {
+			prev = &processSample{pid: pid, over: make(map[string]bool)} // New or replaced agent
+			m.procs[id] = prev
+		}
+		reading := session.ResourceUsage{SampledAt: now, RSSBytes: usage.RSSBytes}
+		if prev.mono > 0 && nowMono > prev.mono {
+			reading.CPUPercent = cpuPercent(usage.CPUTime-prev.cpuTime, nowMono-prev.mono)
+		}
+		prev.cpuTime, prev.mono = usage.CPUTime, nowMono
+
+		if err := m.manager.RecordResourceUsage(id, reading); err != nil {
+			continue // Terminated since List
+		}
+		sampled++
+		m.check(sess, prev, reading)
+	}
+
+	for id := range m.procs {
+		if !live[id] {
+			delete(m.procs, id)
+		}
+	}
+	return sampled
+}
+
+// check warns about every resource that crossed its threshold since the last sample
+func (m *ResourceMonitor) check(sess *session.Session, prev *processSample, reading session.ResourceUsage) {
+	limits := []struct {
+		resource string
+		over     bool
+		limit    float64
+	}{
+		{ResourceCPU, m.thresholds.CPUPercent > 0 && reading.CPUPercent > m.thresholds.CPUPercent, m.thresholds.CPUPercent},
+		{ResourceMemory, m.thresholds.RSSBytes > 0 && reading.RSSBytes > m.thresholds.RSSBytes, float64(m.thresholds.RSSBytes)},
+	}
+	for _, l := range limits {
+		was := prev.over[l.resource]
+		prev.over[l.resource] = l.over
+		if !l.over || was {
+			continue
+		}
+		m.metrics.IncCounter(MetricResourceWarnings)
+		m.logger.Printf("Agent over %s threshold: session=%s cpu=%.1f%% rss=%d limit=%g",
+			l.resource, sess.GetID(), reading.CPUPercent, reading.RSSBytes, l.limit)
+		msg := NewAgentResourceWarningMessage(
+			sess.GetID(),
+			sess.GetAgentID(),
+			sess.GetName(),
+			l.resource,
+			reading.CPUPercent,
+			reading.RSSBytes,
+			l.limit,
+			FormatTimestamp(reading.SampledAt),
+		)
+		if err := broadcast(sess, msg); err != nil {
+			m.logger.Printf("Failed to send resource warning: session=%s err=%v", sess.GetID(), err)
+		}
+	}
+}
+
+// cpuPercent is the share of one core used over elapsed (pure function)
+func cpuPercent(cpu, elapsed time.Duration) float64 {
+	if elapsed <= 0 || cpu < 0 {
+		return 0
+	}
+	return float64(cpu) / float64(elapsed) * 100
+}
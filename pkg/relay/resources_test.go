@@ -0,0 +1,149 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResourceMonitor_SampleAndWarn(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: testTime, mono: time.Second}
+	manager := NewSessionManager(&mockLogger{}, clock, &mockIDGenerator{id: "session-1"})
+	conn := &mockWebSocketConn{}
+	if _, err := manager.Create(ctx, "auth", conn); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	_ = manager.BeginSpawn(ctx, "session-1")
+	if err := manager.AttachAgent(ctx, "session-1", "/work/auth", &mockProcessACPClient{pid: 4242}); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+	conn.written = nil // Lifecycle messages
+
+	usage := ProcessUsage{CPUTime: time.Second, RSSBytes: 100 << 20}
+	read := func(pid int) (ProcessUsage, error) {
+		if pid != 4242 {
+			t.Errorf("expected pid 4242, got %d", pid)
+		}
+		return usage, nil
+	}
+	metrics := NewCounterMetrics()
+	monitor := NewResourceMonitor(manager, read, clock, &mockLogger{},
+		WithResourceThresholds(ResourceThresholds{CPUPercent: 80, RSSBytes: 500 << 20}),
+		WithResourceMetrics(metrics))
+
+	// First sample: memory only, CPU needs two readings
+	if n := monitor.Sample(); n != 1 {
+		t.Fatalf("expected 1 agent sampled, got %d", n)
+	}
+	got := manager.Get("session-1").GetResourceUsage()
+	if got.RSSBytes != 100<<20 || got.CPUPercent != 0 || !got.SampledAt.Equal(testTime) {
+		t.Errorf("unexpected first sample: %+v", got)
+	}
+
+	// 1.5s of CPU over 1s is 150% of a core
+	clock.mono += time.Second
+	usage.CPUTime += 1500 * time.Millisecond
+	monitor.Sample()
+	if got := manager.Get("session-1").GetResourceUsage(); got.CPUPercent != 150 {
+		t.Errorf("expected 150%% CPU, got %v", got.CPUPercent)
+	}
+	if len(conn.written) != 1 {
+		t.Fatalf("expected 1 warning, got %d messages", len(conn.written))
+	}
+	warning, ok := conn.written[0].(AgentResourceWarningMessage)
+	if !ok || warning.Type != "agent:resource_warning" || warning.Resource != ResourceCPU ||
+		warning.SessionID != "session-1" || warning.Role != "auth" || warning.Limit != 80 {
+		t.Errorf("unexpected warning: %+v", conn.written[0])
+	}
+
+	// Still over: no repeat until the reading drops back under the threshold
+	clock.mono += time.Second
+	usage.CPUTime += time.Second
+	monitor.Sample()
+	if len(conn.written) != 1 {
+		t.Errorf("expected the CPU warning not to repeat, got %d messages", len(conn.written))
+	}
+	clock.mono += time.Second
+	usage.CPUTime += 100 * time.Millisecond
+	monitor.Sample()
+	clock.mono += time.Second
+	usage.CPUTime += time.Second
+	usage.RSSBytes = 600 << 20
+	monitor.Sample()
+	if len(conn.written) != 3 {
+		t.Fatalf("expected CPU and memory warnings after re-arming, got %d messages", len(conn.written))
+	}
+	if m := conn.written[2].(AgentResourceWarningMessage); m.Resource != ResourceMemory || m.RSSBytes != 600<<20 {
+		t.Errorf("unexpected memory warning: %+v", m)
+	}
+	if n := metrics.Value(MetricResourceWarnings); n != 3 {
+		t.Errorf("expected 3 warnings counted, got %d", n)
+	}
+}
+
+func TestResourceMonitor_SkipsUnreadableAgents(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: testTime, mono: time.Second}
+	manager := NewSessionManager(&mockLogger{}, clock, &sequentialIDGenerator{})
+	for _, role := range []string{"auth", "db", "api"} {
+		if _, err := manager.Create(ctx, role, &mockWebSocketConn{}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	_ = manager.BeginSpawn(ctx, "session-1")
+	_ = manager.AttachAgent(ctx, "session-1", "/work/auth", &mockStreamingACPClient{}) // No PID
+	_ = manager.BeginSpawn(ctx, "session-2")
+	_ = manager.AttachAgent(ctx, "session-2", "/work/db", &mockProcessACPClient{pid: 7}) // Exited
+	// session-3 never spawned
+
+	read := func(pid int) (ProcessUsage, error) { return ProcessUsage{}, errors.New("no such process") }
+	monitor := NewResourceMonitor(manager, read, clock, &mockLogger{})
+	if n := monitor.Sample(); n != 0 {
+		t.Errorf("expected no agents sampled, got %d", n)
+	}
+	if got := manager.Get("session-2").GetResourceUsage(); !got.SampledAt.IsZero() {
+		t.Errorf("expected no sample recorded, got %+v", got)
+	}
+}
+
+func TestAdminSessionView_Resources(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: testTime, mono: time.Second}
+	manager := NewSessionManager(&mockLogger{}, clock, &mockIDGenerator{id: "session-1"})
+	if _, err := manager.Create(ctx, "auth", &mockWebSocketConn{}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if view := newSessionView(manager.Get("session-1")); view.Resources != nil {
+		t.Errorf("expected no resources before sampling, got %+v", view.Resources)
+	}
+	_ = manager.BeginSpawn(ctx, "session-1")
+	_ = manager.AttachAgent(ctx, "session-1", "/work/auth", &mockProcessACPClient{pid: 1})
+	monitor := NewResourceMonitor(manager, func(int) (ProcessUsage, error) {
+		return ProcessUsage{RSSBytes: 4096}, nil
+	}, clock, &mockLogger{})
+	monitor.Sample()
+
+	view := newSessionView(manager.Get("session-1"))
+	if view.Resources == nil || view.Resources.RSSBytes != 4096 || view.Resources.SampledAt != FormatTimestamp(testTime) {
+		t.Errorf("unexpected resources: %+v", view.Resources)
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	tests := []struct {
+		cpu, elapsed time.Duration
+		want         float64
+	}{
+		{500 * time.Millisecond, time.Second, 50},
+		{3 * time.Second, time.Second, 300},
+		{time.Second, 0, 0},
+		{-time.Second, time.Second, 0}, // Counter reset
+	}
+	for _, tt := range tests {
+		if got := cpuPercent(tt.cpu, tt.elapsed); got != tt.want {
+			t.Errorf("cpuPercent(%v, %v) = %v, want %v", tt.cpu, tt.elapsed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/cron"
+)
+
+// DefaultScheduleTick is how often the scheduler checks for due schedules;
+// cron expressions have minute resolution
+const DefaultScheduleTick = 15 * time.Second
+
+// Schedule run triggers
+const (
+	TriggerCron   = "cron"
+	TriggerManual = "manual"
+)
+
+var (
+	// ErrScheduleNotFound is returned for schedule names that are not defined
+	ErrScheduleNotFound = errors.New("schedule not found")
+
+	// ErrScheduleRunning is returned when a schedule is run while its
+	// previous run is still going
+	ErrScheduleRunning = errors.New("schedule is already running")
+
+	// ErrInvalidSchedule is returned for schedules without a usable name,
+	// cron expression or job
+	ErrInvalidSchedule = errors.New("invalid schedule")
+)
+
+// ScheduleConfig runs a job template whenever a cron expression fires, e.g.
+// a nightly dependency update or an hourly issue triage
+// Expressions are evaluated in UTC (see package cron for the syntax). A
+// paused schedule is kept but never fires on its own.
+type ScheduleConfig struct {
+	Name   string     `json:"name"`
+	Cron   string     `json:"cron"`
+	Job    JobRequest `json:"job"`
+	Paused bool       `json:"paused,omitempty"`
+}
+
+// validate checks the name, the cron expression and the job
+func (c ScheduleConfig) validate() error {
+	if c.Name == "" || strings.ContainsAny(c.Name, "/ \t\r\n") {
+		return fmt.Errorf("%w: name must be non-empty without slashes or whitespace", ErrInvalidSchedule)
+	}
+	if _, err := cron.Parse(c.Cron); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	if err := c.Job.Validate(); err != nil {
+		return fmt.Errorf("%w: job: %v", ErrInvalidSchedule, err)
+	}
+	return nil
+}
+
+// JobFunc runs one job; JobRunner.Run
+type JobFunc func(ctx context.Context, req JobRequest) (JobResult, error)
+
+// Scheduler runs jobs on cron schedules, managed through the admin API
+// A schedule never overlaps itself: a firing while the previous run is
+// still going is skipped. Firings missed while the relay was down are not
+// made up. Safe for concurrent use.
+type Scheduler struct {
+	run    JobFunc
+	clock  Clock
+	logger Logger
+
+	mu        sync.Mutex
+	schedules map[string]*scheduleEntry
+	wg        sync.WaitGroup
+}
+
+// scheduleEntry is one schedule and its state
+type scheduleEntry struct {
+	config ScheduleConfig
+	cron   *cron.Schedule
+	next   time.Time    // Zero when paused
+	active *scheduleRun // The run in progress, if any
+	last   *scheduleRun // The most recent finished run
+}
+
+// scheduleRun is one run of a schedule
+type scheduleRun struct {
+	trigger  string
+	started  time.Time
+	finished time.Time
+	result   JobResult
+}
+
+// NewScheduler creates a scheduler with no schedules that runs jobs with run
+func NewScheduler(run JobFunc, clock Clock, logger Logger) *Scheduler {
+	return &Scheduler{
+		run:       run,
+		clock:     clock,
+		logger:    logger,
+		schedules: make(map[string]*scheduleEntry),
+	}
+}
+
+// Put adds a schedule or replaces the one with the same name
+// A replaced schedule keeps its run history and any run in progress.
+func (s *Scheduler) Put(config ScheduleConfig) (ScheduleView, error) {
+	if err := config.validate(); err != nil {
+		return ScheduleView{}, err
+	}
+	sched, _ := cron.Parse(config.Cron) // Checked by validate
+	entry := &scheduleEntry{config: config, cron: sched}
+	if !config.Paused {
+		entry.next = sched.Next(s.now())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.schedules[config.Name]; ok {
+		entry.active, entry.last = old.active, old.last
+	}
+	s.schedules[config.Name] = entry
+	return entry.view(), nil
+}
+
+// Delete removes a schedule; a run in progress finishes
+func (s *Scheduler) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schedules[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+	}
+	delete(s.schedules, name)
+	return nil
+}
+
+// Get returns one schedule
+func (s *Scheduler) Get(name string) (ScheduleView, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.schedules[name]
+	if !ok {
+		return ScheduleView{}, false
+	}
+	return entry.view(), true
+}
+
+// List returns every schedule sorted by name
+func (s *Scheduler) List() []ScheduleView {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	views := make([]ScheduleView, 0, len(s.schedules))
+	for _, entry := range s.schedules {
+		views = append(views, entry.view())
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+	return views
+}
+
+// Trigger starts a schedule's job now, paused or not, without waiting for it
+// The job runs with ctx, which should outlive the caller's request.
+func (s *Scheduler) Trigger(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.schedules[name]
+	switch {
+	case !ok:
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+	case entry.active != nil:
+		return fmt.Errorf("%w: %s", ErrScheduleRunning, name)
+	}
+	s.start(ctx, entry, TriggerManual)
+	return nil
+}
+
+// Run checks for due schedules every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Tick(ctx)
+		}
+	}
+}
+
+// Tick starts the jobs of schedules that are due, without waiting for them
+// Returns how many jobs were started.
+func (s *Scheduler) Tick(ctx context.Context) int {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	started := 0
+	for name, entry := range s.schedules {
+		if entry.next.IsZero() || now.Before(entry.next) {
+			continue
+		}
+		entry.next = entry.cron.Next(now)
+		if entry.active != nil {
+			s.logger.Printf("Schedule skipped, previous run still going: schedule=%s", name)
+			continue
+		}
+		s.start(ctx, entry, TriggerCron)
+		started++
+	}
+	return started
+}
+
+// Wait blocks until every job started so far has finished
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// start runs entry's job in the background; the caller holds s.mu
+func (s *Scheduler) start(ctx context.Context, entry *scheduleEntry, trigger string) {
+	run := &scheduleRun{trigger: trigger, started: s.now()}
+	entry.active = run
+	name, job := entry.config.Name, entry.config.Job
+	s.logger.Printf("Schedule started: schedule=%s trigger=%s role=%s", name, trigger, job.Role)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		result, err := s.run(ctx, job)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		run.result, run.finished = result, s.now()
+		s.logger.Printf("Schedule finished: schedule=%s session=%s ok=%t", name, result.SessionID, result.OK)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// The schedule may have been deleted, or deleted and defined again
+		if current, ok := s.schedules[name]; ok && current.active == run {
+			current.active, current.last = nil, run
+		}
+	}()
+}
+
+// now returns the current time in UTC, the zone schedules are evaluated in
+func (s *Scheduler) now() time.Time {
+	return s.clock.Now().UTC()
+}
+
+// ScheduleView is the admin API representation of a schedule
+// Timestamps are RFC3339; NextRun is empty for a paused schedule.
+type ScheduleView struct {
+	ScheduleConfig
+	NextRun string           `json:"nextRun,omitempty"`
+	Running *ScheduleRunView `json:"running,omitempty"` // The run in progress, if any
+	LastRun *ScheduleRunView `json:"lastRun,omitempty"` // The most recent finished run
+}
+
+// ScheduleRunView is one run of a schedule; Result is set once it finishes
+type ScheduleRunView struct {
+	Trigger    string     `json:"trigger"`
+	StartedAt  string     `json:"startedAt"`
+	FinishedAt string     `json:"finishedAt,omitempty"`
+	Result     *JobResult `json:"result,omitempty"`
+}
+
+// ScheduleListResponse is returned by GET /admin/schedules
+type ScheduleListResponse struct {
+	Schedules []ScheduleView `json:"schedules"`
+}
+
+// view converts the entry for serialization; the caller holds the lock
+func (e *scheduleEntry) view() ScheduleView {
+	v := ScheduleView{ScheduleConfig: e.config}
+	if !e.next.IsZero() {
+		v.NextRun = e.next.Format(time.RFC3339)
+	}
+	if e.active != nil {
+		v.Running = &ScheduleRunView{Trigger: e.active.trigger, StartedAt: e.active.started.Format(time.RFC3339)}
+	}
+	if e.last != nil {
+		result := e.last.result
+		v.LastRun = &ScheduleRunView{
+			Trigger:    e.last.trigger,
+			StartedAt:  e.last.started.Format(time.RFC3339),
+			FinishedAt: e.last.finished.Format(time.RFC3339),
+			Result:     &result,
+		}
+	}
+	return v
+}
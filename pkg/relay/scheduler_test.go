@@ -0,0 +1,201 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeJobs records the jobs it runs and blocks each until released
+type fakeJobs struct {
+	mu      sync.Mutex
+	ran     []JobRequest
+	release chan struct{}
+}
+
+func (f *fakeJobs) Run(ctx context.Context, req JobRequest) (JobResult, error) {
+	f.mu.Lock()
+	f.ran = append(f.ran, req)
+	f.mu.Unlock()
+	if f.release != nil {
+		<-f.release
+	}
+	return JobResult{SessionID: "session-1", Role: req.Role, OK: true}, nil
+}
+
+func (f *fakeJobs) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.ran)
+}
+
+func testSchedule(name, expr string) ScheduleConfig {
+	return ScheduleConfig{Name: name, Cron: expr, Job: JobRequest{Role: "deps", Workspace: "/tmp/ws", Prompts: []string{"update dependencies"}}}
+}
+
+func TestScheduler_Tick(t *testing.T) {
+	clock := &mockClock{now: time.Date(2026, 1, 14, 10, 30, 0, 0, time.UTC)}
+	jobs := &fakeJobs{}
+	s := NewScheduler(jobs.Run, clock, &mockLogger{})
+	view, err := s.Put(testSchedule("nightly", "0 3 * * *"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if view.NextRun != "2026-01-15T03:00:00Z" {
+		t.Errorf("expected next run at 03:00 tomorrow, got %s", view.NextRun)
+	}
+
+	if n := s.Tick(context.Background()); n != 0 {
+		t.Errorf("expected nothing due, started %d", n)
+	}
+	clock.now = time.Date(2026, 1, 15, 3, 0, 10, 0, time.UTC)
+	if n := s.Tick(context.Background()); n != 1 {
+		t.Fatalf("expected the schedule to fire, started %d", n)
+	}
+	s.Wait()
+	if n := s.Tick(context.Background()); n != 0 {
+		t.Errorf("expected one run per firing, started %d more", n)
+	}
+
+	view, _ = s.Get("nightly")
+	if view.NextRun != "2026-01-16T03:00:00Z" || view.Running != nil {
+		t.Errorf("unexpected schedule after the run: %+v", view)
+	}
+	if view.LastRun == nil || view.LastRun.Trigger != TriggerCron || !view.LastRun.Result.OK {
+		t.Errorf("expected the last run to be recorded, got %+v", view.LastRun)
+	}
+	if jobs.count() != 1 || jobs.ran[0].Role != "deps" {
+		t.Errorf("expected the job template to run once, got %+v", jobs.ran)
+	}
+}
+
+func TestScheduler_SkipsOverlap(t *testing.T) {
+	clock := &mockClock{now: time.Date(2026, 1, 14, 10, 30, 0, 0, time.UTC)}
+	jobs := &fakeJobs{release: make(chan struct{})}
+	logger := &mockLogger{}
+	s := NewScheduler(jobs.Run, clock, logger)
+	if _, err := s.Put(testSchedule("triage", "* * * * *")); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if n := s.Tick(context.Background()); n != 1 {
+		t.Fatalf("expected the schedule to fire, started %d", n)
+	}
+	clock.now = clock.now.Add(time.Minute)
+	if n := s.Tick(context.Background()); n != 0 {
+		t.Errorf("expected a firing during a run to be skipped, started %d", n)
+	}
+	if err := s.Trigger(context.Background(), "triage"); !errors.Is(err, ErrScheduleRunning) {
+		t.Errorf("expected ErrScheduleRunning, got %v", err)
+	}
+	if !slices.ContainsFunc(logger.logs, func(l string) bool { return strings.HasPrefix(l, "Schedule skipped") }) {
+		t.Errorf("expected the skip to be logged, got %v", logger.logs)
+	}
+	close(jobs.release)
+	s.Wait()
+	if jobs.count() != 1 {
+		t.Errorf("expected one run, got %d", jobs.count())
+	}
+}
+
+func TestScheduler_PausedAndTrigger(t *testing.T) {
+	clock := &mockClock{now: time.Date(2026, 1, 14, 10, 30, 0, 0, time.UTC)}
+	jobs := &fakeJobs{}
+	s := NewScheduler(jobs.Run, clock, &mockLogger{})
+	config := testSchedule("triage", "* * * * *")
+	config.Paused = true
+	view, err := s.Put(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.NextRun != "" {
+		t.Errorf("expected a paused schedule to have no next run, got %s", view.NextRun)
+	}
+	clock.now = clock.now.Add(time.Hour)
+	if n := s.Tick(context.Background()); n != 0 {
+		t.Errorf("expected a paused schedule not to fire, started %d", n)
+	}
+
+	if err := s.Trigger(context.Background(), "triage"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	s.Wait()
+	view, _ = s.Get("triage")
+	if view.LastRun == nil || view.LastRun.Trigger != TriggerManual {
+		t.Errorf("expected a manual run, got %+v", view.LastRun)
+	}
+	if err := s.Trigger(context.Background(), "missing"); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("expected ErrScheduleNotFound, got %v", err)
+	}
+}
+
+func TestScheduler_Put_Invalid(t *testing.T) {
+	s := NewScheduler((&fakeJobs{}).Run, &mockClock{}, &mockLogger{})
+	bad := []ScheduleConfig{
+		testSchedule("", "* * * * *"),
+		testSchedule("a/b", "* * * * *"),
+		testSchedule("nightly", "0 3 * *"),
+		{Name: "nightly", Cron: "0 3 * * *", Job: JobRequest{Role: "deps"}},
+	}
+	for _, config := range bad {
+		if _, err := s.Put(config); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Put(%+v): expected ErrInvalidSchedule, got %v", config, err)
+		}
+	}
+	if len(s.List()) != 0 {
+		t.Errorf("expected invalid schedules not to be added")
+	}
+}
+
+func TestAdminHandler_Schedules(t *testing.T) {
+	clock := &mockClock{now: time.Date(2026, 1, 14, 10, 30, 0, 0, time.UTC)}
+	scheduler := NewScheduler((&fakeJobs{}).Run, clock, &mockLogger{})
+	handler := NewAdminHandler(nil, &mockLogger{}, WithAdminScheduler(scheduler))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	body, _ := json.Marshal(testSchedule("", "0 3 * * *"))
+	if rec := do(http.MethodPut, "/admin/schedules/nightly", string(body)); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPut, "/admin/schedules/nightly", `{"cron": "bad", "job": {}}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid schedule, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPut, "/admin/schedules/nightly", `{"name": "other"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a mismatched name, got %d", rec.Code)
+	}
+
+	rec := do(http.MethodGet, "/admin/schedules", "")
+	var list ScheduleListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Schedules) != 1 || list.Schedules[0].Name != "nightly" || list.Schedules[0].NextRun != "2026-01-15T03:00:00Z" {
+		t.Errorf("unexpected schedules: %+v", list.Schedules)
+	}
+
+	if rec := do(http.MethodPost, "/admin/schedules/nightly/run", ""); rec.Code != http.StatusAccepted {
+		t.Errorf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	scheduler.Wait()
+	if rec := do(http.MethodPost, "/admin/schedules/missing/run", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/admin/schedules/nightly", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/admin/schedules/nightly", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package relay
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// ErrSecretNotFound is returned by a SecretProvider for unknown secrets
+var ErrSecretNotFound = errors.New("secret not found")
+
+// minKeySecret is the shortest secret keys are derived from
+const minKeySecret = 32
+
+// Key derivation labels, separating the keys derived from one secret
+const (
+	archiveKeyInfo    = "ourocodus archive encryption v1"
+	federationKeyInfo = "ourocodus federation signing v1"
+	adminKeyInfo      = "ourocodus admin signing v1"
+)
+
+// SecretProvider resolves named secrets such as encryption keys, so they are
+// kept out of config files
+type SecretProvider interface {
+	Secret(name string) ([]byte, error)
+}
+
+// EnvSecrets is a SecretProvider reading environment variables, e.g.
+// EnvSecrets(os.LookupEnv)
+type EnvSecrets func(key string) (string, bool)
+
+// Secret returns the value of the environment variable name
+func (e EnvSecrets) Secret(name string) ([]byte, error) {
+	value, ok := e(name)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	return []byte(value), nil
+}
+
+// NewArchiveCipher builds the session archive cipher keyed from the secret name
+// The secret must be at least 32 bytes of high-entropy data (e.g. the output
+// of `openssl rand -base64 32`); the AES key is derived from it with HMAC-SHA256.
+func NewArchiveCipher(secrets SecretProvider, name string) (*session.ArchiveCipher, error) {
+	key, err := deriveKey(secrets, name, archiveKeyInfo)
+	if err != nil {
+		return nil, err
+	}
+	return session.NewArchiveCipher(key)
+}
+
+// NewFederationKey derives the key relays sign federation requests with from
+// the secret name, which every relay of the federation must share
+// The secret has the same requirements as for NewArchiveCipher.
+func NewFederationKey(secrets SecretProvider, name string) ([]byte, error) {
+	return deriveKey(secrets, name, federationKeyInfo)
+}
+
+// NewAdminKey derives the admin request signing key id from the secret name,
+// which the relay and the clients signing with the key share
+// The secret has the same requirements as for NewArchiveCipher.
+func NewAdminKey(secrets SecretProvider, id, name string) (AdminKey, error) {
+	key, err := deriveKey(secrets, name, adminKeyInfo)
+	if err != nil {
+		return AdminKey{}, err
+	}
+	return AdminKey{ID: id, Key: key}, nil
+}
+
+// deriveKey derives a 32-byte key for info from the named secret with HMAC-SHA256
+func deriveKey(secrets SecretProvider, name, info string) ([]byte, error) {
+	secret, err := secrets.Secret(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) < minKeySecret {
+		return nil, fmt.Errorf("secret %s must be at least %d bytes, got %d", name, minKeySecret, len(secret))
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(info))
+	return mac.Sum(nil), nil
+}
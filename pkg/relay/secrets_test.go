@@ -0,0 +1,63 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEnvSecrets(t *testing.T) {
+	env := EnvSecrets(func(key string) (string, bool) {
+		values := map[string]string{"KEY": "value", "EMPTY": ""}
+		v, ok := values[key]
+		return v, ok
+	})
+	if got, err := env.Secret("KEY"); err != nil || string(got) != "value" {
+		t.Errorf("Secret(KEY) = %q, %v", got, err)
+	}
+	for _, name := range []string{"EMPTY", "MISSING"} {
+		if _, err := env.Secret(name); !errors.Is(err, ErrSecretNotFound) {
+			t.Errorf("Secret(%s): expected ErrSecretNotFound, got %v", name, err)
+		}
+	}
+}
+
+func TestNewArchiveCipher(t *testing.T) {
+	secret := strings.Repeat("k", minKeySecret)
+	env := EnvSecrets(func(key string) (string, bool) {
+		switch key {
+		case "ARCHIVE_KEY":
+			return secret, true
+		case "WEAK_KEY":
+			return "hunter2", true
+		}
+		return "", false
+	})
+
+	if _, err := NewArchiveCipher(env, "WEAK_KEY"); err == nil || !strings.Contains(err.Error(), "at least") {
+		t.Errorf("expected short secret to be rejected, got %v", err)
+	}
+	if _, err := NewArchiveCipher(env, "MISSING"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("expected ErrSecretNotFound, got %v", err)
+	}
+
+	// The same secret derives the same key
+	first, err := NewArchiveCipher(env, "ARCHIVE_KEY")
+	if err != nil {
+		t.Fatalf("NewArchiveCipher failed: %v", err)
+	}
+	second, _ := NewArchiveCipher(env, "ARCHIVE_KEY")
+	var sealed bytes.Buffer
+	w, _ := first.EncryptWriter(&sealed)
+	_, _ = io.WriteString(w, "transcript")
+	_ = w.Close()
+	r, err := second.DecryptReader(&sealed)
+	if err != nil {
+		t.Fatalf("DecryptReader failed: %v", err)
+	}
+	if got, err := io.ReadAll(r); err != nil || string(got) != "transcript" {
+		t.Errorf("decrypted %q, %v", got, err)
+	}
+}
@@ -1,33 +1,184 @@
 package relay
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+	"github.com/gorilla/websocket"
 )
 
+// MetricConnectionPanics counts connection handlers that recovered from a panic
+const MetricConnectionPanics = "relay_connection_panics_total"
+
 // Server handles WebSocket connections with injected dependencies
 type Server struct {
 	serverID string
 	logger   Logger
 	clock    Clock
 	upgrader Upgrader
+	metrics  Metrics
+	streamer *AgentStreamer
+	spawner  *Spawner
+	idGen    IDGenerator
+	conns    atomic.Int64 // Open WebSocket connections
+	draining atomic.Bool  // Set by Drain: new connections are refused
+
+	connections map[WebSocketConn]*connState // Open connections by the conn handlers use
+	connsMu     sync.Mutex
+
+	pullRequests *PullRequestService // nil makes git:open_pr an unknown type
+
+	ipFilter    *IPFilter           // nil accepts every address
+	auth        Authenticator       // nil admits every connection anonymously
+	attachments AttachmentStore     // nil rejects binary frames
+	normalize   func([]byte) []byte // Applied to valid text frames, e.g. NFC
+	protocol    ProtocolConfig
+	journal     *Journal // nil journals nothing
+
+	policy         MessagePolicy // nil passes every message
+	policyFailOpen bool
+
+	// Per-connection protocol violation budget (disabled when budgetMax is 0)
+	budgets      map[WebSocketConn]*errorBudget
+	budgetMax    int
+	budgetWindow time.Duration
+	budgetsMu    sync.Mutex
+
+	readLimit   int64         // Largest client frame (0 is unlimited)
+	readTimeout time.Duration // Longest a client may stay silent (0 is forever)
 	// TODO(Issue #7): Add sessionManager *session.Manager here
 	// sessionManager will coordinate session lifecycle when ACP integration is added
 }
 
+// ServerOption configures optional Server collaborators
+type ServerOption func(*Server)
+
+// WithMetrics sets the metrics sink used for server instrumentation
+func WithMetrics(metrics Metrics) ServerOption {
+	return func(s *Server) {
+		if metrics == nil {
+			s.metrics = &NoOpMetrics{}
+			return
+		}
+		s.metrics = metrics
+	}
+}
+
+// WithAgentStreamer routes agent:message prompts and agent:cancel messages to the streamer
+func WithAgentStreamer(streamer *AgentStreamer) ServerOption {
+	return func(s *Server) {
+		s.streamer = streamer
+	}
+}
+
+// WithSpawner handles session:create_from_template messages with the spawner's templates
+func WithSpawner(spawner *Spawner) ServerOption {
+	return func(s *Server) {
+		s.spawner = spawner
+	}
+}
+
+// WithPullRequests handles git:open_pr messages with the service
+func WithPullRequests(service *PullRequestService) ServerOption {
+	return func(s *Server) {
+		s.pullRequests = service
+	}
+}
+
+// WithErrorBudget closes connections that send more than max invalid
+// messages within window, with close code 1008 (policy violation)
+// A max of zero tolerates any number of recoverable errors
+func WithErrorBudget(max int, window time.Duration) ServerOption {
+	return func(s *Server) {
+		s.budgetMax = max
+		s.budgetWindow = window
+	}
+}
+
+// WithIPFilter refuses the upgrade with 403 for clients the filter rejects
+func WithIPFilter(filter *IPFilter) ServerOption {
+	return func(s *Server) {
+		s.ipFilter = filter
+	}
+}
+
+// WithAuthenticator identifies the user of each connection before the
+// upgrade, refusing it with 401 if the authenticator fails
+// Sessions spawned by an identified user are owned by them and can only be
+// used by them and the collaborators they share them with.
+func WithAuthenticator(auth Authenticator) ServerOption {
+	return func(s *Server) {
+		s.auth = auth
+	}
+}
+
+// WithAttachments stores binary frames in store instead of rejecting them
+func WithAttachments(store AttachmentStore) ServerOption {
+	return func(s *Server) {
+		s.attachments = store
+	}
+}
+
+// WithTextNormalizer rewrites every valid text frame before it is routed,
+// e.g. with norm.NFC.Bytes from golang.org/x/text/unicode/norm
+// The normalizer must return well-formed UTF-8 JSON
+func WithTextNormalizer(normalize func([]byte) []byte) ServerOption {
+	return func(s *Server) {
+		s.normalize = normalize
+	}
+}
+
+// WithProtocolConfig sets the parsing rules for inbound messages
+func WithProtocolConfig(cfg ProtocolConfig) ServerOption {
+	return func(s *Server) {
+		s.protocol = cfg
+	}
+}
+
+// WithJournal journals the protocol frames of every session (see Journal)
+func WithJournal(journal *Journal) ServerOption {
+	return func(s *Server) {
+		s.journal = journal
+	}
+}
+
 // NewServer creates a new relay server with dependency injection
-func NewServer(idGen IDGenerator, logger Logger, clock Clock, upgrader Upgrader) *Server {
-	return &Server{
+func NewServer(idGen IDGenerator, logger Logger, clock Clock, upgrader Upgrader, opts ...ServerOption) *Server {
+	s := &Server{
 		serverID: idGen.Generate(),
+		idGen:    idGen,
 		logger:   logger,
 		clock:    clock,
 		upgrader: upgrader,
+		metrics:  &NoOpMetrics{},
+
+		readLimit:   DefaultReadLimit,
+		readTimeout: DefaultReadTimeout,
+
+		connections: make(map[WebSocketConn]*connState),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
+}
+
+// ActiveConnections returns the number of open WebSocket connections
+func (s *Server) ActiveConnections() int {
+	return int(s.conns.Load())
 }
 
 // sendHandshake sends the connection established message (single responsibility)
 func (s *Server) sendHandshake(conn WebSocketConn) error {
-	handshake := NewConnectionEstablished(s.serverID, s.clock.Now())
+	handshake := NewConnectionEstablished(s.serverID, FormatTimestamp(s.clock.Now()))
 	if err := conn.WriteJSON(handshake); err != nil {
 		s.logger.Printf("Failed to send handshake: %v", err)
 		return err
@@ -36,10 +187,17 @@ func (s *Server) sendHandshake(conn WebSocketConn) error {
 }
 
 // handleValidationError processes validation errors and sends appropriate responses
+// Every error is charged to the connection's error budget
 // Returns true if connection should be closed
 func (s *Server) handleValidationError(conn WebSocketConn, err error) bool {
 	s.logger.Printf("Invalid message: %v", err)
 
+	if budget := s.budgetFor(conn); budget != nil && budget.spend() {
+		s.closeWithPolicyViolation(conn, fmt.Sprintf(
+			"more than %d invalid messages in %s", s.budgetMax, s.budgetWindow))
+		return true
+	}
+
 	// Convert to ValidationError
 	var validationErr ValidationError
 	if verr, ok := err.(ValidationError); ok {
@@ -68,13 +226,58 @@ func (s *Server) handleValidationError(conn WebSocketConn, err error) bool {
 	return false // Keep connection open
 }
 
+// trackBudget gives a new connection its own error budget, if enabled
+// Returns a func that forgets the connection
+func (s *Server) trackBudget(conn WebSocketConn) func() {
+	if s.budgetMax <= 0 {
+		return func() {}
+	}
+	s.budgetsMu.Lock()
+	defer s.budgetsMu.Unlock()
+	if s.budgets == nil {
+		s.budgets = make(map[WebSocketConn]*errorBudget)
+	}
+	s.budgets[conn] = newErrorBudget(s.budgetMax, s.budgetWindow, s.clock)
+	return func() {
+		s.budgetsMu.Lock()
+		defer s.budgetsMu.Unlock()
+		delete(s.budgets, conn)
+	}
+}
+
+// budgetFor returns the connection's error budget (nil if untracked)
+func (s *Server) budgetFor(conn WebSocketConn) *errorBudget {
+	s.budgetsMu.Lock()
+	defer s.budgetsMu.Unlock()
+	return s.budgets[conn]
+}
+
 // addTimestamp adds timestamp to message (pure-ish - operates on provided map)
 func (s *Server) addTimestamp(msg map[string]interface{}) {
-	msg["timestamp"] = s.clock.Now()
+	msg["timestamp"] = FormatTimestamp(s.clock.Now())
 }
 
-// echoMessage parses, timestamps, and echoes back a message
+// echoMessage timestamps and echoes back a message
+// The timestamp is spliced into the raw frame in a pooled buffer; frames the
+// byte-level edit can't handle are decoded and re-encoded instead. Either way
+// the echo is the same JSON object, though member order may differ.
 func (s *Server) echoMessage(conn WebSocketConn, rawMessage []byte) error {
+	bufp := echoBuffers.Get().(*[]byte)
+	frame, ok := appendTimestamp((*bufp)[:0], rawMessage, FormatTimestamp(s.clock.Now()))
+	if ok {
+		// WriteJSON encodes before returning, so the buffer can be reused after
+		err := conn.WriteJSON(json.RawMessage(frame))
+		if cap(frame) <= maxPooledEcho {
+			*bufp = frame
+			echoBuffers.Put(bufp)
+		}
+		if err != nil {
+			s.logger.Printf("Write error: %v", err)
+		}
+		return err
+	}
+	echoBuffers.Put(bufp)
+
 	var msg map[string]interface{}
 	if err := json.Unmarshal(rawMessage, &msg); err != nil {
 		s.logger.Printf("Failed to parse message: %v", err)
@@ -91,40 +294,452 @@ func (s *Server) echoMessage(conn WebSocketConn, rawMessage []byte) error {
 	return nil
 }
 
+// handleFrame dispatches a frame by WebSocket message type
+// Returns true if connection should be closed
+func (s *Server) handleFrame(conn WebSocketConn, messageType int, data []byte) bool {
+	if messageType == websocket.BinaryMessage {
+		return s.handleBinary(conn, data)
+	}
+	return s.handleMessage(conn, data)
+}
+
 // handleMessage processes a single incoming message
 // Returns true if connection should be closed
 func (s *Server) handleMessage(conn WebSocketConn, rawMessage []byte) bool {
 	// Validate message
-	if err := ValidateMessage(rawMessage); err != nil {
+	if err := ValidateMessageWith(rawMessage, s.protocol); err != nil {
 		return s.handleValidationError(conn, err)
 	}
+	if s.normalize != nil {
+		rawMessage = s.normalize(rawMessage)
+	}
+	if s.policy != nil {
+		var ok bool
+		if rawMessage, ok = s.applyInboundPolicy(conn, rawMessage); !ok {
+			return false
+		}
+	}
+	journaled := s.journalFrame(conn, rawMessage)
+
+	base, _ := parseMessage(rawMessage) // Already validated
+	handler, ok := s.handlerFor(base.Type)
+	if !ok {
+		s.handleUnknownType(conn, base.Type)
+		return false
+	}
+	shouldClose := handler.handle(s, conn, rawMessage)
+	if !journaled {
+		s.journalFrame(conn, rawMessage) // e.g. session:reattach, now that conn is attached
+	}
+	return shouldClose
+}
+
+// journalFrame journals an inbound frame if conn is attached to or observing
+// the session it names, reporting whether it did
+// Frames naming any other session, real or made up, are not the client's to
+// journal.
+func (s *Server) journalFrame(conn WebSocketConn, frame []byte) bool {
+	if s.journal == nil {
+		return false
+	}
+	sessionID := frameSessionID(frame)
+	if sessionID == "" || !s.usesSession(conn, sessionID) {
+		return false
+	}
+	s.journal.RecordFrame(FrameIn, frame)
+	return true
+}
+
+// usesSession reports whether conn is attached to or observing a session
+func (s *Server) usesSession(conn WebSocketConn, sessionID string) bool {
+	return s.streamer != nil && (s.streamer.Owns(sessionID, conn) || s.streamer.Observes(sessionID, conn))
+}
+
+// handleAgentSend prompts a session's agent and streams the reply
+// The reply runs off the read loop so agent:cancel can still be received.
+// Rejections end the reply with agent:complete carrying the error, so clients
+// can match them to the request by correlation ID.
+func (s *Server) handleAgentSend(conn WebSocketConn, rawMessage []byte) {
+	msg, err := ParseAgentSend(rawMessage)
+	if err != nil {
+		s.handleValidationError(conn, err)
+		return
+	}
+
+	s.streamReply(conn, msg.SessionID, msg.CorrelationID, func() error {
+		ctx, cancel := s.userContext(conn), context.CancelFunc(func() {})
+		if deadline, ok := msg.DeadlineFrom(s.clock.Now()); ok {
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+		}
+		defer cancel()
+		_, err := s.streamer.Stream(ctx, msg.SessionID, msg.CorrelationID, msg.Content)
+		return err
+	})
+}
+
+// handleAgentToolDecision resolves a tool call left for the client and
+// streams the agent's reply
+func (s *Server) handleAgentToolDecision(conn WebSocketConn, rawMessage []byte) {
+	msg, err := ParseAgentToolDecision(rawMessage)
+	if err != nil {
+		s.handleValidationError(conn, err)
+		return
+	}
+
+	s.streamReply(conn, msg.SessionID, msg.CorrelationID, func() error {
+		_, err := s.streamer.ResolveToolCall(s.userContext(conn), msg.SessionID, msg.CorrelationID, msg.ToolCallID, msg.Approved)
+		return err
+	})
+}
+
+// streamReply runs reply in the background if conn may prompt the session
+// Failures that happen before the reply starts streaming are answered on
+// conn with an agent:complete carrying the error; the others already ended
+// the stream.
+func (s *Server) streamReply(conn WebSocketConn, sessionID, correlationID string, reply func() error) {
+	reject := func(code, message string) {
+		msg := NewAgentCompleteMessage(sessionID, correlationID, 0, nil, FormatTimestamp(s.clock.Now()),
+			&ErrorDetail{Code: code, Message: message, Recoverable: true})
+		if err := conn.WriteJSON(msg); err != nil {
+			s.logger.Printf("Failed to send agent rejection: %v", err)
+		}
+	}
+
+	if s.streamer.Observes(sessionID, conn) {
+		reject("READ_ONLY", fmt.Sprintf("session %s is observed read-only on this connection", sessionID))
+		return
+	}
+	if !s.streamer.Owns(sessionID, conn) {
+		reject("SESSION_NOT_FOUND", fmt.Sprintf("no session %s on this connection", sessionID))
+		return
+	}
+
+	go func() {
+		defer s.recoverReply(sessionID, correlationID, reject)
+		err := reply()
+		switch {
+		case errors.Is(err, ErrDuplicateRequest):
+			reject("DUPLICATE_REQUEST", err.Error())
+		case errors.Is(err, session.ErrAccessDenied):
+			reject("FORBIDDEN", err.Error())
+		}
+		// Other failures already ended the stream with agent:complete
+	}()
+}
+
+// recoverReply contains a panic in a reply goroutine to that reply
+// Deferred outside HandleWebSocket, so recoverConnection does not cover it; the
+// client is sent agent:complete with INTERNAL_ERROR and the connection stays open.
+func (s *Server) recoverReply(sessionID, correlationID string, reject func(code, message string)) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	s.logger.Printf("Recovered from panic in agent reply: session=%s correlationId=%s: %v\n%s", sessionID, correlationID, r, debug.Stack())
+	reject("INTERNAL_ERROR", "Internal server error")
+	if s.metrics != nil {
+		s.metrics.IncCounter(MetricConnectionPanics)
+	}
+}
+
+// handleSessionReattach binds a detached session to this connection
+func (s *Server) handleSessionReattach(conn WebSocketConn, rawMessage []byte) {
+	msg, err := ParseSessionReattach(rawMessage)
+	if err != nil {
+		s.handleValidationError(conn, err)
+		return
+	}
+
+	var reply interface{}
+	sess, err := s.streamer.Reattach(s.userContext(conn), msg.SessionID, conn)
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound):
+		reply = NewErrorMessage("SESSION_NOT_FOUND", err.Error(), true)
+	case errors.Is(err, session.ErrAccessDenied):
+		reply = NewErrorMessage("FORBIDDEN", err.Error(), true)
+	case errors.Is(err, session.ErrConnectionMismatch), errors.Is(err, session.ErrTooManyConnections):
+		reply = NewErrorMessage("SESSION_ATTACHED", err.Error(), true)
+	case err != nil:
+		reply = NewErrorMessage("REATTACH_FAILED", err.Error(), true)
+	default:
+		reply = NewSessionReattachedMessage(sess.GetID(), sess.GetAgentID(), sess.GetName(),
+			string(sess.GetState()), FormatTimestamp(s.clock.Now()))
+	}
+
+	if err := conn.WriteJSON(reply); err != nil {
+		s.logger.Printf("Failed to send reattach response: %v", err)
+	}
+}
+
+// handleSessionObserve lets this connection watch a session read-only
+func (s *Server) handleSessionObserve(conn WebSocketConn, rawMessage []byte) {
+	msg, err := ParseSessionObserve(rawMessage)
+	if err != nil {
+		s.handleValidationError(conn, err)
+		return
+	}
+
+	var reply interface{}
+	sess, err := s.streamer.Observe(s.userContext(conn), msg.SessionID, conn)
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound):
+		reply = NewErrorMessage("SESSION_NOT_FOUND", err.Error(), true)
+	case errors.Is(err, session.ErrAccessDenied):
+		reply = NewErrorMessage("FORBIDDEN", err.Error(), true)
+	case err != nil:
+		reply = NewErrorMessage("OBSERVE_FAILED", err.Error(), true)
+	default:
+		reply = NewSessionObservingMessage(sess.GetID(), sess.GetAgentID(), sess.GetName(),
+			string(sess.GetState()), FormatTimestamp(s.clock.Now()))
+	}
+
+	if err := conn.WriteJSON(reply); err != nil {
+		s.logger.Printf("Failed to send observe response: %v", err)
+	}
+}
+
+// handleSessionShare changes who may use a session this connection's user owns
+func (s *Server) handleSessionShare(conn WebSocketConn, rawMessage []byte) {
+	msg, err := ParseSessionShare(rawMessage)
+	if err != nil {
+		s.handleValidationError(conn, err)
+		return
+	}
+
+	change := s.streamer.Share
+	if msg.Type == "session:unshare" {
+		change = s.streamer.Unshare
+	}
+	sess, users, err := change(s.userContext(conn), msg.SessionID, msg.UserID)
+	s.replyAccess(conn, sess, users, err, "SHARE_FAILED")
+}
+
+// handleSessionTransfer hands a session this connection's user owns to another user
+func (s *Server) handleSessionTransfer(conn WebSocketConn, rawMessage []byte) {
+	msg, err := ParseSessionTransfer(rawMessage)
+	if err != nil {
+		s.handleValidationError(conn, err)
+		return
+	}
+
+	sess, users, err := s.streamer.Transfer(s.userContext(conn), msg.SessionID, msg.UserID, msg.KeepAccess)
+	s.replyAccess(conn, sess, users, err, "TRANSFER_FAILED")
+}
+
+// replyAccess answers an access change with session:access, or an error
+// (failCode for unexpected failures)
+func (s *Server) replyAccess(conn WebSocketConn, sess *session.Session, users []string, err error, failCode string) {
+	var reply interface{}
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound):
+		reply = NewErrorMessage("SESSION_NOT_FOUND", err.Error(), true)
+	case errors.Is(err, session.ErrNotOwner):
+		reply = NewErrorMessage("FORBIDDEN", err.Error(), true)
+	case errors.Is(err, session.ErrNameTaken):
+		reply = NewErrorMessage("NAME_TAKEN", err.Error(), true)
+	case err != nil:
+		reply = NewErrorMessage(failCode, err.Error(), true)
+	default:
+		reply = NewSessionAccessMessage(sess.GetID(), sess.GetOwnerID(), users, FormatTimestamp(s.clock.Now()))
+	}
+
+	if err := conn.WriteJSON(reply); err != nil {
+		s.logger.Printf("Failed to send access response: %v", err)
+	}
+}
+
+// handleAgentCancel stops the in-flight reply named by an agent:cancel message
+// The reply itself confirms with agent:cancelled; only failures are answered here
+// Observers may not cancel replies they are watching.
+func (s *Server) handleAgentCancel(conn WebSocketConn, rawMessage []byte) {
+	msg, err := ParseAgentCancel(rawMessage)
+	if err != nil {
+		s.handleValidationError(conn, err)
+		return
+	}
+
+	if s.streamer.Observes(msg.SessionID, conn) {
+		reply := NewErrorMessage("READ_ONLY", fmt.Sprintf("session %s is observed read-only on this connection", msg.SessionID), true)
+		if err := conn.WriteJSON(reply); err != nil {
+			s.logger.Printf("Failed to send error response: %v", err)
+		}
+		return
+	}
+
+	if err := s.streamer.Cancel(msg.SessionID, msg.CorrelationID); err != nil {
+		// The only failure is ErrUnknownRequest: the reply already finished
+		s.logger.Printf("Cancel failed: %v", err)
+		if err := conn.WriteJSON(NewErrorMessage("UNKNOWN_REQUEST", err.Error(), true)); err != nil {
+			s.logger.Printf("Failed to send error response: %v", err)
+		}
+	}
+}
+
+// handleAgentSpawn starts one agent, or with dryRun reports whether it could
+func (s *Server) handleAgentSpawn(conn WebSocketConn, rawMessage []byte) {
+	msg, err := ParseAgentSpawn(rawMessage)
+	if err != nil {
+		s.handleValidationError(conn, err)
+		return
+	}
+
+	ctx := s.userContext(conn)
+	if msg.PayloadKey != "" {
+		ctx = ContextWithPayloadKey(ctx, msg.PayloadKey)
+	}
+	req := SpawnRequest{Role: msg.Role, Workspace: msg.Workspace, SeedFrom: msg.SeedFrom, OwnerID: session.UserFromContext(ctx), Kind: msg.Kind}
+	if msg.Name != "" {
+		req.Options = append(req.Options, session.WithName(msg.Name))
+	}
+	if msg.TTLSeconds > 0 {
+		req.Options = append(req.Options, session.WithTTL(time.Duration(msg.TTLSeconds)*time.Second))
+	}
+	if !msg.AgentConfig.IsZero() {
+		req.Options = append(req.Options, session.WithAgentConfig(msg.AgentConfig))
+	}
+
+	var reply interface{}
+	if msg.DryRun {
+		reply = NewAgentSpawnPlanMessage(s.spawner.Plan(ctx, req), FormatTimestamp(s.clock.Now()))
+	} else if sess, err := s.spawner.SpawnAgent(ctx, conn, req); err != nil {
+		s.logger.Printf("Spawn failed: role=%s err=%v", msg.Role, err)
+		code := "SPAWN_FAILED"
+		switch {
+		case errors.Is(err, ErrResourcePressure):
+			code = "RESOURCE_PRESSURE"
+		case errors.Is(err, ErrSpawnRejected):
+			code = "SPAWN_REJECTED"
+		}
+		reply = NewErrorMessage(code, err.Error(), true)
+	} else {
+		reply = NewAgentSpawnedMessage(sess.GetID(), sess.GetAgentID(), sess.GetName(), FormatTimestamp(s.clock.Now()))
+	}
+
+	if err := conn.WriteJSON(reply); err != nil {
+		s.logger.Printf("Failed to send spawn response: %v", err)
+	}
+}
+
+// handleCreateFromTemplate launches a template and reports each agent's outcome
+// A failed launch is still reported as session:template_result (ok=false) so
+// clients see which agent failed; only unknown templates get an error message
+func (s *Server) handleCreateFromTemplate(conn WebSocketConn, rawMessage []byte) {
+	msg, err := ParseSessionCreateFromTemplate(rawMessage)
+	if err != nil {
+		s.handleValidationError(conn, err)
+		return
+	}
+
+	result, err := s.spawner.LaunchTemplate(s.userContext(conn), conn, msg.Template)
+	if errors.Is(err, ErrUnknownTemplate) {
+		if err := conn.WriteJSON(NewErrorMessage("UNKNOWN_TEMPLATE", err.Error(), true)); err != nil {
+			s.logger.Printf("Failed to send error response: %v", err)
+		}
+		return
+	}
+	if err != nil {
+		s.logger.Printf("Template launch failed: %v", err)
+	}
 
-	// Echo message back
-	if err := s.echoMessage(conn, rawMessage); err != nil {
-		return true // Close on echo failure
+	if err := conn.WriteJSON(NewSessionTemplateResultMessage(result, FormatTimestamp(s.clock.Now()))); err != nil {
+		s.logger.Printf("Failed to send template result: %v", err)
 	}
+}
 
-	return false // Continue processing messages
+// recoverConnection contains a panic to the connection that raised it
+// Must be deferred after the connection close so it runs first: the client
+// receives INTERNAL_ERROR, then the deferred close tears down only this connection
+func (s *Server) recoverConnection(conn WebSocketConn) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	s.logger.Printf("Recovered from panic in connection handler: %v\n%s", r, debug.Stack())
+	if s.metrics != nil {
+		s.metrics.IncCounter(MetricConnectionPanics)
+	}
+
+	errorMsg := NewErrorMessage("INTERNAL_ERROR", "Internal server error", false)
+	if err := conn.WriteJSON(errorMsg); err != nil {
+		s.logger.Printf("Failed to send internal error response: %v", err)
+	}
 }
 
 // HandleWebSocket handles WebSocket upgrade and connection lifecycle
 func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	if s.ipFilter != nil {
+		if client := s.ipFilter.ClientIP(r); !s.ipFilter.Allowed(client) {
+			s.logger.Printf("Connection refused: client=%s remote=%s", client, r.RemoteAddr)
+			s.metrics.IncCounter(MetricIPRejected)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+	}
+
+	var userID string
+	if s.auth != nil {
+		var err error
+		if userID, err = s.auth.Authenticate(r); err != nil {
+			s.logger.Printf("Connection unauthenticated: remote=%s err=%v", r.RemoteAddr, err)
+			s.metrics.IncCounter(MetricAuthRejected)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		s.logger.Printf("Failed to upgrade connection: %v", err)
+		noteUpgradeError(r.Context(), err)
+		return
+	}
+	conn = serializeWrites(conn)
+	s.applyReadLimits(conn)
+	var client WebSocketConn // The outermost wrapper, which sessions know the connection by
+	if s.journal != nil {
+		conn = s.journal.Conn(conn, func(sessionID string) bool {
+			return client != nil && s.usesSession(client, sessionID)
+		})
+	}
+	if s.policy != nil {
+		conn = &policyConn{WebSocketConn: conn, server: s} // Outside the journal, which records what clients are sent
+	}
+	closeReason := "internal error" // Overwritten on every exit but a panic
+	defer func() { noteCloseReason(r.Context(), closeReason) }()
+	conn, state, unregister := s.registerConnection(conn, r, userID)
+	client = conn // Set before any session can write to the connection
+	defer unregister()
+	if s.draining.Load() {
+		closeReason = "relay shutting down" // Drain began during the upgrade
+		_ = conn.Close()
 		return
 	}
+	s.conns.Add(1)
+	defer s.trackBudget(conn)()
+	defer s.keepAlive(conn)()
 	defer func() {
+		s.conns.Add(-1)
+		if s.streamer != nil {
+			s.streamer.Detach(conn) // Sessions outlive the connection until reattached
+		}
 		if err := conn.Close(); err != nil {
 			s.logger.Printf("Error closing connection: %v", err)
 		}
 	}()
+	defer s.recoverConnection(conn)
 
 	s.logger.Printf("WebSocket connection established from %s", r.RemoteAddr)
 
 	// Send handshake
 	if err := s.sendHandshake(conn); err != nil {
+		closeReason = "handshake failed"
 		return
 	}
 
@@ -136,13 +751,17 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	// Handle incoming messages
 	for {
-		_, message, err := conn.ReadMessage()
+		messageType, message, err := conn.ReadMessage()
 		if err != nil {
 			s.logger.Printf("Read error: %v", err)
+			closeReason = describeReadError(err)
 			break
 		}
+		state.received.Add(1)
+		s.extendReadDeadline(conn)
 
-		if shouldClose := s.handleMessage(conn, message); shouldClose {
+		if shouldClose := s.handleFrame(conn, messageType, message); shouldClose {
+			closeReason = "closed by relay"
 			break
 		}
 	}
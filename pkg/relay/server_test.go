@@ -99,7 +99,7 @@ func TestServer_Echo(t *testing.T) {
 	// Send test message
 	testMsg := map[string]interface{}{
 		"version": "1.0",
-		"type":    "test:echo",
+		"type":    "echo",
 		"message": "hello",
 	}
 	err = conn.WriteJSON(testMsg)
@@ -125,8 +125,8 @@ func TestServer_Echo(t *testing.T) {
 	if echoMsg["version"] != "1.0" {
 		t.Errorf("expected version 1.0, got %v", echoMsg["version"])
 	}
-	if echoMsg["type"] != "test:echo" {
-		t.Errorf("expected type test:echo, got %v", echoMsg["type"])
+	if echoMsg["type"] != "echo" {
+		t.Errorf("expected type echo, got %v", echoMsg["type"])
 	}
 	if echoMsg["message"] != "hello" {
 		t.Errorf("expected message hello, got %v", echoMsg["message"])
@@ -163,7 +163,7 @@ func TestServer_VersionMismatch(t *testing.T) {
 	// Send message with wrong version
 	testMsg := map[string]interface{}{
 		"version": "2.0",
-		"type":    "test:echo",
+		"type":    "echo",
 	}
 	err = conn.WriteJSON(testMsg)
 	if err != nil {
@@ -229,7 +229,7 @@ func TestServer_MissingRequiredField(t *testing.T) {
 
 	// Send message without version
 	testMsg := map[string]interface{}{
-		"type":    "test:echo",
+		"type":    "echo",
 		"message": "test",
 	}
 	err = conn.WriteJSON(testMsg)
@@ -265,7 +265,7 @@ func TestServer_MissingRequiredField(t *testing.T) {
 	// Verify connection stays open - send a valid message
 	validMsg := map[string]interface{}{
 		"version": "1.0",
-		"type":    "test:echo",
+		"type":    "echo",
 		"message": "recovered",
 	}
 	err = conn.WriteJSON(validMsg)
@@ -348,7 +348,7 @@ func TestServer_InvalidJSON(t *testing.T) {
 	// Verify connection stays open - send a valid message
 	validMsg := map[string]interface{}{
 		"version": "1.0",
-		"type":    "test:echo",
+		"type":    "echo",
 		"message": "recovered",
 	}
 	err = conn.WriteJSON(validMsg)
@@ -1,27 +1,44 @@
 package relay
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 )
 
 // Mock implementations for unit testing
 
 type mockLogger struct {
 	logs []string
+	mu   sync.Mutex
 }
 
 func (m *mockLogger) Printf(format string, v ...interface{}) {
 	// Store logs for verification
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.logs = append(m.logs, format)
 }
 
+// testTime is the fixed instant returned by mockClock in tests
+var testTime = time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)
+
 type mockClock struct {
-	timestamp string
+	now  time.Time
+	mono time.Duration
+}
+
+func (m *mockClock) Now() time.Time {
+	return m.now
 }
 
-func (m *mockClock) Now() string {
-	return m.timestamp
+func (m *mockClock) Monotonic() time.Duration {
+	return m.mono
 }
 
 type mockIDGenerator struct {
@@ -37,7 +54,7 @@ type mockUpgrader struct {
 	error error
 }
 
-func (m *mockUpgrader) Upgrade(w interface{}, r interface{}, responseHeader interface{}) (WebSocketConn, error) {
+func (m *mockUpgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (WebSocketConn, error) {
 	return m.conn, m.error
 }
 
@@ -47,12 +64,25 @@ type mockWebSocketConn struct {
 	readError     error
 	writeError    error
 	closed        bool
+	readLimit     int64
+	readDeadline  time.Time
+	writeDeadline time.Time
+	controls      [][]byte // Control frames sent with WriteControl
+	pongHandler   func(appData string) error
 }
 
 func (m *mockWebSocketConn) WriteJSON(v interface{}) error {
 	if m.writeError != nil {
 		return m.writeError
 	}
+	if raw, ok := v.(json.RawMessage); ok {
+		// Decode pre-encoded frames like the wire would; the caller may reuse raw
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return err
+		}
+		v = decoded
+	}
 	m.written = append(m.written, v)
 	return nil
 }
@@ -66,15 +96,38 @@ func (m *mockWebSocketConn) Close() error {
 	return nil
 }
 
+func (m *mockWebSocketConn) SetReadLimit(limit int64) {
+	m.readLimit = limit
+}
+
+func (m *mockWebSocketConn) SetReadDeadline(t time.Time) error {
+	m.readDeadline = t
+	return nil
+}
+
+func (m *mockWebSocketConn) SetWriteDeadline(t time.Time) error {
+	m.writeDeadline = t
+	return nil
+}
+
+func (m *mockWebSocketConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	m.controls = append(m.controls, data)
+	return nil
+}
+
+func (m *mockWebSocketConn) SetPongHandler(h func(appData string) error) {
+	m.pongHandler = h
+}
+
 // Unit tests for server methods
 
 func TestAddTimestamp(t *testing.T) {
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	server := &Server{clock: clock}
 
 	msg := map[string]interface{}{
 		"version": "1.0",
-		"type":    "test:echo",
+		"type":    "echo",
 	}
 
 	server.addTimestamp(msg)
@@ -85,12 +138,12 @@ func TestAddTimestamp(t *testing.T) {
 }
 
 func TestAddTimestamp_PreservesExistingFields(t *testing.T) {
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	server := &Server{clock: clock}
 
 	msg := map[string]interface{}{
 		"version": "1.0",
-		"type":    "test:echo",
+		"type":    "echo",
 		"data":    "important data",
 	}
 
@@ -106,7 +159,7 @@ func TestAddTimestamp_PreservesExistingFields(t *testing.T) {
 
 func TestSendHandshake_Success(t *testing.T) {
 	logger := &mockLogger{}
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	conn := &mockWebSocketConn{}
 	server := &Server{
 		serverID: "test-server-123",
@@ -139,7 +192,7 @@ func TestSendHandshake_Success(t *testing.T) {
 
 func TestSendHandshake_WriteError(t *testing.T) {
 	logger := &mockLogger{}
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	conn := &mockWebSocketConn{
 		writeError: errors.New("write failed"),
 	}
@@ -265,14 +318,14 @@ func TestHandleValidationError_NonValidationError(t *testing.T) {
 
 func TestEchoMessage_Success(t *testing.T) {
 	logger := &mockLogger{}
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	conn := &mockWebSocketConn{}
 	server := &Server{
 		logger: logger,
 		clock:  clock,
 	}
 
-	rawMessage := []byte(`{"version":"1.0","type":"test:echo","message":"hello"}`)
+	rawMessage := []byte(`{"version":"1.0","type":"echo","message":"hello"}`)
 
 	err := server.echoMessage(conn, rawMessage)
 
@@ -299,7 +352,7 @@ func TestEchoMessage_Success(t *testing.T) {
 
 func TestEchoMessage_InvalidJSON(t *testing.T) {
 	logger := &mockLogger{}
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	conn := &mockWebSocketConn{}
 	server := &Server{
 		logger: logger,
@@ -327,14 +380,14 @@ func TestEchoMessage_InvalidJSON(t *testing.T) {
 
 func TestHandleMessage_ValidMessage(t *testing.T) {
 	logger := &mockLogger{}
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	conn := &mockWebSocketConn{}
 	server := &Server{
 		logger: logger,
 		clock:  clock,
 	}
 
-	rawMessage := []byte(`{"version":"1.0","type":"test:echo","message":"hello"}`)
+	rawMessage := []byte(`{"version":"1.0","type":"echo","message":"hello"}`)
 
 	shouldClose := server.handleMessage(conn, rawMessage)
 
@@ -348,9 +401,24 @@ func TestHandleMessage_ValidMessage(t *testing.T) {
 	}
 }
 
+func TestHandleMessage_AppliesNormalizer(t *testing.T) {
+	conn := &mockWebSocketConn{}
+	server := NewServer(&mockIDGenerator{}, &mockLogger{}, &mockClock{now: testTime}, &mockUpgrader{},
+		WithTextNormalizer(func(b []byte) []byte {
+			return bytes.ReplaceAll(b, []byte("e\u0301"), []byte("\u00e9"))
+		}))
+
+	server.handleMessage(conn, []byte("{\"version\":\"1.0\",\"type\":\"echo\",\"message\":\"cafe\u0301\"}"))
+
+	echo, ok := conn.written[0].(map[string]interface{})
+	if !ok || echo["message"] != "caf\u00e9" {
+		t.Errorf("expected normalized echo, got %+v", conn.written[0])
+	}
+}
+
 func TestHandleMessage_ValidationError(t *testing.T) {
 	logger := &mockLogger{}
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	conn := &mockWebSocketConn{}
 	server := &Server{
 		logger: logger,
@@ -358,7 +426,7 @@ func TestHandleMessage_ValidationError(t *testing.T) {
 	}
 
 	// Missing version field
-	rawMessage := []byte(`{"type":"test:echo","message":"hello"}`)
+	rawMessage := []byte(`{"type":"echo","message":"hello"}`)
 
 	shouldClose := server.handleMessage(conn, rawMessage)
 
@@ -383,7 +451,7 @@ func TestHandleMessage_ValidationError(t *testing.T) {
 
 func TestHandleMessage_VersionMismatch(t *testing.T) {
 	logger := &mockLogger{}
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	conn := &mockWebSocketConn{}
 	server := &Server{
 		logger: logger,
@@ -391,7 +459,7 @@ func TestHandleMessage_VersionMismatch(t *testing.T) {
 	}
 
 	// Wrong version - non-recoverable
-	rawMessage := []byte(`{"version":"2.0","type":"test:echo"}`)
+	rawMessage := []byte(`{"version":"2.0","type":"echo"}`)
 
 	shouldClose := server.handleMessage(conn, rawMessage)
 
@@ -408,7 +476,7 @@ func TestHandleMessage_VersionMismatch(t *testing.T) {
 func TestNewServer_UsesIDGenerator(t *testing.T) {
 	idGen := &mockIDGenerator{id: "test-server-123"}
 	logger := &mockLogger{}
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	upgrader := &mockUpgrader{}
 
 	server := NewServer(idGen, logger, clock, upgrader)
@@ -421,7 +489,7 @@ func TestNewServer_UsesIDGenerator(t *testing.T) {
 func TestNewServer_InjectsDependencies(t *testing.T) {
 	idGen := &mockIDGenerator{id: "test-id"}
 	logger := &mockLogger{}
-	clock := &mockClock{timestamp: "2025-10-23T12:00:00Z"}
+	clock := &mockClock{now: testTime}
 	upgrader := &mockUpgrader{}
 
 	server := NewServer(idGen, logger, clock, upgrader)
@@ -437,3 +505,73 @@ func TestNewServer_InjectsDependencies(t *testing.T) {
 		t.Error("expected upgrader to be set")
 	}
 }
+
+// panickingConn panics on read to simulate a bug in message handling
+type panickingConn struct {
+	mockWebSocketConn
+}
+
+func (m *panickingConn) ReadMessage() (int, []byte, error) {
+	panic("boom")
+}
+
+func TestHandleWebSocket_RecoversFromPanic(t *testing.T) {
+	conn := &panickingConn{}
+	metrics := NewCounterMetrics()
+	server := NewServer(
+		&mockIDGenerator{id: "test-server"},
+		&mockLogger{},
+		&mockClock{now: testTime},
+		&mockUpgrader{conn: conn},
+		WithMetrics(metrics),
+	)
+
+	// Must not propagate the panic to the caller
+	server.HandleWebSocket(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+	// Handshake followed by INTERNAL_ERROR
+	if len(conn.written) != 2 {
+		t.Fatalf("expected 2 messages written, got %d", len(conn.written))
+	}
+	errorMsg, ok := conn.written[1].(ErrorMessage)
+	if !ok {
+		t.Fatalf("expected ErrorMessage, got %T", conn.written[1])
+	}
+	if errorMsg.Error.Code != "INTERNAL_ERROR" {
+		t.Errorf("expected code INTERNAL_ERROR, got %s", errorMsg.Error.Code)
+	}
+	if errorMsg.Error.Recoverable {
+		t.Error("expected INTERNAL_ERROR to be non-recoverable")
+	}
+
+	if !conn.closed {
+		t.Error("expected connection to be closed after panic")
+	}
+	if got := metrics.Value(MetricConnectionPanics); got != 1 {
+		t.Errorf("expected panic counter 1, got %d", got)
+	}
+}
+
+func TestNewServer_DefaultsToNoOpMetrics(t *testing.T) {
+	server := NewServer(&mockIDGenerator{}, &mockLogger{}, &mockClock{}, &mockUpgrader{})
+
+	if _, ok := server.metrics.(*NoOpMetrics); !ok {
+		t.Errorf("expected NoOpMetrics by default, got %T", server.metrics)
+	}
+}
+
+func TestGorillaUpgrader_RejectsPlainRequest(t *testing.T) {
+	upgrader := NewGorillaUpgrader(func(r *http.Request) bool { return true })
+	rec := httptest.NewRecorder()
+
+	conn, err := upgrader.Upgrade(rec, httptest.NewRequest(http.MethodGet, "/ws", nil), nil)
+	if err == nil {
+		t.Fatal("expected an error upgrading a request without websocket headers")
+	}
+	if conn != nil {
+		t.Error("expected no connection")
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
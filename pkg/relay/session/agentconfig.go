@@ -0,0 +1,154 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// Bounds of AgentConfig values
+const (
+	maxTemperature        = 2.0
+	maxSystemPromptLength = 64 << 10
+)
+
+// ErrPayloadKeyUnavailable is returned when an agent would be started for a
+// session with EncryptedPayloads without its key, which the relay never keeps
+var ErrPayloadKeyUnavailable = errors.New("payload key unavailable for an end-to-end encrypted session")
+
+// AgentConfig configures the agent started for a session
+// Zero values leave the agent's own defaults. The config is fixed when the
+// session is created and applies to replacement agents too. ToolPolicy stays
+// in the relay, as does Kind, which picks the agent backend that is started;
+// the other settings are sent to the agent at initialize.
+type AgentConfig struct {
+	Kind         string   `json:"kind,omitempty"` // One of the relay's agent kinds (empty = default)
+	SystemPrompt string   `json:"systemPrompt,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty"`
+	MaxTokens    int      `json:"maxTokens,omitempty"`
+
+	// ToolPolicy is enforced by the relay on the agent's tool calls
+	// (nil = the manager's default, see WithDefaultToolPolicy)
+	ToolPolicy *ToolPolicy `json:"toolPolicy,omitempty"`
+
+	// EncryptedPayloads marks prompts and replies as sealed between client
+	// and agent (see pkg/e2e); the relay passes them on without reading them
+	EncryptedPayloads bool `json:"encryptedPayloads,omitempty"`
+}
+
+// IsZero reports whether the config sets nothing
+func (c AgentConfig) IsZero() bool {
+	return c.Kind == "" && c.SystemPrompt == "" && c.Model == "" && c.Temperature == nil && c.MaxTokens == 0 &&
+		c.ToolPolicy == nil && !c.EncryptedPayloads
+}
+
+// Validate checks the config's values are in range
+func (c AgentConfig) Validate() error {
+	if len(c.SystemPrompt) > maxSystemPromptLength {
+		return fmt.Errorf("systemPrompt is longer than %d bytes", maxSystemPromptLength)
+	}
+	if c.Model != strings.TrimSpace(c.Model) {
+		return fmt.Errorf("model cannot have surrounding whitespace")
+	}
+	if c.Kind != strings.TrimSpace(c.Kind) {
+		return fmt.Errorf("kind cannot have surrounding whitespace")
+	}
+	if c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > maxTemperature) {
+		return fmt.Errorf("temperature must be between 0 and %g", maxTemperature)
+	}
+	if c.MaxTokens < 0 {
+		return fmt.Errorf("maxTokens cannot be negative")
+	}
+	if c.ToolPolicy != nil {
+		return c.ToolPolicy.Validate()
+	}
+	return nil
+}
+
+// clone returns a copy sharing no pointers with c
+func (c AgentConfig) clone() AgentConfig {
+	if c.Temperature != nil {
+		t := *c.Temperature
+		c.Temperature = &t
+	}
+	c.ToolPolicy = c.ToolPolicy.clone()
+	return c
+}
+
+// ptr returns a copy of c, or nil if it is zero
+func (c AgentConfig) ptr() *AgentConfig {
+	if c.IsZero() {
+		return nil
+	}
+	out := c.clone()
+	return &out
+}
+
+// WithAgentConfig sets the system prompt and model settings of the session's agent
+func WithAgentConfig(cfg AgentConfig) CreateOption {
+	return func(s *Session) error {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		s.agent = cfg.clone()
+		return nil
+	}
+}
+
+// GetAgentConfig returns a copy of the session's agent config
+func (s *Session) GetAgentConfig() AgentConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.agent.clone()
+}
+
+// agentConfigKey is the context key under which ContextWithAgentConfig
+// stores the config
+type agentConfigKey struct{}
+
+// ContextWithAgentConfig returns a context telling an AgentStarter how to
+// configure the agent it starts
+func ContextWithAgentConfig(ctx context.Context, cfg AgentConfig) context.Context {
+	return context.WithValue(ctx, agentConfigKey{}, cfg)
+}
+
+// AgentConfigFromContext returns the config set by ContextWithAgentConfig
+// (zero = agent defaults)
+func AgentConfigFromContext(ctx context.Context) AgentConfig {
+	cfg, _ := ctx.Value(agentConfigKey{}).(AgentConfig)
+	return cfg
+}
+
+// GetAgentInfo returns a copy of what the session's agent reported about
+// itself and its capabilities at initialize (nil = unknown)
+func (s *Session) GetAgentInfo() *acp.InitializeResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return cloneAgentInfo(s.agentInfo)
+}
+
+// agentInfoOf returns a copy of what client's agent reported at initialize,
+// or nil if the client cannot tell
+func agentInfoOf(client ACPClient) *acp.InitializeResult {
+	reporter, ok := client.(AgentInfoReporter)
+	if !ok {
+		return nil
+	}
+	return cloneAgentInfo(reporter.Info())
+}
+
+// cloneAgentInfo returns a copy of info sharing no slices with it (nil-safe)
+func cloneAgentInfo(info *acp.InitializeResult) *acp.InitializeResult {
+	if info == nil {
+		return nil
+	}
+	out := *info
+	out.Capabilities.Tools = slices.Clone(info.Capabilities.Tools)
+	out.Capabilities.Models = slices.Clone(info.Capabilities.Models)
+	return &out
+}
@@ -0,0 +1,118 @@
+package session
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+func TestManager_Create_WithAgentConfig(t *testing.T) {
+	ctx := context.Background()
+	manager, idGen, _, _, _ := setupManager()
+
+	temperature := 0.3
+	cfg := AgentConfig{SystemPrompt: "Only touch auth code.", Model: "claude-sonnet", Temperature: &temperature, MaxTokens: 4096}
+	session, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithAgentConfig(cfg))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Caller's temperature is copied, not retained
+	temperature = 1.5
+	got := session.GetAgentConfig()
+	if got.SystemPrompt != cfg.SystemPrompt || got.Model != "claude-sonnet" || got.MaxTokens != 4096 || *got.Temperature != 0.3 {
+		t.Errorf("unexpected agent config: %+v", got)
+	}
+
+	bad := -0.1
+	tests := []struct {
+		name   string
+		cfg    AgentConfig
+		errSub string
+	}{
+		{"negative temperature", AgentConfig{Temperature: &bad}, "temperature"},
+		{"negative max tokens", AgentConfig{MaxTokens: -1}, "maxTokens"},
+		{"padded model", AgentConfig{Model: " claude "}, "model"},
+		{"huge system prompt", AgentConfig{SystemPrompt: strings.Repeat("x", maxSystemPromptLength+1)}, "systemPrompt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idGen.nextID = "other"
+			_, err := manager.Create(ctx, "db", &mockWebSocket{}, WithAgentConfig(tt.cfg))
+			if err == nil || !strings.Contains(err.Error(), tt.errSub) {
+				t.Errorf("expected error containing %q, got %v", tt.errSub, err)
+			}
+		})
+	}
+}
+
+func TestManager_ReplaceAgent_KeepsAgentConfig(t *testing.T) {
+	ctx := context.Background()
+	var started AgentConfig
+	manager := setupReplaceManager(func(ctx context.Context, role, worktreeDir string) (ACPClient, error) {
+		started = AgentConfigFromContext(ctx)
+		return &closeCountingACPClient{}, nil
+	})
+	sess, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithAgentConfig(AgentConfig{Model: "claude-opus"}))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, sess.GetID()); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, sess.GetID(), "/tmp/worktree", &closeCountingACPClient{}); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+
+	if err := manager.ReplaceAgent(ctx, sess.GetID(), ""); err != nil {
+		t.Fatalf("ReplaceAgent failed: %v", err)
+	}
+	if started.Model != "claude-opus" {
+		t.Errorf("expected the replacement started with the session's config, got %+v", started)
+	}
+}
+
+// reportingACPClient reports fixed initialize results
+type reportingACPClient struct {
+	closeCountingACPClient
+	info *acp.InitializeResult
+}
+
+func (c *reportingACPClient) Info() *acp.InitializeResult { return c.info }
+
+func TestManager_AgentInfo(t *testing.T) {
+	ctx := context.Background()
+	replacement := &reportingACPClient{info: &acp.InitializeResult{AgentName: "next",
+		Capabilities: acp.AgentCapabilities{Models: []string{"claude-opus"}}}}
+	manager := setupReplaceManager(func(context.Context, string, string) (ACPClient, error) {
+		return replacement, nil
+	})
+	first := &reportingACPClient{info: &acp.InitializeResult{AgentName: "first", ProtocolVersion: 1,
+		Capabilities: acp.AgentCapabilities{Tools: []string{"bash", "edit"}, Streaming: true}}}
+	sess := setupActiveSession(t, manager, first)
+
+	info := sess.GetAgentInfo()
+	if info == nil || info.AgentName != "first" || len(info.Capabilities.Tools) != 2 || !info.Capabilities.Streaming {
+		t.Fatalf("expected the attached agent's info, got %+v", info)
+	}
+	// Copies are independent of the agent's report
+	info.Capabilities.Tools[0] = "rm"
+	if sess.GetAgentInfo().Capabilities.Tools[0] != "bash" {
+		t.Error("expected GetAgentInfo to return a copy")
+	}
+
+	if err := manager.ReplaceAgent(ctx, sess.GetID(), ""); err != nil {
+		t.Fatalf("ReplaceAgent failed: %v", err)
+	}
+	if info := sess.GetAgentInfo(); info == nil || info.AgentName != "next" || info.Capabilities.Tools != nil {
+		t.Errorf("expected the replacement's info, got %+v", info)
+	}
+
+	// Clients that cannot report leave the info unknown
+	plain := setupReplaceManager(nil)
+	if info := setupActiveSession(t, plain, &closeCountingACPClient{}).GetAgentInfo(); info != nil {
+		t.Errorf("expected no info from a client without Info, got %+v", info)
+	}
+}
@@ -0,0 +1,472 @@
+package session
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveFormatVersion is the archive layout written by Export
+const ArchiveFormatVersion = 1
+
+// Archive entry names
+const (
+	archiveManifest  = "session.json"
+	archiveHistory   = "history.jsonl"
+	archiveWorkspace = "workspace/" // Prefix of workspace files
+)
+
+// maxArchiveMetadata bounds the manifest and history Import reads into memory
+const maxArchiveMetadata = 64 << 20
+
+// ErrInvalidArchive is returned by Import for archives it cannot restore
+var ErrInvalidArchive = errors.New("invalid session archive")
+
+// LabelImportedFrom is set on imported sessions to the exported session's ID
+const LabelImportedFrom = "importedFrom"
+
+// ArchiveManifest describes the session in an archive
+type ArchiveManifest struct {
+	FormatVersion int               `json:"formatVersion"`
+	ExportedAt    time.Time         `json:"exportedAt"`
+	SessionID     string            `json:"sessionId"`
+	AgentID       string            `json:"agentId"`
+	Name          string            `json:"name,omitempty"`
+	OwnerID       string            `json:"ownerId,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Agent         *AgentConfig      `json:"agent,omitempty"`
+	State         SessionState      `json:"state"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	MessageCount  int               `json:"messageCount"`
+	Workspace     bool              `json:"workspace"` // Whether workspace files are included
+}
+
+// archivedEntry is one line of history.jsonl
+type archivedEntry struct {
+	Time    time.Time `json:"time"`
+	Speaker Speaker   `json:"speaker"`
+	UserID  string    `json:"userId,omitempty"` // Who sent a prompt in a shared session
+	Content string    `json:"content"`
+}
+
+// Export writes a gzip-compressed tar archive of a session to w: its
+// metadata, its conversation history (if history is enabled) and the files
+// of its workspace, for Import on another relay
+// With WithArchiveCipher the archive is encrypted before it reaches w.
+// The agent itself is not exported. The workspace is read while the agent
+// may still be changing it, so pause the session first for a consistent copy.
+func (m *Manager) Export(ctx context.Context, sessionID string, w io.Writer) error {
+	session := m.store.Get(sessionID)
+	if session == nil {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	worktree := session.GetWorktreeDir()
+	manifest := ArchiveManifest{
+		FormatVersion: ArchiveFormatVersion,
+		ExportedAt:    m.clock.Now(),
+		SessionID:     sessionID,
+		AgentID:       session.AgentID,
+		Name:          session.GetName(),
+		OwnerID:       session.GetOwnerID(),
+		Labels:        session.GetLabels(),
+		Agent:         session.GetAgentConfig().ptr(),
+		State:         session.GetState(),
+		CreatedAt:     session.GetCreatedAt(),
+		MessageCount:  session.GetMessageCount(),
+		Workspace:     worktree != "",
+	}
+
+	out := io.WriteCloser(nopWriteCloser{w})
+	if m.cipher != nil {
+		enc, err := m.cipher.EncryptWriter(w)
+		if err != nil {
+			return fmt.Errorf("export %s: %w", sessionID, err)
+		}
+		out = enc
+	}
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	if err := m.writeArchive(ctx, tw, manifest, worktree); err != nil {
+		return fmt.Errorf("export %s: %w", sessionID, err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("export %s: %w", sessionID, err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("export %s: %w", sessionID, err)
+	}
+	return out.Close()
+}
+
+// nopWriteCloser adds a no-op Close to a writer
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// writeArchive writes the manifest, history and workspace entries
+func (m *Manager) writeArchive(ctx context.Context, tw *tar.Writer, manifest ArchiveManifest, worktree string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeArchiveFile(tw, archiveManifest, data, manifest.ExportedAt); err != nil {
+		return err
+	}
+
+	history, err := m.exportHistory(manifest.SessionID)
+	if err != nil {
+		return err
+	}
+	if err := writeArchiveFile(tw, archiveHistory, history, manifest.ExportedAt); err != nil {
+		return err
+	}
+
+	if worktree == "" {
+		return nil
+	}
+	return archiveTree(ctx, tw, worktree)
+}
+
+// exportHistory encodes a session's history oldest first, one entry per line
+func (m *Manager) exportHistory(sessionID string) ([]byte, error) {
+	if m.history == nil {
+		return nil, nil
+	}
+	matches, err := m.history.Search(HistoryQuery{SessionID: sessionID})
+	if err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := len(matches) - 1; i >= 0; i-- { // Search returns newest first
+		entry := matches[i].Entry
+		if err := enc.Encode(archivedEntry{Time: entry.Time, Speaker: entry.Speaker, UserID: entry.UserID, Content: entry.Content}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// archiveTree adds the directories, regular files and symlinks under root
+// as workspace entries; other special files are skipped
+func archiveTree(ctx context.Context, tw *tar.Writer, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		switch {
+		case d.IsDir(), d.Type().IsRegular():
+		case d.Type()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = archiveWorkspace + filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return copyFileTo(tw, path)
+	})
+}
+
+func copyFileTo(w io.Writer, path string) error {
+	// #nosec G304 -- path is inside the workspace being exported
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Import recreates a session from an archive written by Export
+// The session gets a new ID and starts CREATED without an agent; the caller
+// starts one. Workspace files are extracted into workspace, which must not
+// exist yet, and history is recorded again under the new ID. A nil ws leaves
+// the session detached until a client reattaches. opts are applied after the
+// archived name, owner, labels and agent config, so they can override them;
+// the session is also labelled LabelImportedFrom with the exported session's ID.
+func (m *Manager) Import(ctx context.Context, r io.Reader, ws WebSocketConn, workspace string, opts ...CreateOption) (*Session, error) {
+	if workspace == "" || !filepath.IsAbs(workspace) {
+		return nil, fmt.Errorf("workspace must be an absolute path: %q", workspace)
+	}
+	if err := os.Mkdir(workspace, 0o750); err != nil {
+		return nil, fmt.Errorf("import workspace: %w", err)
+	}
+
+	manifest, history, err := m.readArchive(ctx, r, workspace)
+	if err != nil {
+		_ = os.RemoveAll(workspace)
+		return nil, err
+	}
+
+	labels := manifest.Labels
+	if labels == nil {
+		labels = make(map[string]string, 1)
+	}
+	labels[LabelImportedFrom] = manifest.SessionID
+	createOpts := []CreateOption{WithLabels(labels)}
+	if manifest.OwnerID != "" {
+		createOpts = append(createOpts, WithOwner(manifest.OwnerID))
+	}
+	if manifest.Name != "" {
+		createOpts = append(createOpts, WithName(manifest.Name))
+	}
+	if manifest.Agent != nil {
+		createOpts = append(createOpts, WithAgentConfig(*manifest.Agent))
+	}
+
+	session, err := m.create(ctx, manifest.AgentID, ws, append(createOpts, opts...)...)
+	if err != nil {
+		_ = os.RemoveAll(workspace)
+		return nil, err
+	}
+	for _, entry := range history {
+		if m.history == nil {
+			break
+		}
+		if err := m.history.Append(HistoryEntry{
+			Time:      entry.Time,
+			SessionID: session.GetID(),
+			AgentID:   session.AgentID,
+			OwnerID:   session.GetOwnerID(),
+			UserID:    entry.UserID,
+			Speaker:   entry.Speaker,
+			Content:   entry.Content,
+		}); err != nil {
+			m.logger.Printf("Failed to import history: session=%s err=%v", session.GetID(), err)
+			break
+		}
+	}
+
+	m.logger.Printf("Session imported: id=%s from=%s", session.GetID(), manifest.SessionID)
+	return session, nil
+}
+
+// decryptArchive returns the plaintext of an encrypted archive, or r itself
+// for a plaintext one
+func (m *Manager) decryptArchive(r io.Reader) (io.Reader, error) {
+	r, encrypted := peekEncrypted(r)
+	if !encrypted {
+		return r, nil
+	}
+	if m.cipher == nil {
+		return nil, fmt.Errorf("%w: archive is encrypted but no archive key is configured", ErrInvalidArchive)
+	}
+	plain, err := m.cipher.DecryptReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	return plain, nil
+}
+
+// readArchive reads the manifest and history and extracts workspace files
+func (m *Manager) readArchive(ctx context.Context, r io.Reader, workspace string) (*ArchiveManifest, []archivedEntry, error) {
+	r, err := m.decryptArchive(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	var manifest *ArchiveManifest
+	var history []archivedEntry
+	ex := &extractor{root: workspace, links: make(map[string]bool)}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case hdr.Name == archiveManifest:
+			manifest = &ArchiveManifest{}
+			err = json.NewDecoder(io.LimitReader(tr, maxArchiveMetadata)).Decode(manifest)
+		case hdr.Name == archiveHistory:
+			history, err = readHistory(io.LimitReader(tr, maxArchiveMetadata))
+		case strings.HasPrefix(hdr.Name, archiveWorkspace):
+			err = ex.extract(strings.TrimPrefix(hdr.Name, archiveWorkspace), hdr, tr)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, hdr.Name, err)
+		}
+	}
+
+	switch {
+	case manifest == nil:
+		return nil, nil, fmt.Errorf("%w: missing %s", ErrInvalidArchive, archiveManifest)
+	case manifest.FormatVersion != ArchiveFormatVersion:
+		return nil, nil, fmt.Errorf("%w: format version %d not supported", ErrInvalidArchive, manifest.FormatVersion)
+	case manifest.AgentID == "":
+		return nil, nil, fmt.Errorf("%w: manifest has no agentId", ErrInvalidArchive)
+	}
+	return manifest, history, nil
+}
+
+func readHistory(r io.Reader) ([]archivedEntry, error) {
+	var entries []archivedEntry
+	dec := json.NewDecoder(r)
+	for {
+		var entry archivedEntry
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// extractor writes workspace entries under root
+// Paths and symlink targets must stay inside root, and no entry may be
+// written through a symlink extracted earlier, so an archive cannot write or
+// point outside the workspace.
+type extractor struct {
+	root  string
+	links map[string]bool // Symlinks created so far, by cleaned relative path
+}
+
+// throughLink reports whether any parent directory of name is a symlink
+func (e *extractor) throughLink(name string) bool {
+	for dir := filepath.Dir(name); dir != "."; dir = filepath.Dir(dir) {
+		if e.links[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// linkStaysInside reports whether a symlink at name pointing to link resolves
+// inside root
+// The target is walked component by component before cleaning, since a
+// cleaned "a/.." hides that a may itself be a symlink out of the directory;
+// any component that is a symlink extracted earlier is refused.
+func (e *extractor) linkStaysInside(name, link string) bool {
+	if filepath.IsAbs(link) {
+		return false
+	}
+	var path []string
+	if dir := filepath.Dir(name); dir != "." {
+		path = strings.Split(dir, string(filepath.Separator))
+	}
+	for _, part := range strings.Split(link, string(filepath.Separator)) {
+		switch part {
+		case "", ".":
+		case "..":
+			if len(path) == 0 {
+				return false
+			}
+			path = path[:len(path)-1]
+		default:
+			path = append(path, part)
+			if e.links[filepath.Join(path...)] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// extract writes one workspace entry
+func (e *extractor) extract(name string, hdr *tar.Header, r io.Reader) error {
+	name = filepath.Clean(filepath.FromSlash(strings.TrimSuffix(name, "/")))
+	if name == "." {
+		return nil
+	}
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("path escapes the workspace")
+	}
+	if e.throughLink(name) {
+		return fmt.Errorf("path passes through a symlink")
+	}
+	target := filepath.Join(e.root, name)
+	perm := hdr.FileInfo().Mode().Perm()
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		// #nosec G301 -- Restores the exported directory's permissions
+		return os.MkdirAll(target, perm|0o700)
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+			return err
+		}
+		// #nosec G304 -- target was checked to be inside the workspace
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if err != nil {
+			return err
+		}
+		if _, err := io.CopyN(out, r, hdr.Size); err != nil {
+			_ = out.Close()
+			return err
+		}
+		return out.Close()
+	case tar.TypeSymlink:
+		link := filepath.FromSlash(hdr.Linkname)
+		if !e.linkStaysInside(name, link) {
+			return fmt.Errorf("symlink target escapes the workspace or passes through a symlink")
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+			return err
+		}
+		e.links[name] = true
+		return os.Symlink(link, target)
+	}
+	return nil // Other entry types are not exported; ignore them
+}
@@ -0,0 +1,221 @@
+package session
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupExportable starts an active session "src" with a workspace on disk and
+// one exchange of history
+func setupExportable(t *testing.T) (*Manager, string) {
+	t.Helper()
+	ctx := context.Background()
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "src"},
+		&mockClock{now: time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)}, &mockCleaner{}, &mockLogger{},
+		WithHistory(NewMemoryHistory(100)))
+
+	worktree := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(worktree, "pkg"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktree, "pkg", "main.go"), []byte("package main"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("pkg/main.go", filepath.Join(worktree, "entry.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithName("login"), WithOwner("alice"),
+		WithLabels(map[string]string{"ticket": "BUG-1"}), WithAgentConfig(AgentConfig{Model: "claude-sonnet"})); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, "src"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.AttachAgent(ctx, "src", worktree, &mockACPClient{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.SendMessage(ctx, "src", "add a login form"); err != nil {
+		t.Fatal(err)
+	}
+	return manager, worktree
+}
+
+func TestManager_ExportImport(t *testing.T) {
+	ctx := context.Background()
+	source, _ := setupExportable(t)
+
+	var archive bytes.Buffer
+	if err := source.Export(ctx, "src", &archive); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	target := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "dst"}, &mockClock{}, &mockCleaner{}, &mockLogger{},
+		WithHistory(NewMemoryHistory(100)))
+	workspace := filepath.Join(t.TempDir(), "imported")
+	session, err := target.Import(ctx, &archive, nil, workspace)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if session.GetID() != "dst" || session.AgentID != "auth" || session.GetState() != StateCreated {
+		t.Errorf("unexpected session: id=%s agent=%s state=%s", session.GetID(), session.AgentID, session.GetState())
+	}
+	if session.GetName() != "login" || session.GetOwnerID() != "alice" {
+		t.Errorf("expected name and owner restored, got %q/%q", session.GetName(), session.GetOwnerID())
+	}
+	if model := session.GetAgentConfig().Model; model != "claude-sonnet" {
+		t.Errorf("expected agent config restored, got model %q", model)
+	}
+	labels := session.GetLabels()
+	if labels["ticket"] != "BUG-1" || labels[LabelImportedFrom] != "src" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+	if session.GetHandle().WebSocket != nil {
+		t.Error("expected imported session to be detached")
+	}
+
+	data, err := os.ReadFile(filepath.Join(workspace, "pkg", "main.go")) // #nosec G304 -- test path
+	if err != nil || string(data) != "package main" {
+		t.Errorf("expected workspace file restored, got %q (%v)", data, err)
+	}
+	if link, err := os.Readlink(filepath.Join(workspace, "entry.go")); err != nil || link != "pkg/main.go" {
+		t.Errorf("expected symlink restored, got %q (%v)", link, err)
+	}
+
+	matches, err := target.SearchHistory(HistoryQuery{SessionID: "dst"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[1].Entry.Content != "add a login form" || matches[1].Entry.Speaker != SpeakerUser {
+		t.Errorf("expected history restored under the new ID, got %+v", matches)
+	}
+}
+
+func TestManager_ExportImport_Encrypted(t *testing.T) {
+	ctx := context.Background()
+	source, _ := setupExportable(t)
+	source.cipher = testCipher(t, 1)
+
+	var archive bytes.Buffer
+	if err := source.Export(ctx, "src", &archive); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !IsEncrypted(archive.Bytes()) {
+		t.Fatal("expected an encrypted archive")
+	}
+
+	plain := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "dst"}, &mockClock{}, &mockCleaner{}, &mockLogger{})
+	if _, err := plain.Import(ctx, bytes.NewReader(archive.Bytes()), nil, filepath.Join(t.TempDir(), "w")); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("expected ErrInvalidArchive without a key, got %v", err)
+	}
+
+	target := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "dst"}, &mockClock{}, &mockCleaner{}, &mockLogger{},
+		WithHistory(NewMemoryHistory(100)), WithArchiveCipher(testCipher(t, 1)))
+	workspace := filepath.Join(t.TempDir(), "imported")
+	if _, err := target.Import(ctx, &archive, nil, workspace); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(workspace, "pkg", "main.go")); err != nil || string(data) != "package main" {
+		t.Errorf("workspace not restored: %q %v", data, err)
+	}
+	if matches, err := target.SearchHistory(HistoryQuery{SessionID: "dst"}); err != nil || len(matches) == 0 {
+		t.Errorf("expected history to be restored, got %d entries: %v", len(matches), err)
+	}
+}
+
+func TestManager_Export_UnknownSession(t *testing.T) {
+	manager, _, _, _, _ := setupManager()
+	if err := manager.Export(context.Background(), "missing", &bytes.Buffer{}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+// buildArchive writes a tar.gz with the given entries
+func buildArchive(t *testing.T, entries ...*tar.Header) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	manifest := []byte(`{"formatVersion":1,"sessionId":"src","agentId":"auth"}`)
+	if err := writeArchiveFile(tw, archiveManifest, manifest, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, hdr := range entries {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write(bytes.Repeat([]byte("x"), int(hdr.Size))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestManager_Import_RejectsEscapingEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []*tar.Header
+	}{
+		{"parent path", []*tar.Header{{Name: "workspace/../evil", Typeflag: tar.TypeReg, Mode: 0o600, Size: 1}}},
+		{"absolute symlink", []*tar.Header{{Name: "workspace/etc", Typeflag: tar.TypeSymlink, Linkname: "/etc"}}},
+		{"relative symlink out", []*tar.Header{{Name: "workspace/up", Typeflag: tar.TypeSymlink, Linkname: "../.."}}},
+		{"through symlink", []*tar.Header{
+			{Name: "workspace/a", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "workspace/a/l", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		}},
+		{"symlink chain out", []*tar.Header{
+			{Name: "workspace/a", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "workspace/s", Typeflag: tar.TypeSymlink, Linkname: "a/.."},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, _, _, _, _ := setupManager()
+			parent := t.TempDir()
+			workspace := filepath.Join(parent, "ws")
+
+			_, err := manager.Import(context.Background(), buildArchive(t, tt.entries...), nil, workspace)
+			if !errors.Is(err, ErrInvalidArchive) {
+				t.Fatalf("expected ErrInvalidArchive, got %v", err)
+			}
+			if _, err := os.Stat(workspace); !errors.Is(err, os.ErrNotExist) {
+				t.Error("expected partial workspace to be removed")
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "evil")); err == nil {
+				t.Error("expected nothing written outside the workspace")
+			}
+			if manager.Count() != 0 {
+				t.Errorf("expected no session created, got %d", manager.Count())
+			}
+		})
+	}
+}
+
+func TestManager_Import_ExistingWorkspace(t *testing.T) {
+	manager, _, _, _, _ := setupManager()
+	existing := t.TempDir()
+
+	if _, err := manager.Import(context.Background(), buildArchive(t), nil, existing); err == nil {
+		t.Fatal("expected importing into an existing directory to fail")
+	}
+	if _, err := os.Stat(existing); err != nil {
+		t.Errorf("expected existing directory to be left alone, got %v", err)
+	}
+}
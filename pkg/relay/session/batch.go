@@ -0,0 +1,71 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// ErrBatchSkipped is returned for batch prompts that were not sent because an
+// earlier prompt to the same session failed
+var ErrBatchSkipped = errors.New("skipped after an earlier prompt failed")
+
+// AgentPrompt is one prompt of a batch
+type AgentPrompt struct {
+	SessionID string // Optional; defaults to the session passed to SendBatch
+	Content   string
+}
+
+// BatchResult is the outcome of one prompt of a batch
+type BatchResult struct {
+	Reply     *acp.AgentMessage
+	Err       error
+	SessionID string
+}
+
+// SendBatch sends prompts to sessionID, or to each prompt's own session, and
+// returns the results in prompt order
+// Prompts to one session are sent one after another in batch order, since
+// later prompts usually build on earlier replies; once one fails, the rest
+// for that session fail with ErrBatchSkipped. Different sessions are
+// prompted concurrently. Each prompt goes through SendMessage, so
+// middleware, history and concurrency limits apply as usual.
+func (m *Manager) SendBatch(ctx context.Context, sessionID string, prompts []AgentPrompt) []BatchResult {
+	results := make([]BatchResult, len(prompts))
+	lanes := make(map[string][]int) // Session → indexes of its prompts, in batch order
+	for i, prompt := range prompts {
+		target := prompt.SessionID
+		if target == "" {
+			target = sessionID
+		}
+		results[i].SessionID = target
+		lanes[target] = append(lanes[target], i)
+	}
+
+	var wg sync.WaitGroup
+	for _, indexes := range lanes {
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+			m.sendLane(ctx, prompts, indexes, results)
+		}(indexes)
+	}
+	wg.Wait()
+	return results
+}
+
+// sendLane sends one session's prompts in order, filling in their results
+func (m *Manager) sendLane(ctx context.Context, prompts []AgentPrompt, indexes []int, results []BatchResult) {
+	var failed error
+	for _, i := range indexes {
+		if failed != nil {
+			results[i].Err = fmt.Errorf("%w: %v", ErrBatchSkipped, failed)
+			continue
+		}
+		results[i].Reply, results[i].Err = m.SendMessage(ctx, results[i].SessionID, prompts[i].Content)
+		failed = results[i].Err
+	}
+}
@@ -0,0 +1,109 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// scriptedACPClient records prompts and fails the ones named "fail"
+type scriptedACPClient struct {
+	prompts []string
+}
+
+func (m *scriptedACPClient) SendMessage(content string) (*acp.AgentMessage, error) {
+	m.prompts = append(m.prompts, content)
+	if content == "fail" {
+		return nil, errors.New("agent crashed")
+	}
+	return &acp.AgentMessage{Type: "text", Content: "echo: " + content}, nil
+}
+
+func (m *scriptedACPClient) Close() error { return nil }
+
+// setupBatchSessions starts active sessions "s1" (role a) and "s2" (role b)
+func setupBatchSessions(t *testing.T) (*Manager, *scriptedACPClient, *scriptedACPClient) {
+	t.Helper()
+	manager, idGen, _, _, _ := setupManager()
+	ctx := context.Background()
+	clients := []*scriptedACPClient{{}, {}}
+	for i, id := range []string{"s1", "s2"} {
+		idGen.nextID = id
+		if _, err := manager.Create(ctx, string(rune('a'+i)), &mockWebSocket{}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := manager.BeginSpawn(ctx, id); err != nil {
+			t.Fatalf("BeginSpawn failed: %v", err)
+		}
+		if err := manager.AttachAgent(ctx, id, "/tmp/worktree", clients[i]); err != nil {
+			t.Fatalf("AttachAgent failed: %v", err)
+		}
+	}
+	return manager, clients[0], clients[1]
+}
+
+func TestManager_SendBatch(t *testing.T) {
+	manager, first, second := setupBatchSessions(t)
+
+	results := manager.SendBatch(context.Background(), "s1", []AgentPrompt{
+		{Content: "one"},
+		{SessionID: "s2", Content: "review"},
+		{Content: "two"},
+	})
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for i, want := range []struct{ session, reply string }{{"s1", "echo: one"}, {"s2", "echo: review"}, {"s1", "echo: two"}} {
+		if results[i].Err != nil {
+			t.Fatalf("result %d: unexpected error %v", i, results[i].Err)
+		}
+		if results[i].SessionID != want.session || results[i].Reply.Content != want.reply {
+			t.Errorf("result %d: expected %s/%q, got %s/%q", i, want.session, want.reply, results[i].SessionID, results[i].Reply.Content)
+		}
+	}
+	if len(first.prompts) != 2 || first.prompts[0] != "one" || first.prompts[1] != "two" {
+		t.Errorf("expected s1 prompts in batch order, got %v", first.prompts)
+	}
+	if len(second.prompts) != 1 {
+		t.Errorf("expected one prompt to s2, got %v", second.prompts)
+	}
+	if count := manager.Get("s1").GetMessageCount(); count != 2 {
+		t.Errorf("expected s1 message count 2, got %d", count)
+	}
+}
+
+func TestManager_SendBatch_SkipsAfterFailure(t *testing.T) {
+	manager, first, second := setupBatchSessions(t)
+
+	results := manager.SendBatch(context.Background(), "s1", []AgentPrompt{
+		{Content: "fail"},
+		{Content: "after"},
+		{SessionID: "s2", Content: "independent"},
+	})
+
+	if results[0].Err == nil {
+		t.Fatal("expected first prompt to fail")
+	}
+	if !errors.Is(results[1].Err, ErrBatchSkipped) {
+		t.Errorf("expected ErrBatchSkipped, got %v", results[1].Err)
+	}
+	if len(first.prompts) != 1 {
+		t.Errorf("expected skipped prompt not to reach the agent, got %v", first.prompts)
+	}
+	if results[2].Err != nil || len(second.prompts) != 1 {
+		t.Errorf("expected other session unaffected, got err=%v prompts=%v", results[2].Err, second.prompts)
+	}
+}
+
+func TestManager_SendBatch_UnknownSession(t *testing.T) {
+	manager, _, _ := setupBatchSessions(t)
+
+	results := manager.SendBatch(context.Background(), "missing", []AgentPrompt{{Content: "hi"}})
+
+	if !errors.Is(results[0].Err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", results[0].Err)
+	}
+}
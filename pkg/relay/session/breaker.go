@@ -0,0 +1,213 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// ErrCircuitOpen is returned when a session's agent is failing and traffic is
+// being shed until its cooldown expires
+var ErrCircuitOpen = errors.New("agent circuit open")
+
+// BreakerState is the circuit state of one session's agent
+type BreakerState string
+
+const (
+	// BreakerClosed lets traffic through (agent healthy)
+	BreakerClosed BreakerState = "CLOSED"
+
+	// BreakerOpen rejects traffic until the cooldown expires (agent FAILED)
+	BreakerOpen BreakerState = "OPEN"
+
+	// BreakerHalfOpen lets a single probe through to decide recovery
+	BreakerHalfOpen BreakerState = "HALF_OPEN"
+)
+
+// BreakerEvent describes a circuit state change for one session's agent
+type BreakerEvent struct {
+	Time      time.Time
+	Err       error // Failure that caused the change (nil when closing)
+	SessionID string
+	AgentID   string
+	From      BreakerState
+	To        BreakerState
+	Failures  int // Consecutive failures observed
+}
+
+// circuit is the per-session breaker state (protected by CircuitBreaker.mu)
+type circuit struct {
+	state    BreakerState
+	failures int
+	openedAt time.Duration // Clock.Monotonic when the circuit opened
+	probing  bool          // A half-open probe is in flight
+}
+
+// CircuitBreaker stops sending traffic to agents that fail repeatedly
+// After threshold consecutive failures the circuit opens and requests fail
+// fast with ErrCircuitOpen. Once cooldown has elapsed (on the monotonic clock)
+// a single half-open probe is let through: success closes the circuit, failure
+// reopens it for another cooldown.
+type CircuitBreaker struct {
+	clock     Clock
+	onChange  func(BreakerEvent)
+	circuits  map[string]*circuit
+	threshold int
+	cooldown  time.Duration
+	mu        sync.Mutex
+}
+
+// NewCircuitBreaker creates a breaker that opens after threshold consecutive failures
+// onChange, if non-nil, is called after every state change without locks held
+func NewCircuitBreaker(threshold int, cooldown time.Duration, clock Clock, onChange func(BreakerEvent)) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	if clock == nil {
+		panic("clock cannot be nil")
+	}
+	return &CircuitBreaker{
+		clock:     clock,
+		onChange:  onChange,
+		circuits:  make(map[string]*circuit),
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// State returns the circuit state for a session (CLOSED if never seen)
+func (b *CircuitBreaker) State(sessionID string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[sessionID]; ok {
+		return c.state
+	}
+	return BreakerClosed
+}
+
+// Forget drops the circuit for a session, e.g. after it is cleaned up
+func (b *CircuitBreaker) Forget(sessionID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.circuits, sessionID)
+}
+
+// HandleLifecycle forgets circuits of cleaned-up sessions
+// Subscribe it to Manager.Events so the breaker doesn't grow without bound
+func (b *CircuitBreaker) HandleLifecycle(event LifecycleEvent) {
+	if event.To == StateCleaned {
+		b.Forget(event.SessionID)
+	}
+}
+
+// Middleware returns the breaker as agent request middleware
+func (b *CircuitBreaker) Middleware() Middleware {
+	return func(next SendFunc) SendFunc {
+		return func(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+			if err := b.allow(req); err != nil {
+				return nil, err
+			}
+			msg, err := next(ctx, req)
+			b.record(req, err, ctx.Err() != nil)
+			return msg, err
+		}
+	}
+}
+
+// allow admits the request or fails fast while the circuit is open
+func (b *CircuitBreaker) allow(req AgentRequest) error {
+	var event *BreakerEvent
+	err := func() error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		c := b.circuit(req.SessionID)
+		switch c.state {
+		case BreakerOpen:
+			if b.clock.Monotonic()-c.openedAt < b.cooldown {
+				return fmt.Errorf("%w: session=%s", ErrCircuitOpen, req.SessionID)
+			}
+			event = b.setState(c, req, BreakerHalfOpen, nil)
+			c.probing = true
+		case BreakerHalfOpen:
+			if c.probing {
+				return fmt.Errorf("%w: session=%s (probe in flight)", ErrCircuitOpen, req.SessionID)
+			}
+			c.probing = true
+		}
+		return nil
+	}()
+	b.notify(event)
+	return err
+}
+
+// record updates the circuit with the outcome of an admitted request
+// Permanent session errors and caller cancellations say nothing about agent
+// health and are not counted
+func (b *CircuitBreaker) record(req AgentRequest, err error, cancelled bool) {
+	var event *BreakerEvent
+	func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		c := b.circuit(req.SessionID)
+		wasProbe := c.probing
+		c.probing = false
+
+		if err == nil {
+			c.failures = 0
+			if c.state != BreakerClosed {
+				event = b.setState(c, req, BreakerClosed, nil)
+			}
+			return
+		}
+		if cancelled || isPermanentSendError(err) {
+			return
+		}
+
+		c.failures++
+		if wasProbe || c.failures >= b.threshold {
+			c.openedAt = b.clock.Monotonic()
+			if c.state != BreakerOpen {
+				event = b.setState(c, req, BreakerOpen, err)
+			}
+		}
+	}()
+	b.notify(event)
+}
+
+// circuit returns the circuit for a session, creating it closed (must hold mu)
+func (b *CircuitBreaker) circuit(sessionID string) *circuit {
+	c, ok := b.circuits[sessionID]
+	if !ok {
+		c = &circuit{state: BreakerClosed}
+		b.circuits[sessionID] = c
+	}
+	return c
+}
+
+// setState changes the circuit state and returns the event to publish (must hold mu)
+func (b *CircuitBreaker) setState(c *circuit, req AgentRequest, to BreakerState, cause error) *BreakerEvent {
+	event := &BreakerEvent{
+		Time:      b.clock.Now(),
+		Err:       cause,
+		SessionID: req.SessionID,
+		AgentID:   req.AgentID,
+		From:      c.state,
+		To:        to,
+		Failures:  c.failures,
+	}
+	c.state = to
+	return event
+}
+
+// notify delivers an event to the change handler outside the lock
+func (b *CircuitBreaker) notify(event *BreakerEvent) {
+	if event != nil && b.onChange != nil {
+		b.onChange(*event)
+	}
+}
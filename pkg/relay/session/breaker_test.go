@@ -0,0 +1,130 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// scriptedSend returns errors from a queue, then succeeds
+type scriptedSend struct {
+	errs  []error
+	calls int
+}
+
+func (s *scriptedSend) send(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &acp.AgentMessage{Type: "text"}, nil
+}
+
+func TestCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{mono: time.Second}
+	var events []BreakerEvent
+	breaker := NewCircuitBreaker(2, time.Minute, clock, func(e BreakerEvent) { events = append(events, e) })
+
+	crash := errors.New("agent crashed")
+	agent := &scriptedSend{errs: []error{crash, crash, crash}}
+	send := breaker.Middleware()(agent.send)
+	req := AgentRequest{SessionID: "s1", AgentID: "auth"}
+
+	// Two consecutive failures open the circuit
+	_, _ = send(ctx, req)
+	if breaker.State("s1") != BreakerClosed {
+		t.Fatalf("expected circuit closed after one failure, got %s", breaker.State("s1"))
+	}
+	_, _ = send(ctx, req)
+	if breaker.State("s1") != BreakerOpen {
+		t.Fatalf("expected circuit open after threshold, got %s", breaker.State("s1"))
+	}
+
+	// Open circuit fails fast without reaching the agent
+	if _, err := send(ctx, req); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if agent.calls != 2 {
+		t.Errorf("expected agent not called while open, got %d calls", agent.calls)
+	}
+
+	// After cooldown a failed probe reopens the circuit
+	clock.advance(time.Minute)
+	_, _ = send(ctx, req)
+	if breaker.State("s1") != BreakerOpen {
+		t.Fatalf("expected failed probe to reopen circuit, got %s", breaker.State("s1"))
+	}
+	if _, err := send(ctx, req); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected reopened circuit to restart cooldown, got %v", err)
+	}
+
+	// A successful probe closes it
+	clock.advance(time.Minute)
+	if _, err := send(ctx, req); err != nil {
+		t.Fatalf("expected probe to succeed, got %v", err)
+	}
+	if breaker.State("s1") != BreakerClosed {
+		t.Errorf("expected circuit closed after successful probe, got %s", breaker.State("s1"))
+	}
+
+	want := []struct{ from, to BreakerState }{
+		{BreakerClosed, BreakerOpen},
+		{BreakerOpen, BreakerHalfOpen},
+		{BreakerHalfOpen, BreakerOpen},
+		{BreakerOpen, BreakerHalfOpen},
+		{BreakerHalfOpen, BreakerClosed},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, w := range want {
+		if events[i].From != w.from || events[i].To != w.to {
+			t.Errorf("event %d: expected %s → %s, got %s → %s", i, w.from, w.to, events[i].From, events[i].To)
+		}
+	}
+	if !errors.Is(events[0].Err, crash) || events[0].AgentID != "auth" || events[0].Failures != 2 {
+		t.Errorf("unexpected open event: %+v", events[0])
+	}
+}
+
+func TestCircuitBreaker_IgnoresPermanentAndCancelledErrors(t *testing.T) {
+	clock := &mockClock{}
+	breaker := NewCircuitBreaker(1, time.Minute, clock, nil)
+	req := AgentRequest{SessionID: "s1"}
+
+	agent := &scriptedSend{errs: []error{ErrSessionPaused}}
+	_, _ = breaker.Middleware()(agent.send)(context.Background(), req)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	agent = &scriptedSend{errs: []error{context.Canceled}}
+	_, _ = breaker.Middleware()(agent.send)(ctx, req)
+
+	if breaker.State("s1") != BreakerClosed {
+		t.Errorf("expected circuit to stay closed, got %s", breaker.State("s1"))
+	}
+}
+
+func TestCircuitBreaker_IsolatesSessions(t *testing.T) {
+	breaker := NewCircuitBreaker(1, time.Minute, &mockClock{}, nil)
+	agent := &scriptedSend{errs: []error{errors.New("boom")}}
+	send := breaker.Middleware()(agent.send)
+
+	_, _ = send(context.Background(), AgentRequest{SessionID: "s1"})
+	if _, err := send(context.Background(), AgentRequest{SessionID: "s2"}); err != nil {
+		t.Errorf("expected other session unaffected, got %v", err)
+	}
+
+	breaker.HandleLifecycle(LifecycleEvent{SessionID: "s1", From: StateTerminating, To: StateCleaned})
+	if breaker.State("s1") != BreakerClosed {
+		t.Error("expected cleaned session's circuit to be forgotten")
+	}
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrNotOwner is returned when someone other than a session's owner changes
+// who may use it
+var ErrNotOwner = errors.New("only the session owner may share it")
+
+// ErrAccessDenied is returned when a user is neither a session's owner nor
+// one of its collaborators
+var ErrAccessDenied = errors.New("user may not use this session")
+
+// userKey is the context key under which WithUser stores the user ID
+type userKey struct{}
+
+// WithUser returns a context carrying the user a request is made on behalf of
+// Prompts sent with it are attributed to that user in the session's history.
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserFromContext returns the user set by WithUser ("" = anonymous)
+func UserFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userKey{}).(string)
+	return userID
+}
+
+// Share lets userID prompt a session alongside its owner and returns the
+// session's collaborators
+// Only the owner (actor) may share; anonymous sessions are already open to
+// every connection that knows their ID. Sharing twice is a no-op.
+func (m *Manager) Share(ctx context.Context, sessionID, actor, userID string) ([]string, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("user ID cannot be empty")
+	}
+	return m.updateCollaborators(sessionID, actor, func(users []string) []string {
+		if slices.Contains(users, userID) {
+			return users
+		}
+		users = append(users, userID)
+		slices.Sort(users)
+		return users
+	})
+}
+
+// Unshare revokes a collaborator's access and returns the remaining
+// collaborators
+// Connections the user already attached stay attached, but their prompts are
+// rejected from then on.
+func (m *Manager) Unshare(ctx context.Context, sessionID, actor, userID string) ([]string, error) {
+	return m.updateCollaborators(sessionID, actor, func(users []string) []string {
+		return slices.DeleteFunc(users, func(u string) bool { return u == userID })
+	})
+}
+
+// Transfer makes to the owner of a session owned by from, e.g. to hand work
+// off to a teammate, and returns the session's collaborators
+// The swap only happens if from still owns the session, so concurrent
+// transfers cannot both win; from "" claims an anonymous session. The new
+// owner leaves the collaborators and, with keepAccess, the previous owner
+// joins them. History keeps the owner each entry was recorded under. Fails
+// with ErrNameTaken if to already has a session of the same name.
+func (m *Manager) Transfer(ctx context.Context, sessionID, from, to string, keepAccess bool) ([]string, error) {
+	if to == "" {
+		return nil, fmt.Errorf("new owner cannot be empty")
+	}
+	session := m.store.Get(sessionID)
+	if session == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	// Checked up front like Create; the index follows the owner once stored
+	if name := session.GetName(); name != "" {
+		if existing := m.store.GetByName(to, name); existing != nil && existing.GetID() != sessionID {
+			return nil, fmt.Errorf("%w: %q (session_id=%s)", ErrNameTaken, name, existing.GetID())
+		}
+	}
+
+	var users []string
+	err := m.store.Update(sessionID, func(session *Session) error {
+		if session.ownerID != from {
+			return fmt.Errorf("%w: %s", ErrNotOwner, sessionID)
+		}
+		users = slices.DeleteFunc(slices.Clone(session.collaborators), func(u string) bool { return u == to })
+		if keepAccess && from != "" && from != to && !slices.Contains(users, from) {
+			users = append(users, from)
+			slices.Sort(users)
+		}
+		session.setOwnerID(to)
+		session.setCollaborators(users)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.logger.Printf("Session transferred: id=%s from=%q to=%q keepAccess=%t", sessionID, from, to, keepAccess)
+	return slices.Clone(users), nil
+}
+
+// updateCollaborators applies change to the collaborators of a session owned by actor
+func (m *Manager) updateCollaborators(sessionID, actor string, change func([]string) []string) ([]string, error) {
+	var users []string
+	err := m.store.Update(sessionID, func(session *Session) error {
+		if session.ownerID == "" || session.ownerID != actor {
+			return fmt.Errorf("%w: %s", ErrNotOwner, sessionID)
+		}
+		users = change(slices.Clone(session.collaborators))
+		session.setCollaborators(users)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.logger.Printf("Session access changed: id=%s collaborators=%d", sessionID, len(users))
+	return slices.Clone(users), nil
+}
@@ -0,0 +1,121 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestManager_ShareUnshare(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+	session, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithOwner("alice"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if session.CanAccess("bob") || session.CanAccess("") || !session.CanAccess("alice") {
+		t.Fatal("expected only the owner to have access")
+	}
+
+	if _, err := manager.Share(ctx, session.GetID(), "bob", "carol"); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("expected ErrNotOwner for a non-owner, got %v", err)
+	}
+	for _, user := range []string{"dave", "bob", "bob"} {
+		if _, err := manager.Share(ctx, session.GetID(), "alice", user); err != nil {
+			t.Fatalf("Share failed: %v", err)
+		}
+	}
+	if got := session.GetCollaborators(); !reflect.DeepEqual(got, []string{"bob", "dave"}) {
+		t.Errorf("expected sorted collaborators without duplicates, got %v", got)
+	}
+	if !session.CanAccess("bob") {
+		t.Error("expected a collaborator to have access")
+	}
+
+	users, err := manager.Unshare(ctx, session.GetID(), "alice", "bob")
+	if err != nil || !reflect.DeepEqual(users, []string{"dave"}) {
+		t.Fatalf("expected [dave] after Unshare, got %v (%v)", users, err)
+	}
+	if session.CanAccess("bob") {
+		t.Error("expected access revoked")
+	}
+}
+
+func TestManager_ShareAnonymous(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+	session, err := manager.Create(ctx, "auth", &mockWebSocket{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !session.CanAccess("") || !session.CanAccess("bob") {
+		t.Error("expected an anonymous session to be open")
+	}
+	if _, err := manager.Share(ctx, session.GetID(), "", "bob"); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("expected ErrNotOwner for an unowned session, got %v", err)
+	}
+}
+
+func TestManager_HistoryAttribution(t *testing.T) {
+	ctx := context.Background()
+	history := NewMemoryHistory(10)
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "s1"}, &mockClock{}, &mockCleaner{}, &mockLogger{}, WithHistory(history))
+	setupActiveSession(t, manager, &mockACPClient{})
+
+	if _, err := manager.SendMessage(WithUser(ctx, "bob"), "s1", "hello"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	prompts, err := manager.SearchHistory(HistoryQuery{SessionID: "s1", Speaker: SpeakerUser})
+	if err != nil || len(prompts) != 1 || prompts[0].Entry.UserID != "bob" {
+		t.Fatalf("expected the prompt attributed to bob, got %+v (%v)", prompts, err)
+	}
+	replies, _ := manager.SearchHistory(HistoryQuery{SessionID: "s1", Speaker: SpeakerAgent})
+	if len(replies) != 1 || replies[0].Entry.UserID != "" {
+		t.Errorf("expected an unattributed reply, got %+v", replies)
+	}
+}
+
+func TestManager_Transfer(t *testing.T) {
+	ctx := context.Background()
+	manager, idGen, _, _, _ := setupManager()
+	session, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithOwner("alice"), WithName("login"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := manager.Share(ctx, session.GetID(), "alice", "bob"); err != nil {
+		t.Fatalf("Share failed: %v", err)
+	}
+
+	if _, err := manager.Transfer(ctx, session.GetID(), "carol", "bob", false); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("expected ErrNotOwner for a stale owner, got %v", err)
+	}
+	users, err := manager.Transfer(ctx, session.GetID(), "alice", "bob", true)
+	if err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+	if session.GetOwnerID() != "bob" || !reflect.DeepEqual(users, []string{"alice"}) {
+		t.Errorf("expected bob to own it with alice kept on, got owner=%s collaborators=%v", session.GetOwnerID(), users)
+	}
+
+	// The name moves with the session
+	if manager.GetByName("alice", "login") != nil || manager.GetByName("bob", "login") != session {
+		t.Error("expected the name index to follow the new owner")
+	}
+	idGen.nextID = "other"
+	other, err := manager.Create(ctx, "db", &mockWebSocket{}, WithOwner("carol"), WithName("login"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := manager.Transfer(ctx, other.GetID(), "carol", "bob", false); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("expected ErrNameTaken, got %v", err)
+	}
+
+	// Without keepAccess the previous owner is out
+	if _, err := manager.Transfer(ctx, session.GetID(), "bob", "dave", false); err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+	if session.CanAccess("bob") || !session.CanAccess("alice") {
+		t.Errorf("expected bob out and alice kept, got collaborators=%v", session.GetCollaborators())
+	}
+}
@@ -0,0 +1,89 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSessionTerminated is the cause of a session's contexts being cancelled
+// when it starts terminating; agent requests interrupted by it wrap it
+var ErrSessionTerminated = errors.New("session terminated")
+
+// errAgentReplaced cancels the context of an agent that ReplaceAgent swapped out
+var errAgentReplaced = errors.New("agent replaced")
+
+// Context returns the session's root context
+// It is cancelled, with cause ErrSessionTerminated, when the session starts
+// terminating. Derive background work tied to the session from it.
+func (s *Session) Context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rootContext()
+}
+
+// AgentContext returns the context of the session's current agent
+// It is derived from Context and is also cancelled when the agent is replaced.
+func (s *Session) AgentContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.agentCtx == nil {
+		s.agentCtx, s.cancelAgent = context.WithCancelCause(s.rootContext())
+	}
+	return s.agentCtx
+}
+
+// rootContext creates the root context on first use (must hold lock)
+// Sessions that are already terminating get one that is already cancelled.
+func (s *Session) rootContext() context.Context {
+	if s.ctx == nil {
+		s.ctx, s.cancel = context.WithCancelCause(context.Background())
+		if s.state == StateTerminating || s.state == StateCleaned {
+			s.cancel(ErrSessionTerminated)
+		}
+	}
+	return s.ctx
+}
+
+// renewAgentContext cancels the previous agent's context and starts one for a
+// newly attached agent
+func (s *Session) renewAgentContext() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancelAgent != nil {
+		s.cancelAgent(errAgentReplaced)
+	}
+	s.agentCtx, s.cancelAgent = context.WithCancelCause(s.rootContext())
+}
+
+// cancelContexts cancels the root context and with it every context derived
+// from it
+func (s *Session) cancelContexts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rootContext()
+	s.cancel(ErrSessionTerminated)
+}
+
+// linkContext returns a context that is done when either ctx or parent is,
+// reporting parent's cause in the second case
+// The returned func releases it and must be called.
+func linkContext(ctx, parent context.Context) (context.Context, context.CancelFunc) {
+	linked, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(parent, func() { cancel(context.Cause(parent)) })
+	return linked, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+// terminatedError reports a failed request as ErrSessionTerminated if the
+// session has started terminating
+// The root context is checked rather than the request's: contexts linked to
+// it are cancelled asynchronously.
+func (s *Session) terminatedError(err error) error {
+	if err != nil && !errors.Is(err, ErrSessionTerminated) && errors.Is(context.Cause(s.Context()), ErrSessionTerminated) {
+		return fmt.Errorf("%w: %s: %v", ErrSessionTerminated, s.ID, err)
+	}
+	return err
+}
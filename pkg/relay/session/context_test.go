@@ -0,0 +1,86 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// blockingACPClient answers only once its context is done
+type blockingACPClient struct {
+	mockACPClient
+	started chan struct{}
+}
+
+func (c *blockingACPClient) SendMessageContext(ctx context.Context, content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	close(c.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestManager_TerminateCancelsInFlightRequest(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+	client := &blockingACPClient{started: make(chan struct{})}
+	sess := setupActiveSession(t, manager, client)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := manager.SendMessage(ctx, sess.GetID(), "hello")
+		done <- err
+	}()
+	<-client.started
+
+	if err := manager.MarkTerminating(ctx, sess.GetID(), "user request"); err != nil {
+		t.Fatalf("MarkTerminating failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrSessionTerminated) {
+			t.Errorf("expected ErrSessionTerminated, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request still running after the session began terminating")
+	}
+
+	if cause := context.Cause(sess.Context()); !errors.Is(cause, ErrSessionTerminated) {
+		t.Errorf("expected session context cancelled by termination, got %v", cause)
+	}
+	if sess.AgentContext().Err() == nil {
+		t.Error("expected agent context cancelled with the session")
+	}
+}
+
+func TestManager_ReplaceAgentCancelsOldAgentContext(t *testing.T) {
+	ctx := context.Background()
+	manager := setupReplaceManager(func(ctx context.Context, role, worktreeDir string) (ACPClient, error) {
+		return &mockACPClient{}, nil
+	})
+	sess := setupActiveSession(t, manager, &mockACPClient{})
+	oldCtx := sess.AgentContext()
+
+	if err := manager.ReplaceAgent(ctx, sess.GetID(), ""); err != nil {
+		t.Fatalf("ReplaceAgent failed: %v", err)
+	}
+
+	if oldCtx.Err() == nil {
+		t.Error("expected the replaced agent's context cancelled")
+	}
+	if err := sess.AgentContext().Err(); err != nil {
+		t.Errorf("expected the new agent's context live, got %v", err)
+	}
+	if err := sess.Context().Err(); err != nil {
+		t.Errorf("expected the session context live, got %v", err)
+	}
+}
+
+func TestSession_ContextOfTerminatedSession(t *testing.T) {
+	sess := NewSession("s1", "auth", time.Now())
+	sess.state = StateCleaned
+	if cause := context.Cause(sess.Context()); !errors.Is(cause, ErrSessionTerminated) {
+		t.Errorf("expected a cleaned session's context already cancelled, got %v", cause)
+	}
+}
@@ -0,0 +1,202 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encryptedMagic starts every stream written by ArchiveCipher
+const encryptedMagic = "OUROENC\x01"
+
+// encryptedChunk is the plaintext size of each sealed chunk
+const encryptedChunk = 64 << 10
+
+// ArchiveKeySize is the key length NewArchiveCipher requires (AES-256)
+const ArchiveKeySize = 32
+
+// ErrDecrypt is returned when an encrypted stream is truncated, corrupt or
+// sealed with another key
+var ErrDecrypt = errors.New("decryption failed")
+
+// ArchiveCipher encrypts session archives with AES-256-GCM so transcripts and
+// workspace files are never written out in plaintext
+// Streams are split into chunks sealed with a per-stream random nonce plus
+// the chunk's sequence number; the last chunk is marked final, so reordered,
+// dropped or truncated chunks fail to decrypt.
+type ArchiveCipher struct {
+	aead cipher.AEAD
+}
+
+// NewArchiveCipher returns a cipher for a 32-byte key
+func NewArchiveCipher(key []byte) (*ArchiveCipher, error) {
+	if len(key) != ArchiveKeySize {
+		return nil, fmt.Errorf("archive key must be %d bytes, got %d", ArchiveKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveCipher{aead: aead}, nil
+}
+
+// EncryptWriter returns a writer that encrypts to w
+// Close must be called to write the final chunk; it does not close w.
+func (c *ArchiveCipher) EncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	base := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(base); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, encryptedMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(base); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{c: c, w: w, base: base}, nil
+}
+
+// DecryptReader returns a reader of the plaintext of a stream written by
+// EncryptWriter
+func (c *ArchiveCipher) DecryptReader(r io.Reader) (io.Reader, error) {
+	header := make([]byte, len(encryptedMagic)+c.aead.NonceSize())
+	if _, err := io.ReadFull(r, header); err != nil || !IsEncrypted(header) {
+		return nil, fmt.Errorf("%w: not an encrypted stream", ErrDecrypt)
+	}
+	return &decryptReader{c: c, r: r, base: header[len(encryptedMagic):]}, nil
+}
+
+// IsEncrypted reports whether data starts like a stream written by EncryptWriter
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedMagic))
+}
+
+// peekEncrypted reports whether r holds an encrypted stream, returning a
+// reader that still yields every byte of r
+func peekEncrypted(r io.Reader) (io.Reader, bool) {
+	br := bufio.NewReader(r)
+	prefix, _ := br.Peek(len(encryptedMagic))
+	return br, IsEncrypted(prefix)
+}
+
+// chunkParams returns the nonce and additional data of chunk seq
+func (c *ArchiveCipher) chunkParams(base []byte, seq uint64, final bool) (nonce, aad []byte) {
+	nonce = append([]byte{}, base...)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^seq)
+	aad = []byte{0}
+	if final {
+		aad[0] = 1
+	}
+	return nonce, aad
+}
+
+// encryptWriter buffers plaintext and seals it a chunk at a time
+// Each chunk is written as a final flag byte, the ciphertext length and the
+// ciphertext.
+type encryptWriter struct {
+	c      *ArchiveCipher
+	w      io.Writer
+	base   []byte
+	buf    []byte
+	seq    uint64
+	closed bool
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+	e.buf = append(e.buf, p...)
+	// Keep the tail buffered: the last chunk is only known at Close
+	for len(e.buf) > encryptedChunk {
+		if err := e.seal(e.buf[:encryptedChunk], false); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[encryptedChunk:]
+	}
+	return len(p), nil
+}
+
+// Close seals the remaining plaintext as the final chunk
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(e.buf, true)
+}
+
+func (e *encryptWriter) seal(plain []byte, final bool) error {
+	nonce, aad := e.c.chunkParams(e.base, e.seq, final)
+	e.seq++
+	sealed := e.c.aead.Seal(nil, nonce, plain, aad)
+	header := make([]byte, 5)
+	header[0] = aad[0]
+	binary.BigEndian.PutUint32(header[1:], uint32(len(sealed))) // #nosec G115 -- bounded by encryptedChunk
+	if _, err := e.w.Write(header); err != nil {
+		return err
+	}
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// decryptReader opens one chunk at a time
+type decryptReader struct {
+	c     *ArchiveCipher
+	r     io.Reader
+	base  []byte
+	seq   uint64
+	plain []byte
+	done  bool
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// next reads and opens the next chunk
+func (d *decryptReader) next() error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		return fmt.Errorf("%w: truncated stream", ErrDecrypt)
+	}
+	final := header[0] == 1
+	size := binary.BigEndian.Uint32(header[1:])
+	if header[0] > 1 || size > encryptedChunk+uint32(d.c.aead.Overhead()) {
+		return fmt.Errorf("%w: malformed chunk", ErrDecrypt)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return fmt.Errorf("%w: truncated stream", ErrDecrypt)
+	}
+	nonce, aad := d.c.chunkParams(d.base, d.seq, final)
+	plain, err := d.c.aead.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d: %v", ErrDecrypt, d.seq, err)
+	}
+	d.seq++
+	d.plain = plain
+	d.done = final
+	return nil
+}
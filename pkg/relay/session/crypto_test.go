@@ -0,0 +1,88 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func testCipher(t *testing.T, fill byte) *ArchiveCipher {
+	t.Helper()
+	c, err := NewArchiveCipher(bytes.Repeat([]byte{fill}, ArchiveKeySize))
+	if err != nil {
+		t.Fatalf("NewArchiveCipher failed: %v", err)
+	}
+	return c
+}
+
+func encrypt(t *testing.T, c *ArchiveCipher, plain []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := c.EncryptWriter(&out)
+	if err != nil {
+		t.Fatalf("EncryptWriter failed: %v", err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return out.Bytes()
+}
+
+func decrypt(c *ArchiveCipher, sealed []byte) ([]byte, error) {
+	r, err := c.DecryptReader(bytes.NewReader(sealed))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestArchiveCipher_RoundTrip(t *testing.T) {
+	c := testCipher(t, 1)
+	// Empty, one partial chunk, exactly one chunk and several chunks
+	for _, size := range []int{0, 10, encryptedChunk, 3*encryptedChunk + 7} {
+		plain := bytes.Repeat([]byte("secret "), size/7+1)[:size]
+		sealed := encrypt(t, c, plain)
+		if !IsEncrypted(sealed) || bytes.Contains(sealed, []byte("secret")) {
+			t.Fatalf("size %d: output is not encrypted", size)
+		}
+		got, err := decrypt(c, sealed)
+		if err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: round trip mismatch", size)
+		}
+	}
+
+	if _, err := NewArchiveCipher([]byte("short")); err == nil {
+		t.Error("expected short key to be rejected")
+	}
+}
+
+func TestArchiveCipher_Rejects(t *testing.T) {
+	c := testCipher(t, 1)
+	sealed := encrypt(t, c, bytes.Repeat([]byte("x"), 2*encryptedChunk+1))
+
+	flipped := append([]byte{}, sealed...)
+	flipped[len(flipped)-1] ^= 1
+	// Dropping the final chunk leaves a stream of valid non-final chunks
+	finalChunk := 5 + 1 + c.aead.Overhead()
+	tests := map[string]struct {
+		cipher *ArchiveCipher
+		data   []byte
+	}{
+		"wrong key": {testCipher(t, 2), sealed},
+		"tampered":  {c, flipped},
+		"truncated": {c, sealed[:len(sealed)-finalChunk]},
+		"plaintext": {c, []byte("plain tar.gz")},
+	}
+	for name, tt := range tests {
+		if _, err := decrypt(tt.cipher, tt.data); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: expected ErrDecrypt, got %v", name, err)
+		}
+	}
+}
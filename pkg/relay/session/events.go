@@ -0,0 +1,79 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// LifecycleEvent describes a committed session state transition
+// Published by Manager after the transition is stored, never while holding locks.
+// Creation is published too, as EventCreate with an empty From state.
+type LifecycleEvent struct {
+	Time      time.Time
+	Err       error // Failure cause, set when the session was terminated by MarkFailed
+	SessionID string
+	Name      string // Human-readable session name, empty if unnamed
+	AgentID   string
+	From      SessionState
+	To        SessionState
+	Event     Event
+	Reason    string
+	Elapsed   time.Duration // Monotonic time spent in the From state
+}
+
+// EventHandler consumes lifecycle events
+// Handlers run synchronously on the goroutine that performed the transition,
+// so they must be fast and must not call back into the Manager's mutators
+type EventHandler func(LifecycleEvent)
+
+// subscription pairs a handler with the ID used to unsubscribe it
+type subscription struct {
+	handler EventHandler
+	id      int
+}
+
+// EventBus fans out lifecycle events to subscribers in subscription order
+// Thread-safe: subscribe and publish may be called concurrently
+type EventBus struct {
+	subs   []subscription
+	nextID int
+	mu     sync.RWMutex
+}
+
+// NewEventBus creates an event bus with no subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers a handler and returns a function that removes it
+// The returned function is idempotent
+func (b *EventBus) Subscribe(handler EventHandler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subs = append(b.subs, subscription{id: id, handler: handler})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, sub := range b.subs {
+			if sub.id == id {
+				b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers the event to every current subscriber
+func (b *EventBus) Publish(event LifecycleEvent) {
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		sub.handler(event)
+	}
+}
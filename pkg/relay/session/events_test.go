@@ -0,0 +1,35 @@
+package session
+
+import (
+	"testing"
+)
+
+func TestEventBus_PublishInSubscriptionOrder(t *testing.T) {
+	bus := NewEventBus()
+
+	var order []string
+	bus.Subscribe(func(e LifecycleEvent) { order = append(order, "first:"+e.SessionID) })
+	bus.Subscribe(func(e LifecycleEvent) { order = append(order, "second:"+e.SessionID) })
+
+	bus.Publish(LifecycleEvent{SessionID: "s1"})
+
+	if len(order) != 2 || order[0] != "first:s1" || order[1] != "second:s1" {
+		t.Errorf("unexpected delivery order: %v", order)
+	}
+}
+
+func TestEventBus_Unsubscribe(t *testing.T) {
+	bus := NewEventBus()
+
+	calls := 0
+	unsubscribe := bus.Subscribe(func(e LifecycleEvent) { calls++ })
+
+	bus.Publish(LifecycleEvent{})
+	unsubscribe()
+	unsubscribe() // idempotent
+	bus.Publish(LifecycleEvent{})
+
+	if calls != 1 {
+		t.Errorf("expected 1 delivery before unsubscribe, got %d", calls)
+	}
+}
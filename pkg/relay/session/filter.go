@@ -0,0 +1,181 @@
+package session
+
+import (
+	"sort"
+	"time"
+)
+
+// SortField selects the ordering applied by Store.List
+type SortField string
+
+const (
+	// SortByCreatedAt orders sessions by creation time (default)
+	SortByCreatedAt SortField = "createdAt"
+
+	// SortByLastActive orders sessions by last activity time
+	SortByLastActive SortField = "lastActive"
+
+	// SortByID orders sessions lexically by session ID
+	SortByID SortField = "id"
+)
+
+// IsValid returns true if the field is a recognized SortField
+// The empty field is valid and means SortByCreatedAt
+func (f SortField) IsValid() bool {
+	switch f {
+	case "", SortByCreatedAt, SortByLastActive, SortByID:
+		return true
+	default:
+		return false
+	}
+}
+
+// SessionFilter defines criteria for filtering, sorting, and paginating sessions
+// All criteria are optional; nil pointers and empty slices do not filter
+type SessionFilter struct {
+	State          *SessionState     // Filter by state (nil = no filter)
+	AgentID        *string           // Filter by agent role (nil = no filter)
+	HasAgentRole   []string          // Match sessions whose agent role is any of these
+	OwnerID        *string           // Filter by owning user (nil = no filter)
+	Name           *string           // Filter by human-readable name (nil = no filter)
+	Labels         map[string]string // Match sessions carrying all of these labels
+	CreatedAfter   *time.Time        // Exclusive lower bound on creation time
+	CreatedBefore  *time.Time        // Exclusive upper bound on creation time
+	IdleLongerThan *time.Duration    // Match sessions inactive for longer than this
+
+	// Ordering; ties are broken by session ID so pages are stable
+	SortBy     SortField
+	Descending bool
+
+	// Pagination applied after filtering and sorting (Limit 0 = no limit)
+	Offset int
+	Limit  int
+
+	// Reference readings for IdleLongerThan, set by Manager from its Clock
+	now     time.Time
+	nowMono time.Duration
+}
+
+// referenceTime returns the time idle durations are measured against
+// Falls back to the wall clock for filters built outside the Manager
+func (f *SessionFilter) referenceTime() time.Time {
+	if f == nil || f.now.IsZero() {
+		return time.Now()
+	}
+	return f.now
+}
+
+// Matches reports whether the session satisfies every criterion in the filter
+// now is the reference time for IdleLongerThan. A nil filter matches everything.
+func (f *SessionFilter) Matches(session *Session, now time.Time) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.State != nil && session.GetState() != *f.State {
+		return false
+	}
+
+	if f.AgentID != nil && session.AgentID != *f.AgentID {
+		return false
+	}
+
+	if len(f.HasAgentRole) > 0 && !containsString(f.HasAgentRole, session.AgentID) {
+		return false
+	}
+
+	if f.OwnerID != nil && session.GetOwnerID() != *f.OwnerID {
+		return false
+	}
+
+	if f.Name != nil && session.GetName() != *f.Name {
+		return false
+	}
+
+	if len(f.Labels) > 0 && !session.HasLabels(f.Labels) {
+		return false
+	}
+
+	if f.CreatedAfter != nil && !session.GetCreatedAt().After(*f.CreatedAfter) {
+		return false
+	}
+
+	if f.CreatedBefore != nil && !session.GetCreatedAt().Before(*f.CreatedBefore) {
+		return false
+	}
+
+	if f.IdleLongerThan != nil && session.idleFor(now, f.nowMono) <= *f.IdleLongerThan {
+		return false
+	}
+
+	return true
+}
+
+// Apply sorts the matched sessions and returns the requested page
+// Store implementations call this on their filtered results so ordering and
+// pagination behave identically across backends
+func (f *SessionFilter) Apply(sessions []*Session) []*Session {
+	var field SortField
+	descending := false
+	if f != nil {
+		field, descending = f.SortBy, f.Descending
+	}
+	sortSessions(sessions, field, descending)
+
+	if f == nil {
+		return sessions
+	}
+	return paginate(sessions, f.Offset, f.Limit)
+}
+
+// sortSessions orders sessions in place by field, breaking ties by ID
+func sortSessions(sessions []*Session, field SortField, descending bool) {
+	less := func(a, b *Session) bool {
+		switch field {
+		case SortByLastActive:
+			if ta, tb := a.GetLastActive(), b.GetLastActive(); !ta.Equal(tb) {
+				return ta.Before(tb)
+			}
+		case SortByID:
+			// Falls through to the ID tie-break below
+		default:
+			if ta, tb := a.GetCreatedAt(), b.GetCreatedAt(); !ta.Equal(tb) {
+				return ta.Before(tb)
+			}
+		}
+		return a.ID < b.ID
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if descending {
+			return less(sessions[j], sessions[i])
+		}
+		return less(sessions[i], sessions[j])
+	})
+}
+
+// paginate returns the window [offset, offset+limit) of sessions
+// Out-of-range offsets yield an empty page; limit <= 0 means no limit
+func paginate(sessions []*Session, offset, limit int) []*Session {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(sessions) {
+		return nil
+	}
+	sessions = sessions[offset:]
+	if limit > 0 && limit < len(sessions) {
+		sessions = sessions[:limit]
+	}
+	return sessions
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func newFilterFixture() []*Session {
+	base := time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)
+	s1 := NewSession("s1", "auth", base)
+	s2 := NewSession("s2", "db", base.Add(time.Minute))
+	s3 := NewSession("s3", "tests", base.Add(2*time.Minute))
+	s1.setOwnerID("alice")
+	s2.setOwnerID("bob")
+	s3.setOwnerID("alice")
+	s1.setLastActive(base.Add(10*time.Minute), 0)
+	return []*Session{s3, s1, s2}
+}
+
+func ids(sessions []*Session) []string {
+	out := make([]string, len(sessions))
+	for i, s := range sessions {
+		out[i] = s.ID
+	}
+	return out
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSessionFilter_Matches(t *testing.T) {
+	sessions := newFilterFixture()
+	base := time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)
+	now := base.Add(20 * time.Minute)
+	owner := "alice"
+	after := base
+	before := base.Add(2 * time.Minute)
+	idle := 15 * time.Minute
+
+	tests := []struct {
+		name   string
+		filter *SessionFilter
+		want   []string
+	}{
+		{"nil filter", nil, []string{"s1", "s2", "s3"}},
+		{"owner", &SessionFilter{OwnerID: &owner}, []string{"s1", "s3"}},
+		{"has agent role", &SessionFilter{HasAgentRole: []string{"db", "tests"}}, []string{"s2", "s3"}},
+		{"created after (exclusive)", &SessionFilter{CreatedAfter: &after}, []string{"s2", "s3"}},
+		{"created before (exclusive)", &SessionFilter{CreatedBefore: &before}, []string{"s1", "s2"}},
+		{"idle longer than", &SessionFilter{IdleLongerThan: &idle}, []string{"s2", "s3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []*Session
+			for _, s := range sessions {
+				if tt.filter.Matches(s, now) {
+					got = append(got, s)
+				}
+			}
+			got = tt.filter.Apply(got)
+			if !equalIDs(ids(got), tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, ids(got))
+			}
+		})
+	}
+}
+
+func TestSessionFilter_SortAndPaginate(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *SessionFilter
+		want   []string
+	}{
+		{"default sorts by createdAt", &SessionFilter{}, []string{"s1", "s2", "s3"}},
+		{"descending", &SessionFilter{Descending: true}, []string{"s3", "s2", "s1"}},
+		{"by lastActive", &SessionFilter{SortBy: SortByLastActive}, []string{"s2", "s3", "s1"}},
+		{"by id", &SessionFilter{SortBy: SortByID, Descending: true}, []string{"s3", "s2", "s1"}},
+		{"limit", &SessionFilter{Limit: 2}, []string{"s1", "s2"}},
+		{"offset and limit", &SessionFilter{Offset: 1, Limit: 1}, []string{"s2"}},
+		{"offset past end", &SessionFilter{Offset: 5}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.Apply(newFilterFixture())
+			if !equalIDs(ids(got), tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, ids(got))
+			}
+		})
+	}
+}
+
+func TestSortField_IsValid(t *testing.T) {
+	for _, f := range []SortField{"", SortByCreatedAt, SortByLastActive, SortByID} {
+		if !f.IsValid() {
+			t.Errorf("expected %q to be valid", f)
+		}
+	}
+	if SortField("state").IsValid() {
+		t.Error("expected unknown sort field to be invalid")
+	}
+}
@@ -0,0 +1,198 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrHistoryDisabled is returned by SearchHistory when no HistoryStore is configured
+var ErrHistoryDisabled = errors.New("conversation history is not enabled")
+
+// Speaker identifies who produced a history entry
+type Speaker string
+
+const (
+	// SpeakerUser marks a prompt sent to the agent
+	SpeakerUser Speaker = "user"
+
+	// SpeakerAgent marks the agent's reply
+	SpeakerAgent Speaker = "agent"
+)
+
+// HistoryEntry is one prompt or reply in a session's conversation
+type HistoryEntry struct {
+	Time      time.Time
+	SessionID string
+	AgentID   string
+	OwnerID   string // Owner of the session when the entry was recorded
+	UserID    string // User who sent a prompt (empty for replies and anonymous prompts)
+	Speaker   Speaker
+	Content   string
+}
+
+// HistoryQuery selects entries for SearchHistory
+// Text is split into terms that must all appear (case-insensitive); empty
+// fields do not filter
+type HistoryQuery struct {
+	Text      string
+	SessionID string
+	AgentID   string
+	Speaker   Speaker
+	Limit     int // 0 = no limit
+}
+
+// HistoryMatch is a search hit with a snippet around the first matching term
+type HistoryMatch struct {
+	Entry   HistoryEntry
+	Snippet string
+}
+
+// HistoryStore persists conversation history and searches it
+// Implementations must be safe for concurrent use
+type HistoryStore interface {
+	Append(entry HistoryEntry) error
+	Search(query HistoryQuery) ([]HistoryMatch, error)
+}
+
+// HistoryDeleter is optionally implemented by history stores that can erase
+// a user's entries, for PurgeOwner
+type HistoryDeleter interface {
+	// DeleteOwner removes every entry recorded for ownerID and returns how many
+	DeleteOwner(ownerID string) (int, error)
+}
+
+// MemoryHistory is an in-memory HistoryStore holding the most recent entries
+// Search is a linear scan, adequate for the bounded size; a persistent store
+// should use a real full-text index.
+type MemoryHistory struct {
+	entries    []HistoryEntry
+	maxEntries int
+	mu         sync.RWMutex
+}
+
+// NewMemoryHistory creates a history store that keeps at most maxEntries
+// entries, dropping the oldest first (maxEntries < 1 means unbounded)
+func NewMemoryHistory(maxEntries int) *MemoryHistory {
+	return &MemoryHistory{maxEntries: maxEntries}
+}
+
+// Append records an entry, evicting the oldest when full
+func (h *MemoryHistory) Append(entry HistoryEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entry)
+	if h.maxEntries > 0 && len(h.entries) > h.maxEntries {
+		h.entries = append(h.entries[:0:0], h.entries[len(h.entries)-h.maxEntries:]...)
+	}
+	return nil
+}
+
+// Search returns matching entries, newest first
+func (h *MemoryHistory) Search(query HistoryQuery) ([]HistoryMatch, error) {
+	terms := searchTerms(query.Text)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var matches []HistoryMatch
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		entry := h.entries[i]
+		if !query.matchesEntry(entry) {
+			continue
+		}
+		snippet, ok := matchTerms(entry.Content, terms)
+		if !ok {
+			continue
+		}
+		matches = append(matches, HistoryMatch{Entry: entry, Snippet: snippet})
+		if query.Limit > 0 && len(matches) == query.Limit {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// DeleteOwner removes every entry recorded for ownerID
+func (h *MemoryHistory) DeleteOwner(ownerID string) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	kept := h.entries[:0]
+	for _, entry := range h.entries {
+		if entry.OwnerID != ownerID {
+			kept = append(kept, entry)
+		}
+	}
+	deleted := len(h.entries) - len(kept)
+	clear(h.entries[len(kept):]) // Drop references to the deleted content
+	h.entries = kept
+	return deleted, nil
+}
+
+// matchesEntry applies the non-text filters (pure function)
+func (q HistoryQuery) matchesEntry(entry HistoryEntry) bool {
+	if q.SessionID != "" && entry.SessionID != q.SessionID {
+		return false
+	}
+	if q.AgentID != "" && entry.AgentID != q.AgentID {
+		return false
+	}
+	if q.Speaker != "" && entry.Speaker != q.Speaker {
+		return false
+	}
+	return true
+}
+
+// searchTerms lowercases and splits query text on non-word characters (pure function)
+// Underscores are kept so identifiers like parse_config stay one term
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// snippetRadius is how many bytes of context surround the first hit in a snippet
+const snippetRadius = 60
+
+// matchTerms reports whether content contains every term and returns a
+// snippet around the first occurrence of the first term (pure function)
+// With no terms everything matches and the snippet is the content's start.
+func matchTerms(content string, terms []string) (string, bool) {
+	lower := strings.ToLower(content)
+	first := 0
+	for i, term := range terms {
+		idx := strings.Index(lower, term)
+		if idx < 0 {
+			return "", false
+		}
+		if i == 0 {
+			first = idx
+		}
+	}
+	return snippet(content, first), true
+}
+
+// snippet extracts up to snippetRadius bytes either side of pos, marking cuts
+// with an ellipsis and never splitting a UTF-8 sequence (pure function)
+func snippet(content string, pos int) string {
+	start := max(0, pos-snippetRadius)
+	end := min(len(content), pos+snippetRadius)
+	for start > 0 && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end++
+	}
+
+	s := content[start:end]
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(content) {
+		s += "…"
+	}
+	return s
+}
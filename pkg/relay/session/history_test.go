@@ -0,0 +1,116 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryHistory_Search(t *testing.T) {
+	h := NewMemoryHistory(0)
+	base := time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)
+	entries := []HistoryEntry{
+		{Time: base, SessionID: "s1", AgentID: "auth", Speaker: SpeakerUser, Content: "Where is parse_config defined?"},
+		{Time: base.Add(time.Second), SessionID: "s1", AgentID: "auth", Speaker: SpeakerAgent, Content: "parse_config lives in config.go"},
+		{Time: base.Add(2 * time.Second), SessionID: "s2", AgentID: "db", Speaker: SpeakerAgent, Content: "We decided to use Postgres"},
+	}
+	for _, e := range entries {
+		if err := h.Append(e); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query HistoryQuery
+		want  []string // Contents, newest first
+	}{
+		{"single term", HistoryQuery{Text: "PARSE_CONFIG"}, []string{entries[1].Content, entries[0].Content}},
+		{"all terms required", HistoryQuery{Text: "parse_config config.go"}, []string{entries[1].Content}},
+		{"speaker filter", HistoryQuery{Text: "parse_config", Speaker: SpeakerUser}, []string{entries[0].Content}},
+		{"session filter", HistoryQuery{Text: "postgres", SessionID: "s1"}, nil},
+		{"role filter", HistoryQuery{Text: "decided", AgentID: "db"}, []string{entries[2].Content}},
+		{"limit", HistoryQuery{Text: "parse_config", Limit: 1}, []string{entries[1].Content}},
+		{"no match", HistoryQuery{Text: "mysql"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := h.Search(tt.query)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(matches) != len(tt.want) {
+				t.Fatalf("expected %d matches, got %d: %+v", len(tt.want), len(matches), matches)
+			}
+			for i, m := range matches {
+				if m.Entry.Content != tt.want[i] {
+					t.Errorf("match %d: expected %q, got %q", i, tt.want[i], m.Entry.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryHistory_EvictsOldest(t *testing.T) {
+	h := NewMemoryHistory(2)
+	for _, content := range []string{"alpha", "beta", "gamma"} {
+		_ = h.Append(HistoryEntry{Content: content})
+	}
+
+	matches, _ := h.Search(HistoryQuery{})
+	if len(matches) != 2 || matches[0].Entry.Content != "gamma" || matches[1].Entry.Content != "beta" {
+		t.Errorf("expected gamma and beta to remain, got %+v", matches)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("a", 100) + "needle" + strings.Repeat("é", 100)
+	s, ok := matchTerms(long, []string{"needle"})
+	if !ok {
+		t.Fatal("expected match")
+	}
+	if !strings.HasPrefix(s, "…") || !strings.HasSuffix(s, "…") || !strings.Contains(s, "needle") {
+		t.Errorf("expected elided snippet around needle, got %q", s)
+	}
+	if !strings.ContainsRune(s, 'é') || strings.ContainsRune(s, '�') {
+		t.Errorf("snippet split a UTF-8 sequence: %q", s)
+	}
+}
+
+func TestManager_SearchHistory(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled by default", func(t *testing.T) {
+		manager, _, _, _, _ := setupManager()
+		if _, err := manager.SearchHistory(HistoryQuery{Text: "x"}); !errors.Is(err, ErrHistoryDisabled) {
+			t.Errorf("expected ErrHistoryDisabled, got %v", err)
+		}
+	})
+
+	t.Run("records prompts and replies", func(t *testing.T) {
+		manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "session-1"}, &mockClock{},
+			&mockCleaner{}, &mockLogger{}, WithHistory(NewMemoryHistory(100)))
+		session := setupActiveSession(t, manager, &mockACPClient{})
+
+		if _, err := manager.SendMessage(ctx, session.GetID(), "rename handleLogin"); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+
+		matches, err := manager.SearchHistory(HistoryQuery{Text: "handleLogin"})
+		if err != nil {
+			t.Fatalf("SearchHistory failed: %v", err)
+		}
+		if len(matches) != 2 {
+			t.Fatalf("expected prompt and reply, got %+v", matches)
+		}
+		if matches[0].Entry.Speaker != SpeakerAgent || matches[0].Entry.Content != "echo: rename handleLogin" {
+			t.Errorf("expected agent reply first, got %+v", matches[0].Entry)
+		}
+		if matches[1].Entry.Speaker != SpeakerUser || matches[1].Entry.AgentID != "auth" {
+			t.Errorf("expected user prompt second, got %+v", matches[1].Entry)
+		}
+	})
+}
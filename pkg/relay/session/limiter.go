@@ -0,0 +1,75 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSessionBusy is returned when a session already has the maximum number
+// of agent requests in flight and the limiter does not queue
+var ErrSessionBusy = errors.New("session is busy")
+
+// WithConcurrencyLimit caps in-flight SendMessage calls per session at limit,
+// for agent processes that cannot multiplex requests
+// With queue set extra calls wait for a slot until their context is done;
+// otherwise they fail fast with ErrSessionBusy. A limit below 1 disables it.
+func WithConcurrencyLimit(limit int, queue bool) ManagerOption {
+	return func(c *managerConfig) {
+		if limit < 1 {
+			c.limiter = nil
+			return
+		}
+		c.limiter = &concurrencyLimiter{limit: limit, queue: queue, slots: make(map[string]chan struct{})}
+	}
+}
+
+// concurrencyLimiter is a counting semaphore per session
+type concurrencyLimiter struct {
+	slots map[string]chan struct{} // Buffered to limit; one token per in-flight call
+	limit int
+	queue bool
+	mu    sync.Mutex
+}
+
+// acquire takes a slot for the session; call release when the request ends
+// A nil limiter admits everything
+func (l *concurrencyLimiter) acquire(ctx context.Context, sessionID string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	slots, ok := l.slots[sessionID]
+	if !ok {
+		slots = make(chan struct{}, l.limit)
+		l.slots[sessionID] = slots
+	}
+	l.mu.Unlock()
+
+	release = func() { <-slots }
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if !l.queue {
+		return nil, fmt.Errorf("%w: %s has %d requests in flight", ErrSessionBusy, sessionID, l.limit)
+	}
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// forget drops a session's semaphore; in-flight calls still release into it
+func (l *concurrencyLimiter) forget(sessionID string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.slots, sessionID)
+}
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// gatedACPClient blocks every request until release is closed
+type gatedACPClient struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newGatedACPClient() *gatedACPClient {
+	return &gatedACPClient{started: make(chan struct{}, 10), release: make(chan struct{})}
+}
+
+func (m *gatedACPClient) SendMessage(content string) (*acp.AgentMessage, error) {
+	m.started <- struct{}{}
+	<-m.release
+	return &acp.AgentMessage{Type: "text", Content: "echo: " + content}, nil
+}
+
+func (m *gatedACPClient) Close() error { return nil }
+
+// setupLimitedSession starts an active session behind a one-request limit
+// and occupies its slot with a blocked request
+func setupLimitedSession(t *testing.T, queue bool) (*Manager, *Session, *gatedACPClient, chan error) {
+	t.Helper()
+	store := NewMemoryStore()
+	clock := &mockClock{now: time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)}
+	manager := NewManager(store, &mockIDGenerator{nextID: "s1"}, clock, &mockCleaner{}, &mockLogger{},
+		WithConcurrencyLimit(1, queue))
+	client := newGatedACPClient()
+	session := setupActiveSession(t, manager, client)
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := manager.SendMessage(context.Background(), session.GetID(), "first")
+		first <- err
+	}()
+	<-client.started
+	return manager, session, client, first
+}
+
+func TestConcurrencyLimit_RejectsWhenBusy(t *testing.T) {
+	manager, session, client, first := setupLimitedSession(t, false)
+	ctx := context.Background()
+
+	if _, err := manager.SendMessage(ctx, session.GetID(), "second"); !errors.Is(err, ErrSessionBusy) {
+		t.Fatalf("expected ErrSessionBusy, got %v", err)
+	}
+	if session.GetMessageCount() != 1 {
+		t.Errorf("expected the rejected message not to be counted, got %d", session.GetMessageCount())
+	}
+
+	close(client.release)
+	if err := <-first; err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+	if _, err := manager.SendMessage(ctx, session.GetID(), "third"); err != nil {
+		t.Errorf("expected a free slot after the first request, got %v", err)
+	}
+}
+
+func TestConcurrencyLimit_QueuesWhenEnabled(t *testing.T) {
+	manager, session, client, first := setupLimitedSession(t, true)
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := manager.SendMessage(context.Background(), session.GetID(), "second")
+		second <- err
+	}()
+	select {
+	case <-client.started:
+		t.Fatal("expected the second request to wait for the first")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// A queued request gives up when its context ends
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := manager.SendMessage(ctx, session.GetID(), "third"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	close(client.release)
+	if err := <-first; err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+	if err := <-second; err != nil {
+		t.Fatalf("queued request failed: %v", err)
+	}
+}
@@ -2,19 +2,43 @@ package session
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/clock"
 )
 
+// ErrSessionPaused is returned when agent traffic is attempted on a PAUSED session
+var ErrSessionPaused = errors.New("session is paused")
+
+// ErrSessionNotActive is returned when agent traffic is attempted before the
+// agent is attached or after the session began terminating
+var ErrSessionNotActive = errors.New("session is not active")
+
+// ErrNoAgent is returned when an active session has no ACP client attached
+var ErrNoAgent = errors.New("session has no agent attached")
+
+// ErrConnectionMismatch is returned when rebinding a session that is not
+// attached to the expected WebSocket connection
+var ErrConnectionMismatch = errors.New("session is attached to another connection")
+
+// ErrTooManyConnections is returned by Attach when a session already has as
+// many connections as WithMaxConnections allows
+var ErrTooManyConnections = errors.New("session has too many connections")
+
 // IDGenerator abstracts unique ID generation
 type IDGenerator interface {
 	Generate() string
 }
 
 // Clock abstracts time operations for deterministic testing
-type Clock interface {
-	Now() time.Time
-}
+// Shared with the relay package so no adapter is needed between them
+type Clock = clock.Clock
 
 // Cleaner abstracts cleanup operations for session termination
 // Allows pluggable strategies (no-op for Phase 1, real cleanup later)
@@ -29,6 +53,64 @@ type Logger interface {
 	Printf(format string, v ...interface{})
 }
 
+// CreateOption configures optional attributes of a new session
+// Options run before the session is stored; an error aborts Create
+type CreateOption func(*Session) error
+
+// WithOwner records the user who owns the session
+func WithOwner(ownerID string) CreateOption {
+	return func(s *Session) error {
+		s.setOwnerID(ownerID)
+		return nil
+	}
+}
+
+// maxSessionNameLength bounds human-readable session names
+const maxSessionNameLength = 128
+
+// WithName gives the session a human-readable name so clients need not juggle IDs
+// Names are unique per owner; surrounding whitespace is trimmed
+func WithName(name string) CreateOption {
+	return func(s *Session) error {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return fmt.Errorf("session name cannot be empty")
+		}
+		if len(name) > maxSessionNameLength {
+			return fmt.Errorf("session name exceeds %d bytes", maxSessionNameLength)
+		}
+		s.setName(name)
+		return nil
+	}
+}
+
+// WithLabels attaches caller-defined labels (e.g. project=foo, ticket=BUG-123)
+// so external tools can correlate sessions with their own entities
+// Label keys must be non-empty; the map is copied
+func WithLabels(labels map[string]string) CreateOption {
+	return func(s *Session) error {
+		for key := range labels {
+			if key == "" {
+				return fmt.Errorf("label key cannot be empty")
+			}
+		}
+		s.setLabels(labels)
+		return nil
+	}
+}
+
+// WithTTL limits how long the session may live, however active it is
+// A Reaper terminates it once the TTL has passed
+func WithTTL(ttl time.Duration) CreateOption {
+	return func(s *Session) error {
+		if ttl <= 0 {
+			return fmt.Errorf("ttl must be positive")
+		}
+		s.ttl = ttl
+		return nil
+	}
+}
+
 // Manager coordinates session lifecycle with dependency injection
 // Composes Store + StateMachine + Cleaner for testable orchestration
 type Manager struct {
@@ -37,6 +119,93 @@ type Manager struct {
 	clock   Clock
 	cleaner Cleaner
 	logger  Logger
+	events  *EventBus
+	history HistoryStore        // Optional conversation history (nil = disabled)
+	limiter *concurrencyLimiter // Optional per-session in-flight cap (nil = unlimited)
+	send    SendFunc            // Middleware chain ending in the session's ACP client
+	ttl     time.Duration       // Default session TTL (0 = sessions live until closed)
+	retain  time.Duration       // How long cleaned sessions stay queryable (0 = deleted at once)
+	starter AgentStarter        // Optional; starts agents for ReplaceAgent (nil = disabled)
+	cipher  *ArchiveCipher      // Optional; encrypts Export archives (nil = plaintext)
+	journal EventJournal        // Optional; backs GetTimeline (nil = disabled)
+
+	toolPolicy  *ToolPolicy  // Policy for sessions without their own (nil = none)
+	toolAuditor ToolAuditor  // Optional; receives tool call decisions
+	toolRunners []ToolRunner // Optional; run approved tool calls in the relay
+
+	maxConns int // Connections a session may have at once (< 1 = unlimited)
+
+	spawnFailures atomic.Int64 // Reported by Stats
+}
+
+// ManagerOption configures optional Manager behavior
+type ManagerOption func(*managerConfig)
+
+type managerConfig struct {
+	history    HistoryStore
+	limiter    *concurrencyLimiter
+	middleware []Middleware
+	ttl        time.Duration
+	retain     time.Duration
+	starter    AgentStarter
+	cipher     *ArchiveCipher
+	journal    EventJournal
+	maxConns   int
+
+	toolPolicy  *ToolPolicy
+	toolAuditor ToolAuditor
+	toolRunners []ToolRunner
+}
+
+// WithMiddleware wraps every SendMessage call in the given middleware
+// The first middleware is outermost; repeated options append to the chain
+func WithMiddleware(mw ...Middleware) ManagerOption {
+	return func(c *managerConfig) {
+		c.middleware = append(c.middleware, mw...)
+	}
+}
+
+// WithHistory records every prompt and reply in store so conversations can
+// be searched with SearchHistory
+func WithHistory(store HistoryStore) ManagerOption {
+	return func(c *managerConfig) {
+		c.history = store
+	}
+}
+
+// WithDefaultTTL gives sessions created without WithTTL a TTL of ttl
+// Zero or negative leaves them without one
+func WithDefaultTTL(ttl time.Duration) ManagerOption {
+	return func(c *managerConfig) {
+		c.ttl = ttl
+	}
+}
+
+// WithRetention keeps cleaned-up sessions in the store, queryable in state
+// CLEANED for post-mortems, for retention before PurgeRetained deletes them
+// Retained sessions release their agent role and name, and do not count
+// towards Count. Zero or negative deletes sessions as soon as they are cleaned.
+func WithRetention(retention time.Duration) ManagerOption {
+	return func(c *managerConfig) {
+		c.retain = retention
+	}
+}
+
+// WithArchiveCipher encrypts the archives written by Export and lets Import
+// read them; Import still accepts plaintext archives
+func WithArchiveCipher(c *ArchiveCipher) ManagerOption {
+	return func(mc *managerConfig) {
+		mc.cipher = c
+	}
+}
+
+// WithMaxConnections lets up to n WebSocket connections share a session, all
+// receiving its output; n < 1 means unlimited
+// Without this option a session has at most one connection.
+func WithMaxConnections(n int) ManagerOption {
+	return func(c *managerConfig) {
+		c.maxConns = n
+	}
 }
 
 // NewManager creates a session manager with injected dependencies.
@@ -49,7 +218,7 @@ type Manager struct {
 //
 // If future phases require graceful degradation, update the signature to return
 // (*Manager, error) and propagate validation through callers.
-func NewManager(store Store, idGen IDGenerator, clock Clock, cleaner Cleaner, logger Logger) *Manager {
+func NewManager(store Store, idGen IDGenerator, clock Clock, cleaner Cleaner, logger Logger, opts ...ManagerOption) *Manager {
 	if store == nil {
 		panic("store cannot be nil")
 	}
@@ -66,25 +235,56 @@ func NewManager(store Store, idGen IDGenerator, clock Clock, cleaner Cleaner, lo
 		panic("logger cannot be nil")
 	}
 
-	return &Manager{
+	cfg := &managerConfig{maxConns: 1}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	m := &Manager{
 		store:   store,
 		idGen:   idGen,
 		clock:   clock,
 		cleaner: cleaner,
 		logger:  logger,
+		events:  NewEventBus(),
+		history: cfg.history,
+		limiter: cfg.limiter,
+		ttl:     cfg.ttl,
+		retain:  cfg.retain,
+		starter: cfg.starter,
+		cipher:  cfg.cipher,
+		journal: cfg.journal,
+
+		toolPolicy:  cfg.toolPolicy,
+		toolAuditor: cfg.toolAuditor,
+		toolRunners: cfg.toolRunners,
+
+		maxConns: cfg.maxConns,
 	}
+	m.send = Chain(cfg.middleware...)(m.deliver)
+	return m
+}
+
+// Events returns the bus on which committed lifecycle transitions are published
+// Subscribe to push state changes to clients, metrics, or audit sinks
+func (m *Manager) Events() *EventBus {
+	return m.events
 }
 
 // Create creates a new session in CREATED state
 // Returns error if session for this agent role already exists
-func (m *Manager) Create(ctx context.Context, agentID string, ws WebSocketConn) (*Session, error) {
-	// Validate inputs
-	if agentID == "" {
-		return nil, fmt.Errorf("agentID cannot be empty")
-	}
+func (m *Manager) Create(ctx context.Context, agentID string, ws WebSocketConn, opts ...CreateOption) (*Session, error) {
 	if ws == nil {
 		return nil, fmt.Errorf("websocket connection cannot be nil")
 	}
+	return m.create(ctx, agentID, ws, opts...)
+}
+
+// create stores a new session; a nil ws leaves it detached
+func (m *Manager) create(ctx context.Context, agentID string, ws WebSocketConn, opts ...CreateOption) (*Session, error) {
+	if agentID == "" {
+		return nil, fmt.Errorf("agentID cannot be empty")
+	}
 
 	// Check if session already exists for this role
 	if existing := m.store.GetByRole(agentID); existing != nil {
@@ -102,10 +302,33 @@ func (m *Manager) Create(ctx context.Context, agentID string, ws WebSocketConn)
 		WebSocket: ws,
 	}
 
-	// Attach handle to session
-	session.mu.Lock()
-	session.setHandle(handle)
-	session.mu.Unlock()
+	// Attach handle and apply options before the session becomes visible
+	err := session.withLock(func(s *Session) error {
+		mono := m.clock.Monotonic()
+		s.setHandle(handle)
+		m.touch(s)
+		s.enterState(mono)
+		for _, opt := range opts {
+			if err := opt(s); err != nil {
+				return err
+			}
+		}
+		if s.ttl == 0 {
+			s.ttl = m.ttl
+		}
+		s.setExpiry(now, mono)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid session option: %w", err)
+	}
+
+	// Check name uniqueness up front for a clear error; the store enforces it atomically
+	if name := session.GetName(); name != "" {
+		if existing := m.store.GetByName(session.GetOwnerID(), name); existing != nil {
+			return nil, fmt.Errorf("%w: %q (session_id=%s)", ErrNameTaken, name, existing.GetID())
+		}
+	}
 
 	// Store session
 	if err := m.store.Create(session); err != nil {
@@ -113,6 +336,14 @@ func (m *Manager) Create(ctx context.Context, agentID string, ws WebSocketConn)
 	}
 
 	m.logger.Printf("Session created: id=%s agent=%s", sessionID, agentID)
+	m.events.Publish(LifecycleEvent{
+		Time:      now,
+		SessionID: sessionID,
+		Name:      session.GetName(),
+		AgentID:   agentID,
+		To:        StateCreated,
+		Event:     EventCreate,
+	})
 	return session, nil
 }
 
@@ -126,9 +357,21 @@ func (m *Manager) GetByRole(agentID string) *Session {
 	return m.store.GetByRole(agentID)
 }
 
-// List returns all sessions matching the filter
+// GetByName retrieves a session by its owner and human-readable name
+func (m *Manager) GetByName(ownerID, name string) *Session {
+	return m.store.GetByName(ownerID, name)
+}
+
+// List returns all sessions matching the filter, sorted and paginated
+// Idle durations in the filter are measured against the Manager's Clock
 func (m *Manager) List(filter *SessionFilter) []*Session {
-	return m.store.List(filter)
+	if filter == nil {
+		return m.store.List(nil)
+	}
+	scoped := *filter
+	scoped.now = m.clock.Now()
+	scoped.nowMono = m.clock.Monotonic()
+	return m.store.List(&scoped)
 }
 
 // BeginSpawn transitions session from CREATED to SPAWNING
@@ -139,11 +382,13 @@ func (m *Manager) BeginSpawn(ctx context.Context, sessionID string) error {
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	return m.transition(session, EventSpawn, "begin spawn")
+	return m.transition(session, EventSpawn, "begin spawn", nil)
 }
 
 // AttachAgent attaches ACP client and transitions to ACTIVE
-// Called after ACP process successfully spawned
+// Called after ACP process successfully spawned. If the client implements
+// AgentInfoReporter, what its agent reported at initialize is kept on the
+// session (see Session.GetAgentInfo).
 func (m *Manager) AttachAgent(ctx context.Context, sessionID, worktreeDir string, acpClient ACPClient) error {
 	session := m.store.Get(sessionID)
 	if session == nil {
@@ -159,19 +404,24 @@ func (m *Manager) AttachAgent(ctx context.Context, sessionID, worktreeDir string
 	}
 
 	// Update session with ACP client and worktree
-	session.mu.Lock()
-	handle := session.handle
-	if handle == nil {
-		session.mu.Unlock()
-		return fmt.Errorf("session has no handle")
+	err := m.store.Update(sessionID, func(session *Session) error {
+		handle := session.handle
+		if handle == nil {
+			return fmt.Errorf("session has no handle")
+		}
+		handle.ACPClient = acpClient
+		session.agentInfo = agentInfoOf(acpClient)
+		session.setWorktreeDir(worktreeDir)
+		m.touch(session)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	handle.ACPClient = acpClient
-	session.setWorktreeDir(worktreeDir)
-	session.setLastActive(m.clock.Now())
-	session.mu.Unlock()
+	session.renewAgentContext()
 
 	// Transition to ACTIVE
-	if err := m.transition(session, EventActivate, "attach agent"); err != nil {
+	if err := m.transition(session, EventActivate, "attach agent", nil); err != nil {
 		return err
 	}
 
@@ -182,33 +432,364 @@ func (m *Manager) AttachAgent(ctx context.Context, sessionID, worktreeDir string
 // RecordHeartbeat updates the last activity timestamp
 // Used to track session liveness
 func (m *Manager) RecordHeartbeat(ctx context.Context, sessionID string) error {
+	return m.store.Update(sessionID, func(session *Session) error {
+		m.touch(session)
+		return nil
+	})
+}
+
+// IncrementMessageCount increments the message counter
+// Returns ErrSessionPaused if the session is paused: callers must not forward
+// agent messages until the session is resumed
+func (m *Manager) IncrementMessageCount(ctx context.Context, sessionID string) error {
+	return m.store.Update(sessionID, func(session *Session) error {
+		if session.state == StatePaused {
+			return fmt.Errorf("%w: %s", ErrSessionPaused, sessionID)
+		}
+		session.incrementMessageCount()
+		m.touch(session)
+		return nil
+	})
+}
+
+// SendMessage forwards a prompt to the session's agent through the middleware
+// chain and returns the agent's reply
+// Returns ErrSessionPaused or ErrSessionNotActive if the session cannot accept
+// traffic, or ErrSessionBusy if its concurrency limit is reached; the message
+// is counted once admitted, before it is handed to the middleware. Tool calls
+// in the reply are then subject to the session's ToolPolicy.
+func (m *Manager) SendMessage(ctx context.Context, sessionID, content string) (*acp.AgentMessage, error) {
+	return m.StreamMessage(ctx, sessionID, content, nil)
+}
+
+// StreamMessage sends a prompt like SendMessage and calls onDelta for each
+// partial chunk the agent streams before its final reply
+// Agents whose client does not implement StreamingACPClient produce no deltas.
+// The request is cancelled if the session starts terminating; the error then
+// wraps ErrSessionTerminated.
+// If middleware retries the request, chunks from the failed attempt have
+// already been delivered; onDelta sees the new attempt start again at seq 1.
+func (m *Manager) StreamMessage(ctx context.Context, sessionID, content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
 	session := m.store.Get(sessionID)
 	if session == nil {
-		return fmt.Errorf("session not found: %s", sessionID)
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
 	}
+	ctx, stop := linkContext(ctx, session.Context())
+	defer stop()
 
-	session.mu.Lock()
-	session.setLastActive(m.clock.Now())
-	session.mu.Unlock()
+	release, err := m.admit(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	m.recordHistory(session, SpeakerUser, UserFromContext(ctx), content)
+	msg, err := m.send(ctx, AgentRequest{
+		SessionID:     sessionID,
+		AgentID:       session.AgentID,
+		Content:       content,
+		OnDelta:       onDelta,
+		CorrelationID: CorrelationIDFromContext(ctx),
+	})
+	if err == nil {
+		msg, err = m.applyToolPolicy(ctx, session, msg, onDelta)
+	}
+	if err == nil {
+		m.recordHistory(session, SpeakerAgent, "", replyText(msg))
+	}
+	return msg, session.terminatedError(err)
+}
+
+// admit takes a concurrency slot for a request to the session's agent and
+// counts the request, failing unless the session is active
+// The caller must call release once the agent has answered.
+func (m *Manager) admit(ctx context.Context, session *Session) (release func(), err error) {
+	sessionID := session.GetID()
+	release, err = m.limiter.acquire(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	err = m.store.Update(sessionID, func(session *Session) error {
+		if session.state == StatePaused {
+			return fmt.Errorf("%w: %s", ErrSessionPaused, sessionID)
+		}
+		if !IsActiveState(session.state) {
+			return fmt.Errorf("%w: %s (state=%s)", ErrSessionNotActive, sessionID, session.state)
+		}
+		session.incrementMessageCount()
+		m.touch(session)
+		return nil
+	})
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// SearchHistory finds prompts and replies matching query, newest first
+// Returns ErrHistoryDisabled unless the manager was built WithHistory
+func (m *Manager) SearchHistory(query HistoryQuery) ([]HistoryMatch, error) {
+	if m.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	return m.history.Search(query)
+}
+
+// recordHistory appends a conversation entry if history is enabled
+// Failures are logged; losing history must not fail the agent request
+func (m *Manager) recordHistory(session *Session, speaker Speaker, userID, content string) {
+	if m.history == nil || content == "" {
+		return
+	}
+	err := m.history.Append(HistoryEntry{
+		Time:      m.clock.Now(),
+		SessionID: session.GetID(),
+		AgentID:   session.AgentID,
+		OwnerID:   session.GetOwnerID(),
+		UserID:    userID,
+		Speaker:   speaker,
+		Content:   content,
+	})
+	if err != nil {
+		m.logger.Printf("Failed to record history: session=%s err=%v", session.GetID(), err)
+	}
+}
+
+// replyText flattens the searchable text of an agent reply (pure function)
+func replyText(msg *acp.AgentMessage) string {
+	if msg == nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range msg.AllParts() {
+		switch part.Type {
+		case acp.PartTypeText, acp.PartTypeCode:
+			texts = append(texts, part.Text)
+		case acp.PartTypePatch:
+			texts = append(texts, part.Path, part.Diff)
+		case acp.PartTypeError:
+			if part.Error != nil {
+				texts = append(texts, part.Error.Message)
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// deliver is the innermost SendFunc: it hands the request to the ACP client
+// The client is looked up on every call so retries see a replaced agent.
+// Clients that implement InterruptibleACPClient stop the agent when ctx or
+// the agent's context (see Session.AgentContext) is cancelled; others run to
+// completion.
+func (m *Manager) deliver(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	session := m.store.Get(req.SessionID)
+	if session == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, req.SessionID)
+	}
+	client := session.acpClient()
+	if client == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoAgent, req.SessionID)
+	}
+	ctx, stop := linkContext(ctx, session.AgentContext())
+	defer stop()
+
+	if interruptible, ok := client.(InterruptibleACPClient); ok {
+		return interruptible.SendMessageContext(ctx, req.Content, req.OnDelta)
+	}
+	if streamer, ok := client.(StreamingACPClient); ok && req.OnDelta != nil {
+		return streamer.SendMessageStream(req.Content, req.OnDelta)
+	}
+	return client.SendMessage(req.Content)
+}
+
+// Pause transitions session from ACTIVE to PAUSED
+// Agent message processing is suspended; if the ACP client implements
+// ProcessSuspender its process is also stopped. A failed suspend is logged
+// but the session stays PAUSED since no further messages will be forwarded.
+func (m *Manager) Pause(ctx context.Context, sessionID string, reason string) error {
+	session := m.store.Get(sessionID)
+	if session == nil {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+
+	if err := m.transition(session, EventPause, reason, nil); err != nil {
+		return err
+	}
+
+	if suspender, ok := session.acpClient().(ProcessSuspender); ok {
+		if err := suspender.Suspend(); err != nil {
+			m.logger.Printf("Failed to suspend agent process: session=%s err=%v", sessionID, err)
+		}
+	}
 
 	return nil
 }
 
-// IncrementMessageCount increments the message counter
-func (m *Manager) IncrementMessageCount(ctx context.Context, sessionID string) error {
+// Resume transitions session from PAUSED back to ACTIVE
+// A suspended agent process is continued first; if that fails the session
+// stays PAUSED so no messages are sent to a stopped process
+func (m *Manager) Resume(ctx context.Context, sessionID string) error {
 	session := m.store.Get(sessionID)
 	if session == nil {
-		return fmt.Errorf("session not found: %s", sessionID)
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	// Only continue the process for a paused session; other states fall
+	// through to the state machine, which rejects the transition
+	if session.GetState() == StatePaused {
+		if suspender, ok := session.acpClient().(ProcessSuspender); ok {
+			if err := suspender.Resume(); err != nil {
+				return fmt.Errorf("failed to resume agent process: %w", err)
+			}
+		}
+	}
+
+	return m.transition(session, EventResume, "resume", nil)
+}
+
+// Rebind moves a session from WebSocket from to WebSocket to (either may be nil)
+// The swap only happens if from is attached, so a detached session (from nil,
+// no connections at all) can be claimed by exactly one connection. Other
+// connections sharing the session are kept.
+func (m *Manager) Rebind(ctx context.Context, sessionID string, from, to WebSocketConn) error {
+	err := m.store.Update(sessionID, func(session *Session) error {
+		handle := session.handle
+		if handle == nil {
+			return fmt.Errorf("session has no handle")
+		}
+		conns := handle.Connections()
+		if from == nil {
+			if len(conns) > 0 {
+				return ErrConnectionMismatch
+			}
+			session.setHandle(handle.withConnections([]WebSocketConn{to}))
+			return nil
+		}
+		i := slices.IndexFunc(conns, func(c WebSocketConn) bool { return any(c) == any(from) })
+		if i < 0 {
+			return ErrConnectionMismatch
+		}
+		if to == nil {
+			conns = slices.Delete(conns, i, i+1)
+		} else {
+			conns[i] = to
+		}
+		session.setHandle(handle.withConnections(conns))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.logger.Printf("Session rebound: id=%s attached=%t", sessionID, to != nil)
+	return nil
+}
+
+// Attach adds conn to the connections sharing a session; every attached
+// connection receives the session's output and may prompt its agent
+// Attaching an already attached connection is a no-op; an observer becomes
+// an attached connection. Fails with ErrTooManyConnections once
+// WithMaxConnections is reached, and with ErrSessionTerminated for sessions
+// kept after cleanup by WithRetention. Sessions loaded from a shared store whose
+// agent runs on another relay can be attached too; they have no ACPClient here.
+func (m *Manager) Attach(ctx context.Context, sessionID string, conn WebSocketConn) error {
+	if conn == nil {
+		return fmt.Errorf("connection cannot be nil")
+	}
+	var attached int
+	err := m.store.Update(sessionID, func(session *Session) error {
+		if session.state == StateCleaned {
+			return fmt.Errorf("%w: %s", ErrSessionTerminated, sessionID)
+		}
+		handle := session.handle
+		if handle == nil {
+			handle = &Handle{} // Hosted by another relay sharing the store
+		}
+		if handle.HasConnection(conn) {
+			return nil
+		}
+		conns := handle.Connections()
+		if m.maxConns > 0 && len(conns) >= m.maxConns {
+			return fmt.Errorf("%w: %s has %d", ErrTooManyConnections, sessionID, len(conns))
+		}
+		// An observer attaching is promoted rather than listed twice
+		next := handle.withConnections(append(conns, conn))
+		next.Observers = slices.DeleteFunc(slices.Clone(handle.Observers), func(c WebSocketConn) bool { return any(c) == any(conn) })
+		session.setHandle(next)
+		attached = len(conns) + 1
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	session.mu.Lock()
-	session.incrementMessageCount()
-	session.setLastActive(m.clock.Now())
-	session.mu.Unlock()
+	m.logger.Printf("Connection attached: session=%s connections=%d", sessionID, attached)
+	return nil
+}
+
+// Observe adds conn as a read-only observer of a session: it receives the
+// session's output and state changes but may not prompt its agent
+// Observers do not count towards WithMaxConnections. Observing a session conn
+// is already attached to or observing is a no-op.
+func (m *Manager) Observe(ctx context.Context, sessionID string, conn WebSocketConn) error {
+	if conn == nil {
+		return fmt.Errorf("connection cannot be nil")
+	}
+	err := m.store.Update(sessionID, func(session *Session) error {
+		if session.state == StateCleaned {
+			return fmt.Errorf("%w: %s", ErrSessionTerminated, sessionID)
+		}
+		handle := session.handle
+		if handle == nil {
+			handle = &Handle{}
+		}
+		if handle.HasConnection(conn) || handle.HasObserver(conn) {
+			return nil
+		}
+		next := handle.withConnections(handle.Connections())
+		next.Observers = append(slices.Clone(handle.Observers), conn)
+		session.setHandle(next)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
+	m.logger.Printf("Observer attached: session=%s", sessionID)
 	return nil
 }
 
+// Detach removes conn, attached or observing, from a session and returns how
+// many attached connections remain
+// The session keeps running when the last one leaves; it is then detached
+// until a client attaches again.
+func (m *Manager) Detach(ctx context.Context, sessionID string, conn WebSocketConn) (int, error) {
+	var remaining int
+	err := m.store.Update(sessionID, func(session *Session) error {
+		handle := session.handle
+		if handle == nil || (!handle.HasConnection(conn) && !handle.HasObserver(conn)) {
+			return ErrConnectionMismatch
+		}
+		isConn := func(c WebSocketConn) bool { return any(c) == any(conn) }
+		conns := slices.DeleteFunc(handle.Connections(), isConn)
+		next := handle.withConnections(conns)
+		next.Observers = slices.DeleteFunc(slices.Clone(handle.Observers), isConn)
+		session.setHandle(next)
+		remaining = len(conns)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	m.logger.Printf("Connection detached: session=%s remaining=%d", sessionID, remaining)
+	return remaining, nil
+}
+
 // MarkTerminating transitions session to TERMINATING state
 // Idempotent - safe to call multiple times
 func (m *Manager) MarkTerminating(ctx context.Context, sessionID string, reason string) error {
@@ -222,7 +803,7 @@ func (m *Manager) MarkTerminating(ctx context.Context, sessionID string, reason
 	m.logger.Printf("Terminating session: id=%s reason=%s", sessionID, reason)
 
 	// Transition to TERMINATING (idempotent)
-	if err := m.transition(session, EventTerminate, reason); err != nil {
+	if err := m.transition(session, EventTerminate, reason, nil); err != nil {
 		// Log but don't fail - termination should be best-effort
 		m.logger.Printf("Transition error during termination: %v", err)
 	}
@@ -230,12 +811,36 @@ func (m *Manager) MarkTerminating(ctx context.Context, sessionID string, reason
 	return nil
 }
 
+// MarkFailed transitions session to TERMINATING because of an agent failure
+// Like MarkTerminating it is best-effort and idempotent; the cause is attached
+// to the published lifecycle event so subscribers can surface error details
+func (m *Manager) MarkFailed(ctx context.Context, sessionID string, cause error) error {
+	if cause == nil {
+		return fmt.Errorf("cause cannot be nil")
+	}
+
+	session := m.store.Get(sessionID)
+	if session == nil {
+		m.logger.Printf("Session not found during failure: %s (already cleaned?)", sessionID)
+		return nil
+	}
+
+	m.logger.Printf("Session failed: id=%s err=%v", sessionID, cause)
+
+	if err := m.transition(session, EventTerminate, cause.Error(), cause); err != nil {
+		m.logger.Printf("Transition error during failure: %v", err)
+	}
+
+	return nil
+}
+
 // CompleteCleanup performs cleanup and transitions to CLEANED state
-// Removes session from store after cleanup completes
+// Removes session from store after cleanup completes, or with WithRetention
+// keeps it, detached from its connections and agent, until PurgeRetained.
 // Idempotent - safe to call multiple times
 func (m *Manager) CompleteCleanup(ctx context.Context, sessionID string) error {
 	session := m.store.Get(sessionID)
-	if session == nil {
+	if session == nil || session.GetState() == StateCleaned {
 		// Already cleaned up
 		return nil
 	}
@@ -247,10 +852,21 @@ func (m *Manager) CompleteCleanup(ctx context.Context, sessionID string) error {
 	}
 
 	// Transition to CLEANED
-	if err := m.transition(session, EventClean, "cleanup complete"); err != nil {
+	if err := m.transition(session, EventClean, "cleanup complete", nil); err != nil {
 		m.logger.Printf("Transition error during cleanup: %v", err)
 		return fmt.Errorf("failed to transition to CLEANED state: %w", err)
 	}
+	m.limiter.forget(sessionID)
+
+	if m.retain > 0 {
+		// Subscribers have seen the transition; drop the runtime resources
+		_ = session.withLock(func(s *Session) error {
+			s.setHandle(nil)
+			return nil
+		})
+		m.logger.Printf("Session cleaned: id=%s retained=%s", sessionID, m.retain)
+		return nil
+	}
 
 	// Remove from store only after successful transition
 	m.store.Delete(sessionID)
@@ -259,30 +875,104 @@ func (m *Manager) CompleteCleanup(ctx context.Context, sessionID string) error {
 	return nil
 }
 
-// transition performs a state transition using the pure state machine
-// Coordinates mutex access with state machine logic
-func (m *Manager) transition(session *Session, event Event, reason string) error {
-	session.mu.Lock()
-	defer session.mu.Unlock()
+// PurgeRetained deletes cleaned sessions retained for longer than the
+// retention window (see WithRetention) and returns how many it deleted
+func (m *Manager) PurgeRetained() int {
+	if m.retain <= 0 {
+		return 0
+	}
+	cutoff := m.clock.Now().Add(-m.retain)
+	state := StateCleaned
+	purged := 0
+	for _, session := range m.store.List(&SessionFilter{State: &state}) {
+		if cleanedAt := session.GetCleanedAt(); cleanedAt.IsZero() || cleanedAt.Before(cutoff) {
+			m.store.Delete(session.GetID())
+			purged++
+		}
+	}
+	if purged > 0 {
+		m.logger.Printf("Retained sessions purged: count=%d", purged)
+	}
+	return purged
+}
 
-	currentState := session.state
+// transition performs a state transition using the pure state machine
+// The time spent in the previous state is measured on the monotonic clock, so
+// spawn latency (SPAWNING → ACTIVE) and termination time (TERMINATING → CLEANED)
+// are reported on the published event even if the wall clock steps.
+// Every lifecycle change goes through here: invalid transitions are rejected and
+// logged, and the change is applied through Store.Update so it is versioned.
+// Committed state changes are published on the event bus after the update;
+// idempotent self-transitions (e.g. repeated TERMINATE) are not republished.
+func (m *Manager) transition(session *Session, event Event, reason string, cause error) error {
+	var from, to SessionState
+	var elapsed time.Duration
+	err := m.store.Update(session.ID, func(session *Session) error {
+		// Compute and apply next state using pure state machine
+		currentState, nextState, err := session.applyEvent(event)
+		if err != nil {
+			m.logger.Printf("Session transition rejected: id=%s state=%s (event=%s reason=%s)",
+				session.ID, currentState, event, reason)
+			return fmt.Errorf("transition failed: %w", err)
+		}
+		from, to = currentState, nextState
+		if from != to {
+			elapsed = session.enterState(m.clock.Monotonic())
+		}
+		if to == StateCleaned && from != to {
+			session.cleanedAt = m.clock.Now()
+		}
+
+		m.logger.Printf("Session transition: id=%s %s → %s (event=%s reason=%s elapsed=%s)",
+			session.ID, currentState, nextState, event, reason, elapsed)
 
-	// Compute next state using pure state machine
-	nextState, err := NextState(currentState, event)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("transition failed: %w", err)
+		return err
 	}
 
-	// Apply state change
-	session.setState(nextState)
-
-	m.logger.Printf("Session transition: id=%s %s → %s (event=%s reason=%s)",
-		session.ID, currentState, nextState, event, reason)
+	if from != to {
+		if to == StateTerminating || to == StateCleaned {
+			session.cancelContexts()
+		}
+		if isSpawnFailure(event, from, cause) {
+			m.spawnFailures.Add(1)
+		}
+		m.events.Publish(LifecycleEvent{
+			Time:      m.clock.Now(),
+			Err:       cause,
+			SessionID: session.ID,
+			Name:      session.GetName(),
+			AgentID:   session.AgentID,
+			From:      from,
+			To:        to,
+			Event:     event,
+			Reason:    reason,
+			Elapsed:   elapsed,
+		})
+	}
 
 	return nil
 }
 
-// Count returns total number of sessions
+// touch records activity on the session at the current wall and monotonic time
+// Must hold the session lock (called inside Store.Update or withLock)
+func (m *Manager) touch(session *Session) {
+	session.setLastActive(m.clock.Now(), m.clock.Monotonic())
+}
+
+// Count returns the number of sessions, not counting retained ones
+// Safe for concurrent use; see Stats for a breakdown
 func (m *Manager) Count() int {
-	return m.store.Count()
+	if m.retain <= 0 {
+		return m.store.Count()
+	}
+	n := 0
+	m.store.Range(func(session *Session) {
+		if session.GetState() != StateCleaned {
+			n++
+		}
+	})
+	return n
 }
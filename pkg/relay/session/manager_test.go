@@ -2,10 +2,14 @@ package session
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
 )
 
 // --- Test Mocks ---
@@ -19,13 +23,24 @@ func (m *mockIDGenerator) Generate() string {
 }
 
 type mockClock struct {
-	now time.Time
+	now  time.Time
+	mono time.Duration
 }
 
 func (m *mockClock) Now() time.Time {
 	return m.now
 }
 
+func (m *mockClock) Monotonic() time.Duration {
+	return m.mono
+}
+
+// advance moves both the wall and monotonic readings forward
+func (m *mockClock) advance(d time.Duration) {
+	m.now = m.now.Add(d)
+	m.mono += d
+}
+
 type mockCleaner struct {
 	called    int
 	mu        sync.Mutex
@@ -59,6 +74,17 @@ func (m *mockLogger) Printf(format string, v ...interface{}) {
 	m.messages = append(m.messages, fmt.Sprintf(format, v...))
 }
 
+func (m *mockLogger) Contains(substring string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range m.messages {
+		if strings.Contains(msg, substring) {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *mockLogger) MessageCount() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -73,15 +99,41 @@ func (m *mockWebSocket) Close() error                      { return nil }
 
 type mockACPClient struct{}
 
-func (m *mockACPClient) SendMessage(content string) error { return nil }
-func (m *mockACPClient) Close() error                     { return nil }
+func (m *mockACPClient) SendMessage(content string) (*acp.AgentMessage, error) {
+	return &acp.AgentMessage{Type: "text", Content: "echo: " + content}, nil
+}
+
+func (m *mockACPClient) Close() error { return nil }
+
+type mockSuspendableACPClient struct {
+	mockACPClient
+	suspended  bool
+	resumeErr  error
+	suspendErr error
+}
+
+func (m *mockSuspendableACPClient) Suspend() error {
+	if m.suspendErr != nil {
+		return m.suspendErr
+	}
+	m.suspended = true
+	return nil
+}
+
+func (m *mockSuspendableACPClient) Resume() error {
+	if m.resumeErr != nil {
+		return m.resumeErr
+	}
+	m.suspended = false
+	return nil
+}
 
 // --- Test Setup ---
 
 func setupManager() (*Manager, *mockIDGenerator, *mockClock, *mockCleaner, *mockLogger) {
 	store := NewMemoryStore()
 	idGen := &mockIDGenerator{nextID: "test-session-id"}
-	clock := &mockClock{now: time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)}
+	clock := &mockClock{now: time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC), mono: time.Second}
 	cleaner := &mockCleaner{}
 	logger := &mockLogger{}
 
@@ -276,6 +328,31 @@ func TestManager_BeginSpawn(t *testing.T) {
 	}
 }
 
+func TestManager_BeginSpawn_RejectsInvalidTransition(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, logger := setupManager()
+
+	session, _ := manager.Create(ctx, "auth", &mockWebSocket{})
+	_ = manager.BeginSpawn(ctx, session.GetID())
+
+	// SPAWNING + SPAWN is not a valid transition
+	err := manager.BeginSpawn(ctx, session.GetID())
+	var transitionErr TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("expected TransitionError, got %v", err)
+	}
+
+	if session.GetState() != StateSpawning {
+		t.Errorf("expected state to remain SPAWNING, got %s", session.GetState())
+	}
+	if session.GetVersion() != 1 {
+		t.Errorf("expected rejected transition not to bump version, got %d", session.GetVersion())
+	}
+	if !logger.Contains("Session transition rejected") {
+		t.Error("expected rejected transition to be logged")
+	}
+}
+
 func TestManager_AttachAgent(t *testing.T) {
 	ctx := context.Background()
 	manager, _, _, _, _ := setupManager()
@@ -391,6 +468,316 @@ func TestManager_IncrementMessageCount(t *testing.T) {
 	}
 }
 
+// setupActiveSession creates a session and drives it to ACTIVE with the given client
+func setupActiveSession(t *testing.T, manager *Manager, acpClient ACPClient) *Session {
+	t.Helper()
+	ctx := context.Background()
+
+	session, err := manager.Create(ctx, "auth", &mockWebSocket{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, session.GetID()); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, session.GetID(), "/tmp/worktree", acpClient); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+	return session
+}
+
+func TestManager_Rebind(t *testing.T) {
+	manager, _, _, _, _ := setupManager()
+	ctx := context.Background()
+	acpClient := &mockACPClient{}
+	session := setupActiveSession(t, manager, acpClient)
+	// Named so the connections are distinct (pointers to zero-size values may compare equal)
+	type namedWebSocket struct {
+		mockWebSocket
+		name string
+	}
+	original := session.GetHandle().WebSocket
+	other := &namedWebSocket{name: "other"}
+
+	if err := manager.Rebind(ctx, session.GetID(), other, nil); !errors.Is(err, ErrConnectionMismatch) {
+		t.Fatalf("expected ErrConnectionMismatch for a non-owner, got %v", err)
+	}
+	if err := manager.Rebind(ctx, session.GetID(), original, nil); err != nil {
+		t.Fatalf("detach failed: %v", err)
+	}
+	if err := manager.Rebind(ctx, session.GetID(), nil, other); err != nil {
+		t.Fatalf("reattach failed: %v", err)
+	}
+	if err := manager.Rebind(ctx, session.GetID(), nil, original); !errors.Is(err, ErrConnectionMismatch) {
+		t.Errorf("expected an attached session to be unclaimable, got %v", err)
+	}
+
+	handle := session.GetHandle()
+	if handle.WebSocket != other || handle.ACPClient != acpClient {
+		t.Errorf("expected agent kept and connection swapped, got %+v", handle)
+	}
+	if err := manager.Rebind(ctx, "missing", nil, other); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestManager_AttachDetach(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "s1"}, &mockClock{}, &mockCleaner{}, &mockLogger{}, WithMaxConnections(2))
+	acpClient := &mockACPClient{}
+	session := setupActiveSession(t, manager, acpClient)
+	type namedWebSocket struct {
+		mockWebSocket
+		name string
+	}
+	first := session.GetHandle().WebSocket
+	second := &namedWebSocket{name: "second"}
+	third := &namedWebSocket{name: "third"}
+
+	if err := manager.Attach(ctx, "s1", second); err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+	if err := manager.Attach(ctx, "s1", second); err != nil {
+		t.Errorf("expected attaching twice to be a no-op, got %v", err)
+	}
+	if err := manager.Attach(ctx, "s1", third); !errors.Is(err, ErrTooManyConnections) {
+		t.Errorf("expected ErrTooManyConnections, got %v", err)
+	}
+	if conns := session.GetHandle().Connections(); len(conns) != 2 || conns[0] != first || conns[1] != second {
+		t.Fatalf("expected both connections in attach order, got %v", conns)
+	}
+
+	// The primary leaving promotes the next connection
+	if remaining, err := manager.Detach(ctx, "s1", first); err != nil || remaining != 1 {
+		t.Fatalf("expected 1 remaining, got %d (%v)", remaining, err)
+	}
+	handle := session.GetHandle()
+	if handle.WebSocket != second || len(handle.Others) != 0 || handle.ACPClient != acpClient {
+		t.Errorf("expected second promoted with the agent kept, got %+v", handle)
+	}
+	if _, err := manager.Detach(ctx, "s1", first); !errors.Is(err, ErrConnectionMismatch) {
+		t.Errorf("expected ErrConnectionMismatch for a detached connection, got %v", err)
+	}
+	if remaining, err := manager.Detach(ctx, "s1", second); err != nil || remaining != 0 {
+		t.Fatalf("expected 0 remaining, got %d (%v)", remaining, err)
+	}
+	if session.GetState() != StateActive || session.GetHandle().HasConnection(second) {
+		t.Errorf("expected the session to keep running detached, got %s", session.GetState())
+	}
+	if err := manager.Attach(ctx, "missing", first); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestManager_Observe(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+	session := setupActiveSession(t, manager, &mockACPClient{})
+	type namedWebSocket struct {
+		mockWebSocket
+		name string
+	}
+	owner := session.GetHandle().WebSocket
+	observer := &namedWebSocket{name: "observer"}
+
+	if err := manager.Observe(ctx, session.GetID(), observer); err != nil {
+		t.Fatalf("Observe failed: %v", err)
+	}
+	handle := session.GetHandle()
+	if handle.HasConnection(observer) || !handle.HasObserver(observer) || len(handle.Recipients()) != 2 {
+		t.Fatalf("expected a read-only recipient, got %+v", handle)
+	}
+
+	// Observers stay when the owner leaves and may attach in its place
+	if err := manager.Rebind(ctx, session.GetID(), owner, nil); err != nil {
+		t.Fatalf("detach failed: %v", err)
+	}
+	if !session.GetHandle().HasObserver(observer) {
+		t.Error("expected the observer to outlive the owner")
+	}
+	if err := manager.Attach(ctx, session.GetID(), observer); err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+	if handle := session.GetHandle(); !handle.HasConnection(observer) || handle.HasObserver(observer) {
+		t.Errorf("expected the observer promoted, got %+v", handle)
+	}
+	if remaining, err := manager.Detach(ctx, session.GetID(), observer); err != nil || remaining != 0 {
+		t.Errorf("expected 0 remaining, got %d (%v)", remaining, err)
+	}
+}
+
+func TestManager_PauseResume(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+	acpClient := &mockSuspendableACPClient{}
+	session := setupActiveSession(t, manager, acpClient)
+
+	if err := manager.Pause(ctx, session.GetID(), "user away"); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if session.GetState() != StatePaused {
+		t.Errorf("expected state PAUSED, got %s", session.GetState())
+	}
+	if !acpClient.suspended {
+		t.Error("expected agent process to be suspended")
+	}
+
+	// Agent traffic is refused while paused
+	err := manager.IncrementMessageCount(ctx, session.GetID())
+	if !errors.Is(err, ErrSessionPaused) {
+		t.Errorf("expected ErrSessionPaused, got %v", err)
+	}
+	if session.GetMessageCount() != 0 {
+		t.Errorf("expected message count 0 while paused, got %d", session.GetMessageCount())
+	}
+
+	if err := manager.Resume(ctx, session.GetID()); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if session.GetState() != StateActive {
+		t.Errorf("expected state ACTIVE, got %s", session.GetState())
+	}
+	if acpClient.suspended {
+		t.Error("expected agent process to be resumed")
+	}
+	if err := manager.IncrementMessageCount(ctx, session.GetID()); err != nil {
+		t.Errorf("expected messages to be accepted after resume, got %v", err)
+	}
+}
+
+func TestManager_Pause_WithoutSuspender(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+	session := setupActiveSession(t, manager, &mockACPClient{})
+
+	// Clients without process control still pause logically
+	if err := manager.Pause(ctx, session.GetID(), "user away"); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if session.GetState() != StatePaused {
+		t.Errorf("expected state PAUSED, got %s", session.GetState())
+	}
+}
+
+func TestManager_Pause_SuspendErrorStillPauses(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, logger := setupManager()
+	session := setupActiveSession(t, manager, &mockSuspendableACPClient{suspendErr: fmt.Errorf("no such process")})
+
+	if err := manager.Pause(ctx, session.GetID(), "user away"); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if session.GetState() != StatePaused {
+		t.Errorf("expected state PAUSED, got %s", session.GetState())
+	}
+	if !logger.Contains("Failed to suspend agent process") {
+		t.Error("expected suspend failure to be logged")
+	}
+}
+
+func TestManager_Resume_ProcessErrorStaysPaused(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+	acpClient := &mockSuspendableACPClient{}
+	session := setupActiveSession(t, manager, acpClient)
+	_ = manager.Pause(ctx, session.GetID(), "user away")
+
+	acpClient.resumeErr = fmt.Errorf("no such process")
+	if err := manager.Resume(ctx, session.GetID()); err == nil {
+		t.Fatal("expected error when process cannot be resumed")
+	}
+	if session.GetState() != StatePaused {
+		t.Errorf("expected state to remain PAUSED, got %s", session.GetState())
+	}
+}
+
+func TestManager_PauseResume_InvalidStates(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+	session, _ := manager.Create(ctx, "auth", &mockWebSocket{})
+
+	if err := manager.Pause(ctx, session.GetID(), "too early"); err == nil {
+		t.Error("expected error pausing a CREATED session")
+	}
+	if err := manager.Resume(ctx, session.GetID()); err == nil {
+		t.Error("expected error resuming a CREATED session")
+	}
+	if err := manager.Pause(ctx, "missing", "none"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestManager_PublishesLifecycleEvents(t *testing.T) {
+	ctx := context.Background()
+	manager, _, clock, _, _ := setupManager()
+
+	var events []LifecycleEvent
+	manager.Events().Subscribe(func(e LifecycleEvent) { events = append(events, e) })
+
+	session := setupActiveSession(t, manager, &mockACPClient{})
+	_ = manager.MarkTerminating(ctx, session.GetID(), "user requested")
+	_ = manager.MarkTerminating(ctx, session.GetID(), "again") // idempotent, not republished
+
+	expected := []struct{ from, to SessionState }{
+		{"", StateCreated},
+		{StateCreated, StateSpawning},
+		{StateSpawning, StateActive},
+		{StateActive, StateTerminating},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i, want := range expected {
+		got := events[i]
+		if got.From != want.from || got.To != want.to {
+			t.Errorf("event %d: expected %s → %s, got %s → %s", i, want.from, want.to, got.From, got.To)
+		}
+		if got.SessionID != session.GetID() || got.AgentID != "auth" {
+			t.Errorf("event %d: unexpected identity %s/%s", i, got.SessionID, got.AgentID)
+		}
+		if !got.Time.Equal(clock.now) {
+			t.Errorf("event %d: expected time from clock", i)
+		}
+	}
+	if events[0].Event != EventCreate {
+		t.Errorf("expected creation event first, got %s", events[0].Event)
+	}
+	if events[3].Reason != "user requested" {
+		t.Errorf("expected reason 'user requested', got %q", events[3].Reason)
+	}
+}
+
+func TestManager_MarkFailed(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+
+	var events []LifecycleEvent
+	manager.Events().Subscribe(func(e LifecycleEvent) { events = append(events, e) })
+
+	session, _ := manager.Create(ctx, "auth", &mockWebSocket{})
+	_ = manager.BeginSpawn(ctx, session.GetID())
+
+	cause := fmt.Errorf("claude-code-acp exited with status 1")
+	if err := manager.MarkFailed(ctx, session.GetID(), cause); err != nil {
+		t.Fatalf("MarkFailed failed: %v", err)
+	}
+
+	if session.GetState() != StateTerminating {
+		t.Errorf("expected state TERMINATING, got %s", session.GetState())
+	}
+	last := events[len(events)-1]
+	if !errors.Is(last.Err, cause) {
+		t.Errorf("expected event to carry failure cause, got %v", last.Err)
+	}
+	if last.From != StateSpawning {
+		t.Errorf("expected failure from SPAWNING, got %s", last.From)
+	}
+
+	if err := manager.MarkFailed(ctx, session.GetID(), nil); err == nil {
+		t.Error("expected error for nil cause")
+	}
+}
+
 func TestManager_MarkTerminating(t *testing.T) {
 	ctx := context.Background()
 	manager, _, _, _, _ := setupManager()
@@ -525,6 +912,151 @@ func TestManager_List(t *testing.T) {
 	}
 }
 
+func TestManager_List_OwnerAndIdle(t *testing.T) {
+	ctx := context.Background()
+	manager, idGen, clock, _, _ := setupManager()
+
+	idGen.nextID = "session-1"
+	_, _ = manager.Create(ctx, "auth", &mockWebSocket{}, WithOwner("alice"))
+
+	clock.advance(10 * time.Minute)
+	idGen.nextID = "session-2"
+	_, _ = manager.Create(ctx, "db", &mockWebSocket{}, WithOwner("bob"))
+
+	owner := "alice"
+	owned := manager.List(&SessionFilter{OwnerID: &owner})
+	if len(owned) != 1 || owned[0].GetOwnerID() != "alice" {
+		t.Errorf("expected alice's session only, got %v", ids(owned))
+	}
+
+	// Idle time is measured against the manager clock, not the wall clock
+	idle := 5 * time.Minute
+	stale := manager.List(&SessionFilter{IdleLongerThan: &idle})
+	if !equalIDs(ids(stale), []string{"session-1"}) {
+		t.Errorf("expected only session-1 to be idle, got %v", ids(stale))
+	}
+
+	page := manager.List(&SessionFilter{Descending: true, Limit: 1})
+	if !equalIDs(ids(page), []string{"session-2"}) {
+		t.Errorf("expected newest session first, got %v", ids(page))
+	}
+}
+
+func TestManager_Create_WithLabels(t *testing.T) {
+	ctx := context.Background()
+	manager, idGen, _, _, _ := setupManager()
+
+	labels := map[string]string{"project": "foo", "ticket": "BUG-123"}
+	session, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithLabels(labels))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Caller's map is copied, not retained
+	labels["project"] = "bar"
+	if got := session.GetLabels()["project"]; got != "foo" {
+		t.Errorf("expected label project=foo, got %s", got)
+	}
+
+	matched := manager.List(&SessionFilter{Labels: map[string]string{"ticket": "BUG-123"}})
+	if len(matched) != 1 {
+		t.Errorf("expected 1 labelled session, got %d", len(matched))
+	}
+	unmatched := manager.List(&SessionFilter{Labels: map[string]string{"ticket": "BUG-999"}})
+	if len(unmatched) != 0 {
+		t.Errorf("expected no sessions, got %d", len(unmatched))
+	}
+
+	idGen.nextID = "other"
+	if _, err := manager.Create(ctx, "db", &mockWebSocket{}, WithLabels(map[string]string{"": "x"})); err == nil {
+		t.Error("expected error for empty label key")
+	}
+	if manager.Count() != 1 {
+		t.Errorf("expected rejected session not to be stored, got %d", manager.Count())
+	}
+}
+
+func TestManager_Create_WithName(t *testing.T) {
+	ctx := context.Background()
+	manager, idGen, _, _, _ := setupManager()
+
+	idGen.nextID = "session-1"
+	session, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithOwner("alice"), WithName("  login refactor "))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if session.GetName() != "login refactor" {
+		t.Errorf("expected trimmed name, got %q", session.GetName())
+	}
+	if got := manager.GetByName("alice", "login refactor"); got != session {
+		t.Error("expected lookup by name to return the session")
+	}
+
+	// Same name for the same owner is rejected
+	idGen.nextID = "session-2"
+	_, err = manager.Create(ctx, "db", &mockWebSocket{}, WithOwner("alice"), WithName("login refactor"))
+	if !errors.Is(err, ErrNameTaken) {
+		t.Errorf("expected ErrNameTaken, got %v", err)
+	}
+
+	// Same name for a different owner is fine
+	idGen.nextID = "session-3"
+	if _, err := manager.Create(ctx, "tests", &mockWebSocket{}, WithOwner("bob"), WithName("login refactor")); err != nil {
+		t.Errorf("expected name to be reusable across owners, got %v", err)
+	}
+
+	if _, err := manager.Create(ctx, "docs", &mockWebSocket{}, WithName("   ")); err == nil {
+		t.Error("expected error for blank name")
+	}
+}
+
+func TestManager_IdleUsesMonotonicClock(t *testing.T) {
+	ctx := context.Background()
+	manager, _, clock, _, _ := setupManager()
+
+	session, _ := manager.Create(ctx, "auth", &mockWebSocket{})
+	clock.advance(10 * time.Minute)
+
+	// Wall clock steps back an hour (e.g. NTP correction); idle time must not go negative
+	clock.now = clock.now.Add(-time.Hour)
+
+	idle := 5 * time.Minute
+	stale := manager.List(&SessionFilter{IdleLongerThan: &idle})
+	if len(stale) != 1 || stale[0] != session {
+		t.Errorf("expected session to be idle per monotonic clock, got %d sessions", len(stale))
+	}
+}
+
+func TestManager_TransitionReportsElapsed(t *testing.T) {
+	ctx := context.Background()
+	manager, _, clock, _, _ := setupManager()
+
+	var events []LifecycleEvent
+	manager.Events().Subscribe(func(e LifecycleEvent) { events = append(events, e) })
+
+	session, _ := manager.Create(ctx, "auth", &mockWebSocket{})
+	clock.advance(time.Second)
+	_ = manager.BeginSpawn(ctx, session.GetID())
+	clock.advance(3 * time.Second)
+	_ = manager.AttachAgent(ctx, session.GetID(), "/tmp/worktree", &mockACPClient{})
+	_ = manager.MarkTerminating(ctx, session.GetID(), "done")
+	clock.advance(500 * time.Millisecond)
+	_ = manager.MarkTerminating(ctx, session.GetID(), "again") // self-transition keeps the timer
+	clock.advance(500 * time.Millisecond)
+	_ = manager.CompleteCleanup(ctx, session.GetID())
+
+	want := []time.Duration{0, time.Second, 3 * time.Second, 0, time.Second}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(events))
+	}
+	for i, d := range want {
+		if events[i].Elapsed != d {
+			t.Errorf("event %d (%s → %s): expected elapsed %s, got %s",
+				i, events[i].From, events[i].To, d, events[i].Elapsed)
+		}
+	}
+}
+
 func TestManager_ConcurrentOperations(t *testing.T) {
 	ctx := context.Background()
 	manager, idGen, _, _, _ := setupManager()
@@ -556,3 +1088,54 @@ func TestManager_ConcurrentOperations(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestManager_Retention(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	idGen := &mockIDGenerator{nextID: "old"}
+	clock := &mockClock{now: time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC), mono: time.Second}
+	manager := NewManager(store, idGen, clock, &mockCleaner{}, &mockLogger{}, WithRetention(time.Hour))
+
+	old := setupActiveSession(t, manager, &mockACPClient{})
+	if err := manager.MarkTerminating(ctx, old.GetID(), "done"); err != nil {
+		t.Fatalf("MarkTerminating failed: %v", err)
+	}
+	if err := manager.CompleteCleanup(ctx, old.GetID()); err != nil {
+		t.Fatalf("CompleteCleanup failed: %v", err)
+	}
+
+	kept := manager.Get(old.GetID())
+	if kept == nil || kept.GetState() != StateCleaned {
+		t.Fatal("expected the cleaned session kept for the retention window")
+	}
+	if !kept.GetCleanedAt().Equal(clock.now) || kept.GetHandle() != nil {
+		t.Errorf("expected cleanedAt recorded and the handle dropped, got %v %v", kept.GetCleanedAt(), kept.GetHandle())
+	}
+	if manager.Count() != 0 {
+		t.Errorf("expected retained sessions not counted, got %d", manager.Count())
+	}
+	if err := manager.Attach(ctx, old.GetID(), &mockWebSocket{}); !errors.Is(err, ErrSessionTerminated) {
+		t.Errorf("expected ErrSessionTerminated attaching a retained session, got %v", err)
+	}
+
+	// The role is free again
+	idGen.nextID = "new"
+	if _, err := manager.Create(ctx, "auth", &mockWebSocket{}); err != nil {
+		t.Fatalf("expected the retained session's role released, got %v", err)
+	}
+	if got := manager.GetByRole("auth"); got == nil || got.GetID() != "new" {
+		t.Errorf("expected the role to find the new session, got %v", got)
+	}
+
+	clock.advance(30 * time.Minute)
+	if n := manager.PurgeRetained(); n != 0 {
+		t.Errorf("expected nothing purged within the window, got %d", n)
+	}
+	clock.advance(time.Hour)
+	if n := manager.PurgeRetained(); n != 1 || manager.Get(old.GetID()) != nil {
+		t.Errorf("expected the retained session purged after the window, got %d", n)
+	}
+	if manager.Get("new") == nil {
+		t.Error("expected the live session kept")
+	}
+}
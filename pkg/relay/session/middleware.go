@@ -0,0 +1,128 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/redact"
+)
+
+// AgentRequest describes one prompt sent to a session's agent
+// OnDelta, if set, receives partial response chunks as the agent streams them.
+// CorrelationID is the client's ID for the request, set with WithCorrelationID
+// ("" = none).
+type AgentRequest struct {
+	OnDelta       func(acp.Delta)
+	SessionID     string
+	AgentID       string
+	Content       string
+	CorrelationID string
+}
+
+// correlationKey is the context key under which WithCorrelationID stores the ID
+type correlationKey struct{}
+
+// WithCorrelationID returns a context carrying the client's ID for a request
+// Prompts sent with it have the ID in AgentRequest.CorrelationID.
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, correlationID)
+}
+
+// CorrelationIDFromContext returns the ID set by WithCorrelationID ("" = none)
+func CorrelationIDFromContext(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationKey{}).(string)
+	return correlationID
+}
+
+// SendFunc delivers a prompt to an agent and returns its reply
+type SendFunc func(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error)
+
+// Middleware wraps a SendFunc to add cross-cutting behavior (logging, metrics,
+// redaction, retry, caching) around agent communication
+// Middleware may rewrite the request or response, short-circuit, or call next
+// more than once; it must be safe for concurrent use across sessions
+type Middleware func(next SendFunc) SendFunc
+
+// Chain composes middleware so the first argument is the outermost wrapper
+func Chain(mw ...Middleware) Middleware {
+	return func(next SendFunc) SendFunc {
+		for i := len(mw) - 1; i >= 0; i-- {
+			next = mw[i](next)
+		}
+		return next
+	}
+}
+
+// LoggingMiddleware logs each agent request and its outcome
+// Prompt content is not logged; put a redaction middleware inside this one
+// if content should be recorded
+func LoggingMiddleware(logger Logger) Middleware {
+	return func(next SendFunc) SendFunc {
+		return func(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+			logger.Printf("Agent request: session=%s agent=%s correlation=%s bytes=%d",
+				req.SessionID, req.AgentID, req.CorrelationID, len(req.Content))
+			msg, err := next(ctx, req)
+			if err != nil {
+				logger.Printf("Agent request failed: session=%s err=%v", req.SessionID, err)
+				return nil, err
+			}
+			logger.Printf("Agent response: session=%s type=%s", req.SessionID, msg.Type)
+			return msg, nil
+		}
+	}
+}
+
+// TranscriptMiddleware logs prompt and response content with secrets redacted
+// Only the logged copy is redacted; the agent still receives the original prompt
+func TranscriptMiddleware(logger Logger, redactor *redact.Redactor) Middleware {
+	return func(next SendFunc) SendFunc {
+		return func(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+			logger.Printf("Agent prompt: session=%s agent=%s content=%q",
+				req.SessionID, req.AgentID, redactor.String(req.Content))
+			msg, err := next(ctx, req)
+			if err != nil {
+				logger.Printf("Agent error: session=%s err=%s", req.SessionID, redactor.String(err.Error()))
+				return nil, err
+			}
+			logger.Printf("Agent reply: session=%s type=%s content=%q",
+				req.SessionID, msg.Type, redactor.String(msg.Content))
+			return msg, nil
+		}
+	}
+}
+
+// RetryMiddleware retries failed requests up to maxAttempts times in total
+// Stops early if the context is done or the session is no longer active
+func RetryMiddleware(maxAttempts int) Middleware {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return func(next SendFunc) SendFunc {
+		return func(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+			var err error
+			for attempt := 1; attempt <= maxAttempts; attempt++ {
+				var msg *acp.AgentMessage
+				msg, err = next(ctx, req)
+				if err == nil {
+					return msg, nil
+				}
+				if ctx.Err() != nil || isPermanentSendError(err) {
+					return nil, err
+				}
+			}
+			return nil, fmt.Errorf("agent request failed after %d attempts: %w", maxAttempts, err)
+		}
+	}
+}
+
+// isPermanentSendError reports whether retrying cannot help
+func isPermanentSendError(err error) bool {
+	for _, target := range []error{ErrSessionNotFound, ErrSessionPaused, ErrSessionNotActive, ErrNoAgent, ErrCircuitOpen} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/redact"
+)
+
+type flakyACPClient struct {
+	mockACPClient
+	failures int
+	calls    int
+}
+
+func (m *flakyACPClient) SendMessage(content string) (*acp.AgentMessage, error) {
+	m.calls++
+	if m.calls <= m.failures {
+		return nil, errors.New("broken pipe")
+	}
+	return m.mockACPClient.SendMessage(content)
+}
+
+type streamingACPClient struct {
+	mockACPClient
+	chunks []string
+}
+
+func (m *streamingACPClient) SendMessageStream(content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	for i, chunk := range m.chunks {
+		onDelta(acp.Delta{RequestID: 1, Seq: i + 1, Content: chunk})
+	}
+	return m.SendMessage(content)
+}
+
+func TestChain_OrdersOutermostFirst(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next SendFunc) SendFunc {
+			return func(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+				order = append(order, name)
+				return next(ctx, req)
+			}
+		}
+	}
+	terminal := func(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+		order = append(order, "client")
+		return &acp.AgentMessage{Type: "text"}, nil
+	}
+
+	send := Chain(record("outer"), record("inner"))(terminal)
+	_, _ = send(context.Background(), AgentRequest{})
+
+	want := []string{"outer", "inner", "client"}
+	if len(order) != len(want) {
+		t.Fatalf("expected %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, order)
+			break
+		}
+	}
+}
+
+func TestManager_SendMessage_ThroughMiddleware(t *testing.T) {
+	ctx := WithCorrelationID(context.Background(), "req-1")
+	var seen []AgentRequest
+	capture := func(next SendFunc) SendFunc {
+		return func(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+			seen = append(seen, req)
+			req.Content = "rewritten"
+			return next(ctx, req)
+		}
+	}
+
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "session-1"}, &mockClock{},
+		&mockCleaner{}, &mockLogger{}, WithMiddleware(capture))
+	session := setupActiveSession(t, manager, &mockACPClient{})
+
+	msg, err := manager.SendMessage(ctx, session.GetID(), "hello")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if msg.Content != "echo: rewritten" {
+		t.Errorf("expected middleware to rewrite prompt, got %q", msg.Content)
+	}
+	if len(seen) != 1 || seen[0].AgentID != "auth" || seen[0].Content != "hello" || seen[0].CorrelationID != "req-1" {
+		t.Errorf("unexpected request seen by middleware: %+v", seen)
+	}
+	if session.GetMessageCount() != 1 {
+		t.Errorf("expected message count 1, got %d", session.GetMessageCount())
+	}
+}
+
+func TestManager_StreamMessage(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+
+	t.Run("streaming client delivers deltas", func(t *testing.T) {
+		session := setupActiveSession(t, manager, &streamingACPClient{chunks: []string{"echo: ", "hi"}})
+		var got []string
+		msg, err := manager.StreamMessage(ctx, session.GetID(), "hi", func(d acp.Delta) {
+			got = append(got, d.Content)
+		})
+		if err != nil {
+			t.Fatalf("StreamMessage failed: %v", err)
+		}
+		if len(got) != 2 || got[0]+got[1] != msg.Content {
+			t.Errorf("expected deltas to reassemble %q, got %v", msg.Content, got)
+		}
+	})
+
+	t.Run("non-streaming client replies without deltas", func(t *testing.T) {
+		manager, _, _, _, _ := setupManager()
+		session := setupActiveSession(t, manager, &mockACPClient{})
+		calls := 0
+		msg, err := manager.StreamMessage(ctx, session.GetID(), "hi", func(acp.Delta) { calls++ })
+		if err != nil {
+			t.Fatalf("StreamMessage failed: %v", err)
+		}
+		if calls != 0 || msg.Content != "echo: hi" {
+			t.Errorf("expected plain reply and no deltas, got %d deltas and %q", calls, msg.Content)
+		}
+	})
+}
+
+func TestManager_SendMessage_RejectsInactiveSessions(t *testing.T) {
+	ctx := context.Background()
+	manager, _, _, _, _ := setupManager()
+
+	if _, err := manager.SendMessage(ctx, "missing", "hi"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+
+	session, _ := manager.Create(ctx, "auth", &mockWebSocket{})
+	if _, err := manager.SendMessage(ctx, session.GetID(), "hi"); !errors.Is(err, ErrSessionNotActive) {
+		t.Errorf("expected ErrSessionNotActive before attach, got %v", err)
+	}
+
+	_ = manager.BeginSpawn(ctx, session.GetID())
+	_ = manager.AttachAgent(ctx, session.GetID(), "/tmp/worktree", &mockACPClient{})
+	_ = manager.Pause(ctx, session.GetID(), "away")
+	if _, err := manager.SendMessage(ctx, session.GetID(), "hi"); !errors.Is(err, ErrSessionPaused) {
+		t.Errorf("expected ErrSessionPaused, got %v", err)
+	}
+	if session.GetMessageCount() != 0 {
+		t.Errorf("expected rejected messages not to be counted, got %d", session.GetMessageCount())
+	}
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		failures  int
+		attempts  int
+		wantErr   bool
+		wantCalls int
+	}{
+		{"succeeds after transient failure", 1, 3, false, 2},
+		{"gives up after max attempts", 5, 3, true, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &flakyACPClient{failures: tt.failures}
+			manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "session-1"}, &mockClock{},
+				&mockCleaner{}, &mockLogger{}, WithMiddleware(RetryMiddleware(tt.attempts)))
+			session := setupActiveSession(t, manager, client)
+
+			_, err := manager.SendMessage(ctx, session.GetID(), "hi")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if client.calls != tt.wantCalls {
+				t.Errorf("expected %d calls, got %d", tt.wantCalls, client.calls)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware_OmitsContent(t *testing.T) {
+	logger := &mockLogger{}
+	send := LoggingMiddleware(logger)(func(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+		return &acp.AgentMessage{Type: "text"}, nil
+	})
+
+	_, _ = send(context.Background(), AgentRequest{SessionID: "s1", Content: "sk-secret"})
+
+	if logger.Contains("sk-secret") {
+		t.Error("expected prompt content not to be logged")
+	}
+	if !logger.Contains("session=s1") {
+		t.Error("expected request to be logged")
+	}
+}
+
+func TestTranscriptMiddleware_RedactsSecrets(t *testing.T) {
+	logger := &mockLogger{}
+	secret := "sk-ant-REDACTED"
+	var forwarded string
+	send := TranscriptMiddleware(logger, redact.Default())(func(ctx context.Context, req AgentRequest) (*acp.AgentMessage, error) {
+		forwarded = req.Content
+		return &acp.AgentMessage{Type: "text", Content: "stored key " + secret}, nil
+	})
+
+	_, _ = send(context.Background(), AgentRequest{SessionID: "s1", Content: "use key " + secret})
+
+	if forwarded != "use key "+secret {
+		t.Errorf("expected agent to receive the original prompt, got %q", forwarded)
+	}
+	if logger.Contains(secret) {
+		t.Error("expected secret to be redacted from transcript log")
+	}
+	if !logger.Contains("[REDACTED]") {
+		t.Error("expected redaction placeholder in transcript log")
+	}
+}
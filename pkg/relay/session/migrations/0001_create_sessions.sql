@@ -0,0 +1,22 @@
+-- Session metadata shared by relays using PostgresStore
+-- Runtime resources (WebSocket, agent process) stay with the relay that owns them.
+CREATE TABLE IF NOT EXISTS sessions (
+    id            TEXT PRIMARY KEY,
+    agent_id      TEXT NOT NULL UNIQUE,
+    owner_id      TEXT NOT NULL DEFAULT '',
+    name          TEXT NOT NULL DEFAULT '',
+    labels        JSONB NOT NULL DEFAULT '{}',
+    state         TEXT NOT NULL,
+    worktree_dir  TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL,
+    last_active   TIMESTAMPTZ NOT NULL,
+    expires_at    TIMESTAMPTZ,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    version       BIGINT NOT NULL DEFAULT 0
+);
+
+-- Names are unique per owner; unnamed sessions are exempt
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_owner_name
+    ON sessions (owner_id, name) WHERE name <> '';
+
+CREATE INDEX IF NOT EXISTS sessions_created_at ON sessions (created_at);
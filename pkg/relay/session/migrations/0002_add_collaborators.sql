@@ -0,0 +1,2 @@
+-- Users a session's owner shares it with (sorted JSON array of user IDs)
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS collaborators JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+-- System prompt and model settings of a session's agent, fixed at creation
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS agent_config JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+-- What a session's agent reported about itself and its capabilities at
+-- initialize; NULL when the agent was not initialized
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS agent_info JSONB;
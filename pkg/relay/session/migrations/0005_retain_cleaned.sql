@@ -0,0 +1,11 @@
+-- Cleaned sessions may be retained for post-mortems (Manager WithRetention);
+-- they give up their agent role and name, so uniqueness covers live sessions only
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS cleaned_at TIMESTAMPTZ;
+
+ALTER TABLE sessions DROP CONSTRAINT IF EXISTS sessions_agent_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_live_agent_id
+    ON sessions (agent_id) WHERE state <> 'CLEANED';
+
+DROP INDEX IF EXISTS sessions_owner_name;
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_live_owner_name
+    ON sessions (owner_id, name) WHERE name <> '' AND state <> 'CLEANED';
@@ -1,8 +1,12 @@
 package session
 
 import (
+	"context"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
 )
 
 // SessionState represents the lifecycle state of a session
@@ -18,6 +22,9 @@ const (
 	// StateActive indicates ACP process running, accepting messages
 	StateActive SessionState = "ACTIVE"
 
+	// StatePaused indicates the agent is attached but message processing is suspended
+	StatePaused SessionState = "PAUSED"
+
 	// StateTerminating indicates cleanup in progress, resources being freed
 	StateTerminating SessionState = "TERMINATING"
 
@@ -33,7 +40,7 @@ func (s SessionState) String() string {
 // IsValid returns true if the state is a recognized SessionState
 func (s SessionState) IsValid() bool {
 	switch s {
-	case StateCreated, StateSpawning, StateActive, StateTerminating, StateCleaned:
+	case StateCreated, StateSpawning, StateActive, StatePaused, StateTerminating, StateCleaned:
 		return true
 	default:
 		return false
@@ -48,12 +55,33 @@ type Session struct {
 	AgentID string // Role: "auth", "db", "tests"
 
 	// Mutable fields (protected by mu)
-	state        SessionState
-	worktreeDir  string
-	handle       *Handle
-	createdAt    time.Time
-	lastActive   time.Time
-	messageCount int
+	state           SessionState
+	ownerID         string                // User who created the session (empty = anonymous)
+	collaborators   []string              // Users the owner shares the session with, sorted
+	name            string                // Optional human-readable name, unique per owner
+	labels          map[string]string     // Caller-defined key/value annotations
+	agent           AgentConfig           // Agent settings, fixed at creation
+	agentInfo       *acp.InitializeResult // What the attached agent reported at initialize (nil = unknown)
+	worktreeDir     string
+	handle          *Handle
+	createdAt       time.Time
+	lastActive      time.Time
+	lastActiveMono  time.Duration // Clock.Monotonic at last activity (0 = unknown)
+	stateSince      time.Duration // Clock.Monotonic when the current state was entered
+	ttl             time.Duration // Lifetime requested at creation (0 = manager default)
+	expiresAt       time.Time     // Wall time the TTL runs out (zero = never)
+	expiresMono     time.Duration // Clock.Monotonic deadline (0 = unknown)
+	cleanedAt       time.Time     // When the session was cleaned up (zero until then)
+	messageCount    int
+	version         uint64        // Incremented on every successful Store.Update
+	resources       ResourceUsage // Latest agent process sample (runtime only)
+	pendingToolCall *acp.ToolCall // Call the agent waits on, left for a client (runtime only)
+
+	// Cancellation tree (see context.go), created on first use
+	ctx         context.Context
+	cancel      context.CancelCauseFunc
+	agentCtx    context.Context
+	cancelAgent context.CancelCauseFunc
 
 	mu sync.RWMutex
 }
@@ -61,9 +89,17 @@ type Session struct {
 // Handle encapsulates runtime resources associated with a session
 // Separates connection/process management from session metadata
 type Handle struct {
-	// WebSocket connection to PWA client
+	// WebSocket connection to PWA client; the earliest when several are attached
 	WebSocket WebSocketConn
 
+	// Others are further connections sharing the session (e.g. more browser
+	// tabs), in attach order; empty while WebSocket is nil
+	Others []WebSocketConn
+
+	// Observers receive the session's output but may not prompt its agent
+	// (session:observe), in attach order
+	Observers []WebSocketConn
+
 	// ACP client wrapper (set during SPAWNING → ACTIVE transition)
 	ACPClient ACPClient
 
@@ -71,6 +107,54 @@ type Handle struct {
 	CancelFunc func()
 }
 
+// Connections returns every attached connection, WebSocket first
+func (h *Handle) Connections() []WebSocketConn {
+	if h == nil || h.WebSocket == nil {
+		return nil
+	}
+	return append([]WebSocketConn{h.WebSocket}, h.Others...)
+}
+
+// HasConnection reports whether conn is attached (observers are not)
+func (h *Handle) HasConnection(conn WebSocketConn) bool {
+	return containsConn(h.Connections(), conn)
+}
+
+// Recipients returns every connection that receives the session's output:
+// the attached connections followed by the observers
+func (h *Handle) Recipients() []WebSocketConn {
+	if h == nil {
+		return nil
+	}
+	return append(h.Connections(), h.Observers...)
+}
+
+// HasObserver reports whether conn observes the session
+func (h *Handle) HasObserver(conn WebSocketConn) bool {
+	return h != nil && containsConn(h.Observers, conn)
+}
+
+// containsConn reports whether conn is in conns (pure function)
+func containsConn(conns []WebSocketConn, conn WebSocketConn) bool {
+	for _, c := range conns {
+		if any(c) == any(conn) {
+			return true
+		}
+	}
+	return false
+}
+
+// withConnections returns a copy of the handle attached to conns (pure function)
+// Handles are replaced rather than mutated since readers hold them unlocked.
+func (h *Handle) withConnections(conns []WebSocketConn) *Handle {
+	next := &Handle{ACPClient: h.ACPClient, CancelFunc: h.CancelFunc, Observers: h.Observers}
+	if len(conns) > 0 {
+		next.WebSocket = conns[0]
+		next.Others = append([]WebSocketConn(nil), conns[1:]...)
+	}
+	return next
+}
+
 // WebSocketConn abstracts WebSocket operations
 // Matches existing relay.WebSocketConn interface for compatibility
 type WebSocketConn interface {
@@ -80,12 +164,50 @@ type WebSocketConn interface {
 }
 
 // ACPClient abstracts ACP process operations
-// Will be implemented by pkg/acp.Client
+// Implemented by pkg/acp.Client
 type ACPClient interface {
-	SendMessage(content string) error
+	SendMessage(content string) (*acp.AgentMessage, error)
 	Close() error
 }
 
+// Ensure the real ACP client satisfies the interface
+var _ ACPClient = (*acp.Client)(nil)
+
+// StreamingACPClient is optionally implemented by ACP clients that can report
+// partial responses while the agent is still generating
+type StreamingACPClient interface {
+	SendMessageStream(content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error)
+}
+
+// InterruptibleACPClient is optionally implemented by ACP clients that can
+// interrupt the agent when a request's context is cancelled
+type InterruptibleACPClient interface {
+	SendMessageContext(ctx context.Context, content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error)
+}
+
+// Ensure the real ACP client streams and can be interrupted
+var (
+	_ StreamingACPClient     = (*acp.Client)(nil)
+	_ InterruptibleACPClient = (*acp.Client)(nil)
+)
+
+// AgentInfoReporter is optionally implemented by ACP clients that know what
+// their agent reported about itself at initialize
+type AgentInfoReporter interface {
+	Info() *acp.InitializeResult // nil if the agent was not initialized
+}
+
+// Ensure the real ACP client reports its agent's capabilities
+var _ AgentInfoReporter = (*acp.Client)(nil)
+
+// ProcessSuspender is optionally implemented by ACP clients that can stop and
+// continue their agent process (SIGSTOP/SIGCONT on Unix)
+// Manager uses it on pause/resume so idle agents don't burn CPU or tokens
+type ProcessSuspender interface {
+	Suspend() error
+	Resume() error
+}
+
 // NewSession creates a new session in CREATED state
 // Pure function - no side effects, no I/O
 func NewSession(id, agentID string, createdAt time.Time) *Session {
@@ -117,6 +239,54 @@ func (s *Session) GetState() SessionState {
 	return s.state
 }
 
+// GetOwnerID returns the ID of the user who owns the session
+func (s *Session) GetOwnerID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ownerID
+}
+
+// GetCollaborators returns the users the owner shares the session with, sorted
+func (s *Session) GetCollaborators() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.collaborators)
+}
+
+// CanAccess reports whether userID may prompt the session: its owner or a
+// collaborator; anonymous sessions (no owner) are open to everyone
+func (s *Session) CanAccess(userID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ownerID == "" || userID == s.ownerID || (userID != "" && slices.Contains(s.collaborators, userID))
+}
+
+// GetName returns the human-readable session name (empty if unnamed)
+func (s *Session) GetName() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.name
+}
+
+// GetLabels returns a copy of the session labels (nil if none were set)
+func (s *Session) GetLabels() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyLabels(s.labels)
+}
+
+// HasLabels reports whether every key/value in want is present on the session
+func (s *Session) HasLabels(want map[string]string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for key, value := range want {
+		if got, ok := s.labels[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
 // GetWorktreeDir returns the git worktree directory path
 func (s *Session) GetWorktreeDir() string {
 	s.mu.RLock()
@@ -129,6 +299,21 @@ func (s *Session) GetCreatedAt() time.Time {
 	return s.createdAt
 }
 
+// GetExpiresAt returns when the session's TTL runs out (zero if it has none)
+func (s *Session) GetExpiresAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.expiresAt
+}
+
+// GetCleanedAt returns when the session was cleaned up (zero if it has not been)
+// Only retained sessions (see WithRetention) are seen cleaned.
+func (s *Session) GetCleanedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cleanedAt
+}
+
 // GetLastActive returns the last activity timestamp
 func (s *Session) GetLastActive() time.Time {
 	s.mu.RLock()
@@ -143,6 +328,14 @@ func (s *Session) GetMessageCount() int {
 	return s.messageCount
 }
 
+// GetVersion returns the optimistic concurrency version
+// Starts at 0 and increases by one for each committed Store.Update
+func (s *Session) GetVersion() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
 // GetHandle returns the session handle (may be nil if not attached)
 func (s *Session) GetHandle() *Handle {
 	s.mu.RLock()
@@ -150,13 +343,67 @@ func (s *Session) GetHandle() *Handle {
 	return s.handle
 }
 
-// --- Package-private mutators (called only by Manager) ---
+// acpClient returns the attached ACP client, or nil if no agent is attached
+func (s *Session) acpClient() ACPClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.handle == nil {
+		return nil
+	}
+	return s.handle.ACPClient
+}
+
+// --- Transactional mutation (the only place the write lock is taken) ---
+
+// withLock runs fn while holding the session write lock
+// Store implementations use this to apply UpdateFunc transactions; fn must use
+// the package-private setters below and never the locking getters above
+func (s *Session) withLock(fn func(*Session) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s)
+}
+
+// applyEvent advances the session through the state machine (must hold lock)
+// Returns the previous and new states; the session is unchanged on error
+func (s *Session) applyEvent(event Event) (from, to SessionState, err error) {
+	from = s.state
+	to, err = NextState(from, event)
+	if err != nil {
+		return from, from, err
+	}
+	s.setState(to)
+	return from, to, nil
+}
+
+// --- Package-private mutators (must hold lock, called inside withLock) ---
 
 // setState updates the session state (must hold lock)
 func (s *Session) setState(state SessionState) {
 	s.state = state
 }
 
+// setOwnerID updates the owning user (must hold lock)
+func (s *Session) setOwnerID(ownerID string) {
+	s.ownerID = ownerID
+}
+
+// setCollaborators replaces the users the session is shared with (must hold lock)
+func (s *Session) setCollaborators(userIDs []string) {
+	s.collaborators = userIDs
+}
+
+// setName sets the human-readable name (must hold lock)
+// Only called before the session is stored: store name indexes assume it never changes
+func (s *Session) setName(name string) {
+	s.name = name
+}
+
+// setLabels replaces the session labels with a copy of labels (must hold lock)
+func (s *Session) setLabels(labels map[string]string) {
+	s.labels = copyLabels(labels)
+}
+
 // setWorktreeDir updates the worktree directory path (must hold lock)
 func (s *Session) setWorktreeDir(dir string) {
 	s.worktreeDir = dir
@@ -168,11 +415,176 @@ func (s *Session) setHandle(h *Handle) {
 }
 
 // setLastActive updates the last activity timestamp (must hold lock)
-func (s *Session) setLastActive(t time.Time) {
+// mono is the Clock.Monotonic reading taken at the same moment
+func (s *Session) setLastActive(t time.Time, mono time.Duration) {
 	s.lastActive = t
+	s.lastActiveMono = mono
+}
+
+// enterState records the monotonic time the current state began (must hold lock)
+// Returns how long the session spent in the previous state
+func (s *Session) enterState(mono time.Duration) time.Duration {
+	elapsed := mono - s.stateSince
+	s.stateSince = mono
+	return elapsed
+}
+
+// idleFor returns how long the session has been inactive
+// Prefers monotonic readings; falls back to wall time when the session has no
+// monotonic reading (e.g. it was loaded from a persistent store)
+func (s *Session) idleFor(now time.Time, nowMono time.Duration) time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.lastActiveMono != 0 && nowMono != 0 {
+		return nowMono - s.lastActiveMono
+	}
+	return now.Sub(s.lastActive)
+}
+
+// setExpiry starts the TTL clock at now/mono (must hold lock)
+// A session without a TTL never expires
+func (s *Session) setExpiry(now time.Time, mono time.Duration) {
+	if s.ttl <= 0 {
+		return
+	}
+	s.expiresAt = now.Add(s.ttl)
+	s.expiresMono = mono + s.ttl
+}
+
+// expiresIn returns how long until the TTL runs out (negative once past it)
+// ok is false for sessions without a TTL. Like idleFor it prefers monotonic
+// readings and falls back to wall time.
+func (s *Session) expiresIn(now time.Time, nowMono time.Duration) (remaining time.Duration, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.expiresAt.IsZero() {
+		return 0, false
+	}
+	if s.expiresMono != 0 && nowMono != 0 {
+		return s.expiresMono - nowMono, true
+	}
+	return s.expiresAt.Sub(now), true
 }
 
 // incrementMessageCount increases message counter (must hold lock)
 func (s *Session) incrementMessageCount() {
 	s.messageCount++
 }
+
+// bumpVersion records a committed update (must hold lock)
+func (s *Session) bumpVersion() {
+	s.version++
+}
+
+// copyLabels returns an independent copy of labels, or nil if it is empty
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
+
+// sessionRecord is the persisted form of a session: its metadata without
+// runtime resources (handle) or monotonic readings, which are per-process
+type sessionRecord struct {
+	ID            string                `json:"id"`
+	AgentID       string                `json:"agentId"`
+	OwnerID       string                `json:"ownerId,omitempty"`
+	Collaborators []string              `json:"collaborators,omitempty"`
+	Name          string                `json:"name,omitempty"`
+	Labels        map[string]string     `json:"labels,omitempty"`
+	Agent         *AgentConfig          `json:"agent,omitempty"`
+	AgentInfo     *acp.InitializeResult `json:"agentInfo,omitempty"`
+	State         SessionState          `json:"state"`
+	WorktreeDir   string                `json:"worktreeDir,omitempty"`
+	CreatedAt     time.Time             `json:"createdAt"`
+	LastActive    time.Time             `json:"lastActive"`
+	ExpiresAt     time.Time             `json:"expiresAt"` // Zero = no TTL
+	CleanedAt     time.Time             `json:"cleanedAt"` // Zero = not cleaned up
+	MessageCount  int                   `json:"messageCount"`
+	Version       uint64                `json:"version"`
+}
+
+// record snapshots the persisted fields (must hold lock)
+func (s *Session) record() sessionRecord {
+	return sessionRecord{
+		ID:            s.ID,
+		AgentID:       s.AgentID,
+		OwnerID:       s.ownerID,
+		Collaborators: slices.Clone(s.collaborators),
+		Name:          s.name,
+		Labels:        copyLabels(s.labels),
+		Agent:         s.agent.ptr(),
+		AgentInfo:     cloneAgentInfo(s.agentInfo),
+		State:         s.state,
+		WorktreeDir:   s.worktreeDir,
+		CreatedAt:     s.createdAt,
+		LastActive:    s.lastActive,
+		ExpiresAt:     s.expiresAt,
+		CleanedAt:     s.cleanedAt,
+		MessageCount:  s.messageCount,
+		Version:       s.version,
+	}
+}
+
+// applyRecord overwrites the persisted fields with rec (must hold lock)
+// ID, AgentID, createdAt and the agent config are immutable and left alone.
+// A record at the session's own version changes nothing; otherwise monotonic
+// readings are cleared, since another process may have written it, and
+// durations fall back to wall time.
+func (s *Session) applyRecord(rec sessionRecord) {
+	if rec.Version == s.version {
+		return // Versions are unique per write: nothing changed
+	}
+	s.ownerID = rec.OwnerID
+	s.collaborators = slices.Clone(rec.Collaborators)
+	s.name = rec.Name
+	s.labels = copyLabels(rec.Labels)
+	s.agentInfo = cloneAgentInfo(rec.AgentInfo)
+	if s.state != rec.State {
+		s.stateSince = 0
+	}
+	s.state = rec.State
+	s.worktreeDir = rec.WorktreeDir
+	s.lastActive = rec.LastActive
+	s.lastActiveMono = 0
+	s.expiresAt = rec.ExpiresAt
+	s.expiresMono = 0
+	s.cleanedAt = rec.CleanedAt
+	s.messageCount = rec.MessageCount
+	s.version = rec.Version
+}
+
+// sessionFromRecord rebuilds a detached session from its persisted form
+func sessionFromRecord(rec sessionRecord) *Session {
+	return &Session{
+		ID:            rec.ID,
+		AgentID:       rec.AgentID,
+		state:         rec.State,
+		ownerID:       rec.OwnerID,
+		collaborators: slices.Clone(rec.Collaborators),
+		name:          rec.Name,
+		labels:        copyLabels(rec.Labels),
+		agent:         agentConfigFromRecord(rec.Agent),
+		agentInfo:     cloneAgentInfo(rec.AgentInfo),
+		worktreeDir:   rec.WorktreeDir,
+		createdAt:     rec.CreatedAt,
+		lastActive:    rec.LastActive,
+		expiresAt:     rec.ExpiresAt,
+		cleanedAt:     rec.CleanedAt,
+		messageCount:  rec.MessageCount,
+		version:       rec.Version,
+	}
+}
+
+// agentConfigFromRecord copies a persisted agent config (nil = zero)
+func agentConfigFromRecord(cfg *AgentConfig) AgentConfig {
+	if cfg == nil {
+		return AgentConfig{}
+	}
+	return cfg.clone()
+}
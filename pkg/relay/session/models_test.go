@@ -0,0 +1,55 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSession_ApplyEvent(t *testing.T) {
+	session := NewSession("session-1", "auth", time.Now())
+
+	err := session.withLock(func(s *Session) error {
+		from, to, err := s.applyEvent(EventSpawn)
+		if err != nil {
+			return err
+		}
+		if from != StateCreated || to != StateSpawning {
+			t.Errorf("expected CREATED → SPAWNING, got %s → %s", from, to)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("applyEvent failed: %v", err)
+	}
+
+	if state := session.GetState(); state != StateSpawning {
+		t.Errorf("expected state SPAWNING, got %s", state)
+	}
+}
+
+func TestSession_ApplyEvent_InvalidLeavesStateUnchanged(t *testing.T) {
+	session := NewSession("session-1", "auth", time.Now())
+
+	err := session.withLock(func(s *Session) error {
+		_, _, err := s.applyEvent(EventClean)
+		return err
+	})
+
+	var transitionErr TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("expected TransitionError, got %v", err)
+	}
+	if state := session.GetState(); state != StateCreated {
+		t.Errorf("expected state to remain CREATED, got %s", state)
+	}
+}
+
+func TestSession_WithLock_PropagatesError(t *testing.T) {
+	session := NewSession("session-1", "auth", time.Now())
+	want := errors.New("abort")
+
+	if err := session.withLock(func(s *Session) error { return want }); !errors.Is(err, want) {
+		t.Errorf("expected abort error, got %v", err)
+	}
+}
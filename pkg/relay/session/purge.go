@@ -0,0 +1,118 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// PurgeReport records what PurgeOwner erased, for compliance records
+// It holds IDs and counts only, never transcript content.
+type PurgeReport struct {
+	OwnerID            string    `json:"ownerId"`
+	PurgedAt           time.Time `json:"purgedAt"`
+	Sessions           []string  `json:"sessions"`                     // Terminated and removed from the store
+	HistoryEntries     int       `json:"historyEntries"`               // Transcript entries deleted
+	Workspaces         []string  `json:"workspaces"`                   // Workspace directories removed
+	RetainedWorkspaces []string  `json:"retainedWorkspaces,omitempty"` // Still used by a session that was not purged
+	Errors             []string  `json:"errors,omitempty"`
+}
+
+// PurgeOwner erases a user's data: every session they own is terminated and
+// removed, their conversation history is deleted, and the sessions'
+// workspace directories are removed
+// Sessions retained after cleanup by WithRetention are removed too.
+// Workspaces still used by a session that was not purged are retained and
+// listed in the report. History recorded before entries carried an owner, or
+// kept in a store that is not a HistoryDeleter, cannot be found; the latter
+// is reported as an error. Failures do not stop the purge: everything that
+// can be erased is, and each failure is listed in the report. Sessions
+// created while the purge runs are not included.
+func (m *Manager) PurgeOwner(ctx context.Context, ownerID string) (PurgeReport, error) {
+	if ownerID == "" {
+		return PurgeReport{}, errors.New("owner ID cannot be empty")
+	}
+	report := PurgeReport{OwnerID: ownerID, PurgedAt: m.clock.Now(), Sessions: []string{}, Workspaces: []string{}}
+
+	worktrees := make(map[string]bool)
+	for _, sess := range m.List(&SessionFilter{OwnerID: &ownerID}) {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if dir := sess.GetWorktreeDir(); dir != "" {
+			worktrees[dir] = true
+		}
+		if sess.GetState() != StateCleaned {
+			if err := m.terminate(ctx, sess, "owner purged"); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("session %s: %v", sess.GetID(), err))
+				continue
+			}
+		}
+		m.store.Delete(sess.GetID()) // Not kept for WithRetention
+		report.Sessions = append(report.Sessions, sess.GetID())
+	}
+
+	if m.history != nil {
+		if deleter, ok := m.history.(HistoryDeleter); ok {
+			n, err := deleter.DeleteOwner(ownerID)
+			report.HistoryEntries = n
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("history: %v", err))
+			}
+		} else {
+			report.Errors = append(report.Errors, "history: store cannot delete entries")
+		}
+	}
+
+	m.purgeWorkspaces(worktrees, &report)
+
+	m.logger.Printf("Owner purged: owner=%s sessions=%d history=%d workspaces=%d errors=%d",
+		ownerID, len(report.Sessions), report.HistoryEntries, len(report.Workspaces), len(report.Errors))
+	return report, nil
+}
+
+// purgeWorkspaces removes the given directories unless a remaining session uses them
+func (m *Manager) purgeWorkspaces(dirs map[string]bool, report *PurgeReport) {
+	shared := make(map[string]bool)
+	m.store.Range(func(s *Session) {
+		if s.GetState() == StateCleaned {
+			return // Retained after cleanup; no longer uses its workspace
+		}
+		if dir := s.GetWorktreeDir(); dirs[dir] {
+			shared[dir] = true
+		}
+	})
+
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted) // Stable report order
+	for _, dir := range sorted {
+		if shared[dir] {
+			report.RetainedWorkspaces = append(report.RetainedWorkspaces, dir)
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("workspace %s: %v", dir, err))
+			continue
+		}
+		report.Workspaces = append(report.Workspaces, dir)
+	}
+}
+
+// terminate ends a session outright: TERMINATING, agent closed, then cleaned
+// and removed from the store
+func (m *Manager) terminate(ctx context.Context, sess *Session, reason string) error {
+	id := sess.GetID()
+	if err := m.MarkTerminating(ctx, id, reason); err != nil {
+		m.logger.Printf("Failed to terminate session: id=%s err=%v", id, err)
+	}
+	if client := sess.acpClient(); client != nil {
+		m.closeClient(id, client)
+	}
+	return m.CompleteCleanup(ctx, id)
+}
@@ -0,0 +1,83 @@
+package session
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManager_PurgeOwner(t *testing.T) {
+	ctx := context.Background()
+	history := NewMemoryHistory(0)
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{}, &mockClock{}, &mockCleaner{}, &mockLogger{},
+		WithHistory(history))
+	idGen := manager.idGen.(*mockIDGenerator)
+
+	root := t.TempDir()
+	private := filepath.Join(root, "private")
+	shared := filepath.Join(root, "shared")
+	for _, dir := range []string{private, shared} {
+		if err := os.Mkdir(dir, 0o750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := func(id, role, owner, worktree string) {
+		t.Helper()
+		idGen.nextID = id
+		if _, err := manager.Create(ctx, role, &mockWebSocket{}, WithOwner(owner)); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := manager.BeginSpawn(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if err := manager.AttachAgent(ctx, id, worktree, &mockACPClient{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := manager.SendMessage(ctx, id, "secret plans"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start("a1", "auth", "alice", private)
+	start("a2", "db", "alice", shared)
+	start("b1", "tests", "bob", shared)
+
+	report, err := manager.PurgeOwner(ctx, "alice")
+	if err != nil {
+		t.Fatalf("PurgeOwner failed: %v", err)
+	}
+	want := PurgeReport{
+		OwnerID:            "alice",
+		Sessions:           []string{"a1", "a2"},
+		HistoryEntries:     4, // A prompt and a reply per session
+		Workspaces:         []string{private},
+		RetainedWorkspaces: []string{shared},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("unexpected report:\n got %+v\nwant %+v", report, want)
+	}
+
+	if manager.Get("a1") != nil || manager.Get("a2") != nil || manager.Get("b1") == nil {
+		t.Error("expected only alice's sessions removed")
+	}
+	if _, err := os.Stat(private); !os.IsNotExist(err) {
+		t.Errorf("expected private workspace removed, got %v", err)
+	}
+	if _, err := os.Stat(shared); err != nil {
+		t.Errorf("expected shared workspace kept: %v", err)
+	}
+	matches, _ := manager.SearchHistory(HistoryQuery{})
+	for _, m := range matches {
+		if m.Entry.OwnerID != "bob" {
+			t.Errorf("history of %s survived the purge", m.Entry.OwnerID)
+		}
+	}
+	if len(matches) != 2 {
+		t.Errorf("expected bob's 2 entries kept, got %d", len(matches))
+	}
+
+	if _, err := manager.PurgeOwner(ctx, ""); err == nil {
+		t.Error("expected empty owner to be rejected")
+	}
+}
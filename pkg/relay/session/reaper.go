@@ -0,0 +1,112 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ExpiryWarner is told once per session that its TTL runs out in remaining
+type ExpiryWarner func(session *Session, remaining time.Duration)
+
+// Reaper terminates sessions whose TTL has passed, regardless of activity
+// Sessions are warned warnBefore their expiry, then on expiry their agent is
+// closed and they are cleaned up like any other terminated session.
+type Reaper struct {
+	manager    *Manager
+	warnBefore time.Duration
+	warn       ExpiryWarner // Optional (nil = no warnings)
+
+	mu     sync.Mutex
+	warned map[string]bool // Sessions already warned, pruned as they go
+}
+
+// NewReaper creates a reaper for the manager's sessions
+// A warnBefore of zero or a nil warn disables warnings.
+func NewReaper(manager *Manager, warnBefore time.Duration, warn ExpiryWarner) *Reaper {
+	return &Reaper{
+		manager:    manager,
+		warnBefore: warnBefore,
+		warn:       warn,
+		warned:     make(map[string]bool),
+	}
+}
+
+// Run sweeps every interval until ctx is done
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Sweep(ctx)
+		}
+	}
+}
+
+// Sweep warns sessions nearing their TTL and terminates expired ones, then
+// deletes cleaned sessions past the manager's retention window (see
+// WithRetention)
+// Returns how many sessions were terminated
+func (r *Reaper) Sweep(ctx context.Context) int {
+	now, nowMono := r.manager.clock.Now(), r.manager.clock.Monotonic()
+	live := make(map[string]bool)
+	var expired []*Session
+
+	for _, sess := range r.manager.List(nil) {
+		switch sess.GetState() {
+		case StateTerminating, StateCleaned:
+			continue // Already on its way out
+		}
+		remaining, ok := sess.expiresIn(now, nowMono)
+		if !ok {
+			continue
+		}
+		if remaining <= 0 {
+			expired = append(expired, sess)
+			continue
+		}
+		live[sess.GetID()] = true
+		if remaining <= r.warnBefore {
+			r.warnOnce(sess, remaining)
+		}
+	}
+
+	r.expire(ctx, expired)
+	r.manager.PurgeRetained()
+
+	r.mu.Lock()
+	for id := range r.warned {
+		if !live[id] {
+			delete(r.warned, id)
+		}
+	}
+	r.mu.Unlock()
+	return len(expired)
+}
+
+// warnOnce calls the warner the first time a session is seen nearing expiry
+func (r *Reaper) warnOnce(sess *Session, remaining time.Duration) {
+	if r.warn == nil {
+		return
+	}
+	r.mu.Lock()
+	already := r.warned[sess.GetID()]
+	r.warned[sess.GetID()] = true
+	r.mu.Unlock()
+	if !already {
+		r.warn(sess, remaining)
+	}
+}
+
+// expire terminates sessions and stops their agents, several at a time
+func (r *Reaper) expire(ctx context.Context, sessions []*Session) {
+	logFailures := WithTerminateProgress(func(p TerminateProgress) {
+		if p.Err != nil {
+			r.manager.logger.Printf("Failed to clean up expired session: id=%s err=%v", p.SessionID, p.Err)
+		}
+	})
+	_, _ = r.manager.terminateEach(ctx, sessions, newTerminateConfig([]TerminateOption{WithTerminateReason("ttl expired"), logFailures}))
+}
@@ -0,0 +1,117 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type closeCountingACPClient struct {
+	mockACPClient
+	closed int
+}
+
+func (c *closeCountingACPClient) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestManager_TTL(t *testing.T) {
+	ctx := context.Background()
+	clock := &mockClock{now: time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC), mono: time.Second}
+	idGen := &mockIDGenerator{nextID: "s1"}
+	manager := NewManager(NewMemoryStore(), idGen, clock, &mockCleaner{}, &mockLogger{},
+		WithDefaultTTL(time.Hour))
+
+	defaulted, err := manager.Create(ctx, "auth", &mockWebSocket{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got, want := defaulted.GetExpiresAt(), clock.now.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("default expiry = %v, want %v", got, want)
+	}
+
+	idGen.nextID = "s2"
+	explicit, err := manager.Create(ctx, "db", &mockWebSocket{}, WithTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got, want := explicit.GetExpiresAt(), clock.now.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("explicit expiry = %v, want %v", got, want)
+	}
+
+	idGen.nextID = "s3"
+	if _, err := manager.Create(ctx, "tests", &mockWebSocket{}, WithTTL(0)); err == nil {
+		t.Error("expected error for zero TTL")
+	}
+
+	unlimited, _, _, _, _ := setupManager()
+	sess, err := unlimited.Create(ctx, "auth", &mockWebSocket{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !sess.GetExpiresAt().IsZero() {
+		t.Errorf("expected no expiry without a TTL, got %v", sess.GetExpiresAt())
+	}
+}
+
+func TestReaper_Sweep(t *testing.T) {
+	ctx := context.Background()
+	manager, idGen, clock, _, _ := setupManager()
+	client := &closeCountingACPClient{}
+
+	sess, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithTTL(10*time.Minute))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, sess.GetID()); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, sess.GetID(), "/tmp/worktree", client); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+	idGen.nextID = "forever"
+	if _, err := manager.Create(ctx, "db", &mockWebSocket{}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	var warnings []time.Duration
+	reaper := NewReaper(manager, 2*time.Minute, func(s *Session, remaining time.Duration) {
+		if s.GetID() != sess.GetID() {
+			t.Errorf("warned %s, want %s", s.GetID(), sess.GetID())
+		}
+		warnings = append(warnings, remaining)
+	})
+
+	if n := reaper.Sweep(ctx); n != 0 || len(warnings) != 0 {
+		t.Fatalf("fresh sweep: expired=%d warnings=%v", n, warnings)
+	}
+
+	// Activity does not extend the TTL
+	clock.advance(9 * time.Minute)
+	if _, err := manager.SendMessage(ctx, sess.GetID(), "still here"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	reaper.Sweep(ctx)
+	reaper.Sweep(ctx)
+	if len(warnings) != 1 || warnings[0] != time.Minute {
+		t.Fatalf("warnings = %v, want one with 1m remaining", warnings)
+	}
+
+	clock.advance(time.Minute)
+	if n := reaper.Sweep(ctx); n != 1 {
+		t.Fatalf("expired = %d, want 1", n)
+	}
+	if manager.Get(sess.GetID()) != nil {
+		t.Error("expired session should be cleaned up")
+	}
+	if client.closed != 1 {
+		t.Errorf("agent closed %d times, want 1", client.closed)
+	}
+	if manager.Get("forever") == nil {
+		t.Error("session without a TTL should survive")
+	}
+	if len(reaper.warned) != 0 {
+		t.Errorf("warned set not pruned: %v", reaper.warned)
+	}
+}
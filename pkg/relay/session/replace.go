@@ -0,0 +1,146 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrReplaceDisabled is returned by ReplaceAgent when no AgentStarter is configured
+var ErrReplaceDisabled = errors.New("agent replacement is not enabled")
+
+// AgentStarter starts a fresh agent process for role working in worktreeDir
+type AgentStarter func(ctx context.Context, role, worktreeDir string) (ACPClient, error)
+
+// WithAgentStarter lets ReplaceAgent start agents
+func WithAgentStarter(start AgentStarter) ManagerOption {
+	return func(c *managerConfig) {
+		c.starter = start
+	}
+}
+
+// ReplaceOption configures a ReplaceAgent call
+type ReplaceOption func(*replaceConfig)
+
+type replaceConfig struct {
+	replay int
+}
+
+// WithReplay sends the new agent the session's last n history entries
+// before it takes over, so it can pick up the conversation
+// Requires WithHistory.
+func WithReplay(n int) ReplaceOption {
+	return func(c *replaceConfig) {
+		c.replay = n
+	}
+}
+
+// ReplaceAgent swaps an active session's agent for a fresh one, e.g. when
+// the old agent is degraded, keeping the session, its connection and its
+// worktree
+// The new agent is started for role (empty = the session's role) and, with
+// WithReplay, caught up on recent history; until then traffic keeps going to
+// the old agent. The old agent is closed once the new one is attached, which
+// fails any requests still in flight to it. Sessions with EncryptedPayloads
+// fail with ErrPayloadKeyUnavailable: a new agent would not have their key.
+func (m *Manager) ReplaceAgent(ctx context.Context, sessionID, role string, opts ...ReplaceOption) error {
+	if m.starter == nil {
+		return ErrReplaceDisabled
+	}
+	cfg := &replaceConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.replay > 0 && m.history == nil {
+		return ErrHistoryDisabled
+	}
+
+	session := m.store.Get(sessionID)
+	if session == nil {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	if state := session.GetState(); !IsActiveState(state) {
+		return fmt.Errorf("%w: %s (state=%s)", ErrSessionNotActive, sessionID, state)
+	}
+	if role == "" {
+		role = session.AgentID
+	}
+	if session.GetAgentConfig().EncryptedPayloads {
+		return fmt.Errorf("%w: %s", ErrPayloadKeyUnavailable, sessionID)
+	}
+
+	client, err := m.starter(ContextWithAgentConfig(ctx, session.GetAgentConfig()), role, session.GetWorktreeDir())
+	if err != nil {
+		return fmt.Errorf("failed to start replacement agent %s: %w", role, err)
+	}
+	if err := m.replay(sessionID, client, cfg.replay); err != nil {
+		m.closeClient(sessionID, client)
+		return err
+	}
+
+	var old ACPClient
+	err = m.store.Update(sessionID, func(s *Session) error {
+		if !IsActiveState(s.state) {
+			return fmt.Errorf("%w: %s (state=%s)", ErrSessionNotActive, sessionID, s.state)
+		}
+		if s.handle == nil || s.handle.ACPClient == nil {
+			return fmt.Errorf("%w: %s", ErrNoAgent, sessionID)
+		}
+		old = s.handle.ACPClient
+		s.handle.ACPClient = client
+		s.agentInfo = agentInfoOf(client)
+		m.touch(s)
+		return nil
+	})
+	if err != nil {
+		m.closeClient(sessionID, client)
+		return err
+	}
+	session.renewAgentContext()
+	m.closeClient(sessionID, old)
+
+	m.logger.Printf("Agent replaced: session=%s role=%s replayed=%d", sessionID, role, cfg.replay)
+	return nil
+}
+
+// replay sends client the session's last n history entries as one prompt
+// The reply is discarded, and nothing is recorded: the entries are already in history
+func (m *Manager) replay(sessionID string, client ACPClient, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	matches, err := m.history.Search(HistoryQuery{SessionID: sessionID, Limit: n})
+	if err != nil {
+		return fmt.Errorf("read history: %w", err)
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	entries := make([]HistoryEntry, len(matches))
+	for i, match := range matches { // Search returns newest first
+		entries[len(matches)-1-i] = match.Entry
+	}
+	if _, err := client.SendMessage(replayPrompt(entries)); err != nil {
+		return fmt.Errorf("replay history: %w", err)
+	}
+	return nil
+}
+
+// replayPrompt tells a replacement agent what was said so far (pure function)
+func replayPrompt(entries []HistoryEntry) string {
+	var b strings.Builder
+	b.WriteString("You are taking over this conversation from a previous agent. The most recent messages were:\n")
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "\n[%s]\n%s\n", entry.Speaker, entry.Content)
+	}
+	b.WriteString("\nContinue from here. No reply to this message is needed.")
+	return b.String()
+}
+
+// closeClient closes an agent client, logging failures
+func (m *Manager) closeClient(sessionID string, client ACPClient) {
+	if err := client.Close(); err != nil {
+		m.logger.Printf("Failed to close agent: session=%s err=%v", sessionID, err)
+	}
+}
@@ -0,0 +1,141 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+type promptRecordingACPClient struct {
+	closeCountingACPClient
+	prompts []string
+}
+
+func (c *promptRecordingACPClient) SendMessage(content string) (*acp.AgentMessage, error) {
+	c.prompts = append(c.prompts, content)
+	return c.closeCountingACPClient.SendMessage(content)
+}
+
+func setupReplaceManager(start AgentStarter) *Manager {
+	clock := &mockClock{mono: 1}
+	return NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "s1"}, clock, &mockCleaner{}, &mockLogger{},
+		WithHistory(NewMemoryHistory(100)), WithAgentStarter(start))
+}
+
+func TestManager_ReplaceAgent(t *testing.T) {
+	ctx := context.Background()
+	replacement := &promptRecordingACPClient{}
+	var startedRole, startedDir string
+	manager := setupReplaceManager(func(ctx context.Context, role, worktreeDir string) (ACPClient, error) {
+		startedRole, startedDir = role, worktreeDir
+		return replacement, nil
+	})
+	old := &closeCountingACPClient{}
+	sess := setupActiveSession(t, manager, old)
+	for _, prompt := range []string{"first", "second", "third"} {
+		if _, err := manager.SendMessage(ctx, sess.GetID(), prompt); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+
+	if err := manager.ReplaceAgent(ctx, sess.GetID(), "", WithReplay(3)); err != nil {
+		t.Fatalf("ReplaceAgent failed: %v", err)
+	}
+
+	if startedRole != "auth" || startedDir != "/tmp/worktree" {
+		t.Errorf("started role=%q dir=%q, want auth in /tmp/worktree", startedRole, startedDir)
+	}
+	if old.closed != 1 {
+		t.Errorf("old agent closed %d times, want 1", old.closed)
+	}
+	if replacement.closed != 0 {
+		t.Error("replacement agent should stay open")
+	}
+	if len(replacement.prompts) != 1 {
+		t.Fatalf("expected one replay prompt, got %d", len(replacement.prompts))
+	}
+	replayed := replacement.prompts[0]
+	// The last three entries, oldest first: reply to "second", "third", reply to "third"
+	if strings.Contains(replayed, "\nsecond\n") || !strings.Contains(replayed, "echo: second\n\n[user]\nthird\n\n[agent]\necho: third") {
+		t.Errorf("unexpected replay prompt:\n%s", replayed)
+	}
+
+	reply, err := manager.SendMessage(ctx, sess.GetID(), "after")
+	if err != nil {
+		t.Fatalf("SendMessage after replace failed: %v", err)
+	}
+	if reply.Content != "echo: after" || len(replacement.prompts) != 2 {
+		t.Errorf("traffic should reach the replacement agent, got %q", reply.Content)
+	}
+	if sess.GetState() != StateActive {
+		t.Errorf("state = %s, want ACTIVE", sess.GetState())
+	}
+}
+
+func TestManager_ReplaceAgent_Errors(t *testing.T) {
+	ctx := context.Background()
+	startErr := errors.New("no capacity")
+
+	t.Run("disabled", func(t *testing.T) {
+		manager, _, _, _, _ := setupManager()
+		sess := setupActiveSession(t, manager, &mockACPClient{})
+		if err := manager.ReplaceAgent(ctx, sess.GetID(), ""); !errors.Is(err, ErrReplaceDisabled) {
+			t.Errorf("expected ErrReplaceDisabled, got %v", err)
+		}
+	})
+
+	t.Run("start failure keeps old agent", func(t *testing.T) {
+		manager := setupReplaceManager(func(context.Context, string, string) (ACPClient, error) {
+			return nil, startErr
+		})
+		old := &closeCountingACPClient{}
+		sess := setupActiveSession(t, manager, old)
+		if err := manager.ReplaceAgent(ctx, sess.GetID(), ""); !errors.Is(err, startErr) {
+			t.Errorf("expected start error, got %v", err)
+		}
+		if old.closed != 0 || sess.GetHandle().ACPClient != old {
+			t.Error("old agent should remain attached")
+		}
+	})
+
+	t.Run("not active", func(t *testing.T) {
+		replacement := &closeCountingACPClient{}
+		manager := setupReplaceManager(func(context.Context, string, string) (ACPClient, error) {
+			return replacement, nil
+		})
+		sess, err := manager.Create(ctx, "auth", &mockWebSocket{})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := manager.ReplaceAgent(ctx, sess.GetID(), ""); !errors.Is(err, ErrSessionNotActive) {
+			t.Errorf("expected ErrSessionNotActive, got %v", err)
+		}
+		if err := manager.ReplaceAgent(ctx, "missing", ""); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected ErrSessionNotFound, got %v", err)
+		}
+	})
+
+	t.Run("encrypted payloads", func(t *testing.T) {
+		started := false
+		manager := setupReplaceManager(func(context.Context, string, string) (ACPClient, error) {
+			started = true
+			return &closeCountingACPClient{}, nil
+		})
+		sess, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithAgentConfig(AgentConfig{EncryptedPayloads: true}))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := manager.BeginSpawn(ctx, sess.GetID()); err != nil {
+			t.Fatalf("BeginSpawn failed: %v", err)
+		}
+		if err := manager.AttachAgent(ctx, sess.GetID(), "/tmp/worktree", &closeCountingACPClient{}); err != nil {
+			t.Fatalf("AttachAgent failed: %v", err)
+		}
+		if err := manager.ReplaceAgent(ctx, sess.GetID(), ""); !errors.Is(err, ErrPayloadKeyUnavailable) || started {
+			t.Errorf("expected ErrPayloadKeyUnavailable before starting an agent, got %v (started=%t)", err, started)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package session
+
+import (
+	"fmt"
+	"time"
+)
+
+// ResourceUsage is the latest resource sample of a session's agent process
+type ResourceUsage struct {
+	SampledAt  time.Time // Zero if the agent was never sampled
+	CPUPercent float64   // Share of one core used since the previous sample
+	RSSBytes   int64     // Resident memory
+}
+
+// GetResourceUsage returns the latest sample of the session's agent process
+func (s *Session) GetResourceUsage() ResourceUsage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resources
+}
+
+// RecordResourceUsage attaches a sample of the agent process to the session
+// Samples are runtime state like the handle: they are neither persisted nor
+// versioned, so frequent sampling does not write to the store.
+func (m *Manager) RecordResourceUsage(sessionID string, usage ResourceUsage) error {
+	session := m.store.Get(sessionID)
+	if session == nil {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	return session.withLock(func(s *Session) error {
+		s.resources = usage
+		return nil
+	})
+}
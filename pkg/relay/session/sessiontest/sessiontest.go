@@ -0,0 +1,456 @@
+// Package sessiontest provides fakes of the session package's dependencies,
+// for programs embedding the relay to use in their own tests
+//
+// Every fake is safe for concurrent use. The session package's own tests
+// keep private mocks, since they cannot import this package.
+package sessiontest
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+	"github.com/gorilla/websocket"
+)
+
+// Compile-time checks that the fakes satisfy the session interfaces
+var (
+	_ session.WebSocketConn          = (*Conn)(nil)
+	_ session.Clock                  = (*Clock)(nil)
+	_ session.Logger                 = (*Logger)(nil)
+	_ session.IDGenerator            = (*IDGenerator)(nil)
+	_ session.Cleaner                = (*Cleaner)(nil)
+	_ session.ACPClient              = (*Agent)(nil)
+	_ session.StreamingACPClient     = (*Agent)(nil)
+	_ session.InterruptibleACPClient = (*Agent)(nil)
+)
+
+// Conn is a client connection that records what it is sent and plays back
+// queued client messages
+// ReadMessage blocks until a message is queued with Send or the connection
+// is closed, like a real socket.
+type Conn struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	written []interface{}
+	inbound [][]byte
+	closed  bool
+
+	readLimit     int64
+	readDeadline  time.Time
+	writeDeadline time.Time
+	closeCode     int
+	closeReason   string
+	pongHandler   func(appData string) error
+
+	// WriteErr, if set, fails every WriteJSON
+	WriteErr error
+	// ReadErr is returned by ReadMessage once the connection is closed
+	// (default io.EOF)
+	ReadErr error
+}
+
+// NewConn creates a connection with messages already queued for reading
+func NewConn(messages ...[]byte) *Conn {
+	c := &Conn{inbound: messages}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Send queues a client message, JSON-encoding anything but []byte
+func (c *Conn) Send(v interface{}) error {
+	data, ok := v.([]byte)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inbound = append(c.inbound, data)
+	c.cond.Broadcast()
+	return nil
+}
+
+// WriteJSON records v
+// Pre-encoded frames (json.RawMessage) are decoded into a map, as the
+// client would see them.
+func (c *Conn) WriteJSON(v interface{}) error {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.WriteErr != nil {
+		return c.WriteErr
+	}
+	if raw, ok := v.(json.RawMessage); ok {
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return err
+		}
+		v = decoded
+	}
+	c.written = append(c.written, v)
+	c.cond.Broadcast()
+	return nil
+}
+
+// ReadMessage returns the next queued message as a text frame
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.inbound) == 0 && !c.closed {
+		c.cond.Wait()
+	}
+	if len(c.inbound) == 0 {
+		if c.ReadErr != nil {
+			return 0, nil, c.ReadErr
+		}
+		return 0, nil, io.EOF
+	}
+	msg := c.inbound[0]
+	c.inbound = c.inbound[1:]
+	return 1, msg, nil
+}
+
+// Close marks the connection closed, ending blocked reads
+func (c *Conn) Close() error {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.cond.Broadcast()
+	return nil
+}
+
+// Closed reports whether Close was called
+func (c *Conn) Closed() bool {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// SetReadLimit records the limit
+func (c *Conn) SetReadLimit(limit int64) {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readLimit = limit
+}
+
+// SetReadDeadline records the deadline; reads never time out
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline records the deadline; writes never time out
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
+
+// WriteControl records the code and reason of close frames
+// Ping and pong frames are accepted and dropped.
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if messageType != websocket.CloseMessage {
+		return nil
+	}
+	c.closeCode = websocket.CloseNoStatusReceived
+	if len(data) >= 2 {
+		c.closeCode = int(binary.BigEndian.Uint16(data))
+		c.closeReason = string(data[2:])
+	}
+	return nil
+}
+
+// SetPongHandler records h; Pong calls it
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pongHandler = h
+}
+
+// Pong delivers a pong from the client to the handler, if one is set
+func (c *Conn) Pong() error {
+	c.init()
+	c.mu.Lock()
+	h := c.pongHandler
+	c.mu.Unlock()
+	if h == nil {
+		return nil
+	}
+	return h("")
+}
+
+// ReadLimit returns the limit last set with SetReadLimit
+func (c *Conn) ReadLimit() int64 {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readLimit
+}
+
+// Deadlines returns the read and write deadlines last set
+func (c *Conn) Deadlines() (read, write time.Time) {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readDeadline, c.writeDeadline
+}
+
+// CloseCode returns the status code and reason of the close frame sent, or
+// 0 if none was
+func (c *Conn) CloseCode() (int, string) {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeCode, c.closeReason
+}
+
+// Written returns a copy of everything written so far
+func (c *Conn) Written() []interface{} {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]interface{}(nil), c.written...)
+}
+
+// WaitWritten waits until at least n messages were written and returns them
+// It returns an error if that does not happen within timeout.
+func (c *Conn) WaitWritten(n int, timeout time.Duration) ([]interface{}, error) {
+	c.init()
+	timer := time.AfterFunc(timeout, func() {
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.written) < n {
+		if !time.Now().Before(deadline) {
+			return append([]interface{}(nil), c.written...), fmt.Errorf("got %d messages after %v, want %d", len(c.written), timeout, n)
+		}
+		c.cond.Wait()
+	}
+	return append([]interface{}(nil), c.written...), nil
+}
+
+// init lets a zero Conn be used
+func (c *Conn) init() {
+	c.mu.Lock()
+	if c.cond == nil {
+		c.cond = sync.NewCond(&c.mu)
+	}
+	c.mu.Unlock()
+}
+
+// Clock is a manually driven clock
+// Now and Monotonic move together with Advance; Set steps only the wall clock,
+// as an NTP correction would.
+type Clock struct {
+	mu   sync.Mutex
+	now  time.Time
+	mono time.Duration
+}
+
+// NewClock creates a clock reading now
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now, mono: time.Second}
+}
+
+// Now returns the current fake time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Monotonic returns the fake monotonic reading
+// It starts at one second, so zero still reads as "never".
+func (c *Clock) Monotonic() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mono
+}
+
+// Advance moves both the wall and monotonic clocks forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.mono += d
+}
+
+// Set changes the wall clock only
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Logger records formatted log lines
+type Logger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// Printf records one line
+func (l *Logger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+// Lines returns a copy of the lines logged so far
+func (l *Logger) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+// Contains reports whether any line contains substring
+func (l *Logger) Contains(substring string) bool {
+	for _, line := range l.Lines() {
+		if strings.Contains(line, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// IDGenerator hands out Prefix-1, Prefix-2, ... ("id-1" with no prefix)
+type IDGenerator struct {
+	Prefix string
+	n      atomic.Int64
+}
+
+// Generate returns the next ID
+func (g *IDGenerator) Generate() string {
+	prefix := g.Prefix
+	if prefix == "" {
+		prefix = "id"
+	}
+	return fmt.Sprintf("%s-%d", prefix, g.n.Add(1))
+}
+
+// Cleaner records the sessions it cleaned up
+type Cleaner struct {
+	mu      sync.Mutex
+	cleaned []string
+
+	// Err, if set, is returned by every Cleanup
+	Err error
+}
+
+// Cleanup records the session
+func (c *Cleaner) Cleanup(ctx context.Context, sess *session.Session) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cleaned = append(c.cleaned, sess.GetID())
+	return c.Err
+}
+
+// Cleaned returns the IDs of the sessions cleaned up, in order
+func (c *Cleaner) Cleaned() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.cleaned...)
+}
+
+// Agent is an ACP client that echoes prompts, or answers with Reply
+// It streams its reply word by word and honors context cancellation.
+type Agent struct {
+	// Reply, if set, produces the answer to each prompt
+	Reply func(content string) (*acp.AgentMessage, error)
+
+	mu      sync.Mutex
+	prompts []string
+	closed  bool
+}
+
+// SendMessage answers a prompt
+func (a *Agent) SendMessage(content string) (*acp.AgentMessage, error) {
+	return a.SendMessageContext(context.Background(), content, nil)
+}
+
+// SendMessageStream answers a prompt, streaming it to onDelta first
+func (a *Agent) SendMessageStream(content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	return a.SendMessageContext(context.Background(), content, onDelta)
+}
+
+// SendMessageContext answers a prompt unless ctx is done or the agent closed
+func (a *Agent) SendMessageContext(ctx context.Context, content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil, fmt.Errorf("agent is closed")
+	}
+	a.prompts = append(a.prompts, content)
+	a.mu.Unlock()
+
+	msg := &acp.AgentMessage{Type: "text", Content: "Echo: " + content}
+	if a.Reply != nil {
+		var err error
+		if msg, err = a.Reply(content); err != nil {
+			return nil, err
+		}
+	}
+	if onDelta != nil && msg.Content != "" {
+		for i, word := range strings.SplitAfter(msg.Content, " ") {
+			if word != "" {
+				onDelta(acp.Delta{Seq: i + 1, Content: word})
+			}
+		}
+	}
+	return msg, nil
+}
+
+// Close stops the agent
+func (a *Agent) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	return nil
+}
+
+// Closed reports whether Close was called
+func (a *Agent) Closed() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.closed
+}
+
+// Prompts returns the prompts received, in order
+func (a *Agent) Prompts() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.prompts...)
+}
@@ -0,0 +1,109 @@
+package sessiontest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+	"github.com/2389-research/ourocodus/pkg/relay/session/sessiontest"
+	"github.com/gorilla/websocket"
+)
+
+func TestManager_LifecycleWithFakes(t *testing.T) {
+	ctx := context.Background()
+	cleaner := &sessiontest.Cleaner{}
+	manager := session.NewManager(session.NewMemoryStore(), &sessiontest.IDGenerator{Prefix: "session"},
+		sessiontest.NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)), cleaner, &sessiontest.Logger{})
+
+	sess, err := manager.Create(ctx, "auth", sessiontest.NewConn(), session.WithOwner("user-1"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if sess.GetID() != "session-1" {
+		t.Errorf("expected session-1, got %s", sess.GetID())
+	}
+	if err := manager.BeginSpawn(ctx, sess.GetID()); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	agent := &sessiontest.Agent{}
+	if err := manager.AttachAgent(ctx, sess.GetID(), t.TempDir(), agent); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+
+	var deltas []string
+	msg, err := manager.StreamMessage(ctx, sess.GetID(), "hello there", func(d acp.Delta) {
+		deltas = append(deltas, d.Content)
+	})
+	if err != nil {
+		t.Fatalf("StreamMessage failed: %v", err)
+	}
+	if msg.Content != "Echo: hello there" {
+		t.Errorf("expected echo, got %q", msg.Content)
+	}
+	if got := strings.Join(deltas, ""); got != msg.Content {
+		t.Errorf("expected deltas to add up to the reply, got %q", got)
+	}
+	if prompts := agent.Prompts(); len(prompts) != 1 || prompts[0] != "hello there" {
+		t.Errorf("expected one prompt, got %v", prompts)
+	}
+
+	if _, err := manager.PurgeOwner(ctx, "user-1"); err != nil {
+		t.Fatalf("PurgeOwner failed: %v", err)
+	}
+	if !agent.Closed() {
+		t.Error("expected agent closed by purge")
+	}
+	if cleaned := cleaner.Cleaned(); len(cleaned) != 1 || cleaned[0] != "session-1" {
+		t.Errorf("expected session-1 cleaned, got %v", cleaned)
+	}
+}
+
+func TestConn_ReadUnblocksOnClose(t *testing.T) {
+	conn := sessiontest.NewConn([]byte(`{"type":"ping"}`))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != `{"type":"ping"}` {
+		t.Fatalf("expected queued message, got %q, %v", data, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		done <- err
+	}()
+	_ = conn.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected an error reading a closed connection")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReadMessage did not return after Close")
+	}
+}
+
+func TestClock_SetLeavesMonotonic(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := sessiontest.NewClock(start)
+	mono := clock.Monotonic()
+	clock.Advance(time.Minute)
+	clock.Set(start)
+	if !clock.Now().Equal(start) {
+		t.Errorf("expected wall clock reset, got %v", clock.Now())
+	}
+	if got := clock.Monotonic() - mono; got != time.Minute {
+		t.Errorf("expected monotonic clock 1m on, got %v", got)
+	}
+}
+
+func TestConn_RecordsCloseFrame(t *testing.T) {
+	conn := sessiontest.NewConn()
+	frame := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too many errors")
+	if err := conn.WriteControl(websocket.CloseMessage, frame, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if code, reason := conn.CloseCode(); code != websocket.ClosePolicyViolation || reason != "too many errors" {
+		t.Errorf("expected 1008 too many errors, got %d %q", code, reason)
+	}
+}
@@ -0,0 +1,21 @@
+package session
+
+import "context"
+
+// Shutdown terminates every session whose agent runs in this process, as the
+// relay stops
+// Sessions without an attached agent, such as those another relay hosts in a
+// shared store, are left alone. Sessions are terminated several at a time,
+// configured by opts as for TerminateAll. Returns how many sessions were
+// terminated; termination failures are joined into the error, and Shutdown
+// stops early with ctx's error once ctx is done.
+func (m *Manager) Shutdown(ctx context.Context, opts ...TerminateOption) (int, error) {
+	var hosted []*Session
+	for _, sess := range m.List(nil) {
+		if sess.acpClient() != nil {
+			hosted = append(hosted, sess)
+		}
+	}
+	opts = append([]TerminateOption{WithTerminateReason("relay shutdown")}, opts...)
+	return m.terminateEach(ctx, hosted, newTerminateConfig(opts))
+}
@@ -0,0 +1,49 @@
+package session
+
+import (
+	"context"
+	"testing"
+)
+
+func TestManager_Shutdown(t *testing.T) {
+	ctx := context.Background()
+	manager, idGen, _, cleaner, _ := setupManager()
+	client := &closeCountingACPClient{}
+	hosted := setupActiveSession(t, manager, client)
+	idGen.nextID = "detached"
+	detached, err := manager.Create(ctx, "db", &mockWebSocket{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	n, err := manager.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 session terminated, got %d", n)
+	}
+	if manager.Get(hosted.GetID()) != nil || client.closed != 1 {
+		t.Error("expected the session with an agent terminated and its agent closed")
+	}
+	if manager.Get(detached.GetID()) == nil {
+		t.Error("expected the session without an agent kept")
+	}
+	if cleaner.called != 1 {
+		t.Errorf("expected one cleanup, got %d", cleaner.called)
+	}
+}
+
+func TestManager_ShutdownStopsWhenContextDone(t *testing.T) {
+	manager, _, _, _, _ := setupManager()
+	sess := setupActiveSession(t, manager, &mockACPClient{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := manager.Shutdown(ctx); err == nil {
+		t.Error("expected ctx's error")
+	}
+	if manager.Get(sess.GetID()) == nil {
+		t.Error("expected no sessions terminated once ctx is done")
+	}
+}
@@ -6,12 +6,22 @@ import "fmt"
 type Event string
 
 const (
+	// EventCreate is reported when a session is stored in CREATED state
+	// It is not a transition the state machine accepts
+	EventCreate Event = "CREATE"
+
 	// EventSpawn indicates we're starting to spawn the ACP process
 	EventSpawn Event = "SPAWN"
 
 	// EventActivate indicates ACP process successfully spawned and ready
 	EventActivate Event = "ACTIVATE"
 
+	// EventPause indicates the user stepped away and agent processing should be suspended
+	EventPause Event = "PAUSE"
+
+	// EventResume indicates a paused session should resume processing
+	EventResume Event = "RESUME"
+
 	// EventTerminate indicates session should begin cleanup
 	EventTerminate Event = "TERMINATE"
 
@@ -77,11 +87,25 @@ func NextState(current SessionState, event Event) (SessionState, error) {
 
 	case StateActive:
 		switch event {
+		case EventPause:
+			return StatePaused, nil
+		case EventTerminate:
+			return StateTerminating, nil
+		default:
+			return current, NewTransitionError(current, event,
+				"can only pause or terminate from ACTIVE state")
+		}
+
+	case StatePaused:
+		switch event {
+		case EventResume:
+			return StateActive, nil
 		case EventTerminate:
+			// Paused sessions can still be torn down (e.g., idle reaping)
 			return StateTerminating, nil
 		default:
 			return current, NewTransitionError(current, event,
-				"can only terminate from ACTIVE state")
+				"can only resume or terminate from PAUSED state")
 		}
 
 	case StateTerminating:
@@ -120,6 +144,7 @@ func IsTerminalState(state SessionState) bool {
 }
 
 // IsActiveState returns true if session is actively processing messages
+// PAUSED sessions are not active: agent messages must not be forwarded
 func IsActiveState(state SessionState) bool {
 	return state == StateActive
 }
@@ -46,6 +46,26 @@ func TestNextState_ValidTransitions(t *testing.T) {
 			event:         EventTerminate,
 			expectedState: StateTerminating,
 		},
+		{
+			name:          "ACTIVE + PAUSE → PAUSED",
+			currentState:  StateActive,
+			event:         EventPause,
+			expectedState: StatePaused,
+		},
+
+		// From PAUSED
+		{
+			name:          "PAUSED + RESUME → ACTIVE",
+			currentState:  StatePaused,
+			event:         EventResume,
+			expectedState: StateActive,
+		},
+		{
+			name:          "PAUSED + TERMINATE → TERMINATING",
+			currentState:  StatePaused,
+			event:         EventTerminate,
+			expectedState: StateTerminating,
+		},
 
 		// From TERMINATING
 		{
@@ -155,6 +175,38 @@ func TestNextState_InvalidTransitions(t *testing.T) {
 			currentState: StateCleaned,
 			event:        EventClean,
 		},
+
+		// Pause/resume only apply between ACTIVE and PAUSED
+		{
+			name:         "CREATED + PAUSE",
+			currentState: StateCreated,
+			event:        EventPause,
+		},
+		{
+			name:         "SPAWNING + PAUSE",
+			currentState: StateSpawning,
+			event:        EventPause,
+		},
+		{
+			name:         "ACTIVE + RESUME",
+			currentState: StateActive,
+			event:        EventResume,
+		},
+		{
+			name:         "PAUSED + PAUSE",
+			currentState: StatePaused,
+			event:        EventPause,
+		},
+		{
+			name:         "PAUSED + CLEAN",
+			currentState: StatePaused,
+			event:        EventClean,
+		},
+		{
+			name:         "TERMINATING + RESUME",
+			currentState: StateTerminating,
+			event:        EventResume,
+		},
 	}
 
 	for _, tt := range tests {
@@ -236,6 +288,7 @@ func TestIsActiveState(t *testing.T) {
 		{StateCreated, false},
 		{StateSpawning, false},
 		{StateActive, true},
+		{StatePaused, false},
 		{StateTerminating, false},
 		{StateCleaned, false},
 	}
@@ -258,6 +311,7 @@ func TestSessionState_String(t *testing.T) {
 		{StateCreated, "CREATED"},
 		{StateSpawning, "SPAWNING"},
 		{StateActive, "ACTIVE"},
+		{StatePaused, "PAUSED"},
 		{StateTerminating, "TERMINATING"},
 		{StateCleaned, "CLEANED"},
 	}
@@ -276,6 +330,7 @@ func TestSessionState_IsValid(t *testing.T) {
 		StateCreated,
 		StateSpawning,
 		StateActive,
+		StatePaused,
 		StateTerminating,
 		StateCleaned,
 	}
@@ -302,6 +357,8 @@ func TestEvent_String(t *testing.T) {
 	}{
 		{EventSpawn, "SPAWN"},
 		{EventActivate, "ACTIVATE"},
+		{EventPause, "PAUSE"},
+		{EventResume, "RESUME"},
 		{EventTerminate, "TERMINATE"},
 		{EventClean, "CLEAN"},
 	}
@@ -0,0 +1,54 @@
+package session
+
+import "time"
+
+// Stats is a point-in-time summary of the manager's sessions
+type Stats struct {
+	Sessions      int                  // Live sessions
+	ByState       map[SessionState]int // Sessions per lifecycle state, retained CLEANED ones included
+	AgentsByState map[SessionState]int // Sessions with an attached agent, per lifecycle state
+	SpawnFailures int64                // Sessions failed before becoming active, since the manager started
+	AverageAge    time.Duration        // Mean time since creation of live sessions (0 if none)
+}
+
+// Stats aggregates the sessions in one snapshot taken under the store lock,
+// so the counts agree with each other even while sessions change
+func (m *Manager) Stats() Stats {
+	now := m.clock.Now()
+	stats := Stats{
+		ByState:       make(map[SessionState]int),
+		AgentsByState: make(map[SessionState]int),
+		SpawnFailures: m.spawnFailures.Load(),
+	}
+
+	var totalAge time.Duration
+	m.store.Range(func(session *Session) {
+		state, hasAgent, createdAt := session.statsSnapshot()
+		stats.ByState[state]++
+		if hasAgent {
+			stats.AgentsByState[state]++
+		}
+		if state == StateCleaned {
+			return // Retained for post-mortems, not live
+		}
+		stats.Sessions++
+		totalAge += now.Sub(createdAt)
+	})
+	if stats.Sessions > 0 {
+		stats.AverageAge = totalAge / time.Duration(stats.Sessions)
+	}
+	return stats
+}
+
+// statsSnapshot reads the fields Stats aggregates under one lock
+func (s *Session) statsSnapshot() (state SessionState, hasAgent bool, createdAt time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state, s.handle != nil && s.handle.ACPClient != nil, s.createdAt
+}
+
+// isSpawnFailure reports whether a transition is a session failing before
+// its agent became active (pure function)
+func isSpawnFailure(event Event, from SessionState, cause error) bool {
+	return event == EventTerminate && cause != nil && (from == StateCreated || from == StateSpawning)
+}
@@ -0,0 +1,96 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestManager_Stats(t *testing.T) {
+	ctx := context.Background()
+	manager, idGen, clock, _, _ := setupManager()
+
+	if stats := manager.Stats(); stats.Sessions != 0 || stats.AverageAge != 0 {
+		t.Fatalf("expected empty stats, got %+v", stats)
+	}
+
+	active := setupActiveSession(t, manager, &mockACPClient{})
+	clock.advance(time.Minute)
+	idGen.nextID = "spawning"
+	spawning, err := manager.Create(ctx, "db", &mockWebSocket{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, spawning.GetID()); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	idGen.nextID = "failed"
+	failed, err := manager.Create(ctx, "tests", &mockWebSocket{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.MarkFailed(ctx, failed.GetID(), errors.New("no binary")); err != nil {
+		t.Fatalf("MarkFailed failed: %v", err)
+	}
+	if err := manager.CompleteCleanup(ctx, failed.GetID()); err != nil {
+		t.Fatalf("CompleteCleanup failed: %v", err)
+	}
+	// Failing an active agent is not a spawn failure
+	if err := manager.MarkFailed(ctx, active.GetID(), errors.New("crashed")); err != nil {
+		t.Fatalf("MarkFailed failed: %v", err)
+	}
+	clock.advance(time.Minute)
+
+	stats := manager.Stats()
+	if stats.Sessions != 2 {
+		t.Errorf("Sessions = %d, want 2", stats.Sessions)
+	}
+	if stats.ByState[StateTerminating] != 1 || stats.ByState[StateSpawning] != 1 {
+		t.Errorf("ByState = %v", stats.ByState)
+	}
+	if len(stats.AgentsByState) != 1 || stats.AgentsByState[StateTerminating] != 1 {
+		t.Errorf("AgentsByState = %v", stats.AgentsByState)
+	}
+	if stats.SpawnFailures != 1 {
+		t.Errorf("SpawnFailures = %d, want 1", stats.SpawnFailures)
+	}
+	// Ages are 2m and 1m
+	if stats.AverageAge != 90*time.Second {
+		t.Errorf("AverageAge = %v, want 1m30s", stats.AverageAge)
+	}
+}
+
+func TestManager_Stats_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	manager := NewManager(store, &mockIDGenerator{}, &mockClock{}, &mockCleaner{}, &mockLogger{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			session := NewSession(fmt.Sprintf("s%d", i), fmt.Sprintf("role-%d", i), time.Time{})
+			if err := store.Create(session); err != nil {
+				t.Errorf("Create failed: %v", err)
+				return
+			}
+			_ = manager.MarkTerminating(ctx, session.ID, "done")
+		}(i)
+		go func() {
+			defer wg.Done()
+			stats := manager.Stats()
+			total := 0
+			for _, n := range stats.ByState {
+				total += n
+			}
+			if total != stats.Sessions {
+				t.Errorf("states sum to %d, want %d", total, stats.Sessions)
+			}
+		}()
+	}
+	wg.Wait()
+}
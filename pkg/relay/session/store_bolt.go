@@ -0,0 +1,129 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltSessionsBucket holds one JSON sessionRecord per session ID
+var boltSessionsBucket = []byte("sessions")
+
+// boltOpenTimeout bounds the wait for the file lock held by another process
+const boltOpenTimeout = time.Second
+
+// BoltStore implements Store on an embedded bbolt file, for single-relay
+// deployments that want sessions to survive restarts without running a database
+// Sessions live in memory as in MemoryStore, which serves every read; each
+// Create, Update and Delete is also written to the file in its own fsynced
+// transaction before it returns, so a crash never leaves a half-written
+// session. Only one process can open the file at a time.
+// Sessions loaded at startup are detached: their connections and agents did
+// not survive the restart.
+type BoltStore struct {
+	*MemoryStore
+	db     *bolt.DB
+	logger Logger
+}
+
+// OpenBoltStore opens (creating if needed) the store file at path and loads
+// its sessions
+func OpenBoltStore(path string, logger Logger) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("open session store %s: %w", path, err)
+	}
+	b := &BoltStore{MemoryStore: NewMemoryStore(), db: db, logger: logger}
+	if err := b.load(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("load session store %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Close closes the store file
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+// load reads every persisted session into memory
+func (b *BoltStore) load() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltSessionsBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			var rec sessionRecord
+			if err := json.Unmarshal(value, &rec); err != nil {
+				return fmt.Errorf("decode session %s: %w", key, err)
+			}
+			if !rec.State.IsValid() {
+				return fmt.Errorf("session %s has unknown state %q", key, rec.State)
+			}
+			return b.MemoryStore.Create(sessionFromRecord(rec))
+		})
+	})
+}
+
+// Create adds a session and persists it
+func (b *BoltStore) Create(session *Session) error {
+	if err := b.MemoryStore.Create(session); err != nil {
+		return err
+	}
+	session.mu.RLock()
+	rec := session.record()
+	session.mu.RUnlock()
+	if err := b.put(rec); err != nil {
+		b.MemoryStore.Delete(session.ID)
+		return err
+	}
+	return nil
+}
+
+// Update applies fn and persists the result
+// If the write fails fn's changes to persisted fields are undone.
+func (b *BoltStore) Update(id string, fn UpdateFunc) error {
+	return b.MemoryStore.Update(id, func(s *Session) error {
+		before := s.record()
+		if err := fn(s); err != nil {
+			return err
+		}
+		after := s.record()
+		after.Version++ // MemoryStore bumps the version once this returns
+		if err := b.put(after); err != nil {
+			s.version = ^uint64(0) // Forces applyRecord to restore every field
+			s.applyRecord(before)
+			return err
+		}
+		return nil
+	})
+}
+
+// Delete removes a session; idempotent
+func (b *BoltStore) Delete(id string) {
+	b.MemoryStore.Delete(id)
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSessionsBucket).Delete([]byte(id))
+	})
+	if err != nil {
+		b.logger.Printf("Session store delete failed: id=%s err=%v", id, err)
+	}
+}
+
+// put writes one record in its own transaction
+func (b *BoltStore) put(rec sessionRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode session %s: %w", rec.ID, err)
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSessionsBucket).Put([]byte(rec.ID), data)
+	})
+	if err != nil {
+		return fmt.Errorf("persist session %s: %w", rec.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestBoltStore(t *testing.T, path string) *BoltStore {
+	t.Helper()
+	store, err := OpenBoltStore(path, &mockLogger{})
+	if err != nil {
+		t.Fatalf("OpenBoltStore failed: %v", err)
+	}
+	return store
+}
+
+func TestBoltStore_SurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	store := openTestBoltStore(t, path)
+
+	idGen := &mockIDGenerator{nextID: "s1"}
+	clock := &mockClock{now: time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC), mono: time.Second}
+	manager := NewManager(store, idGen, clock, &mockCleaner{}, &mockLogger{}, WithDefaultTTL(time.Hour))
+	sess, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithOwner("alice"), WithName("login"),
+		WithLabels(map[string]string{"ticket": "BUG-1"}))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, sess.GetID()); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, sess.GetID(), "/tmp/worktree", &mockACPClient{}); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+	idGen.nextID = "s2"
+	gone, err := manager.Create(ctx, "db", &mockWebSocket{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	store.Delete(gone.GetID())
+	want := sess.record()
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened := openTestBoltStore(t, path)
+	defer func() { _ = reopened.Close() }()
+	if reopened.Count() != 1 || reopened.Get("s2") != nil {
+		t.Fatalf("expected only s1 after reopen, got %d sessions", reopened.Count())
+	}
+	loaded := reopened.GetByName("alice", "login")
+	if loaded == nil {
+		t.Fatal("name index not rebuilt")
+	}
+	if got := loaded.record(); got.Version != want.Version || got.State != StateActive ||
+		got.WorktreeDir != "/tmp/worktree" || got.Labels["ticket"] != "BUG-1" || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+	if loaded.GetHandle() != nil {
+		t.Error("loaded sessions should be detached")
+	}
+}
+
+func TestBoltStore_ReopensRetainedSessions(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	store := openTestBoltStore(t, path)
+	idGen := &mockIDGenerator{nextID: "a-old"}
+	clock := &mockClock{now: time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC), mono: time.Second}
+	manager := NewManager(store, idGen, clock, &mockCleaner{}, &mockLogger{}, WithRetention(time.Hour))
+	old := setupActiveSession(t, manager, &mockACPClient{})
+	if err := manager.MarkTerminating(ctx, old.GetID(), "done"); err != nil {
+		t.Fatalf("MarkTerminating failed: %v", err)
+	}
+	if err := manager.CompleteCleanup(ctx, old.GetID()); err != nil {
+		t.Fatalf("CompleteCleanup failed: %v", err)
+	}
+	idGen.nextID = "b-new"
+	setupActiveSession(t, manager, &mockACPClient{})
+	_ = store.Close()
+
+	reopened := openTestBoltStore(t, path)
+	defer func() { _ = reopened.Close() }()
+	if s := reopened.Get("a-old"); s == nil || s.GetState() != StateCleaned || !s.GetCleanedAt().Equal(clock.now) {
+		t.Errorf("expected the retained session reloaded with its cleanup time, got %+v", s)
+	}
+	if s := reopened.GetByRole("auth"); s == nil || s.GetID() != "b-new" {
+		t.Errorf("expected the role to find the live session, got %+v", s)
+	}
+}
+
+func TestBoltStore_UpdateAbortIsNotPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	store := openTestBoltStore(t, path)
+	if err := store.Create(NewSession("s1", "auth", time.Time{})); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	abort := errors.New("abort")
+	if err := store.Update("s1", func(s *Session) error {
+		s.setName("changed")
+		return abort
+	}); !errors.Is(err, abort) {
+		t.Fatalf("expected abort error, got %v", err)
+	}
+	_ = store.Close()
+
+	reopened := openTestBoltStore(t, path)
+	defer func() { _ = reopened.Close() }()
+	if s := reopened.Get("s1"); s == nil || s.GetVersion() != 0 {
+		t.Errorf("expected s1 at version 0, got %+v", s)
+	}
+}
+
+func TestBoltStore_SingleProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	store := openTestBoltStore(t, path)
+	defer func() { _ = store.Close() }()
+
+	if _, err := OpenBoltStore(path, &mockLogger{}); err == nil {
+		t.Error("expected second open to fail while the file is locked")
+	}
+}
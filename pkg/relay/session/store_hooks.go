@@ -0,0 +1,55 @@
+package session
+
+import "time"
+
+// StoreHooks observe writes to a Store, e.g. to emit metrics or debug logs
+// Every hook is optional. Hooks run synchronously after the operation
+// returns, outside the store's locks, and receive its error (nil on success).
+type StoreHooks struct {
+	OnCreate func(session *Session, elapsed time.Duration, err error)
+	OnUpdate func(id string, elapsed time.Duration, err error)
+	OnDelete func(id string, elapsed time.Duration)
+}
+
+// HookedStore wraps a Store and calls StoreHooks around its writes
+// Reads are passed through untouched, so any backend can be observed without
+// the Manager knowing which one it uses.
+type HookedStore struct {
+	Store
+	hooks StoreHooks
+	clock Clock
+}
+
+// NewHookedStore wraps store so hooks see its writes; clock times them
+func NewHookedStore(store Store, clock Clock, hooks StoreHooks) *HookedStore {
+	return &HookedStore{Store: store, hooks: hooks, clock: clock}
+}
+
+// Create adds a session to the wrapped store
+func (h *HookedStore) Create(session *Session) error {
+	start := h.clock.Monotonic()
+	err := h.Store.Create(session)
+	if h.hooks.OnCreate != nil {
+		h.hooks.OnCreate(session, h.clock.Monotonic()-start, err)
+	}
+	return err
+}
+
+// Update applies fn through the wrapped store
+func (h *HookedStore) Update(id string, fn UpdateFunc) error {
+	start := h.clock.Monotonic()
+	err := h.Store.Update(id, fn)
+	if h.hooks.OnUpdate != nil {
+		h.hooks.OnUpdate(id, h.clock.Monotonic()-start, err)
+	}
+	return err
+}
+
+// Delete removes a session from the wrapped store
+func (h *HookedStore) Delete(id string) {
+	start := h.clock.Monotonic()
+	h.Store.Delete(id)
+	if h.hooks.OnDelete != nil {
+		h.hooks.OnDelete(id, h.clock.Monotonic()-start)
+	}
+}
@@ -0,0 +1,58 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHookedStore(t *testing.T) {
+	var created, updated, deleted []string
+	var updateErrs []error
+	store := NewHookedStore(NewMemoryStore(), &mockClock{}, StoreHooks{
+		OnCreate: func(session *Session, _ time.Duration, err error) {
+			if err == nil {
+				created = append(created, session.ID)
+			}
+		},
+		OnUpdate: func(id string, _ time.Duration, err error) {
+			updated = append(updated, id)
+			updateErrs = append(updateErrs, err)
+		},
+		OnDelete: func(id string, _ time.Duration) {
+			deleted = append(deleted, id)
+		},
+	})
+	manager := NewManager(store, &mockIDGenerator{nextID: "s1"}, &mockClock{}, &mockCleaner{}, &mockLogger{})
+
+	sess := setupActiveSession(t, manager, &mockACPClient{})
+	if err := store.Update("missing", func(*Session) error { return nil }); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+	store.Delete(sess.ID)
+
+	if len(created) != 1 || created[0] != "s1" {
+		t.Errorf("created = %v", created)
+	}
+	// AttachAgent's update plus the SPAWNING and ACTIVE transitions, then the failed update
+	if len(updated) != 4 || updated[3] != "missing" || updateErrs[0] != nil || !errors.Is(updateErrs[3], ErrSessionNotFound) {
+		t.Errorf("updated = %v errs = %v", updated, updateErrs)
+	}
+	if len(deleted) != 1 || deleted[0] != "s1" {
+		t.Errorf("deleted = %v", deleted)
+	}
+	if store.Get("s1") != nil || store.Count() != 0 {
+		t.Error("reads should pass through to the wrapped store")
+	}
+}
+
+func TestHookedStore_NilHooks(t *testing.T) {
+	store := NewHookedStore(NewMemoryStore(), &mockClock{}, StoreHooks{})
+	if err := store.Create(NewSession("s1", "auth", time.Time{})); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := store.Update("s1", func(*Session) error { return nil }); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	store.Delete("s1")
+}
@@ -1,15 +1,33 @@
 package session
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 )
 
+// ErrSessionNotFound is returned when an operation targets an unknown session ID
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrNameTaken is returned when a session name is already used by the same owner
+var ErrNameTaken = errors.New("session name already in use")
+
+// ErrVersionConflict is returned by Update when the stored session changed
+// between read and write. Persistent stores implement Update as compare-and-swap
+// on Session.GetVersion(); callers may retry the whole read-modify-write cycle.
+var ErrVersionConflict = errors.New("session version conflict")
+
+// UpdateFunc mutates a session inside a Store.Update transaction
+// Runs with the session lock held: use the package-private setters, not the
+// locking getters. Returning an error aborts the update without bumping the version.
+type UpdateFunc func(session *Session) error
+
 // Store defines the interface for session storage
 // Implementations can be in-memory (Phase 1) or persistent (future phases)
 type Store interface {
 	// Create adds a new session to storage
-	// Returns error if session with same ID already exists
+	// Returns error if session with same ID already exists, or ErrNameTaken
+	// if the session is named and its owner already has a session by that name
 	Create(session *Session) error
 
 	// Get retrieves a session by ID
@@ -21,22 +39,31 @@ type Store interface {
 	// Phase 1: only one session per role allowed
 	GetByRole(agentID string) *Session
 
-	// List returns all sessions, optionally filtered by state
-	// Pass nil filter to get all sessions
+	// GetByName retrieves a session by owner and human-readable name
+	// Returns nil if the owner has no session with that name
+	GetByName(ownerID, name string) *Session
+
+	// List returns sessions matching the filter, sorted and paginated as the
+	// filter requests. Pass nil filter to get all sessions ordered by creation
 	List(filter *SessionFilter) []*Session
 
+	// Update atomically applies fn to the session with the given ID
+	// On success the session version is incremented by one
+	// Returns ErrSessionNotFound if no such session exists, the error from fn
+	// if it aborts, or ErrVersionConflict if a concurrent writer won the race
+	Update(id string, fn UpdateFunc) error
+
 	// Delete removes a session from storage
 	// Idempotent - no error if session doesn't exist
 	Delete(id string)
 
 	// Count returns total number of stored sessions
 	Count() int
-}
 
-// SessionFilter defines criteria for filtering sessions
-type SessionFilter struct {
-	State   *SessionState // Filter by state (nil = no filter)
-	AgentID *string       // Filter by agent role (nil = no filter)
+	// Range calls fn for every stored session while holding the store's read
+	// lock, so the calls together see one consistent set of sessions
+	// fn must not call back into the store.
+	Range(fn func(session *Session))
 }
 
 // MemoryStore implements Store interface with in-memory storage
@@ -44,14 +71,22 @@ type SessionFilter struct {
 type MemoryStore struct {
 	sessions map[string]*Session // session_id → session
 	byRole   map[string]*Session // agent_role → session
+	byName   map[nameKey]*Session
 	mu       sync.RWMutex
 }
 
+// nameKey indexes named sessions; names are unique per owner
+type nameKey struct {
+	ownerID string
+	name    string
+}
+
 // NewMemoryStore creates a new in-memory session store
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		sessions: make(map[string]*Session),
 		byRole:   make(map[string]*Session),
+		byName:   make(map[nameKey]*Session),
 	}
 }
 
@@ -69,15 +104,33 @@ func (m *MemoryStore) Create(session *Session) error {
 		return fmt.Errorf("session with ID %s already exists", session.ID)
 	}
 
+	// Cleaned (retained) sessions give up their role and name
+	if session.GetState() == StateCleaned {
+		m.sessions[session.ID] = session
+		return nil
+	}
+
 	// Check for duplicate agent role
 	if existing, hasRole := m.byRole[session.AgentID]; hasRole {
 		return fmt.Errorf("session for agent %s already exists (session_id=%s)",
 			session.AgentID, existing.ID)
 	}
 
-	// Store in both maps
+	// Check for duplicate name within the owner's namespace
+	name := session.GetName()
+	key := nameKey{ownerID: session.GetOwnerID(), name: name}
+	if name != "" {
+		if existing, taken := m.byName[key]; taken {
+			return fmt.Errorf("%w: %q (session_id=%s)", ErrNameTaken, name, existing.ID)
+		}
+	}
+
+	// Store in all indexes
 	m.sessions[session.ID] = session
 	m.byRole[session.AgentID] = session
+	if name != "" {
+		m.byName[key] = session
+	}
 
 	return nil
 }
@@ -98,45 +151,99 @@ func (m *MemoryStore) GetByRole(agentID string) *Session {
 	return m.byRole[agentID]
 }
 
-// List returns all sessions matching the filter
+// GetByName retrieves a session by owner and human-readable name
+func (m *MemoryStore) GetByName(ownerID, name string) *Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.byName[nameKey{ownerID: ownerID, name: name}]
+}
+
+// List returns all sessions matching the filter, sorted and paginated
 func (m *MemoryStore) List(filter *SessionFilter) []*Session {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	now := filter.referenceTime()
 	var result []*Session
 
 	for _, session := range m.sessions {
-		if m.matchesFilter(session, filter) {
+		if filter.Matches(session, now) {
 			result = append(result, session)
 		}
 	}
 
-	return result
+	return filter.Apply(result)
 }
 
-// matchesFilter checks if session matches filter criteria
-// Pure function - no locks needed (caller holds lock)
-func (m *MemoryStore) matchesFilter(session *Session, filter *SessionFilter) bool {
-	if filter == nil {
-		return true
+// Update atomically applies fn to the session with the given ID
+// The in-memory store serializes writers on the session lock, so it never
+// returns ErrVersionConflict; the version is still maintained so callers can
+// rely on the same semantics as persistent stores.
+func (m *MemoryStore) Update(id string, fn UpdateFunc) error {
+	m.mu.RLock()
+	session, exists := m.sessions[id]
+	m.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
 	}
 
-	// Filter by state
-	if filter.State != nil {
-		sessionState := session.GetState()
-		if sessionState != *filter.State {
-			return false
+	var before, after nameKey
+	var cleaned bool
+	err := session.withLock(func(s *Session) error {
+		before = nameKey{ownerID: s.ownerID, name: s.name}
+		wasCleaned := s.state == StateCleaned
+		if err := fn(s); err != nil {
+			return err
 		}
+		s.bumpVersion()
+		after = nameKey{ownerID: s.ownerID, name: s.name}
+		cleaned = !wasCleaned && s.state == StateCleaned
+		return nil
+	})
+	switch {
+	case err != nil:
+	case cleaned:
+		m.unindex(session, before)
+	case before != after:
+		m.reindexName(session, before, after)
 	}
+	return err
+}
 
-	// Filter by agent ID
-	if filter.AgentID != nil {
-		if session.AgentID != *filter.AgentID {
-			return false
-		}
+// reindexName moves a session's name index entry after its owner changed
+// Runs after the session lock is released: the store lock is always taken first.
+func (m *MemoryStore) reindexName(session *Session, before, after nameKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessions[session.ID] != session {
+		return // Deleted meanwhile
+	}
+	if before.name != "" && m.byName[before] == session {
+		delete(m.byName, before)
 	}
+	if after.name != "" {
+		m.byName[after] = session
+	}
+}
 
-	return true
+// unindex releases a cleaned session's role and name for new sessions while
+// it stays retained
+func (m *MemoryStore) unindex(session *Session, name nameKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropIndexes(session, name)
+}
+
+// dropIndexes removes the role and name index entries that point to session
+// (must hold the store lock)
+func (m *MemoryStore) dropIndexes(session *Session, name nameKey) {
+	if m.byRole[session.AgentID] == session {
+		delete(m.byRole, session.AgentID)
+	}
+	if name.name != "" && m.byName[name] == session {
+		delete(m.byName, name)
+	}
 }
 
 // Delete removes a session from storage
@@ -150,9 +257,10 @@ func (m *MemoryStore) Delete(id string) {
 		return // Idempotent - already deleted
 	}
 
-	// Remove from both maps
+	// Remove from all indexes; a retained session's role and name may
+	// already belong to a newer session
 	delete(m.sessions, id)
-	delete(m.byRole, session.AgentID)
+	m.dropIndexes(session, nameKey{ownerID: session.GetOwnerID(), name: session.GetName()})
 }
 
 // Count returns total number of stored sessions
@@ -162,3 +270,13 @@ func (m *MemoryStore) Count() int {
 
 	return len(m.sessions)
 }
+
+// Range calls fn for every session under the read lock
+func (m *MemoryStore) Range(fn func(session *Session)) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, session := range m.sessions {
+		fn(session)
+	}
+}
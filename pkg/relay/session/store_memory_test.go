@@ -0,0 +1,123 @@
+package session
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Update_IncrementsVersion(t *testing.T) {
+	store := NewMemoryStore()
+	session := NewSession("session-1", "auth", time.Now())
+	if err := store.Create(session); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if v := session.GetVersion(); v != 0 {
+		t.Fatalf("expected initial version 0, got %d", v)
+	}
+
+	err := store.Update("session-1", func(s *Session) error {
+		s.setWorktreeDir("/tmp/worktree")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if v := session.GetVersion(); v != 1 {
+		t.Errorf("expected version 1, got %d", v)
+	}
+	if dir := session.GetWorktreeDir(); dir != "/tmp/worktree" {
+		t.Errorf("expected worktree /tmp/worktree, got %s", dir)
+	}
+}
+
+func TestMemoryStore_Update_AbortKeepsVersion(t *testing.T) {
+	store := NewMemoryStore()
+	session := NewSession("session-1", "auth", time.Now())
+	_ = store.Create(session)
+
+	abort := errors.New("abort")
+	err := store.Update("session-1", func(s *Session) error {
+		return abort
+	})
+
+	if !errors.Is(err, abort) {
+		t.Errorf("expected abort error, got %v", err)
+	}
+	if v := session.GetVersion(); v != 0 {
+		t.Errorf("expected version to stay 0 after abort, got %d", v)
+	}
+}
+
+func TestMemoryStore_Update_NotFound(t *testing.T) {
+	store := NewMemoryStore()
+
+	called := false
+	err := store.Update("missing", func(s *Session) error {
+		called = true
+		return nil
+	})
+
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	if called {
+		t.Error("update func should not run for missing session")
+	}
+}
+
+func TestMemoryStore_Update_Concurrent(t *testing.T) {
+	store := NewMemoryStore()
+	session := NewSession("session-1", "auth", time.Now())
+	_ = store.Create(session)
+
+	const writers = 50
+	var wg sync.WaitGroup
+	wg.Add(writers)
+	for i := 0; i < writers; i++ {
+		go func() {
+			defer wg.Done()
+			_ = store.Update("session-1", func(s *Session) error {
+				s.incrementMessageCount()
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	// No lost updates: every writer is reflected in both counter and version
+	if count := session.GetMessageCount(); count != writers {
+		t.Errorf("expected message count %d, got %d", writers, count)
+	}
+	if v := session.GetVersion(); v != writers {
+		t.Errorf("expected version %d, got %d", writers, v)
+	}
+}
+
+func TestMemoryStore_NameIndex(t *testing.T) {
+	store := NewMemoryStore()
+	session := NewSession("session-1", "auth", time.Now())
+	session.setOwnerID("alice")
+	session.setName("triage")
+	if err := store.Create(session); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	dup := NewSession("session-2", "db", time.Now())
+	dup.setOwnerID("alice")
+	dup.setName("triage")
+	if err := store.Create(dup); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("expected ErrNameTaken, got %v", err)
+	}
+
+	store.Delete("session-1")
+	if store.GetByName("alice", "triage") != nil {
+		t.Error("expected name index to be cleared on delete")
+	}
+	if err := store.Create(dup); err != nil {
+		t.Errorf("expected name to be free after delete, got %v", err)
+	}
+}
@@ -0,0 +1,574 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+//go:embed migrations/*.sql
+var postgresMigrations embed.FS
+
+// postgresQueryTimeout bounds each store call; the Store interface has no context
+const postgresQueryTimeout = 5 * time.Second
+
+// postgresMigrationLock is the advisory lock key serializing migrations
+// across relays starting at the same time
+const postgresMigrationLock = 0x6f75726f // "ouro"
+
+const sessionColumns = "id, agent_id, owner_id, name, labels, state, worktree_dir, created_at, last_active, expires_at, message_count, version, collaborators, agent_config, agent_info, cleaned_at"
+
+// postgresStatements are prepared once per store
+var postgresStatements = map[string]string{
+	"insert": `INSERT INTO sessions (` + sessionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) ON CONFLICT DO NOTHING`,
+	"byID":   `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`,
+	"byRole": `SELECT ` + sessionColumns + ` FROM sessions WHERE agent_id = $1 AND state <> 'CLEANED'`,
+	"byName": `SELECT ` + sessionColumns + ` FROM sessions WHERE owner_id = $1 AND name = $2 AND name <> '' AND state <> 'CLEANED'`,
+	"all":    `SELECT ` + sessionColumns + ` FROM sessions ORDER BY created_at, id`,
+	"update": `UPDATE sessions SET owner_id = $2, name = $3, labels = $4, state = $5, worktree_dir = $6,
+		last_active = $7, expires_at = $8, message_count = $9, version = $10, collaborators = $12, agent_info = $13,
+		cleaned_at = $14 WHERE id = $1 AND version = $11`,
+	"delete": `DELETE FROM sessions WHERE id = $1`,
+	"count":  `SELECT count(*) FROM sessions`,
+}
+
+// PostgresStore implements Store on a PostgreSQL database so several relays
+// can share session metadata
+// Each relay keeps the *Session objects it has handed out, refreshed from
+// the database on every read, so handles (WebSocket, agent) attached by this
+// relay survive reloads; sessions owned by another relay are seen detached.
+// Update is compare-and-swap on the session version and returns
+// ErrVersionConflict when another writer got there first.
+// The caller owns db: register a driver (e.g. pgx's stdlib), size its
+// connection pool, and run MigratePostgres before NewPostgresStore.
+type PostgresStore struct {
+	db     *sql.DB
+	stmts  map[string]*sql.Stmt
+	logger Logger
+
+	mu    sync.Mutex
+	local map[string]*Session // Sessions handed out by this store
+}
+
+// NewPostgresStore prepares the store's statements on db
+// Read failures are logged with logger, since Get and List cannot return errors.
+func NewPostgresStore(ctx context.Context, db *sql.DB, logger Logger) (*PostgresStore, error) {
+	p := &PostgresStore{
+		db:     db,
+		stmts:  make(map[string]*sql.Stmt, len(postgresStatements)),
+		logger: logger,
+		local:  make(map[string]*Session),
+	}
+	for name, query := range postgresStatements {
+		stmt, err := db.PrepareContext(ctx, query)
+		if err != nil {
+			_ = p.Close()
+			return nil, fmt.Errorf("prepare %s: %w", name, err)
+		}
+		p.stmts[name] = stmt
+	}
+	return p, nil
+}
+
+// Close releases the prepared statements; the caller closes db
+func (p *PostgresStore) Close() error {
+	var errs []error
+	for _, stmt := range p.stmts {
+		errs = append(errs, stmt.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Create inserts a new session
+func (p *PostgresStore) Create(session *Session) error {
+	if session == nil {
+		return fmt.Errorf("session cannot be nil")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postgresQueryTimeout)
+	defer cancel()
+
+	session.mu.RLock()
+	rec := session.record()
+	session.mu.RUnlock()
+
+	args, err := insertArgs(rec)
+	if err != nil {
+		return err
+	}
+
+	// Cached before the insert so a concurrent read hydrates this object, not a copy
+	p.mu.Lock()
+	if _, exists := p.local[rec.ID]; exists {
+		p.mu.Unlock()
+		return fmt.Errorf("session with ID %s already exists", rec.ID)
+	}
+	p.local[rec.ID] = session
+	p.mu.Unlock()
+
+	res, err := p.stmts["insert"].ExecContext(ctx, args...)
+	var n int64
+	if err == nil {
+		n, err = res.RowsAffected()
+	}
+	if err != nil || n == 0 {
+		p.forget(rec.ID)
+		if err != nil {
+			return fmt.Errorf("insert session: %w", err)
+		}
+		return p.createConflict(ctx, rec)
+	}
+	return nil
+}
+
+// createConflict explains why an insert hit an existing row
+func (p *PostgresStore) createConflict(ctx context.Context, rec sessionRecord) error {
+	if rec.Name != "" {
+		if existing, err := p.load(ctx, "byName", rec.OwnerID, rec.Name); err == nil && existing != nil {
+			return fmt.Errorf("%w: %q (session_id=%s)", ErrNameTaken, rec.Name, existing.ID)
+		}
+	}
+	if existing, err := p.load(ctx, "byRole", rec.AgentID); err == nil && existing != nil {
+		return fmt.Errorf("session for agent %s already exists (session_id=%s)", rec.AgentID, existing.ID)
+	}
+	return fmt.Errorf("session with ID %s already exists", rec.ID)
+}
+
+// Get retrieves a session by ID
+func (p *PostgresStore) Get(id string) *Session {
+	return p.get("byID", id)
+}
+
+// GetByRole retrieves a session by agent role
+func (p *PostgresStore) GetByRole(agentID string) *Session {
+	return p.get("byRole", agentID)
+}
+
+// GetByName retrieves a session by owner and name
+func (p *PostgresStore) GetByName(ownerID, name string) *Session {
+	if name == "" {
+		return nil
+	}
+	return p.get("byName", ownerID, name)
+}
+
+// get loads one session, logging failures
+func (p *PostgresStore) get(stmt string, args ...interface{}) *Session {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresQueryTimeout)
+	defer cancel()
+	session, err := p.load(ctx, stmt, args...)
+	if err != nil {
+		p.logger.Printf("Session store read failed: query=%s err=%v", stmt, err)
+		return nil
+	}
+	return session
+}
+
+// load runs a single-row query and hydrates the result (nil if no row)
+func (p *PostgresStore) load(ctx context.Context, stmt string, args ...interface{}) (*Session, error) {
+	rec, err := scanRecord(p.stmts[stmt].QueryRowContext(ctx, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		if stmt == "byID" {
+			p.forget(args[0].(string))
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.hydrate(rec), nil
+}
+
+// List returns sessions matching the filter, sorted and paginated
+// Filtering runs in the relay over all rows, like MemoryStore
+func (p *PostgresStore) List(filter *SessionFilter) []*Session {
+	sessions, err := p.loadAll()
+	if err != nil {
+		p.logger.Printf("Session store read failed: query=all err=%v", err)
+		return nil
+	}
+	now := filter.referenceTime()
+	var result []*Session
+	for _, session := range sessions {
+		if filter.Matches(session, now) {
+			result = append(result, session)
+		}
+	}
+	return filter.Apply(result)
+}
+
+// Range calls fn for every session in one query's snapshot
+func (p *PostgresStore) Range(fn func(session *Session)) {
+	sessions, err := p.loadAll()
+	if err != nil {
+		p.logger.Printf("Session store read failed: query=all err=%v", err)
+		return
+	}
+	for _, session := range sessions {
+		fn(session)
+	}
+}
+
+// loadAll hydrates every row
+// Sessions deleted by another relay stay cached until a Get misses them.
+func (p *PostgresStore) loadAll() ([]*Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresQueryTimeout)
+	defer cancel()
+
+	rows, err := p.stmts["all"].QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sessions []*Session
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, p.hydrate(rec))
+	}
+	return sessions, rows.Err()
+}
+
+// Update applies fn to the latest copy of the session and writes it back if
+// nobody else wrote it meanwhile
+// If the write fails (including ErrVersionConflict) fn's changes to persisted
+// fields are undone; changes to the handle are kept.
+func (p *PostgresStore) Update(id string, fn UpdateFunc) error {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresQueryTimeout)
+	defer cancel()
+
+	session, err := p.load(ctx, "byID", id)
+	if err != nil {
+		return fmt.Errorf("load session: %w", err)
+	}
+	if session == nil {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+
+	return session.withLock(func(s *Session) error {
+		before := s.record()
+		if err := fn(s); err != nil {
+			return err
+		}
+		// Undo fn's changes if the write fails; the forced version makes
+		// applyRecord restore every field
+		restore := func() {
+			s.version = ^uint64(0)
+			s.applyRecord(before)
+		}
+
+		after := s.record()
+		after.Version = before.Version + 1
+		args, err := updateArgs(after, before.Version)
+		if err != nil {
+			restore()
+			return err
+		}
+		res, err := p.stmts["update"].ExecContext(ctx, args...)
+		if err == nil {
+			var n int64
+			if n, err = res.RowsAffected(); err == nil && n == 0 {
+				err = fmt.Errorf("%w: %s", ErrVersionConflict, id)
+			}
+		}
+		if err != nil {
+			restore()
+			return err
+		}
+		s.bumpVersion()
+		return nil
+	})
+}
+
+// Delete removes a session; idempotent
+func (p *PostgresStore) Delete(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresQueryTimeout)
+	defer cancel()
+	if _, err := p.stmts["delete"].ExecContext(ctx, id); err != nil {
+		p.logger.Printf("Session store delete failed: id=%s err=%v", id, err)
+	}
+	p.forget(id)
+}
+
+// Count returns the number of stored sessions (0 if the query fails)
+func (p *PostgresStore) Count() int {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresQueryTimeout)
+	defer cancel()
+	var n int
+	if err := p.stmts["count"].QueryRowContext(ctx).Scan(&n); err != nil {
+		p.logger.Printf("Session store read failed: query=count err=%v", err)
+		return 0
+	}
+	return n
+}
+
+// hydrate returns this relay's session object for rec, refreshed from it
+func (p *PostgresStore) hydrate(rec sessionRecord) *Session {
+	p.mu.Lock()
+	session, ok := p.local[rec.ID]
+	if !ok {
+		session = sessionFromRecord(rec)
+		p.local[rec.ID] = session
+	}
+	p.mu.Unlock()
+
+	if ok {
+		_ = session.withLock(func(s *Session) error {
+			s.applyRecord(rec)
+			return nil
+		})
+	}
+	return session
+}
+
+// forget drops a session from the local cache
+func (p *PostgresStore) forget(id string) {
+	p.mu.Lock()
+	delete(p.local, id)
+	p.mu.Unlock()
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRecord reads one row selected with sessionColumns
+func scanRecord(row rowScanner) (sessionRecord, error) {
+	var rec sessionRecord
+	var labels, collaborators, agent, info []byte
+	var state string
+	var expiresAt, cleanedAt sql.NullTime
+	err := row.Scan(&rec.ID, &rec.AgentID, &rec.OwnerID, &rec.Name, &labels, &state,
+		&rec.WorktreeDir, &rec.CreatedAt, &rec.LastActive, &expiresAt, &rec.MessageCount, &rec.Version, &collaborators, &agent, &info, &cleanedAt)
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(labels, &rec.Labels); err != nil {
+		return rec, fmt.Errorf("decode labels of %s: %w", rec.ID, err)
+	}
+	if err := json.Unmarshal(collaborators, &rec.Collaborators); err != nil {
+		return rec, fmt.Errorf("decode collaborators of %s: %w", rec.ID, err)
+	}
+	if len(rec.Collaborators) == 0 {
+		rec.Collaborators = nil
+	}
+	var cfg AgentConfig
+	if err := json.Unmarshal(agent, &cfg); err != nil {
+		return rec, fmt.Errorf("decode agent config of %s: %w", rec.ID, err)
+	}
+	rec.Agent = cfg.ptr()
+	if len(info) > 0 {
+		if err := json.Unmarshal(info, &rec.AgentInfo); err != nil {
+			return rec, fmt.Errorf("decode agent info of %s: %w", rec.ID, err)
+		}
+	}
+	rec.State = SessionState(state)
+	if !rec.State.IsValid() {
+		return rec, fmt.Errorf("session %s has unknown state %q", rec.ID, state)
+	}
+	if expiresAt.Valid {
+		rec.ExpiresAt = expiresAt.Time
+	}
+	if cleanedAt.Valid {
+		rec.CleanedAt = cleanedAt.Time
+	}
+	return rec, nil
+}
+
+// insertArgs lists rec in sessionColumns order (pure function)
+func insertArgs(rec sessionRecord) ([]interface{}, error) {
+	labels, err := encodeLabels(rec.Labels)
+	if err != nil {
+		return nil, err
+	}
+	collaborators, err := encodeCollaborators(rec.Collaborators)
+	if err != nil {
+		return nil, err
+	}
+	agent, err := encodeAgentConfig(rec.Agent)
+	if err != nil {
+		return nil, err
+	}
+	info, err := encodeAgentInfo(rec.AgentInfo)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		rec.ID, rec.AgentID, rec.OwnerID, rec.Name, labels, string(rec.State), rec.WorktreeDir,
+		rec.CreatedAt, rec.LastActive, nullTime(rec.ExpiresAt), rec.MessageCount, rec.Version, collaborators, agent, info,
+		nullTime(rec.CleanedAt),
+	}, nil
+}
+
+// updateArgs lists the update statement's parameters (pure function)
+func updateArgs(rec sessionRecord, expectedVersion uint64) ([]interface{}, error) {
+	labels, err := encodeLabels(rec.Labels)
+	if err != nil {
+		return nil, err
+	}
+	collaborators, err := encodeCollaborators(rec.Collaborators)
+	if err != nil {
+		return nil, err
+	}
+	info, err := encodeAgentInfo(rec.AgentInfo)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		rec.ID, rec.OwnerID, rec.Name, labels, string(rec.State), rec.WorktreeDir,
+		rec.LastActive, nullTime(rec.ExpiresAt), rec.MessageCount, rec.Version, expectedVersion, collaborators, info,
+		nullTime(rec.CleanedAt),
+	}, nil
+}
+
+// encodeLabels renders labels as a JSON object, {} when empty (pure function)
+func encodeLabels(labels map[string]string) (string, error) {
+	if len(labels) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", fmt.Errorf("encode labels: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeCollaborators renders user IDs as a JSON array, [] when empty (pure function)
+func encodeCollaborators(users []string) (string, error) {
+	if len(users) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal(users)
+	if err != nil {
+		return "", fmt.Errorf("encode collaborators: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeAgentConfig renders an agent config as a JSON object, {} when unset
+// (pure function)
+func encodeAgentConfig(cfg *AgentConfig) (string, error) {
+	if cfg == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("encode agent config: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeAgentInfo renders what an agent reported at initialize as JSON, or
+// SQL NULL when it is unknown (pure function)
+func encodeAgentInfo(info *acp.InitializeResult) (sql.NullString, error) {
+	if info == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("encode agent info: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// nullTime maps the zero time to SQL NULL (pure function)
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// migration is one embedded schema change
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations reads NNNN_description.sql files from fsys in version order
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	names, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]migration, 0, len(names))
+	seen := make(map[int]string)
+	for _, name := range names {
+		base := path.Base(name)
+		prefix, _, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s: name must start with a positive version and _", base)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, base, version)
+		}
+		seen[version] = base
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: base, sql: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// MigratePostgres brings the database schema used by PostgresStore up to date
+// Applied versions are recorded in schema_migrations. The whole run is one
+// transaction under an advisory lock, so relays starting together don't race
+// and a failed migration leaves nothing half-applied.
+func MigratePostgres(ctx context.Context, db *sql.DB) error {
+	migrations, err := loadMigrations(postgresMigrations)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+		return fmt.Errorf("migrate: lock: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	for _, m := range migrations {
+		var applied bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.version).Scan(&applied); err != nil {
+			return fmt.Errorf("migrate %s: %w", m.name, err)
+		}
+		if applied {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+			return fmt.Errorf("migrate %s: %w", m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+			return fmt.Errorf("migrate %s: %w", m.name, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,332 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// fakeRow scans fixed values the way database/sql converts driver values
+type fakeRow []interface{}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *string:
+			*d = r[i].(string)
+		case *[]byte:
+			switch v := r[i].(type) {
+			case string:
+				*d = []byte(v)
+			case sql.NullString:
+				*d = nil
+				if v.Valid {
+					*d = []byte(v.String)
+				}
+			}
+		case *time.Time:
+			*d = r[i].(time.Time)
+		case *sql.NullTime:
+			*d = r[i].(sql.NullTime)
+		case *int:
+			*d = r[i].(int)
+		case *uint64:
+			*d = r[i].(uint64)
+		}
+	}
+	return nil
+}
+
+func testRecord() sessionRecord {
+	created := time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC)
+	temperature := 0.5
+	return sessionRecord{
+		ID:            "s1",
+		AgentID:       "auth",
+		OwnerID:       "alice",
+		Collaborators: []string{"bob"},
+		Name:          "login flow",
+		Labels:        map[string]string{"ticket": "BUG-1"},
+		Agent:         &AgentConfig{SystemPrompt: "Review only.", Model: "claude-sonnet", Temperature: &temperature},
+		AgentInfo: &acp.InitializeResult{AgentName: "claude-code-acp", ProtocolVersion: 1,
+			Capabilities: acp.AgentCapabilities{Tools: []string{"bash"}, Streaming: true}},
+		State:        StateActive,
+		WorktreeDir:  "/tmp/worktree",
+		CreatedAt:    created,
+		LastActive:   created.Add(time.Minute),
+		ExpiresAt:    created.Add(time.Hour),
+		MessageCount: 3,
+		Version:      7,
+	}
+}
+
+func TestSessionRecord_RoundTrip(t *testing.T) {
+	rec := testRecord()
+	session := sessionFromRecord(rec)
+	if got := session.record(); !reflect.DeepEqual(got, rec) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, rec)
+	}
+
+	// Args and scanned rows line up with sessionColumns
+	args, err := insertArgs(rec)
+	if err != nil {
+		t.Fatalf("insertArgs failed: %v", err)
+	}
+	if want := len(strings.Split(sessionColumns, ",")); len(args) != want {
+		t.Fatalf("insertArgs has %d values for %d columns", len(args), want)
+	}
+	scanned, err := scanRecord(fakeRow(args))
+	if err != nil {
+		t.Fatalf("scanRecord failed: %v", err)
+	}
+	if !reflect.DeepEqual(scanned, rec) {
+		t.Errorf("scan mismatch:\n got %+v\nwant %+v", scanned, rec)
+	}
+}
+
+func TestScanRecord_Rejects(t *testing.T) {
+	base, _ := insertArgs(testRecord())
+	tests := []struct {
+		name   string
+		column int
+		value  interface{}
+		errSub string
+	}{
+		{"bad labels", 4, "not json", "decode labels"},
+		{"unknown state", 5, "RUNNING", "unknown state"},
+		{"bad collaborators", 12, "not json", "decode collaborators"},
+		{"bad agent config", 13, "not json", "decode agent config"},
+		{"bad agent info", 14, "not json", "decode agent info"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := append(fakeRow{}, base...)
+			row[tt.column] = tt.value
+			if _, err := scanRecord(row); err == nil || !strings.Contains(err.Error(), tt.errSub) {
+				t.Errorf("expected error containing %q, got %v", tt.errSub, err)
+			}
+		})
+	}
+
+	// No TTL, labels, agent config or agent info
+	rec := testRecord()
+	rec.ExpiresAt, rec.Labels, rec.Agent, rec.AgentInfo = time.Time{}, nil, nil, nil
+	args, _ := insertArgs(rec)
+	if args[4] != "{}" || args[9].(sql.NullTime).Valid || args[13] != "{}" || args[14].(sql.NullString).Valid {
+		t.Errorf("expected {} labels, NULL expiry, {} agent config and NULL agent info, got %v, %v, %v and %v",
+			args[4], args[9], args[13], args[14])
+	}
+	if scanned, err := scanRecord(fakeRow(args)); err != nil || scanned.Agent != nil || scanned.AgentInfo != nil {
+		t.Errorf("expected no agent config or info, got %+v and %+v (err=%v)", scanned.Agent, scanned.AgentInfo, err)
+	}
+}
+
+func TestSession_ApplyRecord(t *testing.T) {
+	rec := testRecord()
+	session := sessionFromRecord(rec)
+	session.lastActiveMono = time.Second
+
+	// Same version: our own write, monotonic readings kept
+	session.applyRecord(rec)
+	if session.lastActiveMono != time.Second {
+		t.Error("applying the current version should change nothing")
+	}
+
+	rec.Version++
+	rec.State = StateTerminating
+	rec.Labels = nil
+	session.applyRecord(rec)
+	if session.GetState() != StateTerminating || session.GetVersion() != 8 || session.GetLabels() != nil {
+		t.Errorf("record not applied: state=%s version=%d", session.GetState(), session.GetVersion())
+	}
+	if session.lastActiveMono != 0 {
+		t.Error("monotonic reading should be cleared after another writer's update")
+	}
+}
+
+func TestUpdateArgs(t *testing.T) {
+	rec := testRecord()
+	args, err := updateArgs(rec, 6)
+	if err != nil {
+		t.Fatalf("updateArgs failed: %v", err)
+	}
+	// $1 is the ID, $10 the new version, $11 the expected one, $12 the collaborators, $13 the agent info, $14 the cleanup time
+	if len(args) != 14 || args[0] != "s1" || args[9] != uint64(7) || args[10] != uint64(6) || args[11] != `["bob"]` ||
+		!args[12].(sql.NullString).Valid || args[13].(sql.NullTime).Valid {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	embedded, err := loadMigrations(postgresMigrations)
+	if err != nil {
+		t.Fatalf("embedded migrations: %v", err)
+	}
+	if len(embedded) == 0 || embedded[0].version != 1 || !strings.Contains(embedded[0].sql, "CREATE TABLE IF NOT EXISTS sessions") {
+		t.Errorf("unexpected embedded migrations: %+v", embedded)
+	}
+
+	ordered, err := loadMigrations(fstest.MapFS{
+		"migrations/0010_later.sql": {Data: []byte("B")},
+		"migrations/0002_first.sql": {Data: []byte("A")},
+	})
+	if err != nil {
+		t.Fatalf("loadMigrations failed: %v", err)
+	}
+	if len(ordered) != 2 || ordered[0].sql != "A" || ordered[1].version != 10 {
+		t.Errorf("expected version order, got %+v", ordered)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"no version": {"migrations/init.sql": {}},
+		"duplicate":  {"migrations/0001_a.sql": {}, "migrations/01_b.sql": {}},
+	} {
+		if _, err := loadMigrations(fsys); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// openTestPostgres connects to the database named by SESSION_STORE_TEST_DSN
+// in a schema of its own, dropped when the test ends
+// Tests using it are skipped when the variable is unset.
+func openTestPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("SESSION_STORE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("SESSION_STORE_TEST_DSN not set")
+	}
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+	schema := fmt.Sprintf("ourocodus_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse DSN: %v", err)
+	}
+	cfg.RuntimeParams["search_path"] = schema
+	db := stdlib.OpenDB(*cfg)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestPostgresStore_AgainstDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := openTestPostgres(t)
+
+	for i := 0; i < 2; i++ { // A second run finds everything applied
+		if err := MigratePostgres(ctx, db); err != nil {
+			t.Fatalf("MigratePostgres run %d failed: %v", i+1, err)
+		}
+	}
+	migrations, err := loadMigrations(postgresMigrations)
+	if err != nil {
+		t.Fatalf("loadMigrations failed: %v", err)
+	}
+	var applied int
+	if err := db.QueryRow(`SELECT count(*) FROM schema_migrations`).Scan(&applied); err != nil || applied != len(migrations) {
+		t.Fatalf("expected %d migrations recorded, got %d (%v)", len(migrations), applied, err)
+	}
+
+	// Two stores on one database stand in for two relays
+	store, err := NewPostgresStore(ctx, db, &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewPostgresStore failed: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	other, err := NewPostgresStore(ctx, db, &mockLogger{})
+	if err != nil {
+		t.Fatalf("NewPostgresStore failed: %v", err)
+	}
+	defer func() { _ = other.Close() }()
+
+	idGen := &mockIDGenerator{nextID: "s1"}
+	clock := &mockClock{now: time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC), mono: time.Second}
+	manager := NewManager(store, idGen, clock, &mockCleaner{}, &mockLogger{}, WithDefaultTTL(time.Hour), WithRetention(time.Hour))
+	sess, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithOwner("alice"), WithName("login"),
+		WithLabels(map[string]string{"ticket": "BUG-1"}))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, "s1"); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, "s1", "/tmp/worktree", &mockACPClient{}); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+
+	loaded := other.GetByName("alice", "login")
+	if loaded == nil || other.GetByRole("auth") == nil || other.Count() != 1 {
+		t.Fatal("expected the session found by name and role from the other store")
+	}
+	if got, want := loaded.record(), sess.record(); got.Version != want.Version || got.State != StateActive ||
+		got.WorktreeDir != "/tmp/worktree" || got.Labels["ticket"] != "BUG-1" || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+	if loaded.GetHandle() != nil {
+		t.Error("expected a session attached by another relay to be detached")
+	}
+
+	if err := other.Create(NewSession("s2", "auth", clock.Now())); err == nil || !strings.Contains(err.Error(), "agent auth") {
+		t.Errorf("expected a live session's role to be refused, got %v", err)
+	}
+	named := NewSession("s2", "db", clock.Now())
+	named.setOwnerID("alice")
+	named.setName("login")
+	if err := other.Create(named); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("expected ErrNameTaken, got %v", err)
+	}
+
+	// The other relay writes while this one is mid-update
+	err = store.Update("s1", func(s *Session) error {
+		s.setLabels(map[string]string{"ticket": "BUG-2"})
+		return other.Update("s1", func(s *Session) error {
+			s.setLabels(map[string]string{"ticket": "BUG-3"})
+			return nil
+		})
+	})
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if got := store.Get("s1").GetLabels()["ticket"]; got != "BUG-3" {
+		t.Errorf("expected the other relay's write to win, got %q", got)
+	}
+
+	// Retained cleaned sessions give up their role and name
+	if err := manager.MarkTerminating(ctx, "s1", "done"); err != nil {
+		t.Fatalf("MarkTerminating failed: %v", err)
+	}
+	if err := manager.CompleteCleanup(ctx, "s1"); err != nil {
+		t.Fatalf("CompleteCleanup failed: %v", err)
+	}
+	if other.GetByRole("auth") != nil || other.GetByName("alice", "login") != nil {
+		t.Error("expected a cleaned session's role and name released")
+	}
+	if err := other.Create(NewSession("s3", "auth", clock.Now())); err != nil {
+		t.Errorf("expected the role reusable once cleaned, got %v", err)
+	}
+	store.Delete("s1")
+	store.Delete("s1") // Idempotent
+	if store.Get("s1") != nil || store.Count() != 1 {
+		t.Errorf("expected only s3 left, got %d sessions", store.Count())
+	}
+}
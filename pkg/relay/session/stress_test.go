@@ -0,0 +1,169 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/testutil"
+)
+
+// trackedACPClient counts how many of the agents it stands for are still open
+type trackedACPClient struct {
+	mockACPClient
+	open   *atomic.Int64
+	closed atomic.Bool
+}
+
+func newTrackedACPClient(open *atomic.Int64) *trackedACPClient {
+	open.Add(1)
+	return &trackedACPClient{open: open}
+}
+
+func (c *trackedACPClient) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.open.Add(-1)
+	}
+	return nil
+}
+
+// TestManager_Stress_SpawnTerminateHeartbeat races a spawn, two terminations
+// (as the reaper and an owner purge do them), heartbeats and prompts on one
+// session, round after round
+// Run with -race. Whatever the interleaving, the session must end cleaned
+// and out of the store with every agent started for it closed.
+func TestManager_Stress_SpawnTerminateHeartbeat(t *testing.T) {
+	const (
+		rounds     = 200
+		heartbeats = 8
+	)
+	testutil.CheckGoroutines(t)
+	ctx := context.Background()
+	manager, idGen, _, _, _ := setupManager()
+	var open atomic.Int64
+
+	for round := 0; round < rounds; round++ {
+		idGen.nextID = fmt.Sprintf("session-%d", round)
+		sess, err := manager.Create(ctx, "auth", &mockWebSocket{}, WithOwner("user-1"))
+		if err != nil {
+			t.Fatalf("round %d: Create failed: %v", round, err)
+		}
+		id := sess.GetID()
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		run := func(f func()) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				f()
+			}()
+		}
+
+		// Spawn the way relay.Spawner does: a client that cannot be attached
+		// is closed by the spawner
+		run(func() {
+			if err := manager.BeginSpawn(ctx, id); err != nil {
+				return
+			}
+			client := newTrackedACPClient(&open)
+			if err := manager.AttachAgent(ctx, id, "/tmp/worktree", client); err != nil {
+				_ = client.Close()
+			}
+		})
+		run(func() { _ = manager.terminate(ctx, sess, "stress") })
+		run(func() { _, _ = manager.PurgeOwner(ctx, "user-1") })
+		for i := 0; i < heartbeats; i++ {
+			run(func() {
+				for j := 0; j < 10; j++ {
+					_ = manager.RecordHeartbeat(ctx, id)
+					_, _ = manager.SendMessage(ctx, id, "ping")
+					_ = sess.GetState()
+				}
+			})
+		}
+		close(start)
+		wg.Wait()
+
+		if manager.Get(id) != nil {
+			t.Fatalf("round %d: session still stored after terminate", round)
+		}
+		if state := sess.GetState(); state != StateCleaned {
+			t.Fatalf("round %d: expected %s, got %s", round, StateCleaned, state)
+		}
+		if n := open.Load(); n != 0 {
+			t.Fatalf("round %d: %d agents left open after terminate", round, n)
+		}
+	}
+	if n := manager.Count(); n != 0 {
+		t.Errorf("expected no sessions, got %d", n)
+	}
+}
+
+// TestManager_Stress_ConcurrentSessions drives many sessions through their
+// whole lifecycle at once, each with heartbeats racing its termination
+func TestManager_Stress_ConcurrentSessions(t *testing.T) {
+	const sessions = 50
+	testutil.CheckGoroutines(t)
+	ctx := context.Background()
+	store := NewMemoryStore()
+	var seq atomic.Int64
+	manager := NewManager(store, idFunc(func() string { return fmt.Sprintf("session-%d", seq.Add(1)) }),
+		&mockClock{}, &mockCleaner{}, &mockLogger{})
+	var open atomic.Int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sess, err := manager.Create(ctx, fmt.Sprintf("role-%d", i), &mockWebSocket{})
+			if err != nil {
+				t.Errorf("Create failed: %v", err)
+				return
+			}
+			id := sess.GetID()
+			if err := manager.BeginSpawn(ctx, id); err != nil {
+				t.Errorf("BeginSpawn failed: %v", err)
+				return
+			}
+			client := newTrackedACPClient(&open)
+			if err := manager.AttachAgent(ctx, id, "/tmp/worktree", client); err != nil {
+				_ = client.Close()
+				t.Errorf("AttachAgent failed: %v", err)
+				return
+			}
+
+			var inner sync.WaitGroup
+			for j := 0; j < 4; j++ {
+				inner.Add(1)
+				go func() {
+					defer inner.Done()
+					for k := 0; k < 20; k++ {
+						_ = manager.RecordHeartbeat(ctx, id)
+					}
+				}()
+			}
+			if err := manager.terminate(ctx, sess, "stress"); err != nil {
+				t.Errorf("terminate failed: %v", err)
+			}
+			inner.Wait()
+		}(i)
+	}
+	wg.Wait()
+
+	if n := manager.Count(); n != 0 {
+		t.Errorf("expected every session cleaned up, %d remain", n)
+	}
+	if n := open.Load(); n != 0 {
+		t.Errorf("%d agents left open after terminate", n)
+	}
+}
+
+// idFunc adapts a function to IDGenerator
+type idFunc func() string
+
+func (f idFunc) Generate() string { return f() }
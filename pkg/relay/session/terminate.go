@@ -0,0 +1,132 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultTerminateParallelism is how many sessions TerminateAll ends at once
+// unless told otherwise; each closes an agent process and a workspace
+const DefaultTerminateParallelism = 8
+
+// TerminateProgress reports one session TerminateAll has dealt with
+type TerminateProgress struct {
+	SessionID string
+	Err       error // Set if the session could not be terminated
+	Done      int   // Sessions dealt with so far, this one included
+	Total     int   // Sessions matched
+}
+
+// TerminateOption configures TerminateAll
+type TerminateOption func(*terminateConfig)
+
+type terminateConfig struct {
+	parallelism int
+	reason      string
+	progress    func(TerminateProgress)
+}
+
+// WithTerminateParallelism bounds how many sessions are terminated at once
+// Values below 1 are ignored.
+func WithTerminateParallelism(n int) TerminateOption {
+	return func(c *terminateConfig) {
+		if n > 0 {
+			c.parallelism = n
+		}
+	}
+}
+
+// WithTerminateReason sets the reason recorded on each session
+func WithTerminateReason(reason string) TerminateOption {
+	return func(c *terminateConfig) {
+		c.reason = reason
+	}
+}
+
+// WithTerminateProgress calls fn after each session is dealt with
+// Calls are made one at a time, with Done counting up to Total.
+func WithTerminateProgress(fn func(TerminateProgress)) TerminateOption {
+	return func(c *terminateConfig) {
+		c.progress = fn
+	}
+}
+
+// newTerminateConfig applies opts to the defaults (pure function)
+func newTerminateConfig(opts []TerminateOption) terminateConfig {
+	cfg := terminateConfig{parallelism: DefaultTerminateParallelism, reason: "terminated"}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// TerminateAll terminates every session matching filter (nil = all), closing
+// their agents and cleaning them up, several at a time
+// Sessions already terminating or cleaned are left to finish on their own.
+// Returns how many sessions were terminated; failures are joined into the
+// error. Once ctx is done no further sessions are started and ctx's error is
+// included.
+func (m *Manager) TerminateAll(ctx context.Context, filter *SessionFilter, opts ...TerminateOption) (int, error) {
+	var targets []*Session
+	for _, sess := range m.List(filter) {
+		switch sess.GetState() {
+		case StateTerminating, StateCleaned:
+			continue
+		}
+		targets = append(targets, sess)
+	}
+	return m.terminateEach(ctx, targets, newTerminateConfig(opts))
+}
+
+// terminateEach terminates sessions with at most cfg.parallelism at once
+func (m *Manager) terminateEach(ctx context.Context, sessions []*Session, cfg terminateConfig) (int, error) {
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		errs       []error
+		terminated int
+		done       int
+	)
+	slots := make(chan struct{}, cfg.parallelism)
+
+start:
+	for _, sess := range sessions {
+		select {
+		case <-ctx.Done():
+			break start
+		case slots <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break // Both cases were ready
+		}
+		wg.Add(1)
+		go func(sess *Session) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			err := m.terminate(ctx, sess, cfg.reason)
+			if err != nil {
+				err = fmt.Errorf("session %s: %w", sess.GetID(), err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				terminated++
+			}
+			if cfg.progress != nil {
+				cfg.progress(TerminateProgress{SessionID: sess.GetID(), Err: err, Done: done, Total: len(sessions)})
+			}
+		}(sess)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return terminated, errors.Join(errs...)
+}
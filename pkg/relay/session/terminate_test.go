@@ -0,0 +1,122 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrencyACPClient records how many agents are being closed at once
+type concurrencyACPClient struct {
+	mockACPClient
+	closing *atomic.Int32
+	peak    *atomic.Int32
+}
+
+func (c *concurrencyACPClient) Close() error {
+	n := c.closing.Add(1)
+	defer c.closing.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return nil
+}
+
+func TestManager_TerminateAll(t *testing.T) {
+	ctx := context.Background()
+	manager, idGen, _, cleaner, _ := setupManager()
+	var closing, peak atomic.Int32
+	for i := 0; i < 6; i++ {
+		idGen.nextID = fmt.Sprintf("s%d", i)
+		sess, err := manager.Create(ctx, fmt.Sprintf("role-%d", i), &mockWebSocket{}, WithLabels(map[string]string{"batch": "nightly"}))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := manager.BeginSpawn(ctx, sess.GetID()); err != nil {
+			t.Fatalf("BeginSpawn failed: %v", err)
+		}
+		if err := manager.AttachAgent(ctx, sess.GetID(), "/tmp/worktree", &concurrencyACPClient{closing: &closing, peak: &peak}); err != nil {
+			t.Fatalf("AttachAgent failed: %v", err)
+		}
+	}
+	idGen.nextID = "other"
+	if _, err := manager.Create(ctx, "db", &mockWebSocket{}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var progress []TerminateProgress
+	n, err := manager.TerminateAll(ctx, &SessionFilter{Labels: map[string]string{"batch": "nightly"}},
+		WithTerminateParallelism(2),
+		WithTerminateProgress(func(p TerminateProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, p)
+		}))
+	if err != nil {
+		t.Fatalf("TerminateAll failed: %v", err)
+	}
+	if n != 6 || cleaner.called != 6 {
+		t.Errorf("expected 6 sessions terminated and cleaned, got %d and %d", n, cleaner.called)
+	}
+	if manager.Get("other") == nil {
+		t.Error("expected the session not matching the filter kept")
+	}
+	if p := peak.Load(); p > 2 || p < 1 {
+		t.Errorf("expected at most 2 sessions terminated at once, saw %d", p)
+	}
+	if len(progress) != 6 {
+		t.Fatalf("expected progress for each session, got %d", len(progress))
+	}
+	for i, p := range progress {
+		if p.Done != i+1 || p.Total != 6 || p.Err != nil {
+			t.Errorf("unexpected progress %d: %+v", i, p)
+		}
+	}
+}
+
+func TestManager_TerminateAll_SkipsTerminating(t *testing.T) {
+	ctx := context.Background()
+	manager, idGen, _, _, _ := setupManager()
+	active := setupActiveSession(t, manager, &mockACPClient{})
+	idGen.nextID = "leaving"
+	leaving, err := manager.Create(ctx, "db", &mockWebSocket{})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.MarkTerminating(ctx, leaving.GetID(), "going"); err != nil {
+		t.Fatalf("MarkTerminating failed: %v", err)
+	}
+
+	n, err := manager.TerminateAll(ctx, nil)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 session terminated, got n=%d err=%v", n, err)
+	}
+	if manager.Get(active.GetID()) != nil {
+		t.Error("expected the active session terminated")
+	}
+	if manager.Get(leaving.GetID()) == nil {
+		t.Error("expected the terminating session left to finish on its own")
+	}
+}
+
+func TestManager_TerminateAll_StopsWhenContextDone(t *testing.T) {
+	manager, _, _, _, _ := setupManager()
+	sess := setupActiveSession(t, manager, &mockACPClient{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := manager.TerminateAll(ctx, nil); err == nil {
+		t.Error("expected ctx's error")
+	}
+	if manager.Get(sess.GetID()) == nil {
+		t.Error("expected no sessions terminated once ctx is done")
+	}
+}
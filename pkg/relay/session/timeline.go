@@ -0,0 +1,142 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimelineDisabled is returned by GetTimeline when no EventJournal is configured
+var ErrTimelineDisabled = errors.New("session timeline is not enabled")
+
+// TimelineKind names what happened at a point on a session's timeline
+type TimelineKind string
+
+const (
+	TimelineCreated      TimelineKind = "created"       // Session created
+	TimelineSpawning     TimelineKind = "spawning"      // Agent process starting
+	TimelineAgentSpawned TimelineKind = "agent_spawned" // Agent up, session active
+	TimelinePaused       TimelineKind = "paused"
+	TimelineResumed      TimelineKind = "resumed"
+	TimelineFailed       TimelineKind = "failed"      // Terminated by a failure; Error says why
+	TimelineTerminating  TimelineKind = "terminating" // Termination requested; Reason says why
+	TimelineTerminated   TimelineKind = "terminated"  // Cleaned up
+	TimelineMessages     TimelineKind = "messages"    // Agent exchanges in one minute
+)
+
+// TimelineEvent is one point on a session's timeline
+// Lifecycle points carry the state entered; TimelineMessages points instead
+// count the prompts the agent answered, and those that failed, in the minute
+// starting at Time.
+type TimelineEvent struct {
+	Time     time.Time
+	Kind     TimelineKind
+	AgentID  string
+	State    SessionState
+	Reason   string
+	Error    string
+	Messages int
+	Failures int
+}
+
+// JournaledEvent is a lifecycle event or agent exchange read back from an
+// EventJournal
+type JournaledEvent struct {
+	Time      time.Time
+	Lifecycle *LifecycleEvent // A state change; nil for an agent exchange
+	Failed    bool            // An agent exchange that returned an error
+}
+
+// EventJournal reads back what happened to a session, for GetTimeline
+// Implementations must be safe for concurrent use
+type EventJournal interface {
+	// SessionEvents returns a session's journaled events, oldest first, or
+	// none if nothing was journaled for it
+	SessionEvents(sessionID string) ([]JournaledEvent, error)
+}
+
+// WithEventJournal enables GetTimeline, reading sessions' events from journal
+func WithEventJournal(journal EventJournal) ManagerOption {
+	return func(c *managerConfig) {
+		c.journal = journal
+	}
+}
+
+// GetTimeline returns what happened to a session, oldest first: its
+// lifecycle changes and per-minute counts of agent exchanges
+// The journal outlives the store, so sessions already cleaned up and deleted
+// have timelines too. Returns ErrTimelineDisabled unless the manager was built
+// WithEventJournal, and ErrSessionNotFound if nothing was journaled for the session.
+func (m *Manager) GetTimeline(sessionID string) ([]TimelineEvent, error) {
+	if m.journal == nil {
+		return nil, ErrTimelineDisabled
+	}
+	events, err := m.journal.SessionEvents(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	return buildTimeline(events), nil
+}
+
+// buildTimeline turns journaled events into timeline points (pure function)
+// Agent exchanges are bucketed by minute; each bucket sits where the first
+// exchange of its minute was journaled.
+func buildTimeline(events []JournaledEvent) []TimelineEvent {
+	timeline := make([]TimelineEvent, 0, len(events))
+	buckets := make(map[time.Time]int) // Minute to index in timeline
+	for _, e := range events {
+		if e.Lifecycle == nil {
+			minute := e.Time.Truncate(time.Minute)
+			i, ok := buckets[minute]
+			if !ok {
+				i = len(timeline)
+				buckets[minute] = i
+				timeline = append(timeline, TimelineEvent{Time: minute, Kind: TimelineMessages})
+			}
+			timeline[i].Messages++
+			if e.Failed {
+				timeline[i].Failures++
+			}
+			continue
+		}
+
+		point := TimelineEvent{
+			Time:    e.Time,
+			Kind:    timelineKind(*e.Lifecycle),
+			AgentID: e.Lifecycle.AgentID,
+			State:   e.Lifecycle.To,
+			Reason:  e.Lifecycle.Reason,
+		}
+		if e.Lifecycle.Err != nil {
+			point.Error = e.Lifecycle.Err.Error()
+		}
+		timeline = append(timeline, point)
+	}
+	return timeline
+}
+
+// timelineKind names a lifecycle change on the timeline (pure function)
+func timelineKind(event LifecycleEvent) TimelineKind {
+	switch event.Event {
+	case EventCreate:
+		return TimelineCreated
+	case EventSpawn:
+		return TimelineSpawning
+	case EventActivate:
+		return TimelineAgentSpawned
+	case EventPause:
+		return TimelinePaused
+	case EventResume:
+		return TimelineResumed
+	case EventTerminate:
+		if event.Err != nil {
+			return TimelineFailed
+		}
+		return TimelineTerminating
+	default:
+		return TimelineTerminated
+	}
+}
@@ -0,0 +1,73 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeJournal serves fixed events for one session
+type fakeJournal struct {
+	sessionID string
+	events    []JournaledEvent
+}
+
+func (j *fakeJournal) SessionEvents(sessionID string) ([]JournaledEvent, error) {
+	if sessionID != j.sessionID {
+		return nil, nil
+	}
+	return j.events, nil
+}
+
+func TestManager_GetTimeline(t *testing.T) {
+	start := time.Date(2025, 10, 23, 12, 0, 10, 0, time.UTC)
+	lifecycle := func(at time.Duration, event Event, to SessionState, cause error) JournaledEvent {
+		return JournaledEvent{Time: start.Add(at), Lifecycle: &LifecycleEvent{AgentID: "auth", Event: event, To: to, Err: cause}}
+	}
+	exchange := func(at time.Duration, failed bool) JournaledEvent {
+		return JournaledEvent{Time: start.Add(at), Failed: failed}
+	}
+	journal := &fakeJournal{sessionID: "s1", events: []JournaledEvent{
+		lifecycle(0, EventCreate, StateCreated, nil),
+		lifecycle(time.Second, EventSpawn, StateSpawning, nil),
+		lifecycle(2*time.Second, EventActivate, StateActive, nil),
+		exchange(10*time.Second, false),
+		exchange(20*time.Second, true),
+		exchange(70*time.Second, false), // Next minute
+		exchange(40*time.Second, false), // Journaled late, still its own minute
+		lifecycle(80*time.Second, EventTerminate, StateTerminating, errors.New("agent crashed")),
+		lifecycle(81*time.Second, EventClean, StateCleaned, nil),
+	}}
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{}, &mockClock{}, &mockCleaner{}, &mockLogger{}, WithEventJournal(journal))
+
+	timeline, err := manager.GetTimeline("s1")
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	want := []TimelineKind{TimelineCreated, TimelineSpawning, TimelineAgentSpawned, TimelineMessages, TimelineMessages, TimelineFailed, TimelineTerminated}
+	if len(timeline) != len(want) {
+		t.Fatalf("expected %d points, got %+v", len(want), timeline)
+	}
+	for i, kind := range want {
+		if timeline[i].Kind != kind {
+			t.Errorf("point %d: expected %s, got %s", i, kind, timeline[i].Kind)
+		}
+	}
+	if first := timeline[3]; first.Messages != 3 || first.Failures != 1 || !first.Time.Equal(start.Truncate(time.Minute)) {
+		t.Errorf("unexpected first minute: %+v", first)
+	}
+	if second := timeline[4]; second.Messages != 1 || second.Failures != 0 {
+		t.Errorf("unexpected second minute: %+v", second)
+	}
+	if failed := timeline[5]; failed.Error != "agent crashed" || failed.State != StateTerminating {
+		t.Errorf("unexpected failure point: %+v", failed)
+	}
+
+	if _, err := manager.GetTimeline("unknown"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	manager, _, _, _, _ = setupManager()
+	if _, err := manager.GetTimeline("s1"); !errors.Is(err, ErrTimelineDisabled) {
+		t.Errorf("expected ErrTimelineDisabled, got %v", err)
+	}
+}
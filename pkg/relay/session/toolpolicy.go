@@ -0,0 +1,336 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+var (
+	// ErrNoToolCall is returned when resolving a tool call the agent is not
+	// waiting on
+	ErrNoToolCall = errors.New("no tool call awaiting approval")
+
+	// ErrToolApprovalUnsupported is returned when a tool call must be
+	// resolved but the session's ACP client cannot answer tool calls
+	ErrToolApprovalUnsupported = errors.New("agent client cannot resolve tool calls")
+)
+
+// maxPolicyRounds bounds how many tool calls in a row the policy resolves
+// for one request; a call past it is left for the client to approve
+const maxPolicyRounds = 32
+
+// ToolDecision is what a ToolPolicy does with a tool call
+type ToolDecision string
+
+// Tool decisions
+const (
+	ToolAllow ToolDecision = "allow" // Approved by the relay
+	ToolAsk   ToolDecision = "ask"   // Left for a client to approve or deny
+	ToolDeny  ToolDecision = "deny"  // Denied by the relay and audited
+)
+
+// ToolPolicy decides which tool calls the relay approves on its own, which
+// it leaves to the client, and which it always denies
+// Entries are tool names or path.Match patterns ("mcp__*"). Deny wins over
+// Ask and Ask over Allow; tools matching none get Default (Ask if empty).
+// Only calls that wait for approval (ToolCall.ID set) can be enforced.
+type ToolPolicy struct {
+	Allow   []string     `json:"allow,omitempty"`
+	Ask     []string     `json:"ask,omitempty"`
+	Deny    []string     `json:"deny,omitempty"`
+	Default ToolDecision `json:"default,omitempty"`
+}
+
+// Decide returns the policy's decision for a tool (pure function)
+func (p *ToolPolicy) Decide(tool string) ToolDecision {
+	switch {
+	case matchesAny(p.Deny, tool):
+		return ToolDeny
+	case matchesAny(p.Ask, tool):
+		return ToolAsk
+	case matchesAny(p.Allow, tool):
+		return ToolAllow
+	case p.Default != "":
+		return p.Default
+	}
+	return ToolAsk
+}
+
+// Validate checks the patterns and default decision
+func (p *ToolPolicy) Validate() error {
+	for _, list := range []struct {
+		name     string
+		patterns []string
+	}{{"allow", p.Allow}, {"ask", p.Ask}, {"deny", p.Deny}} {
+		for _, pattern := range list.patterns {
+			if pattern == "" {
+				return fmt.Errorf("toolPolicy.%s has an empty pattern", list.name)
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("toolPolicy.%s pattern %q: %w", list.name, pattern, err)
+			}
+		}
+	}
+	switch p.Default {
+	case "", ToolAllow, ToolAsk, ToolDeny:
+		return nil
+	}
+	return fmt.Errorf("toolPolicy.default must be %q, %q or %q, got %q", ToolAllow, ToolAsk, ToolDeny, p.Default)
+}
+
+// clone returns a copy sharing no slices with p (nil-safe)
+func (p *ToolPolicy) clone() *ToolPolicy {
+	if p == nil {
+		return nil
+	}
+	return &ToolPolicy{
+		Allow:   slices.Clone(p.Allow),
+		Ask:     slices.Clone(p.Ask),
+		Deny:    slices.Clone(p.Deny),
+		Default: p.Default,
+	}
+}
+
+// matchesAny reports whether tool matches one of the patterns (pure function)
+func matchesAny(patterns []string, tool string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolAuditEntry records a decision on one of an agent's tool calls
+// Decision is ToolAsk for calls a client resolved; Approved is the outcome.
+// Approved calls the relay ran but that failed, e.g. because a file guard
+// refused them, get a second entry with Error set.
+type ToolAuditEntry struct {
+	Time       time.Time
+	Args       map[string]interface{}
+	SessionID  string
+	AgentID    string
+	UserID     string // User who resolved an asked call ("" = relay or anonymous)
+	ToolCallID string
+	Tool       string
+	Error      string // Why the relay could not run the call ("" = decision entry)
+	Decision   ToolDecision
+	Approved   bool
+}
+
+// ToolAuditor consumes tool audit entries
+// It runs on the request's goroutine, so it must be fast.
+type ToolAuditor func(ToolAuditEntry)
+
+// WithDefaultToolPolicy enforces policy on sessions whose AgentConfig sets
+// no ToolPolicy
+// Without it (or a session policy) every tool call is left to the client.
+func WithDefaultToolPolicy(policy ToolPolicy) ManagerOption {
+	return func(c *managerConfig) {
+		c.toolPolicy = policy.clone()
+	}
+}
+
+// WithToolAuditor passes every tool call decision to auditor, besides
+// logging denials
+func WithToolAuditor(auditor ToolAuditor) ManagerOption {
+	return func(c *managerConfig) {
+		c.toolAuditor = auditor
+	}
+}
+
+// ToolRunner runs an approved tool call in the relay instead of the agent,
+// in the session's workspace
+// handled is false for calls it does not run; the agent runs those itself.
+type ToolRunner func(ctx context.Context, workspace string, call *acp.ToolCall) (result *acp.ToolResult, handled bool)
+
+// WithToolRunner runs approved tool calls with run, sending the agent their
+// results instead of letting it run them
+// Repeated options add runners; each call goes to the first that handles it.
+func WithToolRunner(run ToolRunner) ManagerOption {
+	return func(c *managerConfig) {
+		c.toolRunners = append(c.toolRunners, run)
+	}
+}
+
+// ToolCallResolver is optionally implemented by ACP clients whose agent
+// waits for approval before running tools
+type ToolCallResolver interface {
+	ResolveToolCall(ctx context.Context, params acp.ToolCallParams, onDelta func(acp.Delta)) (*acp.AgentMessage, error)
+}
+
+// Ensure the real ACP client can resolve tool calls
+var _ ToolCallResolver = (*acp.Client)(nil)
+
+// ResolveToolCall approves or denies the tool call the session's agent is
+// waiting on, as asked of the client, and returns the agent's reply
+// Calls in the reply are subject to the session's tool policy like those in
+// StreamMessage replies. Returns ErrNoToolCall unless toolCallID is the call
+// the last reply left awaiting approval.
+func (m *Manager) ResolveToolCall(ctx context.Context, sessionID, toolCallID string, approved bool, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	session := m.store.Get(sessionID)
+	if session == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	ctx, stop := linkContext(ctx, session.Context())
+	defer stop()
+
+	release, err := m.admit(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	call := session.takePendingToolCall(toolCallID)
+	if call == nil {
+		return nil, fmt.Errorf("%w: %q on %s", ErrNoToolCall, toolCallID, sessionID)
+	}
+	msg, err := m.resolveToolCall(ctx, session, call, ToolAsk, approved, onDelta)
+	if err == nil {
+		msg, err = m.applyToolPolicy(ctx, session, msg, onDelta)
+	}
+	if err == nil {
+		m.recordHistory(session, SpeakerAgent, "", replyText(msg))
+	}
+	return msg, session.terminatedError(err)
+}
+
+// applyToolPolicy resolves the tool calls the session's policy allows or
+// denies, one at a time, and returns the first reply that leaves a call for
+// the client or has none left
+// The call it leaves is remembered for ResolveToolCall. Agents are expected
+// to wait on one call at a time; later calls in the same reply are left to
+// the client.
+func (m *Manager) applyToolPolicy(ctx context.Context, session *Session, msg *acp.AgentMessage, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	policy := m.toolPolicyFor(session)
+	for round := 0; policy != nil && round < maxPolicyRounds; round++ {
+		call := pendingToolCall(msg)
+		if call == nil {
+			break
+		}
+		decision := policy.Decide(call.Name)
+		if decision == ToolAsk {
+			break
+		}
+		next, err := m.resolveToolCall(ctx, session, call, decision, decision == ToolAllow, onDelta)
+		if err != nil {
+			return nil, err
+		}
+		msg = next
+	}
+	session.setPendingToolCall(pendingToolCall(msg))
+	return msg, nil
+}
+
+// toolPolicyFor returns the session's tool policy, else the manager default
+// (nil = none)
+func (m *Manager) toolPolicyFor(session *Session) *ToolPolicy {
+	if policy := session.GetAgentConfig().ToolPolicy; policy != nil {
+		return policy
+	}
+	return m.toolPolicy
+}
+
+// resolveToolCall audits the decision on a tool call and answers it through
+// the session's ACP client, first running an approved call with the first
+// ToolRunner that takes it
+// A run that fails (ToolResult.Error) is audited again with the error.
+func (m *Manager) resolveToolCall(ctx context.Context, session *Session, call *acp.ToolCall, decision ToolDecision, approved bool, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	m.auditToolCall(ctx, session, call, decision, approved, "")
+	client := session.acpClient()
+	if client == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoAgent, session.GetID())
+	}
+	resolver, ok := client.(ToolCallResolver)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrToolApprovalUnsupported, session.GetID())
+	}
+	ctx, stop := linkContext(ctx, session.AgentContext())
+	defer stop()
+
+	params := acp.ToolCallParams{ToolCallID: call.ID, Approved: approved}
+	if approved {
+		params.Result = m.runTool(ctx, session, call)
+		if params.Result != nil && params.Result.Error != "" {
+			m.auditToolCall(ctx, session, call, decision, approved, params.Result.Error)
+		}
+	}
+	return resolver.ResolveToolCall(ctx, params, onDelta)
+}
+
+// runTool runs call with the first ToolRunner that handles it, returning nil
+// if none does
+func (m *Manager) runTool(ctx context.Context, session *Session, call *acp.ToolCall) *acp.ToolResult {
+	for _, run := range m.toolRunners {
+		if result, handled := run(ctx, session.GetWorktreeDir(), call); handled {
+			return result
+		}
+	}
+	return nil
+}
+
+// auditToolCall reports a tool call decision, or the error of a call the
+// relay ran, to the auditor; denials and errors are logged too
+func (m *Manager) auditToolCall(ctx context.Context, session *Session, call *acp.ToolCall, decision ToolDecision, approved bool, runErr string) {
+	switch {
+	case !approved:
+		m.logger.Printf("Tool call denied: session=%s tool=%s id=%s decision=%s", session.GetID(), call.Name, call.ID, decision)
+	case runErr != "":
+		m.logger.Printf("Tool call failed in relay: session=%s tool=%s id=%s err=%s", session.GetID(), call.Name, call.ID, runErr)
+	}
+	if m.toolAuditor == nil {
+		return
+	}
+	m.toolAuditor(ToolAuditEntry{
+		Time:       m.clock.Now(),
+		Args:       call.Args,
+		SessionID:  session.GetID(),
+		AgentID:    session.GetAgentID(),
+		UserID:     UserFromContext(ctx),
+		ToolCallID: call.ID,
+		Tool:       call.Name,
+		Decision:   decision,
+		Approved:   approved,
+		Error:      runErr,
+	})
+}
+
+// pendingToolCall returns the first call in msg awaiting approval, or nil
+// (pure function)
+func pendingToolCall(msg *acp.AgentMessage) *acp.ToolCall {
+	if msg == nil {
+		return nil
+	}
+	for _, part := range msg.AllParts() {
+		if part.Type == acp.PartTypeToolCall && part.ToolCall != nil && part.ToolCall.ID != "" {
+			return part.ToolCall
+		}
+	}
+	return nil
+}
+
+// setPendingToolCall remembers the call the agent waits on (nil = none)
+func (s *Session) setPendingToolCall(call *acp.ToolCall) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pendingToolCall = call
+}
+
+// takePendingToolCall returns and forgets the call the agent waits on if
+// its ID is id, else returns nil
+func (s *Session) takePendingToolCall(id string) *acp.ToolCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	call := s.pendingToolCall
+	if call == nil || call.ID != id {
+		return nil
+	}
+	s.pendingToolCall = nil
+	return call
+}
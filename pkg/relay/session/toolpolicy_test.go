@@ -0,0 +1,278 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+)
+
+// toolAgentClient asks to run the tool named by each prompt and records how
+// its calls are resolved
+type toolAgentClient struct {
+	mockACPClient
+	mu       sync.Mutex
+	calls    int
+	resolved []acp.ToolCallParams
+}
+
+func (c *toolAgentClient) SendMessage(content string) (*acp.AgentMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	return &acp.AgentMessage{Type: acp.MessageTypeParts, Parts: []acp.Part{
+		{Type: acp.PartTypeText, Text: "May I?"},
+		{Type: acp.PartTypeToolCall, ToolCall: &acp.ToolCall{ID: fmt.Sprintf("tool-%d", c.calls), Name: content}},
+	}}, nil
+}
+
+func (c *toolAgentClient) ResolveToolCall(_ context.Context, params acp.ToolCallParams, _ func(acp.Delta)) (*acp.AgentMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolved = append(c.resolved, params)
+	if !params.Approved {
+		return &acp.AgentMessage{Type: "text", Content: "skipped " + params.ToolCallID}, nil
+	}
+	return &acp.AgentMessage{Type: "text", Content: "ran " + params.ToolCallID}, nil
+}
+
+func (c *toolAgentClient) Resolved() []acp.ToolCallParams {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]acp.ToolCallParams(nil), c.resolved...)
+}
+
+func TestToolPolicy_Decide(t *testing.T) {
+	policy := &ToolPolicy{
+		Allow: []string{"read_file", "mcp__*", "bash"},
+		Ask:   []string{"write_file"},
+		Deny:  []string{"bash", "mcp__shell"},
+	}
+	tests := []struct {
+		tool   string
+		policy *ToolPolicy
+		want   ToolDecision
+	}{
+		{"read_file", policy, ToolAllow},
+		{"mcp__search", policy, ToolAllow},
+		{"write_file", policy, ToolAsk},
+		{"bash", policy, ToolDeny}, // Deny wins over Allow
+		{"mcp__shell", policy, ToolDeny},
+		{"unlisted", policy, ToolAsk},
+		{"unlisted", &ToolPolicy{Default: ToolDeny}, ToolDeny},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Decide(tt.tool); got != tt.want {
+			t.Errorf("Decide(%q) = %s, want %s", tt.tool, got, tt.want)
+		}
+	}
+}
+
+func TestToolPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ToolPolicy
+		errSub string
+	}{
+		{"valid", ToolPolicy{Allow: []string{"read_*"}, Deny: []string{"bash"}, Default: ToolDeny}, ""},
+		{"empty pattern", ToolPolicy{Ask: []string{""}}, "toolPolicy.ask"},
+		{"bad pattern", ToolPolicy{Deny: []string{"[bash"}}, "toolPolicy.deny"},
+		{"unknown default", ToolPolicy{Default: "maybe"}, "toolPolicy.default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.errSub == "" {
+				if err != nil {
+					t.Errorf("expected valid policy, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errSub) {
+				t.Errorf("expected error containing %q, got %v", tt.errSub, err)
+			}
+		})
+	}
+}
+
+func TestManager_ToolPolicy(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu      sync.Mutex
+		entries []ToolAuditEntry
+	)
+	logger := &mockLogger{}
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "test-session-id"}, &mockClock{}, &mockCleaner{}, logger,
+		WithDefaultToolPolicy(ToolPolicy{Allow: []string{"read_file"}, Deny: []string{"bash"}}),
+		WithToolAuditor(func(e ToolAuditEntry) {
+			mu.Lock()
+			defer mu.Unlock()
+			entries = append(entries, e)
+		}))
+	client := &toolAgentClient{}
+	session := setupActiveSession(t, manager, client)
+	id := session.GetID()
+
+	// Allowed: approved by the relay, the client sees the outcome
+	msg, err := manager.SendMessage(ctx, id, "read_file")
+	if err != nil || msg.Content != "ran tool-1" {
+		t.Fatalf("expected the allowed call to run, got %+v, %v", msg, err)
+	}
+
+	// Denied: refused by the relay and logged
+	msg, err = manager.SendMessage(ctx, id, "bash")
+	if err != nil || msg.Content != "skipped tool-2" {
+		t.Fatalf("expected the denied call to be skipped, got %+v, %v", msg, err)
+	}
+	if !logger.Contains("Tool call denied: session=test-session-id tool=bash") {
+		t.Error("expected the denial to be logged")
+	}
+
+	// Asked: left for the client, which resolves it
+	msg, err = manager.SendMessage(ctx, id, "write_file")
+	if err != nil || pendingToolCall(msg) == nil {
+		t.Fatalf("expected the call to be left for the client, got %+v, %v", msg, err)
+	}
+	if _, err := manager.ResolveToolCall(ctx, id, "tool-2", true, nil); !errors.Is(err, ErrNoToolCall) {
+		t.Errorf("expected ErrNoToolCall for a call the policy denied, got %v", err)
+	}
+	msg, err = manager.ResolveToolCall(WithUser(ctx, "user-1"), id, "tool-3", false, nil)
+	if err != nil || msg.Content != "skipped tool-3" {
+		t.Fatalf("expected the client's denial to be passed on, got %+v, %v", msg, err)
+	}
+	if _, err := manager.ResolveToolCall(ctx, id, "tool-3", true, nil); !errors.Is(err, ErrNoToolCall) {
+		t.Errorf("expected ErrNoToolCall once resolved, got %v", err)
+	}
+
+	want := []acp.ToolCallParams{{ToolCallID: "tool-1", Approved: true}, {ToolCallID: "tool-2"}, {ToolCallID: "tool-3"}}
+	if got := client.Resolved(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected resolutions %v, got %v", want, got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 audit entries, got %+v", entries)
+	}
+	for i, want := range []struct {
+		tool     string
+		decision ToolDecision
+		approved bool
+		user     string
+	}{{"read_file", ToolAllow, true, ""}, {"bash", ToolDeny, false, ""}, {"write_file", ToolAsk, false, "user-1"}} {
+		e := entries[i]
+		if e.Tool != want.tool || e.Decision != want.decision || e.Approved != want.approved || e.UserID != want.user || e.SessionID != id {
+			t.Errorf("entry %d: unexpected %+v", i, e)
+		}
+	}
+}
+
+func TestManager_ToolPolicy_SessionOverridesDefault(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "test-session-id"}, &mockClock{}, &mockCleaner{}, &mockLogger{},
+		WithDefaultToolPolicy(ToolPolicy{Default: ToolDeny}))
+	session, err := manager.Create(ctx, "auth", &mockWebSocket{},
+		WithAgentConfig(AgentConfig{ToolPolicy: &ToolPolicy{Default: ToolAllow}}))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, session.GetID()); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, session.GetID(), "/tmp/worktree", &toolAgentClient{}); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+
+	msg, err := manager.SendMessage(ctx, session.GetID(), "bash")
+	if err != nil || msg.Content != "ran tool-1" {
+		t.Errorf("expected the session's policy to allow the call, got %+v, %v", msg, err)
+	}
+}
+
+func TestManager_ToolPolicy_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "test-session-id"}, &mockClock{}, &mockCleaner{}, &mockLogger{},
+		WithDefaultToolPolicy(ToolPolicy{Default: ToolDeny}))
+	// Replies with a tool call but cannot resolve it
+	client := &replyingACPClient{reply: &acp.AgentMessage{Type: acp.PartTypeToolCall, ToolCall: &acp.ToolCall{ID: "tool-1", Name: "bash"}}}
+	session := setupActiveSession(t, manager, client)
+
+	if _, err := manager.SendMessage(ctx, session.GetID(), "go"); !errors.Is(err, ErrToolApprovalUnsupported) {
+		t.Errorf("expected ErrToolApprovalUnsupported, got %v", err)
+	}
+}
+
+// replyingACPClient answers every prompt with reply
+type replyingACPClient struct {
+	mockACPClient
+	reply *acp.AgentMessage
+}
+
+func (c *replyingACPClient) SendMessage(string) (*acp.AgentMessage, error) { return c.reply, nil }
+
+func TestManager_ToolRunner(t *testing.T) {
+	ctx := context.Background()
+	var ran []string
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "test-session-id"}, &mockClock{}, &mockCleaner{}, &mockLogger{},
+		WithDefaultToolPolicy(ToolPolicy{Allow: []string{"bash", "read_file"}, Deny: []string{"rm"}}),
+		WithToolRunner(func(_ context.Context, workspace string, call *acp.ToolCall) (*acp.ToolResult, bool) {
+			ran = append(ran, call.Name)
+			if call.Name != "bash" {
+				return nil, false
+			}
+			return &acp.ToolResult{Stdout: "ran in " + workspace}, true
+		}))
+	client := &toolAgentClient{}
+	session := setupActiveSession(t, manager, client)
+
+	for _, tool := range []string{"bash", "read_file", "rm"} {
+		if _, err := manager.SendMessage(ctx, session.GetID(), tool); err != nil {
+			t.Fatalf("SendMessage(%s) failed: %v", tool, err)
+		}
+	}
+
+	// Denied calls never reach the runner
+	if fmt.Sprint(ran) != "[bash read_file]" {
+		t.Errorf("expected the approved calls to be offered to the runner, got %v", ran)
+	}
+	resolved := client.Resolved()
+	if len(resolved) != 3 || resolved[0].Result == nil || resolved[0].Result.Stdout != "ran in /tmp/worktree" {
+		t.Fatalf("expected the runner's result to be sent to the agent, got %+v", resolved)
+	}
+	if resolved[1].Result != nil || resolved[2].Result != nil {
+		t.Errorf("expected calls the runner left to run in the agent, got %+v", resolved[1:])
+	}
+}
+
+func TestManager_ToolRunner_AuditsErrors(t *testing.T) {
+	ctx := context.Background()
+	var entries []ToolAuditEntry
+	logger := &mockLogger{}
+	manager := NewManager(NewMemoryStore(), &mockIDGenerator{nextID: "test-session-id"}, &mockClock{}, &mockCleaner{}, logger,
+		WithDefaultToolPolicy(ToolPolicy{Allow: []string{"read_file"}}),
+		WithToolAuditor(func(e ToolAuditEntry) { entries = append(entries, e) }),
+		WithToolRunner(func(context.Context, string, *acp.ToolCall) (*acp.ToolResult, bool) {
+			return &acp.ToolResult{Error: "path is outside the workspace: /etc/passwd", ExitCode: -1}, true
+		}))
+	client := &toolAgentClient{}
+	session := setupActiveSession(t, manager, client)
+
+	if _, err := manager.SendMessage(ctx, session.GetID(), "read_file"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	// The agent gets the error as the call's result
+	resolved := client.Resolved()
+	if len(resolved) != 1 || resolved[0].Result == nil || resolved[0].Result.Error == "" {
+		t.Fatalf("expected the error to be sent to the agent, got %+v", resolved)
+	}
+	if len(entries) != 2 || entries[0].Error != "" || entries[1].Error != "path is outside the workspace: /etc/passwd" || !entries[1].Approved {
+		t.Errorf("expected a decision entry and an error entry, got %+v", entries)
+	}
+	if !logger.Contains("Tool call failed in relay: session=test-session-id tool=read_file") {
+		t.Error("expected the error to be logged")
+	}
+}
@@ -1,34 +1,13 @@
 package relay
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/2389-research/ourocodus/pkg/relay/session"
 )
 
-// SessionClockAdapter adapts relay.Clock to session.Clock
-// Bridges the two packages until they are unified
-//
-// Note: This adapter parses RFC3339 timestamps on every call.
-// The relay package uses string timestamps for JSON serialization in protocol messages,
-// while the session package uses time.Time for internal state management.
-// This design keeps the relay protocol layer decoupled from internal time handling.
-type SessionClockAdapter struct {
-	clock Clock
-}
-
-// Now implements session.Clock interface
-func (a *SessionClockAdapter) Now() time.Time {
-	// relay.Clock returns string, parse it
-	timestamp := a.clock.Now()
-	t, err := time.Parse(time.RFC3339, timestamp)
-	if err != nil {
-		// Fall back to current time if parse fails
-		return time.Now()
-	}
-	return t
-}
-
 // SessionIDGenAdapter adapts relay.IDGenerator to session.IDGenerator
 type SessionIDGenAdapter struct {
 	idGen IDGenerator
@@ -51,11 +30,15 @@ func (a *SessionLoggerAdapter) Printf(format string, v ...interface{}) {
 
 // NewSessionManager creates a session.Manager using relay dependencies
 // Example of how to wire session management into the relay server
-func NewSessionManager(logger Logger, clock Clock, idGen IDGenerator) *session.Manager {
-	store := session.NewMemoryStore()
+// Options (e.g. session.WithMiddleware) are passed through to the manager
+func NewSessionManager(logger Logger, clock Clock, idGen IDGenerator, opts ...session.ManagerOption) *session.Manager {
+	return NewSessionManagerWithStore(session.NewMemoryStore(), logger, clock, idGen, opts...)
+}
 
-	// Adapt relay dependencies to session interfaces
-	sessionClock := &SessionClockAdapter{clock: clock}
+// NewSessionManagerWithStore is NewSessionManager with sessions kept in store
+// (e.g. a session.PostgresStore shared between relays)
+func NewSessionManagerWithStore(store session.Store, logger Logger, clock Clock, idGen IDGenerator, opts ...session.ManagerOption) *session.Manager {
+	// Adapt relay dependencies to session interfaces (Clock is shared as-is)
 	sessionIDGen := &SessionIDGenAdapter{idGen: idGen}
 	sessionLogger := &SessionLoggerAdapter{logger: logger}
 
@@ -63,5 +46,153 @@ func NewSessionManager(logger Logger, clock Clock, idGen IDGenerator) *session.M
 	// Issue #7 will provide real cleanup implementation
 	cleaner := session.NewNoOpCleaner()
 
-	return session.NewManager(store, sessionIDGen, sessionClock, cleaner, sessionLogger)
+	manager := session.NewManager(store, sessionIDGen, clock, cleaner, sessionLogger, opts...)
+	manager.Events().Subscribe(NewAgentStateNotifier(manager, logger))
+	return manager
+}
+
+// NewAgentStateNotifier returns an event handler that pushes an agent:state
+// message to every connection of the session that transitioned, followed by
+// agent:ready with the agent's capabilities when an agent was attached
+// Sessions without an attached WebSocket are skipped silently, as is creation:
+// the creator already has the session from Create
+func NewAgentStateNotifier(manager *session.Manager, logger Logger) session.EventHandler {
+	return func(event session.LifecycleEvent) {
+		if event.Event == session.EventCreate {
+			return
+		}
+		sess := manager.Get(event.SessionID)
+		if sess == nil {
+			return
+		}
+		if err := broadcast(sess, agentStateFromEvent(event)); err != nil {
+			logger.Printf("Failed to send agent state: session=%s err=%v", event.SessionID, err)
+		}
+		if event.Event == session.EventActivate {
+			announceAgent(sess, event.Time, logger)
+		}
+	}
+}
+
+// announceAgent pushes agent:ready for the agent now attached to sess
+func announceAgent(sess *session.Session, at time.Time, logger Logger) {
+	msg := NewAgentReadyMessage(sess.GetID(), sess.GetAgentID(), sess.GetAgentInfo(), FormatTimestamp(at))
+	if err := broadcast(sess, msg); err != nil {
+		logger.Printf("Failed to send agent ready: session=%s err=%v", sess.GetID(), err)
+	}
+}
+
+// broadcast writes v to every connection attached to or observing sess
+// A failed write does not stop the others; the failures are returned joined.
+func broadcast(sess *session.Session, v interface{}) error {
+	var errs []error
+	for _, conn := range sess.GetHandle().Recipients() {
+		if err := conn.WriteJSON(v); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// agentStateFromEvent converts a lifecycle event into its protocol message (pure function)
+// Timestamps are formatted here, at the serialization boundary
+func agentStateFromEvent(event session.LifecycleEvent) AgentStateMessage {
+	var errDetail *ErrorDetail
+	if event.Err != nil {
+		errDetail = &ErrorDetail{
+			Code:        "AGENT_FAILED",
+			Message:     event.Err.Error(),
+			Recoverable: false,
+		}
+	}
+
+	msg := NewAgentStateMessage(
+		event.SessionID,
+		event.AgentID,
+		event.From.String(),
+		event.To.String(),
+		event.Reason,
+		FormatTimestamp(event.Time),
+		errDetail,
+	)
+	msg.Name = event.Name
+	return msg
+}
+
+// breakerAgentStates maps circuit states to the agent states shown to clients
+var breakerAgentStates = map[session.BreakerState]string{
+	session.BreakerClosed:   "ACTIVE",
+	session.BreakerOpen:     "FAILED",
+	session.BreakerHalfOpen: "RECOVERING",
+}
+
+// NewBreakerNotifier returns a circuit breaker handler that pushes an agent:state
+// message (ACTIVE, FAILED, or RECOVERING) to the affected session's connections
+func NewBreakerNotifier(manager *session.Manager, logger Logger) func(session.BreakerEvent) {
+	return func(event session.BreakerEvent) {
+		sess := manager.Get(event.SessionID)
+		if sess == nil {
+			return
+		}
+		if err := broadcast(sess, agentStateFromBreaker(event, sess.GetName())); err != nil {
+			logger.Printf("Failed to send agent state: session=%s err=%v", event.SessionID, err)
+		}
+	}
+}
+
+// agentStateFromBreaker converts a circuit change into its protocol message (pure function)
+// Open circuits are recoverable: the breaker probes the agent after its cooldown
+func agentStateFromBreaker(event session.BreakerEvent, name string) AgentStateMessage {
+	var errDetail *ErrorDetail
+	if event.Err != nil {
+		errDetail = &ErrorDetail{
+			Code:        "AGENT_UNAVAILABLE",
+			Message:     event.Err.Error(),
+			Recoverable: true,
+		}
+	}
+
+	var reason string
+	switch event.To {
+	case session.BreakerOpen:
+		reason = fmt.Sprintf("%d consecutive failures", event.Failures)
+	case session.BreakerHalfOpen:
+		reason = "cooldown elapsed, probing agent"
+	default:
+		reason = "agent recovered"
+	}
+
+	msg := NewAgentStateMessage(
+		event.SessionID,
+		event.AgentID,
+		breakerAgentStates[event.From],
+		breakerAgentStates[event.To],
+		reason,
+		FormatTimestamp(event.Time),
+		errDetail,
+	)
+	msg.Name = name
+	return msg
+}
+
+// NewExpiryNotifier returns a session.ExpiryWarner that pushes a
+// session:expiring message to the session's connections
+// Detached sessions are skipped; they expire unwarned.
+func NewExpiryNotifier(clock Clock, logger Logger) session.ExpiryWarner {
+	return func(sess *session.Session, remaining time.Duration) {
+		if len(sess.GetHandle().Recipients()) == 0 {
+			return
+		}
+		msg := NewSessionExpiringMessage(
+			sess.GetID(),
+			sess.GetAgentID(),
+			sess.GetName(),
+			FormatTimestamp(sess.GetExpiresAt()),
+			int(remaining.Round(time.Second)/time.Second),
+			FormatTimestamp(clock.Now()),
+		)
+		if err := broadcast(sess, msg); err != nil {
+			logger.Printf("Failed to send expiry warning: session=%s err=%v", sess.GetID(), err)
+		}
+	}
 }
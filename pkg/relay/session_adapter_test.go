@@ -0,0 +1,188 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+func TestNewSessionManager_PushesAgentState(t *testing.T) {
+	ctx := context.Background()
+	conn := &mockWebSocketConn{}
+	manager := NewSessionManager(
+		&mockLogger{},
+		&mockClock{now: testTime},
+		&mockIDGenerator{id: "session-1"},
+	)
+
+	sess, err := manager.Create(ctx, "auth", conn)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, sess.GetID()); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+
+	if len(conn.written) != 1 {
+		t.Fatalf("expected 1 agent:state message, got %d", len(conn.written))
+	}
+	msg, ok := conn.written[0].(AgentStateMessage)
+	if !ok {
+		t.Fatalf("expected AgentStateMessage, got %T", conn.written[0])
+	}
+	if msg.Type != "agent:state" || msg.Version != ProtocolVersion {
+		t.Errorf("unexpected envelope: %+v", msg.BaseMessage)
+	}
+	if msg.SessionID != "session-1" || msg.Role != "auth" {
+		t.Errorf("unexpected identity: session=%s role=%s", msg.SessionID, msg.Role)
+	}
+	if msg.From != "CREATED" || msg.To != "SPAWNING" {
+		t.Errorf("expected CREATED → SPAWNING, got %s → %s", msg.From, msg.To)
+	}
+	if msg.Timestamp != "2025-10-23T12:00:00Z" {
+		t.Errorf("expected timestamp from clock, got %s", msg.Timestamp)
+	}
+	if msg.Error != nil {
+		t.Errorf("expected no error detail, got %+v", msg.Error)
+	}
+}
+
+// reportingACPClient reports fixed initialize results
+type reportingACPClient struct {
+	mockStreamingACPClient
+	info *acp.InitializeResult
+}
+
+func (c *reportingACPClient) Info() *acp.InitializeResult { return c.info }
+
+func TestNewSessionManager_PushesAgentReady(t *testing.T) {
+	ctx := context.Background()
+	conn := &mockWebSocketConn{}
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"})
+	if _, err := manager.Create(ctx, "auth", conn); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, "session-1"); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	client := &reportingACPClient{info: &acp.InitializeResult{AgentName: "claude-code-acp", AgentVersion: "0.5.0", ProtocolVersion: 1,
+		Capabilities: acp.AgentCapabilities{Tools: []string{"bash"}, Models: []string{"claude-sonnet"}, Streaming: true}}}
+	if err := manager.AttachAgent(ctx, "session-1", "/tmp/worktree", client); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+
+	if len(conn.written) != 3 {
+		t.Fatalf("expected agent:state twice then agent:ready, got %d messages", len(conn.written))
+	}
+	ready, ok := conn.written[2].(AgentReadyMessage)
+	if !ok {
+		t.Fatalf("expected AgentReadyMessage after activation, got %T", conn.written[2])
+	}
+	if ready.Type != "agent:ready" || ready.SessionID != "session-1" || ready.Role != "auth" || ready.Timestamp != "2025-10-23T12:00:00Z" {
+		t.Errorf("unexpected ready message: %+v", ready)
+	}
+	if ready.AgentName != "claude-code-acp" || ready.ProtocolVersion != 1 || ready.Capabilities == nil ||
+		!ready.Capabilities.Streaming || ready.Capabilities.Tools[0] != "bash" || ready.Capabilities.Models[0] != "claude-sonnet" {
+		t.Errorf("expected the agent's capabilities, got %+v", ready)
+	}
+}
+
+func TestNewAgentReadyMessage_UnknownCapabilities(t *testing.T) {
+	msg := NewAgentReadyMessage("session-1", "auth", nil, "2025-10-23T12:00:00Z")
+	if msg.Capabilities != nil || msg.AgentName != "" {
+		t.Errorf("expected no capabilities for an uninitialized agent, got %+v", msg)
+	}
+}
+
+func TestAgentStateFromEvent_IncludesErrorDetail(t *testing.T) {
+	event := session.LifecycleEvent{
+		Time:      time.Date(2025, 10, 23, 12, 0, 0, 0, time.UTC),
+		Err:       errors.New("spawn failed"),
+		SessionID: "session-1",
+		Name:      "schema work",
+		AgentID:   "db",
+		From:      session.StateSpawning,
+		To:        session.StateTerminating,
+		Event:     session.EventTerminate,
+		Reason:    "spawn failed",
+	}
+
+	msg := agentStateFromEvent(event)
+
+	if msg.Error == nil {
+		t.Fatal("expected error detail")
+	}
+	if msg.Error.Code != "AGENT_FAILED" || msg.Error.Message != "spawn failed" || msg.Error.Recoverable {
+		t.Errorf("unexpected error detail: %+v", msg.Error)
+	}
+	if msg.Name != "schema work" {
+		t.Errorf("expected session name in message, got %q", msg.Name)
+	}
+	if msg.Role != "db" || msg.From != "SPAWNING" || msg.To != "TERMINATING" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+}
+
+func TestNewBreakerNotifier_PushesFailedState(t *testing.T) {
+	ctx := context.Background()
+	conn := &mockWebSocketConn{}
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"})
+	if _, err := manager.Create(ctx, "auth", conn); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	notify := NewBreakerNotifier(manager, &mockLogger{})
+	notify(session.BreakerEvent{
+		Time:      testTime,
+		Err:       errors.New("agent crashed"),
+		SessionID: "session-1",
+		AgentID:   "auth",
+		From:      session.BreakerClosed,
+		To:        session.BreakerOpen,
+		Failures:  3,
+	})
+
+	if len(conn.written) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(conn.written))
+	}
+	msg := conn.written[0].(AgentStateMessage)
+	if msg.From != "ACTIVE" || msg.To != "FAILED" || msg.Reason != "3 consecutive failures" {
+		t.Errorf("unexpected agent state: %+v", msg)
+	}
+	if msg.Error == nil || msg.Error.Code != "AGENT_UNAVAILABLE" || !msg.Error.Recoverable {
+		t.Errorf("expected recoverable AGENT_UNAVAILABLE error, got %+v", msg.Error)
+	}
+}
+
+func TestNewExpiryNotifier_WarnsBeforeTTL(t *testing.T) {
+	ctx := context.Background()
+	conn := &mockWebSocketConn{}
+	clock := &mockClock{now: testTime}
+	manager := NewSessionManager(&mockLogger{}, clock, &mockIDGenerator{id: "session-1"},
+		session.WithDefaultTTL(time.Hour))
+	if _, err := manager.Create(ctx, "auth", conn, session.WithName("nightly")); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	reaper := session.NewReaper(manager, 5*time.Minute, NewExpiryNotifier(clock, &mockLogger{}))
+	clock.now = testTime.Add(57 * time.Minute)
+	reaper.Sweep(ctx)
+
+	if len(conn.written) != 1 {
+		t.Fatalf("expected 1 session:expiring message, got %d", len(conn.written))
+	}
+	msg, ok := conn.written[0].(SessionExpiringMessage)
+	if !ok {
+		t.Fatalf("expected SessionExpiringMessage, got %T", conn.written[0])
+	}
+	if msg.Type != "session:expiring" || msg.SessionID != "session-1" || msg.Role != "auth" || msg.Name != "nightly" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if msg.ExpiresAt != "2025-10-23T13:00:00Z" || msg.RemainingSeconds != 180 {
+		t.Errorf("expected expiry at 13:00 in 180s, got %s in %ds", msg.ExpiresAt, msg.RemainingSeconds)
+	}
+}
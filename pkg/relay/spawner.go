@@ -0,0 +1,589 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/e2e"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// ErrUnknownTemplate is returned when launching a template not defined in config
+var ErrUnknownTemplate = errors.New("unknown session template")
+
+// AgentFactory starts the agent process for a role in a workspace
+type AgentFactory interface {
+	NewAgent(ctx context.Context, role, workspace string) (session.ACPClient, error)
+}
+
+// AgentChecker is optionally implemented by factories that can tell whether
+// an agent could be started without starting it
+type AgentChecker interface {
+	CheckAgent(ctx context.Context, role, workspace string) error
+}
+
+// ACPAgentFactory starts claude-code-acp processes through pkg/acp
+type ACPAgentFactory struct {
+	apiKey    string
+	command   string
+	args      []string // Used when customArgs; "{workspace}" is expanded
+	env       []string
+	container *AgentContainer // Optional; runs the command in a container
+	wire      []acp.ClientOption
+	logger    Logger
+
+	customArgs bool
+}
+
+// AgentFactoryOption configures an ACPAgentFactory
+type AgentFactoryOption func(*ACPAgentFactory)
+
+// WithAgentEnv adds KEY=value variables to every agent process's environment,
+// e.g. AgentProxyConfig.Env
+func WithAgentEnv(vars ...string) AgentFactoryOption {
+	return func(f *ACPAgentFactory) {
+		f.env = append(f.env, vars...)
+	}
+}
+
+// WithAgentArgs sets the arguments of the agent command, replacing the
+// default "--workspace <workspace>"; "{workspace}" in an argument is replaced
+// by the session's workspace
+func WithAgentArgs(args ...string) AgentFactoryOption {
+	return func(f *ACPAgentFactory) {
+		f.args = args
+		f.customArgs = true
+	}
+}
+
+// WithAgentContainer runs the agent command inside a container (see AgentContainer)
+func WithAgentContainer(c AgentContainer) AgentFactoryOption {
+	return func(f *ACPAgentFactory) {
+		f.container = &c
+	}
+}
+
+// WithAgentWireLog records every JSON-RPC frame exchanged with agents,
+// scrubbed by redact (see acp.WithWireLogger)
+func WithAgentWireLog(logger acp.WireLogger, redact func(string) string) AgentFactoryOption {
+	return func(f *ACPAgentFactory) {
+		f.wire = []acp.ClientOption{acp.WithWireLogger(logger, redact)}
+	}
+}
+
+// NewACPAgentFactory creates a factory that spawns ACP clients with apiKey
+// An empty command uses acp.DefaultCommand
+func NewACPAgentFactory(apiKey, command string, logger Logger, opts ...AgentFactoryOption) *ACPAgentFactory {
+	if command == "" {
+		command = acp.DefaultCommand
+	}
+	f := &ACPAgentFactory{apiKey: apiKey, command: command, logger: logger}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// ClientName identifies the relay to agents in the initialize request
+const ClientName = "ourocodus-relay"
+
+// NewAgent spawns an ACP process working in workspace and initializes it,
+// with the agent config ctx carries (see session.ContextWithAgentConfig)
+// Agents that do not implement initialize are still returned, without
+// capabilities, unless a config had to be passed to them.
+func (f *ACPAgentFactory) NewAgent(ctx context.Context, role, workspace string) (session.ACPClient, error) {
+	env := f.env
+	if key := PayloadKeyFromContext(ctx); key != "" {
+		env = append(slices.Clip(env), e2e.EnvKey+"="+key)
+	}
+	opts := []acp.ClientOption{acp.WithLogger(f.logger)}
+	if path, args, ok := f.commandLine(workspace, env); ok {
+		opts = append(opts, acp.WithCommand(path, args...))
+	}
+	if len(env) > 0 {
+		opts = append(opts, acp.WithEnv(env...))
+	}
+	opts = append(opts, f.wire...)
+	client, err := acp.NewClient(workspace, f.apiKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := session.AgentConfigFromContext(ctx)
+	_, err = client.Initialize(ctx, initializeParams(cfg))
+	agentSettings := cfg
+	agentSettings.ToolPolicy = nil // Enforced by the relay, not sent to the agent
+	agentSettings.Kind = ""        // Chose the factory; nothing to send
+	if errors.Is(err, acp.ErrMethodNotFound) && agentSettings.IsZero() {
+		f.logger.Printf("Agent does not support initialize; capabilities unknown: role=%s", role)
+		return client, nil
+	}
+	if err != nil {
+		if cerr := client.Close(); cerr != nil {
+			f.logger.Printf("Failed to close uninitialized agent: role=%s err=%v", role, cerr)
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// initializeParams builds the initialize request for an agent config (pure function)
+func initializeParams(cfg session.AgentConfig) acp.InitializeParams {
+	return acp.InitializeParams{
+		ClientName:      ClientName,
+		ProtocolVersion: acp.ProtocolVersion,
+		SystemPrompt:    cfg.SystemPrompt,
+		Model:           cfg.Model,
+		Temperature:     cfg.Temperature,
+		MaxTokens:       cfg.MaxTokens,
+	}
+}
+
+// CheckAgent reports whether NewAgent could start a process
+func (f *ACPAgentFactory) CheckAgent(ctx context.Context, role, workspace string) error {
+	if f.apiKey == "" {
+		return fmt.Errorf("API key is not configured")
+	}
+	path := f.command
+	if f.container != nil {
+		path = f.container.runtime()
+	}
+	if _, err := exec.LookPath(path); err != nil {
+		return fmt.Errorf("agent command unavailable: %w", err)
+	}
+	return nil
+}
+
+// ErrSpawnRejected is returned when a spawn request fails its pre-spawn checks
+var ErrSpawnRejected = errors.New("spawn rejected")
+
+// SpawnRequest describes one agent to start
+type SpawnRequest struct {
+	Options   []session.CreateOption
+	Role      string
+	Workspace string // Absolute path to an existing directory
+	SeedFrom  string // Optional; a missing Workspace is seeded from this directory
+	OwnerID   string // Optional; counted against the per-owner quota
+	Kind      string // Optional; the agent kind of the agent config in Options, checked by the factory
+}
+
+// Pre-spawn check names reported in a SpawnPlan
+const (
+	CheckRole      = "role"
+	CheckWorkspace = "workspace"
+	CheckQuota     = "quota"
+	CheckResources = "resources"
+	CheckFactory   = "factory"
+)
+
+// SpawnCheck is the outcome of one pre-spawn validation
+type SpawnCheck struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+	OK    bool   `json:"ok"`
+}
+
+// SpawnPlan reports what SpawnAgent would do with a request
+// OK is true if every check passed and the spawn would be attempted
+type SpawnPlan struct {
+	Role      string
+	Workspace string
+	Checks    []SpawnCheck
+	OK        bool
+
+	pressure error // Set if the resources check failed
+}
+
+// Err summarizes failed checks as an ErrSpawnRejected error (nil if OK)
+// A failed resources check also makes it match ErrResourcePressure.
+func (p SpawnPlan) Err() error {
+	if p.OK {
+		return nil
+	}
+	var failed []string
+	for _, c := range p.Checks {
+		if !c.OK {
+			failed = append(failed, c.Name+": "+c.Error)
+		}
+	}
+	err := fmt.Errorf("%w: %s", ErrSpawnRejected, strings.Join(failed, "; "))
+	if p.pressure != nil {
+		return &causedError{err, p.pressure}
+	}
+	return err
+}
+
+// causedError is an error that also matches the cause it was built from
+type causedError struct {
+	error
+	cause error
+}
+
+func (e *causedError) Unwrap() []error { return []error{e.error, e.cause} }
+
+// Launch statuses reported per agent in a TemplateResult
+const (
+	LaunchSpawned    = "spawned"
+	LaunchFailed     = "failed"
+	LaunchRolledBack = "rolled_back" // Spawned, then torn down because another agent failed
+	LaunchSkipped    = "skipped"     // Not attempted because an earlier agent failed
+)
+
+// AgentLaunchResult reports what happened to one agent of a template
+type AgentLaunchResult struct {
+	Role        string `json:"role"`
+	SessionID   string `json:"sessionId,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	PromptError string `json:"promptError,omitempty"` // Initial prompt failed; the agent stays up
+}
+
+// TemplateResult reports the outcome of launching a template
+// OK is true only if every agent spawned
+type TemplateResult struct {
+	Template string
+	Agents   []AgentLaunchResult
+	OK       bool
+}
+
+// Spawner creates sessions and starts their agents
+type Spawner struct {
+	manager   *session.Manager
+	factory   AgentFactory
+	logger    Logger
+	seeder    WorkspaceSeeder
+	templates map[string]TemplateConfig
+	quota     QuotaConfig
+	pressure  *PressureMonitor // Optional (nil = spawns never refused for pressure)
+}
+
+// SpawnerOption configures optional Spawner behavior
+type SpawnerOption func(*Spawner)
+
+// WithSpawnQuota limits how many live sessions may exist (zero limits are unlimited)
+func WithSpawnQuota(quota QuotaConfig) SpawnerOption {
+	return func(s *Spawner) {
+		s.quota = quota
+	}
+}
+
+// WithSpawnPressure refuses spawns while the monitor reports resource pressure
+func WithSpawnPressure(monitor *PressureMonitor) SpawnerOption {
+	return func(s *Spawner) {
+		s.pressure = monitor
+	}
+}
+
+// WithWorkspaceSeeder lets spawn requests with SeedFrom create their workspace
+func WithWorkspaceSeeder(seeder WorkspaceSeeder) SpawnerOption {
+	return func(s *Spawner) {
+		s.seeder = seeder
+	}
+}
+
+// NewSpawner creates a spawner for the configured templates
+func NewSpawner(manager *session.Manager, factory AgentFactory, templates map[string]TemplateConfig, logger Logger, opts ...SpawnerOption) *Spawner {
+	s := &Spawner{
+		manager:   manager,
+		factory:   factory,
+		logger:    logger,
+		templates: templates,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Plan validates a spawn request without spawning anything
+// Checks the role is free, the workspace is an existing directory (or can be
+// seeded), quotas allow another session, the relay is not short of disk or
+// file descriptors, and the factory can start an agent (if it
+// implements AgentChecker). SpawnAgent runs the same checks first, so a plan
+// that passes predicts SpawnAgent barring races with other spawns.
+func (s *Spawner) Plan(ctx context.Context, req SpawnRequest) SpawnPlan {
+	plan := SpawnPlan{Role: req.Role, Workspace: req.Workspace, OK: true}
+	add := func(name string, err error) {
+		check := SpawnCheck{Name: name, OK: err == nil}
+		if err != nil {
+			check.Error = err.Error()
+			plan.OK = false
+		}
+		plan.Checks = append(plan.Checks, check)
+	}
+
+	add(CheckRole, s.checkRole(req.Role))
+	add(CheckWorkspace, s.checkSpawnWorkspace(req))
+	add(CheckQuota, s.checkQuota(req.OwnerID))
+	plan.pressure = s.checkPressure()
+	add(CheckResources, plan.pressure)
+
+	var factoryErr error
+	if checker, ok := s.factory.(AgentChecker); ok {
+		factoryErr = checker.CheckAgent(session.ContextWithAgentConfig(ctx, session.AgentConfig{Kind: req.Kind}), req.Role, req.Workspace)
+	}
+	add(CheckFactory, factoryErr)
+
+	return plan
+}
+
+// checkRole rejects empty roles and roles that already have a session
+func (s *Spawner) checkRole(role string) error {
+	if role == "" {
+		return fmt.Errorf("role is required")
+	}
+	if existing := s.manager.GetByRole(role); existing != nil {
+		return fmt.Errorf("role %s already has session %s", role, existing.GetID())
+	}
+	return nil
+}
+
+// checkWorkspace requires an absolute path to an existing directory
+func checkWorkspace(path string) error {
+	if path == "" {
+		return fmt.Errorf("workspace is required")
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("workspace must be an absolute path: %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("workspace unavailable: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("workspace is not a directory: %s", path)
+	}
+	return nil
+}
+
+// checkSpawnWorkspace checks the workspace, or if it doesn't exist yet and
+// SeedFrom is set, that it can be seeded
+func (s *Spawner) checkSpawnWorkspace(req SpawnRequest) error {
+	if req.SeedFrom == "" || pathExists(req.Workspace) {
+		return checkWorkspace(req.Workspace)
+	}
+	if s.seeder == nil {
+		return fmt.Errorf("workspace seeding is not configured")
+	}
+	if err := checkWorkspace(req.SeedFrom); err != nil {
+		return fmt.Errorf("seedFrom: %w", err)
+	}
+	if !filepath.IsAbs(req.Workspace) {
+		return fmt.Errorf("workspace must be an absolute path: %s", req.Workspace)
+	}
+	if err := checkWorkspace(filepath.Dir(req.Workspace)); err != nil {
+		return fmt.Errorf("workspace parent: %w", err)
+	}
+	return nil
+}
+
+// pathExists reports whether anything exists at path
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// checkQuota verifies another session fits the global and per-owner limits
+func (s *Spawner) checkQuota(ownerID string) error {
+	if s.quota.MaxSessions > 0 && s.manager.Count() >= s.quota.MaxSessions {
+		return fmt.Errorf("session limit reached (%d)", s.quota.MaxSessions)
+	}
+	if s.quota.MaxSessionsPerOwner > 0 && ownerID != "" {
+		owned := 0
+		for _, sess := range s.manager.List(&session.SessionFilter{OwnerID: &ownerID}) {
+			if sess.GetState() != session.StateCleaned { // Retained after cleanup
+				owned++
+			}
+		}
+		if owned >= s.quota.MaxSessionsPerOwner {
+			return fmt.Errorf("session limit for owner %s reached (%d)", ownerID, s.quota.MaxSessionsPerOwner)
+		}
+	}
+	return nil
+}
+
+// checkPressure returns ErrResourcePressure while the relay is short of disk or FDs
+func (s *Spawner) checkPressure() error {
+	if s.pressure == nil {
+		return nil
+	}
+	return s.pressure.Err()
+}
+
+// SpawnAgent creates a session for the role and starts its agent
+// Requests failing Plan are rejected with ErrSpawnRejected before anything is
+// created. On later failure the session is torn down, so no half-spawned
+// sessions remain; a workspace seeded for it is removed too.
+func (s *Spawner) SpawnAgent(ctx context.Context, ws session.WebSocketConn, req SpawnRequest) (*session.Session, error) {
+	if err := s.Plan(ctx, req).Err(); err != nil {
+		return nil, err
+	}
+	seed := req.SeedFrom != "" && !pathExists(req.Workspace)
+
+	opts := req.Options
+	if req.OwnerID != "" {
+		opts = append([]session.CreateOption{session.WithOwner(req.OwnerID)}, opts...)
+	}
+	sess, err := s.manager.Create(ctx, req.Role, ws, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if seed {
+		if err := s.seeder.Seed(ctx, req.SeedFrom, req.Workspace); err != nil {
+			s.teardown(ctx, sess, err)
+			return nil, err
+		}
+	}
+	if err := s.startAgent(ctx, sess, req.Workspace, seed); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Import recreates a session exported from another relay (see
+// session.Manager.Export) and starts a fresh agent in its restored workspace
+// workspace must not exist yet. A nil ws leaves the session detached until a
+// client claims it with session:reattach.
+func (s *Spawner) Import(ctx context.Context, ws session.WebSocketConn, archive io.Reader, workspace string) (*session.Session, error) {
+	if err := s.checkQuota(""); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrSpawnRejected, CheckQuota, err)
+	}
+	if err := s.checkPressure(); err != nil {
+		return nil, &causedError{fmt.Errorf("%w: %s: %v", ErrSpawnRejected, CheckResources, err), err}
+	}
+	sess, err := s.manager.Import(ctx, archive, ws, workspace)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.startAgent(ctx, sess, workspace, true); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// startAgent moves a created session to SPAWNING and attaches a new agent
+// working in workspace
+// On failure the session is torn down, and with ownsWorkspace the workspace
+// (created for this session) is removed.
+func (s *Spawner) startAgent(ctx context.Context, sess *session.Session, workspace string, ownsWorkspace bool) error {
+	fail := func(err error) error {
+		s.teardown(ctx, sess, err)
+		if ownsWorkspace {
+			if rerr := os.RemoveAll(workspace); rerr != nil {
+				s.logger.Printf("Failed to remove workspace: path=%s err=%v", workspace, rerr)
+			}
+		}
+		return err
+	}
+
+	if sess.GetAgentConfig().EncryptedPayloads && PayloadKeyFromContext(ctx) == "" {
+		return fail(fmt.Errorf("%w: %s", session.ErrPayloadKeyUnavailable, sess.GetID()))
+	}
+	if err := s.manager.BeginSpawn(ctx, sess.GetID()); err != nil {
+		return fail(err)
+	}
+	client, err := s.factory.NewAgent(session.ContextWithAgentConfig(ctx, sess.GetAgentConfig()), sess.AgentID, workspace)
+	if err != nil {
+		return fail(fmt.Errorf("failed to start agent %s: %w", sess.AgentID, err))
+	}
+	if err := s.manager.AttachAgent(ctx, sess.GetID(), workspace, client); err != nil {
+		if cerr := client.Close(); cerr != nil {
+			s.logger.Printf("Failed to close agent: role=%s err=%v", sess.AgentID, cerr)
+		}
+		return fail(err)
+	}
+	return nil
+}
+
+// LaunchTemplate spawns every agent of a template, all or nothing
+// If any agent fails, those already spawned are torn down and the rest are
+// skipped. Once all are up, initial prompts are sent in template order; a
+// failed prompt is reported but does not roll back the launch. The sessions
+// are owned by the user ctx is attributed to (session.WithUser), if any.
+func (s *Spawner) LaunchTemplate(ctx context.Context, ws session.WebSocketConn, name string) (TemplateResult, error) {
+	tmpl, ok := s.templates[name]
+	if !ok {
+		return TemplateResult{Template: name}, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	result := TemplateResult{Template: name, Agents: make([]AgentLaunchResult, len(tmpl.Agents))}
+	spawned := make([]*session.Session, 0, len(tmpl.Agents))
+	var spawnErr error
+	for i, agent := range tmpl.Agents {
+		result.Agents[i] = AgentLaunchResult{Role: agent.Role, Status: LaunchSkipped}
+		if spawnErr != nil {
+			continue
+		}
+
+		opts := []session.CreateOption{session.WithLabels(map[string]string{"template": name})}
+		if agent.Kind != "" {
+			opts = append(opts, session.WithAgentConfig(session.AgentConfig{Kind: agent.Kind}))
+		}
+		sess, err := s.SpawnAgent(ctx, ws, SpawnRequest{
+			Role:      agent.Role,
+			Workspace: agent.Workspace,
+			SeedFrom:  agent.SeedFrom,
+			OwnerID:   session.UserFromContext(ctx),
+			Kind:      agent.Kind,
+			Options:   opts,
+		})
+		if err != nil {
+			spawnErr = fmt.Errorf("template %s: %w", name, err)
+			result.Agents[i].Status = LaunchFailed
+			result.Agents[i].Error = err.Error()
+			continue
+		}
+		result.Agents[i].Status = LaunchSpawned
+		result.Agents[i].SessionID = sess.GetID()
+		spawned = append(spawned, sess)
+	}
+
+	if spawnErr != nil {
+		for i, sess := range spawned {
+			s.closeAgent(sess)
+			s.teardown(ctx, sess, spawnErr)
+			result.Agents[i].Status = LaunchRolledBack
+		}
+		return result, spawnErr
+	}
+
+	result.OK = true
+	for i, agent := range tmpl.Agents {
+		if agent.InitialPrompt == "" {
+			continue
+		}
+		if _, err := s.manager.SendMessage(ctx, spawned[i].GetID(), agent.InitialPrompt); err != nil {
+			result.Agents[i].PromptError = err.Error()
+		}
+	}
+	return result, nil
+}
+
+// closeAgent stops the agent process of a spawned session
+func (s *Spawner) closeAgent(sess *session.Session) {
+	handle := sess.GetHandle()
+	if handle == nil || handle.ACPClient == nil {
+		return
+	}
+	if err := handle.ACPClient.Close(); err != nil {
+		s.logger.Printf("Failed to close agent: session=%s err=%v", sess.GetID(), err)
+	}
+}
+
+// teardown fails and cleans up a session so its role can be spawned again
+func (s *Spawner) teardown(ctx context.Context, sess *session.Session, cause error) {
+	if err := s.manager.MarkFailed(ctx, sess.GetID(), cause); err != nil {
+		s.logger.Printf("Failed to mark session failed: session=%s err=%v", sess.GetID(), err)
+	}
+	if err := s.manager.CompleteCleanup(ctx, sess.GetID()); err != nil {
+		s.logger.Printf("Failed to clean up session: session=%s err=%v", sess.GetID(), err)
+	}
+}
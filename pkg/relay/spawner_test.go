@@ -0,0 +1,391 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// sequentialIDGenerator returns session-1, session-2, ...
+type sequentialIDGenerator struct {
+	n int
+}
+
+func (g *sequentialIDGenerator) Generate() string {
+	g.n++
+	return fmt.Sprintf("session-%d", g.n)
+}
+
+type mockClosableACPClient struct {
+	mockStreamingACPClient
+	closed bool
+}
+
+func (m *mockClosableACPClient) Close() error {
+	m.closed = true
+	return nil
+}
+
+// mockAgentFactory hands out mock clients, failing for listed roles
+type mockAgentFactory struct {
+	failRoles map[string]bool
+	clients   map[string]*mockClosableACPClient
+	configs   map[string]session.AgentConfig // Agent config each role was started with
+	checkErr  error
+}
+
+func (f *mockAgentFactory) CheckAgent(ctx context.Context, role, workspace string) error {
+	return f.checkErr
+}
+
+func (f *mockAgentFactory) NewAgent(ctx context.Context, role, workspace string) (session.ACPClient, error) {
+	if f.failRoles[role] {
+		return nil, errors.New("executable not found")
+	}
+	if f.clients == nil {
+		f.clients = make(map[string]*mockClosableACPClient)
+	}
+	if f.configs == nil {
+		f.configs = make(map[string]session.AgentConfig)
+	}
+	client := &mockClosableACPClient{}
+	f.clients[role] = client
+	f.configs[role] = session.AgentConfigFromContext(ctx)
+	return client, nil
+}
+
+func testTemplates(workspace string) map[string]TemplateConfig {
+	return map[string]TemplateConfig{
+		"fullstack": {Agents: []TemplateAgent{
+			{Role: "db", Workspace: workspace, InitialPrompt: "design the schema"},
+			{Role: "api", Workspace: workspace},
+			{Role: "ui", Workspace: workspace},
+		}},
+	}
+}
+
+func newTestSpawner(t *testing.T, factory AgentFactory, opts ...SpawnerOption) (*Spawner, *session.Manager) {
+	t.Helper()
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &sequentialIDGenerator{},
+		session.WithHistory(session.NewMemoryHistory(10)))
+	return NewSpawner(manager, factory, testTemplates(t.TempDir()), &mockLogger{}, opts...), manager
+}
+
+func TestSpawner_LaunchTemplate(t *testing.T) {
+	spawner, manager := newTestSpawner(t, &mockAgentFactory{})
+
+	result, err := spawner.LaunchTemplate(context.Background(), &mockWebSocketConn{}, "fullstack")
+	if err != nil {
+		t.Fatalf("LaunchTemplate failed: %v", err)
+	}
+	if !result.OK || len(result.Agents) != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	for i, agent := range result.Agents {
+		if agent.Status != LaunchSpawned || agent.SessionID != fmt.Sprintf("session-%d", i+1) {
+			t.Errorf("agent %d: unexpected result %+v", i, agent)
+		}
+		sess := manager.Get(agent.SessionID)
+		if sess == nil || sess.GetState() != session.StateActive || sess.GetLabels()["template"] != "fullstack" {
+			t.Errorf("agent %d: expected ACTIVE session labelled with template", i)
+		}
+	}
+
+	// Initial prompt went to the db agent only
+	matches, _ := manager.SearchHistory(session.HistoryQuery{Text: "schema", Speaker: session.SpeakerUser})
+	if len(matches) != 1 || matches[0].Entry.AgentID != "db" {
+		t.Errorf("expected initial prompt sent to db, got %+v", matches)
+	}
+}
+
+func TestSpawner_LaunchTemplate_RollsBackOnFailure(t *testing.T) {
+	factory := &mockAgentFactory{failRoles: map[string]bool{"api": true}}
+	spawner, manager := newTestSpawner(t, factory)
+
+	result, err := spawner.LaunchTemplate(context.Background(), &mockWebSocketConn{}, "fullstack")
+	if err == nil {
+		t.Fatal("expected launch error")
+	}
+	if result.OK {
+		t.Error("expected OK=false")
+	}
+
+	want := []string{LaunchRolledBack, LaunchFailed, LaunchSkipped}
+	for i, agent := range result.Agents {
+		if agent.Status != want[i] {
+			t.Errorf("agent %s: expected %s, got %s", agent.Role, want[i], agent.Status)
+		}
+	}
+	if result.Agents[1].Error == "" {
+		t.Error("expected failure reason for api")
+	}
+
+	if manager.Count() != 0 {
+		t.Errorf("expected all sessions cleaned up, %d remain", manager.Count())
+	}
+	if !factory.clients["db"].closed {
+		t.Error("expected rolled-back db agent to be closed")
+	}
+
+	// The roles are free again
+	factory.failRoles = nil
+	if _, err := spawner.LaunchTemplate(context.Background(), &mockWebSocketConn{}, "fullstack"); err != nil {
+		t.Errorf("expected relaunch to succeed, got %v", err)
+	}
+}
+
+func TestSpawner_LaunchTemplate_Unknown(t *testing.T) {
+	spawner, _ := newTestSpawner(t, &mockAgentFactory{})
+
+	if _, err := spawner.LaunchTemplate(context.Background(), &mockWebSocketConn{}, "missing"); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("expected ErrUnknownTemplate, got %v", err)
+	}
+}
+
+func TestServer_HandleMessage_CreateFromTemplate(t *testing.T) {
+	spawner, _ := newTestSpawner(t, &mockAgentFactory{})
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, spawner: spawner}
+	conn := &mockWebSocketConn{}
+
+	raw := []byte(`{"version":"1.0","type":"session:create_from_template","template":"fullstack"}`)
+	if shouldClose := server.handleMessage(conn, raw); shouldClose {
+		t.Fatal("expected connection to stay open")
+	}
+
+	// agent:state notifications precede the final report
+	last := conn.written[len(conn.written)-1]
+	msg, ok := last.(SessionTemplateResultMessage)
+	if !ok {
+		t.Fatalf("expected SessionTemplateResultMessage, got %T", last)
+	}
+	if msg.Type != "session:template_result" || !msg.OK || len(msg.Agents) != 3 {
+		t.Errorf("unexpected template result: %+v", msg)
+	}
+}
+
+func TestSpawner_Plan(t *testing.T) {
+	ctx := context.Background()
+	workspace := t.TempDir()
+	file := workspace + "/README"
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		factory *mockAgentFactory
+		quota   QuotaConfig
+		req     SpawnRequest
+		failing string // Name of the single failing check ("" = all pass)
+	}{
+		{"valid", &mockAgentFactory{}, QuotaConfig{}, SpawnRequest{Role: "auth", Workspace: workspace}, ""},
+		{"missing role", &mockAgentFactory{}, QuotaConfig{}, SpawnRequest{Workspace: workspace}, CheckRole},
+		{"role in use", &mockAgentFactory{}, QuotaConfig{}, SpawnRequest{Role: "taken", Workspace: workspace}, CheckRole},
+		{"relative workspace", &mockAgentFactory{}, QuotaConfig{}, SpawnRequest{Role: "auth", Workspace: "work"}, CheckWorkspace},
+		{"missing workspace", &mockAgentFactory{}, QuotaConfig{}, SpawnRequest{Role: "auth", Workspace: workspace + "/nope"}, CheckWorkspace},
+		{"workspace is a file", &mockAgentFactory{}, QuotaConfig{}, SpawnRequest{Role: "auth", Workspace: file}, CheckWorkspace},
+		{"global quota", &mockAgentFactory{}, QuotaConfig{MaxSessions: 1}, SpawnRequest{Role: "auth", Workspace: workspace}, CheckQuota},
+		{"owner quota", &mockAgentFactory{}, QuotaConfig{MaxSessionsPerOwner: 1}, SpawnRequest{Role: "auth", Workspace: workspace, OwnerID: "alice"}, CheckQuota},
+		{"factory unavailable", &mockAgentFactory{checkErr: errors.New("no API key")}, QuotaConfig{}, SpawnRequest{Role: "auth", Workspace: workspace}, CheckFactory},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spawner, manager := newTestSpawner(t, tt.factory, WithSpawnQuota(tt.quota))
+			if _, err := manager.Create(ctx, "taken", &mockWebSocketConn{}, session.WithOwner("alice")); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			plan := spawner.Plan(ctx, tt.req)
+
+			if plan.OK != (tt.failing == "") {
+				t.Errorf("expected OK=%v, got plan %+v", tt.failing == "", plan)
+			}
+			if len(plan.Checks) != 5 {
+				t.Fatalf("expected 5 checks, got %+v", plan.Checks)
+			}
+			for _, c := range plan.Checks {
+				if c.OK == (c.Name == tt.failing) {
+					t.Errorf("check %s: unexpected result %+v", c.Name, c)
+				}
+			}
+			if manager.Count() != 1 {
+				t.Errorf("expected dry run to create nothing, have %d sessions", manager.Count())
+			}
+			if tt.failing != "" && !errors.Is(plan.Err(), ErrSpawnRejected) {
+				t.Errorf("expected ErrSpawnRejected, got %v", plan.Err())
+			}
+		})
+	}
+}
+
+func TestSpawner_SpawnAgent_RejectedByPlan(t *testing.T) {
+	factory := &mockAgentFactory{}
+	spawner, manager := newTestSpawner(t, factory)
+
+	_, err := spawner.SpawnAgent(context.Background(), &mockWebSocketConn{}, SpawnRequest{Role: "auth", Workspace: "relative"})
+	if !errors.Is(err, ErrSpawnRejected) {
+		t.Fatalf("expected ErrSpawnRejected, got %v", err)
+	}
+	if manager.Count() != 0 || len(factory.clients) != 0 {
+		t.Error("expected nothing to be created for a rejected spawn")
+	}
+}
+
+func TestServer_HandleMessage_AgentSpawnDryRun(t *testing.T) {
+	spawner, manager := newTestSpawner(t, &mockAgentFactory{})
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, spawner: spawner}
+	conn := &mockWebSocketConn{}
+
+	raw := []byte(`{"version":"1.0","type":"agent:spawn","role":"auth","workspace":"relative","dryRun":true}`)
+	if shouldClose := server.handleMessage(conn, raw); shouldClose {
+		t.Fatal("expected connection to stay open")
+	}
+
+	if len(conn.written) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(conn.written))
+	}
+	plan, ok := conn.written[0].(AgentSpawnPlanMessage)
+	if !ok {
+		t.Fatalf("expected AgentSpawnPlanMessage, got %T", conn.written[0])
+	}
+	if plan.Type != "agent:spawn_plan" || plan.OK || plan.Role != "auth" {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+	if manager.Count() != 0 {
+		t.Errorf("expected dry run to create nothing, have %d sessions", manager.Count())
+	}
+}
+
+func TestServer_HandleMessage_AgentSpawn(t *testing.T) {
+	spawner, _ := newTestSpawner(t, &mockAgentFactory{})
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, spawner: spawner}
+	conn := &mockWebSocketConn{}
+
+	raw, _ := json.Marshal(map[string]interface{}{
+		"version": "1.0", "type": "agent:spawn", "role": "auth", "workspace": t.TempDir(), "name": "login work",
+	})
+	server.handleMessage(conn, raw)
+
+	last := conn.written[len(conn.written)-1]
+	spawned, ok := last.(AgentSpawnedMessage)
+	if !ok {
+		t.Fatalf("expected AgentSpawnedMessage, got %T: %+v", last, last)
+	}
+	if spawned.SessionID != "session-1" || spawned.Role != "auth" || spawned.Name != "login work" {
+		t.Errorf("unexpected spawned message: %+v", spawned)
+	}
+}
+
+func TestServer_HandleMessage_AgentSpawnWithAgentConfig(t *testing.T) {
+	factory := &mockAgentFactory{}
+	spawner, manager := newTestSpawner(t, factory)
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, spawner: spawner}
+	conn := &mockWebSocketConn{}
+
+	raw, _ := json.Marshal(map[string]interface{}{
+		"version": "1.0", "type": "agent:spawn", "role": "auth", "workspace": t.TempDir(),
+		"systemPrompt": "Only touch auth code.", "model": "claude-sonnet", "temperature": 0.2, "maxTokens": 2048,
+	})
+	server.handleMessage(conn, raw)
+
+	if _, ok := conn.written[len(conn.written)-1].(AgentSpawnedMessage); !ok {
+		t.Fatalf("expected AgentSpawnedMessage, got %+v", conn.written[len(conn.written)-1])
+	}
+	started := factory.configs["auth"]
+	if started.SystemPrompt != "Only touch auth code." || started.Model != "claude-sonnet" ||
+		started.Temperature == nil || *started.Temperature != 0.2 || started.MaxTokens != 2048 {
+		t.Errorf("expected the agent started with the spawn's config, got %+v", started)
+	}
+	view := newSessionView(manager.Get("session-1"))
+	if view.Agent == nil || view.Agent.Model != "claude-sonnet" {
+		t.Errorf("expected the config in the admin view, got %+v", view.Agent)
+	}
+
+	conn.written = nil
+	server.handleMessage(conn, []byte(`{"version":"1.0","type":"agent:spawn","role":"db","workspace":"/tmp","temperature":3}`))
+	if msg, ok := conn.written[0].(ErrorMessage); !ok || msg.Error.Code != "INVALID_MESSAGE" {
+		t.Errorf("expected INVALID_MESSAGE for an out-of-range temperature, got %+v", conn.written[0])
+	}
+}
+
+func TestInitializeParams(t *testing.T) {
+	temperature := 0.7
+	params := initializeParams(session.AgentConfig{SystemPrompt: "Be terse.", Model: "claude-opus", Temperature: &temperature, MaxTokens: 100})
+	if params.ClientName != ClientName || params.ProtocolVersion != acp.ProtocolVersion ||
+		params.SystemPrompt != "Be terse." || params.Model != "claude-opus" || *params.Temperature != 0.7 || params.MaxTokens != 100 {
+		t.Errorf("unexpected initialize params: %+v", params)
+	}
+}
+
+func TestSpawner_SpawnAgent_SeedsWorkspace(t *testing.T) {
+	base := writeBase(t)
+	spawner, _ := newTestSpawner(t, &mockAgentFactory{},
+		WithWorkspaceSeeder(NewWorkspaceCache(t.TempDir(), SeedCopy, &mockLogger{})))
+	workspace := filepath.Join(t.TempDir(), "agent")
+	req := SpawnRequest{Role: "api", Workspace: workspace, SeedFrom: base}
+
+	if plan := spawner.Plan(context.Background(), req); !plan.OK {
+		t.Fatalf("expected plan to pass, got %+v", plan.Checks)
+	}
+	sess, err := spawner.SpawnAgent(context.Background(), &mockWebSocketConn{}, req)
+	if err != nil {
+		t.Fatalf("SpawnAgent failed: %v", err)
+	}
+	if sess.GetWorktreeDir() != workspace {
+		t.Errorf("expected worktree %s, got %s", workspace, sess.GetWorktreeDir())
+	}
+	if got := readFile(t, filepath.Join(workspace, "README.md")); got != "readme" {
+		t.Errorf("expected seeded workspace, got %q", got)
+	}
+}
+
+func TestSpawner_SpawnAgent_RemovesSeededWorkspaceOnFailure(t *testing.T) {
+	spawner, manager := newTestSpawner(t, &mockAgentFactory{failRoles: map[string]bool{"api": true}},
+		WithWorkspaceSeeder(NewWorkspaceCache(t.TempDir(), SeedCopy, &mockLogger{})))
+	workspace := filepath.Join(t.TempDir(), "agent")
+
+	_, err := spawner.SpawnAgent(context.Background(), &mockWebSocketConn{},
+		SpawnRequest{Role: "api", Workspace: workspace, SeedFrom: writeBase(t)})
+	if err == nil {
+		t.Fatal("expected spawn to fail")
+	}
+	if pathExists(workspace) {
+		t.Error("expected seeded workspace to be removed")
+	}
+	if manager.Count() != 0 {
+		t.Errorf("expected no sessions left, got %d", manager.Count())
+	}
+}
+
+func TestSpawner_Plan_SeedFrom(t *testing.T) {
+	base := writeBase(t)
+	missing := filepath.Join(t.TempDir(), "agent")
+
+	unseeded, _ := newTestSpawner(t, &mockAgentFactory{})
+	plan := unseeded.Plan(context.Background(), SpawnRequest{Role: "api", Workspace: missing, SeedFrom: base})
+	if plan.OK || plan.Checks[1].Error != "workspace seeding is not configured" {
+		t.Errorf("expected seeding to be rejected without a seeder, got %+v", plan.Checks[1])
+	}
+
+	seeded, _ := newTestSpawner(t, &mockAgentFactory{},
+		WithWorkspaceSeeder(NewWorkspaceCache(t.TempDir(), SeedCopy, &mockLogger{})))
+	plan = seeded.Plan(context.Background(), SpawnRequest{Role: "api", Workspace: missing, SeedFrom: "/no/such/base"})
+	if plan.OK {
+		t.Error("expected missing seed directory to be rejected")
+	}
+
+	// An existing workspace is used as is
+	existing := t.TempDir()
+	plan = unseeded.Plan(context.Background(), SpawnRequest{Role: "api", Workspace: existing, SeedFrom: base})
+	if !plan.OK {
+		t.Errorf("expected existing workspace to pass, got %+v", plan.Checks)
+	}
+}
@@ -0,0 +1,370 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+var (
+	// ErrUnknownRequest is returned when cancelling a reply that is not in flight
+	ErrUnknownRequest = errors.New("no in-flight agent request")
+
+	// ErrDuplicateRequest is returned when a correlation ID is already in flight
+	ErrDuplicateRequest = errors.New("correlation ID already in flight")
+)
+
+// streamKey identifies an in-flight reply
+type streamKey struct {
+	sessionID     string
+	correlationID string
+}
+
+// AgentStreamer forwards agent replies to session WebSockets and tracks them
+// by correlation ID so clients can cancel them with agent:cancel
+type AgentStreamer struct {
+	manager   *session.Manager
+	forwarder Forwarder
+	clock     Clock
+	logger    Logger
+	inFlight  map[streamKey]context.CancelFunc
+	mu        sync.Mutex
+	highWater int // Outbox size at which reading from the agent pauses
+	lowWater  int // Outbox size at which it resumes
+}
+
+// Forwarder sends prompts for sessions whose agent runs on another relay
+// (see Federation)
+type Forwarder interface {
+	Forward(ctx context.Context, sessionID, content string) (*acp.AgentMessage, error)
+}
+
+// StreamerOption configures an AgentStreamer
+type StreamerOption func(*AgentStreamer)
+
+// WithForwarder forwards prompts for sessions without a local agent, instead
+// of failing them with session.ErrNoAgent
+func WithForwarder(forwarder Forwarder) StreamerOption {
+	return func(s *AgentStreamer) { s.forwarder = forwarder }
+}
+
+// WithFlowControl sets the outbox water marks: reading a reply from the agent
+// pauses once highWater deltas are waiting for a slow client and resumes when
+// lowWater are left (DefaultOutboxHighWater and DefaultOutboxLowWater by default)
+func WithFlowControl(highWater, lowWater int) StreamerOption {
+	return func(s *AgentStreamer) {
+		s.highWater = highWater
+		s.lowWater = lowWater
+	}
+}
+
+// NewAgentStreamer creates a streamer for sessions owned by manager
+func NewAgentStreamer(manager *session.Manager, clock Clock, logger Logger, opts ...StreamerOption) *AgentStreamer {
+	s := &AgentStreamer{
+		manager:   manager,
+		clock:     clock,
+		logger:    logger,
+		inFlight:  make(map[streamKey]context.CancelFunc),
+		highWater: DefaultOutboxHighWater,
+		lowWater:  DefaultOutboxLowWater,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Stream sends a prompt to a session's agent and forwards the reply to the
+// session's connections as agent:delta chunks followed by agent:complete
+// Every message carries correlationID, which the client chose for the request.
+// Sequence numbers are assigned here rather than taken from the agent, so they
+// stay gap-free even if middleware retries the request. agent:complete is sent
+// on failure too, carrying the error, so clients always see the reply end; a
+// reply stopped by Cancel ends with agent:cancelled instead. Prompts for
+// sessions whose agent runs on another relay go through the Forwarder, if
+// any, and produce no deltas. Deltas are sent through an outbox, which
+// pauses reading from the agent while the client falls behind (see
+// WithFlowControl); deltas still queued when the reply is cancelled are
+// dropped, and agent:cancelled counts only the deltas sent.
+// Plaintext prompts to sessions with EncryptedPayloads fail with ErrPayloadNotSealed.
+func (s *AgentStreamer) Stream(ctx context.Context, sessionID, correlationID, content string) (*acp.AgentMessage, error) {
+	return s.stream(ctx, sessionID, correlationID, func(ctx context.Context, sess *session.Session, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+		if err := checkSealed(sess, content); err != nil {
+			return nil, err
+		}
+		if s.forwarder != nil && !hostsAgent(sess) {
+			return s.forwarder.Forward(ctx, sessionID, content)
+		}
+		return s.manager.StreamMessage(ctx, sessionID, content, onDelta)
+	})
+}
+
+// ResolveToolCall approves or denies the tool call a session's agent is
+// waiting on and forwards the agent's reply like Stream
+// Fails with session.ErrNoToolCall unless the session's last reply left that
+// call for the client.
+func (s *AgentStreamer) ResolveToolCall(ctx context.Context, sessionID, correlationID, toolCallID string, approved bool) (*acp.AgentMessage, error) {
+	return s.stream(ctx, sessionID, correlationID, func(ctx context.Context, _ *session.Session, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+		return s.manager.ResolveToolCall(ctx, sessionID, toolCallID, approved, onDelta)
+	})
+}
+
+// replyFunc asks a session's agent for a reply, passing streamed chunks to onDelta
+type replyFunc func(ctx context.Context, sess *session.Session, onDelta func(acp.Delta)) (*acp.AgentMessage, error)
+
+// stream runs send as an in-flight reply and forwards its deltas and end to
+// the session's connections (see Stream)
+func (s *AgentStreamer) stream(ctx context.Context, sessionID, correlationID string, send replyFunc) (*acp.AgentMessage, error) {
+	if correlationID == "" {
+		return nil, fmt.Errorf("correlation ID is required")
+	}
+	sess := s.manager.Get(sessionID)
+	if sess == nil {
+		return nil, fmt.Errorf("%w: %s", session.ErrSessionNotFound, sessionID)
+	}
+	if len(sess.GetHandle().Connections()) == 0 {
+		return nil, fmt.Errorf("session %s has no WebSocket attached", sessionID)
+	}
+	if err := authorize(ctx, sess); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(session.WithCorrelationID(ctx, correlationID))
+	defer cancel()
+	key := streamKey{sessionID: sessionID, correlationID: correlationID}
+	if err := s.track(key, cancel); err != nil {
+		return nil, err
+	}
+	defer s.untrack(key)
+
+	sent := 0 // Written by the outbox's sender; read once it has stopped
+	out := newOutbox(s.highWater, s.lowWater, func(v interface{}) {
+		sent = v.(AgentDeltaMessage).Seq
+		if werr := broadcast(sess, v); werr != nil {
+			s.logger.Printf("Failed to send agent delta: session=%s seq=%d err=%v", sessionID, sent, werr)
+		}
+	})
+	seq := 0
+	msg, err := send(ctx, sess, func(delta acp.Delta) {
+		if delta.Content == "" {
+			return
+		}
+		if out.push(ctx, NewAgentDeltaMessage(sessionID, correlationID, seq+1, delta.Content)) == nil {
+			seq++
+		}
+	})
+	cancelled := err != nil && errors.Is(err, context.Canceled) && ctx.Err() != nil
+	if cancelled {
+		out.discard()
+	} else {
+		out.close()
+	}
+	if n := out.pauseCount(); n > 0 {
+		s.logger.Printf("Agent reply paused for a slow client: session=%s correlation=%s pauses=%d", sessionID, correlationID, n)
+	}
+
+	timestamp := FormatTimestamp(s.clock.Now())
+	var final interface{}
+	switch {
+	case cancelled:
+		final = NewAgentCancelledMessage(sessionID, correlationID, sent, timestamp)
+	case err != nil:
+		final = NewAgentCompleteMessage(sessionID, correlationID, seq, nil, timestamp, &ErrorDetail{
+			Code:        agentErrorCode(err),
+			Message:     err.Error(),
+			Recoverable: true,
+		})
+	default:
+		final = NewAgentCompleteMessage(sessionID, correlationID, seq, msg.AllParts(), timestamp, nil)
+	}
+	if werr := broadcast(sess, final); werr != nil {
+		s.logger.Printf("Failed to send end of agent reply: session=%s err=%v", sessionID, werr)
+	}
+
+	return msg, err
+}
+
+// authorize checks that the user ctx is attributed to may use sess
+// (session.WithUser; anonymous callers may only use anonymous sessions)
+func authorize(ctx context.Context, sess *session.Session) error {
+	if user := session.UserFromContext(ctx); !sess.CanAccess(user) {
+		return fmt.Errorf("%w: %q on %s", session.ErrAccessDenied, user, sess.GetID())
+	}
+	return nil
+}
+
+// agentErrorCode maps a failed agent request to its protocol error code (pure function)
+func agentErrorCode(err error) string {
+	var peerErr *PeerError
+	var tooLarge *acp.ResponseTooLargeError
+	switch {
+	case errors.As(err, &peerErr) && peerErr.Code != "":
+		return peerErr.Code
+	case errors.As(err, &tooLarge):
+		return "RESPONSE_TOO_LARGE"
+	case errors.Is(err, session.ErrSessionBusy):
+		return "BUSY"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "DEADLINE_EXCEEDED"
+	case errors.Is(err, session.ErrSessionTerminated):
+		return "SESSION_TERMINATED"
+	case errors.Is(err, session.ErrNoToolCall):
+		return "NO_TOOL_CALL"
+	case errors.Is(err, ErrPayloadNotSealed):
+		return "PAYLOAD_NOT_SEALED"
+	}
+	return "AGENT_REQUEST_FAILED"
+}
+
+// Owns reports whether conn is one of the WebSockets attached to the session
+// Only attached connections may prompt a session's agent
+func (s *AgentStreamer) Owns(sessionID string, conn WebSocketConn) bool {
+	sess := s.manager.Get(sessionID)
+	return sess != nil && sess.GetHandle().HasConnection(conn)
+}
+
+// Observes reports whether conn watches the session read-only
+func (s *AgentStreamer) Observes(sessionID string, conn WebSocketConn) bool {
+	sess := s.manager.Get(sessionID)
+	return sess != nil && sess.GetHandle().HasObserver(conn)
+}
+
+// Detach unbinds conn from every session it is attached to or observing
+// Called when the connection closes; the sessions and their agents keep
+// running, still served by any other attached connections, and a session
+// left with none can be reattached later
+func (s *AgentStreamer) Detach(conn WebSocketConn) {
+	ids := append(s.OwnedBy(conn), s.ObservedBy(conn)...)
+	for _, id := range ids {
+		if _, err := s.manager.Detach(context.Background(), id, conn); err != nil {
+			s.logger.Printf("Failed to detach session: session=%s err=%v", id, err)
+		}
+	}
+}
+
+// OwnedBy returns the IDs of the sessions attached to conn, oldest first
+func (s *AgentStreamer) OwnedBy(conn WebSocketConn) []string {
+	return s.sessionsWhere(func(h *session.Handle) bool { return h.HasConnection(conn) })
+}
+
+// ObservedBy returns the IDs of the sessions conn observes, oldest first
+func (s *AgentStreamer) ObservedBy(conn WebSocketConn) []string {
+	return s.sessionsWhere(func(h *session.Handle) bool { return h.HasObserver(conn) })
+}
+
+// sessionsWhere returns the IDs of the sessions whose handle matches, oldest first
+func (s *AgentStreamer) sessionsWhere(match func(*session.Handle) bool) []string {
+	var ids []string
+	for _, sess := range s.manager.List(&session.SessionFilter{SortBy: session.SortByCreatedAt}) {
+		if match(sess.GetHandle()) {
+			ids = append(ids, sess.GetID())
+		}
+	}
+	return ids
+}
+
+// Reattach attaches conn to a session, e.g. after a client reconnects or from
+// a second browser tab
+// Anonymous sessions can be reattached by anyone knowing their ID, so IDs
+// must be unguessable (the default UUID strategy); owned sessions only by
+// their owner and collaborators, per the user in ctx (session.WithUser).
+// Sessions that already have as many connections as the manager allows
+// return session.ErrTooManyConnections.
+func (s *AgentStreamer) Reattach(ctx context.Context, sessionID string, conn WebSocketConn) (*session.Session, error) {
+	sess := s.manager.Get(sessionID)
+	if sess == nil {
+		return nil, fmt.Errorf("%w: %s", session.ErrSessionNotFound, sessionID)
+	}
+	if err := authorize(ctx, sess); err != nil {
+		return nil, err
+	}
+	if err := s.manager.Attach(ctx, sessionID, conn); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Observe lets conn watch a session read-only: it receives the session's
+// agent output and state changes but may not prompt or cancel
+// Access is checked like Reattach.
+func (s *AgentStreamer) Observe(ctx context.Context, sessionID string, conn WebSocketConn) (*session.Session, error) {
+	sess := s.manager.Get(sessionID)
+	if sess == nil {
+		return nil, fmt.Errorf("%w: %s", session.ErrSessionNotFound, sessionID)
+	}
+	if err := authorize(ctx, sess); err != nil {
+		return nil, err
+	}
+	if err := s.manager.Observe(ctx, sessionID, conn); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Share lets userID prompt a session owned by the user in ctx and returns the
+// session with its updated collaborators
+func (s *AgentStreamer) Share(ctx context.Context, sessionID, userID string) (*session.Session, []string, error) {
+	users, err := s.manager.Share(ctx, sessionID, session.UserFromContext(ctx), userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.manager.Get(sessionID), users, nil
+}
+
+// Unshare revokes userID's access to a session owned by the user in ctx
+func (s *AgentStreamer) Unshare(ctx context.Context, sessionID, userID string) (*session.Session, []string, error) {
+	users, err := s.manager.Unshare(ctx, sessionID, session.UserFromContext(ctx), userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.manager.Get(sessionID), users, nil
+}
+
+// Transfer hands a session owned by the user in ctx to userID
+// Anonymous callers own nothing and get session.ErrNotOwner.
+func (s *AgentStreamer) Transfer(ctx context.Context, sessionID, userID string, keepAccess bool) (*session.Session, []string, error) {
+	owner := session.UserFromContext(ctx)
+	if owner == "" {
+		return nil, nil, fmt.Errorf("%w: %s", session.ErrNotOwner, sessionID)
+	}
+	users, err := s.manager.Transfer(ctx, sessionID, owner, userID, keepAccess)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.manager.Get(sessionID), users, nil
+}
+
+// Cancel stops an in-flight reply; its stream ends with agent:cancelled
+// Returns ErrUnknownRequest if the reply already finished or never existed
+func (s *AgentStreamer) Cancel(sessionID, correlationID string) error {
+	s.mu.Lock()
+	cancel, ok := s.inFlight[streamKey{sessionID: sessionID, correlationID: correlationID}]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: session=%s correlationId=%s", ErrUnknownRequest, sessionID, correlationID)
+	}
+	cancel()
+	return nil
+}
+
+// track registers an in-flight reply, rejecting duplicate correlation IDs
+func (s *AgentStreamer) track(key streamKey, cancel context.CancelFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.inFlight[key]; exists {
+		return fmt.Errorf("%w: %s for session %s", ErrDuplicateRequest, key.correlationID, key.sessionID)
+	}
+	s.inFlight[key] = cancel
+	return nil
+}
+
+// untrack forgets a finished reply
+func (s *AgentStreamer) untrack(key streamKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, key)
+}
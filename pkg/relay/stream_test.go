@@ -0,0 +1,468 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+type mockStreamingACPClient struct {
+	chunks []string
+	err    error
+}
+
+func (m *mockStreamingACPClient) SendMessage(content string) (*acp.AgentMessage, error) {
+	return m.SendMessageStream(content, func(acp.Delta) {})
+}
+
+func (m *mockStreamingACPClient) SendMessageStream(content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	for i, chunk := range m.chunks {
+		onDelta(acp.Delta{RequestID: 1, Seq: i + 1, Content: chunk})
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &acp.AgentMessage{Type: "text", Content: "Echo: " + content}, nil
+}
+
+func (m *mockStreamingACPClient) Close() error { return nil }
+
+// mockBlockingACPClient streams one chunk then waits until the request is cancelled
+type mockBlockingACPClient struct {
+	started chan struct{}
+}
+
+func (m *mockBlockingACPClient) SendMessage(content string) (*acp.AgentMessage, error) {
+	return m.SendMessageContext(context.Background(), content, nil)
+}
+
+func (m *mockBlockingACPClient) SendMessageContext(ctx context.Context, content string, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	onDelta(acp.Delta{RequestID: 1, Seq: 1, Content: "thinking"})
+	close(m.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (m *mockBlockingACPClient) Close() error { return nil }
+
+func setupStreamer(t *testing.T, client session.ACPClient) (*AgentStreamer, *mockWebSocketConn) {
+	t.Helper()
+	ctx := context.Background()
+	conn := &mockWebSocketConn{}
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"})
+	if _, err := manager.Create(ctx, "auth", conn); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, "session-1"); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, "session-1", "/tmp/worktree", client); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+	conn.written = nil // Drop agent:state notifications
+
+	return NewAgentStreamer(manager, &mockClock{now: testTime}, &mockLogger{}), conn
+}
+
+func TestAgentStreamer_ForwardsDeltasThenComplete(t *testing.T) {
+	streamer, conn := setupStreamer(t, &mockStreamingACPClient{chunks: []string{"Echo: ", "", "hi ", "there"}})
+
+	if _, err := streamer.Stream(context.Background(), "session-1", "req-1", "hi there"); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+
+	if len(conn.written) != 4 {
+		t.Fatalf("expected 3 deltas and 1 complete, got %d messages", len(conn.written))
+	}
+	for i, want := range []string{"Echo: ", "hi ", "there"} {
+		delta, ok := conn.written[i].(AgentDeltaMessage)
+		if !ok {
+			t.Fatalf("message %d: expected AgentDeltaMessage, got %T", i, conn.written[i])
+		}
+		if delta.Type != "agent:delta" || delta.Seq != i+1 || delta.Content != want || delta.CorrelationID != "req-1" {
+			t.Errorf("message %d: unexpected delta %+v", i, delta)
+		}
+	}
+
+	complete, ok := conn.written[3].(AgentCompleteMessage)
+	if !ok {
+		t.Fatalf("expected AgentCompleteMessage, got %T", conn.written[3])
+	}
+	if complete.Type != "agent:complete" || complete.Seq != 3 || complete.Error != nil || complete.CorrelationID != "req-1" {
+		t.Errorf("unexpected complete marker: %+v", complete)
+	}
+	if len(complete.Parts) != 1 || complete.Parts[0].Text != "Echo: hi there" {
+		t.Errorf("expected final reply in parts, got %+v", complete.Parts)
+	}
+	if complete.Timestamp != "2025-10-23T12:00:00Z" {
+		t.Errorf("expected timestamp from clock, got %s", complete.Timestamp)
+	}
+}
+
+func TestAgentStreamer_CompletesWithError(t *testing.T) {
+	streamer, conn := setupStreamer(t, &mockStreamingACPClient{
+		chunks: []string{"partial"},
+		err:    errors.New("agent crashed"),
+	})
+
+	if _, err := streamer.Stream(context.Background(), "session-1", "req-1", "hi"); err == nil {
+		t.Fatal("expected error from crashed agent")
+	}
+
+	if len(conn.written) != 2 {
+		t.Fatalf("expected 1 delta and 1 complete, got %d messages", len(conn.written))
+	}
+	complete := conn.written[1].(AgentCompleteMessage)
+	if complete.Seq != 1 || complete.Parts != nil {
+		t.Errorf("unexpected complete marker: %+v", complete)
+	}
+	if complete.Error == nil || complete.Error.Code != "AGENT_REQUEST_FAILED" || !complete.Error.Recoverable {
+		t.Errorf("expected recoverable AGENT_REQUEST_FAILED, got %+v", complete.Error)
+	}
+}
+
+func TestAgentStreamer_Cancel(t *testing.T) {
+	client := &mockBlockingACPClient{started: make(chan struct{})}
+	streamer, conn := setupStreamer(t, client)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := streamer.Stream(context.Background(), "session-1", "req-1", "long task")
+		done <- err
+	}()
+	<-client.started
+
+	if err := streamer.Cancel("session-1", "req-1"); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// The delta may still be queued when the cancel lands, and is then dropped
+	last := len(conn.written) - 1
+	if last != 0 && last != 1 {
+		t.Fatalf("expected at most 1 delta and agent:cancelled, got %d messages", len(conn.written))
+	}
+	cancelled, ok := conn.written[last].(AgentCancelledMessage)
+	if !ok {
+		t.Fatalf("expected AgentCancelledMessage, got %T", conn.written[last])
+	}
+	if cancelled.Type != "agent:cancelled" || cancelled.CorrelationID != "req-1" || cancelled.Seq != last {
+		t.Errorf("unexpected cancelled message: %+v", cancelled)
+	}
+
+	// The reply is finished, so cancelling again finds nothing
+	if err := streamer.Cancel("session-1", "req-1"); !errors.Is(err, ErrUnknownRequest) {
+		t.Errorf("expected ErrUnknownRequest after completion, got %v", err)
+	}
+}
+
+func TestServer_HandleMessage_AgentCancelUnknown(t *testing.T) {
+	streamer, _ := setupStreamer(t, &mockStreamingACPClient{})
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, streamer: streamer}
+	conn := &mockWebSocketConn{}
+
+	raw := []byte(`{"version":"1.0","type":"agent:cancel","sessionId":"session-1","correlationId":"missing"}`)
+	if shouldClose := server.handleMessage(conn, raw); shouldClose {
+		t.Fatal("expected connection to stay open")
+	}
+
+	if len(conn.written) != 1 {
+		t.Fatalf("expected 1 error message, got %d", len(conn.written))
+	}
+	errMsg, ok := conn.written[0].(ErrorMessage)
+	if !ok {
+		t.Fatalf("expected ErrorMessage, got %T", conn.written[0])
+	}
+	if errMsg.Error.Code != "UNKNOWN_REQUEST" || !errMsg.Error.Recoverable {
+		t.Errorf("unexpected error: %+v", errMsg.Error)
+	}
+}
+
+func TestServer_HandleMessage_AgentMessageForeignSession(t *testing.T) {
+	streamer, owner := setupStreamer(t, &mockStreamingACPClient{})
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, streamer: streamer}
+	conn := &mockWebSocketConn{}
+
+	raw := []byte(`{"version":"1.0","type":"agent:message","sessionId":"session-1","correlationId":"req-1","content":"hi"}`)
+	if shouldClose := server.handleMessage(conn, raw); shouldClose {
+		t.Fatal("expected connection to stay open")
+	}
+
+	if len(conn.written) != 1 {
+		t.Fatalf("expected 1 rejection, got %d", len(conn.written))
+	}
+	complete, ok := conn.written[0].(AgentCompleteMessage)
+	if !ok {
+		t.Fatalf("expected AgentCompleteMessage, got %T", conn.written[0])
+	}
+	if complete.CorrelationID != "req-1" || complete.Error == nil || complete.Error.Code != "SESSION_NOT_FOUND" {
+		t.Errorf("unexpected rejection: %+v", complete)
+	}
+	if len(owner.written) != 0 {
+		t.Errorf("expected the owning connection to see nothing, got %d messages", len(owner.written))
+	}
+}
+
+// mockToolACPClient asks to run a tool, then reports how the call was resolved
+type mockToolACPClient struct{}
+
+func (m *mockToolACPClient) SendMessage(content string) (*acp.AgentMessage, error) {
+	return &acp.AgentMessage{Type: acp.PartTypeToolCall, ToolCall: &acp.ToolCall{ID: "tool-1", Name: content}}, nil
+}
+
+func (m *mockToolACPClient) ResolveToolCall(_ context.Context, params acp.ToolCallParams, onDelta func(acp.Delta)) (*acp.AgentMessage, error) {
+	onDelta(acp.Delta{RequestID: 2, Seq: 1, Content: "Running"})
+	return &acp.AgentMessage{Type: "text", Content: fmt.Sprintf("%s approved=%t", params.ToolCallID, params.Approved)}, nil
+}
+
+func (m *mockToolACPClient) Close() error { return nil }
+
+func TestAgentStreamer_ResolveToolCall(t *testing.T) {
+	streamer, conn := setupStreamer(t, &mockToolACPClient{})
+	ctx := context.Background()
+
+	if _, err := streamer.Stream(ctx, "session-1", "req-1", "bash"); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	conn.written = nil
+
+	msg, err := streamer.ResolveToolCall(ctx, "session-1", "req-2", "tool-1", true)
+	if err != nil {
+		t.Fatalf("ResolveToolCall failed: %v", err)
+	}
+	if msg.Content != "tool-1 approved=true" {
+		t.Errorf("unexpected reply: %+v", msg)
+	}
+	if len(conn.written) != 2 {
+		t.Fatalf("expected 1 delta and 1 complete, got %d messages", len(conn.written))
+	}
+	if delta := conn.written[0].(AgentDeltaMessage); delta.CorrelationID != "req-2" || delta.Content != "Running" {
+		t.Errorf("unexpected delta: %+v", delta)
+	}
+	if complete := conn.written[1].(AgentCompleteMessage); complete.CorrelationID != "req-2" || complete.Error != nil {
+		t.Errorf("unexpected complete: %+v", complete)
+	}
+
+	// The call is no longer awaiting approval
+	conn.written = nil
+	if _, err := streamer.ResolveToolCall(ctx, "session-1", "req-3", "tool-1", true); !errors.Is(err, session.ErrNoToolCall) {
+		t.Fatalf("expected ErrNoToolCall, got %v", err)
+	}
+	if complete := conn.written[0].(AgentCompleteMessage); complete.Error == nil || complete.Error.Code != "NO_TOOL_CALL" {
+		t.Errorf("expected NO_TOOL_CALL, got %+v", complete)
+	}
+}
+
+func TestServer_HandleMessage_AgentToolDecisionForeignSession(t *testing.T) {
+	streamer, _ := setupStreamer(t, &mockToolACPClient{})
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, streamer: streamer}
+	conn := &mockWebSocketConn{}
+
+	raw := []byte(`{"version":"1.0","type":"agent:tool_decision","sessionId":"session-1","correlationId":"req-1","toolCallId":"tool-1","approved":true}`)
+	if shouldClose := server.handleMessage(conn, raw); shouldClose {
+		t.Fatal("expected connection to stay open")
+	}
+
+	if len(conn.written) != 1 {
+		t.Fatalf("expected 1 rejection, got %d", len(conn.written))
+	}
+	if complete, ok := conn.written[0].(AgentCompleteMessage); !ok || complete.Error == nil || complete.Error.Code != "SESSION_NOT_FOUND" {
+		t.Errorf("expected SESSION_NOT_FOUND, got %+v", conn.written[0])
+	}
+}
+
+func TestAgentStreamer_DetachAndReattach(t *testing.T) {
+	streamer, owner := setupStreamer(t, &mockStreamingACPClient{})
+	ctx := context.Background()
+	newConn := &mockWebSocketConn{}
+
+	if _, err := streamer.Reattach(ctx, "session-1", newConn); !errors.Is(err, session.ErrTooManyConnections) {
+		t.Fatalf("expected a live session to be unclaimable, got %v", err)
+	}
+
+	streamer.Detach(owner)
+	if streamer.Owns("session-1", owner) {
+		t.Fatal("expected the closed connection to lose the session")
+	}
+
+	sess, err := streamer.Reattach(ctx, "session-1", newConn)
+	if err != nil {
+		t.Fatalf("Reattach failed: %v", err)
+	}
+	if sess.GetID() != "session-1" || !streamer.Owns("session-1", newConn) {
+		t.Errorf("expected session-1 to belong to the new connection")
+	}
+	if _, err := streamer.Reattach(ctx, "missing", newConn); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestAgentStreamer_FansOutToEveryConnection(t *testing.T) {
+	ctx := context.Background()
+	first := &mockWebSocketConn{}
+	second := &mockWebSocketConn{}
+	manager := NewSessionManager(&mockLogger{}, &mockClock{now: testTime}, &mockIDGenerator{id: "session-1"}, session.WithMaxConnections(2))
+	if _, err := manager.Create(ctx, "auth", first); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	streamer := NewAgentStreamer(manager, &mockClock{now: testTime}, &mockLogger{})
+	if _, err := streamer.Reattach(ctx, "session-1", second); err != nil {
+		t.Fatalf("Reattach failed: %v", err)
+	}
+	if err := manager.BeginSpawn(ctx, "session-1"); err != nil {
+		t.Fatalf("BeginSpawn failed: %v", err)
+	}
+	if err := manager.AttachAgent(ctx, "session-1", "/tmp/worktree", &mockStreamingACPClient{chunks: []string{"hi"}}); err != nil {
+		t.Fatalf("AttachAgent failed: %v", err)
+	}
+	if len(first.written) != 3 || len(second.written) != 3 {
+		t.Fatalf("expected both connections to see agent:state twice and agent:ready, got %d and %d", len(first.written), len(second.written))
+	}
+
+	first.written, second.written = nil, nil
+	if _, err := streamer.Stream(ctx, "session-1", "req-1", "hi"); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	for name, conn := range map[string]*mockWebSocketConn{"first": first, "second": second} {
+		if len(conn.written) != 2 {
+			t.Errorf("%s: expected a delta and complete, got %d messages", name, len(conn.written))
+		}
+	}
+
+	// Closing one tab leaves the session with the other
+	streamer.Detach(first)
+	if streamer.Owns("session-1", first) || !streamer.Owns("session-1", second) {
+		t.Error("expected only the remaining connection to own the session")
+	}
+}
+
+func TestServer_HandleMessage_SessionReattach(t *testing.T) {
+	streamer, owner := setupStreamer(t, &mockStreamingACPClient{})
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, streamer: streamer}
+	conn := &mockWebSocketConn{}
+	raw := []byte(`{"version":"1.0","type":"session:reattach","sessionId":"session-1"}`)
+
+	server.handleMessage(conn, raw)
+	if errMsg, ok := conn.written[0].(ErrorMessage); !ok || errMsg.Error.Code != "SESSION_ATTACHED" {
+		t.Fatalf("expected SESSION_ATTACHED while the owner is connected, got %+v", conn.written[0])
+	}
+
+	streamer.Detach(owner)
+	server.handleMessage(conn, raw)
+	reply, ok := conn.written[1].(SessionReattachedMessage)
+	if !ok {
+		t.Fatalf("expected SessionReattachedMessage, got %T", conn.written[1])
+	}
+	if reply.SessionID != "session-1" || reply.Role != "auth" || reply.State != "ACTIVE" {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+}
+
+func TestServer_HandleMessage_SessionObserve(t *testing.T) {
+	streamer, owner := setupStreamer(t, &mockStreamingACPClient{chunks: []string{"hi"}})
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, streamer: streamer}
+	observer := &mockWebSocketConn{}
+
+	server.handleMessage(observer, []byte(`{"version":"1.0","type":"session:observe","sessionId":"session-1"}`))
+	reply, ok := observer.written[0].(SessionObservingMessage)
+	if !ok {
+		t.Fatalf("expected SessionObservingMessage, got %T", observer.written[0])
+	}
+	if reply.Type != "session:observing" || reply.SessionID != "session-1" || reply.State != "ACTIVE" {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+
+	// Observers may neither prompt nor cancel
+	server.handleMessage(observer, []byte(`{"version":"1.0","type":"agent:message","sessionId":"session-1","correlationId":"req-1","content":"hi"}`))
+	if complete, ok := observer.written[1].(AgentCompleteMessage); !ok || complete.Error == nil || complete.Error.Code != "READ_ONLY" {
+		t.Fatalf("expected a READ_ONLY rejection, got %+v", observer.written[1])
+	}
+	server.handleMessage(observer, []byte(`{"version":"1.0","type":"agent:cancel","sessionId":"session-1","correlationId":"req-1"}`))
+	if errMsg, ok := observer.written[2].(ErrorMessage); !ok || errMsg.Error.Code != "READ_ONLY" {
+		t.Fatalf("expected a READ_ONLY error, got %+v", observer.written[2])
+	}
+
+	// ...but see the owner's replies
+	observer.written = nil
+	if _, err := streamer.Stream(context.Background(), "session-1", "req-1", "hi"); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if len(observer.written) != 2 || len(owner.written) != 2 {
+		t.Errorf("expected owner and observer to both see a delta and complete, got %d and %d", len(owner.written), len(observer.written))
+	}
+
+	streamer.Detach(observer)
+	if streamer.Observes("session-1", observer) || !streamer.Owns("session-1", owner) {
+		t.Error("expected the observer to leave without detaching the owner")
+	}
+	server.handleMessage(observer, []byte(`{"version":"1.0","type":"session:observe","sessionId":"missing"}`))
+	if errMsg, ok := observer.written[2].(ErrorMessage); !ok || errMsg.Error.Code != "SESSION_NOT_FOUND" {
+		t.Errorf("expected SESSION_NOT_FOUND, got %+v", observer.written[2])
+	}
+}
+
+func TestAgentErrorCode(t *testing.T) {
+	busy := fmt.Errorf("%w: session-1", session.ErrSessionBusy)
+	if code := agentErrorCode(busy); code != "BUSY" {
+		t.Errorf("expected BUSY, got %s", code)
+	}
+	if code := agentErrorCode(fmt.Errorf("request 1 cancelled: %w", context.DeadlineExceeded)); code != "DEADLINE_EXCEEDED" {
+		t.Errorf("expected DEADLINE_EXCEEDED, got %s", code)
+	}
+	if code := agentErrorCode(fmt.Errorf("failed to read response: %w", &acp.ResponseTooLargeError{Limit: 1024})); code != "RESPONSE_TOO_LARGE" {
+		t.Errorf("expected RESPONSE_TOO_LARGE, got %s", code)
+	}
+	if code := agentErrorCode(errors.New("agent crashed")); code != "AGENT_REQUEST_FAILED" {
+		t.Errorf("expected AGENT_REQUEST_FAILED, got %s", code)
+	}
+}
+
+func TestAgentStreamer_DeadlineExceeded(t *testing.T) {
+	streamer, conn := setupStreamer(t, &mockBlockingACPClient{started: make(chan struct{})})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := streamer.Stream(ctx, "session-1", "req-1", "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+
+	complete, ok := conn.written[len(conn.written)-1].(AgentCompleteMessage)
+	if !ok {
+		t.Fatalf("expected the reply to end with agent:complete, got %T", conn.written[len(conn.written)-1])
+	}
+	if complete.Error == nil || complete.Error.Code != "DEADLINE_EXCEEDED" {
+		t.Errorf("expected DEADLINE_EXCEEDED, got %+v", complete.Error)
+	}
+}
+
+func TestServer_StreamReplyRecoversFromPanic(t *testing.T) {
+	streamer, conn := setupStreamer(t, &mockStreamingACPClient{})
+	metrics := NewCounterMetrics()
+	server := &Server{logger: &mockLogger{}, clock: &mockClock{now: testTime}, streamer: streamer, metrics: metrics}
+
+	// Must not take the process down
+	server.streamReply(conn, "session-1", "req-1", func() error { panic("boom") })
+
+	// The counter is bumped after the reply is ended, so seeing it orders the write
+	deadline := time.Now().Add(2 * time.Second)
+	for metrics.Value(MetricConnectionPanics) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the panic recovered and counted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(conn.written) != 1 {
+		t.Fatalf("expected agent:complete, got %+v", conn.written)
+	}
+	complete, ok := conn.written[0].(AgentCompleteMessage)
+	if !ok || complete.CorrelationID != "req-1" || complete.Error == nil || complete.Error.Code != "INTERNAL_ERROR" {
+		t.Errorf("expected agent:complete with INTERNAL_ERROR, got %+v", conn.written[0])
+	}
+}
@@ -0,0 +1,127 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// jsonFrame tracks one open object or array while walking a message
+type jsonFrame struct {
+	keys      map[string]bool // Keys seen so far (objects only)
+	path      string
+	key       string // Key whose value is being read (objects only)
+	index     int    // Next element index (arrays only)
+	object    bool
+	expectKey bool
+}
+
+// childPath names the value currently being read inside the frame
+func (f *jsonFrame) childPath() string {
+	if f.object {
+		return f.path + "." + f.key
+	}
+	return f.path + "[" + strconv.Itoa(f.index) + "]"
+}
+
+// valueDone advances the frame past the value just read
+func (f *jsonFrame) valueDone() {
+	if f.object {
+		f.expectKey = true
+	} else {
+		f.index++
+	}
+}
+
+// limitError reports a message that exceeds a structural limit
+func limitError(format string, v ...interface{}) error {
+	return ValidationError{
+		Code:        "MESSAGE_TOO_COMPLEX",
+		Message:     fmt.Sprintf(format, v...),
+		Recoverable: true,
+	}
+}
+
+// checkStrictJSON enforces the structural rules in cfg that encoding/json
+// does not: it walks every token so nested objects are covered too, and
+// stops at the first violation before anything is decoded (pure function)
+func checkStrictJSON(data []byte, cfg ProtocolConfig) error {
+	if !cfg.RejectDuplicateKeys && cfg.MaxDepth <= 0 && cfg.MaxFields <= 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var stack []*jsonFrame
+	fields := 0
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return ValidationError{
+				Code:        "INVALID_MESSAGE",
+				Message:     fmt.Sprintf("Invalid JSON: %v", err),
+				Recoverable: true,
+			}
+		}
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		// Count each object key and array element as it starts
+		if top != nil && (top.expectKey || !top.object) && tok != json.Delim('}') && tok != json.Delim(']') {
+			fields++
+			if cfg.MaxFields > 0 && fields > cfg.MaxFields {
+				return limitError("Message has more than %d fields", cfg.MaxFields)
+			}
+		}
+
+		if top != nil && top.expectKey && tok != json.Delim('}') {
+			key, _ := tok.(string) // Decoder guarantees object keys are strings
+			if cfg.RejectDuplicateKeys && top.keys[key] {
+				return ValidationError{
+					Code:        "DUPLICATE_KEY",
+					Message:     fmt.Sprintf("Duplicate key %q in object at %s", key, top.path),
+					Recoverable: true,
+				}
+			}
+			top.keys[key] = true
+			top.key = key
+			top.expectKey = false
+			continue
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			if cfg.MaxDepth > 0 && len(stack) >= cfg.MaxDepth {
+				return limitError("Message nesting exceeds depth %d", cfg.MaxDepth)
+			}
+			path := "$"
+			if top != nil {
+				path = top.childPath()
+			}
+			object := tok == json.Delim('{')
+			frame := &jsonFrame{path: path, object: object, expectKey: object}
+			if object {
+				frame.keys = make(map[string]bool)
+			}
+			stack = append(stack, frame)
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				stack[len(stack)-1].valueDone()
+			}
+		default:
+			if top != nil {
+				top.valueDone()
+			}
+		}
+	}
+}
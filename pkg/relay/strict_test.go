@@ -0,0 +1,84 @@
+package relay
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckStrictJSON_DuplicateKeys(t *testing.T) {
+	strict := ProtocolConfig{RejectDuplicateKeys: true}
+
+	tests := []struct {
+		name   string
+		data   string
+		errMsg string // Empty means valid
+	}{
+		{"unique keys", `{"version":"1.0","type":"a","payload":{"type":"b"}}`, ""},
+		{"same key in sibling objects", `{"items":[{"id":1},{"id":2}]}`, ""},
+		{"empty containers", `{"a":{},"b":[],"c":[[]]}`, ""},
+		{"top-level duplicate", `{"type":"a","type":"b"}`, `Duplicate key "type" in object at $`},
+		{"nested duplicate", `{"payload":{"x":1,"x":2}}`, `at $.payload`},
+		{"duplicate inside array", `{"items":[{"id":1},{"id":1,"id":2}]}`, `at $.items[1]`},
+		{"duplicate after nested value", `{"a":{"b":[1,{"c":2}]},"a":3}`, `Duplicate key "a"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkStrictJSON([]byte(tt.data), strict)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("expected valid, got %v", err)
+				}
+				return
+			}
+			verr, ok := err.(ValidationError)
+			if !ok || verr.Code != "DUPLICATE_KEY" || !strings.Contains(verr.Message, tt.errMsg) {
+				t.Errorf("expected DUPLICATE_KEY containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestValidateMessageWith_DuplicateKeysOptIn(t *testing.T) {
+	data := []byte(`{"version":"1.0","type":"a","type":"b"}`)
+
+	if err := ValidateMessage(data); err != nil {
+		t.Errorf("expected lenient default to accept duplicates, got %v", err)
+	}
+	if err := ValidateMessageWith(data, ProtocolConfig{RejectDuplicateKeys: true}); err == nil {
+		t.Error("expected strict mode to reject duplicates")
+	}
+}
+
+func TestCheckStrictJSON_Limits(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		cfg   ProtocolConfig
+		valid bool
+	}{
+		{"within depth", `{"a":{"b":[1]}}`, ProtocolConfig{MaxDepth: 3}, true},
+		{"too deep", `{"a":{"b":[1]}}`, ProtocolConfig{MaxDepth: 2}, false},
+		{"pathological nesting", strings.Repeat("[", 100000), ProtocolConfig{MaxDepth: 32}, false},
+		{"within fields", `{"a":1,"b":[1,2]}`, ProtocolConfig{MaxFields: 4}, true},
+		{"too many keys", `{"a":1,"b":2,"c":3}`, ProtocolConfig{MaxFields: 2}, false},
+		{"too many array elements", `{"a":[1,2,3,4]}`, ProtocolConfig{MaxFields: 4}, false},
+		{"unlimited", strings.Repeat("[", 50) + strings.Repeat("]", 50), ProtocolConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkStrictJSON([]byte(tt.data), tt.cfg)
+			if tt.valid {
+				if err != nil {
+					t.Errorf("expected valid, got %v", err)
+				}
+				return
+			}
+			verr, ok := err.(ValidationError)
+			if !ok || verr.Code != "MESSAGE_TOO_COMPLEX" || !verr.Recoverable {
+				t.Errorf("expected recoverable MESSAGE_TOO_COMPLEX, got %v", err)
+			}
+		})
+	}
+}
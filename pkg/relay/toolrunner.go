@@ -0,0 +1,85 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+	"github.com/2389-research/ourocodus/pkg/sandbox"
+)
+
+// ShellToolRunner runs approved calls of the named shell tools through
+// runner instead of letting the agent run them
+// The command is read from the call's "command" argument and runs in the
+// workspace, or in its "cwd" argument resolved inside the workspace; calls
+// without a command are left to the agent. Commands that cannot be started
+// report the reason in ToolResult.Error.
+func ShellToolRunner(runner *sandbox.Runner, tools []string, logger Logger) session.ToolRunner {
+	tools = slices.Clone(tools)
+	return func(ctx context.Context, workspace string, call *acp.ToolCall) (*acp.ToolResult, bool) {
+		if !slices.Contains(tools, call.Name) {
+			return nil, false
+		}
+		command, ok := call.Args["command"].(string)
+		if !ok || command == "" {
+			return nil, false
+		}
+		dir, _ := call.Args["cwd"].(string)
+
+		result, err := runner.Run(ctx, workspace, dir, command)
+		if err != nil {
+			logger.Printf("Sandboxed tool call failed: tool=%s id=%s err=%v", call.Name, call.ID, err)
+			return &acp.ToolResult{Error: err.Error(), ExitCode: -1}, true
+		}
+		logger.Printf("Sandboxed tool call ran: tool=%s id=%s exit=%d timedOut=%t duration=%s",
+			call.Name, call.ID, result.ExitCode, result.TimedOut, result.Duration)
+		return &acp.ToolResult{
+			Stdout:    result.Stdout,
+			Stderr:    result.Stderr,
+			ExitCode:  result.ExitCode,
+			TimedOut:  result.TimedOut,
+			Truncated: result.Truncated,
+		}, true
+	}
+}
+
+// FileToolRunner reads and writes files for approved calls of the named
+// tools through guard, confining them to the workspace
+// The file is the call's "path" argument, and writes take their data from
+// "content"; calls without a path are left to the agent. A read returns the
+// file in Stdout. Paths the guard refuses (outside the workspace, not a
+// regular file, over the size cap) report the reason in ToolResult.Error.
+func FileToolRunner(guard *sandbox.FileGuard, readTools, writeTools []string, logger Logger) session.ToolRunner {
+	readTools, writeTools = slices.Clone(readTools), slices.Clone(writeTools)
+	return func(_ context.Context, workspace string, call *acp.ToolCall) (*acp.ToolResult, bool) {
+		read := slices.Contains(readTools, call.Name)
+		if !read && !slices.Contains(writeTools, call.Name) {
+			return nil, false
+		}
+		name, ok := call.Args["path"].(string)
+		if !ok || name == "" {
+			return nil, false
+		}
+
+		var result acp.ToolResult
+		var err error
+		if read {
+			var data []byte
+			if data, err = guard.ReadFile(workspace, name); err == nil {
+				result.Stdout = string(data)
+			}
+		} else {
+			content, _ := call.Args["content"].(string)
+			if err = guard.WriteFile(workspace, name, []byte(content)); err == nil {
+				result.Stdout = fmt.Sprintf("wrote %d bytes to %s", len(content), name)
+			}
+		}
+		if err != nil {
+			logger.Printf("Guarded file tool call refused: tool=%s id=%s path=%s err=%v", call.Name, call.ID, name, err)
+			return &acp.ToolResult{Error: err.Error(), ExitCode: -1}, true
+		}
+		return &result, true
+	}
+}
@@ -0,0 +1,79 @@
+//go:build !windows
+
+package relay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/2389-research/ourocodus/pkg/acp"
+	"github.com/2389-research/ourocodus/pkg/sandbox"
+)
+
+func TestShellToolRunner(t *testing.T) {
+	ctx := context.Background()
+	workspace := t.TempDir()
+	run := ShellToolRunner(sandbox.New(), []string{"bash"}, &mockLogger{})
+
+	result, handled := run(ctx, workspace, &acp.ToolCall{ID: "tool-1", Name: "bash", Args: map[string]interface{}{"command": "echo hi; exit 2"}})
+	if !handled || result.Stdout != "hi\n" || result.ExitCode != 2 || result.Error != "" {
+		t.Errorf("expected the command to run, got %+v (handled=%t)", result, handled)
+	}
+
+	result, handled = run(ctx, workspace, &acp.ToolCall{ID: "tool-2", Name: "bash", Args: map[string]interface{}{"command": "ls", "cwd": "../.."}})
+	if !handled || result.ExitCode != -1 || !strings.Contains(result.Error, "outside the workspace") {
+		t.Errorf("expected an escaping cwd to be refused, got %+v (handled=%t)", result, handled)
+	}
+
+	for _, call := range []*acp.ToolCall{
+		{ID: "tool-3", Name: "read_file", Args: map[string]interface{}{"command": "ls"}},
+		{ID: "tool-4", Name: "bash", Args: map[string]interface{}{"script": "ls"}},
+	} {
+		if _, handled := run(ctx, workspace, call); handled {
+			t.Errorf("expected %+v to be left to the agent", call)
+		}
+	}
+}
+
+func TestFileToolRunner(t *testing.T) {
+	ctx := context.Background()
+	workspace := t.TempDir()
+	logger := &mockLogger{}
+	run := FileToolRunner(sandbox.NewFileGuard(sandbox.WithMaxWrite(16)), []string{"read_file"}, []string{"write_file"}, logger)
+
+	result, handled := run(ctx, workspace, &acp.ToolCall{ID: "tool-1", Name: "write_file", Args: map[string]interface{}{"path": "notes.txt", "content": "hello"}})
+	if !handled || result.Error != "" || result.Stdout != "wrote 5 bytes to notes.txt" {
+		t.Errorf("expected the file to be written, got %+v (handled=%t)", result, handled)
+	}
+	result, handled = run(ctx, workspace, &acp.ToolCall{ID: "tool-2", Name: "read_file", Args: map[string]interface{}{"path": "notes.txt"}})
+	if !handled || result.Error != "" || result.Stdout != "hello" {
+		t.Errorf("expected the file to be read, got %+v (handled=%t)", result, handled)
+	}
+
+	for _, tt := range []struct {
+		call   *acp.ToolCall
+		errSub string
+	}{
+		{&acp.ToolCall{ID: "tool-3", Name: "read_file", Args: map[string]interface{}{"path": "../../etc/passwd"}}, "outside the workspace"},
+		{&acp.ToolCall{ID: "tool-4", Name: "write_file", Args: map[string]interface{}{"path": "big.txt", "content": strings.Repeat("x", 17)}}, "too large"},
+		{&acp.ToolCall{ID: "tool-5", Name: "read_file", Args: map[string]interface{}{"path": "."}}, "outside the workspace"},
+	} {
+		result, handled := run(ctx, workspace, tt.call)
+		if !handled || result.ExitCode != -1 || !strings.Contains(result.Error, tt.errSub) {
+			t.Errorf("%s: expected an error containing %q, got %+v (handled=%t)", tt.call.ID, tt.errSub, result, handled)
+		}
+	}
+	if len(logger.logs) == 0 {
+		t.Error("expected refusals to be logged")
+	}
+
+	for _, call := range []*acp.ToolCall{
+		{ID: "tool-6", Name: "bash", Args: map[string]interface{}{"path": "notes.txt"}},
+		{ID: "tool-7", Name: "read_file", Args: map[string]interface{}{"file": "notes.txt"}},
+	} {
+		if _, handled := run(ctx, workspace, call); handled {
+			t.Errorf("expected %+v to be left to the agent", call)
+		}
+	}
+}
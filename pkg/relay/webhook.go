@@ -0,0 +1,244 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// Webhook event names
+const (
+	WebhookSessionCreated    = "session.created"
+	WebhookAgentFailed       = "agent.failed"
+	WebhookSessionTerminated = "session.terminated" // Terminations without a failure
+)
+
+// Webhook request headers
+const (
+	WebhookEventHeader     = "X-Ourocodus-Event"
+	WebhookSignatureHeader = "X-Ourocodus-Signature-256" // "sha256=" + hex HMAC of the body
+)
+
+// Webhook delivery defaults
+const (
+	defaultWebhookAttempts = 5
+	defaultWebhookBackoff  = time.Second
+	maxWebhookBackoff      = time.Minute
+	webhookQueueSize       = 256
+	webhookTimeout         = 10 * time.Second
+)
+
+// WebhookPayload is the JSON body POSTed to webhook URLs
+type WebhookPayload struct {
+	Event     string `json:"event"`
+	Time      string `json:"time"`
+	SessionID string `json:"sessionId"`
+	AgentID   string `json:"agentId"`
+	Name      string `json:"name,omitempty"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to"`
+	Reason    string `json:"reason,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SignWebhook returns the signature header value for body (pure function)
+// Receivers recompute it with their copy of the secret and compare in constant time
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookEventName maps a lifecycle event to its webhook event (pure function)
+// Returns "" for transitions that have no webhook
+func webhookEventName(event session.LifecycleEvent) string {
+	switch {
+	case event.Event == session.EventCreate:
+		return WebhookSessionCreated
+	case event.To == session.StateTerminating && event.Err != nil:
+		return WebhookAgentFailed
+	case event.To == session.StateTerminating:
+		return WebhookSessionTerminated
+	}
+	return ""
+}
+
+// webhookTarget is one configured URL with its own delivery queue, so a slow
+// or failing endpoint only delays its own deliveries
+type webhookTarget struct {
+	cfg    WebhookConfig
+	events map[string]bool // nil means every event
+	queue  chan webhookDelivery
+}
+
+// webhookDelivery is a queued POST
+type webhookDelivery struct {
+	event string
+	body  []byte
+}
+
+// WebhookNotifier POSTs HMAC-signed lifecycle notifications to configured URLs
+// Failed deliveries are retried with exponential backoff; events arriving
+// while a target's queue is full are dropped and logged
+type WebhookNotifier struct {
+	client  *http.Client
+	logger  Logger
+	sleep   func(ctx context.Context, d time.Duration) error
+	targets []*webhookTarget
+}
+
+// NewWebhookNotifier creates a notifier for hooks
+// A nil client uses one with a 10s timeout
+func NewWebhookNotifier(hooks []WebhookConfig, client *http.Client, logger Logger) *WebhookNotifier {
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+	n := &WebhookNotifier{client: client, logger: logger, sleep: sleepContext}
+	for _, cfg := range hooks {
+		t := &webhookTarget{cfg: cfg, queue: make(chan webhookDelivery, webhookQueueSize)}
+		if len(cfg.Events) > 0 {
+			t.events = make(map[string]bool, len(cfg.Events))
+			for _, e := range cfg.Events {
+				t.events[e] = true
+			}
+		}
+		n.targets = append(n.targets, t)
+	}
+	return n
+}
+
+// HandleLifecycle queues notifications for a lifecycle event
+// Subscribe it to Manager.Events
+func (n *WebhookNotifier) HandleLifecycle(event session.LifecycleEvent) {
+	name := webhookEventName(event)
+	if name == "" {
+		return
+	}
+
+	payload := WebhookPayload{
+		Event:     name,
+		Time:      FormatTimestamp(event.Time),
+		SessionID: event.SessionID,
+		AgentID:   event.AgentID,
+		Name:      event.Name,
+		From:      string(event.From),
+		To:        string(event.To),
+		Reason:    event.Reason,
+	}
+	if event.Err != nil {
+		payload.Error = event.Err.Error()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		n.logger.Printf("Webhook marshal failed: %v", err)
+		return
+	}
+
+	for _, t := range n.targets {
+		if t.events != nil && !t.events[name] {
+			continue
+		}
+		select {
+		case t.queue <- webhookDelivery{event: name, body: body}:
+		default:
+			n.logger.Printf("Webhook queue full, dropping %s for %s", name, t.cfg.URL)
+		}
+	}
+}
+
+// Run delivers queued notifications until ctx is done
+func (n *WebhookNotifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range n.targets {
+		wg.Add(1)
+		go func(t *webhookTarget) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d := <-t.queue:
+					n.deliver(ctx, t.cfg, d)
+				}
+			}
+		}(t)
+	}
+	wg.Wait()
+}
+
+// deliver POSTs one notification, retrying with exponential backoff
+// Network errors, 429 and 5xx responses are retried; other statuses are final
+func (n *WebhookNotifier) deliver(ctx context.Context, cfg WebhookConfig, d webhookDelivery) {
+	attempts := cfg.MaxAttempts
+	if attempts < 1 {
+		attempts = defaultWebhookAttempts
+	}
+	backoff := time.Duration(cfg.Backoff)
+	if backoff <= 0 {
+		backoff = defaultWebhookBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, cfg, d)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= attempts {
+			n.logger.Printf("Webhook %s to %s failed after %d attempts: %v", d.event, cfg.URL, attempt, err)
+			return
+		}
+		if n.sleep(ctx, backoff) != nil {
+			return
+		}
+		backoff = min(backoff*2, maxWebhookBackoff)
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth retrying
+func (n *WebhookNotifier) post(ctx context.Context, cfg WebhookConfig, d webhookDelivery) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, d.event)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(cfg.Secret, d.body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Allow connection reuse
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,169 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2389-research/ourocodus/pkg/relay/session"
+)
+
+// webhookReceiver records requests and answers with scripted statuses
+type webhookReceiver struct {
+	statuses []int // Consumed per request; 200 once exhausted
+	requests []*http.Request
+	bodies   [][]byte
+	mu       sync.Mutex
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *webhookReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// newTestWebhookNotifier records backoff delays instead of sleeping
+func newTestWebhookNotifier(hooks []WebhookConfig) (*WebhookNotifier, *[]time.Duration) {
+	n := NewWebhookNotifier(hooks, nil, &mockLogger{})
+	var delays []time.Duration
+	n.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return n, &delays
+}
+
+func TestWebhookEventName(t *testing.T) {
+	tests := []struct {
+		event session.LifecycleEvent
+		want  string
+	}{
+		{session.LifecycleEvent{Event: session.EventCreate, To: session.StateCreated}, WebhookSessionCreated},
+		{session.LifecycleEvent{Event: session.EventTerminate, To: session.StateTerminating, Err: errors.New("crash")}, WebhookAgentFailed},
+		{session.LifecycleEvent{Event: session.EventTerminate, To: session.StateTerminating}, WebhookSessionTerminated},
+		{session.LifecycleEvent{Event: session.EventActivate, To: session.StateActive}, ""},
+		{session.LifecycleEvent{Event: session.EventClean, To: session.StateCleaned}, ""},
+	}
+	for _, tt := range tests {
+		if got := webhookEventName(tt.event); got != tt.want {
+			t.Errorf("%s → %s: expected %q, got %q", tt.event.Event, tt.event.To, tt.want, got)
+		}
+	}
+}
+
+func TestWebhookNotifier_DeliversSignedPayload(t *testing.T) {
+	receiver := &webhookReceiver{}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	hook := WebhookConfig{URL: srv.URL, Secret: "s3cret"}
+	n, _ := newTestWebhookNotifier([]WebhookConfig{hook})
+	n.HandleLifecycle(session.LifecycleEvent{
+		Time:      testTime,
+		Err:       errors.New("agent crashed"),
+		SessionID: "session-1",
+		AgentID:   "auth",
+		From:      session.StateActive,
+		To:        session.StateTerminating,
+		Event:     session.EventTerminate,
+	})
+	n.deliver(context.Background(), hook, <-n.targets[0].queue)
+
+	if receiver.count() != 1 {
+		t.Fatalf("expected 1 request, got %d", receiver.count())
+	}
+	req, body := receiver.requests[0], receiver.bodies[0]
+	if req.Header.Get(WebhookEventHeader) != WebhookAgentFailed {
+		t.Errorf("expected event header %s, got %q", WebhookAgentFailed, req.Header.Get(WebhookEventHeader))
+	}
+	if req.Header.Get(WebhookSignatureHeader) != SignWebhook("s3cret", body) {
+		t.Error("signature does not match body")
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if payload.SessionID != "session-1" || payload.Error != "agent crashed" || payload.Time != "2025-10-23T12:00:00Z" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestWebhookNotifier_RetriesWithBackoff(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{500, 429, 200}}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	hook := WebhookConfig{URL: srv.URL, Secret: "s", Backoff: Duration(time.Second)}
+	n, delays := newTestWebhookNotifier([]WebhookConfig{hook})
+	n.deliver(context.Background(), hook, webhookDelivery{event: WebhookSessionCreated, body: []byte(`{}`)})
+
+	if receiver.count() != 3 {
+		t.Errorf("expected 3 attempts, got %d", receiver.count())
+	}
+	if len(*delays) != 2 || (*delays)[0] != time.Second || (*delays)[1] != 2*time.Second {
+		t.Errorf("expected exponential backoff [1s 2s], got %v", *delays)
+	}
+}
+
+func TestWebhookNotifier_StopsRetrying(t *testing.T) {
+	t.Run("client error is final", func(t *testing.T) {
+		receiver := &webhookReceiver{statuses: []int{400}}
+		srv := httptest.NewServer(receiver)
+		defer srv.Close()
+
+		hook := WebhookConfig{URL: srv.URL, Secret: "s"}
+		n, _ := newTestWebhookNotifier([]WebhookConfig{hook})
+		n.deliver(context.Background(), hook, webhookDelivery{body: []byte(`{}`)})
+		if receiver.count() != 1 {
+			t.Errorf("expected no retry after 400, got %d attempts", receiver.count())
+		}
+	})
+
+	t.Run("attempts are bounded", func(t *testing.T) {
+		receiver := &webhookReceiver{statuses: []int{503, 503, 503, 503}}
+		srv := httptest.NewServer(receiver)
+		defer srv.Close()
+
+		hook := WebhookConfig{URL: srv.URL, Secret: "s", MaxAttempts: 2}
+		n, _ := newTestWebhookNotifier([]WebhookConfig{hook})
+		n.deliver(context.Background(), hook, webhookDelivery{body: []byte(`{}`)})
+		if receiver.count() != 2 {
+			t.Errorf("expected 2 attempts, got %d", receiver.count())
+		}
+	})
+}
+
+func TestWebhookNotifier_FiltersEvents(t *testing.T) {
+	n, _ := newTestWebhookNotifier([]WebhookConfig{
+		{URL: "http://a", Secret: "s", Events: []string{WebhookAgentFailed}},
+		{URL: "http://b", Secret: "s"},
+	})
+
+	n.HandleLifecycle(session.LifecycleEvent{Event: session.EventCreate, To: session.StateCreated})
+	n.HandleLifecycle(session.LifecycleEvent{Event: session.EventActivate, To: session.StateActive})
+
+	if len(n.targets[0].queue) != 0 || len(n.targets[1].queue) != 1 {
+		t.Errorf("expected only the unfiltered hook to get session.created, got %d and %d",
+			len(n.targets[0].queue), len(n.targets[1].queue))
+	}
+}
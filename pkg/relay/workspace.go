@@ -0,0 +1,204 @@
+package relay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Workspace seeding modes: how files reach a workspace from its snapshot
+const (
+	SeedReflink  = "reflink"  // Copy-on-write clones where the filesystem supports them, else copies
+	SeedHardlink = "hardlink" // Hard links to read-only snapshot files, else copies
+	SeedCopy     = "copy"     // Plain copies
+)
+
+// WorkspaceSeeder populates a new agent workspace from a base directory
+type WorkspaceSeeder interface {
+	Seed(ctx context.Context, base, workspace string) error
+}
+
+// WorkspaceCache seeds workspaces from snapshots of base directories kept
+// under one cache directory
+// The base is read once per snapshot, and with reflink or hardlink seeding
+// every workspace shares the snapshot's blocks until it changes them, so
+// spawning several agents on one repo doesn't copy the tree several times.
+// In hardlink mode snapshot files are made read-only, since an in-place
+// write through one link would change every workspace; agents can still
+// replace files (write a new file and rename it over the old one).
+// Snapshots are reused until Invalidate is called or they are deleted from
+// the cache directory.
+type WorkspaceCache struct {
+	dir    string
+	mode   string
+	logger Logger
+	mu     sync.Mutex // Serializes snapshot creation
+}
+
+// NewWorkspaceCache keeps snapshots under dir and seeds with mode
+// An empty mode means SeedReflink
+func NewWorkspaceCache(dir, mode string, logger Logger) *WorkspaceCache {
+	if mode == "" {
+		mode = SeedReflink
+	}
+	return &WorkspaceCache{dir: dir, mode: mode, logger: logger}
+}
+
+// Seed creates workspace, which must not exist, from the snapshot of base,
+// taking the snapshot first if there is none
+func (c *WorkspaceCache) Seed(ctx context.Context, base, workspace string) error {
+	snapshot, err := c.snapshot(ctx, base)
+	if err != nil {
+		return err
+	}
+	// Creating the root first claims it; anything already there is left alone
+	if err := os.Mkdir(workspace, 0o750); err != nil {
+		return fmt.Errorf("seed workspace: %w", err)
+	}
+	if err := cloneTree(ctx, snapshot, workspace, c.mode, false); err != nil {
+		_ = os.RemoveAll(workspace)
+		return fmt.Errorf("seed workspace %s: %w", workspace, err)
+	}
+	return nil
+}
+
+// Invalidate drops the snapshot of base; the next Seed takes a fresh one
+// Workspaces already seeded from it are unaffected
+func (c *WorkspaceCache) Invalidate(base string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return os.RemoveAll(c.snapshotPath(base))
+}
+
+// snapshotPath is where the snapshot of base lives
+// The mode is part of the key because hardlink snapshots are read-only
+func (c *WorkspaceCache) snapshotPath(base string) string {
+	sum := sha256.Sum256([]byte(c.mode + "\x00" + filepath.Clean(base)))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:8]))
+}
+
+// snapshot returns the snapshot of base, taking it if needed
+// The snapshot is staged in a temporary directory and renamed into place, so
+// an interrupted snapshot is never mistaken for a complete one.
+func (c *WorkspaceCache) snapshot(ctx context.Context, base string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.snapshotPath(base)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("workspace cache: %w", err)
+	}
+
+	if err := os.MkdirAll(c.dir, 0o750); err != nil {
+		return "", fmt.Errorf("workspace cache: %w", err)
+	}
+	staging, err := os.MkdirTemp(c.dir, ".snapshot-")
+	if err != nil {
+		return "", fmt.Errorf("workspace cache: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	// The base is a live tree, so it is never hard linked into the snapshot
+	mode := c.mode
+	if mode == SeedHardlink {
+		mode = SeedReflink
+	}
+	tree := filepath.Join(staging, "tree")
+	if err := os.Mkdir(tree, 0o750); err != nil {
+		return "", fmt.Errorf("workspace cache: %w", err)
+	}
+	if err := cloneTree(ctx, base, tree, mode, c.mode == SeedHardlink); err != nil {
+		return "", fmt.Errorf("snapshot %s: %w", base, err)
+	}
+	if err := os.Rename(tree, path); err != nil {
+		return "", fmt.Errorf("workspace cache: %w", err)
+	}
+	c.logger.Printf("Workspace snapshot taken: base=%s path=%s", base, path)
+	return path, nil
+}
+
+// cloneTree recreates the src tree in dst, an existing empty directory
+// Regular files are cloned with mode, symlinks are recreated and other
+// special files are skipped. readOnly clears write permission on files.
+func cloneTree(ctx context.Context, src, dst, mode string, readOnly bool) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			perm := info.Mode().Perm() | 0o700
+			if rel == "." {
+				return os.Chmod(target, perm)
+			}
+			// #nosec G301 -- Mirrors the source tree's permissions
+			return os.Mkdir(target, perm)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			perm := info.Mode().Perm()
+			if readOnly {
+				perm &^= 0o222
+			}
+			return cloneFile(path, target, perm, mode)
+		}
+		return nil
+	})
+}
+
+// cloneFile creates dst with src's contents, sharing storage as mode allows
+func cloneFile(src, dst string, perm fs.FileMode, mode string) error {
+	if mode == SeedHardlink {
+		if err := os.Link(src, dst); err == nil {
+			return nil
+		}
+		// Other filesystem, or links unsupported: copy instead
+	}
+
+	// #nosec G304 -- src is inside the tree being cloned
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	// #nosec G304 -- dst is inside the workspace being created
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if mode == SeedReflink && reflink(out, in) == nil {
+		return out.Close()
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
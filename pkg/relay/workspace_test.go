@@ -0,0 +1,155 @@
+package relay
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeBase creates a small repo-like tree to seed from
+func writeBase(t *testing.T) string {
+	t.Helper()
+	base := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(base, "src", "pkg"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "README.md"), []byte("readme"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "src", "pkg", "main.go"), []byte("package main"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("src/pkg/main.go", filepath.Join(base, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+	return base
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path) // #nosec G304 -- test path
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWorkspaceCache_Seed(t *testing.T) {
+	for _, mode := range []string{SeedReflink, SeedHardlink, SeedCopy} {
+		t.Run(mode, func(t *testing.T) {
+			base := writeBase(t)
+			cache := NewWorkspaceCache(t.TempDir(), mode, &mockLogger{})
+			workspace := filepath.Join(t.TempDir(), "agent-1")
+
+			if err := cache.Seed(context.Background(), base, workspace); err != nil {
+				t.Fatalf("Seed failed: %v", err)
+			}
+			if got := readFile(t, filepath.Join(workspace, "src", "pkg", "main.go")); got != "package main" {
+				t.Errorf("expected seeded file, got %q", got)
+			}
+			if link, err := os.Readlink(filepath.Join(workspace, "link.go")); err != nil || link != "src/pkg/main.go" {
+				t.Errorf("expected symlink to be recreated, got %q (%v)", link, err)
+			}
+		})
+	}
+}
+
+func TestWorkspaceCache_ReusesSnapshot(t *testing.T) {
+	ctx := context.Background()
+	base := writeBase(t)
+	cache := NewWorkspaceCache(t.TempDir(), SeedCopy, &mockLogger{})
+	parent := t.TempDir()
+
+	if err := cache.Seed(ctx, base, filepath.Join(parent, "a")); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	// Changes to the base after the snapshot don't reach later workspaces
+	if err := os.WriteFile(filepath.Join(base, "README.md"), []byte("changed"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Seed(ctx, base, filepath.Join(parent, "b")); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(parent, "b", "README.md")); got != "readme" {
+		t.Errorf("expected snapshot contents, got %q", got)
+	}
+
+	if err := cache.Invalidate(base); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	if err := cache.Seed(ctx, base, filepath.Join(parent, "c")); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(parent, "c", "README.md")); got != "changed" {
+		t.Errorf("expected fresh snapshot after Invalidate, got %q", got)
+	}
+}
+
+func TestWorkspaceCache_WorkspacesAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	base := writeBase(t)
+	cache := NewWorkspaceCache(t.TempDir(), SeedReflink, &mockLogger{})
+	a, b := filepath.Join(t.TempDir(), "a"), filepath.Join(t.TempDir(), "b")
+	if err := cache.Seed(ctx, base, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Seed(ctx, base, b); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(a, "README.md"), []byte("edited by a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(b, "README.md")); got != "readme" {
+		t.Errorf("expected b unaffected by a's edit, got %q", got)
+	}
+	if got := readFile(t, filepath.Join(base, "README.md")); got != "readme" {
+		t.Errorf("expected base unaffected, got %q", got)
+	}
+}
+
+func TestWorkspaceCache_HardlinkSnapshotIsReadOnly(t *testing.T) {
+	base := writeBase(t)
+	cache := NewWorkspaceCache(t.TempDir(), SeedHardlink, &mockLogger{})
+	workspace := filepath.Join(t.TempDir(), "agent")
+	if err := cache.Seed(context.Background(), base, workspace); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(workspace, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o222 != 0 {
+		t.Errorf("expected read-only linked file, got %v", info.Mode().Perm())
+	}
+
+	// Replacing a file breaks the link instead of changing the snapshot
+	replacement := filepath.Join(workspace, "README.md.tmp")
+	if err := os.WriteFile(replacement, []byte("new"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replacement, filepath.Join(workspace, "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(t.TempDir(), "other")
+	if err := cache.Seed(context.Background(), base, other); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(other, "README.md")); got != "readme" {
+		t.Errorf("expected snapshot unchanged, got %q", got)
+	}
+}
+
+func TestWorkspaceCache_ExistingWorkspace(t *testing.T) {
+	cache := NewWorkspaceCache(t.TempDir(), SeedCopy, &mockLogger{})
+	existing := t.TempDir()
+
+	if err := cache.Seed(context.Background(), writeBase(t), existing); err == nil {
+		t.Fatal("expected seeding an existing directory to fail")
+	}
+	if _, err := os.Stat(existing); err != nil {
+		t.Errorf("expected existing directory to be left alone, got %v", err)
+	}
+}
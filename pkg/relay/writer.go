@@ -0,0 +1,183 @@
+package relay
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrConnectionClosed is returned for writes queued on a closed connection
+var ErrConnectionClosed = errors.New("connection closed")
+
+// frameWriteTimeout bounds how long one frame may take to write before the
+// client is considered stalled and its connection closed
+const frameWriteTimeout = 10 * time.Second
+
+// dataMessageTypes are bulk agent output; everything else is control traffic
+// (errors, cancellations, state changes, replies to client requests)
+var dataMessageTypes = map[string]bool{
+	"agent:delta":    true,
+	"agent:complete": true,
+}
+
+// isDataMessage reports whether v belongs in the data lane (pure function)
+func isDataMessage(v interface{}) bool {
+	typed, ok := v.(interface{ MessageType() string })
+	return ok && dataMessageTypes[typed.MessageType()]
+}
+
+// outbound is one queued write and the channel its result is reported on
+type outbound struct {
+	v    interface{}
+	done chan error
+}
+
+// priorityWriter writes JSON messages from a single goroutine with two lanes
+// The control lane is drained before each data write, so a flood of agent
+// output never delays an error or cancellation. WriteJSON blocks until its
+// message is written, which keeps each caller's messages in order and
+// applies backpressure to fast agents. WebSocket ping and close frames are
+// sent with WriteControl and bypass the lanes entirely.
+type priorityWriter struct {
+	write   func(v interface{}) error
+	control chan outbound
+	data    chan outbound
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// newPriorityWriter starts a writer that sends messages with write
+func newPriorityWriter(write func(v interface{}) error) *priorityWriter {
+	w := &priorityWriter{
+		write:   write,
+		control: make(chan outbound),
+		data:    make(chan outbound),
+		stop:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// WriteJSON queues v in its lane and waits for it to be written
+func (w *priorityWriter) WriteJSON(v interface{}) error {
+	lane := w.control
+	if isDataMessage(v) {
+		lane = w.data
+	}
+	req := outbound{v: v, done: make(chan error, 1)}
+	select {
+	case lane <- req:
+	case <-w.stop:
+		return ErrConnectionClosed
+	}
+	return <-req.done
+}
+
+// Close stops the writer; queued and later writes fail with ErrConnectionClosed
+func (w *priorityWriter) Close() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+// run writes queued messages with strict priority for the control lane
+func (w *priorityWriter) run() {
+	for {
+		select {
+		case req := <-w.control:
+			req.done <- w.write(req.v)
+			continue
+		case <-w.stop:
+			return
+		default:
+		}
+
+		select {
+		case req := <-w.control:
+			req.done <- w.write(req.v)
+		case req := <-w.data:
+			req.done <- w.write(req.v)
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// priorityConn is a connection whose JSON writes go through a priorityWriter,
+// since gorilla supports only one concurrent writer and the server, the
+// session manager and agent replies all write from their own goroutines
+// Each frame is written with a deadline of writeTimeout; a client that stops
+// reading would otherwise hold the writer, and every reply queued behind it,
+// forever. When the deadline passes the connection is closed, failing the
+// queued writes and ending the read loop.
+// SetWriteDeadline waits for the write in progress, as gorilla requires.
+// Reads, Close and WriteControl are promoted: gorilla allows them concurrently.
+type priorityConn struct {
+	WebSocketConn
+	writer       *priorityWriter
+	writeMu      sync.Mutex // Held by the writer goroutine while it writes
+	writeTimeout time.Duration
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newPriorityConn(conn WebSocketConn) *priorityConn {
+	c := &priorityConn{WebSocketConn: conn, writeTimeout: frameWriteTimeout}
+	c.writer = newPriorityWriter(c.write)
+	return c
+}
+
+// serializeWrites returns conn with its writes serialized, wrapping it
+// unless it already is
+// Every connection the server handles goes through it, so custom upgraders
+// need not guard against concurrent writers themselves.
+func serializeWrites(conn WebSocketConn) WebSocketConn {
+	if pc, ok := conn.(*priorityConn); ok {
+		return pc
+	}
+	return newPriorityConn(conn)
+}
+
+func (c *priorityConn) write(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	select {
+	case <-c.writer.stop:
+		return ErrConnectionClosed // Closed while this write was queued
+	default:
+	}
+	if err := c.WebSocketConn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+		return err
+	}
+	err := c.WebSocketConn.WriteJSON(v)
+	if isTimeout(err) {
+		_ = c.Close()
+	}
+	return err
+}
+
+// isTimeout reports whether err is a passed deadline (pure function)
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (c *priorityConn) WriteJSON(v interface{}) error {
+	return c.writer.WriteJSON(v)
+}
+
+func (c *priorityConn) SetWriteDeadline(t time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.WebSocketConn.SetWriteDeadline(t)
+}
+
+// Close stops the writer and closes the connection; later calls return the
+// first call's result
+func (c *priorityConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.writer.Close()
+		c.closeErr = c.WebSocketConn.Close()
+	})
+	return c.closeErr
+}
@@ -0,0 +1,226 @@
+package relay
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedWriter records writes, blocking each until the test lets it through
+type gatedWriter struct {
+	entered chan struct{}
+	gate    chan struct{}
+	written []string
+	mu      sync.Mutex
+}
+
+func (g *gatedWriter) write(v interface{}) error {
+	g.entered <- struct{}{}
+	<-g.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.written = append(g.written, v.(interface{ MessageType() string }).MessageType())
+	return nil
+}
+
+func TestPriorityWriter_ControlOvertakesQueuedData(t *testing.T) {
+	g := &gatedWriter{entered: make(chan struct{}, 10), gate: make(chan struct{})}
+	w := newPriorityWriter(g.write)
+	defer w.Close()
+
+	var wg sync.WaitGroup
+	send := func(msg interface{}) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.WriteJSON(msg); err != nil {
+				t.Errorf("WriteJSON failed: %v", err)
+			}
+		}()
+	}
+
+	// Occupy the writer with one delta, then queue more output and a cancel
+	send(NewAgentDeltaMessage("s", "r", 1, "a"))
+	<-g.entered
+	send(NewAgentDeltaMessage("s", "r", 2, "b"))
+	send(NewAgentCompleteMessage("s", "r", 2, nil, "", nil))
+	time.Sleep(20 * time.Millisecond) // Let the data writes queue up
+	send(NewErrorMessage("UNKNOWN_REQUEST", "no such request", true))
+	time.Sleep(20 * time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		g.gate <- struct{}{}
+		if i < 3 {
+			<-g.entered
+		}
+	}
+	wg.Wait()
+
+	if len(g.written) != 4 || g.written[0] != "agent:delta" || g.written[1] != "error" {
+		t.Errorf("expected the error right after the in-flight delta, got %v", g.written)
+	}
+}
+
+func TestPriorityWriter_Close(t *testing.T) {
+	w := newPriorityWriter(func(v interface{}) error { return nil })
+	if err := w.WriteJSON(NewErrorMessage("X", "x", true)); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	w.Close()
+	w.Close() // Idempotent
+	if err := w.WriteJSON(NewAgentDeltaMessage("s", "r", 1, "a")); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed, got %v", err)
+	}
+}
+
+func TestIsDataMessage(t *testing.T) {
+	tests := []struct {
+		msg  interface{}
+		want bool
+	}{
+		{NewAgentDeltaMessage("s", "r", 1, "a"), true},
+		{NewAgentCompleteMessage("s", "r", 1, nil, "", nil), true},
+		{NewAgentCancelledMessage("s", "r", 1, ""), false},
+		{NewErrorMessage("X", "x", true), false},
+		{map[string]interface{}{"type": "agent:delta"}, false}, // Echoed client input
+	}
+	for _, tt := range tests {
+		if got := isDataMessage(tt.msg); got != tt.want {
+			t.Errorf("isDataMessage(%T) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+// overlapConn fails the test if two goroutines are inside its write methods at once
+type overlapConn struct {
+	mockWebSocketConn
+	t       *testing.T
+	writing atomic.Int32
+	writes  atomic.Int64
+}
+
+func (c *overlapConn) enter() func() {
+	if c.writing.Add(1) != 1 {
+		c.t.Error("concurrent writes on the connection")
+	}
+	return func() { c.writing.Add(-1) }
+}
+
+func (c *overlapConn) WriteJSON(v interface{}) error {
+	defer c.enter()()
+	time.Sleep(time.Microsecond) // Widen the window for overlapping writers
+	c.writes.Add(1)
+	return nil
+}
+
+func (c *overlapConn) SetWriteDeadline(t time.Time) error {
+	defer c.enter()()
+	return nil
+}
+
+func TestPriorityConn_SerializesConcurrentWriters(t *testing.T) {
+	const (
+		writers = 20
+		each    = 50
+	)
+	raw := &overlapConn{t: t}
+	conn := serializeWrites(raw)
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < each; j++ {
+				var msg interface{} = NewErrorMessage("X", "x", true)
+				if j%2 == 0 {
+					msg = NewAgentDeltaMessage("s", "r", j, "a")
+				}
+				if err := conn.WriteJSON(msg); err != nil {
+					t.Errorf("WriteJSON failed: %v", err)
+				}
+				if j%10 == 0 {
+					_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := raw.writes.Load(); n != writers*each {
+		t.Errorf("expected %d writes, got %d", writers*each, n)
+	}
+}
+
+func TestSerializeWrites_WrapsOnce(t *testing.T) {
+	conn := serializeWrites(&mockWebSocketConn{})
+	defer conn.Close()
+	if again := serializeWrites(conn); again != conn {
+		t.Error("expected an already serialized connection to be returned as is")
+	}
+}
+
+// stalledConn is a client that never reads: each write blocks until its
+// deadline passes
+type stalledConn struct {
+	mockWebSocketConn
+	mu       sync.Mutex
+	deadline time.Time
+	closed   chan struct{}
+}
+
+func (c *stalledConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func (c *stalledConn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+	if deadline.IsZero() {
+		select {} // No deadline: stalled for good
+	}
+	time.Sleep(time.Until(deadline))
+	return os.ErrDeadlineExceeded
+}
+
+func (c *stalledConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestPriorityConn_ClosesStalledClient(t *testing.T) {
+	raw := &stalledConn{closed: make(chan struct{})}
+	conn := newPriorityConn(raw)
+	conn.writeTimeout = 50 * time.Millisecond
+
+	results := make(chan error, 3)
+	for i := 1; i <= 3; i++ {
+		go func(i int) { results <- conn.WriteJSON(NewAgentDeltaMessage("s", "r", i, "a")) }(i)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-results:
+			if !errors.Is(err, os.ErrDeadlineExceeded) && !errors.Is(err, ErrConnectionClosed) {
+				t.Errorf("expected a timeout or ErrConnectionClosed, got %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("writers still blocked on a client that stopped reading")
+		}
+	}
+	select {
+	case <-raw.closed:
+	default:
+		t.Error("expected the stalled connection closed")
+	}
+	if err := conn.WriteJSON(NewErrorMessage("X", "x", true)); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed after the stall, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+// Package report renders the outcome of a scripted run (a job, the smoke
+// test) as JSON or JUnit XML for CI systems, and maps it to an exit code
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Step statuses
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// Exit codes for CI: a run that failed is distinguished from one that could
+// not run at all
+const (
+	ExitPassed = 0 // Every step passed
+	ExitFailed = 1 // The run completed but a step failed
+	ExitUsage  = 2 // Bad arguments or configuration
+	ExitError  = 3 // The run could not be carried out, e.g. the relay is unreachable
+)
+
+// Formats accepted by Write
+const (
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
+// Report is the outcome of one run
+type Report struct {
+	Name     string        `json:"name"`
+	Steps    []Step        `json:"steps"`
+	Duration time.Duration `json:"-"`
+}
+
+// Step is one stage of a run
+// Message says why a step failed or was skipped; Output is anything worth
+// keeping from it, e.g. an agent's reply.
+type Step struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Message  string        `json:"message,omitempty"`
+	Output   string        `json:"output,omitempty"`
+	Duration time.Duration `json:"-"`
+}
+
+// Add appends a step, passed if err is nil and failed otherwise
+// The returned step may be updated until the next step is added.
+func (r *Report) Add(name string, d time.Duration, err error) *Step {
+	step := Step{Name: name, Status: StatusPassed, Duration: d}
+	if err != nil {
+		step.Status, step.Message = StatusFailed, err.Error()
+	}
+	r.Steps = append(r.Steps, step)
+	return &r.Steps[len(r.Steps)-1]
+}
+
+// Skip appends a step that was not run
+func (r *Report) Skip(name, reason string) {
+	r.Steps = append(r.Steps, Step{Name: name, Status: StatusSkipped, Message: reason})
+}
+
+// Count returns how many steps have status (pure function)
+func (r *Report) Count(status string) int {
+	n := 0
+	for _, step := range r.Steps {
+		if step.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Passed reports whether no step failed (pure function)
+func (r *Report) Passed() bool {
+	return r.Count(StatusFailed) == 0
+}
+
+// ExitCode returns ExitPassed or ExitFailed (pure function)
+func (r *Report) ExitCode() int {
+	if r.Passed() {
+		return ExitPassed
+	}
+	return ExitFailed
+}
+
+// Write renders the report to w as FormatJSON or FormatJUnit
+func Write(w io.Writer, format string, r *Report) error {
+	switch format {
+	case FormatJSON:
+		return WriteJSON(w, r)
+	case FormatJUnit:
+		return WriteJUnit(w, r)
+	}
+	return fmt.Errorf("unknown report format %q (want %q or %q)", format, FormatJSON, FormatJUnit)
+}
+
+// jsonReport is the JSON form of a Report, with durations in milliseconds
+type jsonReport struct {
+	Name       string     `json:"name"`
+	Passed     bool       `json:"passed"`
+	DurationMs int64      `json:"durationMs"`
+	Steps      []jsonStep `json:"steps"`
+}
+
+type jsonStep struct {
+	Step
+	DurationMs int64 `json:"durationMs"`
+}
+
+// WriteJSON renders the report as indented JSON
+func WriteJSON(w io.Writer, r *Report) error {
+	out := jsonReport{Name: r.Name, Passed: r.Passed(), DurationMs: r.Duration.Milliseconds(), Steps: make([]jsonStep, len(r.Steps))}
+	for i, step := range r.Steps {
+		out.Steps[i] = jsonStep{Step: step, DurationMs: step.Duration.Milliseconds()}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// junitSuites is the JUnit XML root; one suite per report
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit renders the report as JUnit XML, one test case per step
+func WriteJUnit(w io.Writer, r *Report) error {
+	suite := junitSuite{
+		Name:     r.Name,
+		Tests:    len(r.Steps),
+		Failures: r.Count(StatusFailed),
+		Skipped:  r.Count(StatusSkipped),
+		Time:     seconds(r.Duration),
+		Cases:    make([]junitCase, len(r.Steps)),
+	}
+	for i, step := range r.Steps {
+		c := junitCase{Name: step.Name, ClassName: r.Name, Time: seconds(step.Duration), SystemOut: step.Output}
+		switch step.Status {
+		case StatusFailed:
+			c.Failure = &junitMessage{Message: firstLine(step.Message), Text: step.Message}
+		case StatusSkipped:
+			c.Skipped = &junitMessage{Message: step.Message}
+		}
+		suite.Cases[i] = c
+	}
+	doc := junitSuites{Name: r.Name, Tests: suite.Tests, Failures: suite.Failures, Skipped: suite.Skipped, Time: suite.Time, Suites: []junitSuite{suite}}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// seconds formats d as JUnit's decimal seconds (pure function)
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// firstLine returns s up to its first newline (pure function)
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
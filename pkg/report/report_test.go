@@ -0,0 +1,85 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	r := &Report{Name: "job auth", Duration: 3 * time.Second}
+	r.Add("spawn", 500*time.Millisecond, nil)
+	r.Add("prompt 1", 2*time.Second, errors.New("agent crashed\nexit status 1")).Output = "> hi\n"
+	r.Skip("prompt 2", "an earlier step failed")
+	return r
+}
+
+func TestReport_ExitCode(t *testing.T) {
+	r := &Report{}
+	r.Add("ok", 0, nil)
+	r.Skip("skipped", "not needed")
+	if !r.Passed() || r.ExitCode() != ExitPassed {
+		t.Errorf("expected skipped steps not to fail the run")
+	}
+	if r := testReport(); r.Passed() || r.ExitCode() != ExitFailed {
+		t.Errorf("expected a failed step to fail the run")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJSON, testReport()); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var got struct {
+		Passed     bool `json:"passed"`
+		DurationMs int64
+		Steps      []struct {
+			Name, Status, Message string
+			DurationMs            int64
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if got.Passed || got.DurationMs != 3000 || len(got.Steps) != 3 {
+		t.Fatalf("unexpected report: %+v", got)
+	}
+	if s := got.Steps[1]; s.Status != StatusFailed || s.DurationMs != 2000 || !strings.HasPrefix(s.Message, "agent crashed") {
+		t.Errorf("unexpected failed step: %+v", s)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJUnit, testReport()); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var got junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+	if got.Tests != 3 || got.Failures != 1 || got.Skipped != 1 || got.Time != "3.000" || len(got.Suites) != 1 {
+		t.Fatalf("unexpected totals: %+v", got)
+	}
+	cases := got.Suites[0].Cases
+	if cases[0].Failure != nil || cases[0].Time != "0.500" {
+		t.Errorf("expected a passed case, got %+v", cases[0])
+	}
+	if f := cases[1].Failure; f == nil || f.Message != "agent crashed" || f.Text != "agent crashed\nexit status 1" || cases[1].SystemOut != "> hi\n" {
+		t.Errorf("expected a failure with its first line as message, got %+v", cases[1])
+	}
+	if cases[2].Skipped == nil {
+		t.Errorf("expected a skipped case, got %+v", cases[2])
+	}
+}
+
+func TestWrite_UnknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, "tap", &Report{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,177 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxFileBytes caps file reads and writes unless a FileOption changes it
+const DefaultMaxFileBytes = 1 << 20
+
+var (
+	// ErrNotRegularFile is returned for directories, devices, sockets and pipes
+	ErrNotRegularFile = errors.New("not a regular file")
+
+	// ErrFileTooLarge is returned for reads and writes over the guard's cap
+	ErrFileTooLarge = errors.New("file is too large")
+)
+
+// FileGuard confines the file reads and writes of agent tool calls to a
+// workspace
+// Paths are resolved through symlinks before they are checked, so links
+// pointing out of the workspace are refused, and only regular files are
+// read or written. A link swapped in between the check and the open can
+// still escape; the guard stops mistakes and careless agents, not a hostile
+// process sharing the workspace. Safe for concurrent use.
+type FileGuard struct {
+	maxRead  int64
+	maxWrite int64
+}
+
+// FileOption configures a FileGuard
+type FileOption func(*FileGuard)
+
+// WithMaxRead refuses to read files larger than n bytes
+func WithMaxRead(n int64) FileOption {
+	return func(g *FileGuard) { g.maxRead = n }
+}
+
+// WithMaxWrite refuses to write more than n bytes at once
+func WithMaxWrite(n int64) FileOption {
+	return func(g *FileGuard) { g.maxWrite = n }
+}
+
+// NewFileGuard creates a guard capping reads and writes at
+// DefaultMaxFileBytes unless configured otherwise
+func NewFileGuard(opts ...FileOption) *FileGuard {
+	g := &FileGuard{maxRead: DefaultMaxFileBytes, maxWrite: DefaultMaxFileBytes}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Resolve returns the absolute path name refers to inside workspace,
+// following symlinks
+// name is relative to the workspace, or absolute inside it. The file need
+// not exist but its directory must. Returns ErrOutsideWorkspace for paths
+// that are, or resolve to, anywhere else.
+func (g *FileGuard) Resolve(workspace, name string) (string, error) {
+	root, err := workspaceRoot(workspace)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(root, name)
+	}
+	name = filepath.Clean(name)
+	if !within(filepath.Clean(workspace), name) && !within(root, name) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideWorkspace, name)
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(name))
+	if err != nil {
+		return "", fmt.Errorf("invalid directory: %w", err)
+	}
+	path := filepath.Join(dir, filepath.Base(name))
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("invalid path: %w", err)
+	} else if info, lerr := os.Lstat(path); lerr == nil && info.Mode()&fs.ModeSymlink != 0 {
+		return "", fmt.Errorf("%w: %s is a dangling symlink", ErrOutsideWorkspace, name)
+	}
+	if !within(root, path) || path == root {
+		return "", fmt.Errorf("%w: %s", ErrOutsideWorkspace, name)
+	}
+	return path, nil
+}
+
+// ReadFile returns the contents of a regular file inside workspace
+func (g *FileGuard) ReadFile(workspace, name string) ([]byte, error) {
+	path, err := g.Resolve(workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	// Checked before opening: opening a pipe blocks
+	if err := checkRegular(path, name); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path) // #nosec G304 -- path is confined to the workspace by Resolve
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > g.maxRead {
+		return nil, fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrFileTooLarge, name, info.Size(), g.maxRead)
+	}
+	// The file may grow after Stat
+	data, err := io.ReadAll(io.LimitReader(f, g.maxRead+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > g.maxRead {
+		return nil, fmt.Errorf("%w: %s is over the limit of %d bytes", ErrFileTooLarge, name, g.maxRead)
+	}
+	return data, nil
+}
+
+// WriteFile creates or replaces a regular file inside workspace with data
+// Its directory must already exist; new files get mode 0644.
+func (g *FileGuard) WriteFile(workspace, name string, data []byte) error {
+	if int64(len(data)) > g.maxWrite {
+		return fmt.Errorf("%w: writing %d bytes to %s, the limit is %d", ErrFileTooLarge, len(data), name, g.maxWrite)
+	}
+	path, err := g.Resolve(workspace, name)
+	if err != nil {
+		return err
+	}
+	if err := checkRegular(path, name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644) // #nosec G306 -- workspace files are shared with the agent's tools
+}
+
+// checkRegular returns ErrNotRegularFile unless path is a regular file
+// name is the path as given, for the error.
+func checkRegular(path, name string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %s", ErrNotRegularFile, name)
+	}
+	return nil
+}
+
+// workspaceRoot returns the absolute workspace path with symlinks resolved
+func workspaceRoot(workspace string) (string, error) {
+	if !filepath.IsAbs(workspace) {
+		return "", fmt.Errorf("workspace must be an absolute path, got %q", workspace)
+	}
+	root, err := filepath.EvalSymlinks(workspace)
+	if err != nil {
+		return "", fmt.Errorf("invalid workspace: %w", err)
+	}
+	return root, nil
+}
+
+// within reports whether path is root or inside it; both must be clean and
+// absolute (pure function)
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,116 @@
+//go:build !windows
+
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestFileGuard_ReadWrite(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.Mkdir(filepath.Join(workspace, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("src", filepath.Join(workspace, "link")); err != nil {
+		t.Fatal(err)
+	}
+	guard := NewFileGuard()
+
+	if err := guard.WriteFile(workspace, "src/main.go", []byte("package main\n")); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	// Links inside the workspace and absolute paths into it are fine
+	for _, name := range []string{"src/main.go", "link/main.go", filepath.Join(workspace, "src", "main.go")} {
+		data, err := guard.ReadFile(workspace, name)
+		if err != nil || string(data) != "package main\n" {
+			t.Errorf("ReadFile(%q) = %q, %v", name, data, err)
+		}
+	}
+	if _, err := guard.ReadFile(workspace, "src/missing.go"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing file to be reported, got %v", err)
+	}
+}
+
+func TestFileGuard_StaysInWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "secret")
+	if err := os.WriteFile(secret, []byte("hunter2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"escape":   outside,
+		"secret":   secret,
+		"dangling": filepath.Join(outside, "new"),
+	} {
+		if err := os.Symlink(target, filepath.Join(workspace, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	guard := NewFileGuard()
+
+	for _, name := range []string{"../secret", "escape/secret", "secret", "dangling", secret, "src/../../secret", "."} {
+		if _, err := guard.ReadFile(workspace, name); !errors.Is(err, ErrOutsideWorkspace) {
+			t.Errorf("ReadFile(%q): expected ErrOutsideWorkspace, got %v", name, err)
+		}
+		if err := guard.WriteFile(workspace, name, []byte("x")); !errors.Is(err, ErrOutsideWorkspace) {
+			t.Errorf("WriteFile(%q): expected ErrOutsideWorkspace, got %v", name, err)
+		}
+	}
+	if data, _ := os.ReadFile(secret); string(data) != "hunter2" {
+		t.Errorf("file outside the workspace was modified: %q", data)
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "new")); !os.IsNotExist(err) {
+		t.Errorf("expected no file created through the dangling link, got %v", err)
+	}
+	if _, err := guard.ReadFile("relative", "x"); err == nil || !strings.Contains(err.Error(), "absolute") {
+		t.Errorf("expected relative workspaces to be refused, got %v", err)
+	}
+}
+
+func TestFileGuard_RegularFilesOnly(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.Mkdir(filepath.Join(workspace, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(workspace, "pipe"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	guard := NewFileGuard()
+
+	// Opening the pipe would block; the guard must refuse before that
+	for _, name := range []string{"dir", "pipe"} {
+		if _, err := guard.ReadFile(workspace, name); !errors.Is(err, ErrNotRegularFile) {
+			t.Errorf("ReadFile(%q): expected ErrNotRegularFile, got %v", name, err)
+		}
+		if err := guard.WriteFile(workspace, name, []byte("x")); !errors.Is(err, ErrNotRegularFile) {
+			t.Errorf("WriteFile(%q): expected ErrNotRegularFile, got %v", name, err)
+		}
+	}
+}
+
+func TestFileGuard_SizeCaps(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "big"), make([]byte, 11), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	guard := NewFileGuard(WithMaxRead(10), WithMaxWrite(5))
+
+	if _, err := guard.ReadFile(workspace, "big"); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("expected ErrFileTooLarge reading, got %v", err)
+	}
+	if err := guard.WriteFile(workspace, "new", make([]byte, 6)); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("expected ErrFileTooLarge writing, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "new")); !os.IsNotExist(err) {
+		t.Errorf("expected the oversized write to leave no file, got %v", err)
+	}
+	if err := guard.WriteFile(workspace, "new", make([]byte, 5)); err != nil {
+		t.Errorf("expected a write at the cap to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package sandbox
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// prepareCommand runs the command in its own process group and kills the
+// whole group when it is cancelled, so background jobs die with it
+func prepareCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package sandbox
+
+import "os/exec"
+
+// prepareCommand leaves cancellation to exec.CommandContext, which kills
+// only the command itself; processes it started outlive it
+func prepareCommand(*exec.Cmd) {}
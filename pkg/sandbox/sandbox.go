@@ -0,0 +1,197 @@
+// Package sandbox runs the shell commands of approved agent tool calls with
+// a restricted environment, inside the session's workspace, under a timeout
+// and with capped output, and confines their file reads and writes to the
+// workspace
+// Runner confines what a command sees and how long it runs, not what it can
+// reach on the filesystem; run the relay under an OS-level sandbox for that.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Defaults used by New
+const (
+	DefaultTimeout   = 30 * time.Second
+	DefaultMaxOutput = 64 << 10
+	DefaultPath      = "/usr/local/bin:/usr/bin:/bin"
+)
+
+// waitDelay bounds how long Run waits for output after killing a command
+// whose children still hold its pipes open
+const waitDelay = time.Second
+
+// ErrOutsideWorkspace is returned for working directories and file paths that
+// resolve outside the workspace
+var ErrOutsideWorkspace = errors.New("path is outside the workspace")
+
+// Result is the outcome of a command
+// ExitCode is -1 if the command was killed, e.g. on timeout.
+type Result struct {
+	Stdout    string
+	Stderr    string
+	ExitCode  int
+	TimedOut  bool
+	Truncated bool // Stdout or Stderr was cut at the output cap
+	Duration  time.Duration
+}
+
+// Runner runs shell commands in a workspace
+// Safe for concurrent use.
+type Runner struct {
+	shell     []string
+	env       []string
+	timeout   time.Duration
+	maxOutput int
+}
+
+// Option configures a Runner
+type Option func(*Runner)
+
+// WithTimeout kills commands still running after d (DefaultTimeout by default)
+func WithTimeout(d time.Duration) Option {
+	return func(r *Runner) { r.timeout = d }
+}
+
+// WithMaxOutput keeps at most n bytes each of stdout and stderr
+// (DefaultMaxOutput by default)
+func WithMaxOutput(n int) Option {
+	return func(r *Runner) { r.maxOutput = n }
+}
+
+// WithEnv adds KEY=value variables to the environment commands see
+// Commands get only these, PATH (DefaultPath unless given here) and HOME and
+// TMPDIR set to the workspace; nothing is inherited from the relay.
+func WithEnv(vars ...string) Option {
+	return func(r *Runner) { r.env = append(r.env, vars...) }
+}
+
+// WithShell runs commands as path args... command ("/bin/sh -c" by default)
+func WithShell(path string, args ...string) Option {
+	return func(r *Runner) { r.shell = append([]string{path}, args...) }
+}
+
+// New creates a runner
+func New(opts ...Option) *Runner {
+	r := &Runner{
+		shell:     []string{"/bin/sh", "-c"},
+		timeout:   DefaultTimeout,
+		maxOutput: DefaultMaxOutput,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run runs command with the shell in dir, a path relative to workspace
+// ("" = the workspace itself)
+// A command that runs returns a Result whatever its exit code; errors mean it
+// could not be started, e.g. because dir escapes the workspace. The command
+// and every process it starts are killed when the timeout passes or ctx is
+// cancelled.
+func (r *Runner) Run(ctx context.Context, workspace, dir, command string) (*Result, error) {
+	cwd, err := resolveDir(workspace, dir)
+	if err != nil {
+		return nil, err
+	}
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	args := append(append([]string(nil), r.shell[1:]...), command)
+	cmd := exec.CommandContext(ctx, r.shell[0], args...) // #nosec G204 -- running the approved command is the point
+	cmd.Dir = cwd
+	cmd.Env = r.environ(cwd)
+	stdout, stderr := &cappedBuffer{max: r.maxOutput}, &cappedBuffer{max: r.maxOutput}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.WaitDelay = waitDelay
+	prepareCommand(cmd)
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+	waitErr := cmd.Wait()
+	result := &Result{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		ExitCode:  cmd.ProcessState.ExitCode(),
+		TimedOut:  errors.Is(ctx.Err(), context.DeadlineExceeded),
+		Truncated: stdout.truncated || stderr.truncated,
+		Duration:  time.Since(start),
+	}
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) && !errors.Is(waitErr, exec.ErrWaitDelay) {
+		return nil, fmt.Errorf("command failed: %w", waitErr)
+	}
+	return result, nil
+}
+
+// environ returns the environment for a command run in cwd
+func (r *Runner) environ(cwd string) []string {
+	env := []string{"HOME=" + cwd, "TMPDIR=" + cwd}
+	hasPath := false
+	for _, kv := range r.env {
+		hasPath = hasPath || strings.HasPrefix(kv, "PATH=")
+	}
+	if !hasPath {
+		env = append(env, "PATH="+DefaultPath)
+	}
+	return append(env, r.env...)
+}
+
+// resolveDir returns the absolute directory dir names inside workspace,
+// following symlinks, or ErrOutsideWorkspace
+func resolveDir(workspace, dir string) (string, error) {
+	root, err := workspaceRoot(workspace)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(dir) {
+		return "", fmt.Errorf("%w: %s must be relative", ErrOutsideWorkspace, dir)
+	}
+	cwd, err := filepath.EvalSymlinks(filepath.Join(root, dir))
+	if err != nil {
+		return "", fmt.Errorf("invalid directory: %w", err)
+	}
+	if !within(root, cwd) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideWorkspace, dir)
+	}
+	if info, err := os.Stat(cwd); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("invalid directory: %s is not a directory", dir)
+	}
+	return cwd, nil
+}
+
+// cappedBuffer keeps the first max bytes written to it and discards the rest
+// Writes always succeed so the command is not killed by a closed pipe.
+type cappedBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	room := b.max - len(b.buf)
+	if room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf = append(b.buf, p[:room]...)
+		}
+		return len(p), nil
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string { return string(b.buf) }
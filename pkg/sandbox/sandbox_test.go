@@ -0,0 +1,81 @@
+//go:build !windows
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunner_Run(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.Mkdir(filepath.Join(workspace, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RELAY_SECRET", "hunter2")
+	runner := New(WithEnv("GREETING=hi"))
+
+	result, err := runner.Run(context.Background(), workspace, "src", `pwd; echo "$HOME $GREETING $RELAY_SECRET"; echo oops >&2; exit 3`)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	root, _ := filepath.EvalSymlinks(workspace)
+	want := filepath.Join(root, "src") + "\n" + filepath.Join(root, "src") + " hi \n"
+	if result.Stdout != want {
+		t.Errorf("expected stdout %q, got %q", want, result.Stdout)
+	}
+	if result.Stderr != "oops\n" || result.ExitCode != 3 || result.TimedOut || result.Truncated {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestRunner_Run_Timeout(t *testing.T) {
+	runner := New(WithTimeout(100 * time.Millisecond))
+
+	start := time.Now()
+	result, err := runner.Run(context.Background(), t.TempDir(), "", "sleep 10 & sleep 10")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !result.TimedOut || result.ExitCode != -1 {
+		t.Errorf("expected a killed, timed out command, got %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timeout took %v; the command was not killed", elapsed)
+	}
+}
+
+func TestRunner_Run_CapsOutput(t *testing.T) {
+	runner := New(WithMaxOutput(10))
+
+	result, err := runner.Run(context.Background(), t.TempDir(), "", "yes | head -c 100000")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(result.Stdout) != 10 || !result.Truncated || result.ExitCode != 0 {
+		t.Errorf("expected 10 bytes of truncated output, got %+v", result)
+	}
+}
+
+func TestRunner_Run_StaysInWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(workspace, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	runner := New()
+
+	for _, dir := range []string{"..", "../..", "escape", outside} {
+		if _, err := runner.Run(context.Background(), workspace, dir, "true"); !errors.Is(err, ErrOutsideWorkspace) {
+			t.Errorf("dir %q: expected ErrOutsideWorkspace, got %v", dir, err)
+		}
+	}
+	if _, err := runner.Run(context.Background(), "relative", "", "true"); err == nil || !strings.Contains(err.Error(), "absolute") {
+		t.Errorf("expected relative workspaces to be refused, got %v", err)
+	}
+}
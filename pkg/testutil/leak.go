@@ -0,0 +1,182 @@
+// Package testutil holds helpers shared by the repository's tests
+package testutil
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// LeakTimeout is how long checks wait for goroutines and processes to exit
+// before reporting them; shutdowns finish asynchronously
+var LeakTimeout = 2 * time.Second
+
+// ignoredGoroutines are functions whose goroutines belong to the test runner
+// or runtime rather than the code under test
+var ignoredGoroutines = []string{
+	"testing.(*T).Run",
+	"testing.(*M).",
+	"testing.tRunner",
+	"testing.runTests",
+	"testing.(*T).Parallel",
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"runtime.ensureSigM",
+}
+
+// CheckLeaks fails tb if, once it ends, goroutines or child processes started
+// during it are still running
+// Other tests running in parallel start goroutines and processes too, so use
+// it only in tests that do not call t.Parallel; see Main for those.
+func CheckLeaks(tb testing.TB) {
+	tb.Helper()
+	CheckGoroutines(tb)
+	CheckProcesses(tb)
+}
+
+// CheckGoroutines fails tb if goroutines started during it outlive it
+func CheckGoroutines(tb testing.TB) {
+	tb.Helper()
+	before := goroutines()
+	tb.Cleanup(func() {
+		tb.Helper()
+		leaked := waitFor(func() []string {
+			var leaked []string
+			for id, stack := range goroutines() {
+				if _, ok := before[id]; !ok && !ignored(stack) {
+					leaked = append(leaked, stack)
+				}
+			}
+			return leaked
+		})
+		for _, stack := range leaked {
+			tb.Errorf("leaked goroutine:\n%s", stack)
+		}
+	})
+}
+
+// CheckProcesses fails tb if child processes started during it outlive it
+// Children that exited but were never waited for (zombies) count as leaks.
+// On platforms without process listing (see ChildProcesses) it does nothing.
+func CheckProcesses(tb testing.TB) {
+	tb.Helper()
+	initial, err := ChildProcesses()
+	if err != nil {
+		return
+	}
+	before := make(map[int]bool, len(initial))
+	for _, pid := range initial {
+		before[pid] = true
+	}
+	tb.Cleanup(func() {
+		tb.Helper()
+		leaked := waitFor(func() []string {
+			var leaked []string
+			children, _ := ChildProcesses()
+			for _, pid := range children {
+				if !before[pid] {
+					leaked = append(leaked, describeProcess(pid))
+				}
+			}
+			return leaked
+		})
+		for _, proc := range leaked {
+			tb.Errorf("leaked child process: %s", proc)
+		}
+	})
+}
+
+// Main runs a package's tests, then fails the run if they left goroutines or
+// child processes behind
+// Use it from TestMain; it suits packages whose tests run in parallel:
+//
+//	func TestMain(m *testing.M) { testutil.Main(m) }
+func Main(m *testing.M) {
+	code := m.Run()
+	if code == 0 {
+		if leaks := mainLeaks(); len(leaks) > 0 {
+			fmt.Fprintf(os.Stderr, "FAIL: tests leaked %d goroutines or processes:\n\n%s\n", len(leaks), strings.Join(leaks, "\n\n"))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// mainLeaks lists everything still running once all tests have finished
+func mainLeaks() []string {
+	return waitFor(func() []string {
+		var leaks []string
+		for _, stack := range goroutines() {
+			if !ignored(stack) && !strings.Contains(stack, "testutil.mainLeaks") {
+				leaks = append(leaks, stack)
+			}
+		}
+		children, _ := ChildProcesses()
+		for _, pid := range children {
+			leaks = append(leaks, "child process "+describeProcess(pid))
+		}
+		return leaks
+	})
+}
+
+// waitFor polls check until it finds nothing or LeakTimeout passes, and
+// returns its last findings
+func waitFor(check func() []string) []string {
+	deadline := time.Now().Add(LeakTimeout)
+	for {
+		found := check()
+		if len(found) == 0 || time.Now().After(deadline) {
+			sort.Strings(found)
+			return found
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// goroutines returns the stack of every goroutine, keyed by goroutine ID
+func goroutines() map[uint64]string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := make(map[uint64]string)
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		header, _, _ := bytes.Cut(stack, []byte("\n"))
+		// "goroutine 18 [running]:"
+		fields := bytes.Fields(header)
+		if len(fields) < 2 || string(fields[0]) != "goroutine" {
+			continue
+		}
+		id, err := strconv.ParseUint(string(fields[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		stacks[id] = string(stack)
+	}
+	return stacks
+}
+
+// ignored reports whether a goroutine belongs to the test runner or runtime
+// (pure function)
+func ignored(stack string) bool {
+	if strings.Contains(stack, "testutil.goroutines(") {
+		return true // The goroutine taking the snapshot
+	}
+	for _, fn := range ignoredGoroutines {
+		if strings.Contains(stack, fn) {
+			return true
+		}
+	}
+	return false
+}
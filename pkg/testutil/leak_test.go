@@ -0,0 +1,86 @@
+package testutil
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingTB captures what a leak check reports instead of failing the test
+type recordingTB struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (r *recordingTB) Helper()          {}
+func (r *recordingTB) Cleanup(f func()) { r.cleanups = append(r.cleanups, f) }
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// finish runs the registered cleanups like the end of a test
+func (r *recordingTB) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+// shortTimeout makes checks report leaks quickly
+func shortTimeout(t *testing.T) {
+	old := LeakTimeout
+	LeakTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { LeakTimeout = old })
+}
+
+func TestCheckGoroutines(t *testing.T) {
+	shortTimeout(t)
+
+	tb := &recordingTB{}
+	CheckGoroutines(tb)
+	done := make(chan struct{})
+	go func() { <-done }()
+	tb.finish()
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "TestCheckGoroutines") {
+		t.Errorf("expected the blocked goroutine reported, got %q", tb.errors)
+	}
+
+	close(done)
+	tb = &recordingTB{}
+	CheckGoroutines(tb)
+	go func() {}()
+	tb.finish()
+	if len(tb.errors) != 0 {
+		t.Errorf("expected no leaks once goroutines exit, got %q", tb.errors)
+	}
+}
+
+func TestCheckProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("child process listing is Linux only")
+	}
+	shortTimeout(t)
+
+	tb := &recordingTB{}
+	CheckProcesses(tb)
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep unavailable: %v", err)
+	}
+	tb.finish()
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "(sleep)") {
+		t.Errorf("expected the running child reported, got %q", tb.errors)
+	}
+
+	_ = cmd.Process.Kill()
+	tb = &recordingTB{}
+	CheckProcesses(tb)
+	tb.finish()
+	if len(tb.errors) != 0 {
+		t.Errorf("expected no new children, got %q", tb.errors)
+	}
+	_ = cmd.Wait()
+}
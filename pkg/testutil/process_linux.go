@@ -0,0 +1,65 @@
+//go:build linux
+
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ChildProcesses lists the processes whose parent is this one, read from /proc
+func ChildProcesses() ([]int, error) {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil, err
+	}
+	self := os.Getpid()
+	var children []int
+	for _, path := range stats {
+		pid, ppid, _, ok := readStat(path)
+		if ok && ppid == self {
+			children = append(children, pid)
+		}
+	}
+	return children, nil
+}
+
+// describeProcess names a process for leak reports, e.g. "1234 (echo-agent) Z"
+func describeProcess(pid int) string {
+	_, _, desc, ok := readStat(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if !ok {
+		return strconv.Itoa(pid)
+	}
+	return desc
+}
+
+// readStat parses the PID, parent PID, and "pid (comm) state" of a
+// /proc/PID/stat file
+func readStat(path string) (pid, ppid int, desc string, ok bool) {
+	data, err := os.ReadFile(path) // #nosec G304 -- paths under /proc
+	if err != nil {
+		return 0, 0, "", false
+	}
+	// The command name is parenthesized and may itself contain ") "
+	stat := string(data)
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return 0, 0, "", false
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return 0, 0, "", false
+	}
+	pid, err = strconv.Atoi(strings.Fields(stat[:end])[0])
+	if err != nil {
+		return 0, 0, "", false
+	}
+	ppid, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, "", false
+	}
+	return pid, ppid, fmt.Sprintf("%s %s", stat[:end+1], fields[0]), true
+}
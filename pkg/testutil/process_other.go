@@ -0,0 +1,20 @@
+//go:build !linux
+
+package testutil
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ChildProcesses lists the processes whose parent is this one
+// Only Linux is supported; elsewhere it returns an error and process leak
+// checks are skipped.
+func ChildProcesses() ([]int, error) {
+	return nil, errors.New("listing child processes is only supported on Linux")
+}
+
+// describeProcess names a process for leak reports
+func describeProcess(pid int) string {
+	return strconv.Itoa(pid)
+}
@@ -0,0 +1,37 @@
+// Command protospec writes the relay protocol description as JSON Schema
+// Run through go generate in pkg/relay, which sets the working directory
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/2389-research/ourocodus/pkg/relay"
+	"github.com/2389-research/ourocodus/pkg/relay/protospec"
+)
+
+func main() {
+	out := flag.String("out", "", "schema file to write (stdout if empty)")
+	src := flag.String("src", ".,../acp", "comma-separated source directories to read doc comments from")
+	flag.Parse()
+
+	docs, err := protospec.ParseDocs(strings.Split(*src, ",")...)
+	if err != nil {
+		log.Fatalf("protospec: %v", err)
+	}
+	data, err := protospec.Generate(relay.Protocol, docs)
+	if err != nil {
+		log.Fatalf("protospec: %v", err)
+	}
+
+	if *out == "" {
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+	// #nosec G306 -- the schema is a public, checked-in document
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatalf("protospec: %v", err)
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/2389-research/ourocodus/pkg/report"
 	"github.com/gorilla/websocket"
 )
 
@@ -31,13 +32,28 @@ const (
 
 var rng = rand.New(rand.NewSource(time.Now().UnixNano())) // #nosec G404 -- non-crypto RNG is sufficient for fuzzing
 
+// results collects each stage of the run for -report
+var results = &report.Report{Name: "smoketest"}
+
+// reportFormat and reportPath are set from -report and -report-file
+var reportFormat, reportPath string
+
 func main() {
 	verbose := flag.Bool("verbose", false, "emit every payload/response pair")
 	fuzzCount := flag.Int("fuzz", 100, "number of fuzzed payloads to hurl at the relay")
 	maxPayload := flag.Int("max-payload", 512*1024, "maximum payload size (bytes) used in fuzz cases")
 	seed := flag.Int64("seed", 0, "seed for fuzzing (0 = random)")
+	flag.StringVar(&reportFormat, "report", "", "also write a machine-readable report: json or junit")
+	flag.StringVar(&reportPath, "report-file", "", "file the report is written to (required with -report)")
 	flag.Parse()
 
+	if (reportFormat == "") != (reportPath == "") {
+		fail(report.ExitUsage, "🧾", "-report and -report-file must be given together")
+	}
+	if reportFormat != "" && reportFormat != report.FormatJSON && reportFormat != report.FormatJUnit {
+		fail(report.ExitUsage, "🧾", "Unknown report format %q (want json or junit)", reportFormat)
+	}
+
 	if *seed == 0 {
 		*seed = time.Now().UnixNano()
 	}
@@ -46,17 +62,17 @@ func main() {
 	debug(*verbose, "🧮", "Fuzz seed=%d maxPayload=%d fuzzCount=%d", *seed, *maxPayload, *fuzzCount)
 
 	if err := ensurePortAvailable(relayAddr); err != nil {
-		fail("🛑", "Relay port %s is already in use (%v). Stop the running relay or choose a different port before running the smoke test.", relayAddr, err)
+		fail(report.ExitError, "🛑", "Relay port %s is already in use (%v). Stop the running relay or choose a different port before running the smoke test.", relayAddr, err)
 	}
 
 	root, err := findRepoRoot()
 	if err != nil {
-		fail("🧭", "Failed to locate repo root: %v", err)
+		fail(report.ExitError, "🧭", "Failed to locate repo root: %v", err)
 	}
 
 	relayPath := filepath.Join(root, "bin", "relay")
 	if _, err := os.Stat(relayPath); err != nil {
-		fail("🔨", "Relay binary missing at %s (try `make build` first): %v", relayPath, err)
+		fail(report.ExitError, "🔨", "Relay binary missing at %s (try `make build` first): %v", relayPath, err)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -73,10 +89,10 @@ func main() {
 
 	announce("🚀", "Booting relay from %s. Hold onto your socks.", relayPath)
 	if err := cmd.Start(); err != nil {
-		fail("💥", "Relay refused to start: %v", err)
+		fail(report.ExitError, "💥", "Relay refused to start: %v", err)
 	}
 
-	defer func() {
+	stopRelay := func() {
 		cancel()
 
 		done := make(chan struct{})
@@ -92,64 +108,82 @@ func main() {
 			_ = cmd.Process.Kill()
 			<-done
 		}
-	}()
+	}
 
 	if err := waitForPort(relayAddr, startupTimeout); err != nil {
-		fail("⌛", "Relay never opened %s: %v", relayAddr, err)
+		stopRelay()
+		fail(report.ExitError, "⌛", "Relay never opened %s: %v", relayAddr, err)
 	}
 
-	if err := runSmokeTest(*verbose, *fuzzCount, *maxPayload); err != nil {
-		fail("😬", "Smoke test imploded: %v", err)
+	start := time.Now()
+	err = runSmokeTest(*verbose, *fuzzCount, *maxPayload)
+	results.Duration = time.Since(start)
+	stopRelay()
+	if err != nil {
+		fail(report.ExitFailed, "😬", "Smoke test imploded: %v", err)
 	}
 
+	writeReport()
 	success("🎉", "Smoke test passed. Confidence restored (for now).")
 }
 
 // runSmokeTest orchestrates the full WebSocket → fuzz → teardown flow.
-//
-//nolint:gocyclo // High complexity is expected due to the sequential scenario orchestration.
+// Each stage is recorded in results; the first failure ends the run, except
+// for fuzzing, whose failures are returned once the remaining stages have run.
 func runSmokeTest(verbose bool, fuzzCount int, maxPayload int) error {
-	conn, err := dialRelay()
-	if err != nil {
-		return err
-	}
+	var conn *websocket.Conn
 	defer func() {
+		if conn == nil {
+			return
+		}
 		if cerr := conn.Close(); cerr != nil {
 			warn("⚠️", "Failed to close WebSocket: %v", cerr)
 		}
 	}()
 
-	if handshakeErr := verifyHandshake(conn, verbose); handshakeErr != nil {
-		return handshakeErr
-	}
-	if echoErr := verifyEchoPath(conn, verbose); echoErr != nil {
-		return echoErr
-	}
-	if recoverErr := verifyRecoverablePath(conn, verbose); recoverErr != nil {
-		return recoverErr
-	}
-
 	if fuzzCount < 0 {
 		fuzzCount = 0
 	}
-
-	result := fuzzMessages(conn, fuzzCount, verbose, maxPayload)
-	for _, fuzzErr := range result.errors {
-		warn("⚠️", "Fuzz issue: %v", fuzzErr)
-	}
-
-	if nonRecoverErr := verifyNonRecoverablePath(conn, verbose); nonRecoverErr != nil {
-		return nonRecoverErr
-	}
-	if closedErr := ensureClosedAfterTermination(conn); closedErr != nil {
-		return closedErr
-	}
-
-	if len(result.errors) > 0 {
-		return fmt.Errorf("%d fuzz cases failed (see warnings above)", len(result.errors))
+	stages := []struct {
+		name     string
+		run      func() error
+		nonFatal bool // Later stages still run if it fails
+	}{
+		{name: "dial", run: func() (err error) { conn, err = dialRelay(); return err }},
+		{name: "handshake", run: func() error { return verifyHandshake(conn, verbose) }},
+		{name: "echo", run: func() error { return verifyEchoPath(conn, verbose) }},
+		{name: "recoverable error", run: func() error { return verifyRecoverablePath(conn, verbose) }},
+		{name: "fuzz", nonFatal: true, run: func() error {
+			result := fuzzMessages(conn, fuzzCount, verbose, maxPayload)
+			for _, fuzzErr := range result.errors {
+				warn("⚠️", "Fuzz issue: %v", fuzzErr)
+			}
+			if len(result.errors) > 0 {
+				return fmt.Errorf("%d fuzz cases failed (see warnings above)", len(result.errors))
+			}
+			return nil
+		}},
+		{name: "non-recoverable error", run: func() error { return verifyNonRecoverablePath(conn, verbose) }},
+		{name: "closed after termination", run: func() error { return ensureClosedAfterTermination(conn) }},
+	}
+
+	var deferred error
+	for i, stage := range stages {
+		start := time.Now()
+		err := stage.run()
+		results.Add(stage.name, time.Since(start), err)
+		switch {
+		case err == nil:
+		case stage.nonFatal:
+			deferred = err
+		default:
+			for _, rest := range stages[i+1:] {
+				results.Skip(rest.name, "an earlier stage failed")
+			}
+			return err
+		}
 	}
-
-	return nil
+	return deferred
 }
 
 func dialRelay() (*websocket.Conn, error) {
@@ -350,9 +384,30 @@ func success(icon, format string, args ...interface{}) {
 	fmt.Printf("%s%s %s%s\n", colorGreen, icon, fmt.Sprintf(format, args...), colorReset)
 }
 
-func fail(icon, format string, args ...interface{}) {
+// fail reports an error, writes the report and exits with code
+func fail(code int, icon, format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, "%s%s %s%s\n", colorRed, icon, fmt.Sprintf(format, args...), colorReset)
-	os.Exit(1)
+	if code != report.ExitUsage {
+		writeReport()
+	}
+	os.Exit(code)
+}
+
+// writeReport writes results to -report-file, if requested
+func writeReport() {
+	if reportFormat == "" {
+		return
+	}
+	f, err := os.Create(reportPath)
+	if err == nil {
+		err = report.Write(f, reportFormat, results)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s🧾 Failed to write report: %v%s\n", colorRed, err, colorReset)
+	}
 }
 
 func debug(verbose bool, icon, format string, args ...interface{}) {
@@ -544,7 +599,7 @@ func handleBinaryFrame(iter *fuzzIteration) error {
 func buildValidMessage(maxPayload int) map[string]interface{} {
 	msg := map[string]interface{}{
 		"version": "1.0",
-		"type":    randomType(),
+		"type":    "echo", // Other types get UNKNOWN_TYPE or a handler's reply
 		"payload": randomString(1, clamp(maxPayload/64, 16, 2048), true),
 	}
 
@@ -637,7 +692,7 @@ func sendMalformedFrame(iter *fuzzIteration, badUTF8 bool) error {
 }
 
 func sendDuplicateKeyJSON(iter *fuzzIteration) error {
-	raw := fmt.Sprintf("{\"version\":\"1.0\",\"type\":\"echo\",\"type\":\"shadow\",\"payload\":\"dup-%d\"}", iter.idx)
+	raw := fmt.Sprintf("{\"version\":\"1.0\",\"type\":\"shadow\",\"type\":\"echo\",\"payload\":\"dup-%d\"}", iter.idx)
 	debug(iter.verbose, "📤", "Fuzz #%d send (duplicate keys): %s", iter.idx, raw)
 
 	_ = iter.conn.SetWriteDeadline(time.Now().Add(requestTimeout))
@@ -653,7 +708,7 @@ func sendDuplicateKeyJSON(iter *fuzzIteration) error {
 
 	expected := map[string]interface{}{
 		"version": "1.0",
-		"type":    "shadow",
+		"type":    "echo",
 		"payload": fmt.Sprintf("dup-%d", iter.idx),
 	}
 